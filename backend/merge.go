@@ -0,0 +1,211 @@
+/*
+ * NeoDB
+ *
+ * Copyright 2021-2030 The NeoDB Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package backend
+
+import (
+	"container/heap"
+
+	"github.com/sealdb/neodb/xcontext"
+
+	"github.com/pkg/errors"
+	"github.com/sealdb/mysqlstack/driver"
+	querypb "github.com/sealdb/mysqlstack/sqlparser/depends/query"
+	"github.com/sealdb/mysqlstack/sqlparser/depends/sqltypes"
+)
+
+// rowStream is a stream of the rows sorted by a backend, Next returns nil at the end.
+type rowStream interface {
+	Next() ([]sqltypes.Value, error)
+}
+
+// resultStream is the stream of the buffered rows.
+type resultStream struct {
+	rows [][]sqltypes.Value
+}
+
+// Next implements the rowStream interface.
+func (s *resultStream) Next() ([]sqltypes.Value, error) {
+	if len(s.rows) == 0 {
+		return nil, nil
+	}
+	row := s.rows[0]
+	s.rows[0] = nil
+	s.rows = s.rows[1:]
+	return row, nil
+}
+
+// cursorStream is the stream of the rows fetched by the cursor.
+type cursorStream struct {
+	cursor driver.Rows
+}
+
+// Next implements the rowStream interface.
+func (s *cursorStream) Next() ([]sqltypes.Value, error) {
+	if !s.cursor.Next() {
+		return nil, s.cursor.LastError()
+	}
+	return s.cursor.RowValues()
+}
+
+// mergeHead is the current row of a stream.
+type mergeHead struct {
+	row []sqltypes.Value
+	idx int
+}
+
+// mergeHeap is the min-heap of the heads of the streams, the ties are
+// broken by the stream index to keep the merge deterministic.
+type mergeHeap struct {
+	heads []mergeHead
+	cmp   func(a, b []sqltypes.Value) int
+}
+
+func (h *mergeHeap) Len() int      { return len(h.heads) }
+func (h *mergeHeap) Swap(i, j int) { h.heads[i], h.heads[j] = h.heads[j], h.heads[i] }
+func (h *mergeHeap) Less(i, j int) bool {
+	if cmp := h.cmp(h.heads[i].row, h.heads[j].row); cmp != 0 {
+		return cmp < 0
+	}
+	return h.heads[i].idx < h.heads[j].idx
+}
+func (h *mergeHeap) Push(x interface{}) { h.heads = append(h.heads, x.(mergeHead)) }
+func (h *mergeHeap) Pop() interface{} {
+	n := len(h.heads)
+	head := h.heads[n-1]
+	h.heads = h.heads[:n-1]
+	return head
+}
+
+// rowMerger used to merge the sorted streams by the k-way merge, the heap only holds
+// the current row of each stream, the offset and limit are applied to the merged rows.
+type rowMerger struct {
+	streams []rowStream
+	heap    *mergeHeap
+	offset  int
+	limit   int
+	count   int
+	started bool
+}
+
+// newRowMerger creates the rowMerger by the order of the request.
+func newRowMerger(fields []*querypb.Field, streams []rowStream, req *xcontext.RequestContext) (*rowMerger, error) {
+	idxs := make([]int, len(req.OrderBy))
+	for i, key := range req.OrderBy {
+		idx := -1
+		for k, f := range fields {
+			if f.Name == key.Field && (key.Table == "" || key.Table == f.Table) {
+				idx = k
+				break
+			}
+		}
+		if idx == -1 {
+			return nil, errors.Errorf("can.not.find.the.orderby.field[%s].direction.asc", key.Field)
+		}
+		idxs[i] = idx
+	}
+
+	cmp := func(a, b []sqltypes.Value) int {
+		for i, key := range req.OrderBy {
+			cmp := sqltypes.NullsafeCompare(a[idxs[i]], b[idxs[i]])
+			if cmp == 0 {
+				continue
+			}
+			if key.Desc {
+				cmp = -cmp
+			}
+			return cmp
+		}
+		return 0
+	}
+	return &rowMerger{
+		streams: streams,
+		heap:    &mergeHeap{cmp: cmp},
+		offset:  req.Offset,
+		limit:   req.Limit,
+	}, nil
+}
+
+// Next returns the next merged row, nil at the end.
+func (m *rowMerger) Next() ([]sqltypes.Value, error) {
+	if !m.started {
+		m.started = true
+		for i, stream := range m.streams {
+			if err := m.advance(i, stream); err != nil {
+				return nil, err
+			}
+		}
+		for ; m.offset > 0; m.offset-- {
+			if _, err := m.pop(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if m.limit >= 0 && m.count >= m.limit {
+		return nil, nil
+	}
+	row, err := m.pop()
+	if row != nil {
+		m.count++
+	}
+	return row, err
+}
+
+// pop returns the smallest head and advances its stream.
+func (m *rowMerger) pop() ([]sqltypes.Value, error) {
+	if m.heap.Len() == 0 {
+		return nil, nil
+	}
+	head := heap.Pop(m.heap).(mergeHead)
+	if err := m.advance(head.idx, m.streams[head.idx]); err != nil {
+		return nil, err
+	}
+	return head.row, nil
+}
+
+// advance pushes the next row of the stream into the heap.
+func (m *rowMerger) advance(idx int, stream rowStream) error {
+	row, err := stream.Next()
+	if err != nil || row == nil {
+		return err
+	}
+	heap.Push(m.heap, mergeHead{row: row, idx: idx})
+	return nil
+}
+
+// mergeResults used to merge the sorted results of the backends into one result.
+func mergeResults(results []*sqltypes.Result, req *xcontext.RequestContext) (*sqltypes.Result, error) {
+	qr := &sqltypes.Result{}
+	streams := make([]rowStream, 0, len(results))
+	for _, r := range results {
+		if qr.Fields == nil {
+			qr.Fields = r.Fields
+		}
+		streams = append(streams, &resultStream{rows: r.Rows})
+	}
+	if qr.Fields == nil {
+		return qr, nil
+	}
+
+	merger, err := newRowMerger(qr.Fields, streams, req)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		row, err := merger.Next()
+		if err != nil {
+			return nil, err
+		}
+		if row == nil {
+			break
+		}
+		qr.Rows = append(qr.Rows, row)
+	}
+	qr.RowsAffected = uint64(len(qr.Rows))
+	return qr, nil
+}
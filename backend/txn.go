@@ -15,11 +15,13 @@ import (
 	"time"
 
 	"github.com/sealdb/neodb/config"
+	"github.com/sealdb/neodb/xbase/spill"
 	"github.com/sealdb/neodb/xbase/sync2"
 	"github.com/sealdb/neodb/xcontext"
 
 	"github.com/pkg/errors"
 	"github.com/sealdb/mysqlstack/driver"
+	querypb "github.com/sealdb/mysqlstack/sqlparser/depends/query"
 	"github.com/sealdb/mysqlstack/sqlparser/depends/sqltypes"
 	"github.com/sealdb/mysqlstack/xlog"
 	"golang.org/x/sync/errgroup"
@@ -49,6 +51,20 @@ const (
 	txnStateRecovering
 )
 
+const (
+	// DefaultGroupConcatMaxLen is the default max length of the GROUP_CONCAT result, same as MySQL.
+	DefaultGroupConcatMaxLen = 1024
+	// DefaultJoinBatchSize is the default count of the left join keys looked up in one batch
+	// by the nested loop join, 0 means looking up the keys one by one.
+	DefaultJoinBatchSize = 100
+	// DefaultMaxShardKeyUpdateRows is the default maximum number of rows moved between
+	// the partitions by one UPDATE of the shard key.
+	DefaultMaxShardKeyUpdateRows = 1000
+	// DefaultMaxRecursionDepth is the default maximum number of the iterations of the
+	// recursive common table expression, same as MySQL.
+	DefaultMaxRecursionDepth = 1000
+)
+
 // Transaction interface.
 type Transaction interface {
 	XID() string
@@ -71,8 +87,24 @@ type Transaction interface {
 	SetIsExecOnRep(isExecOnRep bool)
 	SetTimeout(timeout int)
 	SetMaxResult(max int)
+	MaxResult() int
 	SetMaxJoinRows(max int)
 	MaxJoinRows() int
+	SetGroupConcatMaxLen(max int)
+	GroupConcatMaxLen() int
+	SetJoinBatchSize(size int)
+	JoinBatchSize() int
+	SetShardKeyUpdate(enable bool)
+	ShardKeyUpdate() bool
+	SetMaxShardKeyUpdateRows(max int)
+	MaxShardKeyUpdateRows() int
+	SetMaxRecursionDepth(max int)
+	MaxRecursionDepth() int
+	IsTwoPC() bool
+	SetMaxQueryMemory(max int)
+	SetSpillDir(dir string)
+	SetMaxSpillSize(max int)
+	MemTracker() *spill.Tracker
 
 	Execute(req *xcontext.RequestContext) (*sqltypes.Result, error)
 	ExecuteRaw(database string, query string) (*sqltypes.Result, error)
@@ -98,6 +130,12 @@ type Txn struct {
 	timeout            int
 	maxResult          int
 	maxJoinRows        int
+	groupConcatMaxLen  int
+	joinBatchSize      int
+	shardKeyUpdate     bool
+	maxShardKeyRows    int
+	maxRecursionDepth  int
+	memTracker         *spill.Tracker
 	errors             int
 	twopcConnections   map[string]Connection
 	normalConnections  []Connection
@@ -119,6 +157,11 @@ func NewTxn(log *xlog.Log, txid uint64, mgr *TxnManager, backends map[string]*Po
 		normalConnections:  make([]Connection, 0, 8),
 		replicaConnections: make([]Connection, 0, 8),
 		state:              sync2.NewAtomicInt32(int32(txnStateLive)),
+		groupConcatMaxLen:  DefaultGroupConcatMaxLen,
+		joinBatchSize:      DefaultJoinBatchSize,
+		maxShardKeyRows:    DefaultMaxShardKeyUpdateRows,
+		maxRecursionDepth:  DefaultMaxRecursionDepth,
+		memTracker:         spill.NewTracker(0, ""),
 	}
 	txnd := NewTxnDetail(txn)
 	txn.txnd = txnd
@@ -142,6 +185,11 @@ func (txn *Txn) SetMaxResult(max int) {
 	txn.maxResult = max
 }
 
+// MaxResult returns txn maxResult.
+func (txn *Txn) MaxResult() int {
+	return txn.maxResult
+}
+
 // SetMaxJoinRows used to set the txn max join rows.
 func (txn *Txn) SetMaxJoinRows(max int) {
 	txn.maxJoinRows = max
@@ -152,6 +200,84 @@ func (txn *Txn) MaxJoinRows() int {
 	return txn.maxJoinRows
 }
 
+// SetGroupConcatMaxLen used to set the txn max length of the GROUP_CONCAT result.
+func (txn *Txn) SetGroupConcatMaxLen(max int) {
+	txn.groupConcatMaxLen = max
+}
+
+// GroupConcatMaxLen returns txn groupConcatMaxLen.
+func (txn *Txn) GroupConcatMaxLen() int {
+	return txn.groupConcatMaxLen
+}
+
+// SetJoinBatchSize used to set the txn batch size of the nested loop join.
+func (txn *Txn) SetJoinBatchSize(size int) {
+	txn.joinBatchSize = size
+}
+
+// JoinBatchSize returns txn joinBatchSize.
+func (txn *Txn) JoinBatchSize() int {
+	return txn.joinBatchSize
+}
+
+// SetShardKeyUpdate used to enable the UPDATE of the shard key, the rows are moved
+// between the partitions.
+func (txn *Txn) SetShardKeyUpdate(enable bool) {
+	txn.shardKeyUpdate = enable
+}
+
+// ShardKeyUpdate returns true if the UPDATE of the shard key is enabled.
+func (txn *Txn) ShardKeyUpdate() bool {
+	return txn.shardKeyUpdate
+}
+
+// SetMaxShardKeyUpdateRows used to set the max rows moved by one UPDATE of the shard key.
+func (txn *Txn) SetMaxShardKeyUpdateRows(max int) {
+	txn.maxShardKeyRows = max
+}
+
+// MaxShardKeyUpdateRows returns txn maxShardKeyRows.
+func (txn *Txn) MaxShardKeyUpdateRows() int {
+	return txn.maxShardKeyRows
+}
+
+// SetMaxRecursionDepth used to set the max iterations of the recursive cte.
+func (txn *Txn) SetMaxRecursionDepth(max int) {
+	txn.maxRecursionDepth = max
+}
+
+// MaxRecursionDepth returns txn maxRecursionDepth.
+func (txn *Txn) MaxRecursionDepth() int {
+	return txn.maxRecursionDepth
+}
+
+// IsTwoPC returns true if the txn is a XA transaction.
+func (txn *Txn) IsTwoPC() bool {
+	return txn.twopc
+}
+
+// SetMaxQueryMemory used to set the memory limit(in bytes) of the query, the joins,
+// sorts and aggregates spill to disk once exceeded, 0 means unlimited.
+func (txn *Txn) SetMaxQueryMemory(max int) {
+	txn.memTracker.SetLimit(int64(max))
+}
+
+// SetSpillDir used to set the dir of the spilled temp files.
+func (txn *Txn) SetSpillDir(dir string) {
+	txn.memTracker.SetDir(dir)
+}
+
+// SetMaxSpillSize used to set the limit(in bytes) of the temp files spilled by the query,
+// the query fails once exceeded, 0 means unlimited.
+func (txn *Txn) SetMaxSpillSize(max int) {
+	txn.memTracker.SetDiskLimit(int64(max))
+}
+
+// MemTracker returns the memory tracker of the txn.
+func (txn *Txn) MemTracker() *spill.Tracker {
+	return txn.memTracker
+}
+
 // TxID returns txn id.
 func (txn *Txn) TxID() uint64 {
 	return txn.id
@@ -344,6 +470,11 @@ func (txn *Txn) RollbackPhaseOne() error {
 	log := txn.log
 	txn.state.Set(int32(txnStateRollbacking))
 
+	// The statement failed before executing any query.
+	if txn.req == nil {
+		return nil
+	}
+
 	// Here, we only handle the write-txn.
 	// Rollback nothing for read-txn.
 	switch txn.req.TxnMode {
@@ -437,6 +568,7 @@ func (txn *Txn) Execute(req *xcontext.RequestContext) (*sqltypes.Result, error)
 	if txn.twopc {
 		// DATA RACE in the same txn e.g, UNION etc.
 		txn.mu.Lock()
+		prev := txn.req
 		txn.req = req
 		txn.mu.Unlock()
 
@@ -448,7 +580,12 @@ func (txn *Txn) Execute(req *xcontext.RequestContext) (*sqltypes.Result, error)
 		case xcontext.TxnWrite:
 			// write-txn xa starts to the single statement.
 			if !txn.isMultiStmtTxn {
-				if err := txn.xaStart(); err != nil {
+				if prev != nil && prev.TxnMode == xcontext.TxnWrite {
+					// The statement writes more than once, the later writes join the XA transaction.
+					if err := txn.xaJoin(prev); err != nil {
+						return nil, err
+					}
+				} else if err := txn.xaStart(); err != nil {
 					return nil, err
 				}
 			}
@@ -469,6 +606,16 @@ func (txn *Txn) execute(req *xcontext.RequestContext) (*sqltypes.Result, error)
 
 	log := txn.log
 	qr := &sqltypes.Result{}
+	// The sorted results are merged after all the shards finished.
+	var results []*sqltypes.Result
+	// The bytes of the results of all the shards, limited by the maxResult.
+	size := 0
+	done := func(err error) (*sqltypes.Result, error) {
+		if err != nil || len(req.OrderBy) == 0 {
+			return qr, err
+		}
+		return mergeResults(results, req)
+	}
 
 	if txn.twopc {
 		defer queryStats.Record("txn.2pc.execute", time.Now())
@@ -479,24 +626,37 @@ func (txn *Txn) execute(req *xcontext.RequestContext) (*sqltypes.Result, error)
 	}
 
 	// Execute backend-querys.
-	oneShard := func(back string, txn *Txn, querys []string) error {
+	oneShard := func(back string, txn *Txn, querys []xcontext.QueryTuple) error {
 		var x error
 		var c Connection
 
 		if c, x = txn.fetchOneConnection(back); x != nil {
 			log.Error("txn.fetch.connection.on[%s].querys[%v].error:%+v", back, querys, x)
 		} else {
-			log.Debug("conn[%v].txn.sessid[%v].execute[%v]", c.ID(), txn.sessionID, querys[0])
+			log.Debug("conn[%v].txn.sessid[%v].execute[%v]", c.ID(), txn.sessionID, querys[0].Query)
 			for _, query := range querys {
 				var innerqr *sqltypes.Result
 
 				// Execute to backends.
-				if innerqr, x = c.ExecuteWithLimits(query, txn.timeout, txn.maxResult); x != nil {
-					log.Error("txn.execute.on[%v].query[%v].error:%+v", c.Address(), query, x)
+				if innerqr, x = c.ExecuteWithLimits(query.Query, txn.timeout, txn.maxResult); x != nil {
+					log.Error("txn.execute.on[%v].query[%v].error:%+v", c.Address(), query.Query, x)
 					break
 				}
+				if query.Discard {
+					continue
+				}
 				mu.Lock()
-				qr.AppendResult(innerqr)
+				size += resultSize(innerqr)
+				if txn.maxResult > 0 && size > txn.maxResult {
+					mu.Unlock()
+					x = errors.Errorf("Query execution was interrupted, max memory usage[%d bytes] exceeded", txn.maxResult)
+					break
+				}
+				if len(req.OrderBy) > 0 {
+					results = append(results, innerqr)
+				} else {
+					qr.AppendResult(innerqr)
+				}
 				mu.Unlock()
 			}
 		}
@@ -507,16 +667,16 @@ func (txn *Txn) execute(req *xcontext.RequestContext) (*sqltypes.Result, error)
 	// ReqSingle mode: execute on one of the txn.backends,
 	// it is random sometimes, be careful.
 	case xcontext.ReqSingle:
-		qs := []string{req.RawQuery}
+		qs := []xcontext.QueryTuple{{Query: req.RawQuery}}
 		for back, poolz := range txn.backends {
 			if poolz.conf.Role != config.NormalBackend {
 				continue
 			}
-			return qr, oneShard(back, txn, qs)
+			return done(oneShard(back, txn, qs))
 		}
 	// ReqScatter mode: execute on the all shards of txn.backends.
 	case xcontext.ReqScatter:
-		qs := []string{req.RawQuery}
+		qs := []xcontext.QueryTuple{{Query: req.RawQuery}}
 		beLen := len(txn.backends)
 		for b, poolz := range txn.backends {
 			if poolz.conf.Role != config.NormalBackend {
@@ -529,19 +689,19 @@ func (txn *Txn) execute(req *xcontext.RequestContext) (*sqltypes.Result, error)
 					return oneShard(back, txn, qs)
 				})
 			} else {
-				return qr, oneShard(back, txn, qs)
+				return done(oneShard(back, txn, qs))
 			}
 		}
 	// ReqNormal mode: execute on the some shards of txn.backends.
 	case xcontext.ReqNormal:
-		queryMap := make(map[string][]string)
+		queryMap := make(map[string][]xcontext.QueryTuple)
 		for _, query := range req.Querys {
 			v, ok := queryMap[query.Backend]
 			if !ok {
-				v = make([]string, 0, 4)
-				v = append(v, query.Query)
+				v = make([]xcontext.QueryTuple, 0, 4)
+				v = append(v, query)
 			} else {
-				v = append(v, query.Query)
+				v = append(v, query)
 			}
 			queryMap[query.Backend] = v
 		}
@@ -554,11 +714,22 @@ func (txn *Txn) execute(req *xcontext.RequestContext) (*sqltypes.Result, error)
 					return oneShard(back, txn, querys)
 				})
 			} else {
-				return qr, oneShard(back, txn, qs)
+				return done(oneShard(back, txn, qs))
 			}
 		}
 	}
-	return qr, eg.Wait()
+	return done(eg.Wait())
+}
+
+// resultSize returns the bytes of the values of the result.
+func resultSize(qr *sqltypes.Result) int {
+	size := 0
+	for _, row := range qr.Rows {
+		for _, val := range row {
+			size += val.Len()
+		}
+	}
+	return size
 }
 
 // ExecuteStreamFetch used to execute stream fetch query.
@@ -607,6 +778,11 @@ func (txn *Txn) ExecuteStreamFetch(req *xcontext.RequestContext, callback func(*
 		return err
 	}
 
+	// Send the rows merged by the order.
+	if len(req.OrderBy) > 0 {
+		return txn.streamMerge(req, cursors, fields, callback, streamBufferSize)
+	}
+
 	// Send rows.
 	cursorFinished := 0
 	rows := make(chan []sqltypes.Value, 65536)
@@ -697,6 +873,55 @@ func (txn *Txn) ExecuteStreamFetch(req *xcontext.RequestContext, callback func(*
 	return callback(finishQr)
 }
 
+// streamMerge used to send the rows merged from the sorted cursors, the cursors are
+// fetched one row at a time, and stop once the limit is reached.
+func (txn *Txn) streamMerge(req *xcontext.RequestContext, cursors []driver.Rows, fields []*querypb.Field, callback func(*sqltypes.Result) error, streamBufferSize int) error {
+	log := txn.log
+	streams := make([]rowStream, len(cursors))
+	for i, cursor := range cursors {
+		streams[i] = &cursorStream{cursor: cursor}
+	}
+	merger, err := newRowMerger(fields, streams, req)
+	if err != nil {
+		return err
+	}
+
+	var allRowCount uint64
+	byteCount := 0
+	qr := &sqltypes.Result{Fields: fields, Rows: make([][]sqltypes.Value, 0, 256), State: sqltypes.RStateRows}
+	for {
+		row, err := merger.Next()
+		if err != nil {
+			log.Error("txn.stream.merge.cursor.error:%+v", err)
+			return err
+		}
+		if row == nil {
+			break
+		}
+		allRowCount++
+		byteCount += sqltypes.Values(row).Len()
+		qr.Rows = append(qr.Rows, row)
+		if byteCount >= streamBufferSize {
+			if err := callback(qr); err != nil {
+				log.Error("txn.stream.merge.send.error:%+v", err)
+				return err
+			}
+			qr.Rows = qr.Rows[:0]
+			byteCount = 0
+		}
+	}
+	if len(qr.Rows) > 0 {
+		if err := callback(qr); err != nil {
+			log.Error("txn.stream.merge.send.error:%+v", err)
+			return err
+		}
+	}
+
+	// Send finished.
+	finishQr := &sqltypes.Result{Fields: fields, RowsAffected: allRowCount, State: sqltypes.RStateFinished}
+	return callback(finishQr)
+}
+
 // ExecuteScatter used to execute query on all shards.
 func (txn *Txn) ExecuteScatter(query string) (*sqltypes.Result, error) {
 	rctx := &xcontext.RequestContext{
@@ -413,6 +413,104 @@ func TestTxnExecuteStreamFetch(t *testing.T) {
 	}
 }
 
+func TestTxnExecuteMergeOrder(t *testing.T) {
+	defer leaktest.Check(t)()
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	fakedb, txnMgr, backends, addrs, cleanup := MockTxnMgr(log, 2)
+	defer cleanup()
+
+	querys := []xcontext.QueryTuple{
+		{Query: "select id, name from node1 order by id desc", Backend: addrs[0]},
+		{Query: "select id, name from node2 order by id desc", Backend: addrs[1]},
+		{Query: "select id, name from node3 order by id desc", Backend: addrs[1]},
+	}
+	// Each shard returns the ids sorted in descending order.
+	newResult := func(name string, ids ...int) *sqltypes.Result {
+		res := &sqltypes.Result{
+			Fields: []*querypb.Field{
+				{Name: "id", Type: querypb.Type_INT32},
+				{Name: "name", Type: querypb.Type_VARCHAR},
+			},
+		}
+		for _, id := range ids {
+			res.Rows = append(res.Rows, []sqltypes.Value{
+				sqltypes.MakeTrusted(querypb.Type_INT32, []byte(fmt.Sprintf("%d", id))),
+				sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(name)),
+			})
+		}
+		return res
+	}
+	results := []*sqltypes.Result{
+		newResult("a", 9, 6, 3, 0),
+		newResult("b", 10, 7, 4, 1),
+		newResult("c", 8, 5, 2),
+	}
+	orderBy := []xcontext.OrderKey{{Field: "id", Desc: true}}
+
+	// Buffered.
+	{
+		for i, query := range querys {
+			fakedb.AddQuery(query.Query, results[i])
+		}
+		txn, err := txnMgr.CreateTxn(backends)
+		assert.Nil(t, err)
+		defer txn.Finish()
+
+		rctx := &xcontext.RequestContext{
+			Querys:  querys,
+			OrderBy: orderBy,
+			Limit:   5,
+		}
+		qr, err := txn.Execute(rctx)
+		assert.Nil(t, err)
+		assert.Equal(t, "[[10 b] [9 a] [8 c] [7 b] [6 a]]", fmt.Sprintf("%v", qr.Rows))
+		assert.Equal(t, uint64(5), qr.RowsAffected)
+
+		// Unlimited.
+		rctx.Limit = -1
+		qr, err = txn.Execute(rctx)
+		assert.Nil(t, err)
+		assert.Equal(t, 11, len(qr.Rows))
+		for i, row := range qr.Rows {
+			assert.Equal(t, fmt.Sprintf("%d", 10-i), row[0].String())
+		}
+
+		// Unknown field.
+		rctx.OrderBy = []xcontext.OrderKey{{Field: "xx"}}
+		_, err = txn.Execute(rctx)
+		assert.Equal(t, "can.not.find.the.orderby.field[xx].direction.asc", err.Error())
+	}
+
+	// Stream fetch.
+	{
+		for i, query := range querys {
+			fakedb.AddQueryStream(query.Query, results[i])
+		}
+		txn, err := txnMgr.CreateTxn(backends)
+		assert.Nil(t, err)
+		defer txn.Finish()
+
+		rctx := &xcontext.RequestContext{
+			Querys:  querys,
+			OrderBy: orderBy,
+			Offset:  2,
+			Limit:   5,
+		}
+		batches := 0
+		callbackQr := &sqltypes.Result{}
+		err = txn.ExecuteStreamFetch(rctx, func(qr *sqltypes.Result) error {
+			if qr.State == sqltypes.RStateRows {
+				batches++
+			}
+			callbackQr.AppendResult(qr)
+			return nil
+		}, 4)
+		assert.Nil(t, err)
+		assert.Equal(t, "[[8 c] [7 b] [6 a] [5 c] [4 b]]", fmt.Sprintf("%v", callbackQr.Rows))
+		assert.Equal(t, 3, batches)
+	}
+}
+
 func TestTxnNormalError(t *testing.T) {
 	defer leaktest.Check(t)()
 	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
@@ -575,6 +673,14 @@ func TestTxnSetting(t *testing.T) {
 		}
 	}
 
+	// The results of the shards are under the max result size, but their sum exceeds it.
+	{
+		txn.SetMaxResult(40)
+		_, err := txn.ExecuteScatter(query)
+		want := "Query execution was interrupted, max memory usage[40 bytes] exceeded"
+		assert.Equal(t, want, err.Error())
+	}
+
 	{
 		txn.SetSessionID(1)
 	}
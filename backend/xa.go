@@ -60,6 +60,7 @@ func (txn *Txn) executeXACommand(query string, state txnXAState) error {
 		RawQuery: query,
 		Mode:     txn.req.Mode,
 		Querys:   txn.req.Querys,
+		ForceXA:  txn.req.ForceXA,
 	}
 	return txn.executeXA(rctx, state)
 }
@@ -137,7 +138,7 @@ func (txn *Txn) executeXA(req *xcontext.RequestContext, state txnXAState) error
 
 		// Only do XA when Querys's backends numbers larger than one.
 		beLen := len(backends)
-		if beLen > 1 {
+		if beLen > 1 || req.ForceXA {
 			switch state {
 			case txnXAStateCommit, txnXAStateRollback:
 				// Acquire the commit lock if the txn is write.
@@ -192,6 +193,71 @@ func (txn *Txn) xaStart() error {
 	return nil
 }
 
+// xaJoin used to start the XA transaction on the backends which are new to the statement
+// writing more than once.
+func (txn *Txn) xaJoin(prev *xcontext.RequestContext) error {
+	log := txn.log
+	req := txn.req
+	// The XA commands of the statement are sent to all the backends written.
+	merged := &xcontext.RequestContext{
+		RawQuery: req.RawQuery,
+		Mode:     req.Mode,
+		TxnMode:  req.TxnMode,
+		Querys:   append(append([]xcontext.QueryTuple{}, prev.Querys...), req.Querys...),
+		ForceXA:  prev.ForceXA || req.ForceXA,
+	}
+	if prev.Mode == xcontext.ReqScatter {
+		merged.Mode = xcontext.ReqScatter
+	}
+	txn.mu.Lock()
+	txn.req = merged
+	txn.mu.Unlock()
+
+	started := txn.xaBackends(prev)
+	var joins []xcontext.QueryTuple
+	for back := range txn.xaBackends(merged) {
+		if !started[back] {
+			joins = append(joins, xcontext.QueryTuple{Backend: back})
+		}
+	}
+	if len(joins) > 0 {
+		txnCounters.Add(txnCounterXaStart, 1)
+		start := fmt.Sprintf("XA START '%v'", txn.xid)
+		rctx := &xcontext.RequestContext{
+			RawQuery: start,
+			Mode:     xcontext.ReqNormal,
+			Querys:   joins,
+			ForceXA:  true,
+		}
+		if err := txn.executeXA(rctx, txnXAStateStart); err != nil {
+			log.Error("xa.join[%v].error:%v", start, err)
+			txnCounters.Add(txnCounterXaStartError, 1)
+			txn.incErrors()
+			return err
+		}
+	}
+	return nil
+}
+
+// xaBackends returns the backends which the XA commands of the request are sent to.
+func (txn *Txn) xaBackends(req *xcontext.RequestContext) map[string]bool {
+	backends := make(map[string]bool)
+	switch req.Mode {
+	case xcontext.ReqNormal:
+		for _, query := range req.Querys {
+			backends[query.Backend] = true
+		}
+		if len(backends) < 2 && !req.ForceXA {
+			return nil
+		}
+	case xcontext.ReqScatter:
+		for back := range txn.backends {
+			backends[back] = true
+		}
+	}
+	return backends
+}
+
 func (txn *Txn) xaEnd() error {
 	log := txn.log
 	txnCounters.Add(txnCounterXaEnd, 1)
@@ -11,6 +11,7 @@ package backend
 
 import (
 	"errors"
+	"fmt"
 	"strings"
 	"sync"
 	"testing"
@@ -461,6 +462,58 @@ func TestTxnXAExecuteWrite(t *testing.T) {
 	}
 }
 
+func TestTxnXAExecuteJoin(t *testing.T) {
+	defer leaktest.Check(t)()
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	fakedb, txnMgr, backends, addrs, cleanup := MockTxnMgr(log, 2)
+	defer cleanup()
+
+	fakedb.AddQueryPattern("select .*", result2)
+	fakedb.AddQueryPattern("delete .*", result1)
+	fakedb.AddQueryPattern("insert .*", result1)
+	fakedb.AddQueryPattern("XA .*", result1)
+
+	txn, err := txnMgr.CreateTxn(backends)
+	assert.Nil(t, err)
+	defer txn.Finish()
+
+	err = txn.Begin()
+	assert.Nil(t, err)
+
+	// The rows are read for update on one backend in the XA transaction.
+	{
+		rctx := &xcontext.RequestContext{
+			TxnMode: xcontext.TxnWrite,
+			Querys:  []xcontext.QueryTuple{{Query: "select * from node1 for update", Backend: addrs[0]}},
+			ForceXA: true,
+		}
+		_, err := txn.Execute(rctx)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, fakedb.GetQueryCalledNum(fmt.Sprintf("XA START '%v'", txn.XID())))
+	}
+
+	// The later write joins the other backend to the XA transaction.
+	{
+		rctx := &xcontext.RequestContext{
+			TxnMode: xcontext.TxnWrite,
+			Querys: []xcontext.QueryTuple{
+				{Query: "delete from node1", Backend: addrs[0]},
+				{Query: "insert into node2", Backend: addrs[1]},
+			},
+		}
+		_, err := txn.Execute(rctx)
+		assert.Nil(t, err)
+		assert.Equal(t, 2, fakedb.GetQueryCalledNum(fmt.Sprintf("XA START '%v'", txn.XID())))
+	}
+
+	// 2PC Commit on both the backends.
+	{
+		err := txn.Commit()
+		assert.Nil(t, err)
+		assert.Equal(t, 2, fakedb.GetQueryCalledNum(fmt.Sprintf("XA COMMIT '%v'", txn.XID())))
+	}
+}
+
 func TestTxnXAExecuteScatterOnOneBackend(t *testing.T) {
 	defer leaktest.Check(t)()
 	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
@@ -41,6 +41,16 @@ type ProxyConfig struct {
 	LongQueryTime    int    `json:"long-query-time"`
 	StreamBufferSize int    `json:"stream-buffer-size"`
 	IdleTxnTimeout   uint32 `json:"kill-idle-transaction"` //is consistent with the official 8.0 kill_idle_transaction
+	MaxQueryMemory   int    `json:"max-query-memory"`      // 0 -- disable spilling to disk
+	SpillDir         string `json:"spill-dir"`
+	MaxSpillSize     int    `json:"max-spill-size"` // 0 -- unlimited
+	// The maximum rows moved between the partitions by one UPDATE of the shard key.
+	MaxShardKeyUpdateRows int `json:"max-shard-key-update-rows"`
+	// The maximum iterations of the recursive common table expression.
+	CTEMaxRecursionDepth int `json:"cte-max-recursion-depth"`
+	// If interval-scheduler=true (false by default), the node premakes and retires the partitions
+	// of the interval tables every minute, only one node of the cluster should enable it.
+	IntervalScheduler bool `json:"interval-scheduler"`
 
 	//If autocommit-false-is-txn=true (false by default), a client connection with cmd: set autocommit=0
 	//is treated as start a transaction, e.g. begin, start transaction.
@@ -50,19 +60,23 @@ type ProxyConfig struct {
 // DefaultProxyConfig returns default proxy config.
 func DefaultProxyConfig() *ProxyConfig {
 	return &ProxyConfig{
-		MetaDir:             "./neodb-meta",
-		Endpoint:            "127.0.0.1:3308",
-		LoadBalance:         0,
-		LowerCaseTableNames: 0,
-		MaxConnections:      1024,
-		MaxResultSize:       1024 * 1024 * 1024, // 1GB
-		MaxJoinRows:         32768,
-		DDLTimeout:          10 * 3600 * 1000, // 10hours
-		QueryTimeout:        5 * 60 * 1000,    // 5minutes
-		PeerAddress:         "127.0.0.1:8080",
-		LongQueryTime:       5,                // 5 seconds
-		StreamBufferSize:    1024 * 1024 * 32, // 32MB
-		IdleTxnTimeout:      60,               // 60 seconds
+		MetaDir:               "./neodb-meta",
+		Endpoint:              "127.0.0.1:3308",
+		LoadBalance:           0,
+		LowerCaseTableNames:   0,
+		MaxConnections:        1024,
+		MaxResultSize:         1024 * 1024 * 1024, // 1GB
+		MaxJoinRows:           32768,
+		DDLTimeout:            10 * 3600 * 1000, // 10hours
+		QueryTimeout:          5 * 60 * 1000,    // 5minutes
+		PeerAddress:           "127.0.0.1:8080",
+		LongQueryTime:         5,                // 5 seconds
+		StreamBufferSize:      1024 * 1024 * 32, // 32MB
+		IdleTxnTimeout:        60,               // 60 seconds
+		SpillDir:              "./neodb-spill",
+		MaxSpillSize:          10 * 1024 * 1024 * 1024, // 10GB
+		MaxShardKeyUpdateRows: 1000,
+		CTEMaxRecursionDepth:  1000,
 	}
 }
 
@@ -176,6 +190,10 @@ type PartitionConfig struct {
 	Segment   string `json:"segment"`
 	Backend   string `json:"backend"`
 	ListValue string `json:"listvalue"`
+	// ListValues is the value set of the list partition, the single ListValue is kept for compatibility.
+	ListValues  []string `json:"listvalues,omitempty"`
+	ListDefault bool     `json:"listdefault,omitempty"`
+	LessThan    string   `json:"lessthan,omitempty"`
 }
 
 // AutoIncrement tuple.
@@ -183,15 +201,37 @@ type AutoIncrement struct {
 	Column string `json:"column"`
 }
 
+// IntervalConfig tuple.
+type IntervalConfig struct {
+	Unit      string `json:"unit"`
+	Premake   int    `json:"premake"`
+	Retention int    `json:"retention"`
+	Detach    bool   `json:"detach,omitempty"`
+}
+
+// LookupIndexConfig tuple.
+// The lookup table Name maps the Column to the shard key of the table.
+// The Unique index(UNIQUE GLOBAL) keeps the Column unique across all the partitions.
+type LookupIndexConfig struct {
+	Name     string `json:"name"`
+	Column   string `json:"column"`
+	Unique   bool   `json:"unique,omitempty"`
+	Building bool   `json:"building,omitempty"`
+}
+
 // TableConfig tuple.
 type TableConfig struct {
-	Name          string             `json:"name"`
-	Slots         int                `json:"slots-readonly"`
-	Blocks        int                `json:"blocks-readonly"`
-	ShardType     string             `json:"shardtype"`
-	ShardKey      string             `json:"shardkey"`
-	Partitions    []*PartitionConfig `json:"partitions"`
-	AutoIncrement *AutoIncrement     `json:"auto-increment,omitempty"`
+	Name          string               `json:"name"`
+	Slots         int                  `json:"slots-readonly"`
+	Blocks        int                  `json:"blocks-readonly"`
+	ShardType     string               `json:"shardtype"`
+	ShardKey      string               `json:"shardkey"`
+	Partitions    []*PartitionConfig   `json:"partitions"`
+	AutoIncrement *AutoIncrement       `json:"auto-increment,omitempty"`
+	ColocateGroup string               `json:"colocate-group,omitempty"`
+	Interval      *IntervalConfig      `json:"interval,omitempty"`
+	HashFunction  string               `json:"hash-function,omitempty"`
+	LookupIndexes []*LookupIndexConfig `json:"lookup-indexes,omitempty"`
 }
 
 // SchemaConfig tuple.
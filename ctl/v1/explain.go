@@ -14,9 +14,9 @@ import (
 
 	"github.com/sealdb/neodb/optimizer"
 	"github.com/sealdb/neodb/proxy"
+	"github.com/sealdb/neodb/xparser"
 
 	"github.com/ant0ine/go-json-rest/rest"
-	"github.com/sealdb/mysqlstack/sqlparser"
 	"github.com/sealdb/mysqlstack/xlog"
 )
 
@@ -47,7 +47,7 @@ func explainHandler(log *xlog.Log, proxy *proxy.Proxy, w rest.ResponseWriter, r
 	rsp := &resp{}
 	router := proxy.Router()
 	query := p.Query
-	node, err := sqlparser.Parse(query)
+	node, err := xparser.Parse(query)
 	if err != nil {
 		log.Error("ctl.v1.explain[%s].parser.error:%+v", query, err)
 		rsp.Msg = err.Error()
@@ -65,5 +65,13 @@ func TestCtlV1Explain(t *testing.T) {
 		}
 		recorded := test.RunRequest(t, handler, test.MakeSimpleRequest("POST", "http://localhost/v1/neodb/explain", p))
 		recorded.CodeIs(200)
+
+		// The statements extended by the xparser.
+		p = &explainParams{
+			Query: "with c as (select id from test.t1) select id from c where id = 1",
+		}
+		recorded = test.RunRequest(t, handler, test.MakeSimpleRequest("POST", "http://localhost/v1/neodb/explain", p))
+		recorded.CodeIs(200)
+		assert.Contains(t, recorded.Recorder.Body.String(), "select id from (select id from test.t1_0017 as t1 where id = 1) as c")
 	}
 }
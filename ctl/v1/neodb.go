@@ -22,6 +22,9 @@ type neoDBParams struct {
 	MaxConnections      *int     `json:"max-connections"`
 	MaxResultSize       *int     `json:"max-result-size"`
 	MaxJoinRows         *int     `json:"max-join-rows"`
+	MaxQueryMemory      *int     `json:"max-query-memory"`
+	MaxShardKeyRows     *int     `json:"max-shard-key-update-rows"`
+	CTEMaxRecursion     *int     `json:"cte-max-recursion-depth"`
 	DDLTimeout          *int     `json:"ddl-timeout"`
 	QueryTimeout        *int     `json:"query-timeout"`
 	TwoPCEnable         *bool    `json:"twopc-enable"`
@@ -60,6 +63,15 @@ func neoDBConfigHandler(log *xlog.Log, proxy *proxy.Proxy, w rest.ResponseWriter
 	if p.MaxJoinRows != nil {
 		proxy.SetMaxJoinRows(*p.MaxJoinRows)
 	}
+	if p.MaxQueryMemory != nil {
+		proxy.SetMaxQueryMemory(*p.MaxQueryMemory)
+	}
+	if p.MaxShardKeyRows != nil {
+		proxy.SetMaxShardKeyUpdateRows(*p.MaxShardKeyRows)
+	}
+	if p.CTEMaxRecursion != nil {
+		proxy.SetCTEMaxRecursionDepth(*p.CTEMaxRecursion)
+	}
 	if p.DDLTimeout != nil {
 		proxy.SetDDLTimeout(*p.DDLTimeout)
 	}
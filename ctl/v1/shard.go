@@ -16,8 +16,10 @@ import (
 
 	"github.com/sealdb/neodb/plugins/shiftmanager"
 	"github.com/sealdb/neodb/proxy"
+	"github.com/sealdb/neodb/xparser"
 
 	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/sealdb/mysqlstack/sqlparser"
 	"github.com/sealdb/mysqlstack/xlog"
 	shiftlog "github.com/sealdb/neodb/tools/shift/xlog"
 )
@@ -36,6 +38,58 @@ func shardzHandler(log *xlog.Log, proxy *proxy.Proxy, w rest.ResponseWriter, r *
 	w.WriteJson(rulez)
 }
 
+// ShardRouteHandler impl.
+func ShardRouteHandler(log *xlog.Log, proxy *proxy.Proxy) rest.HandlerFunc {
+	f := func(w rest.ResponseWriter, r *rest.Request) {
+		shardRouteHandler(log, proxy, w, r)
+	}
+	return f
+}
+
+// shardRouteHandler used to show where the shard key value routes to, or the querys which the sql is rewritten to.
+// GET /v1/shard/route?db=db1&table=t1&value=1
+// The value is a literal or a tuple for the composite shard key, such as 1, 'a' or (1,'a'),
+// the value which isn't a literal is taken as a string.
+// GET /v1/shard/route?db=db1&sql=select * from t1 where id=1
+// The querys are not executed.
+func shardRouteHandler(log *xlog.Log, p *proxy.Proxy, w rest.ResponseWriter, r *rest.Request) {
+	params := r.URL.Query()
+	database := params.Get("db")
+	if database == "" {
+		rest.Error(w, "api.v1.shard.route.db.is.empty", http.StatusBadRequest)
+		return
+	}
+
+	if query := params.Get("sql"); query != "" {
+		routes, err := p.Spanner().RouteQuery(database, query)
+		if err != nil {
+			log.Error("api.v1.shard.route.sql[%s].error:%+v", query, err)
+			rest.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteJson(routes)
+		return
+	}
+
+	table := params.Get("table")
+	value := params.Get("value")
+	if table == "" || value == "" {
+		rest.Error(w, "api.v1.shard.route.table.and.value.or.sql.is.empty", http.StatusBadRequest)
+		return
+	}
+	vals, err := xparser.ParseValues(value)
+	if err != nil {
+		vals = []*sqlparser.SQLVal{sqlparser.NewStrVal([]byte(value))}
+	}
+	route, err := p.Spanner().RouteValues(database, table, vals)
+	if err != nil {
+		log.Error("api.v1.shard.route[%s.%s].value[%s].error:%+v", database, table, value, err)
+		rest.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteJson(route)
+}
+
 // ShardBalanceAdviceHandler impl.
 func ShardBalanceAdviceHandler(log *xlog.Log, proxy *proxy.Proxy) rest.HandlerFunc {
 	f := func(w rest.ResponseWriter, r *rest.Request) {
@@ -323,6 +377,13 @@ func shardMigrateHandler(proxy *proxy.Proxy, w rest.ResponseWriter, r *rest.Requ
 		return
 	}
 
+	// The colocated partitions are moved together by the rebalance, not one by one.
+	if tables, err := proxy.Router().ColocatedPartitions(p.FromDatabase, p.FromTable); err == nil && len(tables) > 1 {
+		log.Error("api.v1.shard.migrate.table[%s].is.colocated.with%v", p.FromTable, tables[1:])
+		rest.Error(w, fmt.Sprintf("api.v1.shard.migrate.table[%s].is.colocated.with%v", p.FromTable, tables[1:]), http.StatusInternalServerError)
+		return
+	}
+
 	cfg := &shiftmanager.ShiftInfo{
 		From:                   p.From,
 		FromUser:               p.FromUser,
@@ -11,6 +11,7 @@ package v1
 
 import (
 	"github.com/sealdb/neodb/router"
+	"net/url"
 	"strings"
 	"testing"
 
@@ -19,6 +20,7 @@ import (
 	"github.com/ant0ine/go-json-rest/rest"
 	"github.com/ant0ine/go-json-rest/rest/test"
 	"github.com/sealdb/mysqlstack/driver"
+	"github.com/sealdb/mysqlstack/sqlparser"
 	querypb "github.com/sealdb/mysqlstack/sqlparser/depends/query"
 	"github.com/sealdb/mysqlstack/sqlparser/depends/sqltypes"
 	"github.com/sealdb/mysqlstack/xlog"
@@ -1491,3 +1493,95 @@ func TestCtlV1ShardMigrateErr(t *testing.T) {
 		recorded.CodeIs(403)
 	}
 }
+
+func TestCtlV1ShardRoute(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	fakedbs, proxy, cleanup := proxy.MockProxy(log)
+	defer cleanup()
+	address := proxy.Address()
+
+	// fakedbs.
+	{
+		fakedbs.AddQueryPattern("create .*", &sqltypes.Result{})
+	}
+
+	// create test table.
+	{
+		client, err := driver.NewConn("mock", "mock", address, "", "utf8")
+		assert.Nil(t, err)
+		_, err = client.FetchAll("create database test", -1)
+		assert.Nil(t, err)
+		_, err = client.FetchAll("create table test.t1(id int, b int) partition by hash(id)", -1)
+		assert.Nil(t, err)
+		_, err = client.FetchAll("create table test.t2(name varchar(10), b int) partition by hash(name)", -1)
+		assert.Nil(t, err)
+		client.Quit()
+	}
+
+	api := rest.NewApi()
+	router, _ := rest.MakeRouter(
+		rest.Get("/v1/shard/route", ShardRouteHandler(log, proxy)),
+	)
+	api.SetApp(router)
+	handler := api.MakeHandler()
+
+	// route the value.
+	{
+		recorded := test.RunRequest(t, handler, test.MakeSimpleRequest("GET", "http://localhost/v1/shard/route?db=test&table=t1&value=1", nil))
+		recorded.CodeIs(200)
+		want, err := proxy.Spanner().RouteValues("test", "t1", []*sqlparser.SQLVal{sqlparser.NewIntVal([]byte("1"))})
+		assert.Nil(t, err)
+		got := &struct {
+			Index   int    `json:"index"`
+			Table   string `json:"table"`
+			Range   string `json:"range"`
+			Backend string `json:"backend"`
+			Address string `json:"address"`
+		}{}
+		err = recorded.DecodeJsonPayload(got)
+		assert.Nil(t, err)
+		assert.Equal(t, want.Index, got.Index)
+		assert.Equal(t, want.Table, got.Table)
+		assert.Equal(t, want.Range, got.Range)
+		assert.Equal(t, want.Backend, got.Backend)
+		assert.Equal(t, want.Address, got.Address)
+		assert.NotEqual(t, "", got.Address)
+	}
+
+	// The unquoted value is a string.
+	{
+		recorded := test.RunRequest(t, handler, test.MakeSimpleRequest("GET", "http://localhost/v1/shard/route?db=test&table=t2&value=abc", nil))
+		recorded.CodeIs(200)
+		want, err := proxy.Spanner().RouteValues("test", "t2", []*sqlparser.SQLVal{sqlparser.NewStrVal([]byte("abc"))})
+		assert.Nil(t, err)
+		assert.True(t, strings.Contains(recorded.Recorder.Body.String(), "\"table\":\""+want.Table+"\""))
+	}
+
+	// route the sql.
+	{
+		sql := url.QueryEscape("select b from t1 where id=1")
+		recorded := test.RunRequest(t, handler, test.MakeSimpleRequest("GET", "http://localhost/v1/shard/route?db=test&sql="+sql, nil))
+		recorded.CodeIs(200)
+		want, err := proxy.Spanner().RouteQuery("test", "select b from t1 where id=1")
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(want))
+		assert.True(t, strings.Contains(recorded.Recorder.Body.String(), want[0].Query))
+	}
+
+	// errors.
+	{
+		requests := []struct {
+			url  string
+			code int
+		}{
+			{"http://localhost/v1/shard/route?table=t1&value=1", 400},
+			{"http://localhost/v1/shard/route?db=test&table=t1", 400},
+			{"http://localhost/v1/shard/route?db=test&table=t3&value=1", 500},
+			{"http://localhost/v1/shard/route?db=test&sql=" + url.QueryEscape("select * from t3"), 500},
+		}
+		for _, req := range requests {
+			recorded := test.RunRequest(t, handler, test.MakeSimpleRequest("GET", req.url, nil))
+			recorded.CodeIs(req.code)
+		}
+	}
+}
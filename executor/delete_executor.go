@@ -11,9 +11,11 @@ package executor
 
 import (
 	"github.com/sealdb/neodb/backend"
+	"github.com/sealdb/neodb/executor/engine"
 	"github.com/sealdb/neodb/planner"
 	"github.com/sealdb/neodb/xcontext"
 
+	querypb "github.com/sealdb/mysqlstack/sqlparser/depends/query"
 	"github.com/sealdb/mysqlstack/xlog"
 )
 
@@ -40,11 +42,41 @@ func NewDeleteExecutor(log *xlog.Log, plan planner.Plan, txn backend.Transaction
 // Execute used to execute the executor.
 func (executor *DeleteExecutor) Execute(ctx *xcontext.ResultContext) error {
 	plan := executor.plan.(*planner.DeletePlan)
+	if err := pruneRoutes(executor.txn, plan.Prune, plan); err != nil {
+		return err
+	}
+	if plan.Fields != nil {
+		// The cross-shard multi-table delete resolves the primary keys of the target rows first.
+		fields, err := fetchFields(executor.txn, plan.ReqMode, plan.Fields)
+		if err != nil {
+			return err
+		}
+		if err := plan.BuildResolve(fields); err != nil {
+			return err
+		}
+
+		resCtx := xcontext.NewResultContext()
+		planEngine := engine.BuildEngine(executor.log, plan.Root, executor.txn)
+		if err := planEngine.Execute(resCtx); err != nil {
+			return err
+		}
+		if err := plan.BuildTargets(resCtx.Results); err != nil {
+			return err
+		}
+	}
+	if err := checkForceXA(executor.txn, plan.ForceXA); err != nil {
+		return err
+	}
+	if err := buildLookups(executor.txn, plan.ReqMode, plan.ForceXA, plan); err != nil {
+		return err
+	}
+
 	reqCtx := xcontext.NewRequestContext()
 	reqCtx.Mode = plan.ReqMode
 	reqCtx.TxnMode = xcontext.TxnWrite
 	reqCtx.Querys = plan.Querys
 	reqCtx.RawQuery = plan.RawQuery
+	reqCtx.ForceXA = plan.ForceXA
 
 	rs, err := executor.txn.Execute(reqCtx)
 	if err != nil {
@@ -53,3 +85,20 @@ func (executor *DeleteExecutor) Execute(ctx *xcontext.ResultContext) error {
 	ctx.Results = rs
 	return nil
 }
+
+// fetchFields used to fetch the fields of the targets of the multi-table delete or update.
+func fetchFields(txn backend.Transaction, mode xcontext.RequestMode, querys []xcontext.QueryTuple) ([][]*querypb.Field, error) {
+	var fields [][]*querypb.Field
+	for _, query := range querys {
+		reqCtx := xcontext.NewRequestContext()
+		reqCtx.Mode = mode
+		reqCtx.TxnMode = xcontext.TxnRead
+		reqCtx.Querys = []xcontext.QueryTuple{query}
+		rs, err := txn.Execute(reqCtx)
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, rs.Fields)
+	}
+	return fields, nil
+}
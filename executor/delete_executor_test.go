@@ -11,6 +11,7 @@ package executor
 
 import (
 	"github.com/sealdb/neodb/backend"
+	"github.com/sealdb/neodb/config"
 	"github.com/sealdb/neodb/fakedb"
 	"github.com/sealdb/neodb/planner"
 	"github.com/sealdb/neodb/router"
@@ -18,6 +19,8 @@ import (
 	"testing"
 
 	"github.com/sealdb/mysqlstack/sqlparser"
+	querypb "github.com/sealdb/mysqlstack/sqlparser/depends/query"
+	"github.com/sealdb/mysqlstack/sqlparser/depends/sqltypes"
 	"github.com/sealdb/mysqlstack/xlog"
 	"github.com/stretchr/testify/assert"
 )
@@ -67,3 +70,136 @@ func TestDeleteExecutor(t *testing.T) {
 		}
 	}
 }
+
+func TestDeleteExecutorMultiTables(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	database := "sbtest"
+
+	scatter, fakedbs, cleanup := backend.MockScatter(log, 10)
+	defer cleanup()
+
+	route, cleanup := router.MockNewRouter(log)
+	defer cleanup()
+
+	err := route.CreateDatabase(database)
+	assert.Nil(t, err)
+	err = route.AddForTest(database, router.MockTableMConfig(), router.MockTableBConfig())
+	assert.Nil(t, err)
+
+	// The primary key of A is (id, k).
+	pkFlags := uint32(querypb.MySqlFlag_PRI_KEY_FLAG | querypb.MySqlFlag_NOT_NULL_FLAG)
+	fieldsA := &sqltypes.Result{
+		Fields: []*querypb.Field{
+			{Name: "id", Table: "A", Type: querypb.Type_INT32, Flags: pkFlags},
+			{Name: "k", Table: "A", Type: querypb.Type_INT32, Flags: pkFlags},
+			{Name: "name", Table: "A", Type: querypb.Type_VARCHAR},
+		},
+	}
+	// Each shard of A returns the rows (1, 10, 'x'), (1, 11, 'y') and (3, 30, 'x'), B returns 'x'.
+	rowsA := &sqltypes.Result{
+		Fields: []*querypb.Field{
+			{Name: "id", Table: "A", Type: querypb.Type_INT32},
+			{Name: "k", Table: "A", Type: querypb.Type_INT32},
+			{Name: "name", Table: "A", Type: querypb.Type_VARCHAR},
+		},
+	}
+	for _, row := range [][]string{{"1", "10", "x"}, {"1", "11", "y"}, {"3", "30", "x"}} {
+		rowsA.Rows = append(rowsA.Rows, []sqltypes.Value{
+			sqltypes.MakeTrusted(querypb.Type_INT32, []byte(row[0])),
+			sqltypes.MakeTrusted(querypb.Type_INT32, []byte(row[1])),
+			sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(row[2])),
+		})
+	}
+	rowsB := &sqltypes.Result{
+		Fields: []*querypb.Field{{Name: "name", Table: "B", Type: querypb.Type_VARCHAR}},
+		Rows:   [][]sqltypes.Value{{sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("x"))}},
+	}
+	fakedbs.AddQueryPattern("select \\* from .* where 1 != 1", fieldsA)
+	fakedbs.AddQueryPattern("select A.id, A.k, A.name from sbtest.A[0-9]+ as A.*", rowsA)
+	fakedbs.AddQueryPattern("select B.name from sbtest.B1 as B.*", rowsB)
+	fakedbs.AddQueryPattern("delete from sbtest.A[0-9]+ where .*", &sqltypes.Result{RowsAffected: 1})
+
+	query := "delete from A using A join B on A.name = B.name where B.id = 1"
+	node, err := sqlparser.Parse(query)
+	assert.Nil(t, err)
+	plan := planner.NewDeletePlan(log, database, query, node.(*sqlparser.Delete), route)
+	err = plan.Build()
+	assert.Nil(t, err)
+
+	txn, err := scatter.CreateTransaction()
+	assert.Nil(t, err)
+	defer txn.Finish()
+	txn.SetMaxJoinRows(1000)
+	executor := NewDeleteExecutor(log, plan, txn)
+	ctx := xcontext.NewResultContext()
+	err = executor.Execute(ctx)
+	assert.Nil(t, err)
+
+	// The ids 1 and 3 are routed to the shard A6, the row (1, 11) sharing the shard key
+	// with the matched row is kept.
+	var got []string
+	for _, query := range plan.Querys {
+		got = append(got, query.Query)
+	}
+	want := []string{
+		"delete from sbtest.A6 where sbtest.A6.id = 1 and sbtest.A6.k in (10)",
+		"delete from sbtest.A6 where sbtest.A6.id = 3 and sbtest.A6.k in (30)",
+	}
+	assert.Equal(t, want, got)
+	assert.Equal(t, uint64(2), ctx.Results.RowsAffected)
+}
+
+func TestDeleteExecutorLookupIndex(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	database := "sbtest"
+
+	scatter, fakedbs, cleanup := backend.MockScatter(log, 10)
+	defer cleanup()
+
+	route, cleanup := router.MockNewRouter(log)
+	defer cleanup()
+
+	err := route.CreateDatabase(database)
+	assert.Nil(t, err)
+	err = route.AddForTest(database,
+		&config.TableConfig{
+			Name:          "L",
+			ShardType:     "HASH",
+			ShardKey:      "id",
+			LookupIndexes: []*config.LookupIndexConfig{{Name: "LN", Column: "name"}},
+			Partitions:    []*config.PartitionConfig{{Table: "L0", Segment: "0-4096", Backend: "backend0"}},
+		},
+		&config.TableConfig{
+			Name:       "LN",
+			ShardType:  "HASH",
+			ShardKey:   "name",
+			Partitions: []*config.PartitionConfig{{Table: "LN0", Segment: "0-4096", Backend: "backend0"}},
+		})
+	assert.Nil(t, err)
+
+	pairs := &sqltypes.Result{
+		Fields: []*querypb.Field{{Name: "id", Type: querypb.Type_INT32}, {Name: "name", Type: querypb.Type_VARCHAR}},
+		Rows: [][]sqltypes.Value{
+			{sqltypes.MakeTrusted(querypb.Type_INT32, []byte("1")), sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("a"))},
+		},
+	}
+	fakedbs.AddQueryPattern("select `id`, `name` from `sbtest`.`l0`.*", pairs)
+	fakedbs.AddQueryPattern("delete from .*", &sqltypes.Result{RowsAffected: 1})
+
+	query := "delete from sbtest.L where age > 10"
+	node, err := sqlparser.Parse(query)
+	assert.Nil(t, err)
+	plan := planner.NewDeletePlan(log, database, query, node.(*sqlparser.Delete), route)
+	err = plan.Build()
+	assert.Nil(t, err)
+	// The pairs are not read by the planner.
+	assert.Equal(t, 0, fakedbs.GetQueryCalledNum("select `id`, `name` from `sbtest`.`L0` where sbtest.L0.age > 10"))
+
+	txn, err := scatter.CreateTransaction()
+	assert.Nil(t, err)
+	defer txn.Finish()
+	err = NewDeleteExecutor(log, plan, txn).Execute(xcontext.NewResultContext())
+	assert.Nil(t, err)
+	assert.Equal(t, 1, fakedbs.GetQueryCalledNum("select `id`, `name` from `sbtest`.`L0` where sbtest.L0.age > 10"))
+	assert.Equal(t, 1, fakedbs.GetQueryCalledNum("delete from `sbtest`.`LN0` where (`name`, `id`) in (('a', 1))"))
+}
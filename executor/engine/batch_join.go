@@ -0,0 +1,203 @@
+/*
+ * NeoDB
+ *
+ * Copyright 2021-2030 The NeoDB Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package engine
+
+import (
+	"github.com/sealdb/neodb/planner/builder"
+	"github.com/sealdb/neodb/xcontext"
+
+	querypb "github.com/sealdb/mysqlstack/sqlparser/depends/query"
+	"github.com/sealdb/mysqlstack/sqlparser/depends/sqltypes"
+)
+
+// batchRow is the left row in the batch.
+type batchRow struct {
+	row []sqltypes.Value
+	// the hash key of the join keys, valid if ok is true.
+	key string
+	ok  bool
+}
+
+// execBatch used to execute the NestLoop join by looking up the right in batches. The distinct
+// join keys of at most `size` left rows are bound to the IN-lists of the right querys, and the
+// returned right rows are joined with the left rows in memory.
+func (j *JoinEngine) execBatch(ctx *xcontext.ResultContext, right *MergeEngine, bindVars map[string]*querypb.BindVariable, wantfields bool, size int, out *joinOutput) error {
+	lctx := xcontext.NewResultContext()
+
+	if err := j.left.execBindVars(lctx, bindVars, wantfields); err != nil {
+		return err
+	}
+
+	keys := j.node.BatchKeys
+	lkeys := make([]builder.JoinKey, len(keys))
+	rkeys := make([]builder.JoinKey, len(keys))
+	for i, key := range keys {
+		lkeys[i] = builder.JoinKey{Index: key.LeftIdx}
+		rkeys[i] = builder.JoinKey{Index: key.RightIdx}
+	}
+
+	var batch []batchRow
+	var keyRows [][]sqltypes.Value
+	seen := make(map[string]struct{})
+	joinBatch := func() error {
+		table := make(map[string][][]sqltypes.Value)
+		if len(keyRows) > 0 {
+			rctx := xcontext.NewResultContext()
+			if err := right.execBatch(rctx, j.batchVars(right.node, bindVars, keyRows)); err != nil {
+				return err
+			}
+			if rctx.Results != nil {
+				if wantfields {
+					wantfields = false
+					ctx.Results.Fields = joinFields(lctx.Results.Fields, rctx.Results.Fields, j.node.Cols)
+				}
+				for _, rrow := range rctx.Results.Rows {
+					if key, ok := hashKey(rrow, rkeys); ok {
+						table[key] = append(table[key], rrow)
+					}
+				}
+			}
+		}
+
+		for _, brow := range batch {
+			var rrows [][]sqltypes.Value
+			if brow.ok {
+				rrows = table[brow.key]
+			}
+			if len(rrows) == 0 {
+				if err := concatLeftAndNil([][]sqltypes.Value{brow.row}, j.node, out); err != nil {
+					return err
+				}
+				continue
+			}
+			for _, rrow := range rrows {
+				if !j.rightMatch(rrow) {
+					continue
+				}
+				if err := out.append(joinRows(brow.row, rrow, j.node.Cols)); err != nil {
+					return err
+				}
+			}
+		}
+
+		batch, keyRows = nil, nil
+		seen = make(map[string]struct{})
+		return nil
+	}
+
+	for _, lrow := range lctx.Results.Rows {
+		brow := batchRow{row: lrow}
+		if j.leftMatch(lrow) {
+			brow.key, brow.ok = hashKey(lrow, lkeys)
+		}
+		if brow.ok {
+			if _, exists := seen[brow.key]; !exists {
+				if len(keyRows) == size {
+					if err := joinBatch(); err != nil {
+						return err
+					}
+				}
+				seen[brow.key] = struct{}{}
+				keyRows = append(keyRows, lrow)
+			}
+		}
+		batch = append(batch, brow)
+	}
+	if err := joinBatch(); err != nil {
+		return err
+	}
+
+	if wantfields {
+		rctx := xcontext.NewResultContext()
+		joinVars := make(map[string]*querypb.BindVariable)
+		for k := range j.node.Vars {
+			joinVars[k] = sqltypes.NullBindVariable
+		}
+		if err := j.right.getFields(rctx, combineVars(bindVars, joinVars)); err != nil {
+			return err
+		}
+		ctx.Results.Fields = joinFields(lctx.Results.Fields, rctx.Results.Fields, j.node.Cols)
+	}
+	return nil
+}
+
+// batchVars builds the bindvars of each route for the left rows, nil if the route has no keys.
+// If a key is the shard key, the rows are routed by its values, otherwise sent to all the routes.
+func (j *JoinEngine) batchVars(node *builder.MergeNode, bindVars map[string]*querypb.BindVariable, rows [][]sqltypes.Value) []map[string]*querypb.BindVariable {
+	var shardKey *builder.BatchKey
+	keys := j.node.BatchKeys
+	for _, key := range keys {
+		if key.Table != "" {
+			shardKey = key
+			break
+		}
+	}
+
+	routeLen := len(node.BatchQuerys)
+	routeRows := make([][][]sqltypes.Value, routeLen)
+	for _, row := range rows {
+		if shardKey != nil {
+			// The value unable to be routed is sent to all the routes.
+			if idx, err := node.RouteKey(shardKey, row[shardKey.LeftIdx]); err == nil {
+				// The route is pruned by the filters, no row matches.
+				if idx >= 0 {
+					routeRows[idx] = append(routeRows[idx], row)
+				}
+				continue
+			}
+		}
+		for i := range routeRows {
+			routeRows[i] = append(routeRows[i], row)
+		}
+	}
+
+	routeVars := make([]map[string]*querypb.BindVariable, routeLen)
+	for i, rows := range routeRows {
+		if len(rows) == 0 {
+			continue
+		}
+		joinVars := make(map[string]*querypb.BindVariable, len(keys))
+		for _, key := range keys {
+			tuple := &querypb.BindVariable{Type: querypb.Type_TUPLE}
+			seen := make(map[string]struct{})
+			for _, row := range rows {
+				k, _ := hashKey(row, []builder.JoinKey{{Index: key.LeftIdx}})
+				if _, exists := seen[k]; exists {
+					continue
+				}
+				seen[k] = struct{}{}
+				v := row[key.LeftIdx]
+				tuple.Values = append(tuple.Values, &querypb.Value{Type: v.Type(), Value: v.Raw()})
+			}
+			joinVars[key.Var] = tuple
+		}
+		routeVars[i] = combineVars(bindVars, joinVars)
+	}
+	return routeVars
+}
+
+// leftMatch returns true if the left row satisfies the left join's conditions on the left.
+func (j *JoinEngine) leftMatch(lrow []sqltypes.Value) bool {
+	for _, idx := range j.node.LeftTmpCols {
+		if !sqltypes.CastToBool(lrow[idx]) {
+			return false
+		}
+	}
+	return true
+}
+
+// rightMatch returns true if the right row satisfies the null conditions on the right.
+func (j *JoinEngine) rightMatch(rrow []sqltypes.Value) bool {
+	for _, idx := range j.node.RightTmpCols {
+		if !rrow[idx].IsNull() {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,172 @@
+/*
+ * NeoDB
+ *
+ * Copyright 2021-2030 The NeoDB Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package engine
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/sealdb/neodb/backend"
+	"github.com/sealdb/neodb/planner"
+	"github.com/sealdb/neodb/router"
+	"github.com/sealdb/neodb/xcontext"
+
+	"github.com/sealdb/mysqlstack/sqlparser"
+	querypb "github.com/sealdb/mysqlstack/sqlparser/depends/query"
+	"github.com/sealdb/mysqlstack/sqlparser/depends/sqltypes"
+	"github.com/sealdb/mysqlstack/xlog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJoinEngineBatch(t *testing.T) {
+	intVal := func(v string) sqltypes.Value {
+		return sqltypes.MakeTrusted(querypb.Type_INT32, []byte(v))
+	}
+	strVal := func(v string) sqltypes.Value {
+		return sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(v))
+	}
+	ra := &sqltypes.Result{
+		Fields: []*querypb.Field{{Name: "id", Type: querypb.Type_INT32}},
+		Rows: [][]sqltypes.Value{
+			{intVal("3")},
+			{intVal("4")},
+			{intVal("5")},
+			{intVal("3")},
+		},
+	}
+	raEmpty := &sqltypes.Result{
+		Fields: []*querypb.Field{{Name: "id", Type: querypb.Type_INT32}},
+	}
+	rbFields := []*querypb.Field{
+		{Name: "name", Type: querypb.Type_VARCHAR},
+		{Name: "id", Type: querypb.Type_INT32},
+	}
+	rb := func(rows ...[]sqltypes.Value) *sqltypes.Result {
+		return &sqltypes.Result{Fields: rbFields, Rows: rows}
+	}
+	rg := &sqltypes.Result{
+		Fields: []*querypb.Field{{Name: "a", Type: querypb.Type_INT32}},
+		Rows:   [][]sqltypes.Value{{intVal("10")}},
+	}
+
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	database := "sbtest"
+
+	route, cleanup := router.MockNewRouter(log)
+	defer cleanup()
+
+	err := route.CreateDatabase(database)
+	assert.Nil(t, err)
+	err = route.AddForTest(database, router.MockTableAConfig(), router.MockTableBConfig(), router.MockTableGConfig())
+	assert.Nil(t, err)
+
+	scatter, fakedbs, cleanup := backend.MockScatter(log, 10)
+	defer cleanup()
+	fakedbs.AddQuery("select A.id from sbtest.A0 as A where A.id > 2", ra)
+	fakedbs.AddQuery("select A.id from sbtest.A2 as A where A.id > 2", raEmpty)
+	fakedbs.AddQuery("select A.id from sbtest.A4 as A where A.id > 2", raEmpty)
+	fakedbs.AddQuery("select A.id from sbtest.A8 as A where A.id > 2", raEmpty)
+	fakedbs.AddQueryPattern("select G.a from sbtest.G .*", rg)
+	// The keys 3, 4 and 5 route to B1.
+	fakedbs.AddQuery("select B.name, B.id from sbtest.B1 as B where B.id > 2 and B.id in (3, 4, 5)", rb(
+		[]sqltypes.Value{strVal("lang"), intVal("5")},
+		[]sqltypes.Value{strVal("go"), intVal("3")},
+	))
+	fakedbs.AddQuery("select B.name, B.id from sbtest.B1 as B where B.id > 2 and B.id in (3, 4)", rb(
+		[]sqltypes.Value{strVal("go"), intVal("3")},
+	))
+	fakedbs.AddQuery("select B.name, B.id from sbtest.B1 as B where B.id > 2 and B.id in (5, 3)", rb(
+		[]sqltypes.Value{strVal("lang"), intVal("5")},
+		[]sqltypes.Value{strVal("go"), intVal("3")},
+	))
+	fakedbs.AddQueryPattern("select B.name, B.id from sbtest.B0 as B .*", rb())
+	fakedbs.AddQuery("select B.name, B.id from sbtest.B1 as B where B.id > 2 and 3 = B.id", rb(
+		[]sqltypes.Value{strVal("go"), intVal("3")},
+	))
+	fakedbs.AddQuery("select B.name, B.id from sbtest.B1 as B where B.id > 2 and 4 = B.id", rb())
+	fakedbs.AddQuery("select B.name, B.id from sbtest.B1 as B where B.id > 2 and 5 = B.id", rb(
+		[]sqltypes.Value{strVal("lang"), intVal("5")},
+	))
+
+	tcases := []struct {
+		query  string
+		size   int
+		calls  map[string]int
+		result string
+	}{
+		{
+			query: "select A.id, B.name, G.a from A join B on A.id = B.id join G on G.a + A.id > B.id where A.id > 2",
+			size:  100,
+			calls: map[string]int{
+				"select B.name, B.id from sbtest.B0 as B where B.id > 2 and B.id in (3, 4, 5)": 0,
+				"select B.name, B.id from sbtest.B1 as B where B.id > 2 and B.id in (3, 4, 5)": 1,
+			},
+			result: "[[3 go 10] [5 lang 10] [3 go 10]]",
+		},
+		{
+			query: "select A.id, B.name, G.a from A join B on A.id = B.id join G on G.a + A.id > B.id where A.id > 2",
+			size:  2,
+			calls: map[string]int{
+				"select B.name, B.id from sbtest.B1 as B where B.id > 2 and B.id in (3, 4)": 1,
+				"select B.name, B.id from sbtest.B1 as B where B.id > 2 and B.id in (5, 3)": 1,
+			},
+			result: "[[3 go 10] [5 lang 10] [3 go 10]]",
+		},
+		// The batch size 0 looks up the keys one by one.
+		{
+			query: "select A.id, B.name, G.a from A join B on A.id = B.id join G on G.a + A.id > B.id where A.id > 2",
+			size:  0,
+			calls: map[string]int{
+				"select B.name, B.id from sbtest.B1 as B where B.id > 2 and 3 = B.id": 2,
+				"select B.name, B.id from sbtest.B1 as B where B.id > 2 and 4 = B.id": 1,
+				"select B.name, B.id from sbtest.B1 as B where B.id > 2 and 5 = B.id": 1,
+			},
+			result: "[[3 go 10] [5 lang 10] [3 go 10]]",
+		},
+		// The unmatched left rows are kept in the left join.
+		{
+			query: "select A.id, B.name, G.a from A left join B on A.id = B.id join G on G.a + A.id > B.id where A.id > 2",
+			size:  100,
+			calls: map[string]int{
+				"select B.name, B.id from sbtest.B1 as B where B.id > 2 and B.id in (3, 4, 5)": 1,
+			},
+			result: "[[3 go 10] [4  10] [5 lang 10] [3 go 10]]",
+		},
+	}
+
+	for _, tcase := range tcases {
+		calls := make(map[string]int)
+		for query := range tcase.calls {
+			calls[query] = fakedbs.GetQueryCalledNum(query)
+		}
+		node, err := sqlparser.Parse(tcase.query)
+		assert.Nil(t, err)
+
+		plan := planner.NewSelectPlan(log, database, tcase.query, node.(*sqlparser.Select), route)
+		err = plan.Build()
+		assert.Nil(t, err)
+
+		txn, err := scatter.CreateTransaction()
+		assert.Nil(t, err)
+		defer txn.Finish()
+		txn.SetMaxJoinRows(32768)
+		txn.SetJoinBatchSize(tcase.size)
+		planEngine := BuildEngine(log, plan.Root, txn)
+		{
+			ctx := xcontext.NewResultContext()
+			err := planEngine.Execute(ctx)
+			assert.Nil(t, err, tcase.query)
+			assert.Equal(t, tcase.result, fmt.Sprintf("%v", ctx.Results.Rows), tcase.query)
+			assert.Equal(t, 3, len(ctx.Results.Fields))
+			for query, want := range tcase.calls {
+				assert.Equal(t, want, fakedbs.GetQueryCalledNum(query)-calls[query], query)
+			}
+		}
+	}
+}
@@ -0,0 +1,184 @@
+/*
+ * NeoDB
+ *
+ * Copyright 2021-2030 The NeoDB Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package engine
+
+import (
+	"strings"
+
+	"github.com/sealdb/neodb/backend"
+	"github.com/sealdb/neodb/executor/engine/operator"
+	"github.com/sealdb/neodb/expression"
+	"github.com/sealdb/neodb/planner/builder"
+	"github.com/sealdb/neodb/xcontext"
+
+	"github.com/pkg/errors"
+	"github.com/sealdb/mysqlstack/sqlparser"
+	querypb "github.com/sealdb/mysqlstack/sqlparser/depends/query"
+	"github.com/sealdb/mysqlstack/sqlparser/depends/sqltypes"
+	"github.com/sealdb/mysqlstack/xlog"
+)
+
+var (
+	_ PlanEngine = &DerivedEngine{}
+)
+
+// DerivedEngine represents the derived table executor.
+// The derived table's query is executed and its result is materialized,
+// then the columns are projected and the sub plans run on it.
+type DerivedEngine struct {
+	log  *xlog.Log
+	node *builder.DerivedNode
+	txn  backend.Transaction
+}
+
+// NewDerivedEngine creates the new derived table executor.
+func NewDerivedEngine(log *xlog.Log, node *builder.DerivedNode, txn backend.Transaction) *DerivedEngine {
+	return &DerivedEngine{
+		log:  log,
+		node: node,
+		txn:  txn,
+	}
+}
+
+// Execute used to execute the executor.
+func (d *DerivedEngine) Execute(ctx *xcontext.ResultContext) error {
+	innerCtx := xcontext.NewResultContext()
+	if err := BuildEngine(d.log, d.node.Inner, d.txn).Execute(innerCtx); err != nil {
+		return err
+	}
+
+	maxrow := d.txn.MaxJoinRows()
+	if len(innerCtx.Results.Rows) > maxrow {
+		return errors.Errorf("unsupported: derived.table.row.count.exceeded.allowed.limit.of.'%d'", maxrow)
+	}
+
+	if err := filter(innerCtx.Results, d.node.Filters); err != nil {
+		return err
+	}
+
+	var err error
+	if ctx.Results, err = project(innerCtx.Results, d.node.Columns, d.node.Alias()); err != nil {
+		return err
+	}
+	return operator.ExecSubPlan(d.log, d.node, d.txn, ctx)
+}
+
+// execBindVars used to execute querys with bindvas.
+// The derived table is uncorrelated, the bindvars are not used.
+func (d *DerivedEngine) execBindVars(ctx *xcontext.ResultContext, bindVars map[string]*querypb.BindVariable, wantfields bool) error {
+	return d.Execute(ctx)
+}
+
+// getFields fetches the field info.
+func (d *DerivedEngine) getFields(ctx *xcontext.ResultContext, bindVars map[string]*querypb.BindVariable) error {
+	return d.Execute(ctx)
+}
+
+// filter used to keep the rows of the union derived table's result which all the filters are true.
+func filter(res *sqltypes.Result, filters []sqlparser.Expr) error {
+	if len(filters) == 0 {
+		return nil
+	}
+
+	evals := make([]expression.Evaluator, 0, len(filters))
+	for _, expr := range filters {
+		eval, err := expression.Compile(expr, func(e sqlparser.Expr) (int, bool) {
+			col, ok := e.(*sqlparser.ColName)
+			if !ok {
+				return -1, false
+			}
+			for i, field := range res.Fields {
+				if strings.EqualFold(field.Name, col.Name.String()) {
+					return i, true
+				}
+			}
+			return -1, false
+		})
+		if err != nil {
+			return err
+		}
+		evals = append(evals, eval)
+	}
+
+	rows := res.Rows[:0]
+next:
+	for _, row := range res.Rows {
+		for _, eval := range evals {
+			ok, err := expression.IsTrue(eval, row)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				continue next
+			}
+		}
+		rows = append(rows, row)
+	}
+	res.Rows = rows
+	res.RowsAffected = uint64(len(rows))
+	return nil
+}
+
+// project used to build the result by the columns from the derived table's result.
+func project(res *sqltypes.Result, cols []builder.DerivedColumn, table string) (*sqltypes.Result, error) {
+	const (
+		constIdx = -1
+		starIdx  = -2
+	)
+
+	idxs := make([]int, len(cols))
+	qr := &sqltypes.Result{}
+	for i, col := range cols {
+		switch col.Field {
+		case "":
+			idxs[i] = constIdx
+			qr.Fields = append(qr.Fields, &querypb.Field{Name: col.Alias, Table: table, Type: col.Value.Type()})
+		case "*":
+			idxs[i] = starIdx
+			for _, field := range res.Fields {
+				f := *field
+				f.Table = table
+				qr.Fields = append(qr.Fields, &f)
+			}
+		default:
+			idx := -1
+			for j, field := range res.Fields {
+				if strings.EqualFold(field.Name, col.Field) {
+					idx = j
+					break
+				}
+			}
+			if idx == -1 {
+				return nil, errors.Errorf("unsupported: unknown.column.'%s.%s'.in.field.list", table, col.Field)
+			}
+			idxs[i] = idx
+			f := *res.Fields[idx]
+			f.Name = col.Alias
+			f.Table = table
+			qr.Fields = append(qr.Fields, &f)
+		}
+	}
+
+	for _, row := range res.Rows {
+		newRow := make([]sqltypes.Value, 0, len(qr.Fields))
+		for i, idx := range idxs {
+			switch idx {
+			case constIdx:
+				newRow = append(newRow, cols[i].Value)
+			case starIdx:
+				newRow = append(newRow, row...)
+			default:
+				newRow = append(newRow, row[idx])
+			}
+		}
+		qr.Rows = append(qr.Rows, newRow)
+	}
+	qr.RowsAffected = uint64(len(qr.Rows))
+	return qr, nil
+}
@@ -0,0 +1,208 @@
+/*
+ * NeoDB
+ *
+ * Copyright 2021-2030 The NeoDB Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package engine
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/sealdb/neodb/backend"
+	"github.com/sealdb/neodb/planner"
+	"github.com/sealdb/neodb/router"
+	"github.com/sealdb/neodb/xcontext"
+
+	"github.com/sealdb/mysqlstack/sqlparser"
+	querypb "github.com/sealdb/mysqlstack/sqlparser/depends/query"
+	"github.com/sealdb/mysqlstack/sqlparser/depends/sqltypes"
+	"github.com/sealdb/mysqlstack/xlog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDerivedEngine(t *testing.T) {
+	r1 := &sqltypes.Result{
+		Fields: []*querypb.Field{
+			{
+				Name: "id",
+				Type: querypb.Type_INT32,
+			},
+			{
+				Name: "name",
+				Type: querypb.Type_VARCHAR,
+			},
+		},
+		Rows: [][]sqltypes.Value{
+			{
+				sqltypes.MakeTrusted(querypb.Type_INT32, []byte("3")),
+				sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("go")),
+			},
+			{
+				sqltypes.MakeTrusted(querypb.Type_INT32, []byte("1")),
+				sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("c")),
+			},
+		},
+	}
+	r2 := &sqltypes.Result{
+		Fields: []*querypb.Field{
+			{
+				Name: "id",
+				Type: querypb.Type_INT32,
+			},
+			{
+				Name: "name",
+				Type: querypb.Type_VARCHAR,
+			},
+		},
+		Rows: [][]sqltypes.Value{
+			{
+				sqltypes.MakeTrusted(querypb.Type_INT32, []byte("2")),
+				sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("go")),
+			},
+		},
+	}
+	r3 := &sqltypes.Result{
+		Fields: []*querypb.Field{
+			{
+				Name: "b",
+				Type: querypb.Type_VARCHAR,
+			},
+			{
+				Name: "a",
+				Type: querypb.Type_INT32,
+			},
+		},
+		Rows: [][]sqltypes.Value{
+			{
+				sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("x")),
+				sqltypes.MakeTrusted(querypb.Type_INT32, []byte("2")),
+			},
+		},
+	}
+
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	database := "sbtest"
+
+	route, cleanup := router.MockNewRouter(log)
+	defer cleanup()
+
+	err := route.CreateDatabase(database)
+	assert.Nil(t, err)
+	err = route.AddForTest(database, router.MockTableAConfig(), router.MockTableBConfig())
+	assert.Nil(t, err)
+
+	// Create scatter and query handler.
+	scatter, fakedbs, cleanup := backend.MockScatter(log, 10)
+	defer cleanup()
+	fakedbs.AddQueryPattern("select id, name from sbtest.B0 as B.*", r1)
+	fakedbs.AddQueryPattern("select id, name from sbtest.B1 as B.*", r2)
+	fakedbs.AddQueryPattern("select A.b, A.a from sbtest.A0 as A order by A.a asc", r3)
+	fakedbs.AddQueryPattern("select A.b, A.a from sbtest.A[1-9] as A order by A.a asc", &sqltypes.Result{Fields: r3.Fields})
+
+	querys := []string{
+		"select t.name, count(*), max(t.id) from (select id, name from B) as t where t.id > 0 group by t.name order by t.name",
+		"select * from (select id, name from B) as t order by t.id desc limit 2",
+		"select t.name, 1 as c from (select id, name from B) as t order by t.name, t.id",
+		"select t.name, A.b from (select id, name from B) as t join A on t.id = A.a",
+	}
+	results := []string{
+		"[[c 1 1] [go 2 3]]",
+		"[[3 go] [2 go]]",
+		"[[c 1] [go 1] [go 1]]",
+		"[[go x]]",
+	}
+
+	for i, query := range querys {
+		node, err := sqlparser.Parse(query)
+		assert.Nil(t, err)
+
+		plan := planner.NewSelectPlan(log, database, query, node.(*sqlparser.Select), route)
+		err = plan.Build()
+		assert.Nil(t, err)
+
+		txn, err := scatter.CreateTransaction()
+		assert.Nil(t, err)
+		defer txn.Finish()
+		txn.SetMaxJoinRows(32768)
+		planEngine := BuildEngine(log, plan.Root, txn)
+		{
+			ctx := xcontext.NewResultContext()
+			err := planEngine.Execute(ctx)
+			assert.Nil(t, err)
+			got := fmt.Sprintf("%v", ctx.Results.Rows)
+			assert.Equal(t, results[i], got, query)
+		}
+	}
+}
+
+func TestDerivedEngineErr(t *testing.T) {
+	r1 := &sqltypes.Result{
+		Fields: []*querypb.Field{
+			{
+				Name: "id",
+				Type: querypb.Type_INT32,
+			},
+		},
+		Rows: [][]sqltypes.Value{
+			{
+				sqltypes.MakeTrusted(querypb.Type_INT32, []byte("3")),
+			},
+			{
+				sqltypes.MakeTrusted(querypb.Type_INT32, []byte("5")),
+			},
+		},
+	}
+
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	database := "sbtest"
+
+	route, cleanup := router.MockNewRouter(log)
+	defer cleanup()
+
+	err := route.CreateDatabase(database)
+	assert.Nil(t, err)
+	err = route.AddForTest(database, router.MockTableAConfig(), router.MockTableBConfig())
+	assert.Nil(t, err)
+
+	// Create scatter and query handler.
+	scatter, fakedbs, cleanup := backend.MockScatter(log, 10)
+	defer cleanup()
+	fakedbs.AddQueryPattern("select \\* from sbtest.B[0-9] as B", r1)
+
+	querys := []string{
+		"select t.name from (select * from B) as t",
+		"select t.id from (select * from B) as t",
+	}
+	maxrows := []int{32768, 3}
+	wants := []string{
+		"unsupported: unknown.column.'t.name'.in.field.list",
+		"unsupported: derived.table.row.count.exceeded.allowed.limit.of.'3'",
+	}
+
+	for i, query := range querys {
+		node, err := sqlparser.Parse(query)
+		assert.Nil(t, err)
+
+		plan := planner.NewSelectPlan(log, database, query, node.(*sqlparser.Select), route)
+		err = plan.Build()
+		assert.Nil(t, err)
+
+		txn, err := scatter.CreateTransaction()
+		assert.Nil(t, err)
+		defer txn.Finish()
+		txn.SetMaxJoinRows(maxrows[i])
+		planEngine := BuildEngine(log, plan.Root, txn)
+		{
+			ctx := xcontext.NewResultContext()
+			err := planEngine.Execute(ctx)
+			assert.NotNil(t, err)
+			if err != nil {
+				assert.Equal(t, wants[i], err.Error(), query)
+			}
+		}
+	}
+}
@@ -0,0 +1,122 @@
+/*
+ * NeoDB
+ *
+ * Copyright 2021-2030 The NeoDB Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package engine
+
+import (
+	"github.com/sealdb/neodb/planner/builder"
+	"github.com/sealdb/neodb/xbase/spill"
+
+	"github.com/sealdb/mysqlstack/sqlparser/depends/sqltypes"
+)
+
+// hashBucket holds the rows with the same join key values.
+type hashBucket struct {
+	build, probe [][]sqltypes.Value
+}
+
+// hashJoin used to join `lres` and `rres` to `out`. If the memory is limited and the smaller
+// input exceeds the limit, the inputs are partitioned to disk and joined by the grace hash join.
+// The inputs are already in memory, only the partitioning and the joined rows are bounded.
+func hashJoin(lres, rres *sqltypes.Result, out *joinOutput, node *builder.JoinNode) error {
+	lrows, rrows := lres.Rows, rres.Rows
+	if out.tracker.Enabled() {
+		build := rrows
+		if len(lrows) < len(rrows) {
+			build = lrows
+		}
+		if spill.RowsSize(build) > out.tracker.Limit() {
+			// The inputs are owned by the grace hash join, release them once partitioned.
+			lres.Rows, rres.Rows = nil, nil
+			return graceHashJoin(lrows, rrows, out, node, 0)
+		}
+	}
+	return hashJoinRows(lrows, rrows, out, node)
+}
+
+// hashJoinRows used to join the rows in memory. The hash table is built on the smaller input
+// and probed with the other, the rows of the same key are concatenated as the merge join.
+func hashJoinRows(lrows, rrows [][]sqltypes.Value, out *joinOutput, node *builder.JoinNode) error {
+	var err error
+	build, probe := rrows, lrows
+	buildKeys, probeKeys := node.RightKeys, node.LeftKeys
+	buildLeft := len(lrows) < len(rrows)
+	if buildLeft {
+		build, probe = probe, build
+		buildKeys, probeKeys = probeKeys, buildKeys
+	}
+
+	// The left rows with null keys cannot match any right row.
+	var nulls [][]sqltypes.Value
+	var buckets []*hashBucket
+	table := make(map[string]*hashBucket)
+	for _, row := range build {
+		key, ok := hashKey(row, buildKeys)
+		if !ok {
+			if buildLeft {
+				nulls = append(nulls, row)
+			}
+			continue
+		}
+		bucket, exists := table[key]
+		if !exists {
+			bucket = &hashBucket{}
+			table[key] = bucket
+			buckets = append(buckets, bucket)
+		}
+		bucket.build = append(bucket.build, row)
+	}
+
+	for _, row := range probe {
+		key, ok := hashKey(row, probeKeys)
+		var bucket *hashBucket
+		if ok {
+			bucket = table[key]
+		}
+		if bucket == nil {
+			if !buildLeft {
+				nulls = append(nulls, row)
+			}
+			continue
+		}
+		bucket.probe = append(bucket.probe, row)
+	}
+
+	for _, bucket := range buckets {
+		lrows, rrows := bucket.probe, bucket.build
+		if buildLeft {
+			lrows, rrows = rrows, lrows
+		}
+		if len(lrows) == 0 {
+			continue
+		}
+		if len(rrows) == 0 {
+			err = concatLeftAndNil(lrows, node, out)
+		} else {
+			err = concatLeftAndRight(lrows, rrows, node, out)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return concatLeftAndNil(nulls, node, out)
+}
+
+// hashKey encodes the join key values of the row, the values equal in comparison have the
+// same key, such as: `1`, `1.0` and `1.00`. Returns false if any value is null.
+func hashKey(row []sqltypes.Value, keys []builder.JoinKey) (string, bool) {
+	var key []byte
+	for _, k := range keys {
+		v := row[k.Index]
+		if v.IsNull() {
+			return "", false
+		}
+		key = spill.AppendKey(key, v)
+	}
+	return string(key), true
+}
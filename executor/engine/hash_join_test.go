@@ -0,0 +1,127 @@
+/*
+ * NeoDB
+ *
+ * Copyright 2021-2030 The NeoDB Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package engine
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+
+	"github.com/sealdb/neodb/planner/builder"
+
+	querypb "github.com/sealdb/mysqlstack/sqlparser/depends/query"
+	"github.com/sealdb/mysqlstack/sqlparser/depends/sqltypes"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHashJoin(t *testing.T) {
+	intRow := func(vals ...string) []sqltypes.Value {
+		row := make([]sqltypes.Value, len(vals))
+		for i, v := range vals {
+			if v == "" {
+				row[i] = sqltypes.NULL
+				continue
+			}
+			row[i] = sqltypes.MakeTrusted(querypb.Type_INT32, []byte(v))
+		}
+		return row
+	}
+	decRow := func(key, val string) []sqltypes.Value {
+		return []sqltypes.Value{
+			sqltypes.MakeTrusted(querypb.Type_DECIMAL, []byte(key)),
+			sqltypes.MakeTrusted(querypb.Type_INT32, []byte(val)),
+		}
+	}
+	newResult := func(rows ...[]sqltypes.Value) *sqltypes.Result {
+		return &sqltypes.Result{Rows: rows}
+	}
+	sorted := func(rows [][]sqltypes.Value) string {
+		out := make([]string, len(rows))
+		for i, row := range rows {
+			out[i] = fmt.Sprintf("%v", row)
+		}
+		sort.Strings(out)
+		return fmt.Sprintf("%v", out)
+	}
+
+	tcases := []struct {
+		left, right *sqltypes.Result
+		isLeftJoin  bool
+		cmpFilter   []builder.Comparison
+		want        string
+	}{
+		// Build on the right.
+		{
+			left:  newResult(intRow("1", "10"), intRow("2", "20"), intRow("2", "21"), intRow("", "30"), intRow("4", "40")),
+			right: newResult(intRow("2", "200"), intRow("1", "100")),
+			want:  "[[1 10 100] [2 20 200] [2 21 200]]",
+		},
+		// Build on the left.
+		{
+			left:  newResult(intRow("1", "10"), intRow("", "30")),
+			right: newResult(intRow("1", "100"), intRow("1", "101"), intRow("", "300"), intRow("5", "500")),
+			want:  "[[1 10 100] [1 10 101]]",
+		},
+		// The left join keeps the unmatched and null key left rows.
+		{
+			left:       newResult(intRow("1", "10"), intRow("", "30"), intRow("4", "40")),
+			right:      newResult(intRow("1", "100"), intRow("1", "101"), intRow("", "300"), intRow("5", "500")),
+			isLeftJoin: true,
+			want:       "[[ 30 ] [1 10 100] [1 10 101] [4 40 ]]",
+		},
+		{
+			left:       newResult(intRow("1", "10"), intRow("2", "20"), intRow("", "30")),
+			right:      newResult(intRow("1", "100")),
+			isLeftJoin: true,
+			want:       "[[ 30 ] [1 10 100] [2 20 ]]",
+		},
+		// The other filters are checked after the keys matched.
+		{
+			left:       newResult(intRow("1", "10"), intRow("1", "200"), intRow("2", "20")),
+			right:      newResult(intRow("1", "100"), intRow("2", "10")),
+			isLeftJoin: true,
+			cmpFilter:  []builder.Comparison{{Left: 1, Right: 1, Operator: "<"}},
+			want:       "[[1 10 100] [1 200 ] [2 20 ]]",
+		},
+		// The equal values of different types have the same key.
+		{
+			left:  newResult(intRow("1", "10"), intRow("0", "20")),
+			right: newResult(decRow("1.00", "100"), decRow("-0.0", "200"), decRow("1.5", "300")),
+			want:  "[[0 20 200] [1 10 100]]",
+		},
+	}
+
+	for _, tcase := range tcases {
+		node := &builder.JoinNode{
+			Cols:       []int{-1, -2, 2},
+			LeftKeys:   []builder.JoinKey{{Index: 0}},
+			RightKeys:  []builder.JoinKey{{Index: 0}},
+			CmpFilter:  tcase.cmpFilter,
+			IsLeftJoin: tcase.isLeftJoin,
+		}
+		res := &sqltypes.Result{}
+		err := hashJoin(tcase.left, tcase.right, newJoinOutput(res, 100, nil), node)
+		assert.Nil(t, err)
+		assert.Equal(t, tcase.want, sorted(res.Rows))
+		assert.Equal(t, uint64(len(res.Rows)), res.RowsAffected)
+	}
+}
+
+func TestHashJoinMaxRowErr(t *testing.T) {
+	row := []sqltypes.Value{sqltypes.MakeTrusted(querypb.Type_INT32, []byte("1"))}
+	left := &sqltypes.Result{Rows: [][]sqltypes.Value{row, row}}
+	right := &sqltypes.Result{Rows: [][]sqltypes.Value{row, row}}
+	node := &builder.JoinNode{
+		Cols:      []int{-1, 1},
+		LeftKeys:  []builder.JoinKey{{Index: 0}},
+		RightKeys: []builder.JoinKey{{Index: 0}},
+	}
+	err := hashJoin(left, right, newJoinOutput(&sqltypes.Result{}, 3, nil), node)
+	assert.Equal(t, "unsupported: join.row.count.exceeded.allowed.limit.of.'3'", err.Error())
+}
@@ -15,7 +15,6 @@ import (
 	"github.com/sealdb/neodb/planner/builder"
 	"github.com/sealdb/neodb/xcontext"
 
-	"github.com/pkg/errors"
 	querypb "github.com/sealdb/mysqlstack/sqlparser/depends/query"
 	"github.com/sealdb/mysqlstack/sqlparser/depends/sqltypes"
 	"github.com/sealdb/mysqlstack/xlog"
@@ -48,13 +47,13 @@ func (j *JoinEngine) Execute(ctx *xcontext.ResultContext) error {
 	var eg errgroup.Group
 	var err error
 
-	maxrow := j.txn.MaxJoinRows()
+	ctx.Results = &sqltypes.Result{}
+	out := newJoinOutput(ctx.Results, j.txn.MaxJoinRows(), j.txn.MemTracker())
 	if j.node.Strategy == builder.NestLoop {
 		joinVars := make(map[string]*querypb.BindVariable)
-		if err := j.execBindVars(ctx, joinVars, true); err != nil {
-			return err
-		}
+		err = j.execNestLoop(ctx, joinVars, true, out)
 	} else {
+		// The inputs are read into memory before joining, only the joined rows are spilled.
 		lctx := xcontext.NewResultContext()
 		rctx := xcontext.NewResultContext()
 
@@ -68,38 +67,61 @@ func (j *JoinEngine) Execute(ctx *xcontext.ResultContext) error {
 			return err
 		}
 
-		ctx.Results = &sqltypes.Result{}
 		ctx.Results.Fields = joinFields(lctx.Results.Fields, rctx.Results.Fields, j.node.Cols)
 		if len(lctx.Results.Rows) == 0 {
 			return nil
 		}
 
 		if len(rctx.Results.Rows) == 0 {
-			err = concatLeftAndNil(lctx.Results.Rows, j.node, ctx.Results, maxrow)
+			err = concatLeftAndNil(lctx.Results.Rows, j.node, out)
 		} else {
 			switch j.node.Strategy {
 			case builder.SortMerge:
-				err = sortMergeJoin(lctx.Results, rctx.Results, ctx.Results, j.node, maxrow)
+				err = sortMergeJoin(lctx.Results, rctx.Results, out, j.node)
+			case builder.HashJoin:
+				err = hashJoin(lctx.Results, rctx.Results, out, j.node)
 			case builder.Cartesian:
-				err = cartesianProduct(lctx.Results, rctx.Results, ctx.Results, j.node, maxrow)
+				err = cartesianProduct(lctx.Results, rctx.Results, out, j.node)
 			}
 		}
-
-		if err != nil {
-			return err
-		}
 	}
 
-	return operator.ExecSubPlan(j.log, j.node, ctx)
+	if err != nil {
+		out.close()
+		return err
+	}
+	// The spilled rows are consumed by the children plan without being read into memory.
+	if out.spilled() {
+		return operator.ExecSubPlanRows(j.log, j.node, j.txn, ctx, out.rows)
+	}
+	if err = out.materialize(); err != nil {
+		return err
+	}
+	return operator.ExecSubPlan(j.log, j.node, j.txn, ctx)
 }
 
 // execBindVars used to execute querys with bindvars.
 func (j *JoinEngine) execBindVars(ctx *xcontext.ResultContext, bindVars map[string]*querypb.BindVariable, wantfields bool) error {
+	ctx.Results = &sqltypes.Result{}
+	out := newJoinOutput(ctx.Results, j.txn.MaxJoinRows(), j.txn.MemTracker())
+	if err := j.execNestLoop(ctx, bindVars, wantfields, out); err != nil {
+		out.close()
+		return err
+	}
+	return out.materialize()
+}
+
+// execNestLoop used to execute the NestLoop join, the joined rows are appended to the out.
+func (j *JoinEngine) execNestLoop(ctx *xcontext.ResultContext, bindVars map[string]*querypb.BindVariable, wantfields bool, out *joinOutput) error {
+	if size := j.txn.JoinBatchSize(); size > 0 && len(j.node.BatchKeys) > 0 {
+		if right, ok := j.right.(*MergeEngine); ok {
+			return j.execBatch(ctx, right, bindVars, wantfields, size, out)
+		}
+	}
+
 	var err error
 	lctx := xcontext.NewResultContext()
 	rctx := xcontext.NewResultContext()
-	maxrow := j.txn.MaxJoinRows()
-	ctx.Results = &sqltypes.Result{}
 
 	joinVars := make(map[string]*querypb.BindVariable)
 	if err = j.left.execBindVars(lctx, bindVars, wantfields); err != nil {
@@ -107,15 +129,8 @@ func (j *JoinEngine) execBindVars(ctx *xcontext.ResultContext, bindVars map[stri
 	}
 
 	for _, lrow := range lctx.Results.Rows {
-		leftMatch := true
 		matchCnt := 0
-		for _, idx := range j.node.LeftTmpCols {
-			if !sqltypes.CastToBool(lrow[idx]) {
-				leftMatch = false
-				break
-			}
-		}
-		if leftMatch {
+		if j.leftMatch(lrow) {
 			for k, col := range j.node.Vars {
 				joinVars[k] = sqltypes.ValueBindVariable(lrow[col])
 			}
@@ -128,24 +143,15 @@ func (j *JoinEngine) execBindVars(ctx *xcontext.ResultContext, bindVars map[stri
 			}
 			for _, rrow := range rctx.Results.Rows {
 				matchCnt++
-				ok := true
-				for _, idx := range j.node.RightTmpCols {
-					if !rrow[idx].IsNull() {
-						ok = false
-						break
-					}
-				}
-				if ok {
-					ctx.Results.Rows = append(ctx.Results.Rows, joinRows(lrow, rrow, j.node.Cols))
-					ctx.Results.RowsAffected++
-					if len(ctx.Results.Rows) > maxrow {
-						return errors.Errorf("unsupported: join.row.count.exceeded.allowed.limit.of.'%d'", maxrow)
+				if j.rightMatch(rrow) {
+					if err = out.append(joinRows(lrow, rrow, j.node.Cols)); err != nil {
+						return err
 					}
 				}
 			}
 		}
 		if matchCnt == 0 {
-			if err = concatLeftAndNil([][]sqltypes.Value{lrow}, j.node, ctx.Results, maxrow); err != nil {
+			if err = concatLeftAndNil([][]sqltypes.Value{lrow}, j.node, out); err != nil {
 				return err
 			}
 		}
@@ -228,14 +234,12 @@ func combineVars(bv1, bv2 map[string]*querypb.BindVariable) map[string]*querypb.
 }
 
 // cartesianProduct used to produce cartesian product.
-func cartesianProduct(lres, rres, res *sqltypes.Result, node *builder.JoinNode, maxrow int) error {
-	res.Rows = make([][]sqltypes.Value, 0, len(lres.Rows)*len(rres.Rows))
+func cartesianProduct(lres, rres *sqltypes.Result, out *joinOutput, node *builder.JoinNode) error {
+	out.grow(len(lres.Rows) * len(rres.Rows))
 	for _, lrow := range lres.Rows {
 		for _, rrow := range rres.Rows {
-			res.Rows = append(res.Rows, joinRows(lrow, rrow, node.Cols))
-			res.RowsAffected++
-			if len(res.Rows) > maxrow {
-				return errors.Errorf("unsupported: join.row.count.exceeded.allowed.limit.of.'%d'", maxrow)
+			if err := out.append(joinRows(lrow, rrow, node.Cols)); err != nil {
+				return err
 			}
 		}
 	}
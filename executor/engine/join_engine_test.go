@@ -280,6 +280,12 @@ func TestJoinEngine(t *testing.T) {
 	fakedbs.AddQuery("select B.name, B.id from sbtest.B0 as B where B.id > 2 and B.name = 's' order by B.id asc", r21)
 	fakedbs.AddQuery("select B.name, B.id from sbtest.B1 as B where B.id > 2 and B.name = 's' order by B.id asc", r21)
 	fakedbs.AddQuery("select B.name, B.id from sbtest.B0 as B where B.id > 2 order by B.id asc", r21)
+	fakedbs.AddQuery("select /*+ hash_join */ A.id from sbtest.A0 as A where A.id > 2", r1)
+	fakedbs.AddQuery("select /*+ hash_join */ A.id from sbtest.A2 as A where A.id > 2", r12)
+	fakedbs.AddQuery("select /*+ hash_join */ A.id from sbtest.A4 as A where A.id > 2", r12)
+	fakedbs.AddQuery("select /*+ hash_join */ A.id from sbtest.A8 as A where A.id > 2", r12)
+	fakedbs.AddQuery("select /*+ hash_join */ B.name, B.id from sbtest.B0 as B where B.id > 2", r21)
+	fakedbs.AddQuery("select /*+ hash_join */ B.name, B.id from sbtest.B1 as B where B.id > 2", r2)
 	fakedbs.AddQuery("select B.name, B.id from sbtest.B1 as B where B.id > 2 order by B.id asc", r2)
 	fakedbs.AddQuery("select B.name, B.id from sbtest.B1 as B where B.id = 1 and 'go' + b.name = 'golang'", r21)
 	fakedbs.AddQuery("select B.name, B.id from sbtest.B1 as B where B.id = 1 and 'lang' + b.name = 'golang'", r2)
@@ -312,6 +318,7 @@ func TestJoinEngine(t *testing.T) {
 		"select A.id, A.name, B.name, B.id from G,A,B where G.a+A.a=1 and A.name=B.name and A.id=5 and B.id=1",
 		"select B.name, A.id+B.id as id from A join B on A.name=B.name where A.id = 3",
 		"select A.id, B.name, B.id, G.a from A join B on A.id=5 and B.id=1 and 'lang'+B.name='golang' join G on G.name + A.name = 'golang'",
+		"select /*+ hash_join */ A.id, B.name from A join B on A.id=B.id where A.id > 2 order by A.id",
 	}
 	results := []string{
 		"[[3 go] [5 lang]]",
@@ -333,8 +340,9 @@ func TestJoinEngine(t *testing.T) {
 		"[]",
 		"[]",
 		"[]",
+		"[[lang 9]]",
 		"[]",
-		"[]",
+		"[[3 go] [5 lang]]",
 	}
 
 	for i, query := range querys {
@@ -17,6 +17,7 @@ import (
 
 	"github.com/sealdb/mysqlstack/sqlparser"
 	querypb "github.com/sealdb/mysqlstack/sqlparser/depends/query"
+	"github.com/sealdb/mysqlstack/sqlparser/depends/sqltypes"
 	"github.com/sealdb/mysqlstack/xlog"
 )
 
@@ -29,6 +30,9 @@ type MergeEngine struct {
 	log  *xlog.Log
 	node *builder.MergeNode
 	txn  backend.Transaction
+	// keep is whether the routes are kept by the lookup tables, nil if all.
+	keep   []bool
+	pruned bool
 }
 
 // NewMergeEngine creates the new merge executor.
@@ -48,7 +52,10 @@ func (m *MergeEngine) Execute(ctx *xcontext.ResultContext) error {
 	reqCtx.Mode = m.node.ReqMode
 	reqCtx.TxnMode = xcontext.TxnRead
 	if reqCtx.Mode == xcontext.ReqNormal {
-		reqCtx.Querys = m.node.Querys
+		if reqCtx.Querys, err = m.prune(m.node.Querys); err != nil {
+			return err
+		}
+		m.setMergeOrder(reqCtx)
 	} else {
 		buf := sqlparser.NewTrackedBuffer(nil)
 		m.node.Sel.Format(buf)
@@ -58,7 +65,37 @@ func (m *MergeEngine) Execute(ctx *xcontext.ResultContext) error {
 	if ctx.Results, err = m.txn.Execute(reqCtx); err != nil {
 		return err
 	}
-	return operator.ExecSubPlan(m.log, m.node, ctx)
+	ctx.Sorted = reqCtx.OrderBy != nil
+	return operator.ExecSubPlan(m.log, m.node, m.txn, ctx)
+}
+
+// setMergeOrder used to merge the sorted rows of the shards by the order pushed down,
+// only the first offset+limit rows are kept, the merged rows are not sorted again.
+func (m *MergeEngine) setMergeOrder(reqCtx *xcontext.RequestContext) {
+	keys, offset, limit := m.node.MergeOrder()
+	if keys == nil {
+		return
+	}
+	reqCtx.OrderBy = keys
+	reqCtx.Limit = -1
+	if limit >= 0 {
+		reqCtx.Limit = offset + limit
+	}
+}
+
+// newRequest creates the read request of the querys, the query of the node which isn't
+// in ReqNormal mode(such as the select from dual) is executed as the RawQuery.
+func (m *MergeEngine) newRequest(querys []xcontext.QueryTuple) *xcontext.RequestContext {
+	reqCtx := xcontext.NewRequestContext()
+	reqCtx.Mode = m.node.ReqMode
+	reqCtx.TxnMode = xcontext.TxnRead
+	if reqCtx.Mode != xcontext.ReqNormal {
+		reqCtx.RawQuery = querys[0].Query
+		return reqCtx
+	}
+	reqCtx.Querys = querys
+	m.setMergeOrder(reqCtx)
+	return reqCtx
 }
 
 // execBindVars used to execute querys with bindvas.
@@ -74,16 +111,124 @@ func (m *MergeEngine) execBindVars(ctx *xcontext.ResultContext, bindVars map[str
 		}
 		querys[i].Query = query
 	}
+	if querys, err = m.prune(querys); err != nil {
+		return err
+	}
 
-	reqCtx := xcontext.NewRequestContext()
-	reqCtx.Mode = xcontext.ReqNormal
-	reqCtx.TxnMode = xcontext.TxnRead
-	reqCtx.Querys = querys
+	reqCtx := m.newRequest(querys)
 
 	if ctx.Results, err = m.txn.Execute(reqCtx); err != nil {
 		return err
 	}
-	return operator.ExecSubPlan(m.log, m.node, ctx)
+	ctx.Sorted = reqCtx.OrderBy != nil
+	return operator.ExecSubPlan(m.log, m.node, m.txn, ctx)
+}
+
+// execBatch used to execute the batch querys, the route without bindvars is skipped.
+// The Results is nil if all the routes are skipped.
+func (m *MergeEngine) execBatch(ctx *xcontext.ResultContext, routeVars []map[string]*querypb.BindVariable) error {
+	keep, err := m.routes()
+	if err != nil {
+		return err
+	}
+	var querys []xcontext.QueryTuple
+	for i, p := range m.node.BatchQuerys {
+		if routeVars[i] == nil || (keep != nil && !keep[i]) {
+			continue
+		}
+		query, err := p.GenerateQuery(routeVars[i], nil)
+		if err != nil {
+			return err
+		}
+		tuple := m.node.Querys[i]
+		tuple.Query = query
+		querys = append(querys, tuple)
+	}
+	if len(querys) == 0 {
+		ctx.Results = nil
+		return nil
+	}
+
+	reqCtx := m.newRequest(querys)
+
+	if ctx.Results, err = m.txn.Execute(reqCtx); err != nil {
+		return err
+	}
+	ctx.Sorted = reqCtx.OrderBy != nil
+	return operator.ExecSubPlan(m.log, m.node, m.txn, ctx)
+}
+
+// routes returns whether the routes of the node are kept by the lookup tables, nil if all.
+// The lookup tables are read once in the transaction.
+func (m *MergeEngine) routes() ([]bool, error) {
+	if !m.pruned {
+		keep, err := PruneRoutes(m.txn, m.node.LookupPrunes())
+		if err != nil {
+			return nil, err
+		}
+		m.keep, m.pruned = keep, true
+	}
+	return m.keep, nil
+}
+
+// prune returns the querys of the routes kept by the lookup tables.
+func (m *MergeEngine) prune(querys []xcontext.QueryTuple) ([]xcontext.QueryTuple, error) {
+	keep, err := m.routes()
+	if err != nil || keep == nil {
+		return querys, err
+	}
+	res := make([]xcontext.QueryTuple, 0, len(querys))
+	for i, query := range querys {
+		if keep[i] {
+			res = append(res, query)
+		}
+	}
+	return res, nil
+}
+
+// PruneRoutes used to read the lookup tables of the prunes in the transaction, so the pairs
+// written by the transaction are seen. Returns whether the routes are kept by all the prunes,
+// nil if all the routes are kept.
+func PruneRoutes(txn backend.Transaction, prunes []*builder.LookupPrune) ([]bool, error) {
+	var keep []bool
+	for _, prune := range prunes {
+		querys, err := prune.Querys()
+		if err != nil {
+			return nil, err
+		}
+		reqCtx := xcontext.NewRequestContext()
+		reqCtx.Mode = xcontext.ReqNormal
+		reqCtx.TxnMode = xcontext.TxnRead
+		reqCtx.Querys = querys
+		qr, err := txn.Execute(reqCtx)
+		if err != nil {
+			return nil, err
+		}
+		routes, err := prune.Routes([]*sqltypes.Result{qr})
+		if err != nil {
+			return nil, err
+		}
+		if routes == nil {
+			continue
+		}
+		if keep == nil {
+			keep = routes
+			continue
+		}
+		for i := range keep {
+			keep[i] = keep[i] && routes[i]
+		}
+	}
+	// At least one route is kept to return the fields.
+	for i := range keep {
+		if keep[i] {
+			return keep, nil
+		}
+	}
+	if len(keep) > 0 {
+		keep[0] = true
+	}
+	return keep, nil
 }
 
 // getFields fetches the field info.
@@ -97,9 +242,13 @@ func (m *MergeEngine) getFields(ctx *xcontext.ResultContext, bindVars map[string
 	}
 
 	reqCtx := xcontext.NewRequestContext()
-	reqCtx.Mode = xcontext.ReqNormal
+	reqCtx.Mode = m.node.ReqMode
 	reqCtx.TxnMode = xcontext.TxnRead
-	reqCtx.Querys = []xcontext.QueryTuple{query}
+	if reqCtx.Mode == xcontext.ReqNormal {
+		reqCtx.Querys = []xcontext.QueryTuple{query}
+	} else {
+		reqCtx.RawQuery = query.Query
+	}
 
 	if ctx.Results, err = m.txn.Execute(reqCtx); err != nil {
 		return err
@@ -38,6 +38,10 @@ func TestMergeEngine(t *testing.T) {
 			},
 		},
 		Rows: [][]sqltypes.Value{
+			{
+				sqltypes.MakeTrusted(querypb.Type_INT32, []byte("5")),
+				sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("g")),
+			},
 			{
 				sqltypes.MakeTrusted(querypb.Type_INT32, []byte("3")),
 				sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("z")),
@@ -46,10 +50,6 @@ func TestMergeEngine(t *testing.T) {
 				sqltypes.MakeTrusted(querypb.Type_INT32, []byte("1")),
 				sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("x")),
 			},
-			{
-				sqltypes.MakeTrusted(querypb.Type_INT32, []byte("5")),
-				sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("g")),
-			},
 		},
 	}
 	r2 := &sqltypes.Result{
@@ -64,14 +64,14 @@ func TestMergeEngine(t *testing.T) {
 			},
 		},
 		Rows: [][]sqltypes.Value{
-			{
-				sqltypes.MakeTrusted(querypb.Type_INT32, []byte("3")),
-				sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("go")),
-			},
 			{
 				sqltypes.MakeTrusted(querypb.Type_INT32, []byte("51")),
 				sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("lang")),
 			},
+			{
+				sqltypes.MakeTrusted(querypb.Type_INT32, []byte("3")),
+				sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("go")),
+			},
 		},
 	}
 	r3 := &sqltypes.Result{}
@@ -128,6 +128,79 @@ func TestMergeEngine(t *testing.T) {
 	}
 }
 
+func TestMergeEngineMergeOrder(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	database := "sbtest"
+
+	route, cleanup := router.MockNewRouter(log)
+	defer cleanup()
+
+	err := route.CreateDatabase(database)
+	assert.Nil(t, err)
+	err = route.AddForTest(database, router.MockTableAConfig())
+	assert.Nil(t, err)
+
+	// Each shard returns the sorted rows, the shard Ai returns the ids i, i+10, i+20, ...
+	newResult := func(shard int, n int, desc bool) *sqltypes.Result {
+		res := &sqltypes.Result{
+			Fields: []*querypb.Field{
+				{Name: "id", Type: querypb.Type_INT32},
+				{Name: "name", Type: querypb.Type_VARCHAR},
+			},
+		}
+		for i := 0; i < n; i++ {
+			id := shard + i*10
+			if desc {
+				id = shard + (n-1-i)*10
+			}
+			res.Rows = append(res.Rows, []sqltypes.Value{
+				sqltypes.MakeTrusted(querypb.Type_INT32, []byte(fmt.Sprintf("%d", id))),
+				sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(fmt.Sprintf("A%d", shard))),
+			})
+		}
+		return res
+	}
+
+	scatter, fakedbs, cleanup := backend.MockScatter(log, 10)
+	defer cleanup()
+	for _, i := range []int{0, 2, 4, 8} {
+		fakedbs.AddQuery(fmt.Sprintf("select id, name from sbtest.A%d as A order by id desc limit 5", i), newResult(i, 5, true))
+		fakedbs.AddQuery(fmt.Sprintf("select id, name from sbtest.A%d as A order by id asc limit 4", i), newResult(i, 4, false))
+	}
+
+	tcases := []struct {
+		query  string
+		result string
+	}{
+		{
+			query:  "select id, name from A order by id desc limit 2, 3",
+			result: "[[42 A2] [40 A0] [38 A8]]",
+		},
+		{
+			query:  "select id, name from A order by id asc limit 4",
+			result: "[[0 A0] [2 A2] [4 A4] [8 A8]]",
+		},
+	}
+
+	for _, tcase := range tcases {
+		node, err := sqlparser.Parse(tcase.query)
+		assert.Nil(t, err)
+
+		plan := planner.NewSelectPlan(log, database, tcase.query, node.(*sqlparser.Select), route)
+		err = plan.Build()
+		assert.Nil(t, err)
+
+		txn, err := scatter.CreateTransaction()
+		assert.Nil(t, err)
+		planEngine := BuildEngine(log, plan.Root, txn)
+		ctx := xcontext.NewResultContext()
+		err = planEngine.Execute(ctx)
+		assert.Nil(t, err, tcase.query)
+		assert.Equal(t, tcase.result, fmt.Sprintf("%v", ctx.Results.Rows), tcase.query)
+		txn.Finish()
+	}
+}
+
 func TestGenerateQueryErr(t *testing.T) {
 	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
 	database := "sbtest"
@@ -144,7 +217,7 @@ func TestGenerateQueryErr(t *testing.T) {
 	scatter, _, cleanup := backend.MockScatter(log, 10)
 	defer cleanup()
 
-	query := "select B.name, A.id+B.id as id from A join B on A.name=B.name where A.id = 3"
+	query := "select B.name, json_array(A.id, B.id) as id from A join B on A.name=B.name where A.id = 3"
 	want := "missing bind var A_id"
 
 	node, err := sqlparser.Parse(query)
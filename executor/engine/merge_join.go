@@ -15,7 +15,6 @@ import (
 
 	"github.com/sealdb/neodb/planner/builder"
 
-	"github.com/pkg/errors"
 	"github.com/sealdb/mysqlstack/sqlparser"
 	"github.com/sealdb/mysqlstack/sqlparser/depends/sqltypes"
 )
@@ -26,7 +25,7 @@ const (
 )
 
 // sortMergeJoin used to join `lres` and `rres` to `res`.
-func sortMergeJoin(lres, rres, res *sqltypes.Result, node *builder.JoinNode, maxrow int) error {
+func sortMergeJoin(lres, rres *sqltypes.Result, out *joinOutput, node *builder.JoinNode) error {
 	var wg sync.WaitGroup
 	sort := func(keys []builder.JoinKey, res *sqltypes.Result) {
 		defer wg.Done()
@@ -47,17 +46,17 @@ func sortMergeJoin(lres, rres, res *sqltypes.Result, node *builder.JoinNode, max
 	go sort(node.RightKeys, rres)
 	wg.Wait()
 
-	return mergeJoin(lres, rres, res, node, maxrow)
+	return mergeJoin(lres, rres, out, node)
 }
 
 // mergeJoin used to join the sorted results.
-func mergeJoin(lres, rres, res *sqltypes.Result, node *builder.JoinNode, maxrow int) error {
+func mergeJoin(lres, rres *sqltypes.Result, out *joinOutput, node *builder.JoinNode) error {
 	var err error
 	lrows, lidx := fetchSameKeyRows(lres.Rows, node.LeftKeys, 0)
 	rrows, ridx := fetchSameKeyRows(rres.Rows, node.RightKeys, 0)
 	for lrows != nil {
 		if rrows == nil {
-			err = concatLeftAndNil(lres.Rows[lidx-len(lrows):], node, res, maxrow)
+			err = concatLeftAndNil(lres.Rows[lidx-len(lrows):], node, out)
 			break
 		}
 
@@ -76,9 +75,9 @@ func mergeJoin(lres, rres, res *sqltypes.Result, node *builder.JoinNode, maxrow
 
 		if cmp == 0 {
 			if isNull {
-				err = concatLeftAndNil(lrows, node, res, maxrow)
+				err = concatLeftAndNil(lrows, node, out)
 			} else {
-				err = concatLeftAndRight(lrows, rrows, node, res, maxrow)
+				err = concatLeftAndRight(lrows, rrows, node, out)
 			}
 
 			lrows, lidx = fetchSameKeyRows(lres.Rows, node.LeftKeys, lidx)
@@ -86,7 +85,7 @@ func mergeJoin(lres, rres, res *sqltypes.Result, node *builder.JoinNode, maxrow
 		} else if cmp > 0 {
 			rrows, ridx = fetchSameKeyRows(rres.Rows, node.RightKeys, ridx)
 		} else {
-			err = concatLeftAndNil(lrows, node, res, maxrow)
+			err = concatLeftAndNil(lrows, node, out)
 			lrows, lidx = fetchSameKeyRows(lres.Rows, node.LeftKeys, lidx)
 		}
 
@@ -134,7 +133,7 @@ func keysEqual(row1, row2 []sqltypes.Value, joins []builder.JoinKey) bool {
 }
 
 // concatLeftAndRight used to concat the left and right results, handle otherLeftJoin|rightNull|OtherFilter.
-func concatLeftAndRight(lrows, rrows [][]sqltypes.Value, node *builder.JoinNode, res *sqltypes.Result, maxrow int) error {
+func concatLeftAndRight(lrows, rrows [][]sqltypes.Value, node *builder.JoinNode, out *joinOutput) error {
 	var err error
 	var mu sync.Mutex
 	p := newCalcPool(joinWorkers)
@@ -213,10 +212,7 @@ func concatLeftAndRight(lrows, rrows [][]sqltypes.Value, node *builder.JoinNode,
 					if ok {
 						mu.Lock()
 						if err == nil {
-							res.Rows = append(res.Rows, joinRows(lrow, rrow, node.Cols))
-							res.RowsAffected++
-							if len(res.Rows) > maxrow {
-								err = errors.Errorf("unsupported: join.row.count.exceeded.allowed.limit.of.'%d'", maxrow)
+							if err = out.append(joinRows(lrow, rrow, node.Cols)); err != nil {
 								mu.Unlock()
 								break
 							}
@@ -229,11 +225,7 @@ func concatLeftAndRight(lrows, rrows [][]sqltypes.Value, node *builder.JoinNode,
 		if matchCnt == 0 && node.IsLeftJoin && !node.HasRightFilter {
 			mu.Lock()
 			if err == nil {
-				res.Rows = append(res.Rows, joinRows(lrow, nil, node.Cols))
-				res.RowsAffected++
-				if len(res.Rows) > maxrow {
-					err = errors.Errorf("unsupported: join.row.count.exceeded.allowed.limit.of.'%d'", maxrow)
-				}
+				err = out.append(joinRows(lrow, nil, node.Cols))
 			}
 			mu.Unlock()
 		}
@@ -248,13 +240,11 @@ func concatLeftAndRight(lrows, rrows [][]sqltypes.Value, node *builder.JoinNode,
 	return err
 }
 
-func concatLeftAndNil(lrows [][]sqltypes.Value, node *builder.JoinNode, res *sqltypes.Result, maxrow int) error {
+func concatLeftAndNil(lrows [][]sqltypes.Value, node *builder.JoinNode, out *joinOutput) error {
 	if node.IsLeftJoin && !node.HasRightFilter {
 		for _, row := range lrows {
-			res.Rows = append(res.Rows, joinRows(row, nil, node.Cols))
-			res.RowsAffected++
-			if len(res.Rows) > maxrow {
-				return errors.Errorf("unsupported: join.row.count.exceeded.allowed.limit.of.'%d'", maxrow)
+			if err := out.append(joinRows(row, nil, node.Cols)); err != nil {
+				return err
 			}
 		}
 	}
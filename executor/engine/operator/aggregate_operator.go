@@ -10,11 +10,17 @@
 package operator
 
 import (
+	"bytes"
+	"encoding/json"
+	"math"
 	"sort"
+	"unicode/utf8"
 
 	"github.com/sealdb/neodb/planner/builder"
 	"github.com/sealdb/neodb/xcontext"
 
+	"github.com/pkg/errors"
+	querypb "github.com/sealdb/mysqlstack/sqlparser/depends/query"
 	"github.com/sealdb/mysqlstack/sqlparser/depends/sqltypes"
 	"github.com/sealdb/mysqlstack/xlog"
 )
@@ -24,43 +30,43 @@ var (
 )
 
 // AggregateOperator represents aggregate operator.
-// Including: COUNT/MAX/MIN/SUM/AVG/GROUPBY.
+// Including: COUNT/MAX/MIN/SUM/AVG/GROUPBY,
+// GROUP_CONCAT/BIT_AND/BIT_OR/BIT_XOR/STD/STDDEV_SAMP/VARIANCE/VAR_SAMP/JSON_ARRAYAGG/JSON_OBJECTAGG.
 type AggregateOperator struct {
 	log  *xlog.Log
 	plan builder.ChildPlan
+	// groupConcatMaxLen is the maximum length of the GROUP_CONCAT result.
+	groupConcatMaxLen int
 }
 
 // NewAggregateOperator creates new AggregateOperator.
-func NewAggregateOperator(log *xlog.Log, plan builder.ChildPlan) *AggregateOperator {
+func NewAggregateOperator(log *xlog.Log, plan builder.ChildPlan, groupConcatMaxLen int) *AggregateOperator {
 	return &AggregateOperator{
-		log:  log,
-		plan: plan,
+		log:               log,
+		plan:              plan,
+		groupConcatMaxLen: groupConcatMaxLen,
 	}
 }
 
 // Execute used to execute the operator.
 func (operator *AggregateOperator) Execute(ctx *xcontext.ResultContext) error {
 	rs := ctx.Results
-	operator.aggregate(rs)
-	return nil
+	return operator.aggregate(rs)
 }
 
-// Aggregate used to do rows-aggregator(COUNT/SUM/MIN/MAX/AVG) and grouped them into group-by fields.
+// Aggregate used to do rows-aggregator(COUNT/SUM/MIN/MAX/AVG...) and grouped them into group-by fields.
 // Don't use `group by` alone, `group by` needs to be used with the aggregation function. Otherwise
 // the result of neodb may be different from the result of mysql.
 // eg: select a,b from tb group by b.        ×
 //
 //	select count(a),b from tb group by b. √
 //	select b from tb group by b.          √
-func (operator *AggregateOperator) aggregate(result *sqltypes.Result) {
-	var deIdxs []int
+func (operator *AggregateOperator) aggregate(result *sqltypes.Result) error {
 	plan := operator.plan.(*builder.AggregatePlan)
 	if plan.Empty() {
-		return
+		return nil
 	}
 
-	aggPlans := plan.NormalAggregators()
-	aggPlansLen := len(aggPlans)
 	groupAggrs := plan.GroupAggregators()
 	if len(groupAggrs) > 0 {
 		sort.Slice(result.Rows, func(i, j int) bool {
@@ -75,55 +81,127 @@ func (operator *AggregateOperator) aggregate(result *sqltypes.Result) {
 		})
 	}
 
-	type group struct {
-		row      []sqltypes.Value
-		evalCtxs []*sqltypes.AggEvaluateContext
+	aggr := newAggregator(plan, result.Fields, operator.groupConcatMaxLen)
+	var groups []*group
+	for _, row := range result.Rows {
+		length := len(groups)
+		if length == 0 || !keysEqual(groups[length-1].row, row, groupAggrs) {
+			g, err := aggr.newGroup(row)
+			if err != nil {
+				return err
+			}
+			groups = append(groups, g)
+			continue
+		}
+		if err := aggr.update(groups[length-1], row); err != nil {
+			return err
+		}
 	}
 
-	var aggrs []*sqltypes.Aggregation
-	for _, aggPlan := range aggPlans {
-		aggr := sqltypes.NewAggregation(aggPlan.Index, aggPlan.Type, aggPlan.Distinct, plan.IsPushDown)
-		aggr.FixField(result.Fields[aggPlan.Index])
-		aggrs = append(aggrs, aggr)
+	rows := make([][]sqltypes.Value, 0, len(groups))
+	for _, g := range groups {
+		row, err := aggr.result(g)
+		if err != nil {
+			return err
+		}
+		rows = append(rows, row)
 	}
+	return aggr.finish(result, rows)
+}
 
-	var groups []*group
-	for _, row := range result.Rows {
-		length := len(groups)
-		if length == 0 {
-			evalCtxs := sqltypes.NewAggEvalCtxs(aggrs, row)
-			groups = append(groups, &group{row, evalCtxs})
+// group is the aggregate state of the rows with the same group by values.
+type group struct {
+	row      []sqltypes.Value
+	evalCtxs []*sqltypes.AggEvaluateContext
+	extCtxs  []*extEvalContext
+}
+
+// aggregator used to calculate the aggregates of the groups.
+type aggregator struct {
+	aggrs    []*sqltypes.Aggregation
+	extAggrs []*extAggregation
+	// deIdxs and extIdxs are the decomposed columns which need be removed.
+	deIdxs, extIdxs []int
+}
+
+func newAggregator(plan *builder.AggregatePlan, fields []*querypb.Field, groupConcatMaxLen int) *aggregator {
+	a := &aggregator{}
+	for _, aggPlan := range plan.NormalAggregators() {
+		if isExtAggregate(aggPlan.Type) {
+			aggr := newExtAggregation(aggPlan, plan.IsPushDown, groupConcatMaxLen)
+			aggr.fixField(fields[aggPlan.Index])
+			a.extAggrs = append(a.extAggrs, aggr)
+			a.extIdxs = append(a.extIdxs, aggr.removedIdxs()...)
 			continue
 		}
+		aggr := sqltypes.NewAggregation(aggPlan.Index, aggPlan.Type, aggPlan.Distinct, plan.IsPushDown)
+		aggr.FixField(fields[aggPlan.Index])
+		a.aggrs = append(a.aggrs, aggr)
+	}
+	return a
+}
 
-		equal := keysEqual(groups[length-1].row, row, groupAggrs)
-		if equal {
-			if aggPlansLen > 0 {
-				for i, aggr := range aggrs {
-					aggr.Update(row, groups[length-1].evalCtxs[i])
-				}
-			}
-		} else {
-			evalCtxs := sqltypes.NewAggEvalCtxs(aggrs, row)
-			groups = append(groups, &group{row, evalCtxs})
+// empty returns true if there are no aggregate functions.
+func (a *aggregator) empty() bool {
+	return len(a.aggrs) == 0 && len(a.extAggrs) == 0
+}
+
+// newGroup creates the group by the first row.
+func (a *aggregator) newGroup(row []sqltypes.Value) (*group, error) {
+	g := &group{row: row, evalCtxs: sqltypes.NewAggEvalCtxs(a.aggrs, row)}
+	for _, aggr := range a.extAggrs {
+		extCtx := aggr.initEvalCtx()
+		if err := aggr.update(row, extCtx); err != nil {
+			return nil, err
 		}
+		g.extCtxs = append(g.extCtxs, extCtx)
 	}
+	return g, nil
+}
 
-	// Handle the avg operator and rebuild the results.
-	i := 0
-	result.Rows = make([][]sqltypes.Value, len(groups))
-	for _, g := range groups {
-		result.Rows[i], deIdxs = sqltypes.GetResults(aggrs, g.evalCtxs, g.row)
-		i++
+// update used to update the group by the row.
+func (a *aggregator) update(g *group, row []sqltypes.Value) error {
+	for i, aggr := range a.aggrs {
+		aggr.Update(row, g.evalCtxs[i])
+	}
+	for i, aggr := range a.extAggrs {
+		if err := aggr.update(row, g.extCtxs[i]); err != nil {
+			return err
+		}
 	}
+	return nil
+}
 
-	if len(groups) == 0 && aggPlansLen > 0 {
+// result returns the result row of the group, handle the avg operator.
+func (a *aggregator) result(g *group) ([]sqltypes.Value, error) {
+	var err error
+	var row []sqltypes.Value
+	row, a.deIdxs = sqltypes.GetResults(a.aggrs, g.evalCtxs, g.row)
+	for j, aggr := range a.extAggrs {
+		if row[aggr.Index], err = aggr.getResult(g.extCtxs[j]); err != nil {
+			return nil, err
+		}
+	}
+	return row, nil
+}
+
+// finish used to rebuild the results by the rows of the groups.
+func (a *aggregator) finish(result *sqltypes.Result, rows [][]sqltypes.Value) error {
+	var err error
+	result.Rows = rows
+	if len(rows) == 0 && !a.empty() {
 		result.Rows = make([][]sqltypes.Value, 1)
-		evalCtxs := sqltypes.NewAggEvalCtxs(aggrs, nil)
-		result.Rows[0], deIdxs = sqltypes.GetResults(aggrs, evalCtxs, make([]sqltypes.Value, len(result.Fields)))
+		evalCtxs := sqltypes.NewAggEvalCtxs(a.aggrs, nil)
+		result.Rows[0], a.deIdxs = sqltypes.GetResults(a.aggrs, evalCtxs, make([]sqltypes.Value, len(result.Fields)))
+		for _, aggr := range a.extAggrs {
+			if result.Rows[0][aggr.Index], err = aggr.getResult(aggr.initEvalCtx()); err != nil {
+				return err
+			}
+		}
 	}
-	// Remove avg decompose columns.
-	result.RemoveColumns(deIdxs...)
+	// Remove avg and the other decompose columns.
+	result.RemoveColumns(append(a.deIdxs, a.extIdxs...)...)
+	return nil
 }
 
 func keysEqual(row1, row2 []sqltypes.Value, groups []builder.Aggregator) bool {
@@ -135,3 +213,350 @@ func keysEqual(row1, row2 []sqltypes.Value, groups []builder.Aggregator) bool {
 	}
 	return true
 }
+
+// isExtAggregate returns true if the aggregate type is not supported by the sqltypes.Aggregation.
+func isExtAggregate(typ sqltypes.AggrType) bool {
+	switch typ {
+	case builder.AggrTypeGroupConcat,
+		builder.AggrTypeBitAnd, builder.AggrTypeBitOr, builder.AggrTypeBitXor,
+		builder.AggrTypeJSONArrayAgg, builder.AggrTypeJSONObjectAgg:
+		return true
+	}
+	return builder.IsStatisticsAggr(typ)
+}
+
+// extAggregation used to merge the GROUP_CONCAT/BIT_*/STD/VARIANCE/JSON_* aggregates.
+// If pushed down, the partial results of the backends are merged:
+// BIT_*: merged by the same bit operation.
+// STD/VARIANCE: merged by the sum, the sum of squares and the count.
+// JSON_ARRAYAGG/JSON_OBJECTAGG: merged by the elements/members.
+// Otherwise the aggregates are calculated by the raw rows.
+type extAggregation struct {
+	builder.Aggregator
+	isPushDown bool
+	maxLen     int
+	fieldType  querypb.Type
+}
+
+// extEvalContext is the aggregate state of a group.
+type extEvalContext struct {
+	hasValue bool
+	// BIT_*.
+	bits uint64
+	// STD/VARIANCE.
+	count, sum, sumSq float64
+	// GROUP_CONCAT.
+	items []concatItem
+	seen  map[string]struct{}
+	// JSON_ARRAYAGG.
+	elems [][]byte
+	// JSON_OBJECTAGG.
+	members map[string][]byte
+}
+
+// concatItem is the value of GROUP_CONCAT with the order keys.
+type concatItem struct {
+	value []byte
+	keys  []sqltypes.Value
+}
+
+func newExtAggregation(aggr builder.Aggregator, isPushDown bool, maxLen int) *extAggregation {
+	return &extAggregation{
+		Aggregator: aggr,
+		isPushDown: isPushDown,
+		maxLen:     maxLen,
+	}
+}
+
+// fixField used to fix the field type of the result.
+func (aggr *extAggregation) fixField(field *querypb.Field) {
+	switch aggr.Type {
+	case builder.AggrTypeBitAnd, builder.AggrTypeBitOr, builder.AggrTypeBitXor:
+		field.Type = querypb.Type_UINT64
+		field.ColumnLength = 21
+		field.Decimals = 0
+	case builder.AggrTypeGroupConcat:
+		// Same as MySQL, the result is VARCHAR if the max length is not more than 512.
+		field.Type = querypb.Type_VARCHAR
+		if aggr.maxLen > 512 {
+			field.Type = querypb.Type_TEXT
+		}
+		field.ColumnLength = uint32(aggr.maxLen)
+		field.Decimals = 31
+	case builder.AggrTypeJSONArrayAgg, builder.AggrTypeJSONObjectAgg:
+		field.Type = querypb.Type_JSON
+		field.Decimals = 31
+	default:
+		field.Type = querypb.Type_FLOAT64
+		field.ColumnLength = 23
+		field.Decimals = 31
+	}
+	aggr.fieldType = field.Type
+}
+
+// removedIdxs returns the decomposed columns which need be removed.
+func (aggr *extAggregation) removedIdxs() []int {
+	idxs := append([]int{}, aggr.Extras...)
+	for _, order := range aggr.OrderBy {
+		idxs = append(idxs, order.Index)
+	}
+	return idxs
+}
+
+func (aggr *extAggregation) initEvalCtx() *extEvalContext {
+	evalCtx := &extEvalContext{}
+	switch aggr.Type {
+	case builder.AggrTypeBitAnd:
+		evalCtx.bits = math.MaxUint64
+	case builder.AggrTypeGroupConcat:
+		if aggr.Distinct {
+			evalCtx.seen = make(map[string]struct{})
+		}
+	case builder.AggrTypeJSONObjectAgg:
+		evalCtx.members = make(map[string][]byte)
+	}
+	return evalCtx
+}
+
+// update used to update the aggregate state by the row.
+func (aggr *extAggregation) update(row []sqltypes.Value, evalCtx *extEvalContext) error {
+	v := row[aggr.Index]
+	switch aggr.Type {
+	case builder.AggrTypeBitAnd, builder.AggrTypeBitOr, builder.AggrTypeBitXor:
+		if v.IsNull() {
+			return nil
+		}
+		x := valueToUint64(v)
+		switch aggr.Type {
+		case builder.AggrTypeBitAnd:
+			evalCtx.bits &= x
+		case builder.AggrTypeBitOr:
+			evalCtx.bits |= x
+		default:
+			evalCtx.bits ^= x
+		}
+	case builder.AggrTypeGroupConcat:
+		if v.IsNull() {
+			return nil
+		}
+		if evalCtx.seen != nil {
+			if _, ok := evalCtx.seen[string(v.Raw())]; ok {
+				return nil
+			}
+			evalCtx.seen[string(v.Raw())] = struct{}{}
+		}
+		item := concatItem{value: v.Raw()}
+		for _, order := range aggr.OrderBy {
+			item.keys = append(item.keys, row[order.Index])
+		}
+		evalCtx.items = append(evalCtx.items, item)
+	case builder.AggrTypeJSONArrayAgg:
+		if !aggr.isPushDown {
+			evalCtx.hasValue = true
+			evalCtx.elems = append(evalCtx.elems, encodeJSONValue(v))
+			return nil
+		}
+		if v.IsNull() {
+			return nil
+		}
+		var elems []json.RawMessage
+		if err := json.Unmarshal(v.Raw(), &elems); err != nil {
+			return errors.Errorf("unsupported: invalid.json.array.'%s'", v.Raw())
+		}
+		evalCtx.hasValue = true
+		for _, elem := range elems {
+			evalCtx.elems = append(evalCtx.elems, elem)
+		}
+	case builder.AggrTypeJSONObjectAgg:
+		if !aggr.isPushDown {
+			if v.IsNull() {
+				return errors.New("JSON documents may not contain NULL member names")
+			}
+			evalCtx.hasValue = true
+			evalCtx.members[v.ToString()] = encodeJSONValue(row[aggr.Extras[0]])
+			return nil
+		}
+		if v.IsNull() {
+			return nil
+		}
+		var members map[string]json.RawMessage
+		if err := json.Unmarshal(v.Raw(), &members); err != nil {
+			return errors.Errorf("unsupported: invalid.json.object.'%s'", v.Raw())
+		}
+		evalCtx.hasValue = true
+		for key, val := range members {
+			evalCtx.members[key] = val
+		}
+	default:
+		// STD/VARIANCE.
+		if v.IsNull() {
+			return nil
+		}
+		x, _ := v.ParseFloat64()
+		if !aggr.isPushDown {
+			evalCtx.count++
+			evalCtx.sum += x
+			evalCtx.sumSq += x * x
+			return nil
+		}
+		sumSq, _ := row[aggr.Extras[0]].ParseFloat64()
+		count, _ := row[aggr.Extras[1]].ParseFloat64()
+		evalCtx.count += count
+		evalCtx.sum += x
+		evalCtx.sumSq += sumSq
+	}
+	return nil
+}
+
+// getResult returns the aggregate result of the group.
+func (aggr *extAggregation) getResult(evalCtx *extEvalContext) (sqltypes.Value, error) {
+	switch aggr.Type {
+	case builder.AggrTypeBitAnd, builder.AggrTypeBitOr, builder.AggrTypeBitXor:
+		return sqltypes.NewUint64(evalCtx.bits), nil
+	case builder.AggrTypeGroupConcat:
+		if len(evalCtx.items) == 0 {
+			return sqltypes.NULL, nil
+		}
+		return sqltypes.MakeTrusted(aggr.fieldType, aggr.concat(evalCtx.items)), nil
+	case builder.AggrTypeJSONArrayAgg:
+		if !evalCtx.hasValue {
+			return sqltypes.NULL, nil
+		}
+		buf := bytes.NewBufferString("[")
+		for i, elem := range evalCtx.elems {
+			if i > 0 {
+				buf.WriteString(", ")
+			}
+			buf.Write(elem)
+		}
+		buf.WriteString("]")
+		return sqltypes.MakeTrusted(querypb.Type_JSON, buf.Bytes()), nil
+	case builder.AggrTypeJSONObjectAgg:
+		if !evalCtx.hasValue {
+			return sqltypes.NULL, nil
+		}
+		// Same as MySQL, the keys are sorted by the length and then the bytes.
+		keys := make([]string, 0, len(evalCtx.members))
+		for key := range evalCtx.members {
+			keys = append(keys, key)
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			if len(keys[i]) != len(keys[j]) {
+				return len(keys[i]) < len(keys[j])
+			}
+			return keys[i] < keys[j]
+		})
+		buf := bytes.NewBufferString("{")
+		for i, key := range keys {
+			if i > 0 {
+				buf.WriteString(", ")
+			}
+			buf.Write(encodeJSONString(key))
+			buf.WriteString(": ")
+			buf.Write(evalCtx.members[key])
+		}
+		buf.WriteString("}")
+		return sqltypes.MakeTrusted(querypb.Type_JSON, buf.Bytes()), nil
+	default:
+		// STD/VARIANCE.
+		n := evalCtx.count
+		if n == 0 {
+			return sqltypes.NULL, nil
+		}
+		variance := (evalCtx.sumSq - evalCtx.sum*evalCtx.sum/n) / n
+		if aggr.Type == builder.AggrTypeStdSamp || aggr.Type == builder.AggrTypeVarSamp {
+			if n == 1 {
+				return sqltypes.NULL, nil
+			}
+			variance = (evalCtx.sumSq - evalCtx.sum*evalCtx.sum/n) / (n - 1)
+		}
+		// Avoid the negative value caused by the floating point error.
+		if variance < 0 {
+			variance = 0
+		}
+		if aggr.Type == builder.AggrTypeStd || aggr.Type == builder.AggrTypeStdSamp {
+			return sqltypes.NewFloat64(math.Sqrt(variance)), nil
+		}
+		return sqltypes.NewFloat64(variance), nil
+	}
+}
+
+// concat used to sort the items and join them by the separator,
+// the result is truncated to the group_concat_max_len.
+func (aggr *extAggregation) concat(items []concatItem) []byte {
+	if len(aggr.OrderBy) > 0 {
+		sort.SliceStable(items, func(i, j int) bool {
+			for k, order := range aggr.OrderBy {
+				cmp := sqltypes.NullsafeCompare(items[i].keys[k], items[j].keys[k])
+				if cmp == 0 {
+					continue
+				}
+				if order.Direction == builder.DESC {
+					return cmp > 0
+				}
+				return cmp < 0
+			}
+			return false
+		})
+	}
+
+	var buf bytes.Buffer
+	for i, item := range items {
+		if i > 0 {
+			buf.WriteString(aggr.Separator)
+		}
+		buf.Write(item.value)
+		if buf.Len() > aggr.maxLen {
+			break
+		}
+	}
+	res := buf.Bytes()
+	if len(res) > aggr.maxLen {
+		// Truncate at the character boundary.
+		n := aggr.maxLen
+		for n > 0 && !utf8.RuneStart(res[n]) {
+			n--
+		}
+		res = res[:n]
+	}
+	return res
+}
+
+// valueToUint64 converts the value to uint64 as MySQL does in BIT_* functions.
+func valueToUint64(v sqltypes.Value) uint64 {
+	if x, err := v.ParseUint64(); err == nil {
+		return x
+	}
+	if x, err := v.ParseInt64(); err == nil {
+		return uint64(x)
+	}
+	if x, err := v.ParseFloat64(); err == nil {
+		if x < 0 {
+			return uint64(int64(math.Round(x)))
+		}
+		return uint64(math.Round(x))
+	}
+	return 0
+}
+
+// encodeJSONValue encodes the value to the JSON value.
+func encodeJSONValue(v sqltypes.Value) []byte {
+	switch {
+	case v.IsNull():
+		return []byte("null")
+	case v.Type() == querypb.Type_JSON:
+		return v.Raw()
+	case v.IsIntegral(), v.IsFloat(), v.Type() == querypb.Type_DECIMAL:
+		return v.Raw()
+	}
+	return encodeJSONString(v.ToString())
+}
+
+// encodeJSONString encodes the string to the JSON string without the HTML escape.
+func encodeJSONString(s string) []byte {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	_ = enc.Encode(s)
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n"))
+}
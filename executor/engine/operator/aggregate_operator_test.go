@@ -104,7 +104,7 @@ func TestAggregateOperator(t *testing.T) {
 					sqltypes.MakeTrusted(querypb.Type_INT32, []byte("7")),
 				},
 			}
-			err = ExecSubPlan(log, plan.Root, ctx)
+			err = ExecSubPlan(log, plan.Root, txn, ctx)
 			assert.Nil(t, err)
 			want := results[i]
 			got := fmt.Sprintf("%v", ctx.Results.Rows)
@@ -182,7 +182,7 @@ func TestAggregateAvgOperator(t *testing.T) {
 					sqltypes.MakeTrusted(querypb.Type_INT32, []byte("3")),
 				},
 			}
-			err = ExecSubPlan(log, plan.Root, ctx)
+			err = ExecSubPlan(log, plan.Root, txn, ctx)
 			assert.Nil(t, err)
 			want := results[i]
 			got := fmt.Sprintf("%v", ctx.Results.Rows)
@@ -292,7 +292,7 @@ func TestAggregateNotPush(t *testing.T) {
 		{
 			ctx := xcontext.NewResultContext()
 			ctx.Results = rss[i]
-			err = ExecSubPlan(log, plan.Root, ctx)
+			err = ExecSubPlan(log, plan.Root, txn, ctx)
 			assert.Nil(t, err)
 			want := wantResults[i]
 			got := fmt.Sprintf("%v", ctx.Results.Rows)
@@ -371,7 +371,141 @@ func TestAggregateGroup(t *testing.T) {
 			ctx := xcontext.NewResultContext()
 			ctx.Results = &sqltypes.Result{}
 			ctx.Results = r1
-			err = ExecSubPlan(log, plan.Root, ctx)
+			err = ExecSubPlan(log, plan.Root, txn, ctx)
+			assert.Nil(t, err)
+			want := results[i]
+			got := fmt.Sprintf("%v", ctx.Results.Rows)
+			assert.Equal(t, want, got)
+			log.Debug("%+v", ctx.Results)
+		}
+	}
+}
+
+func TestAggregateExtOperator(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	database := "sbtest"
+
+	route, cleanup := router.MockNewRouter(log)
+	defer cleanup()
+
+	err := route.CreateDatabase(database)
+	assert.Nil(t, err)
+	err = route.AddForTest(database, router.MockTableAConfig())
+	assert.Nil(t, err)
+
+	// Create scatter and query handler.
+	scatter, _, cleanup := backend.MockScatter(log, 10)
+	defer cleanup()
+	query := "select bit_and(score), bit_or(score), bit_xor(score), std(score), var_samp(score), json_arrayagg(score), json_objectagg(id, score) from A where id>8"
+	want := `[[1 7 7 1.632993161855452 4 [1, 3, 5] {"1": 1, "2": 3, "10": 5}]]`
+
+	node, err := sqlparser.Parse(query)
+	assert.Nil(t, err)
+
+	plan := planner.NewSelectPlan(log, database, query, node.(*sqlparser.Select), route)
+	err = plan.Build()
+	assert.Nil(t, err)
+	log.Debug("plan:%+v", plan.JSON())
+
+	txn, err := scatter.CreateTransaction()
+	assert.Nil(t, err)
+	defer txn.Finish()
+	{
+		ctx := xcontext.NewResultContext()
+		ctx.Results = &sqltypes.Result{}
+		// The partial results of the backends, the std/var_samp are decomposed into sum, sum of squares and count.
+		names := []string{"bit_and", "bit_or", "bit_xor", "std", "sum1", "count1", "var_samp", "sum2", "count2", "json_arrayagg", "json_objectagg"}
+		types := []querypb.Type{
+			querypb.Type_UINT64, querypb.Type_UINT64, querypb.Type_UINT64,
+			querypb.Type_DECIMAL, querypb.Type_DECIMAL, querypb.Type_INT64,
+			querypb.Type_DECIMAL, querypb.Type_DECIMAL, querypb.Type_INT64,
+			querypb.Type_JSON, querypb.Type_JSON,
+		}
+		for i, name := range names {
+			ctx.Results.Fields = append(ctx.Results.Fields, &querypb.Field{Name: name, Type: types[i]})
+		}
+		rows := [][]string{
+			{"1", "3", "2", "4", "10", "2", "4", "10", "2", "[1, 3]", `{"1": 1, "2": 3}`},
+			{"5", "5", "5", "5", "25", "1", "5", "25", "1", "[5]", `{"10": 5}`},
+			{"18446744073709551615", "0", "0", "", "", "0", "", "", "0", "", ""},
+		}
+		for _, row := range rows {
+			var values []sqltypes.Value
+			for i, val := range row {
+				if val == "" {
+					values = append(values, sqltypes.NULL)
+					continue
+				}
+				values = append(values, sqltypes.MakeTrusted(types[i], []byte(val)))
+			}
+			ctx.Results.Rows = append(ctx.Results.Rows, values)
+		}
+		err = ExecSubPlan(log, plan.Root, txn, ctx)
+		assert.Nil(t, err)
+		got := fmt.Sprintf("%v", ctx.Results.Rows)
+		assert.Equal(t, want, got)
+		assert.Equal(t, 7, len(ctx.Results.Fields))
+		assert.Equal(t, querypb.Type_FLOAT64, ctx.Results.Fields[3].Type)
+		log.Debug("%+v", ctx.Results)
+	}
+}
+
+func TestAggregateExtNotPush(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	database := "sbtest"
+
+	route, cleanup := router.MockNewRouter(log)
+	defer cleanup()
+
+	err := route.CreateDatabase(database)
+	assert.Nil(t, err)
+	err = route.AddForTest(database, router.MockTableAConfig())
+	assert.Nil(t, err)
+
+	// Create scatter and query handler.
+	scatter, _, cleanup := backend.MockScatter(log, 10)
+	defer cleanup()
+	query := "select group_concat(distinct name order by score desc separator '-'), bit_xor(score), variance(score), json_arrayagg(name), json_objectagg(name, score) from A"
+	maxLens := []int{1024, 3}
+	results := []string{
+		`[[b-a-c 0 0.6666666666666666 ["a", "b", "a", "c"] {"a": 2, "b": 3, "c": null}]]`,
+		`[[b-a 0 0.6666666666666666 ["a", "b", "a", "c"] {"a": 2, "b": 3, "c": null}]]`,
+	}
+
+	for i, maxLen := range maxLens {
+		node, err := sqlparser.Parse(query)
+		assert.Nil(t, err)
+
+		plan := planner.NewSelectPlan(log, database, query, node.(*sqlparser.Select), route)
+		err = plan.Build()
+		assert.Nil(t, err)
+		log.Debug("plan:%+v", plan.JSON())
+
+		txn, err := scatter.CreateTransaction()
+		assert.Nil(t, err)
+		defer txn.Finish()
+		txn.SetGroupConcatMaxLen(maxLen)
+		{
+			ctx := xcontext.NewResultContext()
+			ctx.Results = &sqltypes.Result{}
+			// The backends only fetch the rows: name, score(order by), score, score, name, name, score.
+			types := []querypb.Type{
+				querypb.Type_VARCHAR, querypb.Type_INT32, querypb.Type_INT32, querypb.Type_INT32,
+				querypb.Type_VARCHAR, querypb.Type_VARCHAR, querypb.Type_INT32,
+			}
+			for _, typ := range types {
+				ctx.Results.Fields = append(ctx.Results.Fields, &querypb.Field{Type: typ})
+			}
+			rows := [][]string{{"a", "1"}, {"b", "3"}, {"a", "2"}, {"c", ""}}
+			for _, row := range rows {
+				name := sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(row[0]))
+				score := sqltypes.NULL
+				if row[1] != "" {
+					score = sqltypes.MakeTrusted(querypb.Type_INT32, []byte(row[1]))
+				}
+				ctx.Results.Rows = append(ctx.Results.Rows, []sqltypes.Value{name, score, score, score, name, name, score})
+			}
+			err = ExecSubPlan(log, plan.Root, txn, ctx)
 			assert.Nil(t, err)
 			want := results[i]
 			got := fmt.Sprintf("%v", ctx.Results.Rows)
@@ -0,0 +1,73 @@
+/*
+ * NeoDB
+ *
+ * Copyright 2021-2030 The NeoDB Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package operator
+
+import (
+	"strconv"
+
+	"github.com/sealdb/neodb/planner/builder"
+	"github.com/sealdb/neodb/xcontext"
+
+	"github.com/sealdb/mysqlstack/sqlparser/depends/sqltypes"
+	"github.com/sealdb/mysqlstack/xlog"
+)
+
+var (
+	_ Operator = &DistinctOperator{}
+)
+
+// DistinctOperator represents distinct operator.
+// The first row of the duplicates is kept, so the order of the rows is unchanged.
+type DistinctOperator struct {
+	log  *xlog.Log
+	plan builder.ChildPlan
+}
+
+// NewDistinctOperator creates the new distinct operator.
+func NewDistinctOperator(log *xlog.Log, plan builder.ChildPlan) *DistinctOperator {
+	return &DistinctOperator{
+		log:  log,
+		plan: plan,
+	}
+}
+
+// Execute used to execute the operator.
+func (operator *DistinctOperator) Execute(ctx *xcontext.ResultContext) error {
+	rs := ctx.Results
+	seen := make(map[string]struct{}, len(rs.Rows))
+	rows := rs.Rows[:0]
+	for _, row := range rs.Rows {
+		key := rowKey(row)
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		seen[key] = struct{}{}
+		rows = append(rows, row)
+	}
+	rs.Rows = rows
+	rs.RowsAffected = uint64(len(rows))
+	return nil
+}
+
+// rowKey encodes the row to a key, each value is prefixed with its length
+// to avoid the ambiguity, and NULL is distinguished from the empty string.
+func rowKey(row []sqltypes.Value) string {
+	var key []byte
+	for _, v := range row {
+		if v.IsNull() {
+			key = append(key, 'N')
+			continue
+		}
+		raw := v.Raw()
+		key = strconv.AppendInt(key, int64(len(raw)), 10)
+		key = append(key, ':')
+		key = append(key, raw...)
+	}
+	return string(key)
+}
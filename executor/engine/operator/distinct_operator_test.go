@@ -0,0 +1,118 @@
+/*
+ * NeoDB
+ *
+ * Copyright 2021-2030 The NeoDB Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package operator
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/sealdb/neodb/backend"
+	"github.com/sealdb/neodb/planner"
+	"github.com/sealdb/neodb/router"
+	"github.com/sealdb/neodb/xcontext"
+
+	"github.com/sealdb/mysqlstack/sqlparser"
+	querypb "github.com/sealdb/mysqlstack/sqlparser/depends/query"
+	"github.com/sealdb/mysqlstack/sqlparser/depends/sqltypes"
+	"github.com/sealdb/mysqlstack/xlog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDistinctOperator(t *testing.T) {
+	newResult := func() *sqltypes.Result {
+		return &sqltypes.Result{
+			Fields: []*querypb.Field{
+				{
+					Name: "name",
+					Type: querypb.Type_VARCHAR,
+				},
+				{
+					Name: "b",
+					Type: querypb.Type_VARCHAR,
+				},
+			},
+			Rows: [][]sqltypes.Value{
+				{
+					sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("ab")),
+					sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("c")),
+				},
+				{
+					sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("a")),
+					sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("bc")),
+				},
+				{
+					sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("ab")),
+					sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("c")),
+				},
+				{
+					sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("x")),
+					sqltypes.NULL,
+				},
+				{
+					sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("x")),
+					sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("")),
+				},
+				{
+					sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("x")),
+					sqltypes.NULL,
+				},
+			},
+		}
+	}
+
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	database := "sbtest"
+
+	route, cleanup := router.MockNewRouter(log)
+	defer cleanup()
+
+	err := route.CreateDatabase(database)
+	assert.Nil(t, err)
+	err = route.AddForTest(database, router.MockTableAConfig())
+	assert.Nil(t, err)
+
+	// Create scatter and query handler.
+	scatter, _, cleanup := backend.MockScatter(log, 10)
+	defer cleanup()
+
+	querys := []string{
+		"select distinct name, b from A",
+		"select distinct name, b from A order by name desc",
+		"select distinct name, b from A order by name limit 1, 2",
+	}
+	// The NULL and the empty string are different.
+	results := []string{
+		"[[ab c] [a bc] [x ] [x ]]",
+		"[[x ] [x ] [ab c] [a bc]]",
+		"[[ab c] [x ]]",
+	}
+
+	for i, query := range querys {
+		node, err := sqlparser.Parse(query)
+		assert.Nil(t, err)
+
+		plan := planner.NewSelectPlan(log, database, query, node.(*sqlparser.Select), route)
+		err = plan.Build()
+		assert.Nil(t, err)
+		log.Debug("plan:%+v", plan.JSON())
+
+		txn, err := scatter.CreateTransaction()
+		assert.Nil(t, err)
+		defer txn.Finish()
+		{
+			ctx := xcontext.NewResultContext()
+			ctx.Results = newResult()
+			err = ExecSubPlan(log, plan.Root, txn, ctx)
+			assert.Nil(t, err)
+			want := results[i]
+			got := fmt.Sprintf("%v", ctx.Results.Rows)
+			assert.Equal(t, want, got, query)
+		}
+	}
+}
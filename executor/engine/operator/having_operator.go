@@ -0,0 +1,64 @@
+/*
+ * NeoDB
+ *
+ * Copyright 2021-2030 The NeoDB Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package operator
+
+import (
+	"github.com/sealdb/neodb/expression"
+	"github.com/sealdb/neodb/planner/builder"
+	"github.com/sealdb/neodb/xcontext"
+
+	"github.com/sealdb/mysqlstack/xlog"
+)
+
+var (
+	_ Operator = &HavingOperator{}
+)
+
+// HavingOperator represents having operator.
+// The rows are kept only if all the havings are true.
+type HavingOperator struct {
+	log  *xlog.Log
+	plan builder.ChildPlan
+}
+
+// NewHavingOperator creates the new having operator.
+func NewHavingOperator(log *xlog.Log, plan builder.ChildPlan) *HavingOperator {
+	return &HavingOperator{
+		log:  log,
+		plan: plan,
+	}
+}
+
+// Execute used to execute the operator.
+func (operator *HavingOperator) Execute(ctx *xcontext.ResultContext) error {
+	rs := ctx.Results
+	plan := operator.plan.(*builder.HavingPlan)
+
+	rows := rs.Rows[:0]
+	for _, row := range rs.Rows {
+		keep := true
+		for _, filter := range plan.Filters() {
+			ok, err := expression.IsTrue(filter, row)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				keep = false
+				break
+			}
+		}
+		if keep {
+			rows = append(rows, row)
+		}
+	}
+	rs.Rows = rows
+	rs.RowsAffected = uint64(len(rows))
+	rs.RemoveColumns(plan.RemovedIdxs...)
+	return nil
+}
@@ -0,0 +1,123 @@
+/*
+ * NeoDB
+ *
+ * Copyright 2021-2030 The NeoDB Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package operator
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/sealdb/neodb/backend"
+	"github.com/sealdb/neodb/planner"
+	"github.com/sealdb/neodb/router"
+	"github.com/sealdb/neodb/xcontext"
+
+	"github.com/sealdb/mysqlstack/sqlparser"
+	querypb "github.com/sealdb/mysqlstack/sqlparser/depends/query"
+	"github.com/sealdb/mysqlstack/sqlparser/depends/sqltypes"
+	"github.com/sealdb/mysqlstack/xlog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHavingOperator(t *testing.T) {
+	newResult := func() *sqltypes.Result {
+		return &sqltypes.Result{
+			Fields: []*querypb.Field{
+				{
+					Name: "a",
+					Type: querypb.Type_INT32,
+				},
+				{
+					Name: "sum(b)",
+					Type: querypb.Type_DECIMAL,
+				},
+				{
+					Name: "count(b)",
+					Type: querypb.Type_INT64,
+				},
+			},
+			Rows: [][]sqltypes.Value{
+				{
+					sqltypes.MakeTrusted(querypb.Type_INT32, []byte("1")),
+					sqltypes.MakeTrusted(querypb.Type_DECIMAL, []byte("3")),
+					sqltypes.MakeTrusted(querypb.Type_INT64, []byte("2")),
+				},
+				{
+					sqltypes.MakeTrusted(querypb.Type_INT32, []byte("1")),
+					sqltypes.MakeTrusted(querypb.Type_DECIMAL, []byte("4")),
+					sqltypes.MakeTrusted(querypb.Type_INT64, []byte("1")),
+				},
+				{
+					sqltypes.MakeTrusted(querypb.Type_INT32, []byte("3")),
+					sqltypes.NULL,
+					sqltypes.MakeTrusted(querypb.Type_INT64, []byte("0")),
+				},
+			},
+		}
+	}
+
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	database := "sbtest"
+
+	route, cleanup := router.MockNewRouter(log)
+	defer cleanup()
+
+	err := route.CreateDatabase(database)
+	assert.Nil(t, err)
+	err = route.AddForTest(database, router.MockTableAConfig())
+	assert.Nil(t, err)
+
+	// Create scatter and query handler.
+	scatter, _, cleanup := backend.MockScatter(log, 10)
+	defer cleanup()
+
+	querys := []string{
+		"select a, sum(b), count(b) from A group by a having count(b) > 2",
+		"select a from A group by a having sum(b) > 1 and count(b) > 0",
+		"select a, sum(b)/count(b) as x from A group by a having x is null",
+		"select a from A group by a having sum(b) is null or count(b) > 0 order by count(b) desc",
+	}
+	results := []string{
+		"[[1 7 3]]",
+		"[[1]]",
+		"[[3 ]]",
+		"[[1] [3]]",
+	}
+	fields := []string{
+		"[a sum(b) count(b)]",
+		"[a]",
+		"[a x]",
+		"[a]",
+	}
+
+	for i, query := range querys {
+		node, err := sqlparser.Parse(query)
+		assert.Nil(t, err)
+
+		plan := planner.NewSelectPlan(log, database, query, node.(*sqlparser.Select), route)
+		err = plan.Build()
+		assert.Nil(t, err)
+		log.Debug("plan:%+v", plan.JSON())
+
+		txn, err := scatter.CreateTransaction()
+		assert.Nil(t, err)
+		defer txn.Finish()
+		{
+			ctx := xcontext.NewResultContext()
+			ctx.Results = newResult()
+			err = ExecSubPlan(log, plan.Root, txn, ctx)
+			assert.Nil(t, err)
+			assert.Equal(t, results[i], fmt.Sprintf("%v", ctx.Results.Rows), query)
+			var names []string
+			for _, field := range ctx.Results.Fields {
+				names = append(names, field.Name)
+			}
+			assert.Equal(t, fields[i], fmt.Sprintf("%v", names), query)
+		}
+	}
+}
@@ -99,7 +99,7 @@ func TestLimitOperator(t *testing.T) {
 			ctx := xcontext.NewResultContext()
 			ctx.Results = &sqltypes.Result{}
 			ctx.Results = r1
-			err = ExecSubPlan(log, plan.Root, ctx)
+			err = ExecSubPlan(log, plan.Root, txn, ctx)
 			assert.Nil(t, err)
 			want := results[i]
 			got := fmt.Sprintf("%v", ctx.Results.Rows)
@@ -10,6 +10,7 @@
 package operator
 
 import (
+	"github.com/sealdb/neodb/backend"
 	"github.com/sealdb/neodb/planner/builder"
 	"github.com/sealdb/neodb/xcontext"
 
@@ -22,13 +23,17 @@ type Operator interface {
 }
 
 // ExecSubPlan used to execute all the children plan.
-func ExecSubPlan(log *xlog.Log, node builder.PlanNode, ctx *xcontext.ResultContext) error {
-	subPlanTree := node.Children()
+func ExecSubPlan(log *xlog.Log, node builder.PlanNode, txn backend.Transaction, ctx *xcontext.ResultContext) error {
+	return execChildren(log, node.Children(), txn, ctx)
+}
+
+// execChildren used to execute the children plan in order.
+func execChildren(log *xlog.Log, subPlanTree []builder.ChildPlan, txn backend.Transaction, ctx *xcontext.ResultContext) error {
 	if subPlanTree != nil {
 		for _, subPlan := range subPlanTree {
 			switch subPlan.Type() {
 			case builder.ChildTypeAggregate:
-				aggrOperator := NewAggregateOperator(log, subPlan)
+				aggrOperator := NewAggregateOperator(log, subPlan, txn.GroupConcatMaxLen())
 				if err := aggrOperator.Execute(ctx); err != nil {
 					return err
 				}
@@ -37,6 +42,26 @@ func ExecSubPlan(log *xlog.Log, node builder.PlanNode, ctx *xcontext.ResultConte
 				if err := orderByOperator.Execute(ctx); err != nil {
 					return err
 				}
+			case builder.ChildTypeDistinct:
+				distinctOperator := NewDistinctOperator(log, subPlan)
+				if err := distinctOperator.Execute(ctx); err != nil {
+					return err
+				}
+			case builder.ChildTypeProject:
+				projectOperator := NewProjectOperator(log, subPlan)
+				if err := projectOperator.Execute(ctx); err != nil {
+					return err
+				}
+			case builder.ChildTypeHaving:
+				havingOperator := NewHavingOperator(log, subPlan)
+				if err := havingOperator.Execute(ctx); err != nil {
+					return err
+				}
+			case builder.ChildTypeWindow:
+				windowOperator := NewWindowOperator(log, subPlan)
+				if err := windowOperator.Execute(ctx); err != nil {
+					return err
+				}
 			case builder.ChildTypeLimit:
 				limitOperator := NewLimitOperator(log, subPlan)
 				if err := limitOperator.Execute(ctx); err != nil {
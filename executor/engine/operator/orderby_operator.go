@@ -44,6 +44,13 @@ func (operator *OrderByOperator) Execute(ctx *xcontext.ResultContext) error {
 	rs := ctx.Results
 	plan := operator.plan.(*builder.OrderByPlan)
 
+	// The rows merged from the sorted rows of the backends are already in order.
+	if ctx.Sorted {
+		ctx.Sorted = false
+		rs.RemoveColumns(plan.RemovedIdxs...)
+		return nil
+	}
+
 	sort.Slice(rs.Rows, func(i, j int) bool {
 		// If there are any errors below, the function sets
 		// the external err and returns true. Once err is set,
@@ -83,10 +83,12 @@ func TestOrderByOperator(t *testing.T) {
 
 	querys := []string{
 		"select id, name from A where id>8 order by id desc, name asc",
+		"select id, name from A where id>8 order by 2, 1 desc",
 		"select id from A where id>8 order by id desc, name asc",
 	}
 	results := []string{
 		"[[51 lang] [5 g] [5 g] [3 go] [3 z] [1 x]]",
+		"[[5 g] [5 g] [3 go] [51 lang] [1 x] [3 z]]",
 		"[[51] [5] [5] [3] [3] [1]]",
 	}
 
@@ -106,7 +108,7 @@ func TestOrderByOperator(t *testing.T) {
 			ctx := xcontext.NewResultContext()
 			ctx.Results = &sqltypes.Result{}
 			ctx.Results = r1
-			err = ExecSubPlan(log, plan.Root, ctx)
+			err = ExecSubPlan(log, plan.Root, txn, ctx)
 			assert.Nil(t, err)
 			want := results[i]
 			got := fmt.Sprintf("%v", ctx.Results.Rows)
@@ -179,7 +181,7 @@ func TestOrderByError(t *testing.T) {
 			ctx := xcontext.NewResultContext()
 			ctx.Results = &sqltypes.Result{}
 			ctx.Results = r1
-			err = ExecSubPlan(log, plan.Root, ctx)
+			err = ExecSubPlan(log, plan.Root, txn, ctx)
 			assert.NotNil(t, err)
 			got := err.Error()
 			assert.Equal(t, wants[i], got)
@@ -0,0 +1,121 @@
+/*
+ * NeoDB
+ *
+ * Copyright 2021-2030 The NeoDB Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package operator
+
+import (
+	"github.com/sealdb/neodb/planner/builder"
+	"github.com/sealdb/neodb/xcontext"
+
+	querypb "github.com/sealdb/mysqlstack/sqlparser/depends/query"
+	"github.com/sealdb/mysqlstack/sqlparser/depends/sqltypes"
+	"github.com/sealdb/mysqlstack/xlog"
+)
+
+var (
+	_ Operator = &ProjectOperator{}
+)
+
+// ProjectOperator represents project operator.
+// The input columns are replaced by the projected columns, the columns after
+// the input(such as the order by columns) are kept.
+type ProjectOperator struct {
+	log  *xlog.Log
+	plan builder.ChildPlan
+}
+
+// NewProjectOperator creates the new project operator.
+func NewProjectOperator(log *xlog.Log, plan builder.ChildPlan) *ProjectOperator {
+	return &ProjectOperator{
+		log:  log,
+		plan: plan,
+	}
+}
+
+// Execute used to execute the operator.
+func (operator *ProjectOperator) Execute(ctx *xcontext.ResultContext) error {
+	rs := ctx.Results
+	plan := operator.plan.(*builder.ProjectPlan)
+	inputLen := plan.InputLen()
+
+	for i, row := range rs.Rows {
+		projected := make([]sqltypes.Value, 0, len(plan.Projects)+len(row)-inputLen)
+		for _, project := range plan.Projects {
+			if project.Index >= 0 {
+				projected = append(projected, row[project.Index])
+				continue
+			}
+			v, err := project.Expr.Eval(row)
+			if err != nil {
+				return err
+			}
+			projected = append(projected, v)
+		}
+		rs.Rows[i] = append(projected, row[inputLen:]...)
+	}
+
+	if len(rs.Fields) >= inputLen {
+		fields := make([]*querypb.Field, 0, len(plan.Projects)+len(rs.Fields)-inputLen)
+		for i, project := range plan.Projects {
+			if project.Index >= 0 {
+				fields = append(fields, rs.Fields[project.Index])
+				continue
+			}
+			fields = append(fields, computedField(project.Field, rs.Rows, i))
+		}
+		rs.Fields = append(fields, rs.Fields[inputLen:]...)
+	}
+	return nil
+}
+
+// computedField builds the field of the computed column by the values,
+// the type is decided by the first non-null value.
+func computedField(name string, rows [][]sqltypes.Value, idx int) *querypb.Field {
+	field := &querypb.Field{
+		Name:    name,
+		Type:    sqltypes.Null,
+		Charset: 63,
+	}
+	var length uint32
+	for _, row := range rows {
+		v := row[idx]
+		if v.IsNull() {
+			continue
+		}
+		if field.Type == sqltypes.Null {
+			field.Type = v.Type()
+		}
+		if l := uint32(v.Len()); l > length {
+			length = l
+		}
+	}
+	field.ColumnLength = length
+
+	switch {
+	case sqltypes.IsText(field.Type):
+		// utf8_general_ci.
+		field.Charset = 33
+	case field.Type == sqltypes.Float64 || field.Type == sqltypes.Float32:
+		// The decimals of the float is not fixed.
+		field.Decimals = 31
+	case field.Type == sqltypes.Decimal:
+		for _, row := range rows {
+			if v := row[idx]; !v.IsNull() {
+				raw := v.ToString()
+				for i := 0; i < len(raw); i++ {
+					if raw[i] == '.' {
+						field.Decimals = uint32(len(raw) - i - 1)
+						break
+					}
+				}
+				break
+			}
+		}
+	}
+	return field
+}
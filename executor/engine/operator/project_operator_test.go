@@ -0,0 +1,154 @@
+/*
+ * NeoDB
+ *
+ * Copyright 2021-2030 The NeoDB Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package operator
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/sealdb/neodb/backend"
+	"github.com/sealdb/neodb/planner"
+	"github.com/sealdb/neodb/router"
+	"github.com/sealdb/neodb/xcontext"
+
+	"github.com/sealdb/mysqlstack/sqlparser"
+	querypb "github.com/sealdb/mysqlstack/sqlparser/depends/query"
+	"github.com/sealdb/mysqlstack/sqlparser/depends/sqltypes"
+	"github.com/sealdb/mysqlstack/xlog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProjectOperator(t *testing.T) {
+	newResult := func() *sqltypes.Result {
+		return &sqltypes.Result{
+			Fields: []*querypb.Field{
+				{
+					Name: "a",
+					Type: querypb.Type_INT32,
+				},
+				{
+					Name: "sum(b)",
+					Type: querypb.Type_DECIMAL,
+				},
+				{
+					Name: "count(b)",
+					Type: querypb.Type_INT64,
+				},
+			},
+			Rows: [][]sqltypes.Value{
+				{
+					sqltypes.MakeTrusted(querypb.Type_INT32, []byte("1")),
+					sqltypes.MakeTrusted(querypb.Type_DECIMAL, []byte("3")),
+					sqltypes.MakeTrusted(querypb.Type_INT64, []byte("2")),
+				},
+				{
+					sqltypes.MakeTrusted(querypb.Type_INT32, []byte("1")),
+					sqltypes.MakeTrusted(querypb.Type_DECIMAL, []byte("4")),
+					sqltypes.MakeTrusted(querypb.Type_INT64, []byte("1")),
+				},
+				{
+					sqltypes.MakeTrusted(querypb.Type_INT32, []byte("3")),
+					sqltypes.NULL,
+					sqltypes.MakeTrusted(querypb.Type_INT64, []byte("0")),
+				},
+			},
+		}
+	}
+
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	database := "sbtest"
+
+	route, cleanup := router.MockNewRouter(log)
+	defer cleanup()
+
+	err := route.CreateDatabase(database)
+	assert.Nil(t, err)
+	err = route.AddForTest(database, router.MockTableAConfig())
+	assert.Nil(t, err)
+
+	// Create scatter and query handler.
+	scatter, _, cleanup := backend.MockScatter(log, 10)
+	defer cleanup()
+
+	querys := []string{
+		"select a, sum(b)/count(b) as x from A group by a",
+		"select a, ifnull(sum(b), 0)+count(b) from A group by a order by a desc",
+		"select a, sum(b)/count(b) as x from A group by a order by x desc limit 1",
+	}
+	results := []string{
+		"[[1 2.3333] [3 ]]",
+		"[[3 0] [1 10]]",
+		"[[1 2.3333]]",
+	}
+	fields := []string{
+		"[a x]",
+		"[a ifnull(sum(b), 0) + count(b)]",
+		"[a x]",
+	}
+
+	for i, query := range querys {
+		node, err := sqlparser.Parse(query)
+		assert.Nil(t, err)
+
+		plan := planner.NewSelectPlan(log, database, query, node.(*sqlparser.Select), route)
+		err = plan.Build()
+		assert.Nil(t, err)
+		log.Debug("plan:%+v", plan.JSON())
+
+		txn, err := scatter.CreateTransaction()
+		assert.Nil(t, err)
+		defer txn.Finish()
+		{
+			ctx := xcontext.NewResultContext()
+			ctx.Results = newResult()
+			err = ExecSubPlan(log, plan.Root, txn, ctx)
+			assert.Nil(t, err)
+			assert.Equal(t, results[i], fmt.Sprintf("%v", ctx.Results.Rows), query)
+			var names []string
+			for _, field := range ctx.Results.Fields {
+				names = append(names, field.Name)
+			}
+			assert.Equal(t, fields[i], fmt.Sprintf("%v", names), query)
+		}
+	}
+}
+
+func TestProjectOperatorError(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	database := "sbtest"
+
+	route, cleanup := router.MockNewRouter(log)
+	defer cleanup()
+
+	err := route.CreateDatabase(database)
+	assert.Nil(t, err)
+	err = route.AddForTest(database, router.MockTableAConfig())
+	assert.Nil(t, err)
+
+	scatter, _, cleanup := backend.MockScatter(log, 10)
+	defer cleanup()
+
+	query := "select max(a)+9223372036854775807 from A"
+	node, err := sqlparser.Parse(query)
+	assert.Nil(t, err)
+	plan := planner.NewSelectPlan(log, database, query, node.(*sqlparser.Select), route)
+	assert.Nil(t, plan.Build())
+
+	txn, err := scatter.CreateTransaction()
+	assert.Nil(t, err)
+	defer txn.Finish()
+
+	ctx := xcontext.NewResultContext()
+	ctx.Results = &sqltypes.Result{
+		Fields: []*querypb.Field{{Name: "max(a)", Type: querypb.Type_INT64}},
+		Rows:   [][]sqltypes.Value{{sqltypes.MakeTrusted(querypb.Type_INT64, []byte("1"))}},
+	}
+	err = ExecSubPlan(log, plan.Root, txn, ctx)
+	assert.Equal(t, "BIGINT value is out of range in 'max(a) + 9223372036854775807'", err.Error())
+}
@@ -0,0 +1,282 @@
+/*
+ * NeoDB
+ *
+ * Copyright 2021-2030 The NeoDB Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package operator
+
+import (
+	"io"
+	"sort"
+
+	"github.com/sealdb/neodb/backend"
+	"github.com/sealdb/neodb/planner/builder"
+	"github.com/sealdb/neodb/xbase/spill"
+	"github.com/sealdb/neodb/xcontext"
+
+	"github.com/pkg/errors"
+	querypb "github.com/sealdb/mysqlstack/sqlparser/depends/query"
+	"github.com/sealdb/mysqlstack/sqlparser/depends/sqltypes"
+	"github.com/sealdb/mysqlstack/xlog"
+)
+
+const (
+	// aggrPartitions is the count of the partitions of the spilled groups.
+	aggrPartitions = 16
+	// aggrMaxDepth is the max depth of re-partitioning the spilled groups.
+	aggrMaxDepth = 3
+)
+
+// ExecSubPlanRows used to execute all the children plan on the rows spilled to disk. The leading
+// aggregate or order by is executed by the spillable hash aggregation or the external merge sort
+// under the memory limit of the txn, the others are executed in memory. The rows are closed after.
+func ExecSubPlanRows(log *xlog.Log, node builder.PlanNode, txn backend.Transaction, ctx *xcontext.ResultContext, rows *spill.Rows) error {
+	defer rows.Close()
+
+	children := node.Children()
+	tracker := txn.MemTracker()
+	if len(children) > 0 {
+		switch children[0].Type() {
+		case builder.ChildTypeAggregate:
+			if !children[0].(*builder.AggregatePlan).Empty() {
+				aggrOperator := NewAggregateOperator(log, children[0], txn.GroupConcatMaxLen())
+				if err := aggrOperator.hashAggregate(ctx.Results, rows, tracker); err != nil {
+					return err
+				}
+				return execChildren(log, children[1:], txn, ctx)
+			}
+		case builder.ChildTypeOrderby:
+			// Only the first offset+limit rows are needed.
+			limit := -1
+			if limitPlan := builder.LimitAfterOrderBy(children); limitPlan != nil {
+				limit = limitPlan.Offset + limitPlan.Limit
+			}
+			orderByOperator := NewOrderByOperator(log, children[0])
+			if err := orderByOperator.externalSort(ctx.Results, rows, tracker, limit); err != nil {
+				return err
+			}
+			return execChildren(log, children[1:], txn, ctx)
+		}
+	}
+
+	all, err := rows.All()
+	if err != nil {
+		return err
+	}
+	ctx.Results.Rows = all
+	return execChildren(log, children, txn, ctx)
+}
+
+// hashAggregate used to aggregate the spilled rows by the hash table of the groups. Once the memory
+// exceeds the limit, the rows of the new groups are spilled to the partitions by the group by values,
+// which are aggregated one by one after. The results are sorted by the group by values as aggregate.
+func (operator *AggregateOperator) hashAggregate(result *sqltypes.Result, rows *spill.Rows, tracker *spill.Tracker) error {
+	plan := operator.plan.(*builder.AggregatePlan)
+	aggr := newAggregator(plan, result.Fields, operator.groupConcatMaxLen)
+	groupAggrs := plan.GroupAggregators()
+
+	it, err := rows.Iterator()
+	if err != nil {
+		return err
+	}
+	defer it.Close()
+
+	var res [][]sqltypes.Value
+	emit := func(g *group) error {
+		row, err := aggr.result(g)
+		if err != nil {
+			return err
+		}
+		res = append(res, row)
+		return nil
+	}
+	if err := hashGroups(aggr, it, groupAggrs, tracker, 0, emit); err != nil {
+		return err
+	}
+
+	sort.SliceStable(res, func(i, j int) bool {
+		for _, key := range groupAggrs {
+			cmp := sqltypes.NullsafeCompare(res[i][key.Index], res[j][key.Index])
+			if cmp == 0 {
+				continue
+			}
+			return cmp < 0
+		}
+		return false
+	})
+	return aggr.finish(result, res)
+}
+
+// hashGroups used to aggregate the rows of the iterator into the groups, the groups are emitted
+// after all the rows consumed. The rows spilled to the partitions are aggregated recursively.
+func hashGroups(aggr *aggregator, it spill.Iterator, keys []builder.Aggregator, tracker *spill.Tracker, depth int, emit func(*group) error) error {
+	var size int64
+	var groups []*group
+	var parts []*spill.File
+	table := make(map[string]*group)
+	defer func() {
+		tracker.Release(size)
+		for _, part := range parts {
+			if part != nil {
+				part.Close()
+			}
+		}
+	}()
+
+	for {
+		row, err := it.Next()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return err
+		}
+
+		var k []byte
+		for _, key := range keys {
+			k = spill.AppendKey(k, row[key.Index])
+		}
+		key := string(k)
+		if g, ok := table[key]; ok {
+			if err := aggr.update(g, row); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if parts == nil && depth < aggrMaxDepth && tracker.Exceeded() {
+			parts = make([]*spill.File, aggrPartitions)
+		}
+		if parts != nil {
+			idx := spill.Partition(key, depth, aggrPartitions)
+			if parts[idx] == nil {
+				if parts[idx], err = spill.NewFile(tracker); err != nil {
+					return err
+				}
+			}
+			if err := parts[idx].Write(row); err != nil {
+				return err
+			}
+			continue
+		}
+
+		g, err := aggr.newGroup(row)
+		if err != nil {
+			return err
+		}
+		table[key] = g
+		groups = append(groups, g)
+		n := spill.RowSize(row)
+		size += n
+		tracker.Consume(n)
+	}
+
+	for _, g := range groups {
+		if err := emit(g); err != nil {
+			return err
+		}
+	}
+	tracker.Release(size)
+	size, table, groups = 0, nil, nil
+
+	for _, part := range parts {
+		if part == nil {
+			continue
+		}
+		r, err := part.Reader()
+		if err != nil {
+			return err
+		}
+		err = hashGroups(aggr, r, keys, tracker, depth+1, emit)
+		r.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// externalSort used to sort the spilled rows by the external merge sort,
+// only the first `limit` rows are kept if the limit is not negative.
+func (operator *OrderByOperator) externalSort(result *sqltypes.Result, rows *spill.Rows, tracker *spill.Tracker, limit int) error {
+	plan := operator.plan.(*builder.OrderByPlan)
+	idxs, err := orderByIndexes(result.Fields, plan.OrderBys)
+	if err != nil {
+		return err
+	}
+
+	sorter := spill.NewSorter(tracker, func(a, b []sqltypes.Value) bool {
+		for i, orderby := range plan.OrderBys {
+			cmp := sqltypes.NullsafeCompare(a[idxs[i]], b[idxs[i]])
+			if cmp == 0 {
+				continue
+			}
+			if orderby.Direction == builder.DESC {
+				cmp = -cmp
+			}
+			return cmp < 0
+		}
+		return false
+	})
+	defer sorter.Close()
+
+	it, err := rows.Iterator()
+	if err != nil {
+		return err
+	}
+	defer it.Close()
+	for {
+		row, err := it.Next()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return err
+		}
+		if err := sorter.Add(row); err != nil {
+			return err
+		}
+	}
+
+	sorted, err := sorter.Iterator()
+	if err != nil {
+		return err
+	}
+	defer sorted.Close()
+
+	result.Rows = nil
+	for limit < 0 || len(result.Rows) < limit {
+		row, err := sorted.Next()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return err
+		}
+		result.Rows = append(result.Rows, row)
+	}
+	result.RemoveColumns(plan.RemovedIdxs...)
+	return nil
+}
+
+// orderByIndexes returns the field indexes of the order by.
+func orderByIndexes(fields []*querypb.Field, orderbys []builder.OrderBy) ([]int, error) {
+	idxs := make([]int, len(orderbys))
+	for i, orderby := range orderbys {
+		idx := -1
+		for k, f := range fields {
+			if f.Name == orderby.Field && (orderby.Table == "" || orderby.Table == f.Table) {
+				idx = k
+				break
+			}
+		}
+		if idx == -1 {
+			return nil, errors.Errorf("can.not.find.the.orderby.field[%s].direction.asc", orderby.Field)
+		}
+		idxs[i] = idx
+	}
+	return idxs, nil
+}
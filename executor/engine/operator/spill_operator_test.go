@@ -0,0 +1,135 @@
+/*
+ * NeoDB
+ *
+ * Copyright 2021-2030 The NeoDB Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package operator
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/sealdb/neodb/backend"
+	"github.com/sealdb/neodb/planner"
+	"github.com/sealdb/neodb/router"
+	"github.com/sealdb/neodb/xbase/spill"
+	"github.com/sealdb/neodb/xcontext"
+
+	"github.com/sealdb/mysqlstack/sqlparser"
+	querypb "github.com/sealdb/mysqlstack/sqlparser/depends/query"
+	"github.com/sealdb/mysqlstack/sqlparser/depends/sqltypes"
+	"github.com/sealdb/mysqlstack/xlog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExecSubPlanRows(t *testing.T) {
+	dir, err := ioutil.TempDir("", "neodb_spill_")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	database := "sbtest"
+
+	route, cleanup := router.MockNewRouter(log)
+	defer cleanup()
+
+	err = route.CreateDatabase(database)
+	assert.Nil(t, err)
+	err = route.AddForTest(database, router.MockTableAConfig())
+	assert.Nil(t, err)
+
+	scatter, _, cleanup := backend.MockScatter(log, 10)
+	defer cleanup()
+
+	// The rows: id and score are i, name is i%37 and cnt is 1.
+	newResult := func(names ...string) *sqltypes.Result {
+		res := &sqltypes.Result{}
+		for _, name := range names {
+			res.Fields = append(res.Fields, &querypb.Field{Name: name, Type: querypb.Type_INT32})
+		}
+		for i := 0; i < 300; i++ {
+			vals := map[string]int{"id": i, "name": i % 37, "score": i, "cnt": 1}
+			row := make([]sqltypes.Value, len(names))
+			for j, name := range names {
+				row[j] = sqltypes.MakeTrusted(querypb.Type_INT32, []byte(fmt.Sprintf("%d", vals[name])))
+			}
+			res.Rows = append(res.Rows, row)
+		}
+		return res
+	}
+
+	tcases := []struct {
+		query  string
+		fields []string
+	}{
+		{
+			query:  "select name, sum(score) as score, count(*) as cnt from A group by name",
+			fields: []string{"name", "score", "cnt"},
+		},
+		{
+			query:  "select name, max(score) as score from A group by name having max(score) > 290",
+			fields: []string{"name", "score"},
+		},
+		{
+			query:  "select sum(score) as score from A",
+			fields: []string{"score"},
+		},
+		{
+			query:  "select name, score from A order by name desc, score limit 10, 20",
+			fields: []string{"name", "score"},
+		},
+		{
+			query:  "select id, score from A order by score desc",
+			fields: []string{"id", "score"},
+		},
+		{
+			query:  "select distinct name from A",
+			fields: []string{"name"},
+		},
+	}
+
+	for _, tcase := range tcases {
+		node, err := sqlparser.Parse(tcase.query)
+		assert.Nil(t, err)
+
+		plan := planner.NewSelectPlan(log, database, tcase.query, node.(*sqlparser.Select), route)
+		err = plan.Build()
+		assert.Nil(t, err)
+
+		txn, err := scatter.CreateTransaction()
+		assert.Nil(t, err)
+
+		want := xcontext.NewResultContext()
+		want.Results = newResult(tcase.fields...)
+		err = ExecSubPlan(log, plan.Root, txn, want)
+		assert.Nil(t, err)
+
+		txn.SetMaxQueryMemory(1024)
+		txn.SetSpillDir(dir)
+		tracker := txn.MemTracker()
+		rows := spill.NewRows(tracker)
+		res := newResult(tcase.fields...)
+		for _, row := range res.Rows {
+			err := rows.Append(row)
+			assert.Nil(t, err)
+		}
+		assert.True(t, rows.Spilled())
+
+		got := xcontext.NewResultContext()
+		got.Results = &sqltypes.Result{Fields: res.Fields}
+		err = ExecSubPlanRows(log, plan.Root, txn, got, rows)
+		assert.Nil(t, err, tcase.query)
+		assert.Equal(t, fmt.Sprintf("%v", want.Results.Rows), fmt.Sprintf("%v", got.Results.Rows), tcase.query)
+		assert.Equal(t, int64(0), tracker.Used(), tcase.query)
+		txn.Finish()
+	}
+
+	files, err := ioutil.ReadDir(dir)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(files))
+}
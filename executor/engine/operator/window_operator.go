@@ -0,0 +1,306 @@
+/*
+ * NeoDB
+ *
+ * Copyright 2021-2030 The NeoDB Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package operator
+
+import (
+	"sort"
+
+	"github.com/sealdb/neodb/planner/builder"
+	"github.com/sealdb/neodb/xcontext"
+	"github.com/sealdb/neodb/xparser"
+
+	querypb "github.com/sealdb/mysqlstack/sqlparser/depends/query"
+	"github.com/sealdb/mysqlstack/sqlparser/depends/sqltypes"
+	"github.com/sealdb/mysqlstack/xlog"
+)
+
+var (
+	_ Operator = &WindowOperator{}
+)
+
+// defaultFrame is the frame without the frame clause, all the rows are peers without the order by.
+var defaultFrame = &xparser.WindowFrame{
+	Unit:  xparser.FrameRange,
+	Start: xparser.FrameBound{Typ: xparser.BoundUnboundedPreceding},
+	End:   xparser.FrameBound{Typ: xparser.BoundCurrentRow},
+}
+
+// windowAggrs are the aggregate functions used as the window functions.
+var windowAggrs = map[string]sqltypes.AggrType{
+	"count": sqltypes.AggrTypeCount,
+	"sum":   sqltypes.AggrTypeSum,
+	"avg":   sqltypes.AggrTypeAvg,
+	"min":   sqltypes.AggrTypeMin,
+	"max":   sqltypes.AggrTypeMax,
+}
+
+// WindowOperator represents window operator.
+// Including: ROW_NUMBER/RANK/DENSE_RANK/PERCENT_RANK/CUME_DIST/NTILE/LAG/LEAD,
+// FIRST_VALUE/LAST_VALUE/NTH_VALUE/COUNT/SUM/AVG/MIN/MAX over the frames.
+type WindowOperator struct {
+	log  *xlog.Log
+	plan builder.ChildPlan
+}
+
+// NewWindowOperator creates the new window operator.
+func NewWindowOperator(log *xlog.Log, plan builder.ChildPlan) *WindowOperator {
+	return &WindowOperator{
+		log:  log,
+		plan: plan,
+	}
+}
+
+// Execute used to execute the operator.
+// The rows are sorted by the partition by and the order by of every window to compute,
+// the results are set to the rows in the original order.
+func (operator *WindowOperator) Execute(ctx *xcontext.ResultContext) error {
+	rs := ctx.Results
+	plan := operator.plan.(*builder.WindowPlan)
+
+	vals := make([][]sqltypes.Value, len(plan.Windows))
+	fields := make([]*querypb.Field, len(plan.Windows))
+	for i := range plan.Windows {
+		vals[i], fields[i] = computeWindow(&plan.Windows[i], rs)
+	}
+	for i, w := range plan.Windows {
+		fields[i].Name = rs.Fields[w.Index].Name
+		rs.Fields[w.Index] = fields[i]
+		for j, row := range rs.Rows {
+			row[w.Index] = vals[i][j]
+		}
+	}
+	rs.RemoveColumns(plan.RemovedIdxs...)
+	return nil
+}
+
+// computeWindow returns the results of the window function for the rows, and the field of the results.
+func computeWindow(w *builder.WindowFunction, rs *sqltypes.Result) ([]sqltypes.Value, *querypb.Field) {
+	rows := rs.Rows
+	idxs := make([]int, len(rows))
+	for i := range idxs {
+		idxs[i] = i
+	}
+	sort.SliceStable(idxs, func(i, j int) bool {
+		x, y := rows[idxs[i]], rows[idxs[j]]
+		for _, idx := range w.Partitions {
+			if cmp := sqltypes.NullsafeCompare(x[idx], y[idx]); cmp != 0 {
+				return cmp < 0
+			}
+		}
+		for _, order := range w.OrderBys {
+			if cmp := sqltypes.NullsafeCompare(x[order.Index], y[order.Index]); cmp != 0 {
+				return (cmp < 0) != order.Desc
+			}
+		}
+		return false
+	})
+
+	eval := newWindowEvaluator(w, rs.Fields)
+	vals := make([]sqltypes.Value, len(rows))
+	for start := 0; start < len(idxs); {
+		end := start + 1
+		for end < len(idxs) && partitionEqual(rows[idxs[start]], rows[idxs[end]], w.Partitions) {
+			end++
+		}
+		eval.partition(rows, idxs[start:end], vals)
+		start = end
+	}
+	return vals, eval.field
+}
+
+// partitionEqual returns true if the two rows are in the same partition.
+func partitionEqual(x, y []sqltypes.Value, idxs []int) bool {
+	for _, idx := range idxs {
+		if sqltypes.NullsafeCompare(x[idx], y[idx]) != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// windowEvaluator used to compute the window function partition by partition.
+type windowEvaluator struct {
+	w     *builder.WindowFunction
+	frame *xparser.WindowFrame
+	// field of the results.
+	field *querypb.Field
+	// aggr is not nil if the function is the aggregate.
+	aggr *sqltypes.Aggregation
+}
+
+func newWindowEvaluator(w *builder.WindowFunction, fields []*querypb.Field) *windowEvaluator {
+	e := &windowEvaluator{w: w, frame: w.Frame}
+	if e.frame == nil {
+		e.frame = defaultFrame
+	}
+
+	switch w.Name {
+	case "percent_rank", "cume_dist":
+		e.field = &querypb.Field{Type: querypb.Type_FLOAT64, ColumnLength: 23, Decimals: 31}
+	case "row_number", "rank", "dense_rank", "ntile":
+		e.field = &querypb.Field{Type: querypb.Type_INT64, ColumnLength: 21}
+	default:
+		e.field = &querypb.Field{Type: querypb.Type_INT64, ColumnLength: 21}
+		if w.Arg >= 0 {
+			field := *fields[w.Arg]
+			e.field = &field
+		}
+	}
+	if typ, ok := windowAggrs[w.Name]; ok {
+		e.aggr = sqltypes.NewAggregation(0, typ, false, false)
+		e.aggr.FixField(e.field)
+	}
+	return e
+}
+
+// arg returns the value of the first arg of the row, 1 for the count(*).
+func (e *windowEvaluator) arg(row []sqltypes.Value) sqltypes.Value {
+	if e.w.Arg < 0 {
+		return sqltypes.NewInt64(1)
+	}
+	return row[e.w.Arg]
+}
+
+// partition computes the window function on the sorted rows of the partition.
+func (e *windowEvaluator) partition(rows [][]sqltypes.Value, part []int, vals []sqltypes.Value) {
+	w := e.w
+	n := len(part)
+
+	// The peers are the rows with the same order by values, the peerStart and the peerEnd
+	// are the first and the last peer of the row. The ranks are the indexes of the peers.
+	peerStart := make([]int, n)
+	peerEnd := make([]int, n)
+	dense := make([]int, n)
+	for start, rank := 0, 1; start < n; rank++ {
+		end := start + 1
+		for end < n && orderEqual(rows[part[start]], rows[part[end]], w.OrderBys) {
+			end++
+		}
+		for k := start; k < end; k++ {
+			peerStart[k], peerEnd[k], dense[k] = start, end-1, rank
+		}
+		start = end
+	}
+
+	var ctx *sqltypes.AggEvaluateContext
+	aggrLo, aggrHi := 0, -1
+	for k, idx := range part {
+		switch w.Name {
+		case "row_number":
+			vals[idx] = sqltypes.NewInt64(int64(k + 1))
+		case "rank":
+			vals[idx] = sqltypes.NewInt64(int64(peerStart[k] + 1))
+		case "dense_rank":
+			vals[idx] = sqltypes.NewInt64(int64(dense[k]))
+		case "percent_rank":
+			rank := 0.0
+			if n > 1 {
+				rank = float64(peerStart[k]) / float64(n-1)
+			}
+			vals[idx] = sqltypes.NewFloat64(rank)
+		case "cume_dist":
+			vals[idx] = sqltypes.NewFloat64(float64(peerEnd[k]+1) / float64(n))
+		case "ntile":
+			vals[idx] = sqltypes.NewInt64(int64(ntile(k, n, w.Offset)))
+		case "lag", "lead":
+			j := k - w.Offset
+			if w.Name == "lead" {
+				j = k + w.Offset
+			}
+			vals[idx] = w.Default
+			if j >= 0 && j < n {
+				vals[idx] = rows[part[j]][w.Arg]
+			}
+		default:
+			lo, hi := e.frameBounds(k, n, peerStart, peerEnd)
+			if e.aggr == nil {
+				vals[idx] = e.value(rows, part, lo, hi)
+				continue
+			}
+			// The frame is extended on the former one if the start doesn't move,
+			// such as the running sum.
+			if ctx == nil || lo != aggrLo || hi < aggrHi {
+				ctx = e.aggr.InitEvalCtx(nil)
+				aggrLo, aggrHi = lo, lo-1
+			}
+			for ; aggrHi < hi; aggrHi++ {
+				e.aggr.Update([]sqltypes.Value{e.arg(rows[part[aggrHi+1]])}, ctx)
+			}
+			vals[idx] = e.aggr.GetResult(ctx)
+		}
+	}
+}
+
+// value returns the FIRST_VALUE/LAST_VALUE/NTH_VALUE of the frame, NULL if the frame is empty.
+func (e *windowEvaluator) value(rows [][]sqltypes.Value, part []int, lo, hi int) sqltypes.Value {
+	j := lo
+	switch e.w.Name {
+	case "last_value":
+		j = hi
+	case "nth_value":
+		j = lo + e.w.Offset - 1
+	}
+	if lo > hi || j > hi {
+		return sqltypes.NULL
+	}
+	return rows[part[j]][e.w.Arg]
+}
+
+// frameBounds returns the first and the last row of the frame of the row k,
+// the frame is empty if the first is after the last.
+func (e *windowEvaluator) frameBounds(k, n int, peerStart, peerEnd []int) (int, int) {
+	bound := func(b xparser.FrameBound, start bool) int {
+		switch b.Typ {
+		case xparser.BoundUnboundedPreceding:
+			return 0
+		case xparser.BoundUnboundedFollowing:
+			return n - 1
+		case xparser.BoundPreceding:
+			return k - b.Offset
+		case xparser.BoundFollowing:
+			return k + b.Offset
+		}
+		if e.frame.Unit == xparser.FrameRange {
+			if start {
+				return peerStart[k]
+			}
+			return peerEnd[k]
+		}
+		return k
+	}
+
+	lo, hi := bound(e.frame.Start, true), bound(e.frame.End, false)
+	if lo < 0 {
+		lo = 0
+	}
+	if hi > n-1 {
+		hi = n - 1
+	}
+	return lo, hi
+}
+
+// orderEqual returns true if the two rows are peers.
+func orderEqual(x, y []sqltypes.Value, orderBys []builder.WindowOrder) bool {
+	for _, order := range orderBys {
+		if sqltypes.NullsafeCompare(x[order.Index], y[order.Index]) != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// ntile returns the bucket of the row k in the n rows divided into the buckets,
+// the first n%buckets buckets have one more row.
+func ntile(k, n, buckets int) int {
+	size, extra := n/buckets, n%buckets
+	if k < extra*(size+1) {
+		return k/(size+1) + 1
+	}
+	return extra + (k-extra*(size+1))/size + 1
+}
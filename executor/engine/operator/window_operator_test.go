@@ -0,0 +1,106 @@
+/*
+ * NeoDB
+ *
+ * Copyright 2021-2030 The NeoDB Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package operator
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/sealdb/neodb/backend"
+	"github.com/sealdb/neodb/planner"
+	"github.com/sealdb/neodb/router"
+	"github.com/sealdb/neodb/xcontext"
+	"github.com/sealdb/neodb/xparser"
+
+	"github.com/sealdb/mysqlstack/sqlparser"
+	querypb "github.com/sealdb/mysqlstack/sqlparser/depends/query"
+	"github.com/sealdb/mysqlstack/sqlparser/depends/sqltypes"
+	"github.com/sealdb/mysqlstack/xlog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWindowOperator(t *testing.T) {
+	// The rows of (a, b, c), the window functions are fetched as NULL.
+	newResult := func(names ...string) *sqltypes.Result {
+		data := [][]int64{{1, 3, 10}, {1, 5, 20}, {2, 3, 30}, {1, 1, 40}}
+		rs := &sqltypes.Result{}
+		for _, name := range names {
+			rs.Fields = append(rs.Fields, &querypb.Field{Name: name, Type: querypb.Type_INT64})
+		}
+		for _, vals := range data {
+			row := make([]sqltypes.Value, len(names))
+			for i, name := range names {
+				switch name {
+				case "a":
+					row[i] = sqltypes.NewInt64(vals[0])
+				case "b":
+					row[i] = sqltypes.NewInt64(vals[1])
+				case "c":
+					row[i] = sqltypes.NewInt64(vals[2])
+				default:
+					row[i] = sqltypes.NULL
+				}
+			}
+			rs.Rows = append(rs.Rows, row)
+		}
+		return rs
+	}
+
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	database := "sbtest"
+
+	route, cleanup := router.MockNewRouter(log)
+	defer cleanup()
+
+	err := route.CreateDatabase(database)
+	assert.Nil(t, err)
+	err = route.AddForTest(database, router.MockTableAConfig())
+	assert.Nil(t, err)
+
+	// Create scatter and query handler.
+	scatter, _, cleanup := backend.MockScatter(log, 10)
+	defer cleanup()
+
+	querys := []string{
+		"select a, rank() over (partition by a order by b desc) as r, lag(c, 2, 0) over (order by b) as l, sum(b) over (order by b rows between 1 preceding and 1 following) as s from A",
+		"select a, row_number() over (order by b) as rn, dense_rank() over (order by b) as dr, percent_rank() over (order by b) as pr, cume_dist() over (order by b) as cd, ntile(3) over (order by b) as nt from A",
+		"select a, first_value(c) over (partition by a order by b) as f, last_value(c) over (partition by a order by b) as l, nth_value(c, 2) over (partition by a order by b rows between unbounded preceding and unbounded following) as n, count(*) over (partition by a) as cnt, max(c) over (partition by a) as m from A order by b, a",
+	}
+	fields := [][]string{
+		{"a", "r", "l", "s", "b", "c"},
+		{"a", "rn", "dr", "pr", "cd", "nt", "b"},
+		{"a", "f", "l", "n", "cnt", "m", "c", "b"},
+	}
+	results := []string{
+		"[[1 2 0 7] [1 1 10 8] [2 1 40 11] [1 3 0 4]]",
+		"[[1 2 2 0.3333333333333333 0.75 1] [1 4 3 1 1 3] [2 3 2 0.3333333333333333 0.75 2] [1 1 1 0 0.25 1]]",
+		"[[1 40 40 10 3 40] [1 40 10 10 3 40] [2 30 30  1 30] [1 40 20 10 3 40]]",
+	}
+
+	for i, query := range querys {
+		node, err := xparser.Parse(query)
+		assert.Nil(t, err)
+
+		plan := planner.NewSelectPlan(log, database, query, node.(*sqlparser.Select), route)
+		err = plan.Build()
+		assert.Nil(t, err, query)
+		log.Debug("plan:%+v", plan.JSON())
+
+		txn, err := scatter.CreateTransaction()
+		assert.Nil(t, err)
+		defer txn.Finish()
+		{
+			ctx := xcontext.NewResultContext()
+			ctx.Results = newResult(fields[i]...)
+			err = ExecSubPlan(log, plan.Root, txn, ctx)
+			assert.Nil(t, err, query)
+			assert.Equal(t, results[i], fmt.Sprintf("%v", ctx.Results.Rows), query)
+		}
+	}
+}
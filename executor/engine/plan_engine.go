@@ -41,6 +41,14 @@ func BuildEngine(log *xlog.Log, plan builder.PlanNode, txn backend.Transaction)
 		unionEngine.left = BuildEngine(log, node.Left, txn)
 		unionEngine.right = BuildEngine(log, node.Right, txn)
 		engine = unionEngine
+	case *builder.SemiJoinNode:
+		engine = NewSemiJoinEngine(log, node, txn)
+	case *builder.DerivedNode:
+		engine = NewDerivedEngine(log, node, txn)
+	case *builder.RecursiveNode:
+		recursiveEngine := NewRecursiveEngine(log, node, txn)
+		recursiveEngine.anchor = BuildEngine(log, node.Anchor, txn)
+		engine = recursiveEngine
 	}
 	return engine
 }
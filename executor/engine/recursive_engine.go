@@ -0,0 +1,123 @@
+/*
+ * NeoDB
+ *
+ * Copyright 2021-2030 The NeoDB Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package engine
+
+import (
+	"github.com/sealdb/neodb/backend"
+	"github.com/sealdb/neodb/executor/engine/operator"
+	"github.com/sealdb/neodb/planner/builder"
+	"github.com/sealdb/neodb/xcontext"
+
+	"github.com/pkg/errors"
+	"github.com/sealdb/mysqlstack/sqlparser"
+	"github.com/sealdb/mysqlstack/sqlparser/depends/common"
+	querypb "github.com/sealdb/mysqlstack/sqlparser/depends/query"
+	"github.com/sealdb/mysqlstack/sqlparser/depends/sqltypes"
+	"github.com/sealdb/mysqlstack/xlog"
+)
+
+var (
+	_ PlanEngine = &RecursiveEngine{}
+)
+
+// RecursiveEngine represents the recursive cte executor.
+// The anchor is executed first, then the recursive member is planned and executed
+// on the rows produced by the last iteration, until no more rows are produced.
+type RecursiveEngine struct {
+	log    *xlog.Log
+	node   *builder.RecursiveNode
+	anchor PlanEngine
+	txn    backend.Transaction
+}
+
+// NewRecursiveEngine creates the new recursive cte executor.
+func NewRecursiveEngine(log *xlog.Log, node *builder.RecursiveNode, txn backend.Transaction) *RecursiveEngine {
+	return &RecursiveEngine{
+		log:  log,
+		node: node,
+		txn:  txn,
+	}
+}
+
+// Execute used to execute the executor.
+func (r *RecursiveEngine) Execute(ctx *xcontext.ResultContext) error {
+	anchorCtx := xcontext.NewResultContext()
+	if err := r.anchor.Execute(anchorCtx); err != nil {
+		return err
+	}
+
+	// UNION DISTINCT discards the rows produced by the former iterations,
+	// a row is kept only once.
+	var table *common.HashTable
+	if r.node.Typ == sqlparser.UnionDistinctStr || r.node.Typ == sqlparser.UnionStr {
+		table = common.NewHashTable()
+	}
+	dedup := func(rows [][]sqltypes.Value) [][]sqltypes.Value {
+		if table == nil {
+			return rows
+		}
+		kept := rows[:0]
+		for _, row := range rows {
+			var key []byte
+			for _, v := range row {
+				key = append(key, v.Raw()...)
+			}
+			if has, _ := table.Get(key); !has {
+				table.Put(key, row)
+				kept = append(kept, row)
+			}
+		}
+		return kept
+	}
+
+	fields := anchorCtx.Results.Fields
+	res := &sqltypes.Result{Fields: fields}
+	work := &sqltypes.Result{Fields: fields, Rows: dedup(anchorCtx.Results.Rows)}
+	res.Rows = append(res.Rows, work.Rows...)
+
+	maxrow := r.txn.MaxJoinRows()
+	depth := r.txn.MaxRecursionDepth()
+	for i := 0; len(work.Rows) > 0; i++ {
+		if i >= depth {
+			return errors.Errorf("unsupported: recursive.query.aborted.after.'%d'.iterations.exceeded.cte-max-recursion-depth", i+1)
+		}
+
+		plan, err := r.node.Iterate(work)
+		if err != nil {
+			return err
+		}
+		iterCtx := xcontext.NewResultContext()
+		if err := BuildEngine(r.log, plan, r.txn).Execute(iterCtx); err != nil {
+			return err
+		}
+		if len(iterCtx.Results.Fields) != len(fields) {
+			return errors.New("unsupported: the.used.'select'.statements.have.a.different.number.of.columns")
+		}
+
+		work = &sqltypes.Result{Fields: fields, Rows: dedup(iterCtx.Results.Rows)}
+		res.Rows = append(res.Rows, work.Rows...)
+		if len(res.Rows) > maxrow {
+			return errors.Errorf("unsupported: recursive.cte.'%s'.row.count.exceeded.allowed.limit.of.'%d'", r.node.Name, maxrow)
+		}
+	}
+	res.RowsAffected = uint64(len(res.Rows))
+	ctx.Results = res
+	return operator.ExecSubPlan(r.log, r.node, r.txn, ctx)
+}
+
+// execBindVars used to execute querys with bindvas.
+// The recursive cte is uncorrelated, the bindvars are not used.
+func (r *RecursiveEngine) execBindVars(ctx *xcontext.ResultContext, bindVars map[string]*querypb.BindVariable, wantfields bool) error {
+	return r.Execute(ctx)
+}
+
+// getFields fetches the field info.
+func (r *RecursiveEngine) getFields(ctx *xcontext.ResultContext, bindVars map[string]*querypb.BindVariable) error {
+	return r.Execute(ctx)
+}
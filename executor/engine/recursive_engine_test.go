@@ -0,0 +1,152 @@
+/*
+ * NeoDB
+ *
+ * Copyright 2021-2030 The NeoDB Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package engine
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/sealdb/neodb/backend"
+	"github.com/sealdb/neodb/planner"
+	"github.com/sealdb/neodb/router"
+	"github.com/sealdb/neodb/xcontext"
+	"github.com/sealdb/neodb/xparser"
+
+	"github.com/sealdb/mysqlstack/sqlparser"
+	querypb "github.com/sealdb/mysqlstack/sqlparser/depends/query"
+	"github.com/sealdb/mysqlstack/sqlparser/depends/sqltypes"
+	"github.com/sealdb/mysqlstack/xlog"
+	"github.com/stretchr/testify/assert"
+)
+
+// intResult returns the result of the int64 rows with the field.
+func intResult(field string, vals ...int64) *sqltypes.Result {
+	res := &sqltypes.Result{Fields: []*querypb.Field{{Name: field, Type: querypb.Type_INT64}}}
+	for _, v := range vals {
+		res.Rows = append(res.Rows, []sqltypes.Value{sqltypes.NewInt64(v)})
+	}
+	return res
+}
+
+func TestRecursiveEngine(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	database := "sbtest"
+
+	route, cleanup := router.MockNewRouter(log)
+	defer cleanup()
+
+	err := route.CreateDatabase(database)
+	assert.Nil(t, err)
+	err = route.AddForTest(database, router.MockTableAConfig(), router.MockTableBConfig())
+	assert.Nil(t, err)
+
+	// Create scatter and query handler.
+	scatter, fakedbs, cleanup := backend.MockScatter(log, 10)
+	defer cleanup()
+	fakedbs.AddQuery("select 1 as n from dual", intResult("n", 1))
+	fakedbs.AddQuery("select n + 1 from (select 1 as n from dual) as t where n < 3", intResult("n + 1", 2))
+	fakedbs.AddQuery("select n + 1 from (select 2 as n from dual) as t where n < 3", intResult("n + 1", 3))
+	fakedbs.AddQuery("select n + 1 from (select 3 as n from dual) as t where n < 3", intResult("n + 1"))
+	fakedbs.AddQuery("select n % 2 + 1 from (select 1 as n from dual) as t", intResult("n % 2 + 1", 2))
+	fakedbs.AddQuery("select n % 2 + 1 from (select 2 as n from dual) as t", intResult("n % 2 + 1", 1))
+
+	querys := []string{
+		"with recursive t(n) as (select 1 union all select n + 1 from t where n < 3) select * from t",
+		"with recursive t(n) as (select 1 union all select n + 1 from t where n < 3) select t.n from t where t.n > 1 order by t.n desc",
+		"with recursive t(n) as (select 1 union select n % 2 + 1 from t) select n from t",
+	}
+	results := []string{
+		"[[1] [2] [3]]",
+		"[[3] [2]]",
+		"[[1] [2]]",
+	}
+
+	for i, query := range querys {
+		node, err := xparser.Parse(query)
+		assert.Nil(t, err)
+
+		plan := planner.NewSelectPlan(log, database, query, node.(*sqlparser.Select), route)
+		err = plan.Build()
+		assert.Nil(t, err, query)
+
+		txn, err := scatter.CreateTransaction()
+		assert.Nil(t, err)
+		defer txn.Finish()
+		txn.SetMaxJoinRows(32768)
+		planEngine := BuildEngine(log, plan.Root, txn)
+		{
+			ctx := xcontext.NewResultContext()
+			err := planEngine.Execute(ctx)
+			assert.Nil(t, err, query)
+			got := fmt.Sprintf("%v", ctx.Results.Rows)
+			assert.Equal(t, results[i], got, query)
+		}
+	}
+}
+
+func TestRecursiveEngineErr(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	database := "sbtest"
+
+	route, cleanup := router.MockNewRouter(log)
+	defer cleanup()
+
+	err := route.CreateDatabase(database)
+	assert.Nil(t, err)
+	err = route.AddForTest(database, router.MockTableAConfig(), router.MockTableBConfig())
+	assert.Nil(t, err)
+
+	// Create scatter and query handler.
+	scatter, fakedbs, cleanup := backend.MockScatter(log, 10)
+	defer cleanup()
+	fakedbs.AddQuery("select 1 as n from dual", intResult("n", 1))
+	fakedbs.AddQuery("select n + 1 from (select 1 as n from dual) as t where n < 3", intResult("n + 1", 2))
+	fakedbs.AddQuery("select n + 1 from (select 2 as n from dual) as t where n < 3", intResult("n + 1", 3))
+	fakedbs.AddQuery("select n + 1 from (select 3 as n from dual) as t where n < 3", intResult("n + 1"))
+	fakedbs.AddQuery("select n, n from (select 1 as n from dual) as t", &sqltypes.Result{
+		Fields: []*querypb.Field{{Name: "n", Type: querypb.Type_INT64}, {Name: "n", Type: querypb.Type_INT64}},
+	})
+
+	querys := []string{
+		"with recursive t(n) as (select 1 union all select n + 1 from t where n < 3) select * from t",
+		"with recursive t(n) as (select 1 union all select n + 1 from t where n < 3) select * from t",
+		"with recursive t(n) as (select 1 union all select n, n from t) select * from t",
+	}
+	depths := []int{1, 1000, 1000}
+	maxrows := []int{32768, 2, 32768}
+	wants := []string{
+		"unsupported: recursive.query.aborted.after.'2'.iterations.exceeded.cte-max-recursion-depth",
+		"unsupported: recursive.cte.'t'.row.count.exceeded.allowed.limit.of.'2'",
+		"unsupported: the.used.'select'.statements.have.a.different.number.of.columns",
+	}
+
+	for i, query := range querys {
+		node, err := xparser.Parse(query)
+		assert.Nil(t, err)
+
+		plan := planner.NewSelectPlan(log, database, query, node.(*sqlparser.Select), route)
+		err = plan.Build()
+		assert.Nil(t, err, query)
+
+		txn, err := scatter.CreateTransaction()
+		assert.Nil(t, err)
+		defer txn.Finish()
+		txn.SetMaxJoinRows(maxrows[i])
+		txn.SetMaxRecursionDepth(depths[i])
+		planEngine := BuildEngine(log, plan.Root, txn)
+		{
+			ctx := xcontext.NewResultContext()
+			err := planEngine.Execute(ctx)
+			assert.NotNil(t, err, query)
+			if err != nil {
+				assert.Equal(t, wants[i], err.Error(), query)
+			}
+		}
+	}
+}
@@ -0,0 +1,84 @@
+/*
+ * NeoDB
+ *
+ * Copyright 2021-2030 The NeoDB Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package engine
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/sealdb/neodb/backend"
+	"github.com/sealdb/neodb/planner/builder"
+	"github.com/sealdb/neodb/xcontext"
+
+	querypb "github.com/sealdb/mysqlstack/sqlparser/depends/query"
+	"github.com/sealdb/mysqlstack/sqlparser/depends/sqltypes"
+	"github.com/sealdb/mysqlstack/xlog"
+	"golang.org/x/sync/errgroup"
+)
+
+var (
+	_ PlanEngine = &SemiJoinEngine{}
+)
+
+// SemiJoinEngine represents the semi-join executor.
+// The subqueries are executed first, then the outer query is planned with their results and executed.
+type SemiJoinEngine struct {
+	log  *xlog.Log
+	node *builder.SemiJoinNode
+	txn  backend.Transaction
+}
+
+// NewSemiJoinEngine creates the new semi-join executor.
+func NewSemiJoinEngine(log *xlog.Log, node *builder.SemiJoinNode, txn backend.Transaction) *SemiJoinEngine {
+	return &SemiJoinEngine{
+		log:  log,
+		node: node,
+		txn:  txn,
+	}
+}
+
+// Execute used to execute the executor.
+func (s *SemiJoinEngine) Execute(ctx *xcontext.ResultContext) error {
+	var eg errgroup.Group
+	maxrow := s.txn.MaxJoinRows()
+	results := make([]*sqltypes.Result, len(s.node.Subqueries))
+	for i, sub := range s.node.Subqueries {
+		i, sub := i, sub
+		eg.Go(func() error {
+			subCtx := xcontext.NewResultContext()
+			if err := BuildEngine(s.log, sub.Node, s.txn).Execute(subCtx); err != nil {
+				return err
+			}
+			if len(subCtx.Results.Rows) > maxrow {
+				return fmt.Errorf("unsupported: subquery.row.count.exceeded.allowed.limit.of.'%d'", maxrow)
+			}
+			results[i] = subCtx.Results
+			return nil
+		})
+	}
+	if err := eg.Wait(); err != nil {
+		return err
+	}
+
+	outer, err := s.node.Rebind(results)
+	if err != nil {
+		return err
+	}
+	return BuildEngine(s.log, outer, s.txn).Execute(ctx)
+}
+
+// execBindVars used to execute querys with bindvas.
+func (s *SemiJoinEngine) execBindVars(ctx *xcontext.ResultContext, bindVars map[string]*querypb.BindVariable, wantfields bool) error {
+	return errors.New("SemiJoinEngine.execBindVars: unreachable")
+}
+
+// getFields fetches the field info.
+func (s *SemiJoinEngine) getFields(ctx *xcontext.ResultContext, bindVars map[string]*querypb.BindVariable) error {
+	return errors.New("SemiJoinEngine.getFields: unreachable")
+}
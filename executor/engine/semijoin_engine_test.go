@@ -0,0 +1,197 @@
+/*
+ * NeoDB
+ *
+ * Copyright 2021-2030 The NeoDB Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package engine
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/sealdb/neodb/backend"
+	"github.com/sealdb/neodb/planner"
+	"github.com/sealdb/neodb/router"
+	"github.com/sealdb/neodb/xcontext"
+
+	"github.com/sealdb/mysqlstack/sqlparser"
+	querypb "github.com/sealdb/mysqlstack/sqlparser/depends/query"
+	"github.com/sealdb/mysqlstack/sqlparser/depends/sqltypes"
+	"github.com/sealdb/mysqlstack/xlog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSemiJoinEngine(t *testing.T) {
+	r1 := &sqltypes.Result{
+		Fields: []*querypb.Field{
+			{
+				Name: "id",
+				Type: querypb.Type_INT32,
+			},
+		},
+		Rows: [][]sqltypes.Value{
+			{
+				sqltypes.MakeTrusted(querypb.Type_INT32, []byte("3")),
+			},
+			{
+				sqltypes.MakeTrusted(querypb.Type_INT32, []byte("3")),
+			},
+		},
+	}
+	r2 := &sqltypes.Result{
+		Fields: []*querypb.Field{
+			{
+				Name: "id",
+				Type: querypb.Type_INT32,
+			},
+			{
+				Name: "name",
+				Type: querypb.Type_VARCHAR,
+			},
+		},
+		Rows: [][]sqltypes.Value{
+			{
+				sqltypes.MakeTrusted(querypb.Type_INT32, []byte("3")),
+				sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("go")),
+			},
+		},
+	}
+	r3 := &sqltypes.Result{
+		Fields: []*querypb.Field{
+			{
+				Name: "id",
+				Type: querypb.Type_INT32,
+			},
+		},
+	}
+
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	database := "sbtest"
+
+	route, cleanup := router.MockNewRouter(log)
+	defer cleanup()
+
+	err := route.CreateDatabase(database)
+	assert.Nil(t, err)
+	err = route.AddForTest(database, router.MockTableAConfig(), router.MockTableBConfig())
+	assert.Nil(t, err)
+
+	// Create scatter and query handler.
+	scatter, fakedbs, cleanup := backend.MockScatter(log, 10)
+	defer cleanup()
+	fakedbs.AddQuery("select id from sbtest.B0 as B where id > 1", r1)
+	fakedbs.AddQuery("select id from sbtest.B1 as B where id > 1", r3)
+	fakedbs.AddQueryPattern("select 1 from sbtest.B[0-9] as B where id = 5 limit 1", r3)
+	fakedbs.AddQueryPattern("select id, name from sbtest.A[0-9] as A where id in \\(3\\).*", r2)
+	fakedbs.AddQueryPattern("select id, name from sbtest.A[0-9] as A where 0", r3)
+
+	querys := []string{
+		"select id, name from A where id in (select id from B where id > 1)",
+		"select id, name from A where id in (select id from B where id > 1) and not exists (select 1 from B where id = 5)",
+		"select id, name from A where exists (select 1 from B where id = 5)",
+	}
+	results := []string{
+		"[[3 go]]",
+		"[[3 go]]",
+		"[]",
+	}
+
+	for i, query := range querys {
+		node, err := sqlparser.Parse(query)
+		assert.Nil(t, err)
+
+		plan := planner.NewSelectPlan(log, database, query, node.(*sqlparser.Select), route)
+		err = plan.Build()
+		assert.Nil(t, err)
+
+		txn, err := scatter.CreateTransaction()
+		assert.Nil(t, err)
+		defer txn.Finish()
+		txn.SetMaxJoinRows(32768)
+		planEngine := BuildEngine(log, plan.Root, txn)
+		{
+			ctx := xcontext.NewResultContext()
+			err := planEngine.Execute(ctx)
+			assert.Nil(t, err)
+			got := fmt.Sprintf("%v", ctx.Results.Rows)
+			assert.Equal(t, results[i], got, query)
+		}
+	}
+}
+
+func TestSemiJoinEngineErr(t *testing.T) {
+	r1 := &sqltypes.Result{
+		Fields: []*querypb.Field{
+			{
+				Name: "id",
+				Type: querypb.Type_INT32,
+			},
+			{
+				Name: "name",
+				Type: querypb.Type_VARCHAR,
+			},
+		},
+		Rows: [][]sqltypes.Value{
+			{
+				sqltypes.MakeTrusted(querypb.Type_INT32, []byte("3")),
+				sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("go")),
+			},
+			{
+				sqltypes.MakeTrusted(querypb.Type_INT32, []byte("5")),
+				sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("lang")),
+			},
+		},
+	}
+
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	database := "sbtest"
+
+	route, cleanup := router.MockNewRouter(log)
+	defer cleanup()
+
+	err := route.CreateDatabase(database)
+	assert.Nil(t, err)
+	err = route.AddForTest(database, router.MockTableAConfig(), router.MockTableBConfig())
+	assert.Nil(t, err)
+
+	// Create scatter and query handler.
+	scatter, fakedbs, cleanup := backend.MockScatter(log, 10)
+	defer cleanup()
+	fakedbs.AddQueryPattern("select .* from sbtest.B0 as B where id = 0", r1)
+
+	querys := []string{
+		"select id, name from A where id in (select id, name from B where id = 0)",
+		"select id, name from A where id not in (select id from B where id = 0)",
+		"select id, name from A where exists (select id from B where id = 1)",
+	}
+	maxrows := []int{32768, 1, 32768}
+	wants := []string{
+		"Operand should contain 1 column(s)",
+		"unsupported: subquery.row.count.exceeded.allowed.limit.of.'1'",
+		"mock.handler.query[select id from sbtest.b1 as b where id = 1 limit 1].error[can.not.found.the.cond.please.set.first] (errno 1105) (sqlstate HY000)",
+	}
+
+	for i, query := range querys {
+		node, err := sqlparser.Parse(query)
+		assert.Nil(t, err)
+
+		plan := planner.NewSelectPlan(log, database, query, node.(*sqlparser.Select), route)
+		err = plan.Build()
+		assert.Nil(t, err)
+
+		txn, err := scatter.CreateTransaction()
+		assert.Nil(t, err)
+		defer txn.Finish()
+		txn.SetMaxJoinRows(maxrows[i])
+		planEngine := BuildEngine(log, plan.Root, txn)
+		{
+			ctx := xcontext.NewResultContext()
+			err := planEngine.Execute(ctx)
+			assert.NotNil(t, err)
+			assert.Equal(t, wants[i], err.Error())
+		}
+	}
+}
@@ -0,0 +1,194 @@
+/*
+ * NeoDB
+ *
+ * Copyright 2021-2030 The NeoDB Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package engine
+
+import (
+	"github.com/sealdb/neodb/planner/builder"
+	"github.com/sealdb/neodb/xbase/spill"
+
+	"github.com/pkg/errors"
+	"github.com/sealdb/mysqlstack/sqlparser/depends/sqltypes"
+)
+
+const (
+	// gracePartitions is the count of the partitions of the grace hash join.
+	gracePartitions = 16
+	// graceMaxDepth is the max depth of re-partitioning the partitions still exceeding the limit.
+	graceMaxDepth = 3
+)
+
+// joinOutput collects the joined rows. If the memory of the query is limited, the rows are
+// held by the spill.Rows which spills to disk once the limit is exceeded, the join row count
+// is not limited by the maxrow but the spilled bytes are limited by the disk limit of the
+// tracker. Otherwise the rows are appended to the result.
+type joinOutput struct {
+	res     *sqltypes.Result
+	rows    *spill.Rows
+	maxrow  int
+	tracker *spill.Tracker
+}
+
+func newJoinOutput(res *sqltypes.Result, maxrow int, tracker *spill.Tracker) *joinOutput {
+	out := &joinOutput{
+		res:    res,
+		maxrow: maxrow,
+	}
+	if tracker.Enabled() {
+		out.tracker = tracker
+		out.rows = spill.NewRows(tracker)
+	}
+	return out
+}
+
+// append used to append the joined row.
+func (out *joinOutput) append(row []sqltypes.Value) error {
+	out.res.RowsAffected++
+	if out.rows != nil {
+		return out.rows.Append(row)
+	}
+	out.res.Rows = append(out.res.Rows, row)
+	if len(out.res.Rows) > out.maxrow {
+		return errors.Errorf("unsupported: join.row.count.exceeded.allowed.limit.of.'%d'", out.maxrow)
+	}
+	return nil
+}
+
+// grow used to preallocate the result for n rows if the rows are held in the result.
+func (out *joinOutput) grow(n int) {
+	if out.rows == nil {
+		out.res.Rows = make([][]sqltypes.Value, 0, n)
+	}
+}
+
+// spilled returns true if the joined rows were spilled to disk.
+func (out *joinOutput) spilled() bool {
+	return out.rows != nil && out.rows.Spilled()
+}
+
+// materialize used to move the joined rows into the result.
+func (out *joinOutput) materialize() error {
+	if out.rows == nil {
+		return nil
+	}
+	rows, err := out.rows.All()
+	if err != nil {
+		out.close()
+		return err
+	}
+	out.res.Rows = rows
+	return out.close()
+}
+
+// close used to release the joined rows held by the spill.Rows.
+func (out *joinOutput) close() error {
+	if out.rows == nil {
+		return nil
+	}
+	return out.rows.Close()
+}
+
+// graceHashJoin used to join the rows exceeding the memory limit. Both inputs are partitioned
+// to the temp files by the hash of the join keys, then the pairs of the partitions are joined
+// in memory one by one. The partitions still exceeding the limit are partitioned again.
+func graceHashJoin(lrows, rrows [][]sqltypes.Value, out *joinOutput, node *builder.JoinNode, depth int) error {
+	lparts := make([]*spill.File, gracePartitions)
+	rparts := make([]*spill.File, gracePartitions)
+	defer func() {
+		for i := range lparts {
+			if lparts[i] != nil {
+				lparts[i].Close()
+			}
+			if rparts[i] != nil {
+				rparts[i].Close()
+			}
+		}
+	}()
+
+	partition := func(rows [][]sqltypes.Value, keys []builder.JoinKey, parts []*spill.File, isLeft bool) error {
+		for _, row := range rows {
+			key, ok := hashKey(row, keys)
+			if !ok {
+				// The rows with null keys cannot match, only the left rows are kept by the left join.
+				if isLeft {
+					if err := concatLeftAndNil([][]sqltypes.Value{row}, node, out); err != nil {
+						return err
+					}
+				}
+				continue
+			}
+			idx := spill.Partition(key, depth, gracePartitions)
+			if parts[idx] == nil {
+				part, err := spill.NewFile(out.tracker)
+				if err != nil {
+					return err
+				}
+				parts[idx] = part
+			}
+			if err := parts[idx].Write(row); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := partition(lrows, node.LeftKeys, lparts, true); err != nil {
+		return err
+	}
+	lrows = nil
+	if err := partition(rrows, node.RightKeys, rparts, false); err != nil {
+		return err
+	}
+	rrows = nil
+
+	for i := range lparts {
+		if lparts[i] == nil {
+			continue
+		}
+		lpart, err := readPartition(lparts[i])
+		if err != nil {
+			return err
+		}
+		rpart, err := readPartition(rparts[i])
+		if err != nil {
+			return err
+		}
+		if err := joinPartition(lpart, rpart, out, node, depth); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// joinPartition used to join the pair of the partitions, the partition held in memory is tracked.
+func joinPartition(lrows, rrows [][]sqltypes.Value, out *joinOutput, node *builder.JoinNode, depth int) error {
+	build := rrows
+	if len(lrows) < len(rrows) {
+		build = lrows
+	}
+	if spill.RowsSize(build) > out.tracker.Limit() && depth+1 < graceMaxDepth {
+		return graceHashJoin(lrows, rrows, out, node, depth+1)
+	}
+
+	size := spill.RowsSize(lrows) + spill.RowsSize(rrows)
+	out.tracker.Consume(size)
+	defer out.tracker.Release(size)
+	return hashJoinRows(lrows, rrows, out, node)
+}
+
+// readPartition used to read the rows of the partition into memory.
+func readPartition(part *spill.File) ([][]sqltypes.Value, error) {
+	if part == nil {
+		return nil, nil
+	}
+	r, err := part.Reader()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return spill.ReadAll(r)
+}
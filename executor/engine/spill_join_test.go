@@ -0,0 +1,209 @@
+/*
+ * NeoDB
+ *
+ * Copyright 2021-2030 The NeoDB Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package engine
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"testing"
+
+	"github.com/sealdb/neodb/backend"
+	"github.com/sealdb/neodb/planner"
+	"github.com/sealdb/neodb/planner/builder"
+	"github.com/sealdb/neodb/router"
+	"github.com/sealdb/neodb/xbase/spill"
+	"github.com/sealdb/neodb/xcontext"
+
+	"github.com/sealdb/mysqlstack/sqlparser"
+	querypb "github.com/sealdb/mysqlstack/sqlparser/depends/query"
+	"github.com/sealdb/mysqlstack/sqlparser/depends/sqltypes"
+	"github.com/sealdb/mysqlstack/xlog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGraceHashJoin(t *testing.T) {
+	dir, err := ioutil.TempDir("", "neodb_spill_")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	intRow := func(vals ...int) []sqltypes.Value {
+		row := make([]sqltypes.Value, len(vals))
+		for i, v := range vals {
+			if v < 0 {
+				row[i] = sqltypes.NULL
+				continue
+			}
+			row[i] = sqltypes.NewInt64(int64(v))
+		}
+		return row
+	}
+	sorted := func(rows [][]sqltypes.Value) string {
+		out := make([]string, len(rows))
+		for i, row := range rows {
+			out[i] = fmt.Sprintf("%v", row)
+		}
+		sort.Strings(out)
+		return fmt.Sprintf("%v", out)
+	}
+	newResults := func() (*sqltypes.Result, *sqltypes.Result) {
+		left, right := &sqltypes.Result{}, &sqltypes.Result{}
+		for i := 0; i < 200; i++ {
+			left.Rows = append(left.Rows, intRow(i%50, i))
+			right.Rows = append(right.Rows, intRow(i%70, i))
+		}
+		left.Rows = append(left.Rows, intRow(-1, 1000))
+		right.Rows = append(right.Rows, intRow(-1, 2000))
+		return left, right
+	}
+
+	for _, isLeftJoin := range []bool{false, true} {
+		node := &builder.JoinNode{
+			Cols:       []int{-1, -2, 2},
+			LeftKeys:   []builder.JoinKey{{Index: 0}},
+			RightKeys:  []builder.JoinKey{{Index: 0}},
+			IsLeftJoin: isLeftJoin,
+		}
+
+		left, right := newResults()
+		want := &sqltypes.Result{}
+		err := hashJoin(left, right, newJoinOutput(want, 1<<20, nil), node)
+		assert.Nil(t, err)
+
+		left, right = newResults()
+		tracker := spill.NewTracker(1024, dir)
+		got := &sqltypes.Result{}
+		out := newJoinOutput(got, 10, tracker)
+		err = hashJoin(left, right, out, node)
+		assert.Nil(t, err)
+		assert.True(t, out.spilled())
+		assert.True(t, tracker.Spills() > 1)
+		err = out.materialize()
+		assert.Nil(t, err)
+
+		assert.Equal(t, sorted(want.Rows), sorted(got.Rows))
+		assert.Equal(t, want.RowsAffected, got.RowsAffected)
+		assert.Equal(t, int64(0), tracker.Used())
+		files, err := ioutil.ReadDir(dir)
+		assert.Nil(t, err)
+		assert.Equal(t, 0, len(files))
+	}
+}
+
+func TestJoinEngineSpill(t *testing.T) {
+	dir, err := ioutil.TempDir("", "neodb_spill_")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	newResult := func(table string, n int) *sqltypes.Result {
+		res := &sqltypes.Result{
+			Fields: []*querypb.Field{{Name: "id", Table: table, Type: querypb.Type_INT32}},
+		}
+		for i := 0; i < n; i++ {
+			res.Rows = append(res.Rows, []sqltypes.Value{sqltypes.MakeTrusted(querypb.Type_INT32, []byte(fmt.Sprintf("%d", i)))})
+		}
+		return res
+	}
+
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	database := "sbtest"
+
+	route, cleanup := router.MockNewRouter(log)
+	defer cleanup()
+
+	err = route.CreateDatabase(database)
+	assert.Nil(t, err)
+	err = route.AddForTest(database, router.MockTableAConfig(), router.MockTableBConfig())
+	assert.Nil(t, err)
+
+	scatter, fakedbs, cleanup := backend.MockScatter(log, 10)
+	defer cleanup()
+	// Each shard of A returns 0..9, each shard of B returns 0..4.
+	fakedbs.AddQueryPattern("select .* from sbtest.A[0-9]+ as A.*", newResult("A", 10))
+	fakedbs.AddQueryPattern("select .* from sbtest.B[0-9]+ as B.*", newResult("B", 5))
+
+	tcases := []struct {
+		query  string
+		result string
+	}{
+		{
+			query:  "select A.id, B.id from A join B order by A.id desc, B.id limit 3",
+			result: "[[9 0] [9 0] [9 0]]",
+		},
+		{
+			query:  "select A.id, sum(B.id) from A join B group by A.id",
+			result: "[[0 80] [1 80] [2 80] [3 80] [4 80] [5 80] [6 80] [7 80] [8 80] [9 80]]",
+		},
+		{
+			query:  "select max(B.id), min(A.id) from A join B",
+			result: "[[4 0]]",
+		},
+		{
+			query:  "select /*+ hash_join */ A.id, B.id from A join B on A.id = B.id order by B.id desc limit 9",
+			result: "[[4 4] [4 4] [4 4] [4 4] [4 4] [4 4] [4 4] [4 4] [3 3]]",
+		},
+	}
+
+	for _, tcase := range tcases {
+		node, err := sqlparser.Parse(tcase.query)
+		assert.Nil(t, err)
+
+		plan := planner.NewSelectPlan(log, database, tcase.query, node.(*sqlparser.Select), route)
+		err = plan.Build()
+		assert.Nil(t, err)
+
+		// The join rows exceed the limit without spilling.
+		{
+			txn, err := scatter.CreateTransaction()
+			assert.Nil(t, err)
+			txn.SetMaxJoinRows(32)
+			planEngine := BuildEngine(log, plan.Root, txn)
+			err = planEngine.Execute(xcontext.NewResultContext())
+			assert.Equal(t, "unsupported: join.row.count.exceeded.allowed.limit.of.'32'", err.Error(), tcase.query)
+			txn.Finish()
+		}
+
+		{
+			txn, err := scatter.CreateTransaction()
+			assert.Nil(t, err)
+			txn.SetMaxJoinRows(32)
+			txn.SetMaxQueryMemory(2048)
+			txn.SetSpillDir(dir)
+			planEngine := BuildEngine(log, plan.Root, txn)
+			ctx := xcontext.NewResultContext()
+			err = planEngine.Execute(ctx)
+			assert.Nil(t, err, tcase.query)
+			assert.Equal(t, tcase.result, fmt.Sprintf("%v", ctx.Results.Rows), tcase.query)
+			assert.True(t, txn.MemTracker().Spills() > 0, tcase.query)
+			assert.Equal(t, int64(0), txn.MemTracker().Used(), tcase.query)
+			assert.Equal(t, int64(0), txn.MemTracker().DiskUsed(), tcase.query)
+			txn.Finish()
+		}
+
+		// The spilled rows exceed the disk limit.
+		{
+			txn, err := scatter.CreateTransaction()
+			assert.Nil(t, err)
+			txn.SetMaxJoinRows(32)
+			txn.SetMaxQueryMemory(2048)
+			txn.SetMaxSpillSize(64)
+			txn.SetSpillDir(dir)
+			planEngine := BuildEngine(log, plan.Root, txn)
+			err = planEngine.Execute(xcontext.NewResultContext())
+			assert.Equal(t, "Query execution was interrupted, max spill size[64 bytes] exceeded", err.Error(), tcase.query)
+			assert.Equal(t, int64(0), txn.MemTracker().DiskUsed(), tcase.query)
+			txn.Finish()
+		}
+	}
+
+	files, err := ioutil.ReadDir(dir)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(files))
+}
@@ -90,7 +90,7 @@ func (u *UnionEngine) Execute(ctx *xcontext.ResultContext) error {
 		ctx.Results.Rows = lctx.Results.Rows
 		ctx.Results.RowsAffected = lctx.Results.RowsAffected
 	}
-	return operator.ExecSubPlan(u.log, u.node, ctx)
+	return operator.ExecSubPlan(u.log, u.node, u.txn, ctx)
 }
 
 // execBindVars used to execute querys with bindvas.
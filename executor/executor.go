@@ -11,7 +11,9 @@ package executor
 
 import (
 	"github.com/sealdb/neodb/backend"
+	"github.com/sealdb/neodb/executor/engine"
 	"github.com/sealdb/neodb/planner"
+	"github.com/sealdb/neodb/planner/builder"
 	"github.com/sealdb/neodb/xcontext"
 
 	"github.com/pkg/errors"
@@ -102,3 +104,56 @@ func (et *Tree) Execute() (*sqltypes.Result, error) {
 	}
 	return rsCtx.Results, nil
 }
+
+// checkForceXA used to refuse the write which must be in the XA transaction if the twopc
+// is disabled, the rows and the pairs of their unique global indexes are written together.
+func checkForceXA(txn backend.Transaction, forceXA bool) error {
+	if forceXA && !txn.IsTwoPC() {
+		return errors.New("unsupported: unique.global.index.write.without.twopc")
+	}
+	return nil
+}
+
+// lookupPlan is the DML plan reading the lookup tables in the transaction before the write.
+type lookupPlan interface {
+	PruneRoutes(keep []bool)
+	Lookups() []xcontext.QueryTuple
+	BuildLookups(results []*sqltypes.Result) error
+}
+
+// pruneRoutes used to prune the routes of the plan by the lookup table read in the transaction.
+func pruneRoutes(txn backend.Transaction, prune *builder.LookupPrune, plan lookupPlan) error {
+	if prune == nil {
+		return nil
+	}
+	keep, err := engine.PruneRoutes(txn, []*builder.LookupPrune{prune})
+	if err != nil {
+		return err
+	}
+	plan.PruneRoutes(keep)
+	return nil
+}
+
+// buildLookups used to read the lookup pairs of the rows to change in the transaction, and
+// build the querys maintaining the lookup tables by them. The pairs are read in the XA
+// transaction of the write, so the rows read by the statement before are still locked.
+func buildLookups(txn backend.Transaction, mode xcontext.RequestMode, forceXA bool, plan lookupPlan) error {
+	reads := plan.Lookups()
+	if len(reads) == 0 {
+		return nil
+	}
+	results := make([]*sqltypes.Result, 0, len(reads))
+	for _, read := range reads {
+		reqCtx := xcontext.NewRequestContext()
+		reqCtx.Mode = mode
+		reqCtx.TxnMode = xcontext.TxnWrite
+		reqCtx.Querys = []xcontext.QueryTuple{read}
+		reqCtx.ForceXA = forceXA
+		rs, err := txn.Execute(reqCtx)
+		if err != nil {
+			return err
+		}
+		results = append(results, rs)
+	}
+	return plan.BuildLookups(results)
+}
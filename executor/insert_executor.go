@@ -11,9 +11,11 @@ package executor
 
 import (
 	"github.com/sealdb/neodb/backend"
+	"github.com/sealdb/neodb/executor/engine"
 	"github.com/sealdb/neodb/planner"
 	"github.com/sealdb/neodb/xcontext"
 
+	"github.com/sealdb/mysqlstack/sqlparser/depends/sqltypes"
 	"github.com/sealdb/mysqlstack/xlog"
 )
 
@@ -40,11 +42,32 @@ func NewInsertExecutor(log *xlog.Log, plan planner.Plan, txn backend.Transaction
 // Execute used to execute the executor.
 func (executor *InsertExecutor) Execute(ctx *xcontext.ResultContext) error {
 	plan := executor.plan.(*planner.InsertPlan)
+	if err := checkForceXA(executor.txn, plan.ForceXA); err != nil {
+		return err
+	}
+	if plan.Root != nil {
+		// The rows of the insert ... select are read in the transaction before the insert,
+		// the rows read from all the shards are limited by the max result of the txn.
+		resCtx := xcontext.NewResultContext()
+		planEngine := engine.BuildEngine(executor.log, plan.Root, executor.txn)
+		if err := planEngine.Execute(resCtx); err != nil {
+			return err
+		}
+		if err := plan.BuildRows(resCtx.Results); err != nil {
+			return err
+		}
+		if len(plan.Querys) == 0 {
+			ctx.Results = &sqltypes.Result{}
+			return nil
+		}
+	}
+
 	reqCtx := xcontext.NewRequestContext()
 	reqCtx.Mode = plan.ReqMode
 	reqCtx.TxnMode = xcontext.TxnWrite
 	reqCtx.Querys = plan.Querys
 	reqCtx.RawQuery = plan.RawQuery
+	reqCtx.ForceXA = plan.ForceXA
 
 	rs, err := executor.txn.Execute(reqCtx)
 	if err != nil {
@@ -10,15 +10,19 @@
 package executor
 
 import (
+	"errors"
 	"testing"
 
 	"github.com/sealdb/neodb/backend"
+	"github.com/sealdb/neodb/config"
 	"github.com/sealdb/neodb/fakedb"
 	"github.com/sealdb/neodb/planner"
 	"github.com/sealdb/neodb/router"
 	"github.com/sealdb/neodb/xcontext"
 
 	"github.com/sealdb/mysqlstack/sqlparser"
+	querypb "github.com/sealdb/mysqlstack/sqlparser/depends/query"
+	"github.com/sealdb/mysqlstack/sqlparser/depends/sqltypes"
 	"github.com/sealdb/mysqlstack/xlog"
 	"github.com/stretchr/testify/assert"
 )
@@ -66,3 +70,151 @@ func TestInsertExecutor(t *testing.T) {
 		}
 	}
 }
+
+func TestInsertExecutorSelect(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	database := "sbtest"
+
+	scatter, fakedbs, cleanup := backend.MockScatter(log, 10)
+	defer cleanup()
+
+	route, cleanup := router.MockNewRouter(log)
+	defer cleanup()
+
+	err := route.CreateDatabase(database)
+	assert.Nil(t, err)
+	err = route.AddForTest(database, router.MockTableBConfig(), router.MockTableCConfig())
+	assert.Nil(t, err)
+
+	// Each shard of B returns the rows (1, 'x') and (1002, 'y').
+	rowsB := &sqltypes.Result{
+		Fields: []*querypb.Field{
+			{Name: "id", Table: "B", Type: querypb.Type_INT32},
+			{Name: "name", Table: "B", Type: querypb.Type_VARCHAR},
+		},
+	}
+	for _, row := range [][]string{{"1", "x"}, {"1002", "y"}} {
+		rowsB.Rows = append(rowsB.Rows, []sqltypes.Value{
+			sqltypes.MakeTrusted(querypb.Type_INT32, []byte(row[0])),
+			sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(row[1])),
+		})
+	}
+	fakedbs.AddQueryPattern("select id, name from sbtest.B[0-9] as B.*", rowsB)
+	fakedbs.AddQueryPattern("insert into sbtest.C[0-9].*", &sqltypes.Result{RowsAffected: 2})
+
+	// The rows are read by the select and routed by the shard key of C.
+	{
+		query := "insert into C(a, b) select id, name from B limit 10"
+		node, err := sqlparser.Parse(query)
+		assert.Nil(t, err)
+		plan := planner.NewInsertPlan(log, database, query, node.(*sqlparser.Insert), route)
+		err = plan.Build()
+		assert.Nil(t, err)
+
+		txn, err := scatter.CreateTransaction()
+		assert.Nil(t, err)
+		defer txn.Finish()
+		executor := NewInsertExecutor(log, plan, txn)
+		ctx := xcontext.NewResultContext()
+		err = executor.Execute(ctx)
+		assert.Nil(t, err)
+
+		want := []string{
+			"insert into sbtest.C0(a, b) values (1002, 'y'), (1002, 'y')",
+			"insert into sbtest.C1(a, b) values (1, 'x'), (1, 'x')",
+		}
+		var got []string
+		for _, q := range plan.Querys {
+			got = append(got, q.Query)
+		}
+		assert.Equal(t, want, got)
+		assert.Equal(t, uint64(4), ctx.Results.RowsAffected)
+	}
+
+	// The rows read exceed the max result size.
+	{
+		query := "insert into C(a, b) select id, name from B limit 10"
+		node, err := sqlparser.Parse(query)
+		assert.Nil(t, err)
+		plan := planner.NewInsertPlan(log, database, query, node.(*sqlparser.Insert), route)
+		err = plan.Build()
+		assert.Nil(t, err)
+
+		txn, err := scatter.CreateTransaction()
+		assert.Nil(t, err)
+		defer txn.Finish()
+		txn.SetMaxResult(10)
+		executor := NewInsertExecutor(log, plan, txn)
+		ctx := xcontext.NewResultContext()
+		err = executor.Execute(ctx)
+		assert.Equal(t, "Query execution was interrupted, max memory usage[10 bytes] exceeded", err.Error())
+	}
+}
+
+func TestInsertExecutorUniqueGlobalIndex(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	database := "sbtest"
+
+	scatter, fakedbs, cleanup := backend.MockScatter(log, 10)
+	defer cleanup()
+
+	route, cleanup := router.MockNewRouter(log)
+	defer cleanup()
+
+	// The rows and the lookup pairs are on one backend.
+	err := route.CreateDatabase(database)
+	assert.Nil(t, err)
+	err = route.AddForTest(database,
+		&config.TableConfig{
+			Name:          "L",
+			ShardType:     "HASH",
+			ShardKey:      "id",
+			LookupIndexes: []*config.LookupIndexConfig{{Name: "LN", Column: "name", Unique: true}},
+			Partitions:    []*config.PartitionConfig{{Table: "L0", Segment: "0-4096", Backend: "backend0"}},
+		},
+		&config.TableConfig{
+			Name:       "LN",
+			ShardType:  "HASH",
+			ShardKey:   "name",
+			Partitions: []*config.PartitionConfig{{Table: "LN0", Segment: "0-4096", Backend: "backend0"}},
+		})
+	assert.Nil(t, err)
+
+	fakedbs.AddQueryPattern("insert into sbtest.L0.*", &sqltypes.Result{RowsAffected: 1})
+	fakedbs.AddQueryErrorPattern("insert into `sbtest`.`ln0`.*", errors.New("Duplicate entry 'a' for key 'PRIMARY'"))
+	fakedbs.AddQueryPattern("XA .*", &sqltypes.Result{})
+
+	query := "insert into sbtest.L(id, name) values(2, 'a')"
+	node, err := sqlparser.Parse(query)
+	assert.Nil(t, err)
+	plan := planner.NewInsertPlan(log, database, query, node.(*sqlparser.Insert), route)
+	err = plan.Build()
+	assert.Nil(t, err)
+	assert.True(t, plan.ForceXA)
+
+	// Not in the XA transaction.
+	{
+		txn, err := scatter.CreateTransaction()
+		assert.Nil(t, err)
+		defer txn.Finish()
+		err = NewInsertExecutor(log, plan, txn).Execute(xcontext.NewResultContext())
+		assert.Equal(t, "unsupported: unique.global.index.write.without.twopc", err.Error())
+		assert.Equal(t, 0, fakedbs.GetQueryCalledNum("insert into sbtest.L0(id, name) values (2, 'a')"))
+	}
+
+	// The collision rolls back the row inserted in the same XA transaction.
+	{
+		txn, err := scatter.CreateTransaction()
+		assert.Nil(t, err)
+		defer txn.Finish()
+		err = txn.Begin()
+		assert.Nil(t, err)
+		err = NewInsertExecutor(log, plan, txn).Execute(xcontext.NewResultContext())
+		assert.NotNil(t, err)
+		err = txn.Rollback()
+		assert.Nil(t, err)
+		assert.Equal(t, 1, fakedbs.GetQueryCalledNum("insert into sbtest.L0(id, name) values (2, 'a')"))
+		assert.Equal(t, 1, fakedbs.GetQueryCalledNum("XA START '"+txn.XID()+"'"))
+		assert.Equal(t, 1, fakedbs.GetQueryCalledNum("XA ROLLBACK '"+txn.XID()+"'"))
+	}
+}
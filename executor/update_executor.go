@@ -11,9 +11,12 @@ package executor
 
 import (
 	"github.com/sealdb/neodb/backend"
+	"github.com/sealdb/neodb/executor/engine"
 	"github.com/sealdb/neodb/planner"
 	"github.com/sealdb/neodb/xcontext"
 
+	"github.com/pkg/errors"
+	"github.com/sealdb/mysqlstack/sqlparser/depends/sqltypes"
 	"github.com/sealdb/mysqlstack/xlog"
 )
 
@@ -40,11 +43,44 @@ func NewUpdateExecutor(log *xlog.Log, plan planner.Plan, txn backend.Transaction
 // Execute used to execute the executor.
 func (executor *UpdateExecutor) Execute(ctx *xcontext.ResultContext) error {
 	plan := executor.plan.(*planner.UpdatePlan)
+	if err := pruneRoutes(executor.txn, plan.Prune, plan); err != nil {
+		return err
+	}
+	if plan.Resolve != nil {
+		return executor.executeMoves(plan, ctx)
+	}
+	if plan.Fields != nil {
+		// The cross-shard multi-table update resolves the primary keys and the new values first.
+		fields, err := fetchFields(executor.txn, plan.ReqMode, plan.Fields)
+		if err != nil {
+			return err
+		}
+		if err := plan.BuildResolve(fields); err != nil {
+			return err
+		}
+
+		resCtx := xcontext.NewResultContext()
+		planEngine := engine.BuildEngine(executor.log, plan.Root, executor.txn)
+		if err := planEngine.Execute(resCtx); err != nil {
+			return err
+		}
+		if err := plan.BuildTargets(resCtx.Results); err != nil {
+			return err
+		}
+	}
+	if err := checkForceXA(executor.txn, plan.ForceXA); err != nil {
+		return err
+	}
+	if err := buildLookups(executor.txn, plan.ReqMode, plan.ForceXA, plan); err != nil {
+		return err
+	}
+
 	reqCtx := xcontext.NewRequestContext()
 	reqCtx.Mode = plan.ReqMode
 	reqCtx.TxnMode = xcontext.TxnWrite
 	reqCtx.Querys = plan.Querys
 	reqCtx.RawQuery = plan.RawQuery
+	reqCtx.ForceXA = plan.ForceXA
 
 	rs, err := executor.txn.Execute(reqCtx)
 	if err != nil {
@@ -53,3 +89,67 @@ func (executor *UpdateExecutor) Execute(ctx *xcontext.ResultContext) error {
 	ctx.Results = rs
 	return nil
 }
+
+// executeMoves used to update the shard key, the rows are read and locked, then deleted from
+// the old partitions and inserted into the new ones in the same XA transaction.
+func (executor *UpdateExecutor) executeMoves(plan *planner.UpdatePlan, ctx *xcontext.ResultContext) error {
+	txn := executor.txn
+	if !txn.ShardKeyUpdate() {
+		return errors.New("unsupported: cannot.update.shard.key")
+	}
+	if !txn.IsTwoPC() {
+		return errors.New("unsupported: cannot.update.shard.key.without.twopc")
+	}
+
+	// The generated and the ON UPDATE columns are read before the rows are moved.
+	reqCtx := xcontext.NewRequestContext()
+	reqCtx.Mode = plan.ReqMode
+	reqCtx.TxnMode = xcontext.TxnRead
+	reqCtx.Querys = plan.Columns
+	reqCtx.RawQuery = plan.RawQuery
+	columns, err := txn.Execute(reqCtx)
+	if err != nil {
+		return err
+	}
+	if err := plan.BuildColumns(columns); err != nil {
+		return err
+	}
+
+	reqCtx = xcontext.NewRequestContext()
+	reqCtx.Mode = plan.ReqMode
+	reqCtx.TxnMode = xcontext.TxnWrite
+	reqCtx.Querys = plan.Resolve
+	reqCtx.RawQuery = plan.RawQuery
+	// The rows are locked in the XA transaction until they are moved.
+	reqCtx.ForceXA = true
+	rows, err := txn.Execute(reqCtx)
+	if err != nil {
+		return err
+	}
+	if max := txn.MaxShardKeyUpdateRows(); len(rows.Rows) > max {
+		return errors.Errorf("unsupported: shard.key.update.row.count.exceeded.allowed.limit.of.'%d'", max)
+	}
+	if err := plan.BuildMoves(rows); err != nil {
+		return err
+	}
+	// The lookup pairs of the moved rows are read in the XA transaction.
+	if err := buildLookups(txn, plan.ReqMode, true, plan); err != nil {
+		return err
+	}
+
+	rs := &sqltypes.Result{}
+	if len(plan.Querys) > 0 {
+		reqCtx = xcontext.NewRequestContext()
+		reqCtx.Mode = plan.ReqMode
+		reqCtx.TxnMode = xcontext.TxnWrite
+		reqCtx.Querys = plan.Querys
+		reqCtx.RawQuery = plan.RawQuery
+		if rs, err = txn.Execute(reqCtx); err != nil {
+			return err
+		}
+	}
+	// The moved rows are affected once, not by both the delete and the insert.
+	rs.RowsAffected = uint64(len(rows.Rows))
+	ctx.Results = rs
+	return nil
+}
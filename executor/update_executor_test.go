@@ -17,8 +17,11 @@ import (
 	"github.com/sealdb/neodb/planner"
 	"github.com/sealdb/neodb/router"
 	"github.com/sealdb/neodb/xcontext"
+	"github.com/sealdb/neodb/xparser"
 
 	"github.com/sealdb/mysqlstack/sqlparser"
+	querypb "github.com/sealdb/mysqlstack/sqlparser/depends/query"
+	"github.com/sealdb/mysqlstack/sqlparser/depends/sqltypes"
 	"github.com/sealdb/mysqlstack/xlog"
 	"github.com/stretchr/testify/assert"
 )
@@ -67,3 +70,185 @@ func TestUpdateExecutor(t *testing.T) {
 		}
 	}
 }
+
+func TestUpdateExecutorShardKey(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	database := "sbtest"
+
+	scatter, fakedbs, cleanup := backend.MockScatter(log, 10)
+	defer cleanup()
+
+	route, cleanup := router.MockNewRouter(log)
+	defer cleanup()
+
+	err := route.CreateDatabase(database)
+	assert.Nil(t, err)
+	err = route.AddForTest(database, router.MockTableBConfig())
+	assert.Nil(t, err)
+
+	rows := &sqltypes.Result{
+		Fields: []*querypb.Field{
+			{Name: "id", Type: querypb.Type_INT32, Flags: uint32(querypb.MySqlFlag_PRI_KEY_FLAG)},
+			{Name: "name", Type: querypb.Type_VARCHAR},
+			{Name: "name_len", Type: querypb.Type_INT32},
+		},
+		Rows: [][]sqltypes.Value{
+			{sqltypes.NewInt32(1), sqltypes.NewVarChar("a"), sqltypes.NewInt32(1)},
+			{sqltypes.NewInt32(2), sqltypes.NewVarChar("b"), sqltypes.NewInt32(1)},
+		},
+	}
+	// The generated column name_len is left out of the moved rows.
+	columns := &sqltypes.Result{
+		Fields: []*querypb.Field{
+			{Name: "column_name", Type: querypb.Type_VARCHAR},
+			{Name: "extra", Type: querypb.Type_VARCHAR},
+		},
+		Rows: [][]sqltypes.Value{
+			{sqltypes.NewVarChar("id"), sqltypes.NewVarChar("")},
+			{sqltypes.NewVarChar("name"), sqltypes.NewVarChar("")},
+			{sqltypes.NewVarChar("name_len"), sqltypes.NewVarChar("STORED GENERATED")},
+		},
+	}
+	fakedbs.AddQueryPattern("select column_name, extra from information_schema.columns .*", columns)
+	fakedbs.AddQueryPattern("select \\* from sbtest.B1 .*", rows)
+	fakedbs.AddQueryPattern("delete from sbtest.B1 .*", &sqltypes.Result{RowsAffected: 2})
+	fakedbs.AddQueryPattern("insert into sbtest.B[0-9].*", &sqltypes.Result{RowsAffected: 1})
+	fakedbs.AddQueryPattern("XA .*", &sqltypes.Result{})
+
+	query := "update sbtest.B set id = id + 1000 where id in (1, 2)"
+	node, err := sqlparser.Parse(query)
+	assert.Nil(t, err)
+
+	execute := func(twopc bool, enable bool, max int) (*xcontext.ResultContext, error) {
+		plan := planner.NewUpdatePlan(log, database, query, node.(*sqlparser.Update), route)
+		if err := plan.Build(); err != nil {
+			return nil, err
+		}
+		txn, err := scatter.CreateTransaction()
+		assert.Nil(t, err)
+		defer txn.Finish()
+		if twopc {
+			err := txn.Begin()
+			assert.Nil(t, err)
+		}
+		txn.SetShardKeyUpdate(enable)
+		txn.SetMaxShardKeyUpdateRows(max)
+
+		ctx := xcontext.NewResultContext()
+		if err := NewUpdateExecutor(log, plan, txn).Execute(ctx); err != nil {
+			return nil, err
+		}
+		if twopc {
+			err := txn.Commit()
+			assert.Nil(t, err)
+		}
+		return ctx, nil
+	}
+
+	// Not enabled by the session.
+	{
+		_, err := execute(true, false, 10)
+		assert.Equal(t, "unsupported: cannot.update.shard.key", err.Error())
+	}
+
+	// Not in the XA transaction.
+	{
+		_, err := execute(false, true, 10)
+		assert.Equal(t, "unsupported: cannot.update.shard.key.without.twopc", err.Error())
+	}
+
+	// Too many rows.
+	{
+		_, err := execute(true, true, 1)
+		assert.Equal(t, "unsupported: shard.key.update.row.count.exceeded.allowed.limit.of.'1'", err.Error())
+	}
+
+	// The rows are moved from B1 to B0 and B1.
+	{
+		ctx, err := execute(true, true, 10)
+		assert.Nil(t, err)
+		assert.Equal(t, uint64(2), ctx.Results.RowsAffected)
+		assert.Equal(t, 1, fakedbs.GetQueryCalledNum("delete from sbtest.B1 where sbtest.B1.id in (1, 2)"))
+		assert.Equal(t, 1, fakedbs.GetQueryCalledNum("insert into sbtest.B0(id, name) values (1002, 'b')"))
+		assert.Equal(t, 1, fakedbs.GetQueryCalledNum("insert into sbtest.B1(id, name) values (1001, 'a')"))
+	}
+}
+
+func TestUpdateExecutorMultiTables(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	database := "sbtest"
+
+	scatter, fakedbs, cleanup := backend.MockScatter(log, 10)
+	defer cleanup()
+
+	route, cleanup := router.MockNewRouter(log)
+	defer cleanup()
+
+	err := route.CreateDatabase(database)
+	assert.Nil(t, err)
+	err = route.AddForTest(database, router.MockTableMConfig(), router.MockTableBConfig())
+	assert.Nil(t, err)
+
+	// The primary key of A is (id, k).
+	pkFlags := uint32(querypb.MySqlFlag_PRI_KEY_FLAG | querypb.MySqlFlag_NOT_NULL_FLAG)
+	fieldsA := &sqltypes.Result{
+		Fields: []*querypb.Field{
+			{Name: "id", Table: "A", Type: querypb.Type_INT32, Flags: pkFlags},
+			{Name: "k", Table: "A", Type: querypb.Type_INT32, Flags: pkFlags},
+			{Name: "name", Table: "A", Type: querypb.Type_VARCHAR},
+		},
+	}
+	// Each shard of A returns the rows (1, 10, 'x'), (1, 11, 'y') and (3, 30, 'x'), B returns 'x'.
+	rowsA := &sqltypes.Result{
+		Fields: []*querypb.Field{
+			{Name: "id", Table: "A", Type: querypb.Type_INT32},
+			{Name: "k", Table: "A", Type: querypb.Type_INT32},
+			{Name: "z", Type: querypb.Type_VARCHAR},
+			{Name: "name", Table: "A", Type: querypb.Type_VARCHAR},
+		},
+	}
+	for _, row := range [][]string{{"1", "10", "x"}, {"1", "11", "y"}, {"3", "30", "x"}} {
+		rowsA.Rows = append(rowsA.Rows, []sqltypes.Value{
+			sqltypes.MakeTrusted(querypb.Type_INT32, []byte(row[0])),
+			sqltypes.MakeTrusted(querypb.Type_INT32, []byte(row[1])),
+			sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("z")),
+			sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(row[2])),
+		})
+	}
+	rowsB := &sqltypes.Result{
+		Fields: []*querypb.Field{{Name: "name", Table: "B", Type: querypb.Type_VARCHAR}},
+		Rows:   [][]sqltypes.Value{{sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("x"))}},
+	}
+	fakedbs.AddQueryPattern("select \\* from .* where 1 != 1", fieldsA)
+	fakedbs.AddQueryPattern("select A.id, A.k, 'z', A.name from sbtest.A[0-9]+ as A.*", rowsA)
+	fakedbs.AddQueryPattern("select B.name from sbtest.B1 as B.*", rowsB)
+	fakedbs.AddQueryPattern("update sbtest.A[0-9]+ set .*", &sqltypes.Result{RowsAffected: 1})
+
+	query := "update A join B on A.name = B.name set A.name = 'z' where B.id = 1"
+	node, err := xparser.Parse(query)
+	assert.Nil(t, err)
+	plan := planner.NewMultiUpdatePlan(log, database, query, node.(*xparser.MultiUpdate), route)
+	err = plan.Build()
+	assert.Nil(t, err)
+
+	txn, err := scatter.CreateTransaction()
+	assert.Nil(t, err)
+	defer txn.Finish()
+	txn.SetMaxJoinRows(1000)
+	executor := NewUpdateExecutor(log, plan, txn)
+	ctx := xcontext.NewResultContext()
+	err = executor.Execute(ctx)
+	assert.Nil(t, err)
+
+	// The row (1, 11) sharing the shard key with the matched row is kept.
+	var got []string
+	for _, query := range plan.Querys {
+		got = append(got, query.Query)
+	}
+	want := []string{
+		"update sbtest.A6 set name = 'z' where id = 1 and k in (10)",
+		"update sbtest.A6 set name = 'z' where id = 3 and k in (30)",
+	}
+	assert.Equal(t, want, got)
+	assert.Equal(t, uint64(2), ctx.Results.RowsAffected)
+}
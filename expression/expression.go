@@ -0,0 +1,788 @@
+/*
+ * NeoDB
+ *
+ * Copyright 2021-2030 The NeoDB Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+// Package expression compiles the sqlparser expressions to the evaluators,
+// which compute the expressions on the rows merged in the proxy.
+package expression
+
+import (
+	"bytes"
+	"encoding/hex"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/pkg/errors"
+	"github.com/sealdb/mysqlstack/sqlparser"
+	"github.com/sealdb/mysqlstack/sqlparser/depends/sqltypes"
+)
+
+var (
+	_ Evaluator = &constantExpr{}
+	_ Evaluator = &columnExpr{}
+	_ Evaluator = &andExpr{}
+	_ Evaluator = &orExpr{}
+	_ Evaluator = &notExpr{}
+	_ Evaluator = &compareExpr{}
+	_ Evaluator = &inExpr{}
+	_ Evaluator = &likeExpr{}
+	_ Evaluator = &regexpExpr{}
+	_ Evaluator = &isExpr{}
+	_ Evaluator = &betweenExpr{}
+	_ Evaluator = &arithExpr{}
+	_ Evaluator = &unaryExpr{}
+	_ Evaluator = &caseExpr{}
+)
+
+// Evaluator evaluates the expression on a row.
+type Evaluator interface {
+	Eval(row []sqltypes.Value) (sqltypes.Value, error)
+}
+
+// Resolver returns the index of the expr in the row, false if the expr is not
+// a column of the row and it need be computed from its sub expressions.
+type Resolver func(expr sqlparser.Expr) (int, bool)
+
+// Compile compiles the expr to the Evaluator, the columns and the aggregates
+// must be resolved to the indexes of the row by the resolver.
+func Compile(expr sqlparser.Expr, resolve Resolver) (Evaluator, error) {
+	return compile(expr, resolve)
+}
+
+// IsTrue evaluates the expr as a condition on the row, the NULL is not true.
+func IsTrue(e Evaluator, row []sqltypes.Value) (bool, error) {
+	b, err := evalBool(e, row)
+	return b == boolTrue, err
+}
+
+func compile(expr sqlparser.Expr, resolve Resolver) (Evaluator, error) {
+	if idx, ok := resolve(expr); ok {
+		return &columnExpr{idx}, nil
+	}
+
+	compileAll := func(exprs ...sqlparser.Expr) ([]Evaluator, error) {
+		evals := make([]Evaluator, 0, len(exprs))
+		for _, e := range exprs {
+			eval, err := compile(e, resolve)
+			if err != nil {
+				return nil, err
+			}
+			evals = append(evals, eval)
+		}
+		return evals, nil
+	}
+
+	switch e := expr.(type) {
+	case *sqlparser.ParenExpr:
+		return compile(e.Expr, resolve)
+	case *sqlparser.CollateExpr:
+		return compile(e.Expr, resolve)
+	case *sqlparser.SQLVal:
+		return compileSQLVal(e)
+	case *sqlparser.NullVal:
+		return &constantExpr{sqltypes.NULL}, nil
+	case sqlparser.BoolVal:
+		return &constantExpr{boolValue(bool(e))}, nil
+	case *sqlparser.AndExpr:
+		evals, err := compileAll(e.Left, e.Right)
+		if err != nil {
+			return nil, err
+		}
+		return &andExpr{evals[0], evals[1]}, nil
+	case *sqlparser.OrExpr:
+		evals, err := compileAll(e.Left, e.Right)
+		if err != nil {
+			return nil, err
+		}
+		return &orExpr{evals[0], evals[1]}, nil
+	case *sqlparser.NotExpr:
+		eval, err := compile(e.Expr, resolve)
+		if err != nil {
+			return nil, err
+		}
+		return &notExpr{eval}, nil
+	case *sqlparser.ComparisonExpr:
+		return compileComparison(e, resolve)
+	case *sqlparser.RangeCond:
+		evals, err := compileAll(e.Left, e.From, e.To)
+		if err != nil {
+			return nil, err
+		}
+		return &betweenExpr{not: e.Operator == sqlparser.NotBetweenStr, left: evals[0], from: evals[1], to: evals[2]}, nil
+	case *sqlparser.IsExpr:
+		eval, err := compile(e.Expr, resolve)
+		if err != nil {
+			return nil, err
+		}
+		return &isExpr{e.Operator, eval}, nil
+	case *sqlparser.BinaryExpr:
+		if interval, ok := e.Right.(*sqlparser.IntervalExpr); ok && (e.Operator == sqlparser.PlusStr || e.Operator == sqlparser.MinusStr) {
+			return compileDateAdd(e.Left, interval, e.Operator == sqlparser.MinusStr, resolve)
+		}
+		if interval, ok := e.Left.(*sqlparser.IntervalExpr); ok && e.Operator == sqlparser.PlusStr {
+			return compileDateAdd(e.Right, interval, false, resolve)
+		}
+		switch e.Operator {
+		case sqlparser.JSONExtractOp, sqlparser.JSONUnquoteExtractOp:
+			return nil, errors.Errorf("unsupported: expr.'%s'.cannot.be.evaluated", sqlparser.String(e))
+		}
+		evals, err := compileAll(e.Left, e.Right)
+		if err != nil {
+			return nil, err
+		}
+		return &arithExpr{op: e.Operator, left: evals[0], right: evals[1], text: sqlparser.String(e)}, nil
+	case *sqlparser.UnaryExpr:
+		eval, err := compile(e.Expr, resolve)
+		if err != nil {
+			return nil, err
+		}
+		if e.Operator == sqlparser.BangStr {
+			return &notExpr{eval}, nil
+		}
+		return &unaryExpr{e.Operator, eval}, nil
+	case *sqlparser.CaseExpr:
+		c := &caseExpr{}
+		var err error
+		if e.Expr != nil {
+			if c.value, err = compile(e.Expr, resolve); err != nil {
+				return nil, err
+			}
+		}
+		for _, when := range e.Whens {
+			evals, err := compileAll(when.Cond, when.Val)
+			if err != nil {
+				return nil, err
+			}
+			c.conds = append(c.conds, evals[0])
+			c.vals = append(c.vals, evals[1])
+		}
+		if e.Else != nil {
+			if c.elseVal, err = compile(e.Else, resolve); err != nil {
+				return nil, err
+			}
+		}
+		return c, nil
+	case *sqlparser.FuncExpr:
+		return compileFunc(e, resolve)
+	case *sqlparser.ConvertExpr:
+		return compileConvert(e, resolve)
+	case *sqlparser.ColName:
+		return nil, errors.Errorf("unsupported: unknown.column.'%s'.in.expression", sqlparser.String(e))
+	}
+	return nil, errors.Errorf("unsupported: expr.'%s'.cannot.be.evaluated", sqlparser.String(expr))
+}
+
+func compileSQLVal(val *sqlparser.SQLVal) (Evaluator, error) {
+	switch val.Type {
+	case sqlparser.StrVal:
+		return &constantExpr{sqltypes.MakeTrusted(sqltypes.VarChar, val.Val)}, nil
+	case sqlparser.IntVal:
+		if v, err := sqltypes.NewIntegral(string(val.Val)); err == nil {
+			return &constantExpr{v}, nil
+		}
+		return &constantExpr{sqltypes.MakeTrusted(sqltypes.Decimal, val.Val)}, nil
+	case sqlparser.FloatVal:
+		// The literal with the exponent is the approximate-value, others are exact-value.
+		if bytes.ContainsAny(val.Val, "eE") {
+			f, err := strconv.ParseFloat(string(val.Val), 64)
+			if err != nil {
+				return nil, errors.Errorf("unsupported: invalid.number.'%s'", val.Val)
+			}
+			return &constantExpr{sqltypes.NewFloat64(f)}, nil
+		}
+		n, ok := newDecimal(string(val.Val))
+		if !ok {
+			return nil, errors.Errorf("unsupported: invalid.number.'%s'", val.Val)
+		}
+		return &constantExpr{n.value()}, nil
+	case sqlparser.HexNum:
+		u, err := strconv.ParseUint(string(val.Val[2:]), 16, 64)
+		if err != nil {
+			return nil, errors.Errorf("unsupported: invalid.number.'%s'", val.Val)
+		}
+		return &constantExpr{sqltypes.NewUint64(u)}, nil
+	case sqlparser.HexVal:
+		b, err := hex.DecodeString(string(val.Val))
+		if err != nil {
+			return nil, errors.Errorf("unsupported: invalid.hex.value.'%s'", val.Val)
+		}
+		return &constantExpr{sqltypes.MakeTrusted(sqltypes.VarBinary, b)}, nil
+	}
+	return nil, errors.Errorf("unsupported: expr.'%s'.cannot.be.evaluated", sqlparser.String(val))
+}
+
+func compileComparison(e *sqlparser.ComparisonExpr, resolve Resolver) (Evaluator, error) {
+	left, err := compile(e.Left, resolve)
+	if err != nil {
+		return nil, err
+	}
+
+	switch e.Operator {
+	case sqlparser.InStr, sqlparser.NotInStr:
+		tuple, ok := e.Right.(sqlparser.ValTuple)
+		if !ok {
+			return nil, errors.Errorf("unsupported: expr.'%s'.cannot.be.evaluated", sqlparser.String(e))
+		}
+		in := &inExpr{not: e.Operator == sqlparser.NotInStr, left: left}
+		for _, expr := range tuple {
+			eval, err := compile(expr, resolve)
+			if err != nil {
+				return nil, err
+			}
+			in.list = append(in.list, eval)
+		}
+		return in, nil
+	case sqlparser.JSONExtractOp, sqlparser.JSONUnquoteExtractOp:
+		return nil, errors.Errorf("unsupported: expr.'%s'.cannot.be.evaluated", sqlparser.String(e))
+	}
+
+	right, err := compile(e.Right, resolve)
+	if err != nil {
+		return nil, err
+	}
+	switch e.Operator {
+	case sqlparser.LikeStr, sqlparser.NotLikeStr:
+		like := &likeExpr{not: e.Operator == sqlparser.NotLikeStr, left: left, pattern: right}
+		if e.Escape != nil {
+			if like.escape, err = compile(e.Escape, resolve); err != nil {
+				return nil, err
+			}
+		}
+		return like, nil
+	case sqlparser.RegexpStr, sqlparser.NotRegexpStr:
+		re := &regexpExpr{not: e.Operator == sqlparser.NotRegexpStr, left: left, pattern: right}
+		if c, ok := right.(*constantExpr); ok && !c.val.IsNull() {
+			if re.re, err = regexp.Compile("(?i)" + c.val.ToString()); err != nil {
+				return nil, errors.Errorf("unsupported: invalid.regexp.'%s'", c.val.ToString())
+			}
+		}
+		return re, nil
+	}
+	return &compareExpr{op: e.Operator, left: left, right: right}, nil
+}
+
+// constantExpr is the literal value.
+type constantExpr struct {
+	val sqltypes.Value
+}
+
+// Eval implements the Evaluator interface.
+func (e *constantExpr) Eval(row []sqltypes.Value) (sqltypes.Value, error) {
+	return e.val, nil
+}
+
+// columnExpr is the column of the row.
+type columnExpr struct {
+	idx int
+}
+
+// Eval implements the Evaluator interface.
+func (e *columnExpr) Eval(row []sqltypes.Value) (sqltypes.Value, error) {
+	if e.idx >= len(row) {
+		return sqltypes.NULL, errors.Errorf("expression.column.index[%d].out.of.range[%d]", e.idx, len(row))
+	}
+	return row[e.idx], nil
+}
+
+// andExpr is the three-valued logical AND.
+type andExpr struct {
+	left, right Evaluator
+}
+
+// Eval implements the Evaluator interface.
+func (e *andExpr) Eval(row []sqltypes.Value) (sqltypes.Value, error) {
+	l, err := evalBool(e.left, row)
+	if err != nil || l == boolFalse {
+		return l.value(), err
+	}
+	r, err := evalBool(e.right, row)
+	if err != nil || r == boolFalse {
+		return r.value(), err
+	}
+	if l == boolNull || r == boolNull {
+		return sqltypes.NULL, nil
+	}
+	return boolValue(true), nil
+}
+
+// orExpr is the three-valued logical OR.
+type orExpr struct {
+	left, right Evaluator
+}
+
+// Eval implements the Evaluator interface.
+func (e *orExpr) Eval(row []sqltypes.Value) (sqltypes.Value, error) {
+	l, err := evalBool(e.left, row)
+	if err != nil || l == boolTrue {
+		return l.value(), err
+	}
+	r, err := evalBool(e.right, row)
+	if err != nil || r == boolTrue {
+		return r.value(), err
+	}
+	if l == boolNull || r == boolNull {
+		return sqltypes.NULL, nil
+	}
+	return boolValue(false), nil
+}
+
+// notExpr is the logical NOT.
+type notExpr struct {
+	expr Evaluator
+}
+
+// Eval implements the Evaluator interface.
+func (e *notExpr) Eval(row []sqltypes.Value) (sqltypes.Value, error) {
+	b, err := evalBool(e.expr, row)
+	if err != nil {
+		return sqltypes.NULL, err
+	}
+	switch b {
+	case boolTrue:
+		return boolValue(false), nil
+	case boolFalse:
+		return boolValue(true), nil
+	}
+	return sqltypes.NULL, nil
+}
+
+// compareExpr is the comparison, such as: '=', '<', '<=>'.
+type compareExpr struct {
+	op          string
+	left, right Evaluator
+}
+
+// Eval implements the Evaluator interface.
+func (e *compareExpr) Eval(row []sqltypes.Value) (sqltypes.Value, error) {
+	l, err := e.left.Eval(row)
+	if err != nil {
+		return sqltypes.NULL, err
+	}
+	r, err := e.right.Eval(row)
+	if err != nil {
+		return sqltypes.NULL, err
+	}
+
+	if e.op == sqlparser.NullSafeEqualStr {
+		if l.IsNull() || r.IsNull() {
+			return boolValue(l.IsNull() && r.IsNull()), nil
+		}
+		return boolValue(compareValues(l, r) == 0), nil
+	}
+	if l.IsNull() || r.IsNull() {
+		return sqltypes.NULL, nil
+	}
+
+	cmp := compareValues(l, r)
+	switch e.op {
+	case sqlparser.EqualStr:
+		return boolValue(cmp == 0), nil
+	case sqlparser.NotEqualStr:
+		return boolValue(cmp != 0), nil
+	case sqlparser.LessThanStr:
+		return boolValue(cmp < 0), nil
+	case sqlparser.LessEqualStr:
+		return boolValue(cmp <= 0), nil
+	case sqlparser.GreaterThanStr:
+		return boolValue(cmp > 0), nil
+	case sqlparser.GreaterEqualStr:
+		return boolValue(cmp >= 0), nil
+	}
+	return sqltypes.NULL, errors.Errorf("unsupported: operator.'%s'", e.op)
+}
+
+// inExpr is the IN or NOT IN list.
+type inExpr struct {
+	not  bool
+	left Evaluator
+	list []Evaluator
+}
+
+// Eval implements the Evaluator interface.
+func (e *inExpr) Eval(row []sqltypes.Value) (sqltypes.Value, error) {
+	l, err := e.left.Eval(row)
+	if err != nil || l.IsNull() {
+		return sqltypes.NULL, err
+	}
+
+	hasNull := false
+	for _, item := range e.list {
+		v, err := item.Eval(row)
+		if err != nil {
+			return sqltypes.NULL, err
+		}
+		if v.IsNull() {
+			hasNull = true
+			continue
+		}
+		if compareValues(l, v) == 0 {
+			return boolValue(!e.not), nil
+		}
+	}
+	if hasNull {
+		return sqltypes.NULL, nil
+	}
+	return boolValue(e.not), nil
+}
+
+// likeExpr is the LIKE or NOT LIKE pattern matching, the matching is case-insensitive.
+type likeExpr struct {
+	not                   bool
+	left, pattern, escape Evaluator
+}
+
+// Eval implements the Evaluator interface.
+func (e *likeExpr) Eval(row []sqltypes.Value) (sqltypes.Value, error) {
+	vals, null, err := evalAll(row, e.left, e.pattern)
+	if err != nil || null {
+		return sqltypes.NULL, err
+	}
+
+	escape := '\\'
+	if e.escape != nil {
+		v, err := e.escape.Eval(row)
+		if err != nil {
+			return sqltypes.NULL, err
+		}
+		runes := []rune(v.ToString())
+		switch len(runes) {
+		case 0:
+			escape = -1
+		case 1:
+			escape = runes[0]
+		default:
+			return sqltypes.NULL, errors.New("Incorrect arguments to ESCAPE")
+		}
+	}
+	matched := matchLike([]rune(vals[0].ToString()), []rune(vals[1].ToString()), escape)
+	return boolValue(matched != e.not), nil
+}
+
+// matchLike matches the string with the LIKE pattern, '%' matches any
+// number of characters and '_' matches exactly one character.
+func matchLike(str, pattern []rune, escape rune) bool {
+	s, p := 0, 0
+	starP, starS := -1, -1
+	for s < len(str) {
+		if p < len(pattern) {
+			c := pattern[p]
+			switch {
+			case c == escape && p+1 < len(pattern):
+				if equalFoldRune(pattern[p+1], str[s]) {
+					p += 2
+					s++
+					continue
+				}
+			case c == '%':
+				starP, starS = p, s
+				p++
+				continue
+			case c == '_':
+				p++
+				s++
+				continue
+			default:
+				if equalFoldRune(c, str[s]) {
+					p++
+					s++
+					continue
+				}
+			}
+		}
+		if starP < 0 {
+			return false
+		}
+		starS++
+		s, p = starS, starP+1
+	}
+	for p < len(pattern) && pattern[p] == '%' {
+		p++
+	}
+	return p == len(pattern)
+}
+
+func equalFoldRune(a, b rune) bool {
+	return a == b || unicode.ToLower(a) == unicode.ToLower(b)
+}
+
+// regexpExpr is the REGEXP or NOT REGEXP matching, the matching is case-insensitive.
+type regexpExpr struct {
+	not           bool
+	left, pattern Evaluator
+	// re is the pre-compiled pattern if the pattern is a constant.
+	re *regexp.Regexp
+}
+
+// Eval implements the Evaluator interface.
+func (e *regexpExpr) Eval(row []sqltypes.Value) (sqltypes.Value, error) {
+	vals, null, err := evalAll(row, e.left, e.pattern)
+	if err != nil || null {
+		return sqltypes.NULL, err
+	}
+
+	re := e.re
+	if re == nil {
+		if re, err = regexp.Compile("(?i)" + vals[1].ToString()); err != nil {
+			return sqltypes.NULL, errors.Errorf("unsupported: invalid.regexp.'%s'", vals[1].ToString())
+		}
+	}
+	return boolValue(re.MatchString(vals[0].ToString()) != e.not), nil
+}
+
+// isExpr is the IS [NOT] NULL/TRUE/FALSE test.
+type isExpr struct {
+	op   string
+	expr Evaluator
+}
+
+// Eval implements the Evaluator interface.
+func (e *isExpr) Eval(row []sqltypes.Value) (sqltypes.Value, error) {
+	b, err := evalBool(e.expr, row)
+	if err != nil {
+		return sqltypes.NULL, err
+	}
+	switch e.op {
+	case sqlparser.IsNullStr:
+		return boolValue(b == boolNull), nil
+	case sqlparser.IsNotNullStr:
+		return boolValue(b != boolNull), nil
+	case sqlparser.IsTrueStr:
+		return boolValue(b == boolTrue), nil
+	case sqlparser.IsNotTrueStr:
+		return boolValue(b != boolTrue), nil
+	case sqlparser.IsFalseStr:
+		return boolValue(b == boolFalse), nil
+	case sqlparser.IsNotFalseStr:
+		return boolValue(b != boolFalse), nil
+	}
+	return sqltypes.NULL, errors.Errorf("unsupported: operator.'%s'", e.op)
+}
+
+// betweenExpr is the [NOT] BETWEEN range.
+type betweenExpr struct {
+	not            bool
+	left, from, to Evaluator
+}
+
+// Eval implements the Evaluator interface.
+func (e *betweenExpr) Eval(row []sqltypes.Value) (sqltypes.Value, error) {
+	vals, err := evalValues(row, e.left, e.from, e.to)
+	if err != nil || vals[0].IsNull() {
+		return sqltypes.NULL, err
+	}
+
+	// left >= from AND left <= to.
+	ge, le := boolNull, boolNull
+	if !vals[1].IsNull() {
+		ge = toTriBool(compareValues(vals[0], vals[1]) >= 0)
+	}
+	if !vals[2].IsNull() {
+		le = toTriBool(compareValues(vals[0], vals[2]) <= 0)
+	}
+	var res triBool
+	switch {
+	case ge == boolFalse || le == boolFalse:
+		res = boolFalse
+	case ge == boolNull || le == boolNull:
+		return sqltypes.NULL, nil
+	default:
+		res = boolTrue
+	}
+	if e.not {
+		return boolValue(res == boolFalse), nil
+	}
+	return res.value(), nil
+}
+
+// arithExpr is the binary arithmetic or bit operator.
+type arithExpr struct {
+	op          string
+	left, right Evaluator
+	// text is used in the out of range error.
+	text string
+}
+
+// Eval implements the Evaluator interface.
+func (e *arithExpr) Eval(row []sqltypes.Value) (sqltypes.Value, error) {
+	vals, null, err := evalAll(row, e.left, e.right)
+	if err != nil || null {
+		return sqltypes.NULL, err
+	}
+
+	res, null, err := arithmetic(e.op, newNumeric(vals[0]), newNumeric(vals[1]), e.text)
+	if err != nil || null {
+		return sqltypes.NULL, err
+	}
+	return res.value(), nil
+}
+
+// unaryExpr is the unary operator, such as: '-', '~'.
+type unaryExpr struct {
+	op   string
+	expr Evaluator
+}
+
+// Eval implements the Evaluator interface.
+func (e *unaryExpr) Eval(row []sqltypes.Value) (sqltypes.Value, error) {
+	v, err := e.expr.Eval(row)
+	if err != nil || v.IsNull() {
+		return sqltypes.NULL, err
+	}
+
+	switch e.op {
+	case sqlparser.UMinusStr:
+		return negate(newNumeric(v)).value(), nil
+	case sqlparser.UPlusStr:
+		return v, nil
+	case sqlparser.TildaStr:
+		return sqltypes.NewUint64(^newNumeric(v).toUint64()), nil
+	case sqlparser.BinaryStr:
+		return sqltypes.MakeTrusted(sqltypes.VarBinary, v.Raw()), nil
+	}
+	return sqltypes.NULL, errors.Errorf("unsupported: operator.'%s'", e.op)
+}
+
+// caseExpr is the CASE expression, the value is nil if there's no CASE value.
+type caseExpr struct {
+	value   Evaluator
+	conds   []Evaluator
+	vals    []Evaluator
+	elseVal Evaluator
+}
+
+// Eval implements the Evaluator interface.
+func (e *caseExpr) Eval(row []sqltypes.Value) (sqltypes.Value, error) {
+	var value sqltypes.Value
+	if e.value != nil {
+		var err error
+		if value, err = e.value.Eval(row); err != nil {
+			return sqltypes.NULL, err
+		}
+	}
+
+	for i, cond := range e.conds {
+		matched := false
+		if e.value != nil {
+			v, err := cond.Eval(row)
+			if err != nil {
+				return sqltypes.NULL, err
+			}
+			matched = !value.IsNull() && !v.IsNull() && compareValues(value, v) == 0
+		} else {
+			b, err := evalBool(cond, row)
+			if err != nil {
+				return sqltypes.NULL, err
+			}
+			matched = b == boolTrue
+		}
+		if matched {
+			return e.vals[i].Eval(row)
+		}
+	}
+
+	if e.elseVal != nil {
+		return e.elseVal.Eval(row)
+	}
+	return sqltypes.NULL, nil
+}
+
+// triBool is the three-valued logic result.
+type triBool int
+
+const (
+	boolNull triBool = iota
+	boolFalse
+	boolTrue
+)
+
+func toTriBool(b bool) triBool {
+	if b {
+		return boolTrue
+	}
+	return boolFalse
+}
+
+func (b triBool) value() sqltypes.Value {
+	switch b {
+	case boolTrue:
+		return boolValue(true)
+	case boolFalse:
+		return boolValue(false)
+	}
+	return sqltypes.NULL
+}
+
+func boolValue(b bool) sqltypes.Value {
+	if b {
+		return sqltypes.NewInt64(1)
+	}
+	return sqltypes.NewInt64(0)
+}
+
+// evalBool evaluates the expr as a condition, the non-zero number is true.
+func evalBool(e Evaluator, row []sqltypes.Value) (triBool, error) {
+	v, err := e.Eval(row)
+	if err != nil || v.IsNull() {
+		return boolNull, err
+	}
+	return toTriBool(!newNumeric(v).isZero()), nil
+}
+
+// evalValues evaluates the exprs.
+func evalValues(row []sqltypes.Value, exprs ...Evaluator) ([]sqltypes.Value, error) {
+	vals := make([]sqltypes.Value, len(exprs))
+	for i, e := range exprs {
+		v, err := e.Eval(row)
+		if err != nil {
+			return nil, err
+		}
+		vals[i] = v
+	}
+	return vals, nil
+}
+
+// evalAll evaluates the exprs, returns true if any of the values is NULL.
+func evalAll(row []sqltypes.Value, exprs ...Evaluator) ([]sqltypes.Value, bool, error) {
+	vals, err := evalValues(row, exprs...)
+	if err != nil {
+		return nil, false, err
+	}
+	return vals, hasNull(vals), nil
+}
+
+func hasNull(vals []sqltypes.Value) bool {
+	for _, v := range vals {
+		if v.IsNull() {
+			return true
+		}
+	}
+	return false
+}
+
+func isNumber(v sqltypes.Value) bool {
+	return v.IsIntegral() || v.IsFloat() || v.Type() == sqltypes.Decimal || v.Type() == sqltypes.Bit
+}
+
+// compareValues compares the two non-NULL values, returns -1, 0 or 1.
+// If any value is a number, the values are compared as the numbers,
+// the strings are compared case-insensitively and the trailing spaces
+// are ignored, same as the default collation.
+func compareValues(a, b sqltypes.Value) int {
+	switch {
+	case isNumber(a) || isNumber(b):
+		return compareNumeric(newNumeric(a), newNumeric(b))
+	case a.IsBinary() || b.IsBinary():
+		return bytes.Compare(a.Raw(), b.Raw())
+	}
+	return compareString(a.ToString(), b.ToString())
+}
+
+func compareString(a, b string) int {
+	return strings.Compare(strings.ToLower(strings.TrimRight(a, " ")), strings.ToLower(strings.TrimRight(b, " ")))
+}
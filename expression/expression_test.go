@@ -0,0 +1,220 @@
+/*
+ * NeoDB
+ *
+ * Copyright 2021-2030 The NeoDB Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package expression
+
+import (
+	"testing"
+
+	"github.com/sealdb/mysqlstack/sqlparser"
+	querypb "github.com/sealdb/mysqlstack/sqlparser/depends/query"
+	"github.com/sealdb/mysqlstack/sqlparser/depends/sqltypes"
+	"github.com/stretchr/testify/assert"
+)
+
+// testRow is the row used in the tests, the columns are a, b, c, d, e, f, g.
+var testRow = []sqltypes.Value{
+	sqltypes.NewInt64(3),
+	sqltypes.NewInt64(2),
+	sqltypes.MakeTrusted(sqltypes.Decimal, []byte("1.50")),
+	sqltypes.NULL,
+	sqltypes.NewVarChar("Hello"),
+	sqltypes.MakeTrusted(sqltypes.Datetime, []byte("2020-01-31 10:11:12")),
+	sqltypes.NewFloat64(2.5),
+}
+
+func testResolver(expr sqlparser.Expr) (int, bool) {
+	if col, ok := expr.(*sqlparser.ColName); ok {
+		name := col.Name.Lowered()
+		if len(name) == 1 && name[0] >= 'a' && name[0] <= 'g' {
+			return int(name[0] - 'a'), true
+		}
+	}
+	return -1, false
+}
+
+func testEval(t *testing.T, query string) (sqltypes.Value, error) {
+	node, err := sqlparser.Parse("select " + query + " from t")
+	assert.Nil(t, err, query)
+	expr := node.(*sqlparser.Select).SelectExprs[0].(*sqlparser.AliasedExpr).Expr
+	eval, err := Compile(expr, testResolver)
+	if err != nil {
+		return sqltypes.NULL, err
+	}
+	return eval.Eval(testRow)
+}
+
+func TestExpression(t *testing.T) {
+	tcases := []struct {
+		expr string
+		want string
+		typ  querypb.Type
+	}{
+		// Arithmetic.
+		{"a+b", "5", sqltypes.Int64},
+		{"a-b*2", "-1", sqltypes.Int64},
+		{"a/b", "1.5000", sqltypes.Decimal},
+		{"1/3", "0.3333", sqltypes.Decimal},
+		{"c/b", "0.750000", sqltypes.Decimal},
+		{"a*c", "4.50", sqltypes.Decimal},
+		{"a+c", "4.50", sqltypes.Decimal},
+		{"a+g", "5.5", sqltypes.Float64},
+		{"a div b", "1", sqltypes.Int64},
+		{"a % b", "1", sqltypes.Int64},
+		{"-a", "-3", sqltypes.Int64},
+		{"a & b", "2", sqltypes.Uint64},
+		{"a | b", "3", sqltypes.Uint64},
+		{"a << 2", "12", sqltypes.Uint64},
+		{"'3abc'+1", "4", sqltypes.Float64},
+		{"1.5e1+1", "16", sqltypes.Float64},
+		{"18446744073709551615+0", "18446744073709551615", sqltypes.Uint64},
+		// Comparison and logic.
+		{"a>b", "1", sqltypes.Int64},
+		{"a=3.0", "1", sqltypes.Int64},
+		{"e='hello'", "1", sqltypes.Int64},
+		{"e<'world'", "1", sqltypes.Int64},
+		{"a in (1,2,3)", "1", sqltypes.Int64},
+		{"a not in (1,2)", "1", sqltypes.Int64},
+		{"a between 1 and b", "0", sqltypes.Int64},
+		{"a not between 1 and b", "1", sqltypes.Int64},
+		{"e like 'h%o'", "1", sqltypes.Int64},
+		{"e like 'h_l%'", "1", sqltypes.Int64},
+		{"e not like '%x%'", "1", sqltypes.Int64},
+		{"'a%' like 'a!%' escape '!'", "1", sqltypes.Int64},
+		{"e regexp '^he'", "1", sqltypes.Int64},
+		{"a>1 and b>1", "1", sqltypes.Int64},
+		{"a>5 or b>1", "1", sqltypes.Int64},
+		{"d is null", "1", sqltypes.Int64},
+		{"a is true", "1", sqltypes.Int64},
+		{"not a", "0", sqltypes.Int64},
+		{"d<=>d", "1", sqltypes.Int64},
+		{"a<=>d", "0", sqltypes.Int64},
+		{"d and 0", "0", sqltypes.Int64},
+		{"d or 1", "1", sqltypes.Int64},
+		// Case.
+		{"case when a>b then 'x' else 'y' end", "x", sqltypes.VarChar},
+		{"case a when 1 then 'one' when 3 then 'three' end", "three", sqltypes.VarChar},
+		{"case when a<b then 'x' else c end", "1.50", sqltypes.Decimal},
+		// Cast.
+		{"cast(c as signed)", "2", sqltypes.Int64},
+		{"cast('1.5' as signed)", "1", sqltypes.Int64},
+		{"cast(-1 as unsigned)", "18446744073709551615", sqltypes.Uint64},
+		{"cast(a/b as decimal(10,1))", "1.5", sqltypes.Decimal},
+		{"cast(a as char)", "3", sqltypes.VarChar},
+		{"cast(f as date)", "2020-01-31", sqltypes.Date},
+	}
+
+	for _, tcase := range tcases {
+		got, err := testEval(t, tcase.expr)
+		assert.Nil(t, err, tcase.expr)
+		assert.Equal(t, tcase.want, got.ToString(), tcase.expr)
+		assert.Equal(t, tcase.typ, got.Type(), tcase.expr)
+	}
+}
+
+func TestExpressionNull(t *testing.T) {
+	exprs := []string{
+		"a+d",
+		"a/0",
+		"a div 0",
+		"a%0",
+		"d=1",
+		"d in (1,2)",
+		"a in (1,d)",
+		"d like 'x'",
+		"d between 1 and 2",
+		"a between d and 5",
+		"d and 1",
+		"d or 0",
+		"not d",
+		"case when d then 1 end",
+		"cast(d as signed)",
+	}
+
+	for _, expr := range exprs {
+		got, err := testEval(t, expr)
+		assert.Nil(t, err, expr)
+		assert.True(t, got.IsNull(), expr)
+	}
+}
+
+func TestExpressionError(t *testing.T) {
+	tcases := []struct {
+		expr string
+		err  string
+	}{
+		{"9223372036854775807+a", "BIGINT value is out of range in '9223372036854775807 + a'"},
+		{"cast(0 as unsigned)-1", "BIGINT UNSIGNED value is out of range in 'convert(0, unsigned) - 1'"},
+		{"x+1", "unsupported: unknown.column.'x'.in.expression"},
+		{"a->'$.x'", "unsupported: expr.'a -> '$.x''.cannot.be.evaluated"},
+		{"now()", "unsupported: function.'now'.cannot.be.evaluated"},
+		{"if(a,b)", "Incorrect parameter count in the call to native function 'if'"},
+		{"substring(e)", "Incorrect parameter count in the call to native function 'substring'"},
+		{"cast(a as json)", "unsupported: convert.type.'json'"},
+		{"date_add(f, interval 1 day_hour)", "unsupported: interval.unit.'day_hour'"},
+		{"'a' like 'b' escape 'xy'", "Incorrect arguments to ESCAPE"},
+	}
+
+	for _, tcase := range tcases {
+		_, err := testEval(t, tcase.expr)
+		assert.NotNil(t, err, tcase.expr)
+		if err != nil {
+			assert.Equal(t, tcase.err, err.Error(), tcase.expr)
+		}
+	}
+}
+
+func TestMatchLike(t *testing.T) {
+	tcases := []struct {
+		str, pattern string
+		want         bool
+	}{
+		{"abc", "abc", true},
+		{"abc", "ABC", true},
+		{"abc", "a%", true},
+		{"abc", "%c", true},
+		{"abc", "%b%", true},
+		{"abc", "a_c", true},
+		{"abc", "a_", false},
+		{"abc", "%%%", true},
+		{"", "%", true},
+		{"", "_", false},
+		{"a_c", "a\\_c", true},
+		{"abc", "a\\_c", false},
+		{"aXbXc", "a%b%c", true},
+		{"mississippi", "%iss%ppi", true},
+	}
+
+	for _, tcase := range tcases {
+		got := matchLike([]rune(tcase.str), []rune(tcase.pattern), '\\')
+		assert.Equal(t, tcase.want, got, tcase.str+" like "+tcase.pattern)
+	}
+}
+
+func TestIsTrue(t *testing.T) {
+	tcases := []struct {
+		expr string
+		want bool
+	}{
+		{"a>b", true},
+		{"a<b", false},
+		{"d>1", false},
+		{"c", true},
+		{"'abc'", false},
+	}
+
+	for _, tcase := range tcases {
+		node, err := sqlparser.Parse("select " + tcase.expr + " from t")
+		assert.Nil(t, err)
+		eval, err := Compile(node.(*sqlparser.Select).SelectExprs[0].(*sqlparser.AliasedExpr).Expr, testResolver)
+		assert.Nil(t, err)
+		got, err := IsTrue(eval, testRow)
+		assert.Nil(t, err)
+		assert.Equal(t, tcase.want, got, tcase.expr)
+	}
+}
@@ -0,0 +1,713 @@
+/*
+ * NeoDB
+ *
+ * Copyright 2021-2030 The NeoDB Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package expression
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+	"github.com/sealdb/mysqlstack/sqlparser"
+	"github.com/sealdb/mysqlstack/sqlparser/depends/sqltypes"
+	"github.com/shopspring/decimal"
+)
+
+var (
+	_ Evaluator = &funcExpr{}
+	_ Evaluator = &ifExpr{}
+	_ Evaluator = &coalesceExpr{}
+	_ Evaluator = &convertExpr{}
+)
+
+// builtinFunc computes the function on the evaluated arguments.
+type builtinFunc func(args []sqltypes.Value) (sqltypes.Value, error)
+
+// builtin is the function definition, maxArgs is -1 if the arguments are unlimited.
+type builtin struct {
+	minArgs, maxArgs int
+	// nullable is true if the function handles the NULL arguments,
+	// otherwise the result is NULL if any argument is NULL.
+	nullable bool
+	fn       builtinFunc
+}
+
+var builtins map[string]builtin
+
+func init() {
+	builtins = map[string]builtin{
+		// Control flow functions, IF/IFNULL/COALESCE are compiled lazily.
+		"nullif": {2, 2, true, nullIfFunc},
+		"isnull": {1, 1, true, isNullFunc},
+
+		// Comparison functions.
+		"greatest": {2, -1, false, greatestFunc},
+		"least":    {2, -1, false, leastFunc},
+		"strcmp":   {2, 2, false, strcmpFunc},
+
+		// Numeric functions.
+		"abs":      {1, 1, false, absFunc},
+		"ceil":     {1, 1, false, ceilFunc},
+		"ceiling":  {1, 1, false, ceilFunc},
+		"floor":    {1, 1, false, floorFunc},
+		"round":    {1, 2, false, roundFunc},
+		"truncate": {2, 2, false, truncateFunc},
+		"mod":      {2, 2, false, modFunc},
+		"sign":     {1, 1, false, signFunc},
+		"sqrt":     {1, 1, false, sqrtFunc},
+		"pow":      {2, 2, false, powFunc},
+		"power":    {2, 2, false, powFunc},
+
+		// String functions.
+		"concat":           {1, -1, false, concatFunc},
+		"concat_ws":        {2, -1, true, concatWsFunc},
+		"length":           {1, 1, false, lengthFunc},
+		"char_length":      {1, 1, false, charLengthFunc},
+		"character_length": {1, 1, false, charLengthFunc},
+		"upper":            {1, 1, false, upperFunc},
+		"ucase":            {1, 1, false, upperFunc},
+		"lower":            {1, 1, false, lowerFunc},
+		"lcase":            {1, 1, false, lowerFunc},
+		"substring":        {2, 3, false, substringFunc},
+		"substr":           {2, 3, false, substringFunc},
+		"mid":              {3, 3, false, substringFunc},
+		"left":             {2, 2, false, leftFunc},
+		"right":            {2, 2, false, rightFunc},
+		"trim":             {1, 1, false, trimFunc},
+		"ltrim":            {1, 1, false, ltrimFunc},
+		"rtrim":            {1, 1, false, rtrimFunc},
+		"replace":          {3, 3, false, replaceFunc},
+		"reverse":          {1, 1, false, reverseFunc},
+		"repeat":           {2, 2, false, repeatFunc},
+		"lpad":             {3, 3, false, lpadFunc},
+		"rpad":             {3, 3, false, rpadFunc},
+		"instr":            {2, 2, false, instrFunc},
+		"locate":           {2, 3, false, locateFunc},
+
+		// Date and time functions.
+		"date":        {1, 1, false, dateFunc},
+		"year":        {1, 1, false, yearFunc},
+		"quarter":     {1, 1, false, quarterFunc},
+		"month":       {1, 1, false, monthFunc},
+		"day":         {1, 1, false, dayFunc},
+		"dayofmonth":  {1, 1, false, dayFunc},
+		"dayofweek":   {1, 1, false, dayOfWeekFunc},
+		"weekday":     {1, 1, false, weekdayFunc},
+		"dayofyear":   {1, 1, false, dayOfYearFunc},
+		"hour":        {1, 1, false, hourFunc},
+		"minute":      {1, 1, false, minuteFunc},
+		"second":      {1, 1, false, secondFunc},
+		"date_format": {2, 2, false, dateFormatFunc},
+		"datediff":    {2, 2, false, dateDiffFunc},
+	}
+}
+
+// funcExpr is the builtin function call.
+type funcExpr struct {
+	name string
+	fn   builtin
+	args []Evaluator
+}
+
+// Eval implements the Evaluator interface.
+func (e *funcExpr) Eval(row []sqltypes.Value) (sqltypes.Value, error) {
+	args, null, err := evalAll(row, e.args...)
+	if err != nil || (null && !e.fn.nullable) {
+		return sqltypes.NULL, err
+	}
+	return e.fn.fn(args)
+}
+
+// ifExpr is the IF(cond, a, b) and IFNULL(a, b), only the returned branch is evaluated.
+type ifExpr struct {
+	cond, then, otherwise Evaluator
+}
+
+// Eval implements the Evaluator interface.
+func (e *ifExpr) Eval(row []sqltypes.Value) (sqltypes.Value, error) {
+	b, err := evalBool(e.cond, row)
+	if err != nil {
+		return sqltypes.NULL, err
+	}
+	if b == boolTrue {
+		return e.then.Eval(row)
+	}
+	return e.otherwise.Eval(row)
+}
+
+// coalesceExpr returns the first non-NULL value, also used by IFNULL.
+type coalesceExpr struct {
+	args []Evaluator
+}
+
+// Eval implements the Evaluator interface.
+func (e *coalesceExpr) Eval(row []sqltypes.Value) (sqltypes.Value, error) {
+	for _, arg := range e.args {
+		v, err := arg.Eval(row)
+		if err != nil || !v.IsNull() {
+			return v, err
+		}
+	}
+	return sqltypes.NULL, nil
+}
+
+func compileFunc(e *sqlparser.FuncExpr, resolve Resolver) (Evaluator, error) {
+	name := e.Name.Lowered()
+	if !e.Qualifier.IsEmpty() || e.Distinct {
+		return nil, errors.Errorf("unsupported: function.'%s'.cannot.be.evaluated", sqlparser.String(e))
+	}
+
+	var args []Evaluator
+	var interval *sqlparser.IntervalExpr
+	for _, expr := range e.Exprs {
+		aliased, ok := expr.(*sqlparser.AliasedExpr)
+		if !ok {
+			return nil, errors.Errorf("unsupported: function.'%s'.cannot.be.evaluated", sqlparser.String(e))
+		}
+		if i, ok := aliased.Expr.(*sqlparser.IntervalExpr); ok {
+			interval = i
+			continue
+		}
+		arg, err := compile(aliased.Expr, resolve)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+	}
+
+	switch name {
+	case "date_add", "adddate", "date_sub", "subdate":
+		if interval == nil || len(e.Exprs) != 2 {
+			return nil, errors.Errorf("unsupported: function.'%s'.cannot.be.evaluated", sqlparser.String(e))
+		}
+		return compileDateAdd(e.Exprs[0].(*sqlparser.AliasedExpr).Expr, interval, name == "date_sub" || name == "subdate", resolve)
+	}
+	if interval != nil {
+		return nil, errors.Errorf("unsupported: function.'%s'.cannot.be.evaluated", sqlparser.String(e))
+	}
+
+	argCountErr := errors.Errorf("Incorrect parameter count in the call to native function '%s'", name)
+	switch name {
+	case "if":
+		if len(args) != 3 {
+			return nil, argCountErr
+		}
+		return &ifExpr{args[0], args[1], args[2]}, nil
+	case "ifnull":
+		if len(args) != 2 {
+			return nil, argCountErr
+		}
+		return &coalesceExpr{args}, nil
+	case "coalesce":
+		if len(args) == 0 {
+			return nil, argCountErr
+		}
+		return &coalesceExpr{args}, nil
+	}
+
+	fn, ok := builtins[name]
+	if !ok {
+		return nil, errors.Errorf("unsupported: function.'%s'.cannot.be.evaluated", name)
+	}
+	if len(args) < fn.minArgs || (fn.maxArgs >= 0 && len(args) > fn.maxArgs) {
+		return nil, argCountErr
+	}
+	return &funcExpr{name: name, fn: fn, args: args}, nil
+}
+
+// convertExpr is the CAST(expr AS type) or CONVERT(expr, type).
+type convertExpr struct {
+	expr Evaluator
+	typ  string
+	// length and scale of the type, -1 if not set.
+	length, scale int
+}
+
+func compileConvert(e *sqlparser.ConvertExpr, resolve Resolver) (Evaluator, error) {
+	expr, err := compile(e.Expr, resolve)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &convertExpr{expr: expr, length: -1, scale: -1}
+	if fields := strings.Fields(strings.ToLower(e.Type.Type)); len(fields) > 0 {
+		c.typ = fields[0]
+	}
+	if e.Type.Length != nil {
+		if c.length, err = strconv.Atoi(string(e.Type.Length.Val)); err != nil {
+			return nil, errors.Errorf("unsupported: convert.type.'%s'", sqlparser.String(e.Type))
+		}
+	}
+	if e.Type.Scale != nil {
+		if c.scale, err = strconv.Atoi(string(e.Type.Scale.Val)); err != nil {
+			return nil, errors.Errorf("unsupported: convert.type.'%s'", sqlparser.String(e.Type))
+		}
+	}
+
+	switch c.typ {
+	case "signed", "unsigned", "char", "nchar", "binary", "decimal", "date", "datetime", "time", "double", "float", "real":
+		return c, nil
+	}
+	return nil, errors.Errorf("unsupported: convert.type.'%s'", sqlparser.String(e.Type))
+}
+
+// Eval implements the Evaluator interface.
+func (e *convertExpr) Eval(row []sqltypes.Value) (sqltypes.Value, error) {
+	v, err := e.expr.Eval(row)
+	if err != nil || v.IsNull() {
+		return sqltypes.NULL, err
+	}
+
+	switch e.typ {
+	case "signed", "unsigned":
+		n := newNumeric(v)
+		if !isNumber(v) && !v.IsTemporal() {
+			// CAST('1.5' AS SIGNED) is 1.
+			if i, ok := parseIntPrefix(v.ToString()); ok {
+				n = newIntNumeric(i)
+			}
+		}
+		if e.typ == "unsigned" {
+			return sqltypes.NewUint64(n.toUint64()), nil
+		}
+		if n.typ == uintNum {
+			return sqltypes.NewInt64(int64(n.uval)), nil
+		}
+		return sqltypes.NewInt64(n.toInt64()), nil
+	case "char", "nchar":
+		s := v.ToString()
+		if e.length >= 0 && utf8.RuneCountInString(s) > e.length {
+			s = string([]rune(s)[:e.length])
+		}
+		return sqltypes.NewVarChar(s), nil
+	case "binary":
+		b := v.Raw()
+		if e.length >= 0 && len(b) > e.length {
+			b = b[:e.length]
+		}
+		return sqltypes.MakeTrusted(sqltypes.VarBinary, b), nil
+	case "decimal":
+		length, scale := e.length, e.scale
+		if length < 0 {
+			length = 10
+		}
+		if scale < 0 {
+			scale = 0
+		}
+		d := newNumeric(v).toDecimal().Round(int32(scale))
+		// Clip to the max value of the DECIMAL(M,D).
+		max := decimal.New(1, int32(length-scale)).Sub(decimal.New(1, int32(-scale)))
+		if d.GreaterThan(max) {
+			d = max
+		} else if d.LessThan(max.Neg()) {
+			d = max.Neg()
+		}
+		return newDecimalNumeric(d, int32(scale)).value(), nil
+	case "double", "float", "real":
+		return sqltypes.NewFloat64(newNumeric(v).toFloat()), nil
+	case "date", "datetime", "time":
+		t, ok := parseTime(v)
+		if !ok {
+			return sqltypes.NULL, nil
+		}
+		switch e.typ {
+		case "date":
+			return formatDate(t), nil
+		case "time":
+			return sqltypes.MakeTrusted(sqltypes.Time, []byte(t.Format("15:04:05"))), nil
+		}
+		return formatDatetime(t), nil
+	}
+	return sqltypes.NULL, errors.Errorf("unsupported: convert.type.'%s'", e.typ)
+}
+
+func nullIfFunc(args []sqltypes.Value) (sqltypes.Value, error) {
+	if !args[0].IsNull() && !args[1].IsNull() && compareValues(args[0], args[1]) == 0 {
+		return sqltypes.NULL, nil
+	}
+	return args[0], nil
+}
+
+func isNullFunc(args []sqltypes.Value) (sqltypes.Value, error) {
+	return boolValue(args[0].IsNull()), nil
+}
+
+func greatestFunc(args []sqltypes.Value) (sqltypes.Value, error) {
+	res := args[0]
+	for _, arg := range args[1:] {
+		if compareValues(arg, res) > 0 {
+			res = arg
+		}
+	}
+	return res, nil
+}
+
+func leastFunc(args []sqltypes.Value) (sqltypes.Value, error) {
+	res := args[0]
+	for _, arg := range args[1:] {
+		if compareValues(arg, res) < 0 {
+			res = arg
+		}
+	}
+	return res, nil
+}
+
+func strcmpFunc(args []sqltypes.Value) (sqltypes.Value, error) {
+	return sqltypes.NewInt64(int64(compareString(args[0].ToString(), args[1].ToString()))), nil
+}
+
+func absFunc(args []sqltypes.Value) (sqltypes.Value, error) {
+	n := newNumeric(args[0])
+	if n.sign() < 0 {
+		n = negate(n)
+	}
+	return n.value(), nil
+}
+
+// integralResult converts the decimal integer to the BIGINT if it fits, such as the result of FLOOR/CEIL.
+func integralResult(d decimal.Decimal) sqltypes.Value {
+	b := d.BigInt()
+	if b.IsInt64() {
+		return sqltypes.NewInt64(b.Int64())
+	}
+	if b.IsUint64() {
+		return sqltypes.NewUint64(b.Uint64())
+	}
+	return newDecimalNumeric(d, 0).value()
+}
+
+func ceilFunc(args []sqltypes.Value) (sqltypes.Value, error) {
+	n := newNumeric(args[0])
+	switch n.typ {
+	case decimalNum:
+		return integralResult(n.dval.Ceil()), nil
+	case floatNum:
+		return sqltypes.NewFloat64(math.Ceil(n.fval)), nil
+	}
+	return n.value(), nil
+}
+
+func floorFunc(args []sqltypes.Value) (sqltypes.Value, error) {
+	n := newNumeric(args[0])
+	switch n.typ {
+	case decimalNum:
+		return integralResult(n.dval.Floor()), nil
+	case floatNum:
+		return sqltypes.NewFloat64(math.Floor(n.fval)), nil
+	}
+	return n.value(), nil
+}
+
+// roundDigits returns the digits argument of ROUND/TRUNCATE.
+func roundDigits(args []sqltypes.Value) int32 {
+	if len(args) < 2 {
+		return 0
+	}
+	d := newNumeric(args[1]).toInt64()
+	switch {
+	case d > maxDecimalScale:
+		return maxDecimalScale
+	case d < -maxDecimalScale:
+		return -maxDecimalScale
+	}
+	return int32(d)
+}
+
+func roundFunc(args []sqltypes.Value) (sqltypes.Value, error) {
+	n := newNumeric(args[0])
+	d := roundDigits(args)
+	switch n.typ {
+	case floatNum:
+		// The approximate-value is rounded to the nearest even, same as the C library.
+		shift := math.Pow(10, float64(d))
+		return sqltypes.NewFloat64(math.RoundToEven(n.fval*shift) / shift), nil
+	case intNum, uintNum:
+		if d >= 0 {
+			return n.value(), nil
+		}
+		r := n.toDecimal().Round(d)
+		res, err := integralNumeric(r.BigInt(), n.typ == uintNum, "round")
+		if err != nil {
+			return sqltypes.NULL, err
+		}
+		return res.value(), nil
+	}
+	scale := d
+	if scale < 0 {
+		scale = 0
+	}
+	return newDecimalNumeric(n.dval.Round(d), scale).value(), nil
+}
+
+func truncateFunc(args []sqltypes.Value) (sqltypes.Value, error) {
+	n := newNumeric(args[0])
+	d := roundDigits(args)
+	switch n.typ {
+	case floatNum:
+		shift := math.Pow(10, float64(d))
+		return sqltypes.NewFloat64(math.Trunc(n.fval*shift) / shift), nil
+	case intNum, uintNum:
+		if d >= 0 {
+			return n.value(), nil
+		}
+		r := n.toDecimal().Shift(d).Truncate(0).Shift(-d)
+		res, err := integralNumeric(r.BigInt(), n.typ == uintNum, "truncate")
+		if err != nil {
+			return sqltypes.NULL, err
+		}
+		return res.value(), nil
+	}
+	if d >= 0 {
+		return newDecimalNumeric(n.dval.Truncate(d), d).value(), nil
+	}
+	return newDecimalNumeric(n.dval.Shift(d).Truncate(0).Shift(-d), 0).value(), nil
+}
+
+func modFunc(args []sqltypes.Value) (sqltypes.Value, error) {
+	res, null, err := arithmetic(sqlparser.ModStr, newNumeric(args[0]), newNumeric(args[1]), "mod")
+	if err != nil || null {
+		return sqltypes.NULL, err
+	}
+	return res.value(), nil
+}
+
+func signFunc(args []sqltypes.Value) (sqltypes.Value, error) {
+	return sqltypes.NewInt64(int64(newNumeric(args[0]).sign())), nil
+}
+
+func sqrtFunc(args []sqltypes.Value) (sqltypes.Value, error) {
+	f := newNumeric(args[0]).toFloat()
+	if f < 0 {
+		return sqltypes.NULL, nil
+	}
+	return sqltypes.NewFloat64(math.Sqrt(f)), nil
+}
+
+func powFunc(args []sqltypes.Value) (sqltypes.Value, error) {
+	f := math.Pow(newNumeric(args[0]).toFloat(), newNumeric(args[1]).toFloat())
+	if math.IsInf(f, 0) || math.IsNaN(f) {
+		return sqltypes.NULL, errors.New("DOUBLE value is out of range in 'pow'")
+	}
+	return sqltypes.NewFloat64(f), nil
+}
+
+// stringResult builds the string result, the binary arguments make the binary result.
+func stringResult(s string, args ...sqltypes.Value) sqltypes.Value {
+	for _, arg := range args {
+		if arg.IsBinary() {
+			return sqltypes.MakeTrusted(sqltypes.VarBinary, []byte(s))
+		}
+	}
+	return sqltypes.NewVarChar(s)
+}
+
+func concatFunc(args []sqltypes.Value) (sqltypes.Value, error) {
+	var buf strings.Builder
+	for _, arg := range args {
+		buf.Write(arg.Raw())
+	}
+	return stringResult(buf.String(), args...), nil
+}
+
+func concatWsFunc(args []sqltypes.Value) (sqltypes.Value, error) {
+	if args[0].IsNull() {
+		return sqltypes.NULL, nil
+	}
+	var parts []string
+	for _, arg := range args[1:] {
+		if !arg.IsNull() {
+			parts = append(parts, arg.ToString())
+		}
+	}
+	return stringResult(strings.Join(parts, args[0].ToString()), args...), nil
+}
+
+func lengthFunc(args []sqltypes.Value) (sqltypes.Value, error) {
+	return sqltypes.NewInt64(int64(len(args[0].Raw()))), nil
+}
+
+func charLengthFunc(args []sqltypes.Value) (sqltypes.Value, error) {
+	if args[0].IsBinary() {
+		return lengthFunc(args)
+	}
+	return sqltypes.NewInt64(int64(utf8.RuneCount(args[0].Raw()))), nil
+}
+
+func upperFunc(args []sqltypes.Value) (sqltypes.Value, error) {
+	if args[0].IsBinary() {
+		return args[0], nil
+	}
+	return sqltypes.NewVarChar(strings.ToUpper(args[0].ToString())), nil
+}
+
+func lowerFunc(args []sqltypes.Value) (sqltypes.Value, error) {
+	if args[0].IsBinary() {
+		return args[0], nil
+	}
+	return sqltypes.NewVarChar(strings.ToLower(args[0].ToString())), nil
+}
+
+// substringFunc is the SUBSTRING(str, pos[, len]), the pos starts from 1 and
+// the negative pos counts from the end of the string.
+func substringFunc(args []sqltypes.Value) (sqltypes.Value, error) {
+	runes := []rune(args[0].ToString())
+	pos := newNumeric(args[1]).toInt64()
+	size := int64(len(runes))
+	switch {
+	case pos > 0:
+		pos--
+	case pos < 0:
+		pos += size
+	default:
+		return stringResult("", args[0]), nil
+	}
+	if pos < 0 || pos >= size {
+		return stringResult("", args[0]), nil
+	}
+
+	end := size
+	if len(args) == 3 {
+		length := newNumeric(args[2]).toInt64()
+		if length <= 0 {
+			return stringResult("", args[0]), nil
+		}
+		if length < size-pos {
+			end = pos + length
+		}
+	}
+	return stringResult(string(runes[pos:end]), args[0]), nil
+}
+
+func leftFunc(args []sqltypes.Value) (sqltypes.Value, error) {
+	runes := []rune(args[0].ToString())
+	n := newNumeric(args[1]).toInt64()
+	switch {
+	case n <= 0:
+		n = 0
+	case n > int64(len(runes)):
+		n = int64(len(runes))
+	}
+	return stringResult(string(runes[:n]), args[0]), nil
+}
+
+func rightFunc(args []sqltypes.Value) (sqltypes.Value, error) {
+	runes := []rune(args[0].ToString())
+	n := newNumeric(args[1]).toInt64()
+	switch {
+	case n <= 0:
+		n = 0
+	case n > int64(len(runes)):
+		n = int64(len(runes))
+	}
+	return stringResult(string(runes[int64(len(runes))-n:]), args[0]), nil
+}
+
+func trimFunc(args []sqltypes.Value) (sqltypes.Value, error) {
+	return stringResult(strings.Trim(args[0].ToString(), " "), args[0]), nil
+}
+
+func ltrimFunc(args []sqltypes.Value) (sqltypes.Value, error) {
+	return stringResult(strings.TrimLeft(args[0].ToString(), " "), args[0]), nil
+}
+
+func rtrimFunc(args []sqltypes.Value) (sqltypes.Value, error) {
+	return stringResult(strings.TrimRight(args[0].ToString(), " "), args[0]), nil
+}
+
+func replaceFunc(args []sqltypes.Value) (sqltypes.Value, error) {
+	s, from := args[0].ToString(), args[1].ToString()
+	if from == "" {
+		return stringResult(s, args...), nil
+	}
+	return stringResult(strings.ReplaceAll(s, from, args[2].ToString()), args...), nil
+}
+
+func reverseFunc(args []sqltypes.Value) (sqltypes.Value, error) {
+	runes := []rune(args[0].ToString())
+	for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+		runes[i], runes[j] = runes[j], runes[i]
+	}
+	return stringResult(string(runes), args[0]), nil
+}
+
+// maxRepeatLength limits the length of the REPEAT/LPAD/RPAD results.
+const maxRepeatLength = 64 * 1024 * 1024
+
+func repeatFunc(args []sqltypes.Value) (sqltypes.Value, error) {
+	s := args[0].ToString()
+	n := newNumeric(args[1]).toInt64()
+	if n <= 0 || s == "" {
+		return stringResult("", args[0]), nil
+	}
+	if n*int64(len(s)) > maxRepeatLength {
+		return sqltypes.NULL, nil
+	}
+	return stringResult(strings.Repeat(s, int(n)), args[0]), nil
+}
+
+func pad(args []sqltypes.Value, left bool) (sqltypes.Value, error) {
+	runes := []rune(args[0].ToString())
+	n := newNumeric(args[1]).toInt64()
+	padding := []rune(args[2].ToString())
+	if n < 0 || n > maxRepeatLength {
+		return sqltypes.NULL, nil
+	}
+	if n <= int64(len(runes)) {
+		return stringResult(string(runes[:n]), args[0]), nil
+	}
+	if len(padding) == 0 {
+		return sqltypes.NULL, nil
+	}
+
+	fill := make([]rune, 0, n-int64(len(runes)))
+	for int64(len(fill)) < n-int64(len(runes)) {
+		fill = append(fill, padding[len(fill)%len(padding)])
+	}
+	if left {
+		return stringResult(string(fill)+string(runes), args[0], args[2]), nil
+	}
+	return stringResult(string(runes)+string(fill), args[0], args[2]), nil
+}
+
+func lpadFunc(args []sqltypes.Value) (sqltypes.Value, error) {
+	return pad(args, true)
+}
+
+func rpadFunc(args []sqltypes.Value) (sqltypes.Value, error) {
+	return pad(args, false)
+}
+
+// locate returns the 1-based position of the first occurrence of the sub
+// in the str at or after the start, 0 if not found, case-insensitively.
+func locate(str, sub string, start int64) int64 {
+	runes := []rune(strings.ToLower(str))
+	subs := []rune(strings.ToLower(sub))
+	if start < 1 || start > int64(len(runes))+1 {
+		return 0
+	}
+	for i := start - 1; i+int64(len(subs)) <= int64(len(runes)); i++ {
+		if string(runes[i:i+int64(len(subs))]) == string(subs) {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+func instrFunc(args []sqltypes.Value) (sqltypes.Value, error) {
+	return sqltypes.NewInt64(locate(args[0].ToString(), args[1].ToString(), 1)), nil
+}
+
+func locateFunc(args []sqltypes.Value) (sqltypes.Value, error) {
+	start := int64(1)
+	if len(args) == 3 {
+		start = newNumeric(args[2]).toInt64()
+	}
+	return sqltypes.NewInt64(locate(args[1].ToString(), args[0].ToString(), start)), nil
+}
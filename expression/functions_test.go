@@ -0,0 +1,116 @@
+/*
+ * NeoDB
+ *
+ * Copyright 2021-2030 The NeoDB Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package expression
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFunctions(t *testing.T) {
+	tcases := []struct {
+		expr string
+		want string
+	}{
+		// Control flow functions.
+		{"if(a>b, 'yes', 'no')", "yes"},
+		{"if(d, 1, 2)", "2"},
+		{"ifnull(d, b)", "2"},
+		{"ifnull(a, 1/0)", "3"},
+		{"nullif(a, 3)", "NULL"},
+		{"nullif(a, b)", "3"},
+		{"coalesce(d, d, e)", "Hello"},
+		{"isnull(d)", "1"},
+		// Comparison functions.
+		{"greatest(a, b, 1)", "3"},
+		{"least(a, c, g)", "1.50"},
+		{"greatest(a, d)", "NULL"},
+		{"strcmp('abc', 'ABD')", "-1"},
+		// Numeric functions.
+		{"abs(-a)", "3"},
+		{"abs(-c)", "1.50"},
+		{"ceil(c)", "2"},
+		{"floor(-c)", "-2"},
+		{"ceil(g)", "3"},
+		{"round(c)", "2"},
+		{"round(2.345, 2)", "2.35"},
+		{"round(1234, -2)", "1200"},
+		{"round(g)", "2"},
+		{"truncate(2.345, 2)", "2.34"},
+		{"truncate(1234, -2)", "1200"},
+		{"mod(a, b)", "1"},
+		{"mod(a, 0)", "NULL"},
+		{"sign(-c)", "-1"},
+		{"sqrt(16)", "4"},
+		{"sqrt(-1)", "NULL"},
+		{"pow(b, 3)", "8"},
+		// String functions.
+		{"concat(e, ' ', a)", "Hello 3"},
+		{"concat(e, d)", "NULL"},
+		{"concat_ws(',', e, d, a)", "Hello,3"},
+		{"length('中文')", "6"},
+		{"char_length('中文')", "2"},
+		{"upper(e)", "HELLO"},
+		{"lower(e)", "hello"},
+		{"substring(e, 2)", "ello"},
+		{"substring(e, 2, 3)", "ell"},
+		{"substring(e, -3, 2)", "ll"},
+		{"substring(e, 0)", ""},
+		{"substr(e, 10)", ""},
+		{"mid(e, 1, 1)", "H"},
+		{"left(e, 2)", "He"},
+		{"right(e, 10)", "Hello"},
+		{"trim('  x  ')", "x"},
+		{"ltrim('  x  ')", "x  "},
+		{"rtrim('  x  ')", "  x"},
+		{"replace(e, 'l', 'L')", "HeLLo"},
+		{"reverse(e)", "olleH"},
+		{"repeat('ab', 3)", "ababab"},
+		{"lpad(e, 8, 'xy')", "xyxHello"},
+		{"rpad(e, 3, 'x')", "Hel"},
+		{"rpad(e, 7, '')", "NULL"},
+		{"instr(e, 'LL')", "3"},
+		{"locate('l', e, 4)", "4"},
+		{"locate('z', e)", "0"},
+		// Date and time functions.
+		{"date(f)", "2020-01-31"},
+		{"year(f)", "2020"},
+		{"quarter(f)", "1"},
+		{"month(f)", "1"},
+		{"day(f)", "31"},
+		{"dayofweek(f)", "6"},
+		{"weekday(f)", "4"},
+		{"dayofyear('2020-03-01')", "61"},
+		{"hour(f)", "10"},
+		{"minute(f)", "11"},
+		{"second(f)", "12"},
+		{"hour('23:59:58')", "23"},
+		{"year(20200102)", "2020"},
+		{"year('0000-00-00')", "NULL"},
+		{"date_format(f, '%Y/%m/%d %H:%i:%s')", "2020/01/31 10:11:12"},
+		{"date_format(f, '%W %M %D %y %h%p %j %%')", "Friday January 31st 20 10AM 031 %"},
+		{"datediff('2020-03-01', f)", "30"},
+		{"date_add(f, interval 1 month)", "2020-02-29 10:11:12"},
+		{"date_add('2020-01-31', interval 1 day)", "2020-02-01"},
+		{"date_sub('2020-01-31', interval 1 hour)", "2020-01-30 23:00:00"},
+		{"f + interval 1 year", "2021-01-31 10:11:12"},
+		{"f - interval 2 week", "2020-01-17 10:11:12"},
+	}
+
+	for _, tcase := range tcases {
+		got, err := testEval(t, tcase.expr)
+		assert.Nil(t, err, tcase.expr)
+		if got.IsNull() {
+			assert.Equal(t, tcase.want, "NULL", tcase.expr)
+			continue
+		}
+		assert.Equal(t, tcase.want, got.ToString(), tcase.expr)
+	}
+}
@@ -0,0 +1,549 @@
+/*
+ * NeoDB
+ *
+ * Copyright 2021-2030 The NeoDB Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package expression
+
+import (
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/sealdb/mysqlstack/sqlparser"
+	"github.com/sealdb/mysqlstack/sqlparser/depends/sqltypes"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	// divPrecisionIncrement is the scale increment of the '/' result, same as the MySQL default.
+	divPrecisionIncrement = 4
+	// maxDecimalScale is the max scale of the decimal results.
+	maxDecimalScale = 30
+)
+
+type numericType int
+
+const (
+	intNum numericType = iota
+	uintNum
+	decimalNum
+	floatNum
+)
+
+// numeric represents a number in the arithmetic, the decimal keeps the scale
+// so that the results have the same digits after the point as MySQL.
+type numeric struct {
+	typ   numericType
+	ival  int64
+	uval  uint64
+	fval  float64
+	dval  decimal.Decimal
+	scale int32
+}
+
+// newNumeric converts the value to the numeric, the strings are converted
+// by the longest numeric prefix as MySQL, such as: '12abc' is 12.
+func newNumeric(v sqltypes.Value) numeric {
+	raw := v.ToString()
+	switch {
+	case v.IsSigned():
+		if i, err := strconv.ParseInt(raw, 10, 64); err == nil {
+			return numeric{typ: intNum, ival: i}
+		}
+	case v.IsUnsigned():
+		if u, err := strconv.ParseUint(raw, 10, 64); err == nil {
+			return numeric{typ: uintNum, uval: u}
+		}
+	case v.IsFloat():
+		if f, err := strconv.ParseFloat(raw, 64); err == nil {
+			return numeric{typ: floatNum, fval: f}
+		}
+	case v.Type() == sqltypes.Decimal:
+		if n, ok := newDecimal(raw); ok {
+			return n
+		}
+	case v.Type() == sqltypes.Bit:
+		var u uint64
+		for _, b := range v.Raw() {
+			u = u<<8 | uint64(b)
+		}
+		return numeric{typ: uintNum, uval: u}
+	case v.IsTemporal():
+		// '2020-01-02 10:11:12' is 20200102101112 in the numeric context.
+		digits := strings.Map(func(r rune) rune {
+			if r == '-' || r == ':' || r == ' ' || r == 'T' {
+				return -1
+			}
+			return r
+		}, raw)
+		if n, ok := newDecimal(digits); ok {
+			if n.scale == 0 && n.dval.BigInt().IsInt64() {
+				return numeric{typ: intNum, ival: n.dval.IntPart()}
+			}
+			return n
+		}
+	}
+	return numeric{typ: floatNum, fval: parseFloatPrefix(raw)}
+}
+
+// newDecimal parses the string to the decimal numeric.
+func newDecimal(s string) (numeric, bool) {
+	d, err := decimal.NewFromString(s)
+	if err != nil {
+		return numeric{}, false
+	}
+	var scale int32
+	if i := strings.IndexByte(s, '.'); i >= 0 && !strings.ContainsAny(s, "eE") {
+		scale = int32(len(s) - i - 1)
+	}
+	return numeric{typ: decimalNum, dval: d, scale: scale}, true
+}
+
+// parseFloatPrefix parses the longest numeric prefix of the string, returns 0 if not found.
+func parseFloatPrefix(s string) float64 {
+	s = strings.TrimLeft(s, " \t\n")
+	end := 0
+	if end < len(s) && (s[end] == '+' || s[end] == '-') {
+		end++
+	}
+	digits := 0
+	for end < len(s) && s[end] >= '0' && s[end] <= '9' {
+		end++
+		digits++
+	}
+	if end < len(s) && s[end] == '.' {
+		end++
+		for end < len(s) && s[end] >= '0' && s[end] <= '9' {
+			end++
+			digits++
+		}
+	}
+	if digits == 0 {
+		return 0
+	}
+	if end < len(s) && (s[end] == 'e' || s[end] == 'E') {
+		exp := end + 1
+		if exp < len(s) && (s[exp] == '+' || s[exp] == '-') {
+			exp++
+		}
+		if exp < len(s) && s[exp] >= '0' && s[exp] <= '9' {
+			for exp < len(s) && s[exp] >= '0' && s[exp] <= '9' {
+				exp++
+			}
+			end = exp
+		}
+	}
+	f, _ := strconv.ParseFloat(s[:end], 64)
+	return f
+}
+
+// parseIntPrefix parses the longest integer prefix of the string, used by CAST(str AS SIGNED).
+func parseIntPrefix(s string) (int64, bool) {
+	s = strings.TrimLeft(s, " \t\n")
+	end := 0
+	if end < len(s) && (s[end] == '+' || s[end] == '-') {
+		end++
+	}
+	for end < len(s) && s[end] >= '0' && s[end] <= '9' {
+		end++
+	}
+	i, err := strconv.ParseInt(s[:end], 10, 64)
+	return i, err == nil
+}
+
+func newIntNumeric(i int64) numeric {
+	return numeric{typ: intNum, ival: i}
+}
+
+func newFloatNumeric(f float64) numeric {
+	return numeric{typ: floatNum, fval: f}
+}
+
+func newDecimalNumeric(d decimal.Decimal, scale int32) numeric {
+	if scale > maxDecimalScale {
+		scale = maxDecimalScale
+	}
+	return numeric{typ: decimalNum, dval: d, scale: scale}
+}
+
+// value converts the numeric to the sqltypes.Value.
+func (n numeric) value() sqltypes.Value {
+	switch n.typ {
+	case intNum:
+		return sqltypes.NewInt64(n.ival)
+	case uintNum:
+		return sqltypes.NewUint64(n.uval)
+	case decimalNum:
+		return sqltypes.MakeTrusted(sqltypes.Decimal, []byte(n.dval.StringFixed(n.scale)))
+	}
+	return sqltypes.NewFloat64(n.fval)
+}
+
+func (n numeric) toFloat() float64 {
+	switch n.typ {
+	case intNum:
+		return float64(n.ival)
+	case uintNum:
+		return float64(n.uval)
+	case decimalNum:
+		f, _ := n.dval.Float64()
+		return f
+	}
+	return n.fval
+}
+
+func (n numeric) toDecimal() decimal.Decimal {
+	switch n.typ {
+	case intNum:
+		return decimal.NewFromInt(n.ival)
+	case uintNum:
+		return decimal.NewFromBigInt(new(big.Int).SetUint64(n.uval), 0)
+	case decimalNum:
+		return n.dval
+	}
+	return decimal.NewFromFloat(n.fval)
+}
+
+func (n numeric) toBig() *big.Int {
+	switch n.typ {
+	case intNum:
+		return big.NewInt(n.ival)
+	case uintNum:
+		return new(big.Int).SetUint64(n.uval)
+	}
+	return n.toDecimal().Round(0).BigInt()
+}
+
+// toInt64 converts the numeric to int64, the fraction is rounded.
+func (n numeric) toInt64() int64 {
+	switch n.typ {
+	case intNum:
+		return n.ival
+	case uintNum:
+		return int64(n.uval)
+	case decimalNum:
+		return clampInt64(n.dval.Round(0).BigInt())
+	}
+	f := math.Round(n.fval)
+	switch {
+	case f >= math.MaxInt64:
+		return math.MaxInt64
+	case f <= math.MinInt64:
+		return math.MinInt64
+	}
+	return int64(f)
+}
+
+// toUint64 converts the numeric to uint64, the negative number is in two's complement.
+func (n numeric) toUint64() uint64 {
+	switch n.typ {
+	case uintNum:
+		return n.uval
+	case intNum:
+		return uint64(n.ival)
+	case decimalNum:
+		b := n.dval.Round(0).BigInt()
+		if b.Sign() < 0 {
+			return uint64(clampInt64(b))
+		}
+		if b.IsUint64() {
+			return b.Uint64()
+		}
+		return math.MaxUint64
+	}
+	f := math.Round(n.fval)
+	switch {
+	case f < 0:
+		return uint64(numeric{typ: floatNum, fval: f}.toInt64())
+	case f >= math.MaxUint64:
+		return math.MaxUint64
+	}
+	return uint64(f)
+}
+
+func (n numeric) isZero() bool {
+	switch n.typ {
+	case intNum:
+		return n.ival == 0
+	case uintNum:
+		return n.uval == 0
+	case decimalNum:
+		return n.dval.IsZero()
+	}
+	return n.fval == 0
+}
+
+func (n numeric) sign() int {
+	switch n.typ {
+	case intNum:
+		switch {
+		case n.ival > 0:
+			return 1
+		case n.ival < 0:
+			return -1
+		}
+		return 0
+	case uintNum:
+		if n.uval > 0 {
+			return 1
+		}
+		return 0
+	case decimalNum:
+		return n.dval.Sign()
+	}
+	switch {
+	case n.fval > 0:
+		return 1
+	case n.fval < 0:
+		return -1
+	}
+	return 0
+}
+
+func clampInt64(b *big.Int) int64 {
+	if b.IsInt64() {
+		return b.Int64()
+	}
+	if b.Sign() < 0 {
+		return math.MinInt64
+	}
+	return math.MaxInt64
+}
+
+// integralNumeric converts the big integer to the int or uint numeric.
+func integralNumeric(b *big.Int, unsigned bool, text string) (numeric, error) {
+	if unsigned {
+		if b.Sign() < 0 || !b.IsUint64() {
+			return numeric{}, errors.Errorf("BIGINT UNSIGNED value is out of range in '%s'", text)
+		}
+		return numeric{typ: uintNum, uval: b.Uint64()}, nil
+	}
+	if !b.IsInt64() {
+		return numeric{}, errors.Errorf("BIGINT value is out of range in '%s'", text)
+	}
+	return newIntNumeric(b.Int64()), nil
+}
+
+// resultType returns the type of the arithmetic result.
+func resultType(a, b numeric) numericType {
+	switch {
+	case a.typ == floatNum || b.typ == floatNum:
+		return floatNum
+	case a.typ == decimalNum || b.typ == decimalNum:
+		return decimalNum
+	case a.typ == uintNum || b.typ == uintNum:
+		return uintNum
+	}
+	return intNum
+}
+
+func maxScale(a, b numeric) int32 {
+	if a.scale > b.scale {
+		return a.scale
+	}
+	return b.scale
+}
+
+// arithmetic computes the binary arithmetic operator, the text is the expression used in the error.
+// The bool result is true if the result is NULL, such as division by zero.
+func arithmetic(op string, a, b numeric, text string) (numeric, bool, error) {
+	typ := resultType(a, b)
+	switch op {
+	case sqlparser.PlusStr, sqlparser.MinusStr, sqlparser.MultStr:
+		switch typ {
+		case floatNum:
+			x, y := a.toFloat(), b.toFloat()
+			switch op {
+			case sqlparser.PlusStr:
+				return newFloatNumeric(x + y), false, nil
+			case sqlparser.MinusStr:
+				return newFloatNumeric(x - y), false, nil
+			}
+			return newFloatNumeric(x * y), false, nil
+		case decimalNum:
+			x, y := a.toDecimal(), b.toDecimal()
+			switch op {
+			case sqlparser.PlusStr:
+				return newDecimalNumeric(x.Add(y), maxScale(a, b)), false, nil
+			case sqlparser.MinusStr:
+				return newDecimalNumeric(x.Sub(y), maxScale(a, b)), false, nil
+			}
+			return newDecimalNumeric(x.Mul(y), a.scale+b.scale), false, nil
+		case intNum:
+			if r, ok := intArithmetic(op, a.ival, b.ival); ok {
+				return newIntNumeric(r), false, nil
+			}
+		}
+		x, y := a.toBig(), b.toBig()
+		switch op {
+		case sqlparser.PlusStr:
+			x.Add(x, y)
+		case sqlparser.MinusStr:
+			x.Sub(x, y)
+		default:
+			x.Mul(x, y)
+		}
+		r, err := integralNumeric(x, typ == uintNum, text)
+		return r, false, err
+	case sqlparser.DivStr:
+		if b.isZero() {
+			return numeric{}, true, nil
+		}
+		if typ == floatNum {
+			return newFloatNumeric(a.toFloat() / b.toFloat()), false, nil
+		}
+		scale := a.scale + divPrecisionIncrement
+		if scale > maxDecimalScale {
+			scale = maxDecimalScale
+		}
+		return newDecimalNumeric(a.toDecimal().DivRound(b.toDecimal(), scale), scale), false, nil
+	case sqlparser.IntDivStr:
+		if b.isZero() {
+			return numeric{}, true, nil
+		}
+		var q *big.Int
+		switch typ {
+		case intNum, uintNum:
+			q = new(big.Int).Quo(a.toBig(), b.toBig())
+		case decimalNum:
+			q = a.toDecimal().DivRound(b.toDecimal(), maxDecimalScale).Truncate(0).BigInt()
+		default:
+			f := math.Trunc(a.toFloat() / b.toFloat())
+			q, _ = big.NewFloat(f).Int(nil)
+		}
+		r, err := integralNumeric(q, typ == uintNum, text)
+		return r, false, err
+	case sqlparser.ModStr:
+		if b.isZero() {
+			return numeric{}, true, nil
+		}
+		switch typ {
+		case intNum:
+			if b.ival == -1 {
+				return newIntNumeric(0), false, nil
+			}
+			return newIntNumeric(a.ival % b.ival), false, nil
+		case uintNum:
+			x := new(big.Int).Rem(a.toBig(), b.toBig())
+			if a.sign() < 0 {
+				return newIntNumeric(x.Int64()), false, nil
+			}
+			r, err := integralNumeric(x, true, text)
+			return r, false, err
+		case decimalNum:
+			return newDecimalNumeric(a.toDecimal().Mod(b.toDecimal()), maxScale(a, b)), false, nil
+		}
+		return newFloatNumeric(math.Mod(a.toFloat(), b.toFloat())), false, nil
+	case sqlparser.BitAndStr:
+		return numeric{typ: uintNum, uval: a.toUint64() & b.toUint64()}, false, nil
+	case sqlparser.BitOrStr:
+		return numeric{typ: uintNum, uval: a.toUint64() | b.toUint64()}, false, nil
+	case sqlparser.BitXorStr:
+		return numeric{typ: uintNum, uval: a.toUint64() ^ b.toUint64()}, false, nil
+	case sqlparser.ShiftLeftStr, sqlparser.ShiftRightStr:
+		x, y := a.toUint64(), b.toUint64()
+		if y >= 64 {
+			return numeric{typ: uintNum}, false, nil
+		}
+		if op == sqlparser.ShiftLeftStr {
+			return numeric{typ: uintNum, uval: x << y}, false, nil
+		}
+		return numeric{typ: uintNum, uval: x >> y}, false, nil
+	}
+	return numeric{}, false, errors.Errorf("unsupported: operator.'%s'", op)
+}
+
+// intArithmetic computes the int64 arithmetic, returns false if overflow.
+func intArithmetic(op string, x, y int64) (int64, bool) {
+	switch op {
+	case sqlparser.PlusStr:
+		r := x + y
+		if (x > 0 && y > 0 && r < 0) || (x < 0 && y < 0 && r >= 0) {
+			return 0, false
+		}
+		return r, true
+	case sqlparser.MinusStr:
+		r := x - y
+		if (x >= 0 && y < 0 && r < 0) || (x < 0 && y > 0 && r >= 0) {
+			return 0, false
+		}
+		return r, true
+	}
+	if x == 0 || y == 0 {
+		return 0, true
+	}
+	r := x * y
+	if r/y != x || (x == -1 && y == math.MinInt64) || (y == -1 && x == math.MinInt64) {
+		return 0, false
+	}
+	return r, true
+}
+
+// negate computes the unary minus.
+func negate(n numeric) numeric {
+	switch n.typ {
+	case intNum:
+		if n.ival != math.MinInt64 {
+			return newIntNumeric(-n.ival)
+		}
+	case uintNum:
+		if n.uval <= math.MaxInt64 {
+			return newIntNumeric(-int64(n.uval))
+		}
+	case floatNum:
+		return newFloatNumeric(-n.fval)
+	}
+	return newDecimalNumeric(n.toDecimal().Neg(), n.scale)
+}
+
+// compareNumeric compares the two numerics, returns -1, 0 or 1.
+func compareNumeric(a, b numeric) int {
+	switch resultType(a, b) {
+	case intNum:
+		return compareInt64(a.ival, b.ival)
+	case uintNum:
+		switch {
+		case a.typ == intNum && a.ival < 0:
+			return -1
+		case b.typ == intNum && b.ival < 0:
+			return 1
+		}
+		return compareUint64(a.toUint64(), b.toUint64())
+	case decimalNum:
+		return a.toDecimal().Cmp(b.toDecimal())
+	}
+	x, y := a.toFloat(), b.toFloat()
+	switch {
+	case x < y:
+		return -1
+	case x > y:
+		return 1
+	}
+	return 0
+}
+
+func compareInt64(x, y int64) int {
+	switch {
+	case x < y:
+		return -1
+	case x > y:
+		return 1
+	}
+	return 0
+}
+
+func compareUint64(x, y uint64) int {
+	switch {
+	case x < y:
+		return -1
+	case x > y:
+		return 1
+	}
+	return 0
+}
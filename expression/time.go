@@ -0,0 +1,291 @@
+/*
+ * NeoDB
+ *
+ * Copyright 2021-2030 The NeoDB Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package expression
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sealdb/mysqlstack/sqlparser"
+	"github.com/sealdb/mysqlstack/sqlparser/depends/sqltypes"
+)
+
+var (
+	_ Evaluator = &dateAddExpr{}
+)
+
+// timeLayouts are the accepted layouts of the temporal strings.
+var timeLayouts = []string{
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+	"20060102150405",
+	"20060102",
+	"15:04:05.999999999",
+	"15:04:05",
+}
+
+// parseTime parses the value to the time, returns false if the value is not a valid
+// date or time, such as the zero date '0000-00-00', then the functions return NULL.
+func parseTime(v sqltypes.Value) (time.Time, bool) {
+	s := strings.TrimSpace(v.ToString())
+	if v.IsIntegral() || v.Type() == sqltypes.Decimal {
+		// The number 20200102 or 20200102101112 is a date or datetime.
+		if i := strings.IndexByte(s, '.'); i >= 0 {
+			s = s[:i]
+		}
+	}
+	for _, layout := range timeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+func hasTimePart(t time.Time) bool {
+	return t.Hour() != 0 || t.Minute() != 0 || t.Second() != 0 || t.Nanosecond() != 0
+}
+
+func formatDate(t time.Time) sqltypes.Value {
+	return sqltypes.MakeTrusted(sqltypes.Date, []byte(t.Format("2006-01-02")))
+}
+
+func formatDatetime(t time.Time) sqltypes.Value {
+	layout := "2006-01-02 15:04:05"
+	if t.Nanosecond() != 0 {
+		layout = "2006-01-02 15:04:05.000000"
+	}
+	return sqltypes.MakeTrusted(sqltypes.Datetime, []byte(t.Format(layout)))
+}
+
+// timeFunc builds the function which extracts an integer from the time.
+func timeFunc(extract func(t time.Time) int) builtinFunc {
+	return func(args []sqltypes.Value) (sqltypes.Value, error) {
+		t, ok := parseTime(args[0])
+		if !ok {
+			return sqltypes.NULL, nil
+		}
+		return sqltypes.NewInt64(int64(extract(t))), nil
+	}
+}
+
+var (
+	yearFunc      = timeFunc(func(t time.Time) int { return t.Year() })
+	quarterFunc   = timeFunc(func(t time.Time) int { return (int(t.Month())-1)/3 + 1 })
+	monthFunc     = timeFunc(func(t time.Time) int { return int(t.Month()) })
+	dayFunc       = timeFunc(func(t time.Time) int { return t.Day() })
+	dayOfWeekFunc = timeFunc(func(t time.Time) int { return int(t.Weekday()) + 1 })
+	weekdayFunc   = timeFunc(func(t time.Time) int { return (int(t.Weekday()) + 6) % 7 })
+	dayOfYearFunc = timeFunc(func(t time.Time) int { return t.YearDay() })
+	hourFunc      = timeFunc(func(t time.Time) int { return t.Hour() })
+	minuteFunc    = timeFunc(func(t time.Time) int { return t.Minute() })
+	secondFunc    = timeFunc(func(t time.Time) int { return t.Second() })
+)
+
+func dateFunc(args []sqltypes.Value) (sqltypes.Value, error) {
+	t, ok := parseTime(args[0])
+	if !ok {
+		return sqltypes.NULL, nil
+	}
+	return formatDate(t), nil
+}
+
+// dateDiffFunc returns the days from the second date to the first date, the time parts are ignored.
+func dateDiffFunc(args []sqltypes.Value) (sqltypes.Value, error) {
+	t1, ok1 := parseTime(args[0])
+	t2, ok2 := parseTime(args[1])
+	if !ok1 || !ok2 {
+		return sqltypes.NULL, nil
+	}
+	d1 := time.Date(t1.Year(), t1.Month(), t1.Day(), 0, 0, 0, 0, time.UTC)
+	d2 := time.Date(t2.Year(), t2.Month(), t2.Day(), 0, 0, 0, 0, time.UTC)
+	return sqltypes.NewInt64(int64(d1.Sub(d2).Hours() / 24)), nil
+}
+
+// dateFormatFunc formats the date by the MySQL DATE_FORMAT specifiers.
+func dateFormatFunc(args []sqltypes.Value) (sqltypes.Value, error) {
+	t, ok := parseTime(args[0])
+	if !ok {
+		return sqltypes.NULL, nil
+	}
+
+	format := args[1].ToString()
+	var buf strings.Builder
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' || i == len(format)-1 {
+			buf.WriteByte(format[i])
+			continue
+		}
+		i++
+		hour12 := t.Hour() % 12
+		if hour12 == 0 {
+			hour12 = 12
+		}
+		switch format[i] {
+		case 'Y':
+			fmt.Fprintf(&buf, "%04d", t.Year())
+		case 'y':
+			fmt.Fprintf(&buf, "%02d", t.Year()%100)
+		case 'm':
+			fmt.Fprintf(&buf, "%02d", int(t.Month()))
+		case 'c':
+			buf.WriteString(strconv.Itoa(int(t.Month())))
+		case 'M':
+			buf.WriteString(t.Month().String())
+		case 'b':
+			buf.WriteString(t.Month().String()[:3])
+		case 'd':
+			fmt.Fprintf(&buf, "%02d", t.Day())
+		case 'e':
+			buf.WriteString(strconv.Itoa(t.Day()))
+		case 'D':
+			buf.WriteString(strconv.Itoa(t.Day()) + ordinalSuffix(t.Day()))
+		case 'j':
+			fmt.Fprintf(&buf, "%03d", t.YearDay())
+		case 'H':
+			fmt.Fprintf(&buf, "%02d", t.Hour())
+		case 'k':
+			buf.WriteString(strconv.Itoa(t.Hour()))
+		case 'h', 'I':
+			fmt.Fprintf(&buf, "%02d", hour12)
+		case 'l':
+			buf.WriteString(strconv.Itoa(hour12))
+		case 'i':
+			fmt.Fprintf(&buf, "%02d", t.Minute())
+		case 's', 'S':
+			fmt.Fprintf(&buf, "%02d", t.Second())
+		case 'f':
+			fmt.Fprintf(&buf, "%06d", t.Nanosecond()/1000)
+		case 'p':
+			buf.WriteString(t.Format("PM"))
+		case 'r':
+			fmt.Fprintf(&buf, "%02d:%02d:%02d %s", hour12, t.Minute(), t.Second(), t.Format("PM"))
+		case 'T':
+			buf.WriteString(t.Format("15:04:05"))
+		case 'W':
+			buf.WriteString(t.Weekday().String())
+		case 'a':
+			buf.WriteString(t.Weekday().String()[:3])
+		case 'w':
+			buf.WriteString(strconv.Itoa(int(t.Weekday())))
+		default:
+			buf.WriteByte(format[i])
+		}
+	}
+	return sqltypes.NewVarChar(buf.String()), nil
+}
+
+func ordinalSuffix(day int) string {
+	if day >= 11 && day <= 13 {
+		return "th"
+	}
+	switch day % 10 {
+	case 1:
+		return "st"
+	case 2:
+		return "nd"
+	case 3:
+		return "rd"
+	}
+	return "th"
+}
+
+// dateAddExpr is the DATE_ADD/DATE_SUB or the date +/- INTERVAL.
+type dateAddExpr struct {
+	date, interval Evaluator
+	unit           string
+	sub            bool
+}
+
+func compileDateAdd(date sqlparser.Expr, interval *sqlparser.IntervalExpr, sub bool, resolve Resolver) (Evaluator, error) {
+	unit := strings.ToLower(interval.Unit)
+	switch unit {
+	case "microsecond", "second", "minute", "hour", "day", "week", "month", "quarter", "year":
+	default:
+		return nil, errors.Errorf("unsupported: interval.unit.'%s'", interval.Unit)
+	}
+
+	d, err := compile(date, resolve)
+	if err != nil {
+		return nil, err
+	}
+	i, err := compile(interval.Expr, resolve)
+	if err != nil {
+		return nil, err
+	}
+	return &dateAddExpr{date: d, interval: i, unit: unit, sub: sub}, nil
+}
+
+// Eval implements the Evaluator interface.
+func (e *dateAddExpr) Eval(row []sqltypes.Value) (sqltypes.Value, error) {
+	vals, null, err := evalAll(row, e.date, e.interval)
+	if err != nil || null {
+		return sqltypes.NULL, err
+	}
+	t, ok := parseTime(vals[0])
+	if !ok {
+		return sqltypes.NULL, nil
+	}
+
+	n := newNumeric(vals[1]).toInt64()
+	if e.sub {
+		n = -n
+	}
+	isDate := vals[0].Type() == sqltypes.Date || (!vals[0].IsTemporal() && !hasTimePart(t))
+	switch e.unit {
+	case "microsecond":
+		t = t.Add(time.Duration(n) * time.Microsecond)
+		isDate = false
+	case "second":
+		t = t.Add(time.Duration(n) * time.Second)
+		isDate = false
+	case "minute":
+		t = t.Add(time.Duration(n) * time.Minute)
+		isDate = false
+	case "hour":
+		t = t.Add(time.Duration(n) * time.Hour)
+		isDate = false
+	case "day":
+		t = t.AddDate(0, 0, int(n))
+	case "week":
+		t = t.AddDate(0, 0, int(n)*7)
+	case "month":
+		t = addMonths(t, int(n))
+	case "quarter":
+		t = addMonths(t, int(n)*3)
+	case "year":
+		t = addMonths(t, int(n)*12)
+	}
+	if t.Year() < 1 || t.Year() > 9999 {
+		return sqltypes.NULL, nil
+	}
+	if isDate {
+		return formatDate(t), nil
+	}
+	return formatDatetime(t), nil
+}
+
+// addMonths adds the months, the day is clipped to the last day of the month,
+// such as: '2020-01-31' + INTERVAL 1 MONTH is '2020-02-29'.
+func addMonths(t time.Time, months int) time.Time {
+	first := time.Date(t.Year(), t.Month(), 1, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC).AddDate(0, months, 0)
+	last := first.AddDate(0, 1, -1).Day()
+	day := t.Day()
+	if day > last {
+		day = last
+	}
+	return first.AddDate(0, 0, day-1)
+}
@@ -2,7 +2,7 @@ module github.com/sealdb/neodb
 
 go 1.19
 
-require github.com/shopspring/decimal v1.2.0 // indirect
+require github.com/shopspring/decimal v1.2.0
 
 require (
 	github.com/ant0ine/go-json-rest v3.3.2+incompatible
@@ -12,6 +12,7 @@ package optimizer
 import (
 	"github.com/sealdb/neodb/planner"
 	"github.com/sealdb/neodb/router"
+	"github.com/sealdb/neodb/xparser"
 
 	"github.com/pkg/errors"
 	"github.com/sealdb/mysqlstack/sqlparser"
@@ -64,6 +65,9 @@ func (so *SimpleOptimizer) BuildPlanTree() (*planner.PlanTree, error) {
 	case *sqlparser.Update:
 		node := planner.NewUpdatePlan(log, database, query, node.(*sqlparser.Update), router)
 		plans.Add(node)
+	case *xparser.MultiUpdate:
+		node := planner.NewMultiUpdatePlan(log, database, query, node.(*xparser.MultiUpdate), router)
+		plans.Add(node)
 	case *sqlparser.Select:
 		nod := node.(*sqlparser.Select)
 		selectNode := planner.NewSelectPlan(log, database, query, nod, router)
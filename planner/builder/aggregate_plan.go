@@ -24,12 +24,88 @@ var (
 	_ ChildPlan = &AggregatePlan{}
 )
 
+const (
+	// AggrTypeGroupConcat enum.
+	AggrTypeGroupConcat sqltypes.AggrType = "GROUP_CONCAT"
+
+	// AggrTypeBitAnd enum.
+	AggrTypeBitAnd sqltypes.AggrType = "BIT_AND"
+
+	// AggrTypeBitOr enum.
+	AggrTypeBitOr sqltypes.AggrType = "BIT_OR"
+
+	// AggrTypeBitXor enum.
+	AggrTypeBitXor sqltypes.AggrType = "BIT_XOR"
+
+	// AggrTypeStd enum, the population standard deviation.
+	AggrTypeStd sqltypes.AggrType = "STD"
+
+	// AggrTypeStdSamp enum, the sample standard deviation.
+	AggrTypeStdSamp sqltypes.AggrType = "STDDEV_SAMP"
+
+	// AggrTypeVariance enum, the population variance.
+	AggrTypeVariance sqltypes.AggrType = "VARIANCE"
+
+	// AggrTypeVarSamp enum, the sample variance.
+	AggrTypeVarSamp sqltypes.AggrType = "VAR_SAMP"
+
+	// AggrTypeJSONArrayAgg enum.
+	AggrTypeJSONArrayAgg sqltypes.AggrType = "JSON_ARRAYAGG"
+
+	// AggrTypeJSONObjectAgg enum.
+	AggrTypeJSONObjectAgg sqltypes.AggrType = "JSON_OBJECTAGG"
+)
+
+// aggrTypes maps the aggregate function name to the AggrType.
+var aggrTypes = map[string]sqltypes.AggrType{
+	"sum":            sqltypes.AggrTypeSum,
+	"count":          sqltypes.AggrTypeCount,
+	"min":            sqltypes.AggrTypeMin,
+	"max":            sqltypes.AggrTypeMax,
+	"avg":            sqltypes.AggrTypeAvg,
+	"group_concat":   AggrTypeGroupConcat,
+	"bit_and":        AggrTypeBitAnd,
+	"bit_or":         AggrTypeBitOr,
+	"bit_xor":        AggrTypeBitXor,
+	"std":            AggrTypeStd,
+	"stddev":         AggrTypeStd,
+	"stddev_pop":     AggrTypeStd,
+	"stddev_samp":    AggrTypeStdSamp,
+	"variance":       AggrTypeVariance,
+	"var_pop":        AggrTypeVariance,
+	"var_samp":       AggrTypeVarSamp,
+	"json_arrayagg":  AggrTypeJSONArrayAgg,
+	"json_objectagg": AggrTypeJSONObjectAgg,
+}
+
+// IsStatisticsAggr returns true if the aggregate type is STD/VARIANCE.
+func IsStatisticsAggr(typ sqltypes.AggrType) bool {
+	switch typ {
+	case AggrTypeStd, AggrTypeStdSamp, AggrTypeVariance, AggrTypeVarSamp:
+		return true
+	}
+	return false
+}
+
+// AggrOrder tuple, the order of the values in GROUP_CONCAT.
+type AggrOrder struct {
+	Index     int
+	Direction Direction
+}
+
 // Aggregator tuple.
 type Aggregator struct {
 	Field    string
 	Index    int
 	Type     sqltypes.AggrType
 	Distinct bool
+	// Extras mark the decomposed columns, which will be removed after aggregation.
+	// eg: the sum of squares and the count of STD, the value of JSON_OBJECTAGG.
+	Extras []int `json:",omitempty"`
+	// OrderBy used by GROUP_CONCAT, the order columns will be removed after aggregation.
+	OrderBy []AggrOrder `json:",omitempty"`
+	// Separator used by GROUP_CONCAT.
+	Separator string `json:",omitempty"`
 }
 
 // AggregatePlan represents order-by plan.
@@ -63,6 +139,7 @@ func NewAggregatePlan(log *xlog.Log, exprs []sqlparser.SelectExpr, tuples, group
 // analyze used to check the aggregator is at the support level.
 // Supports:
 // SUM/COUNT/MIN/MAX/AVG/GROUPBY
+// GROUP_CONCAT/BIT_AND/BIT_OR/BIT_XOR/STD/STDDEV/VARIANCE/JSON_ARRAYAGG/JSON_OBJECTAGG
 // Notes:
 // group by fields must be in the select list, for example:
 // select count(a), a from t group by a --[OK]
@@ -70,51 +147,81 @@ func NewAggregatePlan(log *xlog.Log, exprs []sqlparser.SelectExpr, tuples, group
 func (p *AggregatePlan) analyze() error {
 	var nullAggrs []Aggregator
 	tuples := p.tuples
+	if !p.IsPushDown {
+		// The tuples will be rebuilt with the decomposed columns.
+		p.tuples = make([]selectTuple, 0, len(tuples))
+	}
 
 	// aggregators.
 	k := 0
-	for _, tuple := range tuples {
+	for i, tuple := range tuples {
 		aggrFuc := strings.ToLower(tuple.aggrFuc)
 		if aggrFuc == "" {
 			if tuple.field == "*" {
 				return errors.Errorf("unsupported: exists.aggregate.and.'*'.select.exprs")
 			}
 			nullAggrs = append(nullAggrs, Aggregator{Field: tuple.field, Index: k, Type: sqltypes.AggrTypeNull})
+			if !p.IsPushDown {
+				p.tuples = append(p.tuples, tuple)
+			}
 			k++
 			continue
 		}
 
-		var aggType sqltypes.AggrType
-		switch aggrFuc {
-		case "sum":
-			aggType = sqltypes.AggrTypeSum
-		case "count":
-			aggType = sqltypes.AggrTypeCount
-		case "min":
-			aggType = sqltypes.AggrTypeMin
-		case "max":
-			aggType = sqltypes.AggrTypeMax
-		case "avg":
-			aggType = sqltypes.AggrTypeAvg
-		default:
+		aggType, ok := aggrTypes[aggrFuc]
+		if !ok {
 			return errors.Errorf("unsupported: function:%+v", tuple.aggrFuc)
 		}
 
-		p.normalAggrs = append(p.normalAggrs, Aggregator{Field: tuple.field, Index: k, Type: aggType, Distinct: tuple.distinct})
+		aggr := Aggregator{Field: tuple.field, Index: k, Type: aggType, Distinct: tuple.distinct}
 		if p.IsPushDown {
-			if aggType == sqltypes.AggrTypeAvg {
+			switch {
+			case aggType == sqltypes.AggrTypeAvg:
+				p.normalAggrs = append(p.normalAggrs, aggr)
 				p.normalAggrs = append(p.normalAggrs, Aggregator{Field: fmt.Sprintf("sum(%s)", tuple.aggrField), Index: k, Type: sqltypes.AggrTypeSum})
 				p.normalAggrs = append(p.normalAggrs, Aggregator{Field: fmt.Sprintf("count(%s)", tuple.aggrField), Index: k + 1, Type: sqltypes.AggrTypeCount})
 				avgs := decomposeAvg(&tuple)
-				p.rewritten = append(p.rewritten, &sqlparser.AliasedExpr{})
-				copy(p.rewritten[(k+2):], p.rewritten[k+1:])
 				p.rewritten[k] = avgs[0]
-				p.rewritten[(k + 1)] = avgs[1]
+				p.insertExprs(k, avgs[1])
 				k++
+			case IsStatisticsAggr(aggType):
+				stats := decomposeStatistics(&tuple)
+				aggr.Extras = []int{k + 1, k + 2}
+				p.normalAggrs = append(p.normalAggrs, aggr)
+				p.rewritten[k] = stats[0]
+				p.insertExprs(k, stats[1:]...)
+				k += 2
+			default:
+				p.normalAggrs = append(p.normalAggrs, aggr)
 			}
 		} else {
-			p.rewritten[k] = decomposeAgg(&tuple)
-			p.tuples[k].expr = p.rewritten[k]
+			var extras []*sqlparser.AliasedExpr
+			switch aggType {
+			case AggrTypeGroupConcat:
+				var orders []*sqlparser.AliasedExpr
+				var value *sqlparser.AliasedExpr
+				value, orders, aggr.OrderBy, aggr.Separator = decomposeGroupConcat(&tuple)
+				for j := range aggr.OrderBy {
+					aggr.OrderBy[j].Index = k + 1 + j
+				}
+				p.rewritten[k] = value
+				extras = orders
+			case AggrTypeJSONObjectAgg:
+				kv := decomposeJSONObjectAgg(&tuple)
+				aggr.Extras = []int{k + 1}
+				p.rewritten[k] = kv[0]
+				extras = kv[1:]
+			default:
+				p.rewritten[k] = decomposeAgg(&tuple)
+			}
+			p.normalAggrs = append(p.normalAggrs, aggr)
+			tuples[i].expr = p.rewritten[k]
+			p.tuples = append(p.tuples, tuples[i])
+			for _, extra := range extras {
+				p.tuples = append(p.tuples, extraTuple(extra, &tuples[i]))
+			}
+			p.insertExprs(k, extras...)
+			k += len(extras)
 		}
 		k++
 	}
@@ -137,6 +244,19 @@ func (p *AggregatePlan) analyze() error {
 	return nil
 }
 
+// insertExprs used to insert the decomposed exprs after the index k of the rewritten exprs.
+func (p *AggregatePlan) insertExprs(k int, exprs ...*sqlparser.AliasedExpr) {
+	if len(exprs) == 0 {
+		return
+	}
+	rewritten := make(sqlparser.SelectExprs, 0, len(p.rewritten)+len(exprs))
+	rewritten = append(rewritten, p.rewritten[:k+1]...)
+	for _, expr := range exprs {
+		rewritten = append(rewritten, expr)
+	}
+	p.rewritten = append(rewritten, p.rewritten[k+1:]...)
+}
+
 // Build used to build distributed querys.
 func (p *AggregatePlan) Build() error {
 	return p.analyze()
@@ -183,6 +303,22 @@ func (p *AggregatePlan) ReWritten() sqlparser.SelectExprs {
 	return p.rewritten
 }
 
+// ignoreDuplicates returns true if all the aggregators are not affected by
+// the duplicate rows, such as COUNT(DISTINCT)/SUM(DISTINCT)/MIN/MAX/BIT_AND/BIT_OR.
+func (p *AggregatePlan) ignoreDuplicates() bool {
+	for _, aggr := range p.normalAggrs {
+		if aggr.Distinct {
+			continue
+		}
+		switch aggr.Type {
+		case sqltypes.AggrTypeMin, sqltypes.AggrTypeMax, AggrTypeBitAnd, AggrTypeBitOr:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
 // Empty returns the aggregator number more than zero.
 func (p *AggregatePlan) Empty() bool {
 	return (len(p.normalAggrs) == 0 && len(p.groupAggrs) == 0)
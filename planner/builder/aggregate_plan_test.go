@@ -515,3 +515,107 @@ func TestAggregatePlans(t *testing.T) {
 		}
 	}
 }
+
+func TestAggregatePlanExtensions(t *testing.T) {
+	querys := []string{
+		"select bit_or(a), std(b) as s, json_arrayagg(c) from A",
+		"select a, group_concat(distinct b, c order by d desc, 1 separator ';'), json_objectagg(b, c) from A group by a",
+	}
+	results := []string{
+		`{
+	"Aggrs": [
+		{
+			"Field": "bit_or(a)",
+			"Index": 0,
+			"Type": "BIT_OR",
+			"Distinct": false
+		},
+		{
+			"Field": "std(b)",
+			"Index": 1,
+			"Type": "STD",
+			"Distinct": false,
+			"Extras": [
+				2,
+				3
+			]
+		},
+		{
+			"Field": "json_arrayagg(c)",
+			"Index": 4,
+			"Type": "JSON_ARRAYAGG",
+			"Distinct": false
+		}
+	],
+	"ReWritten": "bit_or(a), sum(b) as s, sum((b) * (b)), count(b), json_arrayagg(c)"
+}`,
+		`{
+	"Aggrs": [
+		{
+			"Field": "group_concat(distinct b, c order by d desc, 1 asc separator ';')",
+			"Index": 1,
+			"Type": "GROUP_CONCAT",
+			"Distinct": true,
+			"OrderBy": [
+				{
+					"Index": 2,
+					"Direction": "DESC"
+				},
+				{
+					"Index": 3,
+					"Direction": "ASC"
+				}
+			],
+			"Separator": ";"
+		},
+		{
+			"Field": "json_objectagg(b, c)",
+			"Index": 4,
+			"Type": "JSON_OBJECTAGG",
+			"Distinct": false,
+			"Extras": [
+				5
+			]
+		},
+		{
+			"Field": "a",
+			"Index": 0,
+			"Type": "GROUP BY",
+			"Distinct": false
+		}
+	],
+	"ReWritten": "a, concat(b, c) as ` + "`group_concat(distinct b, c order by d desc, 1 asc separator ';')`" + `, d, b, b as ` + "`json_objectagg(b, c)`" + `, c"
+}`,
+	}
+
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	route, cleanup := router.MockNewRouter(log)
+	defer cleanup()
+
+	err := route.CreateDatabase("sbtest")
+	assert.Nil(t, err)
+	err = route.AddForTest("sbtest", router.MockTableMConfig())
+	assert.Nil(t, err)
+	for i, query := range querys {
+		tree, err := sqlparser.Parse(query)
+		assert.Nil(t, err)
+		node := tree.(*sqlparser.Select)
+		p, err := scanTableExprs(log, route, "sbtest", node.From)
+		assert.Nil(t, err)
+		tuples, aggTyp, err := parseSelectExprs(node.SelectExprs, p)
+		assert.Nil(t, err)
+		_, ok := p.(*MergeNode)
+		groups, err := checkGroupBy(node.GroupBy, tuples, route, p.getReferTables(), ok)
+		assert.Nil(t, err)
+		plan := NewAggregatePlan(log, node.SelectExprs, tuples, groups, aggTyp != notPush)
+		// plan build
+		{
+			err := plan.Build()
+			assert.Nil(t, err)
+			want := results[i]
+			got := plan.JSON()
+			log.Debug(got)
+			assert.Equal(t, want, got)
+		}
+	}
+}
@@ -14,6 +14,7 @@ import (
 
 	"github.com/sealdb/neodb/router"
 	"github.com/sealdb/neodb/xcontext"
+	"github.com/sealdb/neodb/xparser"
 
 	"github.com/pkg/errors"
 	"github.com/sealdb/mysqlstack/sqlparser"
@@ -22,16 +23,7 @@ import (
 
 // BuildNode used to build the plannode tree.
 func BuildNode(log *xlog.Log, router *router.Router, database string, node sqlparser.SelectStatement) (PlanNode, error) {
-	var err error
-	var root PlanNode
-	switch node := node.(type) {
-	case *sqlparser.Select:
-		root, err = processSelect(log, router, database, node)
-	case *sqlparser.Union:
-		root, err = processUnion(log, router, database, node)
-	default:
-		err = errors.New("unsupported: unknown.select.statement")
-	}
+	root, err := processSelectStatement(log, router, database, node)
 	if err != nil {
 		return nil, err
 	}
@@ -40,7 +32,53 @@ func BuildNode(log *xlog.Log, router *router.Router, database string, node sqlpa
 	return root, nil
 }
 
+// processSelectStatement used to build the plannode tree without the querys.
+func processSelectStatement(log *xlog.Log, router *router.Router, database string, node sqlparser.SelectStatement) (PlanNode, error) {
+	switch node := node.(type) {
+	case *sqlparser.Select:
+		return processSelect(log, router, database, node)
+	case *sqlparser.Union:
+		return processUnion(log, router, database, node)
+	}
+	return nil, errors.New("unsupported: unknown.select.statement")
+}
+
+// processSelect used to process select, the IN/EXISTS subqueries in the WHERE clause are
+// replaced with the placeholders and built after the outer query.
 func processSelect(log *xlog.Log, router *router.Router, database string, node *sqlparser.Select) (PlanNode, error) {
+	// The select from dual is executed on any backend.
+	if len(node.From) == 1 {
+		if aliasExpr, ok := node.From[0].(*sqlparser.AliasedTableExpr); ok {
+			if tb, ok := aliasExpr.Expr.(sqlparser.TableName); ok && tb.Qualifier.IsEmpty() && tb.Name.String() == "dual" {
+				m := newMergeNode(log, router)
+				m.Sel = node
+				m.routeLen = 1
+				m.nonGlobalCnt = 0
+				m.ReqMode = xcontext.ReqSingle
+				return m, nil
+			}
+		}
+	}
+
+	if node.Where == nil || !hasSubquery(node.Where) {
+		return processSelectNode(log, router, database, node)
+	}
+
+	query := sqlparser.String(node)
+	subs := extractSubqueries(node.Where)
+	root, err := processSelectNode(log, router, database, node)
+	if err != nil || len(subs) == 0 {
+		return root, err
+	}
+	return processSubqueries(log, router, database, root, query, subs)
+}
+
+func processSelectNode(log *xlog.Log, router *router.Router, database string, node *sqlparser.Select) (PlanNode, error) {
+	windows, err := parseWindows(node)
+	if err != nil {
+		return nil, err
+	}
+
 	root, err := scanTableExprs(log, router, database, node.From)
 	if err != nil {
 		return nil, err
@@ -68,6 +106,15 @@ func processSelect(log *xlog.Log, router *router.Router, database string, node *
 		return root, nil
 	}
 
+	// The window functions are computed by the backends if every partition is in one shard,
+	// otherwise they are replaced by NULL and computed in the proxy.
+	if len(windows) > 0 && ok && windowPushDown(mn, node, windows) {
+		windows = nil
+	}
+	if len(windows) > 0 {
+		node = replaceWindows(node, windows)
+	}
+
 	var groups []selectTuple
 	fields, aggTyp, err := parseSelectExprs(node.SelectExprs, root)
 	if err != nil {
@@ -78,11 +125,32 @@ func processSelect(log *xlog.Log, router *router.Router, database string, node *
 		return nil, err
 	}
 
-	if groups, err = checkDistinct(node, groups, fields, router, tbInfos, ok); err != nil {
+	dedup, err := checkDistinct(node, fields, aggTyp, router, tbInfos, ok)
+	if err != nil {
 		return nil, err
 	}
 
-	if err = root.pushSelectExprs(fields, groups, node, aggTyp); err != nil {
+	// The aggregates in the having and the exprs in the order by which are not in the
+	// select list are fetched as the hidden fields.
+	sel := node
+	visible := len(fields)
+	pushHaving := ok && len(node.GroupBy) > 0 && groups == nil
+	if hidden := parseHiddenExprs(node, fields, windows, pushHaving); len(hidden) > 0 {
+		if dedup {
+			return nil, errors.Errorf("unsupported: distinct.with.exprs.not.in.select.list")
+		}
+		copied := *node
+		copied.SelectExprs = append(append(sqlparser.SelectExprs(nil), node.SelectExprs...), hidden...)
+		if fields, aggTyp, err = parseSelectExprs(copied.SelectExprs, root); err != nil {
+			return nil, err
+		}
+		for i := visible; i < len(fields); i++ {
+			fields[i].hidden = true
+		}
+		sel = &copied
+	}
+
+	if err = root.pushSelectExprs(fields, groups, sel, aggTyp); err != nil {
 		return nil, err
 	}
 
@@ -92,10 +160,26 @@ func processSelect(log *xlog.Log, router *router.Router, database string, node *
 		}
 	}
 
+	if len(windows) > 0 {
+		if err = root.pushWindows(windows); err != nil {
+			return nil, err
+		}
+	}
+
 	if len(node.OrderBy) > 0 {
 		if err = root.pushOrderBy(node.OrderBy); err != nil {
 			return nil, err
 		}
+	} else if len(fields) > visible {
+		removeHiddenFields(root)
+	}
+
+	// Deduplicate after sorting, the first row of the duplicates is kept,
+	// so the rows are still in order.
+	if dedup {
+		if err = root.pushDistinct(); err != nil {
+			return nil, err
+		}
 	}
 
 	// Limit SubPlan.
@@ -111,6 +195,10 @@ func processSelect(log *xlog.Log, router *router.Router, database string, node *
 
 // processUnion used to process union.
 func processUnion(log *xlog.Log, router *router.Router, database string, node *sqlparser.Union) (PlanNode, error) {
+	if name := xparser.RecursiveCTE(node.Right); name != "" {
+		return processRecursive(log, router, database, node, name)
+	}
+
 	left, err := processPart(log, router, database, node.Left)
 	if err != nil {
 		return nil, err
@@ -128,18 +216,6 @@ func processPart(log *xlog.Log, router *router.Router, database string, part sql
 	case *sqlparser.Union:
 		return processUnion(log, router, database, part)
 	case *sqlparser.Select:
-		if len(part.From) == 1 {
-			if aliasExpr, ok := part.From[0].(*sqlparser.AliasedTableExpr); ok {
-				if tb, ok := aliasExpr.Expr.(sqlparser.TableName); ok && tb.Name.String() == "dual" {
-					m := newMergeNode(log, router)
-					m.Sel = part
-					m.routeLen = 1
-					m.nonGlobalCnt = 0
-					m.ReqMode = xcontext.ReqSingle
-					return m, nil
-				}
-			}
-		}
 		node, err := processSelect(log, router, database, part)
 		if err != nil {
 			return nil, err
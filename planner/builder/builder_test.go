@@ -10,6 +10,7 @@
 package builder
 
 import (
+	"encoding/json"
 	"testing"
 
 	"github.com/sealdb/neodb/router"
@@ -211,12 +212,12 @@ func TestProcessSelect(t *testing.T) {
 			project: "tmp, b, sum(id), count(id)",
 			out: []xcontext.QueryTuple{
 				{
-					Query:   "select id as tmp, b, id as `sum(id)`, id as `count(id)` from sbtest.B0 as B group by b order by b asc",
+					Query:   "select id as tmp, b, id as `sum(id)`, id as `count(id)` from sbtest.B0 as B order by b asc",
 					Backend: "backend1",
 					Range:   "[0-512)",
 				},
 				{
-					Query:   "select id as tmp, b, id as `sum(id)`, id as `count(id)` from sbtest.B1 as B group by b order by b asc",
+					Query:   "select id as tmp, b, id as `sum(id)`, id as `count(id)` from sbtest.B1 as B order by b asc",
 					Backend: "backend2",
 					Range:   "[512-4096)",
 				}},
@@ -490,28 +491,20 @@ func TestSelectDatabaseIsNull(t *testing.T) {
 
 func TestSelectUnsupported(t *testing.T) {
 	querys := []string{
-		"select * from A as A1 where id in (select id from B)",
-		"select distinct(b) from A",
+		"select * from A as A1 where exists (select id from B where B.id=A1.id)",
 		"select * from A join B on B.id=A.id",
 		"select id from A limit x",
-		"select age,count(*) from A group by age having count(*) >=2",
 		"select * from A where B.a >1",
 		"select count() from A",
-		"select round(avg(id)) from A",
-		"select id,group_concat(distinct name) from A group by id",
+		"select id,group_concat(distinct name order by 2) from A group by id",
 		"select next value for A",
 		"select A.*,(select b.str from b where A.id=B.id) str from A",
-		"select avg(id)*1000 from A",
 		"select avg(*) from A",
 		"select B.* from A",
 		"select * from D,A",
 		"select * from A where a>1 having count(a) >3",
 		"select a,b from A group by B.a",
 		"select *,avg(a) from A",
-		"select sum(A.id) as tmp, B.id from A,B having tmp=1",
-		"select COALESCE(B.b, ''), IF(B.b IS NULL, FALSE, TRUE) AS spent from A left join B on A.a=B.a",
-		"select abs(B.a) AS spent,G.a from A left join B on A.a=B.a,G",
-		"select abs(B.a) AS spent,G.a from G,A left join B on A.a=B.a",
 		"select A.id from A left join B on A.id=B.id right join G on A.id = G.id where length(B.str) is null",
 		"select A.id from A left join B on A.id=B.id left join G on A.id = G.id and abs(B.a)",
 		"select A.id from A left join B on A.id=B.id join G on A.id = G.id and abs(B.a) > G.a",
@@ -521,37 +514,30 @@ func TestSelectUnsupported(t *testing.T) {
 		"select A.id from G, A left join B on A.id=B.id where abs(B.a) > G.a",
 		"select A.id from (G, A left join B on A.id=B.id),C where abs(B.a) > G.a",
 		"select A.id from C,(G, A left join B on A.id=B.id) where abs(B.a+B.b) > G.a",
-		"select a+1 from A order by a+1",
 		"select b as a from A group by A.a",
 		"select a+1 from A group by a+1",
 		"select count(distinct *) from A",
+		"select bit_and(distinct a) from A",
+		"select json_objectagg(a) from A",
 		"select t1.a from G",
 		"select S.id from A join B on B.id=A.id",
 		"select eeeee from A join B on B.id=A.id",
 	}
 	results := []string{
-		"unsupported: subqueries.in.select",
-		"unsupported: distinct",
+		"unsupported: correlated.subquery.column.'A1.id'",
 		"unsupported: '*'.expression.in.cross-shard.query",
 		"unsupported: limit.offset.or.counts.must.be.IntVal",
-		"unsupported: expr[count(*)].in.having.clause",
 		"unsupported: unknown.column.'B.a'.in.clause",
 		"unsupported: invalid.use.of.group.function[count]",
-		"unsupported: 'round(avg(id))'.contain.aggregate.in.select.exprs",
-		"unsupported: group_concat.in.select.exprs",
+		"unsupported: unknown.column.'2'.in.order.clause",
 		"unsupported: nextval.in.select.exprs",
 		"unsupported: subqueries.in.select.exprs",
-		"unsupported: 'avg(id) * 1000'.contain.aggregate.in.select.exprs",
 		"unsupported: syntax.error.at.'avg(*)'",
 		"unsupported:  unknown.table.'B'.in.field.list",
 		"Table 'D' doesn't exist (errno 1146) (sqlstate 42S02)",
-		"unsupported: expr[count(a)].in.having.clause",
+		"unsupported: exists.aggregate.and.'*'.select.exprs",
 		"unsupported: unknow.table.in.group.by.field[B.a]",
 		"unsupported: exists.aggregate.and.'*'.select.exprs",
-		"unsupported: aggregation.in.having.clause",
-		"unsupported: expr.'COALESCE(B.b, '')'.in.cross-shard.left.join",
-		"unsupported: expr.'abs(B.a)'.in.cross-shard.left.join",
-		"unsupported: expr.'abs(B.a)'.in.cross-shard.left.join",
 		"unsupported: expr.'length(B.str)'.in.cross-shard.left.join",
 		"unsupported: expr.'abs(B.a)'.in.cross-shard.left.join",
 		"unsupported: expr.'abs(B.a)'.in.cross-shard.left.join",
@@ -561,10 +547,11 @@ func TestSelectUnsupported(t *testing.T) {
 		"unsupported: expr.'abs(B.a)'.in.cross-shard.left.join",
 		"unsupported: expr.'abs(B.a)'.in.cross-shard.left.join",
 		"unsupported: expr.'abs(B.a + B.b)'.in.cross-shard.left.join",
-		"unsupported: orderby:[a + 1].type.should.be.colname",
 		"unsupported: group.by.field[A.a].should.be.in.select.list",
 		"unsupported: group.by.[a + 1].type.should.be.colname",
 		"unsupported: syntax.error.at.'count(distinct *)'",
+		"unsupported: syntax.error.at.'bit_and(distinct a)'",
+		"unsupported: invalid.use.of.group.function[json_objectagg]",
 		"unsupported: unknown.column.'t1.a'.in.exprs",
 		"unsupported: unknown.column.'S.id'.in.field.list",
 		"unsupported: unknown.column.'eeeee'.in.select.exprs",
@@ -624,6 +611,18 @@ func TestSelectSupported(t *testing.T) {
 		"select A.id from A left join B on B.id+1=A.id where B.str1+B.str2 is null",
 		"select A.id from A join B on A.id=B.id where A.id in (1,2) or B.a=1",
 		"select A.id from A join B on A.id = B.id join G on A.id+B.id<=G.id where A.str + B.str is null",
+		"select round(avg(id)) from A",
+		"select avg(id)*1000 from A",
+		"select COALESCE(B.b, ''), IF(B.b IS NULL, FALSE, TRUE) AS spent from A left join B on A.a=B.a",
+		"select abs(B.a) AS spent,G.a from A left join B on A.a=B.a,G",
+		"select abs(B.a) AS spent,G.a from G,A left join B on A.a=B.a",
+		"select A.a, B.a, sum(A.a)/count(B.a), A.a+B.a from A join B on A.id=B.id group by A.a, B.a",
+		"select age,count(*) from A group by age having count(*) >=2",
+		"select sum(A.id) as tmp, B.id from A,B having tmp=1",
+		"select a+1 from A order by a+1",
+		"select a, b from A order by 2 desc, a+b",
+		"select G.id, B.id, B.a from G,A,B where A.id=B.id having G.id=B.id and B.a=1 and 1=1",
+		"select A.a from A join B on A.id=B.id group by A.a having count(*) > 1 order by sum(B.a)/count(*)",
 	}
 
 	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
@@ -1112,7 +1111,8 @@ func TestUnionUnsupported(t *testing.T) {
 }
 
 func TestGenerateFieldQuery(t *testing.T) {
-	query := "select A.id+B.id from A join B on A.name=B.name"
+	// The expr cannot be computed in the proxy, so it's computed by the right node with the join vars.
+	query := "select json_array(A.id, B.id) from A join B on A.name=B.name"
 	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
 	database := "sbtest"
 
@@ -1130,20 +1130,20 @@ func TestGenerateFieldQuery(t *testing.T) {
 	assert.Nil(t, err)
 
 	got := plan.(*JoinNode).Right.(*MergeNode).GenerateFieldQuery().Query
-	want := "select :A_id + B.id as `A.id + B.id` from sbtest.B1 as B where 1 != 1"
+	want := "select json_array(:A_id, B.id) as `json_array(A.id, B.id)` from sbtest.B1 as B where 1 != 1"
 	assert.Equal(t, want, got)
 }
 
 func TestSelectPlanList(t *testing.T) {
 	querys := []string{
-		"select 1, sum(a),avg(a),a,b from sbtest.L where id>1 group by a,b order by a desc limit 10 offset 100",
+		"select 1, sum(a),avg(a),a,b from sbtest.L where id>=2 group by a,b order by a desc limit 10 offset 100",
 		"select L.a, L.b from L join L1 on L.a = L1.a where L1.id=1",
 		"select L.a, L.b from L, L1 where L.a = L1.a and L1.id=1",
 		"select L.a, L.b from L, L1 where L.a = L1.a and L1.id=1 and L.id=1",
 	}
 
 	wants := []int{
-		3,
+		2,
 		4,
 		4,
 		1,
@@ -1176,6 +1176,277 @@ func TestSelectPlanList(t *testing.T) {
 	}
 }
 
+func TestSelectPlanRange(t *testing.T) {
+	querys := []string{
+		"select * from RG where id>=100",
+		"select * from RG where id<=99",
+		"select * from RG where id between 100 and 199",
+		"select * from RG where id>50 and id<150 and b=1",
+		"select * from RG where id in (1,101) and id<100",
+		"select * from RG where id=1 or id=2",
+		"select * from RG where id>=100 or b=1",
+		"select * from L where id between 2 and 10",
+		"select * from L where 5>=id",
+		"select RG.a from RG join L on RG.id=L.id where RG.id>=200",
+		"select RG.a from RG join L on RG.a=L.a where RG.id>=200 and L.id<=4",
+	}
+
+	wants := []int{
+		2,
+		1,
+		1,
+		2,
+		1,
+		1,
+		3,
+		2,
+		2,
+		2,
+		2,
+	}
+
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	database := "sbtest"
+
+	route, cleanup := router.MockNewRouter(log)
+	defer cleanup()
+
+	err := route.CreateDatabase("sbtest")
+	assert.Nil(t, err)
+	err = route.AddForTest(database, router.MockTableRangeConfig(), router.MockTableListConfig())
+	assert.Nil(t, err)
+	for i, query := range querys {
+		node, err := sqlparser.Parse(query)
+		assert.Nil(t, err)
+
+		plan, err := BuildNode(log, route, database, node.(sqlparser.SelectStatement))
+		assert.Nil(t, err)
+		assert.Equal(t, wants[i], len(plan.GetQuery()), query)
+	}
+}
+
+func TestSelectPlanCompositeShardKey(t *testing.T) {
+	querys := []string{
+		"select * from CK where tenant_id=1",
+		"select * from CK where tenant_id=1 and user_id=2",
+		"select * from CK where (tenant_id, user_id)=(1,2)",
+		"select CK.a from CK join CK1 on CK.tenant_id=CK1.tenant_id and CK.user_id=CK1.user_id",
+		"select CK.a from CK, CK1 where CK.tenant_id=CK1.tenant_id and CK.user_id=CK1.user_id",
+		"select CK.a from CK join CK1 on CK.tenant_id=CK1.tenant_id where CK.user_id=CK1.user_id",
+		"select CK.a from CK join CK1 on CK.tenant_id=CK1.tenant_id",
+		"select CK.a from CK join CK1 on CK.tenant_id=CK1.user_id and CK.user_id=CK1.tenant_id",
+	}
+
+	wants := []int{
+		2,
+		1,
+		2,
+		2,
+		2,
+		2,
+		4,
+		4,
+	}
+
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	database := "sbtest"
+
+	route, cleanup := router.MockNewRouter(log)
+	defer cleanup()
+
+	err := route.CreateDatabase("sbtest")
+	assert.Nil(t, err)
+	err = route.AddForTest(database, router.MockTableCKConfig(), router.MockTableCK1Config())
+	assert.Nil(t, err)
+	for i, query := range querys {
+		node, err := sqlparser.Parse(query)
+		assert.Nil(t, err)
+
+		plan, err := BuildNode(log, route, database, node.(sqlparser.SelectStatement))
+		assert.Nil(t, err)
+		assert.Equal(t, wants[i], len(plan.GetQuery()), query)
+	}
+}
+
+func TestSelectPlanColocate(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	database := "sbtest"
+
+	route, cleanup := router.MockNewRouter(log)
+	defer cleanup()
+
+	err := route.CreateDatabase(database)
+	assert.Nil(t, err)
+	err = route.CreateHashTable(database, "t1", "id", router.TableTypePartitionHash, []string{"backend1", "backend2"}, sqlparser.NewIntVal([]byte("8")), nil)
+	assert.Nil(t, err)
+	err = route.CreateColocateTable(database, "t2", "", "", "t1", nil)
+	assert.Nil(t, err)
+
+	build := func() int {
+		node, err := sqlparser.Parse("select t1.a from t1 join t2 on t1.id=t2.id")
+		assert.Nil(t, err)
+		plan, err := BuildNode(log, route, database, node.(sqlparser.SelectStatement))
+		assert.Nil(t, err)
+		return len(plan.GetQuery())
+	}
+
+	// The members of the group are colocated.
+	{
+		assert.Equal(t, 8, build())
+	}
+
+	// One member is shifted before the others.
+	done := route.ShiftColocated(database, []string{"t1_0000", "t2_0000"})
+	defer done()
+	{
+		err := route.PartitionRuleShift("backend1", "backend3", database, "t2_0000")
+		assert.Nil(t, err)
+		assert.Equal(t, 16, build())
+	}
+
+	// The others are shifted too.
+	{
+		err := route.PartitionRuleShift("backend1", "backend3", database, "t1_0000")
+		assert.Nil(t, err)
+		assert.Equal(t, 8, build())
+	}
+}
+
+func TestSelectPlanHashJoin(t *testing.T) {
+	tcases := []struct {
+		query    string
+		out      []string
+		strategy JoinStrategy
+	}{
+		// The hint forces the hash join, the rows needn't be sorted by the backends.
+		{
+			query: "select /*+ hash_join */ A.a, B.a from A join B on A.a = B.a where A.id = 1",
+			out: []string{
+				"backend6: select /*+ hash_join */ A.a from sbtest.A6 as A where A.id = 1",
+				"backend1: select /*+ hash_join */ B.a from sbtest.B0 as B",
+				"backend2: select /*+ hash_join */ B.a from sbtest.B1 as B",
+			},
+			strategy: HashJoin,
+		},
+		// The right side is much smaller than the left.
+		{
+			query: "select A.a, B.a from A join B on A.a = B.a where B.id = 1",
+			out: []string{
+				"backend1: select A.a from sbtest.A1 as A",
+				"backend2: select A.a from sbtest.A2 as A",
+				"backend3: select A.a from sbtest.A3 as A",
+				"backend4: select A.a from sbtest.A4 as A",
+				"backend5: select A.a from sbtest.A5 as A",
+				"backend6: select A.a from sbtest.A6 as A",
+				"backend2: select B.a from sbtest.B1 as B where B.id = 1",
+			},
+			strategy: HashJoin,
+		},
+		{
+			query: "select A.a, B.a from A join B on A.a = B.a",
+			out: []string{
+				"backend1: select A.a from sbtest.A1 as A order by A.a asc",
+				"backend2: select A.a from sbtest.A2 as A order by A.a asc",
+				"backend3: select A.a from sbtest.A3 as A order by A.a asc",
+				"backend4: select A.a from sbtest.A4 as A order by A.a asc",
+				"backend5: select A.a from sbtest.A5 as A order by A.a asc",
+				"backend6: select A.a from sbtest.A6 as A order by A.a asc",
+				"backend1: select B.a from sbtest.B0 as B order by B.a asc",
+				"backend2: select B.a from sbtest.B1 as B order by B.a asc",
+			},
+			strategy: SortMerge,
+		},
+		// Without the equal conditions, the hint is ignored.
+		{
+			query: "select /*+ hash_join */ A.a, B.a from A join B on A.a > B.a where A.id = 1",
+			out: []string{
+				"backend6: select /*+ hash_join */ A.a from sbtest.A6 as A where A.id = 1",
+				"backend1: select /*+ hash_join */ B.a from sbtest.B0 as B",
+				"backend2: select /*+ hash_join */ B.a from sbtest.B1 as B",
+			},
+			strategy: SortMerge,
+		},
+	}
+
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	database := "sbtest"
+
+	route, cleanup := router.MockNewRouter(log)
+	defer cleanup()
+
+	err := route.CreateDatabase(database)
+	assert.Nil(t, err)
+	err = route.AddForTest(database, router.MockTableMConfig(), router.MockTableBConfig())
+	assert.Nil(t, err)
+	for _, tcase := range tcases {
+		node, err := sqlparser.Parse(tcase.query)
+		assert.Nil(t, err)
+		p, err := BuildNode(log, route, database, node.(sqlparser.SelectStatement))
+		assert.Nil(t, err, tcase.query)
+		assert.Equal(t, tcase.out, querysOf(p), tcase.query)
+		j, ok := p.(*JoinNode)
+		assert.True(t, ok, tcase.query)
+		assert.Equal(t, tcase.strategy, j.Strategy, tcase.query)
+	}
+}
+
+func TestSelectPlanBatchJoin(t *testing.T) {
+	tcases := []struct {
+		query string
+		keys  string
+		batch []string
+	}{
+		// B is looked up by the shard key.
+		{
+			query: "select S.a from A join B on A.id=B.id join S on A.a+B.a>S.a",
+			keys:  `[{"Var":"A_id","LeftIdx":1,"RightIdx":1,"Table":"B"}]`,
+			batch: []string{
+				"select B.a, B.id from sbtest.B0 as B where B.id in ::A_id",
+				"select B.a, B.id from sbtest.B1 as B where B.id in ::A_id",
+			},
+		},
+		{
+			query: "select S.a from A join B on A.a=B.a and B.b=A.b join S on A.a+B.a>S.a where B.id=1",
+			keys:  `[{"Var":"A_a","LeftIdx":0,"RightIdx":0},{"Var":"A_b","LeftIdx":1,"RightIdx":1}]`,
+			batch: []string{
+				"select B.a, B.b from sbtest.B1 as B where B.id = 1 and B.a in ::A_a and B.b in ::A_b",
+			},
+		},
+	}
+
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	database := "sbtest"
+
+	route, cleanup := router.MockNewRouter(log)
+	defer cleanup()
+
+	err := route.CreateDatabase(database)
+	assert.Nil(t, err)
+	err = route.AddForTest(database, router.MockTableMConfig(), router.MockTableBConfig(), router.MockTableSConfig())
+	assert.Nil(t, err)
+	for _, tcase := range tcases {
+		node, err := sqlparser.Parse(tcase.query)
+		assert.Nil(t, err)
+		p, err := BuildNode(log, route, database, node.(sqlparser.SelectStatement))
+		assert.Nil(t, err, tcase.query)
+
+		// S refers to A and B by the non-equal condition.
+		j := p.(*JoinNode)
+		assert.Equal(t, NestLoop, j.Strategy)
+		assert.Nil(t, j.BatchKeys)
+
+		left := j.Left.(*JoinNode)
+		keys, err := json.Marshal(left.BatchKeys)
+		assert.Nil(t, err)
+		assert.Equal(t, tcase.keys, string(keys), tcase.query)
+		var batch []string
+		for _, pq := range left.Right.(*MergeNode).BatchQuerys {
+			batch = append(batch, pq.Query)
+		}
+		assert.Equal(t, tcase.batch, batch, tcase.query)
+	}
+}
+
 func TestSelectSupportedPlanList(t *testing.T) {
 	querys := []string{
 		"select id,rand(id) from L",
@@ -21,6 +21,18 @@ const (
 
 	// ChildTypeAggregate enum.
 	ChildTypeAggregate ChildType = "ChildTypeAggregate"
+
+	// ChildTypeDistinct enum.
+	ChildTypeDistinct ChildType = "ChildTypeDistinct"
+
+	// ChildTypeProject enum.
+	ChildTypeProject ChildType = "ChildTypeProject"
+
+	// ChildTypeHaving enum.
+	ChildTypeHaving ChildType = "ChildTypeHaving"
+
+	// ChildTypeWindow enum.
+	ChildTypeWindow ChildType = "ChildTypeWindow"
 )
 
 // ChildPlan interface.
@@ -20,6 +20,10 @@ import (
 
 func checkTbName(tbInfos map[string]*tableInfo, node sqlparser.SQLNode) error {
 	return sqlparser.Walk(func(node sqlparser.SQLNode) (kontinue bool, err error) {
+		if _, ok := node.(*sqlparser.Subquery); ok {
+			// The subquery has been checked when it is built.
+			return false, nil
+		}
 		if col, ok := node.(*sqlparser.ColName); ok {
 			tableName := col.Qualifier.Name.String()
 			if tableName != "" {
@@ -56,14 +60,17 @@ func isContainKey(a []string, b string) bool {
 	return false
 }
 
-// fetchIndex used to fetch index from router.
-func fetchIndex(tbInfo *tableInfo, val *sqlparser.SQLVal, router *router.Router) error {
-	idx, err := router.GetIndex(tbInfo.database, tbInfo.tableName, val)
+// fetchIndex used to fetch the indexes from router by the table's shard range,
+// and rebuild the indexes of the parent.
+func fetchIndex(tbInfo *tableInfo, router *router.Router) error {
+	indexes, err := tbInfo.shardRange.lookup(tbInfo.database, tbInfo.tableName, router)
 	if err != nil {
 		return err
 	}
 
-	tbInfo.parent.indexes = append(tbInfo.parent.indexes, idx)
+	tbInfo.indexes = indexes
+	tbInfo.prune = tbInfo.shardRange.lookupPrune(tbInfo.database, tbInfo.tableName, router)
+	tbInfo.parent.(*MergeNode).rebuildIndexes()
 	return nil
 }
 
@@ -0,0 +1,436 @@
+/*
+ * NeoDB
+ *
+ * Copyright 2021-2030 The NeoDB Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package builder
+
+import (
+	"strings"
+
+	"github.com/sealdb/neodb/router"
+	"github.com/sealdb/neodb/xcontext"
+
+	"github.com/pkg/errors"
+	"github.com/sealdb/mysqlstack/sqlparser"
+	"github.com/sealdb/mysqlstack/sqlparser/depends/sqltypes"
+	"github.com/sealdb/mysqlstack/xlog"
+)
+
+// DerivedColumn is the column of the DerivedNode's result.
+type DerivedColumn struct {
+	// Field is the column name in the derived table, "*" means all the columns, empty means the Value.
+	Field string `json:",omitempty"`
+	// Alias is the column name in the result.
+	Alias string `json:",omitempty"`
+	// Value is the constant of the column.
+	Value sqltypes.Value `json:"-"`
+}
+
+// DerivedNode represents the derived table whose query is cross-shard.
+// eg: select t.a, count(*) from (select a from t1 where b > 1) as t where t.a < 10 group by t.a;
+// The filters on the derived table are pushed into its query, the query is executed and its
+// result is materialized in the proxy, then the aggregation, order by and limit run on it.
+type DerivedNode struct {
+	log      *xlog.Log
+	router   *router.Router
+	database string
+	// Inner is the plan of the derived table's query.
+	Inner PlanNode
+	// sel is the derived table's query with the pushed filters.
+	sel sqlparser.SelectStatement
+	// alias of the derived table.
+	alias string
+	// referred tables' tableInfo map.
+	referTables map[string]*tableInfo
+	// parent node in the plan tree.
+	parent *JoinNode
+	// children plans in select(such as: orderby, limit..).
+	children []ChildPlan
+	// Columns are the columns projected from the derived table.
+	Columns []DerivedColumn `json:",omitempty"`
+	// Filters on the union derived table are evaluated on its result in the proxy,
+	// the columns are unqualified.
+	Filters []sqlparser.Expr `json:"-"`
+	// the returned result fields.
+	fields []selectTuple
+	// The filters without tables, pushed into the Inner.
+	noTableFilter []sqlparser.Expr
+	order         int
+}
+
+// scanDerivedTable builds the plannode of the derived table.
+// If the derived table's query routes to a single backend, it is pushed down with the outer query
+// as a MergeNode, otherwise a DerivedNode is built.
+func scanDerivedTable(log *xlog.Log, r *router.Router, database string, tableExpr *sqlparser.AliasedTableExpr, sub *sqlparser.Subquery) (PlanNode, error) {
+	alias := tableExpr.As.String()
+	query := sqlparser.String(sub.Select)
+	inner, err := BuildNode(log, r, database, sub.Select)
+	if err != nil {
+		return nil, err
+	}
+
+	tbInfo := &tableInfo{
+		database:  database,
+		tableName: alias,
+		alias:     alias,
+		tableExpr: tableExpr,
+	}
+	if mn, ok := inner.(*MergeNode); ok && mn.routeLen == 1 {
+		return newDerivedMergeNode(log, r, tbInfo, mn), nil
+	}
+
+	// The sub.Select has been rewritten by the build, keep the origin one.
+	stmt, err := sqlparser.Parse(query)
+	if err != nil {
+		return nil, err
+	}
+	sel, ok := stmt.(sqlparser.SelectStatement)
+	if !ok {
+		return nil, errors.Errorf("unsupported: derived.table.'%s'.query[%s]", alias, query)
+	}
+	d := &DerivedNode{
+		log:         log,
+		router:      r,
+		database:    database,
+		sel:         sel,
+		alias:       alias,
+		referTables: map[string]*tableInfo{alias: tbInfo},
+	}
+	tbInfo.parent = d
+	return d, nil
+}
+
+// newDerivedMergeNode creates the MergeNode which pushes down the derived table with the outer query.
+func newDerivedMergeNode(log *xlog.Log, r *router.Router, tbInfo *tableInfo, inner *MergeNode) *MergeNode {
+	mn := newMergeNode(log, r)
+	tbInfo.tableExpr.Expr = &sqlparser.Subquery{Select: inner.Sel}
+	tbInfo.derived = inner
+	tbInfo.parent = mn
+	mn.referTables[tbInfo.alias] = tbInfo
+	mn.nonGlobalCnt = inner.nonGlobalCnt
+	// The derived table from dual is executed on any backend.
+	mn.ReqMode = inner.ReqMode
+	mn.Sel = &sqlparser.Select{From: sqlparser.TableExprs([]sqlparser.TableExpr{tbInfo.tableExpr})}
+	return mn
+}
+
+// build used to build the plan of the derived table's query.
+func (d *DerivedNode) build() error {
+	// The build rewrites the ast, so build from a copy.
+	stmt, err := sqlparser.Parse(sqlparser.String(d.sel))
+	if err != nil {
+		return err
+	}
+	d.Inner, err = processSelectStatement(d.log, d.router, d.database, stmt.(sqlparser.SelectStatement))
+	return err
+}
+
+// replaceCols replaces the columns of the derived table in the expr with the select exprs of its query.
+// eg: `t.tmp > 1` in `select b from (select a+1 as tmp, b from t1) as t where t.tmp > 1`
+// is replaced as `a + 1 > 1`.
+func (d *DerivedNode) replaceCols(expr sqlparser.Expr) (sqlparser.Expr, error) {
+	var err error
+	expr = sqlparser.Rewrite(sqlparser.CloneExpr(expr), func(cursor *sqlparser.Cursor) bool {
+		if err != nil {
+			return false
+		}
+		col, ok := cursor.Node().(*sqlparser.ColName)
+		if !ok {
+			return true
+		}
+		if table := col.Qualifier.Name.String(); table != "" && table != d.alias {
+			// Only the nested loop join pushes the cross-shard filters to the leaf node.
+			err = errors.Errorf("unsupported: cross-shard.derived.table.'%s'.in.nested.loop.join", d.alias)
+			return false
+		}
+
+		var inner sqlparser.Expr
+		if inner, err = d.innerExpr(col.Name.String()); err == nil {
+			cursor.Replace(inner)
+		}
+		return false
+	}, nil).(sqlparser.Expr)
+	return expr, err
+}
+
+// innerExpr returns the select expr of the derived table's query by the column name.
+func (d *DerivedNode) innerExpr(name string) (sqlparser.Expr, error) {
+	hasStar := false
+	for _, expr := range d.sel.(*sqlparser.Select).SelectExprs {
+		switch expr := expr.(type) {
+		case *sqlparser.StarExpr:
+			hasStar = true
+		case *sqlparser.AliasedExpr:
+			field := expr.As.String()
+			if field == "" {
+				if col, ok := expr.Expr.(*sqlparser.ColName); ok {
+					field = col.Name.String()
+				} else {
+					field = sqlparser.String(expr.Expr)
+				}
+			}
+			if !strings.EqualFold(field, name) {
+				continue
+			}
+
+			aggregate := false
+			_ = sqlparser.Walk(func(node sqlparser.SQLNode) (kontinue bool, err error) {
+				switch node := node.(type) {
+				case *sqlparser.FuncExpr:
+					aggregate = aggregate || isAggregate(node)
+				case *sqlparser.GroupConcatExpr:
+					aggregate = true
+				}
+				return !aggregate, nil
+			}, expr.Expr)
+			if aggregate {
+				return nil, errors.New("unsupported: aggregation.field.in.subquery.is.used.in.clause")
+			}
+			return sqlparser.CloneExpr(expr.Expr), nil
+		}
+	}
+
+	if hasStar {
+		return &sqlparser.ColName{Name: sqlparser.NewColIdent(name)}, nil
+	}
+	return nil, errors.Errorf("unsupported: unknown.column.name.'%s'", name)
+}
+
+// Alias returns the alias of the derived table.
+func (d *DerivedNode) Alias() string {
+	return d.alias
+}
+
+// getReferTables get the referTables.
+func (d *DerivedNode) getReferTables() map[string]*tableInfo {
+	return d.referTables
+}
+
+// getFields get the fields.
+func (d *DerivedNode) getFields() []selectTuple {
+	return d.fields
+}
+
+// pushFilter used to push the filter into the derived table's query.
+func (d *DerivedNode) pushFilter(filter exprInfo) error {
+	sel, ok := d.sel.(*sqlparser.Select)
+	if !ok {
+		return d.pushUnionFilter(filter)
+	}
+	if sel.Limit != nil {
+		return errors.Errorf("unsupported: filter.on.derived.table.'%s'.with.limit", d.alias)
+	}
+
+	expr, err := d.replaceCols(filter.expr)
+	if err != nil {
+		return err
+	}
+	sel.AddWhere(expr)
+
+	// The filter is pushed after the route is calculated, rebuild the Inner.
+	if d.Inner != nil {
+		return d.build()
+	}
+	return nil
+}
+
+// pushUnionFilter used to keep the filter on the union derived table, which is evaluated
+// on the union's result in the proxy.
+func (d *DerivedNode) pushUnionFilter(filter exprInfo) error {
+	var err error
+	expr := sqlparser.Rewrite(sqlparser.CloneExpr(filter.expr), func(cursor *sqlparser.Cursor) bool {
+		col, ok := cursor.Node().(*sqlparser.ColName)
+		if !ok || err != nil {
+			return err == nil
+		}
+		if table := col.Qualifier.Name.String(); table != "" && table != d.alias {
+			err = errors.Errorf("unsupported: cross-shard.derived.table.'%s'.in.nested.loop.join", d.alias)
+			return false
+		}
+		cursor.Replace(&sqlparser.ColName{Name: col.Name})
+		return false
+	}, nil).(sqlparser.Expr)
+	if err != nil {
+		return err
+	}
+	if !canCompute(expr) {
+		return errors.Errorf("unsupported: filter.'%s'.on.union.derived.table.'%s'", sqlparser.String(expr), d.alias)
+	}
+	d.Filters = append(d.Filters, expr)
+	return nil
+}
+
+// pushKeyFilter used to push the key filter into the derived table's query.
+func (d *DerivedNode) pushKeyFilter(filter exprInfo, table, field string) error {
+	return d.pushFilter(filter)
+}
+
+// setParent set the parent node.
+func (d *DerivedNode) setParent(p *JoinNode) {
+	d.parent = p
+}
+
+// addNoTableFilter used to push the no table filters.
+func (d *DerivedNode) addNoTableFilter(exprs []sqlparser.Expr) {
+	d.noTableFilter = append(d.noTableFilter, exprs...)
+}
+
+// calcRoute used to build the derived table's query, if the pushed filters make it
+// route to a single backend, the derived table is pushed down with the outer query.
+func (d *DerivedNode) calcRoute() (PlanNode, error) {
+	if err := d.build(); err != nil {
+		return nil, err
+	}
+
+	if mn, ok := d.Inner.(*MergeNode); ok && mn.routeLen == 1 && len(d.Filters) == 0 {
+		mn.buildQuery(mn)
+		m := newDerivedMergeNode(d.log, d.router, d.referTables[d.alias], mn)
+		m.setParent(d.parent)
+		return m.calcRoute()
+	}
+	return d, nil
+}
+
+// pushSelectExprs used to push the select fields.
+func (d *DerivedNode) pushSelectExprs(fields, groups []selectTuple, sel *sqlparser.Select, aggTyp aggrType) error {
+	children, tuples, projected, err := buildSelectPlans(d.log, fields, groups, sel.SelectExprs, len(groups) > 0 || aggTyp != nullAgg, d.computeInProxy(fields))
+	if err != nil {
+		return err
+	}
+	d.children = append(d.children, children...)
+
+	for _, tuple := range tuples {
+		if _, err := d.pushSelectExpr(tuple); err != nil {
+			return err
+		}
+	}
+	if projected != nil {
+		d.fields = projected
+	}
+	return nil
+}
+
+// computeInProxy returns the func reports whether the tuple is computed in the proxy,
+// the derived table only fetch the columns and the constants. If there's '*' in the
+// fields, the columns are unknown until the rows are fetched, so nothing is computed.
+func (d *DerivedNode) computeInProxy(fields []selectTuple) func(tuple selectTuple) bool {
+	for _, field := range fields {
+		if field.field == "*" {
+			return func(selectTuple) bool { return false }
+		}
+	}
+	return func(tuple selectTuple) bool {
+		aliased, ok := tuple.expr.(*sqlparser.AliasedExpr)
+		if !ok {
+			return false
+		}
+		switch aliased.Expr.(type) {
+		case *sqlparser.ColName, *sqlparser.SQLVal, *sqlparser.NullVal:
+			return false
+		}
+		return canCompute(aliased.Expr)
+	}
+}
+
+// pushSelectExpr used to push the select field, only the columns and the constants are supported.
+func (d *DerivedNode) pushSelectExpr(field selectTuple) (int, error) {
+	col := DerivedColumn{Alias: field.alias}
+	switch expr := field.expr.(type) {
+	case *sqlparser.StarExpr:
+		col.Field = "*"
+	case *sqlparser.AliasedExpr:
+		switch e := expr.Expr.(type) {
+		case *sqlparser.ColName:
+			col.Field = e.Name.String()
+		case *sqlparser.SQLVal, *sqlparser.NullVal:
+			pv, err := sqlparser.NewPlanValue(e)
+			if err != nil {
+				return -1, err
+			}
+			col.Value = pv.Value
+		default:
+			return -1, errors.Errorf("unsupported: expr.'%s'.on.cross-shard.derived.table", field.field)
+		}
+		if col.Alias == "" {
+			col.Alias = field.field
+		}
+	}
+
+	d.Columns = append(d.Columns, col)
+	d.fields = append(d.fields, field)
+	return len(d.fields) - 1, nil
+}
+
+// pushHaving used to push having expr, the having refers to the fields is filtered in the proxy.
+func (d *DerivedNode) pushHaving(having exprInfo) error {
+	if len(having.referTables) == 0 && !havingInProxy(having, d.fields) {
+		return d.pushFilter(having)
+	}
+	var err error
+	d.children, err = pushHavingPlan(d.log, d.children, having.expr, d.fields)
+	return err
+}
+
+// pushWindows used to push the window functions computed in the proxy.
+func (d *DerivedNode) pushWindows(windows []windowTuple) error {
+	windowPlan := NewWindowPlan(d.log, windows, d.fields)
+	d.children = append(d.children, windowPlan)
+	return windowPlan.Build()
+}
+
+// pushOrderBy used to push the order by exprs.
+func (d *DerivedNode) pushOrderBy(orderBy sqlparser.OrderBy) error {
+	orderPlan := NewOrderByPlan(d.log, orderBy, d)
+	d.children = append(d.children, orderPlan)
+	return orderPlan.Build()
+}
+
+// pushDistinct used to deduplicate the rows of the derived table.
+func (d *DerivedNode) pushDistinct() error {
+	distinctPlan := NewDistinctPlan(d.log, d.fields)
+	d.children = append(d.children, distinctPlan)
+	return distinctPlan.Build()
+}
+
+// pushLimit used to push limit.
+func (d *DerivedNode) pushLimit(limit *sqlparser.Limit) error {
+	limitPlan := NewLimitPlan(d.log, limit)
+	d.children = append(d.children, limitPlan)
+	return limitPlan.Build()
+}
+
+// pushMisc used tp push miscelleaneous constructs.
+func (d *DerivedNode) pushMisc(sel *sqlparser.Select) {
+}
+
+// Children returns the children of the plan.
+func (d *DerivedNode) Children() []ChildPlan {
+	return d.children
+}
+
+// reOrder satisfies the plannode interface.
+func (d *DerivedNode) reOrder(order int) {
+	d.order = order + 1
+}
+
+// Order satisfies the plannode interface.
+func (d *DerivedNode) Order() int {
+	return d.order
+}
+
+// buildQuery used to build the QueryTuple.
+func (d *DerivedNode) buildQuery(root PlanNode) {
+	if len(d.noTableFilter) > 0 {
+		d.Inner.addNoTableFilter(d.noTableFilter)
+	}
+	d.Inner.buildQuery(d.Inner)
+}
+
+// GetQuery used to get the Querys.
+func (d *DerivedNode) GetQuery() []xcontext.QueryTuple {
+	return d.Inner.GetQuery()
+}
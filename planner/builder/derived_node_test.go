@@ -0,0 +1,312 @@
+/*
+ * NeoDB
+ *
+ * Copyright 2021-2030 The NeoDB Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package builder
+
+import (
+	"testing"
+
+	"github.com/sealdb/neodb/router"
+
+	"github.com/sealdb/mysqlstack/sqlparser"
+	"github.com/sealdb/mysqlstack/xlog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDerivedPushDown(t *testing.T) {
+	tcases := []struct {
+		query string
+		out   []string
+	}{
+		// Single backend.
+		{
+			query: "select * from (select * from B where id = 1) as t",
+			out: []string{
+				"backend2: select * from (select * from sbtest.B1 as B where id = 1) as t",
+			},
+		},
+		// Join the global table.
+		{
+			query: "select t.a from (select a from B where id = 1) as t join G on t.a = G.a where t.a > 1",
+			out: []string{
+				"backend2: select t.a from (select a from sbtest.B1 as B where id = 1) as t join sbtest.G on t.a = G.a where t.a > 1",
+			},
+		},
+		// The global derived table joins the shard table.
+		{
+			query: "select * from (select a from G) as t join B on t.a = B.id",
+			out: []string{
+				"backend1: select * from (select a from sbtest.G) as t join sbtest.B0 as B on t.a = B.id",
+				"backend2: select * from (select a from sbtest.G) as t join sbtest.B1 as B on t.a = B.id",
+			},
+		},
+		// The pushed filters make the query route to a single backend.
+		{
+			query: "select * from (select id, a as x from B) as t where t.x = 3 and t.id = 1",
+			out: []string{
+				"backend2: select * from (select id, a as x from sbtest.B1 as B where a = 3 and id = 1) as t",
+			},
+		},
+		// The derived table from dual.
+		{
+			query: "select t.n + 1 from (select 1 as n) as t where t.n < 5",
+			out: []string{
+				": select t.n + 1 from (select 1 as n from dual) as t where t.n < 5",
+			},
+		},
+		// The derived table from dual joins the shard table.
+		{
+			query: "select B.a from B join (select 1 as id union all select 2) as t on B.id = t.id",
+			out: []string{
+				"backend1: select B.a from sbtest.B0 as B join (select 1 as id from dual union all select 2 from dual) as t on B.id = t.id",
+				"backend2: select B.a from sbtest.B1 as B join (select 1 as id from dual union all select 2 from dual) as t on B.id = t.id",
+			},
+		},
+	}
+
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	database := "sbtest"
+
+	route, cleanup := router.MockNewRouter(log)
+	defer cleanup()
+
+	err := route.CreateDatabase(database)
+	assert.Nil(t, err)
+	err = route.AddForTest(database, router.MockTableMConfig(), router.MockTableBConfig(), router.MockTableGConfig())
+	assert.Nil(t, err)
+	for _, tcase := range tcases {
+		node, err := sqlparser.Parse(tcase.query)
+		assert.Nil(t, err)
+		p, err := BuildNode(log, route, database, node.(sqlparser.SelectStatement))
+		assert.Nil(t, err)
+		_, ok := p.(*MergeNode)
+		assert.True(t, ok, tcase.query)
+		assert.Equal(t, tcase.out, querysOf(p), tcase.query)
+	}
+}
+
+func TestDerivedNodeUnionFilter(t *testing.T) {
+	query := "select t.a from (select a from B union select b from B) as t where t.a > 1 and t.a + 1 < 5"
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	database := "sbtest"
+
+	route, cleanup := router.MockNewRouter(log)
+	defer cleanup()
+
+	err := route.CreateDatabase(database)
+	assert.Nil(t, err)
+	err = route.AddForTest(database, router.MockTableMConfig(), router.MockTableBConfig(), router.MockTableGConfig())
+	assert.Nil(t, err)
+
+	node, err := sqlparser.Parse(query)
+	assert.Nil(t, err)
+	p, err := BuildNode(log, route, database, node.(sqlparser.SelectStatement))
+	assert.Nil(t, err)
+	d, ok := p.(*DerivedNode)
+	assert.True(t, ok)
+	want := []string{
+		"backend1: select a from sbtest.B0 as B",
+		"backend2: select a from sbtest.B1 as B",
+		"backend1: select b from sbtest.B0 as B",
+		"backend2: select b from sbtest.B1 as B",
+	}
+	assert.Equal(t, want, querysOf(p))
+	assert.Equal(t, 2, len(d.Filters))
+	assert.Equal(t, "a > 1", sqlparser.String(d.Filters[0]))
+	assert.Equal(t, "a + 1 < 5", sqlparser.String(d.Filters[1]))
+}
+
+func TestDerivedNode(t *testing.T) {
+	tcases := []struct {
+		query    string
+		out      []string
+		cols     []DerivedColumn
+		children int
+	}{
+		{
+			query: "select t.a, count(*) from (select a, b from B) as t where t.b > 1 group by t.a order by t.a limit 2",
+			out: []string{
+				"backend1: select a, b from sbtest.B0 as B where b > 1",
+				"backend2: select a, b from sbtest.B1 as B where b > 1",
+			},
+			cols:     []DerivedColumn{{Field: "a", Alias: "a"}, {Alias: "count(*)"}},
+			children: 3,
+		},
+		// The exprs are computed in the proxy before and after the aggregation.
+		{
+			query: "select t.a, t.a+1 as x, sum(t.b)/count(*) from (select a, b from B) as t group by t.a",
+			out: []string{
+				"backend1: select a, b from sbtest.B0 as B",
+				"backend2: select a, b from sbtest.B1 as B",
+			},
+			cols:     []DerivedColumn{{Field: "a", Alias: "a"}, {Field: "b", Alias: "sum(t.b)"}, {Alias: "count(*)"}},
+			children: 3,
+		},
+		{
+			query: "select * from (select id, a as x from B) as t where t.x = 3",
+			out: []string{
+				"backend1: select id, a as x from sbtest.B0 as B where a = 3",
+				"backend2: select id, a as x from sbtest.B1 as B where a = 3",
+			},
+			cols: []DerivedColumn{{Field: "*"}},
+		},
+		{
+			query: "select t.a as c from (select a from B union select b from B) as t",
+			out: []string{
+				"backend1: select a from sbtest.B0 as B",
+				"backend2: select a from sbtest.B1 as B",
+				"backend1: select b from sbtest.B0 as B",
+				"backend2: select b from sbtest.B1 as B",
+			},
+			cols: []DerivedColumn{{Field: "a", Alias: "c"}},
+		},
+		{
+			query: "select * from (select * from (select a from B) as x) as t where 1 = 1",
+			out: []string{
+				"backend1: select a from sbtest.B0 as B where 1 = 1",
+				"backend2: select a from sbtest.B1 as B where 1 = 1",
+			},
+			cols: []DerivedColumn{{Field: "*"}},
+		},
+	}
+
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	database := "sbtest"
+
+	route, cleanup := router.MockNewRouter(log)
+	defer cleanup()
+
+	err := route.CreateDatabase(database)
+	assert.Nil(t, err)
+	err = route.AddForTest(database, router.MockTableMConfig(), router.MockTableBConfig(), router.MockTableGConfig())
+	assert.Nil(t, err)
+	for _, tcase := range tcases {
+		node, err := sqlparser.Parse(tcase.query)
+		assert.Nil(t, err)
+		p, err := BuildNode(log, route, database, node.(sqlparser.SelectStatement))
+		assert.Nil(t, err, tcase.query)
+		d, ok := p.(*DerivedNode)
+		assert.True(t, ok, tcase.query)
+		assert.Equal(t, "t", d.Alias())
+		assert.Equal(t, tcase.out, querysOf(p), tcase.query)
+		assert.Equal(t, len(tcase.cols), len(d.Columns), tcase.query)
+		for i, col := range tcase.cols {
+			assert.Equal(t, col.Field, d.Columns[i].Field, tcase.query)
+			assert.Equal(t, col.Alias, d.Columns[i].Alias, tcase.query)
+		}
+		assert.Equal(t, tcase.children, len(d.Children()), tcase.query)
+	}
+}
+
+func TestDerivedNodeJoin(t *testing.T) {
+	tcases := []struct {
+		query    string
+		out      []string
+		strategy JoinStrategy
+	}{
+		// The filter is pushed to both sides by the join key.
+		{
+			query: "select t.a, B.b from (select a, count(*) as c from A group by a) as t join B on t.a = B.a where t.a < 2",
+			out: []string{
+				"backend1: select a, count(*) as c from sbtest.A1 as A where a < 2 group by a order by a asc",
+				"backend2: select a, count(*) as c from sbtest.A2 as A where a < 2 group by a order by a asc",
+				"backend3: select a, count(*) as c from sbtest.A3 as A where a < 2 group by a order by a asc",
+				"backend4: select a, count(*) as c from sbtest.A4 as A where a < 2 group by a order by a asc",
+				"backend5: select a, count(*) as c from sbtest.A5 as A where a < 2 group by a order by a asc",
+				"backend6: select a, count(*) as c from sbtest.A6 as A where a < 2 group by a order by a asc",
+				"backend1: select B.b, B.a from sbtest.B0 as B where B.a < 2 order by B.a asc",
+				"backend2: select B.b, B.a from sbtest.B1 as B where B.a < 2 order by B.a asc",
+			},
+			strategy: SortMerge,
+		},
+		// The left join's on condition of the derived table.
+		{
+			query: "select t.a, B.b from B left join (select a, id from A) as t on t.a = B.a and t.id > 2 where B.id = 1",
+			out: []string{
+				"backend2: select B.b, B.a from sbtest.B1 as B where B.id = 1",
+				"backend1: select a, id from sbtest.A1 as A where id > 2",
+				"backend2: select a, id from sbtest.A2 as A where id > 2",
+				"backend3: select a, id from sbtest.A3 as A where id > 2",
+				"backend4: select a, id from sbtest.A4 as A where id > 2",
+				"backend5: select a, id from sbtest.A5 as A where id > 2",
+				"backend6: select a, id from sbtest.A6 as A where id > 2",
+			},
+			strategy: HashJoin,
+		},
+		// The derived table routing to a single backend is the left side of the nested loop join.
+		{
+			query: "select t.a, B.b from (select a from A where id = 1) as t join B where t.a = B.a or B.b = 1",
+			out: []string{
+				"backend6: select t.a from (select a from sbtest.A6 as A where id = 1) as t",
+				"backend1: select B.b from sbtest.B0 as B where (:t_a = B.a or B.b in (1))",
+				"backend2: select B.b from sbtest.B1 as B where (:t_a = B.a or B.b in (1))",
+			},
+			strategy: NestLoop,
+		},
+	}
+
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	database := "sbtest"
+
+	route, cleanup := router.MockNewRouter(log)
+	defer cleanup()
+
+	err := route.CreateDatabase(database)
+	assert.Nil(t, err)
+	err = route.AddForTest(database, router.MockTableMConfig(), router.MockTableBConfig(), router.MockTableGConfig())
+	assert.Nil(t, err)
+	for _, tcase := range tcases {
+		node, err := sqlparser.Parse(tcase.query)
+		assert.Nil(t, err)
+		p, err := BuildNode(log, route, database, node.(sqlparser.SelectStatement))
+		assert.Nil(t, err, tcase.query)
+		j, ok := p.(*JoinNode)
+		assert.True(t, ok, tcase.query)
+		assert.Equal(t, tcase.strategy, j.Strategy, tcase.query)
+		assert.Equal(t, tcase.out, querysOf(p), tcase.query)
+	}
+}
+
+func TestDerivedNodeUnsupported(t *testing.T) {
+	querys := []string{
+		"select * from (select a from A) as t where t.b = 1",
+		"select *, t.a+1 from (select a from A) as t",
+		"select * from (select a from A limit 1) as t where t.a = 1",
+		"select * from (select a from A union select a from B) as t where t.a = database()",
+		"select t.a from (select a, count(*) as c from A group by a) as t where t.c > 1",
+		"select B.b, t.a from B join (select a from A) as t where B.a = t.a or B.b = 1",
+	}
+	wants := []string{
+		"unsupported: unknown.column.name.'b'",
+		"unsupported: expr.'t.a + 1'.on.cross-shard.derived.table",
+		"unsupported: filter.on.derived.table.'t'.with.limit",
+		"unsupported: filter.'a = database()'.on.union.derived.table.'t'",
+		"unsupported: aggregation.field.in.subquery.is.used.in.clause",
+		"unsupported: cross-shard.derived.table.'t'.in.nested.loop.join",
+	}
+
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	database := "sbtest"
+
+	route, cleanup := router.MockNewRouter(log)
+	defer cleanup()
+
+	err := route.CreateDatabase(database)
+	assert.Nil(t, err)
+	err = route.AddForTest(database, router.MockTableMConfig(), router.MockTableBConfig(), router.MockTableGConfig())
+	assert.Nil(t, err)
+	for i, query := range querys {
+		node, err := sqlparser.Parse(query)
+		assert.Nil(t, err)
+		_, err = BuildNode(log, route, database, node.(sqlparser.SelectStatement))
+		assert.NotNil(t, err, query)
+		if err != nil {
+			assert.Equal(t, wants[i], err.Error(), query)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+/*
+ * NeoDB
+ *
+ * Copyright 2021-2030 The NeoDB Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package builder
+
+import (
+	"github.com/sealdb/mysqlstack/sqlparser/depends/common"
+	"github.com/sealdb/mysqlstack/xlog"
+)
+
+var (
+	_ ChildPlan = &DistinctPlan{}
+)
+
+// DistinctPlan represents distinct plan.
+// The rows fetched from the backends are deduplicated in the proxy.
+type DistinctPlan struct {
+	log    *xlog.Log
+	Fields []string `json:"Distinct"`
+	typ    ChildType
+}
+
+// NewDistinctPlan used to create DistinctPlan.
+func NewDistinctPlan(log *xlog.Log, fields []selectTuple) *DistinctPlan {
+	p := &DistinctPlan{
+		log: log,
+		typ: ChildTypeDistinct,
+	}
+	for _, field := range fields {
+		name := field.field
+		if field.alias != "" {
+			name = field.alias
+		}
+		p.Fields = append(p.Fields, name)
+	}
+	return p
+}
+
+// Build used to build distributed querys.
+func (p *DistinctPlan) Build() error {
+	return nil
+}
+
+// Type returns the type of the plan.
+func (p *DistinctPlan) Type() ChildType {
+	return p.typ
+}
+
+// JSON returns the plan info.
+func (p *DistinctPlan) JSON() string {
+	out, err := common.ToJSONString(p, false, "", "\t")
+	if err != nil {
+		return err.Error()
+	}
+	return out
+}
@@ -0,0 +1,132 @@
+/*
+ * NeoDB
+ *
+ * Copyright 2021-2030 The NeoDB Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package builder
+
+import (
+	"testing"
+
+	"github.com/sealdb/neodb/router"
+
+	"github.com/sealdb/mysqlstack/sqlparser"
+	"github.com/sealdb/mysqlstack/xlog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDistinctPlan(t *testing.T) {
+	tcases := []struct {
+		query    string
+		out      []string
+		children []ChildType
+	}{
+		// Dedup in the proxy.
+		{
+			query: "select distinct a, b as c from B order by a limit 1",
+			out: []string{
+				"backend1: select distinct a, b as c from sbtest.B0 as B order by a asc limit 1",
+				"backend2: select distinct a, b as c from sbtest.B1 as B order by a asc limit 1",
+			},
+			children: []ChildType{ChildTypeOrderby, ChildTypeDistinct, ChildTypeLimit},
+		},
+		{
+			query: "select distinct * from B",
+			out: []string{
+				"backend1: select distinct * from sbtest.B0 as B",
+				"backend2: select distinct * from sbtest.B1 as B",
+			},
+			children: []ChildType{ChildTypeDistinct},
+		},
+		{
+			query: "select distinct a+1, b from B",
+			out: []string{
+				"backend1: select distinct a + 1, b from sbtest.B0 as B",
+				"backend2: select distinct a + 1, b from sbtest.B1 as B",
+			},
+			children: []ChildType{ChildTypeDistinct},
+		},
+		{
+			query: "select distinct A.a, B.b from A join B on A.id = B.id where B.id = 1",
+			out: []string{
+				"backend6: select A.a, A.id from sbtest.A6 as A where A.id = 1 order by A.id asc",
+				"backend2: select B.b, B.id from sbtest.B1 as B where B.id = 1 order by B.id asc",
+			},
+			children: []ChildType{ChildTypeDistinct},
+		},
+		// The shard key makes the rows unique.
+		{
+			query: "select distinct id, a from B",
+			out: []string{
+				"backend1: select distinct id, a from sbtest.B0 as B",
+				"backend2: select distinct id, a from sbtest.B1 as B",
+			},
+		},
+		// Fetch the distinct rows and finish the aggregates in the proxy.
+		{
+			query: "select a, count(distinct b), max(c) from B group by a",
+			out: []string{
+				"backend1: select distinct a, b as `count(distinct b)`, c as `max(c)` from sbtest.B0 as B order by a asc",
+				"backend2: select distinct a, b as `count(distinct b)`, c as `max(c)` from sbtest.B1 as B order by a asc",
+			},
+			children: []ChildType{ChildTypeAggregate},
+		},
+		{
+			query: "select count(distinct b), sum(distinct b) from B limit 1",
+			out: []string{
+				"backend1: select distinct b as `count(distinct b)`, b as `sum(distinct b)` from sbtest.B0 as B",
+				"backend2: select distinct b as `count(distinct b)`, b as `sum(distinct b)` from sbtest.B1 as B",
+			},
+			children: []ChildType{ChildTypeAggregate, ChildTypeLimit},
+		},
+		// The duplicate rows affect the count.
+		{
+			query: "select distinct count(distinct b), count(c) from B",
+			out: []string{
+				"backend1: select b as `count(distinct b)`, c as `count(c)` from sbtest.B0 as B",
+				"backend2: select b as `count(distinct b)`, c as `count(c)` from sbtest.B1 as B",
+			},
+			children: []ChildType{ChildTypeAggregate},
+		},
+	}
+
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	database := "sbtest"
+
+	route, cleanup := router.MockNewRouter(log)
+	defer cleanup()
+
+	err := route.CreateDatabase(database)
+	assert.Nil(t, err)
+	err = route.AddForTest(database, router.MockTableMConfig(), router.MockTableBConfig())
+	assert.Nil(t, err)
+	for _, tcase := range tcases {
+		node, err := sqlparser.Parse(tcase.query)
+		assert.Nil(t, err)
+		p, err := BuildNode(log, route, database, node.(sqlparser.SelectStatement))
+		assert.Nil(t, err, tcase.query)
+		assert.Equal(t, tcase.out, querysOf(p), tcase.query)
+		var children []ChildType
+		for _, child := range p.Children() {
+			children = append(children, child.Type())
+		}
+		assert.Equal(t, tcase.children, children, tcase.query)
+	}
+}
+
+func TestDistinctPlanJSON(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	plan := NewDistinctPlan(log, []selectTuple{{field: "a"}, {field: "b", alias: "c"}})
+	assert.Nil(t, plan.Build())
+	assert.Equal(t, ChildTypeDistinct, plan.Type())
+	want := `{
+	"Distinct": [
+		"a",
+		"c"
+	]
+}`
+	assert.Equal(t, want, plan.JSON())
+}
@@ -124,48 +124,42 @@ func parseWhereOrJoinExprs(exprs sqlparser.Expr, tbInfos map[string]*tableInfo)
 }
 
 // LookupFromWhere used to get the routing from the where clause.
-func LookupFromWhere(database, table, shardkey string, where *sqlparser.Where, router *router.Router) ([]router.Segment, error) {
+// The filters on the shard key are intersected, such as:
+// 'id>=10 and id<20', 'id between 10 and 20', 'id in (1,2)'.
+// The composite shard key is routed if all the columns are bound to the values.
+// If the shard key is not restricted, the segments are pruned by the lookup indexes of the
+// table at execution, such as: 'email=?' routes by the lookup table from email to the shard key,
+// the prune is nil if no lookup index column is bound to the values.
+func LookupFromWhere(database, table, shardkey string, where *sqlparser.Where, router *router.Router) ([]router.Segment, *LookupPrune, error) {
 	if shardkey != "" && where != nil {
+		shardRange := newShardRange(shardkey)
+		if conf, err := router.TableConfig(database, table); err == nil {
+			shardRange.addLookups(conf.LookupIndexes)
+		}
 		filters := splitAndExpression(nil, where.Expr)
 		for _, filter := range filters {
 			filter = skipParenthesis(filter)
 			filter = convertOrToIn(filter)
-			comparison, ok := filter.(*sqlparser.ComparisonExpr)
-			if !ok {
-				continue
+			shardRange.pushFilter(filter, table)
+		}
+		if !shardRange.isFull() {
+			indexes, err := shardRange.lookup(database, table, router)
+			if err != nil {
+				return nil, nil, err
 			}
-
-			// Only deal with Equal statement.
-			switch comparison.Operator {
-			case sqlparser.EqualStr:
-				if nameMatch(comparison.Left, table, shardkey) {
-					sqlval, ok := comparison.Right.(*sqlparser.SQLVal)
-					if ok {
-						return router.Lookup(database, table, sqlval, sqlval)
-					}
-				}
-			case sqlparser.InStr:
-				if nameMatch(comparison.Left, table, shardkey) {
-					if valTuple, ok := comparison.Right.(sqlparser.ValTuple); ok {
-						var idxs []int
-						for _, val := range valTuple {
-							if sqlVal, ok := val.(*sqlparser.SQLVal); ok {
-								idx, err := router.GetIndex(database, table, sqlVal)
-								if err != nil {
-									return nil, err
-								}
-								idxs = append(idxs, idx)
-							} else {
-								return router.Lookup(database, table, nil, nil)
-							}
-						}
-						return router.GetSegments(database, table, idxs)
-					}
-				}
+			segments, err := router.GetSegments(database, table, indexes)
+			if err != nil {
+				return nil, nil, err
+			}
+			prune := shardRange.lookupPrune(database, table, router)
+			if prune != nil {
+				prune.setSegments(segments)
 			}
+			return segments, prune, nil
 		}
 	}
-	return router.Lookup(database, table, nil, nil)
+	segments, err := router.Lookup(database, table, nil, nil)
+	return segments, nil, err
 }
 
 func nameMatch(node sqlparser.Expr, table, shardkey string) bool {
@@ -317,7 +311,7 @@ func checkJoinOn(lpn, rpn PlanNode, join exprInfo) (exprInfo, error) {
 }
 
 // parseHaving used to check the having exprs and parse into tuples.
-// unsupport: `select sum(t2.id) as tmp, t1.id from t2,t1 having tmp=1`.
+// The columns out of the aggregates must be in the select fields.
 func parseHaving(exprs sqlparser.Expr, fields []selectTuple) ([]exprInfo, error) {
 	var tuples []exprInfo
 	filters := splitAndExpression(nil, exprs)
@@ -338,10 +332,6 @@ func parseHaving(exprs sqlparser.Expr, fields []selectTuple) ([]exprInfo, error)
 					}
 					return false, errors.Errorf("unsupported: unknown.column.'%s'.in.having.clause", col)
 				}
-				if field.aggrFuc != "" {
-					return false, errors.Errorf("unsupported: aggregation.in.having.clause")
-				}
-
 				for _, tb := range field.info.referTables {
 					if isContainKey(tuple.referTables, tb) {
 						continue
@@ -349,11 +339,12 @@ func parseHaving(exprs sqlparser.Expr, fields []selectTuple) ([]exprInfo, error)
 					tuple.referTables = append(tuple.referTables, tb)
 				}
 			case *sqlparser.FuncExpr:
-				if node.IsAggregate() {
-					buf := sqlparser.NewTrackedBuffer(nil)
-					node.Format(buf)
-					return false, errors.Errorf("unsupported: expr[%s].in.having.clause", buf.String())
+				// The aggregates are filtered in the proxy or by the backends.
+				if isAggregate(node) {
+					return false, nil
 				}
+			case *sqlparser.GroupConcatExpr:
+				return false, nil
 			}
 			return true, nil
 		}, filter)
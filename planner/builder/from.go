@@ -12,6 +12,7 @@ package builder
 import (
 	"github.com/sealdb/neodb/config"
 	"github.com/sealdb/neodb/router"
+	"github.com/sealdb/neodb/xcontext"
 
 	"github.com/pkg/errors"
 	"github.com/sealdb/mysqlstack/sqlparser"
@@ -36,8 +37,16 @@ type tableInfo struct {
 	tableExpr *sqlparser.AliasedTableExpr
 	// table's route.
 	Segments []router.Segment `json:",omitempty"`
-	// table's parent node, the type always a MergeNode.
-	parent *MergeNode
+	// the shard key values allowed by the filters, nil if no shard key.
+	shardRange *shardRange
+	// the shard indexes pruned by the shardRange.
+	indexes []int
+	// the prune of the routes by the lookup indexes at execution.
+	prune *LookupPrune
+	// the plan of the derived table pushed down with the outer query, nil if not a derived table.
+	derived *MergeNode
+	// table's parent node, the type is MergeNode or DerivedNode.
+	parent PlanNode
 }
 
 /* scanTableExprs analyzes the 'FROM' clause, build a plannode tree.
@@ -49,7 +58,7 @@ type tableInfo struct {
  *          /  \
  *         /    \
  *  MergeNode  MergeNode
- *  The leaf node is MergeNode, branch node is JoinNode.
+ *  The leaf node is MergeNode or DerivedNode, branch node is JoinNode.
  */
 func scanTableExprs(log *xlog.Log, router *router.Router, database string, tableExprs sqlparser.TableExprs) (PlanNode, error) {
 	if len(tableExprs) == 1 {
@@ -87,6 +96,10 @@ func scanTableExpr(log *xlog.Log, router *router.Router, database string, tableE
 // scanAliasedTableExpr produces the table's tableInfo by the AliasedTableExpr, and build a MergeNode subtree.
 func scanAliasedTableExpr(log *xlog.Log, r *router.Router, database string, tableExpr *sqlparser.AliasedTableExpr) (PlanNode, error) {
 	var err error
+	if sub, ok := tableExpr.Expr.(*sqlparser.Subquery); ok {
+		return scanDerivedTable(log, r, database, tableExpr, sub)
+	}
+
 	mn := newMergeNode(log, r)
 	switch expr := tableExpr.Expr.(type) {
 	case sqlparser.TableName:
@@ -107,6 +120,10 @@ func scanAliasedTableExpr(log *xlog.Log, r *router.Router, database string, tabl
 			return nil, err
 		}
 		tn.shardKey = tn.tableConfig.ShardKey
+		if tn.shardKey != "" {
+			tn.shardRange = newShardRange(tn.shardKey)
+			tn.shardRange.addLookups(tn.tableConfig.LookupIndexes)
+		}
 		tn.shardType = tn.tableConfig.ShardType
 		tn.tableExpr = tableExpr
 
@@ -114,9 +131,10 @@ func scanAliasedTableExpr(log *xlog.Log, r *router.Router, database string, tabl
 		case "GLOBAL":
 			mn.nonGlobalCnt = 0
 		case "SINGLE":
+			tn.indexes = []int{0}
 			mn.indexes = append(mn.indexes, 0)
 			mn.nonGlobalCnt = 1
-		case "HASH", "LIST":
+		case "HASH", "LIST", "RANGE", "INTERVAL":
 			// if a shard table hasn't alias, create one in order to push.
 			if tableExpr.As.String() == "" {
 				tableExpr.As = sqlparser.NewTableIdent(tn.tableName)
@@ -131,8 +149,6 @@ func scanAliasedTableExpr(log *xlog.Log, r *router.Router, database string, tabl
 		} else {
 			mn.referTables[tn.tableName] = tn
 		}
-	case *sqlparser.Subquery:
-		err = errors.New("unsupported: subquery.in.select")
 	}
 	mn.Sel = &sqlparser.Select{From: sqlparser.TableExprs([]sqlparser.TableExpr{tableExpr})}
 	return mn, err
@@ -217,10 +233,8 @@ func join(log *xlog.Log, lpn, rpn PlanNode, joinExpr *sqlparser.JoinTableExpr, r
 				return mergeRoutes(lmn, rmn, joinExpr, otherJoinOn)
 			}
 			// if join on condition's cols are both shardkey, and the tables have same shards.
-			for _, jt := range joinOn {
-				if isSameShard(lmn.referTables, rmn.referTables, jt.cols[0], jt.cols[1]) {
-					return mergeRoutes(lmn, rmn, joinExpr, otherJoinOn)
-				}
+			if isSameShard(lmn.referTables, rmn.referTables, joinOn) {
+				return mergeRoutes(lmn, rmn, joinExpr, otherJoinOn)
 			}
 		}
 	}
@@ -265,6 +279,10 @@ func mergeRoutes(lmn, rmn *MergeNode, joinExpr *sqlparser.JoinTableExpr, otherJo
 	}
 
 	lmn.nonGlobalCnt += rmn.nonGlobalCnt
+	// The derived table from dual goes with the other side.
+	if lmn.ReqMode == xcontext.ReqSingle {
+		lmn.ReqMode = rmn.ReqMode
+	}
 	if joinExpr == nil || joinExpr.Join != sqlparser.LeftJoinStr {
 		for _, filter := range otherJoinOn {
 			if err := lmn.pushFilter(filter); err != nil {
@@ -275,24 +293,70 @@ func mergeRoutes(lmn, rmn *MergeNode, joinExpr *sqlparser.JoinTableExpr, otherJo
 	return lmn, err
 }
 
-// isSameShard used to judge lcn|rcn contain shardkey and have same shards.
-func isSameShard(ltb, rtb map[string]*tableInfo, lcn, rcn *sqlparser.ColName) bool {
-	lt := ltb[lcn.Qualifier.Name.String()]
-	if lt.shardKey == "" || !lcn.Name.EqualString(lt.shardKey) {
-		return false
+// isSameShard used to judge whether the joins contain the equal conditions on all the
+// shard key columns of a left table and a right table, and the tables have same shards.
+// eg: 't1.a=t2.a and t1.b=t2.b', the shard keys of t1 and t2 are (a, b).
+func isSameShard(ltb, rtb map[string]*tableInfo, joins []exprInfo) bool {
+	for _, join := range joins {
+		ltName := join.cols[0].Qualifier.Name.String()
+		rtName := join.cols[1].Qualifier.Name.String()
+		lt, rt := ltb[ltName], rtb[rtName]
+		if lt.shardRange == nil || rt.shardRange == nil || len(lt.shardRange.keys) != len(rt.shardRange.keys) {
+			continue
+		}
+		if isKeyJoined(ltName, rtName, lt.shardRange.keys, rt.shardRange.keys, joins) && isColocated(lt, rt) {
+			return true
+		}
 	}
-	ltp := lt.tableConfig.Partitions
-	rt := rtb[rcn.Qualifier.Name.String()]
-	if rt.shardKey == "" || !rcn.Name.EqualString(rt.shardKey) {
-		return false
+	return false
+}
+
+// isKeyJoined used to judge whether each column of lkeys is joined with the column of rkeys in the same position.
+func isKeyJoined(ltName, rtName string, lkeys, rkeys []string, joins []exprInfo) bool {
+	for i, lkey := range lkeys {
+		joined := false
+		for _, join := range joins {
+			lcn, rcn := join.cols[0], join.cols[1]
+			if lcn.Qualifier.Name.String() == ltName && lcn.Name.EqualString(lkey) &&
+				rcn.Qualifier.Name.String() == rtName && rcn.Name.EqualString(rkeys[i]) {
+				joined = true
+				break
+			}
+		}
+		if !joined {
+			return false
+		}
 	}
-	rtp := rt.tableConfig.Partitions
+	return true
+}
+
+// hashFunction returns the hash function name of the table, empty means the default.
+func hashFunction(conf *config.TableConfig) string {
+	if conf.HashFunction == "" {
+		return router.DefaultHashFunction
+	}
+	return conf.HashFunction
+}
 
+// isColocated used to judge whether the two tables have same shards.
+// The members of a colocate group share the partition layout, only the backends are compared
+// since a group member may be shifted before the others during rebalancing. The tables out of
+// the group fall back to compare the partitions one by one.
+func isColocated(lt, rt *tableInfo) bool {
+	ltp := lt.tableConfig.Partitions
+	rtp := rt.tableConfig.Partitions
 	if len(ltp) != len(rtp) {
 		return false
 	}
+	sameGroup := lt.tableConfig.ColocateGroup != "" && lt.tableConfig.ColocateGroup == rt.tableConfig.ColocateGroup
+	if !sameGroup && hashFunction(lt.tableConfig) != hashFunction(rt.tableConfig) {
+		return false
+	}
 	for i, lpart := range ltp {
-		if lpart.Segment != rtp[i].Segment || lpart.Backend != rtp[i].Backend {
+		if lpart.Backend != rtp[i].Backend {
+			return false
+		}
+		if !sameGroup && (lpart.Segment != rtp[i].Segment || lpart.LessThan != rtp[i].LessThan) {
 			return false
 		}
 	}
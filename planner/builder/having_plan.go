@@ -0,0 +1,148 @@
+/*
+ * NeoDB
+ *
+ * Copyright 2021-2030 The NeoDB Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package builder
+
+import (
+	"github.com/sealdb/neodb/expression"
+
+	"github.com/pkg/errors"
+	"github.com/sealdb/mysqlstack/sqlparser"
+	"github.com/sealdb/mysqlstack/sqlparser/depends/common"
+	"github.com/sealdb/mysqlstack/xlog"
+)
+
+var (
+	_ ChildPlan = &HavingPlan{}
+)
+
+// HavingPlan represents the having filtered in the proxy, such as:
+// the having on the aggregates merged in the proxy, the having on the cross-shard join.
+type HavingPlan struct {
+	log *xlog.Log
+	// the fields of the rows to be filtered.
+	fields  []selectTuple
+	exprs   []sqlparser.Expr
+	filters []expression.Evaluator
+	Havings []string
+	// The indexes mark the hidden fields to be removed.
+	RemovedIdxs []int `json:",omitempty"`
+	typ         ChildType
+}
+
+// NewHavingPlan used to create HavingPlan.
+func NewHavingPlan(log *xlog.Log, expr sqlparser.Expr, fields []selectTuple) *HavingPlan {
+	return &HavingPlan{
+		log:    log,
+		fields: fields,
+		exprs:  []sqlparser.Expr{expr},
+		typ:    ChildTypeHaving,
+	}
+}
+
+// analyze used to compile the having exprs by the fields.
+func (p *HavingPlan) analyze() error {
+	for _, field := range p.fields {
+		if field.field == "*" {
+			return errors.Errorf("unsupported: exists.'*'.and.having.clause.in.proxy")
+		}
+	}
+
+	p.filters = p.filters[:0]
+	p.Havings = p.Havings[:0]
+	for _, expr := range p.exprs {
+		filter, err := expression.Compile(expr, func(e sqlparser.Expr) (int, bool) {
+			return resolveField(p.fields, e)
+		})
+		if err != nil {
+			return err
+		}
+		p.filters = append(p.filters, filter)
+		p.Havings = append(p.Havings, sqlparser.String(expr))
+	}
+	return nil
+}
+
+// Build used to build distributed querys.
+func (p *HavingPlan) Build() error {
+	return p.analyze()
+}
+
+// Type returns the type of the plan.
+func (p *HavingPlan) Type() ChildType {
+	return p.typ
+}
+
+// JSON returns the plan info.
+func (p *HavingPlan) JSON() string {
+	out, err := common.ToJSONString(p, false, "", "\t")
+	if err != nil {
+		return err.Error()
+	}
+	return out
+}
+
+// Filters returns the compiled having exprs, the row is kept if all of them are true.
+func (p *HavingPlan) Filters() []expression.Evaluator {
+	return p.filters
+}
+
+// pushHavingPlan used to add the having filtered in the proxy to the children,
+// the havings are merged into one plan.
+func pushHavingPlan(log *xlog.Log, children []ChildPlan, having sqlparser.Expr, fields []selectTuple) ([]ChildPlan, error) {
+	for _, child := range children {
+		if plan, ok := child.(*HavingPlan); ok {
+			plan.exprs = append(plan.exprs, having)
+			return children, plan.Build()
+		}
+	}
+	plan := NewHavingPlan(log, having, fields)
+	if err := plan.Build(); err != nil {
+		return nil, err
+	}
+	return append(children, plan), nil
+}
+
+// havingInProxy returns true if the having refers to the aggregates or the fields computed
+// in the proxy, such as: `count(*) > 1`, `x > 1` in `select sum(a)/count(b) as x`.
+func havingInProxy(having exprInfo, fields []selectTuple) bool {
+	inProxy := false
+	sqlparser.Walk(func(node sqlparser.SQLNode) (kontinue bool, err error) {
+		switch node := node.(type) {
+		case *sqlparser.FuncExpr:
+			inProxy = inProxy || isAggregate(node)
+		case *sqlparser.GroupConcatExpr:
+			inProxy = true
+		case *sqlparser.ColName:
+			if idx, ok := resolveField(fields, node); ok {
+				field := fields[idx]
+				inProxy = inProxy || field.aggrFuc != "" || len(field.deps) > 0
+			}
+		}
+		return !inProxy, nil
+	}, having.expr)
+	return inProxy
+}
+
+// removeHiddenFields used to remove the hidden fields after the having filtered and
+// the window functions computed, if there's no order by to remove them.
+func removeHiddenFields(root PlanNode) {
+	var last ChildPlan
+	for _, child := range root.Children() {
+		switch child.(type) {
+		case *HavingPlan, *WindowPlan:
+			last = child
+		}
+	}
+	switch plan := last.(type) {
+	case *HavingPlan:
+		plan.RemovedIdxs = hiddenIdxs(root.getFields())
+	case *WindowPlan:
+		plan.RemovedIdxs = hiddenIdxs(root.getFields())
+	}
+}
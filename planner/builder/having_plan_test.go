@@ -0,0 +1,108 @@
+/*
+ * NeoDB
+ *
+ * Copyright 2021-2030 The NeoDB Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package builder
+
+import (
+	"testing"
+
+	"github.com/sealdb/neodb/router"
+
+	"github.com/sealdb/mysqlstack/sqlparser"
+	"github.com/sealdb/mysqlstack/xlog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHavingPlan(t *testing.T) {
+	tcases := []struct {
+		query    string
+		out      []string
+		children []ChildType
+		havings  []string
+		removed  []int
+	}{
+		// The having on the aggregates merged in the proxy.
+		{
+			query: "select a, count(*) from B group by a having count(*) > 1 and a > 2",
+			out: []string{
+				"backend1: select a, count(*) from sbtest.B0 as B group by a having a > 2 order by a asc",
+				"backend2: select a, count(*) from sbtest.B1 as B group by a having a > 2 order by a asc",
+			},
+			children: []ChildType{ChildTypeAggregate, ChildTypeHaving},
+			havings:  []string{"count(*) > 1"},
+		},
+		// The aggregates not in the select list are hidden.
+		{
+			query: "select a from B group by a having sum(b) > 1",
+			out: []string{
+				"backend1: select a, sum(b) from sbtest.B0 as B group by a order by a asc",
+				"backend2: select a, sum(b) from sbtest.B1 as B group by a order by a asc",
+			},
+			children: []ChildType{ChildTypeAggregate, ChildTypeHaving},
+			havings:  []string{"sum(b) > 1"},
+			removed:  []int{1},
+		},
+		// The having on the shard key group is pushed down.
+		{
+			query: "select id, count(*) from B group by id having count(*) > 1",
+			out: []string{
+				"backend1: select id, count(*) from sbtest.B0 as B group by id having count(*) > 1 order by id asc",
+				"backend2: select id, count(*) from sbtest.B1 as B group by id having count(*) > 1 order by id asc",
+			},
+			children: []ChildType{ChildTypeOrderby},
+		},
+		// The having on the cross-shard join.
+		{
+			query: "select A.a, B.a from A join B on A.id = B.id where A.id = 1 having A.a + B.a > 1",
+			out: []string{
+				"backend6: select A.a, A.id from sbtest.A6 as A where A.id = 1 order by A.id asc",
+				"backend2: select B.a, B.id from sbtest.B1 as B where B.id = 1 order by B.id asc",
+			},
+			children: []ChildType{ChildTypeHaving},
+			havings:  []string{"A.a + B.a > 1"},
+		},
+	}
+
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	database := "sbtest"
+
+	route, cleanup := router.MockNewRouter(log)
+	defer cleanup()
+
+	err := route.CreateDatabase(database)
+	assert.Nil(t, err)
+	err = route.AddForTest(database, router.MockTableMConfig(), router.MockTableBConfig())
+	assert.Nil(t, err)
+	for _, tcase := range tcases {
+		node, err := sqlparser.Parse(tcase.query)
+		assert.Nil(t, err)
+		p, err := BuildNode(log, route, database, node.(sqlparser.SelectStatement))
+		assert.Nil(t, err, tcase.query)
+		assert.Equal(t, tcase.out, querysOf(p), tcase.query)
+		var children []ChildType
+		for _, child := range p.Children() {
+			children = append(children, child.Type())
+			if plan, ok := child.(*HavingPlan); ok {
+				assert.Equal(t, tcase.havings, plan.Havings, tcase.query)
+				assert.Equal(t, tcase.removed, plan.RemovedIdxs, tcase.query)
+			}
+		}
+		assert.Equal(t, tcase.children, children, tcase.query)
+	}
+}
+
+func TestHavingPlanError(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	node, err := sqlparser.Parse("select * from t having a > 1")
+	assert.Nil(t, err)
+	fields := []selectTuple{{expr: node.(*sqlparser.Select).SelectExprs[0], field: "*"}}
+
+	plan := NewHavingPlan(log, node.(*sqlparser.Select).Having.Expr, fields)
+	assert.Equal(t, ChildTypeHaving, plan.Type())
+	assert.Equal(t, "unsupported: exists.'*'.and.having.clause.in.proxy", plan.Build().Error())
+}
@@ -30,6 +30,16 @@ const (
 	SortMerge
 	// NestLoop Join.
 	NestLoop
+	// HashJoin builds a hash table on the smaller input and probes with the other.
+	HashJoin
+)
+
+const (
+	// hashJoinHint forces the equi-joins to use the hash join.
+	hashJoinHint = "/*+hash_join*/"
+	// hashJoinRatio is the minimum ratio of the estimated rows between the two sides,
+	// the hash join is used if one side is much smaller than the other.
+	hashJoinRatio = 4
 )
 
 // JoinKey is the column info in the on conditions.
@@ -42,6 +52,24 @@ type JoinKey struct {
 	Index int
 }
 
+// BatchKey is the equal join condition `right.col = left.col` of the NestLoop join,
+// the left rows are looked up in batches by `right.col in ::Var`.
+type BatchKey struct {
+	// Var is the joinVar of the left column.
+	Var string
+	// index of the left column in the left fields.
+	LeftIdx int
+	// index of the right column in the batch query's select exprs.
+	RightIdx int
+	// Table is the right table if the right column is its shard key,
+	// the key values can be routed to the shards.
+	Table string `json:",omitempty"`
+	// the right column.
+	col *sqlparser.ColName
+	// the join condition in the right node's where clause.
+	expr *sqlparser.ComparisonExpr
+}
+
 // Comparison is record the sqlparser.Comparison info.
 type Comparison struct {
 	// index in left and right node's fields.
@@ -120,6 +148,8 @@ type JoinNode struct {
 	// Vars defines the list of joinVars that need to be built
 	// from the Left result before invoking the Right subqquery.
 	Vars map[string]int
+	// BatchKeys is not empty if the NestLoop join can look up the Right in batches.
+	BatchKeys []*BatchKey `json:",omitempty"`
 }
 
 // newJoinNode used to create JoinNode.
@@ -183,17 +213,12 @@ func (j *JoinNode) pushFilter(filter exprInfo) error {
 		tb := filter.referTables[0]
 		tbInfo := j.referTables[tb]
 		if len(filter.cols) != 1 {
-			addFilter(tbInfo.parent, filter)
-		} else {
-			if err := j.pushKeyFilter(filter, filter.cols[0].Qualifier.Name.String(), filter.cols[0].Name.String()); err != nil {
-				return err
-			}
+			return addFilter(tbInfo.parent, filter)
 		}
-	} else {
-		parent := findParent(filter.referTables, j)
-		addFilter(parent, filter)
+		return j.pushKeyFilter(filter, filter.cols[0].Qualifier.Name.String(), filter.cols[0].Name.String())
 	}
-	return nil
+	parent := findParent(filter.referTables, j)
+	return addFilter(parent, filter)
 }
 
 // pushKeyFilter used to build the keyFilter based on the filter and joinOn.
@@ -320,7 +345,9 @@ func (j *JoinNode) pushOthers() error {
 		// If its parent is JoinNode, add it to parent.otherFilter.
 		for _, filter := range j.otherLeftJoin.right {
 			parent := findParent(filter.referTables, j.Right)
-			addFilter(parent, filter)
+			if err := addFilter(parent, filter); err != nil {
+				return err
+			}
 		}
 
 		if len(j.otherLeftJoin.others) > 0 {
@@ -360,6 +387,59 @@ func (j *JoinNode) judgeStrategy(filters []exprInfo) {
 	}
 }
 
+// judgeHashJoin set the strategy of the equi-joins to HashJoin if the hint is given,
+// or one side is estimated to be much smaller than the other. The join without equal
+// conditions falls back to SortMerge or Cartesian when building the query.
+func (j *JoinNode) judgeHashJoin(hint bool) {
+	if left, ok := j.Left.(*JoinNode); ok {
+		left.judgeHashJoin(hint)
+	}
+	if right, ok := j.Right.(*JoinNode); ok {
+		right.judgeHashJoin(hint)
+	}
+	if j.Strategy != SortMerge {
+		return
+	}
+
+	lrows, rrows := estimateRows(j.Left), estimateRows(j.Right)
+	if hint || lrows*hashJoinRatio <= rrows || rrows*hashJoinRatio <= lrows {
+		j.Strategy = HashJoin
+	}
+}
+
+// hasHashJoinHint returns true if the select has the hint `/*+ hash_join */`.
+func hasHashJoinHint(sel *sqlparser.Select) bool {
+	for _, comment := range sel.Comments {
+		if strings.EqualFold(strings.Replace(string(comment), " ", "", -1), hashJoinHint) {
+			return true
+		}
+	}
+	return false
+}
+
+// estimateRows estimates the rows of the node by the count of the partitions to be scanned,
+// the global tables and the query routed by the shard key are regarded as one partition.
+func estimateRows(node PlanNode) int {
+	switch node := node.(type) {
+	case *MergeNode:
+		if node.nonGlobalCnt == 0 || node.routeLen < 1 {
+			return 1
+		}
+		return node.routeLen
+	case *JoinNode:
+		lrows, rrows := estimateRows(node.Left), estimateRows(node.Right)
+		if lrows > rrows {
+			return lrows
+		}
+		return rrows
+	case *DerivedNode:
+		return estimateRows(node.Inner)
+	case *UnionNode:
+		return estimateRows(node.Left) + estimateRows(node.Right)
+	}
+	return 1
+}
+
 // setNestLoop set the strategy to Nest Loop.
 func (j *JoinNode) setNestLoop() {
 	if left, ok := j.Left.(*JoinNode); ok {
@@ -388,7 +468,9 @@ func (j *JoinNode) pushEqualCmprs(joins []exprInfo) PlanNode {
 			join, _ := checkJoinOn(node.Left, node.Right, joinCond)
 			if lmn, ok := node.Left.(*MergeNode); ok {
 				if rmn, ok := node.Right.(*MergeNode); ok {
-					if isSameShard(lmn.referTables, rmn.referTables, join.cols[0], join.cols[1]) {
+					// The join conditions of the composite shard key may be pushed one by one.
+					joinOn := append(append([]exprInfo{}, node.joinOn...), join)
+					if isSameShard(lmn.referTables, rmn.referTables, joinOn) {
 						mn, _ := mergeRoutes(lmn, rmn, node.joinExpr, nil)
 						mn.setParent(node.parent)
 						setParenthese(mn, node.hasParen)
@@ -480,27 +562,73 @@ func (j *JoinNode) calcRoute() (PlanNode, error) {
 func (j *JoinNode) pushSelectExprs(fields, groups []selectTuple, sel *sqlparser.Select, aggTyp aggrType) error {
 	j.reOrder(0)
 
-	if len(groups) > 0 || aggTyp != nullAgg {
-		aggrPlan := NewAggregatePlan(j.log, sel.SelectExprs, fields, groups, false)
-		if err := aggrPlan.Build(); err != nil {
-			return err
-		}
-		j.children = append(j.children, aggrPlan)
-		fields = aggrPlan.tuples
+	children, tuples, projected, err := buildSelectPlans(j.log, fields, groups, sel.SelectExprs, len(groups) > 0 || aggTyp != nullAgg, j.computeInProxy)
+	if err != nil {
+		return err
 	}
+	j.children = append(j.children, children...)
 
-	for _, tuple := range fields {
+	for _, tuple := range tuples {
 		if _, err := j.pushSelectExpr(tuple); err != nil {
 			return err
 		}
 	}
+	if projected != nil {
+		j.fields = projected
+	}
+	j.judgeHashJoin(hasHashJoinHint(sel))
 
 	if err := j.handleOthers(); err != nil {
 		return err
 	}
 
-	j.handleJoinOn()
-	return nil
+	return j.handleJoinOn()
+}
+
+// computeInProxy returns true if the tuple must be computed over the joined rows, such as:
+// the expr mixes the columns from both sides, or the expr refers to the null-supplying
+// side of the left join, eg: `ifnull(t2.a, 0)` cannot be computed before the join.
+func (j *JoinNode) computeInProxy(tuple selectTuple) bool {
+	aliased, ok := tuple.expr.(*sqlparser.AliasedExpr)
+	if !ok || tuple.isCol || len(tuple.info.referTables) == 0 {
+		return false
+	}
+	if _, ok := aliased.Expr.(*sqlparser.ColName); ok {
+		return false
+	}
+
+	var parent PlanNode
+	compute := false
+	for _, tb := range tuple.info.referTables {
+		tbInfo, ok := j.referTables[tb]
+		if !ok {
+			return false
+		}
+		if (parent != nil && parent != tbInfo.parent) || j.isNullable(tb) {
+			compute = true
+		}
+		parent = tbInfo.parent
+	}
+	return compute && canCompute(aliased.Expr)
+}
+
+// isNullable returns true if the table is in the null-supplying side of the left join.
+func (j *JoinNode) isNullable(table string) bool {
+	var node PlanNode = j
+	for {
+		join, ok := node.(*JoinNode)
+		if !ok {
+			return false
+		}
+		if _, ok := join.Right.getReferTables()[table]; ok {
+			if join.IsLeftJoin {
+				return true
+			}
+			node = join.Right
+			continue
+		}
+		node = join.Left
+	}
 }
 
 // handleOthers used to handle otherLeftJoin|rightNull|otherFilter.
@@ -545,7 +673,7 @@ func (j *JoinNode) pushOtherFilters(filters []exprInfo, isOtherJoin bool) error
 	for _, filter := range filters {
 		switch j.Strategy {
 		case NestLoop:
-			var m *MergeNode
+			var m PlanNode
 			for _, tb := range filter.referTables {
 				tbInfo := j.referTables[tb]
 				if m == nil {
@@ -556,8 +684,10 @@ func (j *JoinNode) pushOtherFilters(filters []exprInfo, isOtherJoin bool) error
 					m = tbInfo.parent
 				}
 			}
-			m.addWhere(filter.expr)
-		case SortMerge:
+			if err := addFilter(m, filter); err != nil {
+				return err
+			}
+		case SortMerge, HashJoin:
 			var err error
 			var lidx, ridx int
 			var exchange bool
@@ -674,16 +804,20 @@ func (j *JoinNode) pushSelectExpr(field selectTuple) (int, error) {
 }
 
 // handleJoinOn used to build order by based on On conditions.
-func (j *JoinNode) handleJoinOn() {
+func (j *JoinNode) handleJoinOn() error {
 	// eg: select t1.a,t2.a from t1 join t2 on t1.a=t2.a;
 	// push: select t1.a from t1 order by t1.a asc;
 	//       select t2.a from t2 order by t2.a asc;
 	if left, ok := j.Left.(*JoinNode); ok {
-		left.handleJoinOn()
+		if err := left.handleJoinOn(); err != nil {
+			return err
+		}
 	}
 
 	if right, ok := j.Right.(*JoinNode); ok {
-		right.handleJoinOn()
+		if err := right.handleJoinOn(); err != nil {
+			return err
+		}
 	}
 
 	for _, join := range j.joinOn {
@@ -699,7 +833,9 @@ func (j *JoinNode) handleJoinOn() {
 			if origin.Order() < rtb.parent.Order() {
 				origin = rtb.parent
 			}
-			origin.addWhere(join.expr)
+			if err := addFilter(origin, join); err != nil {
+				return err
+			}
 
 			leftKey = JoinKey{Field: join.cols[0].Name.String(),
 				Table: lt,
@@ -707,7 +843,7 @@ func (j *JoinNode) handleJoinOn() {
 			rightKey = JoinKey{Field: join.cols[1].Name.String(),
 				Table: rt,
 			}
-		case SortMerge:
+		case SortMerge, HashJoin:
 			leftKey = j.buildOrderBy(j.Left, parseExpr(join.cols[0]))
 			rightKey = j.buildOrderBy(j.Right, parseExpr(join.cols[1]))
 		}
@@ -715,6 +851,7 @@ func (j *JoinNode) handleJoinOn() {
 		j.LeftKeys = append(j.LeftKeys, leftKey)
 		j.RightKeys = append(j.RightKeys, rightKey)
 	}
+	return nil
 }
 
 func (j *JoinNode) buildOrderBy(node PlanNode, tuple selectTuple) JoinKey {
@@ -747,7 +884,8 @@ func (j *JoinNode) buildOrderBy(node PlanNode, tuple selectTuple) JoinKey {
 		col = &sqlparser.ColName{Name: tuple.expr.(*sqlparser.AliasedExpr).As}
 	}
 
-	if m, ok := node.(*MergeNode); ok {
+	// The hash join needn't the rows sorted by the backends.
+	if m, ok := node.(*MergeNode); ok && j.Strategy == SortMerge {
 		m.Sel.(*sqlparser.Select).OrderBy = append(m.Sel.(*sqlparser.Select).OrderBy, &sqlparser.Order{
 			Expr:      col,
 			Direction: sqlparser.AscScr,
@@ -758,6 +896,13 @@ func (j *JoinNode) buildOrderBy(node PlanNode, tuple selectTuple) JoinKey {
 
 // pushHaving used to push having expr.
 func (j *JoinNode) pushHaving(having exprInfo) error {
+	// The having on the aggregates or the computed fields is filtered after the join.
+	if havingInProxy(having, j.fields) {
+		var err error
+		j.children, err = pushHavingPlan(j.log, j.children, having.expr, j.fields)
+		return err
+	}
+
 	var parent PlanNode
 	if len(having.referTables) == 0 {
 		j.Left.pushHaving(having)
@@ -779,7 +924,7 @@ func (j *JoinNode) pushHaving(having exprInfo) error {
 					if parent.Order() < tbInfo.parent.Order() {
 						parent = tbInfo.parent
 					}
-				case SortMerge:
+				case SortMerge, HashJoin:
 					parent = findLCA(j, parent, tbInfo.parent)
 				}
 			}
@@ -788,12 +933,18 @@ func (j *JoinNode) pushHaving(having exprInfo) error {
 
 	if mn, ok := parent.(*MergeNode); ok {
 		mn.addHaving(having.expr)
-	} else {
-		buf := sqlparser.NewTrackedBuffer(nil)
-		having.expr.Format(buf)
-		return errors.Errorf("unsupported: havings.'%s'.in.cross-shard.join", buf.String())
+		return nil
 	}
-	return nil
+	var err error
+	j.children, err = pushHavingPlan(j.log, j.children, having.expr, j.fields)
+	return err
+}
+
+// pushWindows used to push the window functions computed in the proxy.
+func (j *JoinNode) pushWindows(windows []windowTuple) error {
+	windowPlan := NewWindowPlan(j.log, windows, j.fields)
+	j.children = append(j.children, windowPlan)
+	return windowPlan.Build()
 }
 
 // pushOrderBy used to push the order by exprs.
@@ -803,6 +954,13 @@ func (j *JoinNode) pushOrderBy(orderBy sqlparser.OrderBy) error {
 	return orderPlan.Build()
 }
 
+// pushDistinct used to deduplicate the joined rows.
+func (j *JoinNode) pushDistinct() error {
+	distinctPlan := NewDistinctPlan(j.log, j.fields)
+	j.children = append(j.children, distinctPlan)
+	return distinctPlan.Build()
+}
+
 // pushLimit used to push limit.
 func (j *JoinNode) pushLimit(limit *sqlparser.Limit) error {
 	limitPlan := NewLimitPlan(j.log, limit)
@@ -835,12 +993,19 @@ func (j *JoinNode) Order() int {
 
 // buildQuery used to build the QueryTuple.
 func (j *JoinNode) buildQuery(root PlanNode) {
+	if j.Strategy == HashJoin && len(j.LeftKeys) == 0 {
+		j.Strategy = SortMerge
+	}
 	if j.Strategy == SortMerge {
 		if len(j.LeftKeys) == 0 && len(j.CmpFilter) == 0 && !j.IsLeftJoin {
 			j.Strategy = Cartesian
 		}
 	}
 
+	if j.Strategy == NestLoop {
+		j.buildBatchKeys()
+	}
+
 	j.Right.addNoTableFilter(j.noTableFilter)
 	j.Right.buildQuery(root)
 
@@ -892,3 +1057,93 @@ func (j *JoinNode) procure(col *sqlparser.ColName) string {
 	j.Vars[joinVar] = index
 	return joinVar
 }
+
+// buildBatchKeys finds the equal join conditions `right.col = left.col` in the Right MergeNode.
+// If the Right refers to the Left only by them, the Right can be looked up in batches with the
+// IN-list of the Left's values, eg: `select B.a from B where B.id = :A_id` to
+// `select B.a, B.id from B where B.id in ::A_id`.
+func (j *JoinNode) buildBatchKeys() {
+	m, ok := j.Right.(*MergeNode)
+	if !ok {
+		return
+	}
+	sel, ok := m.Sel.(*sqlparser.Select)
+	if !ok || sel.Where == nil {
+		return
+	}
+
+	ltbs := j.Left.getReferTables()
+	inLeft := func(col *sqlparser.ColName) bool {
+		table := col.Qualifier.Name.String()
+		if table == "" {
+			return false
+		}
+		if _, ok := m.referTables[table]; ok {
+			return false
+		}
+		_, ok := ltbs[table]
+		return ok
+	}
+
+	refs := 0
+	_ = sqlparser.Walk(func(node sqlparser.SQLNode) (kontinue bool, err error) {
+		switch node := node.(type) {
+		case *sqlparser.Subquery:
+			// The pushed down subquery is uncorrelated.
+			return false, nil
+		case *sqlparser.ColName:
+			if inLeft(node) {
+				refs++
+			}
+		}
+		return true, nil
+	}, sel)
+	if refs == 0 {
+		return
+	}
+
+	var keys []*BatchKey
+	for _, filter := range splitAndExpression(nil, sel.Where.Expr) {
+		expr, ok := filter.(*sqlparser.ComparisonExpr)
+		if !ok || expr.Operator != sqlparser.EqualStr {
+			continue
+		}
+		rcol, ok := expr.Left.(*sqlparser.ColName)
+		if !ok {
+			continue
+		}
+		lcol, ok := expr.Right.(*sqlparser.ColName)
+		if !ok {
+			continue
+		}
+		if inLeft(rcol) {
+			lcol, rcol = rcol, lcol
+		}
+		if !inLeft(lcol) {
+			continue
+		}
+		tbInfo, ok := m.referTables[rcol.Qualifier.Name.String()]
+		if !ok {
+			continue
+		}
+
+		joinVar := j.procure(lcol)
+		key := &BatchKey{
+			Var:     joinVar,
+			LeftIdx: j.Vars[joinVar],
+			col:     rcol,
+			expr:    expr,
+		}
+		if cols := router.SplitShardKey(tbInfo.shardKey); len(cols) == 1 && strings.EqualFold(cols[0], rcol.Name.String()) {
+			key.Table = rcol.Qualifier.Name.String()
+		}
+		keys = append(keys, key)
+	}
+
+	// The Right refers to the Left by the other exprs.
+	if len(keys) != refs {
+		return
+	}
+	j.BatchKeys = keys
+	m.batchKeys = keys
+}
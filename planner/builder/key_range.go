@@ -0,0 +1,393 @@
+/*
+ * NeoDB
+ *
+ * Copyright 2021-2030 The NeoDB Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package builder
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+
+	"github.com/sealdb/neodb/config"
+	"github.com/sealdb/neodb/router"
+
+	"github.com/sealdb/mysqlstack/sqlparser"
+	"github.com/sealdb/mysqlstack/sqlparser/depends/common"
+)
+
+// keyRange is the shard key values allowed by the filters of a table,
+// the filters are intersected.
+// eg: 'a>1 and a<=10 and a in (2,20)' -> [2, 10], {2}.
+type keyRange struct {
+	// start and end are the inclusive bounds, nil means unbounded.
+	start *sqlparser.SQLVal
+	end   *sqlparser.SQLVal
+	// vals is the value set of the equal and in filters.
+	vals []*sqlparser.SQLVal
+	// hasVals is true if the key is restricted by the vals.
+	hasVals bool
+}
+
+// isFull returns true if the key range is not restricted.
+func (k *keyRange) isFull() bool {
+	return !k.hasVals && k.start == nil && k.end == nil
+}
+
+// pushFilter intersects the key range with the filter if the filter
+// restricts the shard key, otherwise the filter is ignored.
+// Supports: =, <=>, >, >=, <, <=, in, between.
+func (k *keyRange) pushFilter(expr sqlparser.Expr, table, shardkey string) {
+	switch expr := skipParenthesis(expr).(type) {
+	case *sqlparser.ComparisonExpr:
+		left, right, op := expr.Left, expr.Right, expr.Operator
+		if !nameMatch(left, table, shardkey) {
+			if !nameMatch(right, table, shardkey) {
+				return
+			}
+			left, right, op = right, left, reverseOperator(op)
+		}
+		if op == sqlparser.InStr {
+			if valTuple, ok := right.(sqlparser.ValTuple); ok {
+				var vals []*sqlparser.SQLVal
+				for _, val := range valTuple {
+					if _, ok := val.(*sqlparser.NullVal); ok {
+						// NULL never matches.
+						continue
+					}
+					sqlVal, ok := val.(*sqlparser.SQLVal)
+					if !ok {
+						return
+					}
+					vals = append(vals, sqlVal)
+				}
+				if len(vals) > 0 {
+					k.intersectVals(vals)
+				}
+			}
+			return
+		}
+
+		val, ok := right.(*sqlparser.SQLVal)
+		if !ok {
+			return
+		}
+		switch op {
+		case sqlparser.EqualStr, sqlparser.NullSafeEqualStr:
+			k.intersectVals([]*sqlparser.SQLVal{val})
+		// The open bound is pruned as the closed one, the key may be not an integer,
+		// such as 'a>1' keeps the partition of 1 for the values like 1.5.
+		case sqlparser.GreaterThanStr, sqlparser.GreaterEqualStr:
+			k.intersectStart(val)
+		case sqlparser.LessThanStr, sqlparser.LessEqualStr:
+			k.intersectEnd(val)
+		}
+	case *sqlparser.RangeCond:
+		if expr.Operator != sqlparser.BetweenStr || !nameMatch(expr.Left, table, shardkey) {
+			return
+		}
+		if from, ok := expr.From.(*sqlparser.SQLVal); ok {
+			k.intersectStart(from)
+		}
+		if to, ok := expr.To.(*sqlparser.SQLVal); ok {
+			k.intersectEnd(to)
+		}
+	}
+}
+
+// intersectVals intersects the value set with the vals.
+func (k *keyRange) intersectVals(vals []*sqlparser.SQLVal) {
+	if !k.hasVals {
+		k.vals = vals
+		k.hasVals = true
+		return
+	}
+
+	var both []*sqlparser.SQLVal
+	for _, val := range vals {
+		for _, old := range k.vals {
+			if bytes.Equal(val.Val, old.Val) {
+				both = append(both, val)
+				break
+			}
+		}
+	}
+	// The same value may be written in different forms, such as 1 and 1.0,
+	// the union is always a safe route.
+	if len(both) == 0 {
+		both = append(k.vals, vals...)
+	}
+	k.vals = both
+}
+
+// intersectStart intersects the inclusive lower bound.
+func (k *keyRange) intersectStart(val *sqlparser.SQLVal) {
+	if !isRangeVal(val) {
+		return
+	}
+	if k.start == nil {
+		k.start = val
+		return
+	}
+	if cmp, ok := compareSQLVal(val, k.start); ok && cmp > 0 {
+		k.start = val
+	}
+}
+
+// intersectEnd intersects the inclusive upper bound.
+func (k *keyRange) intersectEnd(val *sqlparser.SQLVal) {
+	if !isRangeVal(val) {
+		return
+	}
+	if k.end == nil {
+		k.end = val
+		return
+	}
+	if cmp, ok := compareSQLVal(val, k.end); ok && cmp < 0 {
+		k.end = val
+	}
+}
+
+// lookup returns the indexes of the segments which the key range routes to,
+// nil means all the segments.
+func (k *keyRange) lookup(database, table string, router *router.Router) ([]int, error) {
+	if k.hasVals {
+		var indexes []int
+		for _, val := range k.rangeVals() {
+			idx, err := router.GetIndex(database, table, val)
+			if err != nil {
+				return nil, err
+			}
+			indexes = append(indexes, idx)
+		}
+		return indexes, nil
+	}
+
+	if k.start == nil && k.end == nil {
+		return nil, nil
+	}
+	return router.LookupIndexes(database, table, k.start, k.end)
+}
+
+// rangeVals returns the vals in the range [start, end], only the numeric
+// values are compared with the bounds.
+func (k *keyRange) rangeVals() []*sqlparser.SQLVal {
+	var vals []*sqlparser.SQLVal
+	for _, val := range k.vals {
+		if k.start != nil {
+			if cmp, ok := compareNumeric(val, k.start); ok && cmp < 0 {
+				continue
+			}
+		}
+		if k.end != nil {
+			if cmp, ok := compareNumeric(val, k.end); ok && cmp > 0 {
+				continue
+			}
+		}
+		vals = append(vals, val)
+	}
+	// The filters match no rows, but the query still needs a route.
+	if len(vals) == 0 {
+		vals = k.vals[:1]
+	}
+	return vals
+}
+
+// maxTupleKeys limits the combinations of the composite shard key values,
+// the route is not pruned if exceeded.
+const maxTupleKeys = 1024
+
+// shardRange is the key ranges of the shard key columns.
+type shardRange struct {
+	keys   []string
+	ranges []keyRange
+	// lookups are the values of the lookup index columns, used if the shard key is not restricted.
+	lookups []*lookupRange
+}
+
+// lookupRange is the values of a lookup index column.
+type lookupRange struct {
+	index *config.LookupIndexConfig
+	rng   keyRange
+}
+
+// newShardRange creates the shardRange, the composite shard key has more than one column.
+func newShardRange(shardKey string) *shardRange {
+	keys := router.SplitShardKey(shardKey)
+	return &shardRange{
+		keys:   keys,
+		ranges: make([]keyRange, len(keys)),
+	}
+}
+
+// hasKey returns true if the field is a column of the shard key.
+func (s *shardRange) hasKey(field string) bool {
+	for _, key := range s.keys {
+		if strings.EqualFold(key, field) {
+			return true
+		}
+	}
+	return false
+}
+
+// addLookups adds the lookup indexes of the table, the building ones are skipped.
+func (s *shardRange) addLookups(indexes []*config.LookupIndexConfig) {
+	for _, index := range indexes {
+		if !index.Building {
+			s.lookups = append(s.lookups, &lookupRange{index: index})
+		}
+	}
+}
+
+// isFull returns true if neither the shard key nor the lookup index columns are restricted.
+func (s *shardRange) isFull() bool {
+	for i := range s.ranges {
+		if !s.ranges[i].isFull() {
+			return false
+		}
+	}
+	for _, l := range s.lookups {
+		if l.rng.hasVals {
+			return false
+		}
+	}
+	return true
+}
+
+// pushFilter intersects the key ranges with the filter.
+func (s *shardRange) pushFilter(expr sqlparser.Expr, table string) {
+	for i, key := range s.keys {
+		s.ranges[i].pushFilter(expr, table, key)
+	}
+	for _, l := range s.lookups {
+		l.rng.pushFilter(expr, table, l.index.Column)
+	}
+}
+
+// lookup returns the indexes of the segments which the shard key routes to,
+// nil means all the segments. The composite shard key is routed only if all
+// the columns are bound to the values.
+func (s *shardRange) lookup(database, table string, route *router.Router) ([]int, error) {
+	if len(s.ranges) == 1 {
+		if s.ranges[0].isFull() {
+			// Pruned by the lookup indexes at execution.
+			return nil, nil
+		}
+		return s.ranges[0].lookup(database, table, route)
+	}
+
+	tuples := [][]*sqlparser.SQLVal{nil}
+	for i := range s.ranges {
+		if !s.ranges[i].hasVals {
+			return nil, nil
+		}
+		vals := s.ranges[i].rangeVals()
+		if len(tuples)*len(vals) > maxTupleKeys {
+			return nil, nil
+		}
+		next := make([][]*sqlparser.SQLVal, 0, len(tuples)*len(vals))
+		for _, tuple := range tuples {
+			for _, val := range vals {
+				next = append(next, append(tuple[:len(tuple):len(tuple)], val))
+			}
+		}
+		tuples = next
+	}
+
+	var indexes []int
+	for _, tuple := range tuples {
+		key, err := router.TupleKey(tuple)
+		if err != nil {
+			return nil, err
+		}
+		idx, err := route.GetIndex(database, table, key)
+		if err != nil {
+			return nil, err
+		}
+		indexes = append(indexes, idx)
+	}
+	return indexes, nil
+}
+
+// lookupPrune returns the prune of the routes by the first lookup index column bound to
+// the values, nil if the shard key is restricted or no lookup index column is bound.
+func (s *shardRange) lookupPrune(database, table string, route *router.Router) *LookupPrune {
+	if len(s.ranges) != 1 || !s.ranges[0].isFull() {
+		return nil
+	}
+	for _, l := range s.lookups {
+		if l.rng.hasVals {
+			return &LookupPrune{
+				router:   route,
+				database: database,
+				table:    table,
+				index:    l.index,
+				vals:     l.rng.rangeVals(),
+			}
+		}
+	}
+	return nil
+}
+
+// reverseOperator returns the operator when the operands are swapped.
+func reverseOperator(op string) string {
+	switch op {
+	case sqlparser.GreaterThanStr:
+		return sqlparser.LessThanStr
+	case sqlparser.GreaterEqualStr:
+		return sqlparser.LessEqualStr
+	case sqlparser.LessThanStr:
+		return sqlparser.GreaterThanStr
+	case sqlparser.LessEqualStr:
+		return sqlparser.GreaterEqualStr
+	case sqlparser.EqualStr, sqlparser.NullSafeEqualStr:
+		return op
+	}
+	// The others can't be reversed.
+	return ""
+}
+
+// isRangeVal returns true if the val can be used as the range bound.
+func isRangeVal(val *sqlparser.SQLVal) bool {
+	switch val.Type {
+	case sqlparser.IntVal, sqlparser.FloatVal, sqlparser.StrVal:
+		return true
+	}
+	return false
+}
+
+// compareNumeric compares the vals as numbers, the ok is false if
+// any of them is not a number.
+func compareNumeric(a, b *sqlparser.SQLVal) (int, bool) {
+	x, err := strconv.ParseFloat(common.BytesToString(a.Val), 64)
+	if err != nil {
+		return 0, false
+	}
+	y, err := strconv.ParseFloat(common.BytesToString(b.Val), 64)
+	if err != nil {
+		return 0, false
+	}
+	switch {
+	case x < y:
+		return -1, true
+	case x > y:
+		return 1, true
+	}
+	return 0, true
+}
+
+// compareSQLVal compares the vals as numbers if both are numbers,
+// the strings are compared in bytes.
+func compareSQLVal(a, b *sqlparser.SQLVal) (int, bool) {
+	if cmp, ok := compareNumeric(a, b); ok {
+		return cmp, true
+	}
+	if a.Type == sqlparser.StrVal && b.Type == sqlparser.StrVal {
+		return bytes.Compare(a.Val, b.Val), true
+	}
+	return 0, false
+}
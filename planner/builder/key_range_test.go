@@ -0,0 +1,267 @@
+/*
+ * NeoDB
+ *
+ * Copyright 2021-2030 The NeoDB Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package builder
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/sealdb/neodb/config"
+	"github.com/sealdb/neodb/router"
+
+	"github.com/sealdb/mysqlstack/sqlparser"
+	"github.com/sealdb/mysqlstack/xlog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeyRangePushFilter(t *testing.T) {
+	tests := []struct {
+		where string
+		start string
+		end   string
+		vals  []string
+	}{
+		{"a>1", "1", "", nil},
+		{"a>=1", "1", "", nil},
+		{"a<10", "", "10", nil},
+		{"a<=10", "", "10", nil},
+		{"10>a", "", "10", nil},
+		{"1<=a", "1", "", nil},
+		{"a>1.5", "1.5", "", nil},
+		{"a>'abc'", "abc", "", nil},
+		{"a between 1 and 10", "1", "10", nil},
+		{"a>1 and a>5 and a<20 and a<=10", "5", "10", nil},
+		{"a>=5 and a between 1 and 10", "5", "10", nil},
+		{"a>='2023-01-01' and a<'2023-02-01' and a<'2023-03-01'", "2023-01-01", "2023-02-01", nil},
+		{"a=1", "", "", []string{"1"}},
+		{"a<=>1", "", "", []string{"1"}},
+		{"a in (1,2,3) and a in (2,3,4)", "", "", []string{"2", "3"}},
+		{"a in (1,2) and a=3", "", "", []string{"1", "2", "3"}},
+		{"a=1 or a=2", "", "", []string{"1", "2"}},
+		{"t.a>1 and a<5", "1", "5", nil},
+		// not the shard key or not supported.
+		{"b>1", "", "", nil},
+		{"t1.a>1", "", "", nil},
+		{"a!=1", "", "", nil},
+		{"a>b", "", "", nil},
+		{"a not between 1 and 10", "", "", nil},
+		{"a in (1,b)", "", "", nil},
+		{"a>0x12", "", "", nil},
+		{"a like '1%'", "", "", nil},
+		{"'1%' like a", "", "", nil},
+		{"a>1 or a<0", "", "", nil},
+	}
+
+	for _, test := range tests {
+		node, err := sqlparser.Parse("select * from t where " + test.where)
+		assert.Nil(t, err)
+
+		var kr keyRange
+		for _, filter := range splitAndExpression(nil, node.(*sqlparser.Select).Where.Expr) {
+			kr.pushFilter(convertOrToIn(skipParenthesis(filter)), "t", "a")
+		}
+
+		start, end := "", ""
+		if kr.start != nil {
+			start = string(kr.start.Val)
+		}
+		if kr.end != nil {
+			end = string(kr.end.Val)
+		}
+		var vals []string
+		for _, val := range kr.vals {
+			vals = append(vals, string(val.Val))
+		}
+		assert.Equal(t, test.start, start, test.where)
+		assert.Equal(t, test.end, end, test.where)
+		assert.Equal(t, test.vals, vals, test.where)
+		assert.Equal(t, test.start == "" && test.end == "" && test.vals == nil, kr.isFull(), test.where)
+	}
+}
+
+func TestKeyRangeLookup(t *testing.T) {
+	tests := []struct {
+		table   string
+		where   string
+		indexes []int
+	}{
+		// list: 1, 5, 6.
+		{"L", "id>1", nil},
+		{"L", "id<=5", []int{0, 1}},
+		{"L", "id between 2 and 5", []int{1}},
+		{"L", "id>=1", nil},
+		{"L", "id in (1,5,6) and id>=5", []int{1, 2}},
+		{"L", "id in (1,5) and id>10", []int{0}},
+		// range: [,100), [100,200), [200,).
+		{"RG", "id<100", []int{0, 1}},
+		{"RG", "id>=100 and id<200", []int{1, 2}},
+		{"RG", "id>=100 and id<=200", []int{1, 2}},
+		{"RG", "id>199", []int{1, 2}},
+		{"RG", "id>=200", []int{2}},
+		{"RG", "id in (1, 300)", []int{0, 2}},
+		// hash.
+		{"A", "id>1 and id<10", nil},
+		{"A", "id=1 and id>0", []int{2323}},
+	}
+
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	database := "sbtest"
+
+	route, cleanup := router.MockNewRouter(log)
+	defer cleanup()
+
+	err := route.CreateDatabase(database)
+	assert.Nil(t, err)
+	err = route.AddForTest(database, router.MockTableMConfig(), router.MockTableListConfig(), router.MockTableRangeConfig())
+	assert.Nil(t, err)
+
+	for _, test := range tests {
+		node, err := sqlparser.Parse("select * from t where " + test.where)
+		assert.Nil(t, err)
+
+		var kr keyRange
+		for _, filter := range splitAndExpression(nil, node.(*sqlparser.Select).Where.Expr) {
+			kr.pushFilter(filter, test.table, "id")
+		}
+		indexes, err := kr.lookup(database, test.table, route)
+		assert.Nil(t, err)
+		assert.Equal(t, test.indexes, indexes, test.where)
+	}
+
+	// error.
+	{
+		kr := keyRange{}
+		kr.intersectVals([]*sqlparser.SQLVal{sqlparser.NewIntVal([]byte("2"))})
+		_, err := kr.lookup(database, "L", route)
+		assert.EqualError(t, err, "Table has no partition for value 2")
+	}
+}
+
+func TestShardRangeLookup(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	database := "sbtest"
+
+	route, cleanup := router.MockNewRouter(log)
+	defer cleanup()
+
+	err := route.CreateDatabase(database)
+	assert.Nil(t, err)
+	err = route.AddForTest(database, router.MockTableCKConfig())
+	assert.Nil(t, err)
+
+	getIndex := func(tenant, user string) int {
+		key, err := router.TupleKey([]*sqlparser.SQLVal{sqlparser.NewIntVal([]byte(tenant)), sqlparser.NewIntVal([]byte(user))})
+		assert.Nil(t, err)
+		idx, err := route.GetIndex(database, "CK", key)
+		assert.Nil(t, err)
+		return idx
+	}
+
+	tests := []struct {
+		where   string
+		indexes []int
+	}{
+		{"tenant_id=1", nil},
+		{"tenant_id=1 and user_id>2", nil},
+		{"tenant_id=1 and user_id=2", []int{getIndex("1", "2")}},
+		{"user_id=2 and 1=tenant_id and b=3", []int{getIndex("1", "2")}},
+		{"tenant_id in (1,2) and user_id=3", []int{getIndex("1", "3"), getIndex("2", "3")}},
+	}
+
+	for _, test := range tests {
+		node, err := sqlparser.Parse("select * from CK where " + test.where)
+		assert.Nil(t, err)
+
+		sr := newShardRange("tenant_id,user_id")
+		assert.True(t, sr.isFull())
+		for _, filter := range splitAndExpression(nil, node.(*sqlparser.Select).Where.Expr) {
+			sr.pushFilter(filter, "CK")
+		}
+		assert.False(t, sr.isFull())
+		assert.True(t, sr.hasKey("USER_ID"))
+		assert.False(t, sr.hasKey("b"))
+		indexes, err := sr.lookup(database, "CK", route)
+		assert.Nil(t, err)
+		assert.Equal(t, test.indexes, indexes, test.where)
+	}
+
+	// error.
+	{
+		sr := newShardRange("tenant_id,user_id")
+		sr.ranges[0].intersectVals([]*sqlparser.SQLVal{sqlparser.NewIntVal([]byte("1"))})
+		sr.ranges[1].intersectVals([]*sqlparser.SQLVal{sqlparser.NewValArg([]byte(":a"))})
+		_, err := sr.lookup(database, "CK", route)
+		assert.EqualError(t, err, "hash.unsupported.key.type:[5]")
+	}
+}
+
+func TestSelectPlanOpenBound(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	database := "sbtest"
+
+	route, cleanup := router.MockNewRouter(log)
+	defer cleanup()
+
+	err := route.CreateDatabase(database)
+	assert.Nil(t, err)
+	// IV is split by day on the datetime ts, D is split by range on the decimal id.
+	err = route.AddForTest(database,
+		&config.TableConfig{
+			Name:      "IV",
+			ShardType: router.MethodTypeInterval,
+			ShardKey:  "ts",
+			Interval:  &config.IntervalConfig{Unit: router.IntervalDay},
+			Partitions: []*config.PartitionConfig{
+				{Table: "IV_0000", Backend: "backend1", LessThan: "2021-01-02 00:00:00"},
+				{Table: "IV_0001", Backend: "backend2", LessThan: "2021-01-03 00:00:00"},
+			},
+		},
+		&config.TableConfig{
+			Name:      "D",
+			ShardType: router.MethodTypeRange,
+			ShardKey:  "id",
+			Partitions: []*config.PartitionConfig{
+				{Table: "D_0000", Backend: "backend1", LessThan: "100"},
+				{Table: "D_0001", Backend: "backend2", LessThan: "200"},
+				{Table: "D_0002", Backend: "backend3", LessThan: "MAXVALUE"},
+			},
+		})
+	assert.Nil(t, err)
+
+	// The open bound keeps the partition of the bound value, which holds the values near it,
+	// such as '2021-01-01 12:00' for 'ts > 20210101' and 99.5 for 'id > 99'.
+	tests := []struct {
+		query  string
+		tables []string
+	}{
+		{"select * from IV where ts > 20210101", []string{"IV_0000", "IV_0001"}},
+		{"select * from IV where ts > '2021-01-01 12:00:00'", []string{"IV_0000", "IV_0001"}},
+		{"select * from IV where ts < '2021-01-02 00:00:00'", []string{"IV_0000", "IV_0001"}},
+		{"select * from IV where ts >= '2021-01-02 00:00:00'", []string{"IV_0001"}},
+		{"select * from D where id > 99", []string{"D_0000", "D_0001", "D_0002"}},
+		{"select * from D where id < 100.5", []string{"D_0000", "D_0001"}},
+		{"select * from D where id > 199.5", []string{"D_0001", "D_0002"}},
+		{"select * from D where id >= 200", []string{"D_0002"}},
+	}
+	for _, test := range tests {
+		node, err := sqlparser.Parse(test.query)
+		assert.Nil(t, err)
+		plan, err := BuildNode(log, route, database, node.(sqlparser.SelectStatement))
+		assert.Nil(t, err, test.query)
+		var tables []string
+		for _, q := range plan.GetQuery() {
+			for _, table := range test.tables {
+				if strings.Contains(q.Query, "."+table+" ") {
+					tables = append(tables, table)
+				}
+			}
+		}
+		assert.Equal(t, test.tables, tables, test.query)
+	}
+}
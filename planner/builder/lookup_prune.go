@@ -0,0 +1,120 @@
+/*
+ * NeoDB
+ *
+ * Copyright 2021-2030 The NeoDB Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package builder
+
+import (
+	"sort"
+
+	"github.com/sealdb/neodb/config"
+	"github.com/sealdb/neodb/router"
+	"github.com/sealdb/neodb/xcontext"
+
+	"github.com/sealdb/mysqlstack/sqlparser"
+	"github.com/sealdb/mysqlstack/sqlparser/depends/sqltypes"
+)
+
+// LookupPrune prunes the routes of a table by the values of its lookup index column, such as
+// 'email=?' routes to the shard keys which the lookup table maps the email to. The lookup table
+// is read by the executor in the transaction, so the pairs written by the transaction are seen,
+// and the EXPLAIN doesn't touch the backends.
+type LookupPrune struct {
+	router   *router.Router
+	database string
+	table    string
+	index    *config.LookupIndexConfig
+	vals     []*sqlparser.SQLVal
+	// tables are the sub tables of the routes.
+	tables []string
+}
+
+// setSegments sets the segments of the routes.
+func (l *LookupPrune) setSegments(segments []router.Segment) {
+	l.tables = make([]string, len(segments))
+	for i, segment := range segments {
+		l.tables[i] = segment.Table
+	}
+}
+
+// Querys returns the querys reading the shard keys of the values from the lookup table,
+// one for each sub table of the lookup table.
+func (l *LookupPrune) Querys() ([]xcontext.QueryTuple, error) {
+	shardKey, err := l.router.ShardKey(l.database, l.table)
+	if err != nil {
+		return nil, err
+	}
+
+	// Group the values by the sub tables of the lookup table.
+	segments := make(map[string]router.Segment)
+	vals := make(map[string]sqlparser.ValTuple)
+	for _, val := range l.vals {
+		segs, err := l.router.Lookup(l.database, l.index.Name, val, val)
+		if err != nil {
+			return nil, err
+		}
+		for _, segment := range segs {
+			segments[segment.Table] = segment
+			vals[segment.Table] = append(vals[segment.Table], val)
+		}
+	}
+	subTables := make([]string, 0, len(vals))
+	for subTable := range vals {
+		subTables = append(subTables, subTable)
+	}
+	sort.Strings(subTables)
+
+	querys := make([]xcontext.QueryTuple, 0, len(subTables))
+	for _, subTable := range subTables {
+		segment := segments[subTable]
+		buf := sqlparser.NewTrackedBuffer(nil)
+		buf.Myprintf("select distinct `%s`, `%s` from `%s`.`%s` where `%s` in %v", l.index.Column, shardKey, l.database, subTable, l.index.Column, vals[subTable])
+		querys = append(querys, xcontext.QueryTuple{
+			Query:   buf.String(),
+			Backend: segment.Backend,
+			Range:   segment.Range.String(),
+		})
+	}
+	return querys, nil
+}
+
+// Routes returns whether the routes are kept by the rows read by the Querys, nil if all the
+// routes are kept since some value is not found in the lookup table. At least one route is
+// kept, so the fields are returned even if no row matches.
+func (l *LookupPrune) Routes(results []*sqltypes.Result) ([]bool, error) {
+	var rows [][]sqltypes.Value
+	for _, res := range results {
+		rows = append(rows, res.Rows...)
+	}
+	keys, complete := router.LookupIndexKeys(l.vals, rows)
+	if !complete {
+		return nil, nil
+	}
+
+	tables := make(map[string]bool)
+	for _, key := range keys {
+		idx, err := l.router.GetIndex(l.database, l.table, key)
+		if err != nil {
+			return nil, err
+		}
+		segments, err := l.router.GetSegments(l.database, l.table, []int{idx})
+		if err != nil {
+			return nil, err
+		}
+		tables[segments[0].Table] = true
+	}
+	keep := make([]bool, len(l.tables))
+	found := false
+	for i, table := range l.tables {
+		keep[i] = tables[table]
+		found = found || keep[i]
+	}
+	if !found && len(keep) > 0 {
+		keep[0] = true
+	}
+	return keep, nil
+}
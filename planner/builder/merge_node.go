@@ -12,13 +12,17 @@ package builder
 import (
 	"fmt"
 	"math/rand"
+	"sort"
 	"strings"
 	"time"
 
 	"github.com/sealdb/neodb/router"
 	"github.com/sealdb/neodb/xcontext"
+	"github.com/sealdb/neodb/xparser"
 
+	"github.com/pkg/errors"
 	"github.com/sealdb/mysqlstack/sqlparser"
+	"github.com/sealdb/mysqlstack/sqlparser/depends/sqltypes"
 	"github.com/sealdb/mysqlstack/xlog"
 )
 
@@ -39,6 +43,8 @@ type MergeNode struct {
 	routeLen int
 	// referred tables' tableInfo map.
 	referTables map[string]*tableInfo
+	// the tables of the pushed down subqueries, renamed to the co-located sub tables.
+	subTables []*tableInfo
 	// whether has parenthese in FROM clause.
 	hasParen bool
 	// parent node in the plan tree.
@@ -49,6 +55,10 @@ type MergeNode struct {
 	Querys []xcontext.QueryTuple
 	// querys with bind locations.
 	ParsedQuerys []*sqlparser.ParsedQuery
+	// querys looking up the batch keys, the key values are bound to the list bindvars.
+	BatchQuerys []*sqlparser.ParsedQuery `json:",omitempty"`
+	// the batch keys of the parent NestLoop join.
+	batchKeys []*BatchKey
 	// the returned result fields, used in the Multiple Plan Tree.
 	fields []selectTuple
 	order  int
@@ -56,6 +66,8 @@ type MergeNode struct {
 	ReqMode xcontext.RequestMode
 	// aliasIndex is the tmp col's alias index.
 	aliasIndex int
+	// the prunes of the routes by the lookup indexes at execution.
+	prunes []*LookupPrune
 }
 
 // newMergeNode used to create MergeNode.
@@ -93,14 +105,9 @@ func (m *MergeNode) pushFilter(filter exprInfo) error {
 	m.addWhere(filter.expr)
 	if len(filter.referTables) == 1 {
 		tbInfo := m.referTables[filter.referTables[0]]
-		if tbInfo.shardKey != "" && len(filter.vals) > 0 {
-			if nameMatch(filter.cols[0], filter.referTables[0], tbInfo.shardKey) {
-				for _, val := range filter.vals {
-					if err := fetchIndex(tbInfo, val, m.router); err != nil {
-						return err
-					}
-				}
-			}
+		if tbInfo.shardRange != nil {
+			tbInfo.shardRange.pushFilter(filter.expr, filter.referTables[0])
+			return fetchIndex(tbInfo, m.router)
 		}
 	}
 	return nil
@@ -111,12 +118,9 @@ func (m *MergeNode) pushKeyFilter(filter exprInfo, table, field string) error {
 	m.addWhere(expr)
 
 	tbInfo := m.referTables[table]
-	if strings.EqualFold(tbInfo.shardKey, field) && len(filter.vals) > 0 {
-		for _, val := range filter.vals {
-			if err := fetchIndex(tbInfo, val, m.router); err != nil {
-				return err
-			}
-		}
+	if tbInfo.shardRange != nil && tbInfo.shardRange.hasKey(field) {
+		tbInfo.shardRange.pushFilter(filter.expr, table)
+		return fetchIndex(tbInfo, m.router)
 	}
 	return nil
 }
@@ -141,10 +145,38 @@ func (m *MergeNode) addNoTableFilter(exprs []sqlparser.Expr) {
 	}
 }
 
+// rebuildIndexes rebuilds the shard indexes from the referred tables.
+func (m *MergeNode) rebuildIndexes() {
+	aliases := make([]string, 0, len(m.referTables))
+	for alias := range m.referTables {
+		aliases = append(aliases, alias)
+	}
+	sort.Strings(aliases)
+
+	var indexes []int
+	for _, alias := range aliases {
+		indexes = append(indexes, m.referTables[alias].indexes...)
+	}
+	m.indexes = indexes
+}
+
 // calcRoute used to calc the route.
 func (m *MergeNode) calcRoute() (PlanNode, error) {
 	var err error
 	for _, tbInfo := range m.referTables {
+		if tbInfo.derived != nil {
+			// The pushed down derived table routes to a single backend,
+			// the derived table from dual goes with the other tables.
+			if (m.nonGlobalCnt > 0 && tbInfo.derived.nonGlobalCnt == 0) || tbInfo.derived.ReqMode == xcontext.ReqSingle {
+				continue
+			}
+			m.backend = tbInfo.derived.backend
+			m.routeLen = 1
+			if m.nonGlobalCnt == 0 {
+				break
+			}
+			continue
+		}
 		if m.nonGlobalCnt == 0 {
 			segments, err := m.router.Lookup(tbInfo.database, tbInfo.tableName, nil, nil)
 			if err != nil {
@@ -171,6 +203,10 @@ func (m *MergeNode) calcRoute() (PlanNode, error) {
 			m.routeLen = len(tbInfo.Segments)
 		}
 	}
+	// All the tables are the derived tables from dual.
+	if m.routeLen == 0 && m.ReqMode == xcontext.ReqSingle {
+		m.routeLen = 1
+	}
 	return m, nil
 }
 
@@ -205,12 +241,37 @@ func (m *MergeNode) pushSelectExprs(fields, groups []selectTuple, sel *sqlparser
 	}
 
 	if aggTyp != nullAgg || len(groups) > 0 {
-		aggrPlan := NewAggregatePlan(m.log, node.SelectExprs, fields, groups, aggTyp == canPush)
+		// The exprs over the aggregates are computed after the aggregation,
+		// the backends fetch the aggregates which the exprs depend on.
+		var projectPlan *ProjectPlan
+		pushed := fields
+		if hasComputed(fields) {
+			projectPlan = NewProjectPlan(m.log, fields)
+			if err := projectPlan.Build(); err != nil {
+				return err
+			}
+			pushed = projectPlan.input
+			node.SelectExprs = tupleExprs(pushed)
+		}
+
+		aggrPlan := NewAggregatePlan(m.log, node.SelectExprs, pushed, groups, aggTyp == canPush)
 		if err := aggrPlan.Build(); err != nil {
 			return err
 		}
 		m.children = append(m.children, aggrPlan)
+		if projectPlan != nil {
+			m.children = append(m.children, projectPlan)
+		}
 		node.SelectExprs = aggrPlan.ReWritten()
+		if aggTyp == notPush {
+			// The aggregates are finished in the proxy, the backends only fetch the rows.
+			// If the duplicate rows don't affect the aggregates, fetch the distinct rows.
+			node.GroupBy = nil
+			node.Distinct = ""
+			if aggrPlan.ignoreDuplicates() {
+				node.Distinct = sqlparser.DistinctStr
+			}
+		}
 	}
 	return nil
 }
@@ -230,19 +291,74 @@ func (m *MergeNode) pushSelectExpr(field selectTuple) (int, error) {
 }
 
 // pushHaving used to push having expr.
+// The having on the aggregates merged in the proxy is filtered after the aggregation.
 func (m *MergeNode) pushHaving(having exprInfo) error {
+	if m.hasAggregate() && havingInProxy(having, m.fields) {
+		var err error
+		m.children, err = pushHavingPlan(m.log, m.children, having.expr, m.fields)
+		return err
+	}
 	m.addHaving(having.expr)
 	return nil
 }
 
 // pushOrderBy used to push the order by exprs.
 func (m *MergeNode) pushOrderBy(orderBy sqlparser.OrderBy) error {
-	m.Sel.(*sqlparser.Select).OrderBy = orderBy
+	// The fields computed in the proxy are unknown to the backends, and the exprs
+	// over the aggregates are only known after the aggregation.
+	if !m.hasWindow() && !orderByComputed(orderBy, m.fields) && (!m.hasAggregate() || orderByCols(orderBy)) {
+		m.Sel.(*sqlparser.Select).OrderBy = orderBy
+	}
 	orderPlan := NewOrderByPlan(m.log, orderBy, m)
 	m.children = append(m.children, orderPlan)
 	return orderPlan.Build()
 }
 
+// hasAggregate returns true if the aggregates are merged in the proxy.
+func (m *MergeNode) hasAggregate() bool {
+	for _, child := range m.children {
+		if _, ok := child.(*AggregatePlan); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// pushWindows used to push the window functions computed in the proxy.
+func (m *MergeNode) pushWindows(windows []windowTuple) error {
+	windowPlan := NewWindowPlan(m.log, windows, m.fields)
+	m.children = append(m.children, windowPlan)
+	return windowPlan.Build()
+}
+
+// hasWindow returns true if the window functions are computed in the proxy,
+// the order by and the limit cannot be pushed down.
+func (m *MergeNode) hasWindow() bool {
+	for _, child := range m.children {
+		if _, ok := child.(*WindowPlan); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// aggrInProxy returns true if the aggregates cannot be finished by the backends.
+func (m *MergeNode) aggrInProxy() bool {
+	for _, child := range m.children {
+		if aggr, ok := child.(*AggregatePlan); ok {
+			return !aggr.IsPushDown || len(aggr.groupAggrs) > 0
+		}
+	}
+	return false
+}
+
+// pushDistinct used to deduplicate the distinct rows fetched from the backends.
+func (m *MergeNode) pushDistinct() error {
+	distinctPlan := NewDistinctPlan(m.log, m.fields)
+	m.children = append(m.children, distinctPlan)
+	return distinctPlan.Build()
+}
+
 // pushLimit used to push limit.
 func (m *MergeNode) pushLimit(limit *sqlparser.Limit) error {
 	limitPlan := NewLimitPlan(m.log, limit)
@@ -250,7 +366,7 @@ func (m *MergeNode) pushLimit(limit *sqlparser.Limit) error {
 		return err
 	}
 	m.children = append(m.children, limitPlan)
-	if len(m.Sel.(*sqlparser.Select).GroupBy) == 0 {
+	if len(m.Sel.(*sqlparser.Select).GroupBy) == 0 && !m.aggrInProxy() && !m.hasWindow() {
 		// Rewrite the limit clause.
 		m.Sel.SetLimit(limitPlan.ReWritten())
 	}
@@ -281,7 +397,6 @@ func (m *MergeNode) Order() int {
 
 // buildQuery used to build the QueryTuple.
 func (m *MergeNode) buildQuery(root PlanNode) {
-	var Range string
 	tbInfos := root.getReferTables()
 	if sel, ok := m.Sel.(*sqlparser.Select); ok {
 		if len(sel.SelectExprs) == 0 {
@@ -292,6 +407,17 @@ func (m *MergeNode) buildQuery(root PlanNode) {
 
 	varFormatter := func(buf *sqlparser.TrackedBuffer, node sqlparser.SQLNode) {
 		switch node := node.(type) {
+		case *sqlparser.Subquery:
+			// The pushed down subquery is uncorrelated.
+			sub := sqlparser.NewTrackedBuffer(formatWindow)
+			formatWindow(sub, node)
+			buf.WriteString(sub.String())
+			return
+		case *sqlparser.FuncExpr:
+			if xparser.IsWindow(node) {
+				formatWindow(buf, node)
+				return
+			}
 		case *sqlparser.ColName:
 			tableName := node.Qualifier.Name.String()
 			if tableName != "" {
@@ -308,27 +434,68 @@ func (m *MergeNode) buildQuery(root PlanNode) {
 		node.Format(buf)
 	}
 
-	for i := 0; i < m.routeLen; i++ {
-		// Rewrite the shard table's name.
-		backend := m.backend
-		for _, tbInfo := range m.referTables {
-			if tbInfo.shardKey == "" {
-				continue
+	// batchFormatter replaces the batch key's join condition with the IN-list.
+	batchFormatter := func(buf *sqlparser.TrackedBuffer, node sqlparser.SQLNode) {
+		if expr, ok := node.(*sqlparser.ComparisonExpr); ok {
+			for _, key := range m.batchKeys {
+				if key.expr == expr {
+					buf.Myprintf("%v in %a", key.col, "::"+key.Var)
+					return
+				}
+			}
+		}
+		varFormatter(buf, node)
+	}
+
+	// The batch querys select the right keys to join the left rows in memory.
+	var batchExprs sqlparser.SelectExprs
+	if len(m.batchKeys) > 0 {
+		sel := m.Sel.(*sqlparser.Select)
+		batchExprs = make(sqlparser.SelectExprs, 0, len(sel.SelectExprs)+len(m.batchKeys))
+		batchExprs = append(batchExprs, sel.SelectExprs...)
+	next:
+		for _, key := range m.batchKeys {
+			table := key.col.Qualifier.Name.String()
+			for i, field := range m.fields {
+				if field.isCol && table == field.info.referTables[0] && strings.EqualFold(key.col.Name.String(), field.field) {
+					key.RightIdx = i
+					continue next
+				}
 			}
-			if backend == "" {
-				backend = tbInfo.Segments[i].Backend
+			key.RightIdx = len(batchExprs)
+			batchExprs = append(batchExprs, &sqlparser.AliasedExpr{Expr: key.col})
+		}
+	}
+
+	// The routes of the tables bound to the lookup index values are pruned at execution.
+	if m.routeLen > 1 {
+		for _, tbInfo := range m.referTables {
+			if tbInfo.prune != nil && tbInfo.shardKey != "" && len(tbInfo.Segments) >= m.routeLen {
+				tbInfo.prune.setSegments(tbInfo.Segments[:m.routeLen])
+				m.prunes = append(m.prunes, tbInfo.prune)
 			}
-			Range = tbInfo.Segments[i].Range.String()
-			expr, _ := tbInfo.tableExpr.Expr.(sqlparser.TableName)
-			expr.Name = sqlparser.NewTableIdent(tbInfo.Segments[i].Table)
-			tbInfo.tableExpr.Expr = expr
 		}
+	}
+
+	for i := 0; i < m.routeLen; i++ {
+		// Rewrite the shard table's name.
+		backend, Range := m.renameTables(i)
 
 		buf := sqlparser.NewTrackedBuffer(varFormatter)
 		varFormatter(buf, m.Sel)
 		pq := buf.ParsedQuery()
 		m.ParsedQuerys = append(m.ParsedQuerys, pq)
 
+		if len(m.batchKeys) > 0 {
+			sel := m.Sel.(*sqlparser.Select)
+			exprs := sel.SelectExprs
+			sel.SelectExprs = batchExprs
+			buf := sqlparser.NewTrackedBuffer(batchFormatter)
+			batchFormatter(buf, sel)
+			sel.SelectExprs = exprs
+			m.BatchQuerys = append(m.BatchQuerys, buf.ParsedQuery())
+		}
+
 		tuple := xcontext.QueryTuple{
 			Query:   pq.Query,
 			Backend: backend,
@@ -338,11 +505,205 @@ func (m *MergeNode) buildQuery(root PlanNode) {
 	}
 }
 
+// renameTables used to rename the shard tables to the sub tables of the route i,
+// returns the backend and the range of the route.
+func (m *MergeNode) renameTables(i int) (string, string) {
+	var Range string
+	backend := m.backend
+	for _, tbInfo := range m.referTables {
+		if tbInfo.shardKey == "" {
+			continue
+		}
+		if backend == "" {
+			backend = tbInfo.Segments[i].Backend
+		}
+		Range = tbInfo.Segments[i].Range.String()
+		expr, _ := tbInfo.tableExpr.Expr.(sqlparser.TableName)
+		expr.Name = sqlparser.NewTableIdent(tbInfo.Segments[i].Table)
+		tbInfo.tableExpr.Expr = expr
+	}
+	for _, tbInfo := range m.subTables {
+		expr, _ := tbInfo.tableExpr.Expr.(sqlparser.TableName)
+		expr.Name = sqlparser.NewTableIdent(tbInfo.Segments[i].Table)
+		tbInfo.tableExpr.Expr = expr
+	}
+	return backend, Range
+}
+
+// GenerateDML used to generate the DML querys sharing the FROM and WHERE of the node,
+// such as the multi-table delete whose tables are co-located. The shard tables are
+// renamed on each route before formatting, the DML only on the global tables is
+// executed on all the backends.
+func (m *MergeNode) GenerateDML(format func(sel *sqlparser.Select) string) ([]xcontext.QueryTuple, error) {
+	sel, ok := m.Sel.(*sqlparser.Select)
+	if !ok {
+		return nil, errors.New("unsupported: unknown.select.statement")
+	}
+
+	var querys []xcontext.QueryTuple
+	if m.nonGlobalCnt == 0 {
+		for _, tbInfo := range m.referTables {
+			segments, err := m.router.Lookup(tbInfo.database, tbInfo.tableName, nil, nil)
+			if err != nil {
+				return nil, err
+			}
+			query := format(sel)
+			for _, segment := range segments {
+				querys = append(querys, xcontext.QueryTuple{Query: query, Backend: segment.Backend})
+			}
+			return querys, nil
+		}
+	}
+	for i := 0; i < m.routeLen; i++ {
+		backend, Range := m.renameTables(i)
+		querys = append(querys, xcontext.QueryTuple{
+			Query:   format(sel),
+			Backend: backend,
+			Range:   Range,
+		})
+	}
+	return querys, nil
+}
+
+// GenerateInsert used to generate the INSERT ... SELECT querys executed on the backends
+// where the rows are read, the key is the index of the destination's shard key in the
+// select exprs. The rows of the partitioned destination must be read from the co-located
+// shard tables by the shard key, the global or single destination only reads the global
+// tables or the tables on its backend. Returns false if the insert cannot be pushed down.
+func (m *MergeNode) GenerateInsert(database, table string, key int, format func(table string, sel sqlparser.SelectStatement) string) ([]xcontext.QueryTuple, bool, error) {
+	conf, err := m.router.TableConfig(database, table)
+	if err != nil {
+		return nil, false, err
+	}
+	segments, err := m.router.Lookup(database, table, nil, nil)
+	if err != nil {
+		return nil, false, err
+	}
+
+	var querys []xcontext.QueryTuple
+	switch conf.ShardType {
+	case "GLOBAL", "SINGLE":
+		if m.nonGlobalCnt != 0 {
+			if conf.ShardType == "GLOBAL" || m.routeLen != 1 {
+				return nil, false, nil
+			}
+			if backend, _ := m.renameTables(0); backend != segments[0].Backend {
+				return nil, false, nil
+			}
+		}
+		query := format(table, m.Sel)
+		for _, segment := range segments {
+			querys = append(querys, xcontext.QueryTuple{Query: query, Backend: segment.Backend})
+		}
+		return querys, true, nil
+	}
+
+	// The rows of the routes are merged in proxy.
+	if m.routeLen > 1 && len(m.children) > 0 {
+		return nil, false, nil
+	}
+	sel, ok := m.Sel.(*sqlparser.Select)
+	if !ok || key < 0 || key >= len(sel.SelectExprs) {
+		return nil, false, nil
+	}
+	expr, ok := sel.SelectExprs[key].(*sqlparser.AliasedExpr)
+	if !ok {
+		return nil, false, nil
+	}
+	col, ok := expr.Expr.(*sqlparser.ColName)
+	if !ok {
+		return nil, false, nil
+	}
+	var src *tableInfo
+	for alias, tbInfo := range m.referTables {
+		if tbInfo.derived == nil && col.Name.EqualString(tbInfo.shardKey) &&
+			(col.Qualifier.IsEmpty() || col.Qualifier.Name.String() == alias) {
+			src = tbInfo
+			break
+		}
+	}
+	if src == nil || !isColocated(src, &tableInfo{tableConfig: conf}) {
+		return nil, false, nil
+	}
+
+	dsts, err := m.router.GetSegments(database, table, m.indexes)
+	if err != nil {
+		return nil, false, err
+	}
+	if len(dsts) != m.routeLen {
+		return nil, false, nil
+	}
+	for i, dst := range dsts {
+		if dst.Backend != src.Segments[i].Backend {
+			return nil, false, nil
+		}
+	}
+	for i, dst := range dsts {
+		backend, _ := m.renameTables(i)
+		querys = append(querys, xcontext.QueryTuple{
+			Query:   format(dst.Table, m.Sel),
+			Backend: backend,
+			Range:   dst.Range.String(),
+		})
+	}
+	return querys, true, nil
+}
+
 // GetQuery used to get the Querys.
 func (m *MergeNode) GetQuery() []xcontext.QueryTuple {
 	return m.Querys
 }
 
+// LookupPrunes returns the prunes of the routes by the lookup indexes, the lookup tables are
+// read by the executor and the routes not kept by any prune are skipped.
+func (m *MergeNode) LookupPrunes() []*LookupPrune {
+	return m.prunes
+}
+
+// MergeOrder returns the order of the rows sorted by the backends with the offset and
+// limit after it, if the leading order by is pushed down. The sorted rows of the shards
+// can be merged by the order instead of being sorted again, the limit is -1 if unlimited.
+func (m *MergeNode) MergeOrder() ([]xcontext.OrderKey, int, int) {
+	sel, ok := m.Sel.(*sqlparser.Select)
+	if !ok || len(sel.OrderBy) == 0 || len(m.children) == 0 {
+		return nil, 0, -1
+	}
+	orderPlan, ok := m.children[0].(*OrderByPlan)
+	if !ok || sqlparser.String(orderPlan.node) != sqlparser.String(sel.OrderBy) {
+		return nil, 0, -1
+	}
+
+	var keys []xcontext.OrderKey
+	for _, orderBy := range orderPlan.OrderBys {
+		keys = append(keys, xcontext.OrderKey{
+			Field: orderBy.Field,
+			Table: orderBy.Table,
+			Desc:  orderBy.Direction == DESC,
+		})
+	}
+	if limitPlan := LimitAfterOrderBy(m.children); limitPlan != nil {
+		return keys, limitPlan.Offset, limitPlan.Limit
+	}
+	return keys, 0, -1
+}
+
+// RouteKey returns the index of the route which the batch key's value routes to,
+// -1 if the route is pruned by the filters.
+func (m *MergeNode) RouteKey(key *BatchKey, val sqltypes.Value) (int, error) {
+	tbInfo := m.referTables[key.Table]
+	vals := []*sqlparser.SQLVal{router.SQLValFromValue(val)}
+	_, segment, err := m.router.RouteValues(tbInfo.database, tbInfo.tableName, vals)
+	if err != nil {
+		return -1, err
+	}
+	for i := 0; i < m.routeLen && i < len(tbInfo.Segments); i++ {
+		if tbInfo.Segments[i].Table == segment.Table {
+			return i, nil
+		}
+	}
+	return -1, nil
+}
+
 // GenerateFieldQuery generates a query with an impossible where.
 // This will be used on the RHS node to fetch field info if the LHS
 // returns no result.
@@ -10,6 +10,8 @@
 package builder
 
 import (
+	"strconv"
+
 	"github.com/pkg/errors"
 	"github.com/sealdb/mysqlstack/sqlparser"
 	"github.com/sealdb/mysqlstack/sqlparser/depends/common"
@@ -60,18 +62,21 @@ func NewOrderByPlan(log *xlog.Log, node sqlparser.OrderBy, root PlanNode) *Order
 }
 
 // analyze used to check the 'order by' is at the support level.
+// The item can be the column, the ordinal of the select list or the expr in the select list,
+// the exprs not in the select list are fetched as the hidden fields before.
 func (p *OrderByPlan) analyze() error {
 	tbInfos := p.root.getReferTables()
 	for _, o := range p.node {
+		orderBy := OrderBy{}
+		switch o.Direction {
+		case "desc":
+			orderBy.Direction = DESC
+		case "asc":
+			orderBy.Direction = ASC
+		}
+
 		switch e := o.Expr.(type) {
 		case *sqlparser.ColName:
-			orderBy := OrderBy{}
-			switch o.Direction {
-			case "desc":
-				orderBy.Direction = DESC
-			case "asc":
-				orderBy.Direction = ASC
-			}
 			orderBy.Field = e.Name.String()
 			orderBy.Table = e.Qualifier.Name.String()
 			if orderBy.Table != "" {
@@ -116,12 +121,73 @@ func (p *OrderByPlan) analyze() error {
 					orderBy.Field = tuple.field
 				}
 			}
-			p.OrderBys = append(p.OrderBys, orderBy)
+		case *sqlparser.SQLVal:
+			// The constants don't affect the order.
+			if e.Type != sqlparser.IntVal {
+				continue
+			}
+			tuple, err := ordinalField(e, p.root.getFields())
+			if err != nil {
+				return err
+			}
+			orderBy.Field, orderBy.Table = fieldName(tuple)
+		case *sqlparser.NullVal:
+			continue
 		default:
-			buf := sqlparser.NewTrackedBuffer(nil)
-			e.Format(buf)
-			return errors.Errorf("unsupported: orderby:[%+v].type.should.be.colname", buf.String())
+			idx, ok := resolveField(p.root.getFields(), e)
+			if !ok {
+				buf := sqlparser.NewTrackedBuffer(nil)
+				e.Format(buf)
+				return errors.Errorf("unsupported: orderby:[%+v].type.should.be.colname", buf.String())
+			}
+			orderBy.Field, orderBy.Table = fieldName(p.root.getFields()[idx])
+		}
+		p.OrderBys = append(p.OrderBys, orderBy)
+	}
+	// The hidden fields are removed after sorting.
+	p.RemovedIdxs = append(p.RemovedIdxs, hiddenIdxs(p.root.getFields())...)
+	return nil
+}
+
+// ordinalField returns the field referred by the ordinal, such as: `order by 2`.
+func ordinalField(val *sqlparser.SQLVal, fields []selectTuple) (selectTuple, error) {
+	n, err := strconv.Atoi(string(val.Val))
+	if err != nil || n < 1 || n > len(fields) || fields[n-1].hidden {
+		return selectTuple{}, errors.Errorf("unsupported: unknown.column.'%s'.in.'order.clause'", val.Val)
+	}
+	for _, field := range fields[:n] {
+		if field.field == "*" {
+			return selectTuple{}, errors.Errorf("unsupported: orderby.ordinal.'%s'.with.'*'.in.select.list", val.Val)
+		}
+	}
+	return fields[n-1], nil
+}
+
+// fieldName returns the name and the table of the field in the results.
+func fieldName(tuple selectTuple) (string, string) {
+	if tuple.alias != "" {
+		return tuple.alias, ""
+	}
+	if col, ok := tuple.info.expr.(*sqlparser.ColName); ok && tuple.isCol {
+		return tuple.field, col.Qualifier.Name.String()
+	}
+	return tuple.field, ""
+}
+
+// LimitAfterOrderBy returns the limit executed right after the leading order by of
+// the children, only the projects can be between them. Returns nil if not found.
+func LimitAfterOrderBy(children []ChildPlan) *LimitPlan {
+	if len(children) == 0 || children[0].Type() != ChildTypeOrderby {
+		return nil
+	}
+	for _, child := range children[1:] {
+		switch child.Type() {
+		case ChildTypeProject:
+			continue
+		case ChildTypeLimit:
+			return child.(*LimitPlan)
 		}
+		break
 	}
 	return nil
 }
@@ -10,6 +10,7 @@
 package builder
 
 import (
+	"fmt"
 	"testing"
 
 	"github.com/sealdb/neodb/router"
@@ -67,11 +68,13 @@ func TestOrderByPlanError(t *testing.T) {
 		"select a,b from A order by rand()",
 		"select A.* from A order by X.a",
 		"select A.a from A join B on A.id=B.id order by b",
+		"select a,b from A order by 3",
 	}
 	results := []string{
 		"unsupported: orderby:[rand()].type.should.be.colname",
 		"unsupported: unknow.table.in.order.by.field[X.a]",
 		"unsupported: column.'b'.in.order.clause.is.ambiguous",
+		"unsupported: unknown.column.'3'.in.'order.clause'",
 	}
 
 	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
@@ -99,3 +102,62 @@ func TestOrderByPlanError(t *testing.T) {
 		}
 	}
 }
+
+func TestMergeOrder(t *testing.T) {
+	tcases := []struct {
+		query string
+		out   string
+	}{
+		{
+			query: "select id, name from A order by id desc, name limit 10, 5",
+			out:   "[{id  true} {name  false}] 10 5",
+		},
+		{
+			query: "select A.id, a+1 as b from A order by A.id, b",
+			out:   "[{id A false} {b  false}] 0 -1",
+		},
+		// The project is between the order by and the limit.
+		{
+			query: "select id, sum(a) from A group by id order by id limit 3",
+			out:   "[{id  false}] 0 3",
+		},
+		// The expr is sorted by the backends.
+		{
+			query: "select id, a+1 from A order by a+1 limit 3",
+			out:   "[{a + 1  false}] 0 3",
+		},
+		// The order by is executed after the aggregation.
+		{
+			query: "select name, count(*) as cnt from A group by name order by cnt",
+			out:   "[] 0 -1",
+		},
+		// The distinct is between the order by and the limit.
+		{
+			query: "select distinct name from A order by name limit 3",
+			out:   "[{name  false}] 0 -1",
+		},
+		{
+			query: "select id from A limit 3",
+			out:   "[] 0 -1",
+		},
+	}
+
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	database := "sbtest"
+
+	route, cleanup := router.MockNewRouter(log)
+	defer cleanup()
+
+	err := route.CreateDatabase(database)
+	assert.Nil(t, err)
+	err = route.AddForTest(database, router.MockTableMConfig())
+	assert.Nil(t, err)
+	for _, tcase := range tcases {
+		node, err := sqlparser.Parse(tcase.query)
+		assert.Nil(t, err)
+		p, err := BuildNode(log, route, database, node.(sqlparser.SelectStatement))
+		assert.Nil(t, err, tcase.query)
+		keys, offset, limit := p.(*MergeNode).MergeOrder()
+		assert.Equal(t, tcase.out, fmt.Sprintf("%v %d %d", keys, offset, limit), tcase.query)
+	}
+}
@@ -29,7 +29,9 @@ type PlanNode interface {
 	pushFilter(filter exprInfo) error
 	pushKeyFilter(filter exprInfo, table, field string) error
 	pushHaving(having exprInfo) error
+	pushWindows(windows []windowTuple) error
 	pushOrderBy(orderBy sqlparser.OrderBy) error
+	pushDistinct() error
 	pushLimit(limit *sqlparser.Limit) error
 	pushMisc(sel *sqlparser.Select)
 	reOrder(int)
@@ -83,13 +85,16 @@ func findParent(tables []string, node PlanNode) PlanNode {
 	return parent
 }
 
-func addFilter(s PlanNode, filter exprInfo) {
+func addFilter(s PlanNode, filter exprInfo) error {
 	switch node := s.(type) {
 	case *JoinNode:
 		node.otherFilter = append(node.otherFilter, filter)
 	case *MergeNode:
 		node.addWhere(filter.expr)
+	case *DerivedNode:
+		return node.pushFilter(filter)
 	}
+	return nil
 }
 
 // pushFilters push a WHERE clause down, and update the PlanNode info.
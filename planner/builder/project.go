@@ -11,6 +11,7 @@ package builder
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
 
 	"github.com/sealdb/neodb/router"
@@ -38,6 +39,30 @@ type selectTuple struct {
 	//field in the aggregate function.
 	aggrField       string
 	distinct, isCol bool
+	// the aggregates and the columns which the expr is computed by in the proxy,
+	// such as: `sum(a)/count(b)` depends on `sum(a)` and `count(b)`.
+	deps []selectTuple
+	// the field is only fetched for the having or the order by, and removed from the results.
+	hidden bool
+}
+
+// distinctAggrs are the aggregate functions which support DISTINCT.
+var distinctAggrs = map[string]bool{
+	"avg":   true,
+	"count": true,
+	"max":   true,
+	"min":   true,
+	"sum":   true,
+}
+
+// isAggregate returns true if the function is an aggregate function,
+// the sqlparser.Aggregates doesn't contain the JSON_ARRAYAGG/JSON_OBJECTAGG.
+func isAggregate(node *sqlparser.FuncExpr) bool {
+	switch node.Name.Lowered() {
+	case "json_arrayagg", "json_objectagg":
+		return true
+	}
+	return node.IsAggregate()
 }
 
 // parseSelectExpr parses the AliasedExpr to select tuple.
@@ -50,7 +75,11 @@ func parseSelectExpr(expr *sqlparser.AliasedExpr, tbInfos map[string]*tableInfo)
 	distinct := false
 	isCol := false
 	hasAggregates := false
+	// the aggregates are nested in the expr.
+	nested := false
 
+	// The aggregates in the window functions are computed by the backends.
+	windowed := windowAggregates(expr.Expr)
 	alias := expr.As.String()
 	if col, ok := expr.Expr.(*sqlparser.ColName); ok {
 		field = col.Name.String()
@@ -83,25 +112,60 @@ func parseSelectExpr(expr *sqlparser.AliasedExpr, tbInfos map[string]*tableInfo)
 			}
 			referTables = append(referTables, tableName)
 		case *sqlparser.FuncExpr:
-			distinct = node.Distinct
-			if node.IsAggregate() {
+			if isAggregate(node) && !windowed[node] {
 				hasAggregates = true
+				if hasNestedAggregate(node.Exprs) {
+					return false, errors.Errorf("unsupported: invalid.use.of.group.function[%s]", node.Name.String())
+				}
 				if node != expr.Expr {
-					return false, errors.Errorf("unsupported: '%s'.contain.aggregate.in.select.exprs", field)
+					nested = true
+					return true, nil
 				}
 				funcName = node.Name.String()
-				if len(node.Exprs) != 1 {
+				distinct = node.Distinct
+				argc := 1
+				if node.Name.Lowered() == "json_objectagg" {
+					argc = 2
+				}
+				if len(node.Exprs) != argc {
 					return false, errors.Errorf("unsupported: invalid.use.of.group.function[%s]", funcName)
 				}
 				buf := sqlparser.NewTrackedBuffer(nil)
 				node.Exprs.Format(buf)
 				aggrField = buf.String()
-				if aggrField == "*" && (node.Name.String() != "count" || distinct) {
+				if aggrField == "*" && (node.Name.Lowered() != "count" || distinct) {
+					return false, errors.Errorf("unsupported: syntax.error.at.'%s'", field)
+				}
+				if distinct && !distinctAggrs[node.Name.Lowered()] {
 					return false, errors.Errorf("unsupported: syntax.error.at.'%s'", field)
 				}
 			}
 		case *sqlparser.GroupConcatExpr:
-			return false, errors.Errorf("unsupported: group_concat.in.select.exprs")
+			hasAggregates = true
+			if hasNestedAggregate(node.Exprs) {
+				return false, errors.Errorf("unsupported: invalid.use.of.group.function[group_concat]")
+			}
+			if node != expr.Expr {
+				nested = true
+				return true, nil
+			}
+			funcName = "group_concat"
+			distinct = node.Distinct != ""
+			for _, e := range node.Exprs {
+				if _, ok := e.(*sqlparser.AliasedExpr); !ok {
+					return false, errors.Errorf("unsupported: syntax.error.at.'%s'", field)
+				}
+			}
+			for _, o := range node.OrderBy {
+				if val, ok := o.Expr.(*sqlparser.SQLVal); ok && val.Type == sqlparser.IntVal {
+					if n, err := strconv.Atoi(string(val.Val)); err != nil || n < 1 || n > len(node.Exprs) {
+						return false, errors.Errorf("unsupported: unknown.column.'%s'.in.order.clause", string(val.Val))
+					}
+				}
+			}
+			buf := sqlparser.NewTrackedBuffer(nil)
+			node.Exprs.Format(buf)
+			aggrField = buf.String()
 		case *sqlparser.Subquery:
 			return false, errors.Errorf("unsupported: subqueries.in.select.exprs")
 		}
@@ -111,7 +175,65 @@ func parseSelectExpr(expr *sqlparser.AliasedExpr, tbInfos map[string]*tableInfo)
 		return nil, hasAggregates, err
 	}
 
-	return &selectTuple{expr, exprInfo{expr.Expr, referTables, cols, nil}, field, alias, funcName, aggrField, distinct, isCol}, hasAggregates, nil
+	tuple := &selectTuple{expr, exprInfo{expr.Expr, referTables, cols, nil}, field, alias, funcName, aggrField, distinct, isCol, nil, false}
+	if nested {
+		if tuple.deps, err = parseDeps(expr.Expr, tbInfos); err != nil {
+			return nil, hasAggregates, err
+		}
+	}
+	return tuple, hasAggregates, nil
+}
+
+// hasNestedAggregate returns true if the args of the aggregate contain aggregate, such as: sum(count(a)).
+func hasNestedAggregate(exprs sqlparser.SelectExprs) bool {
+	found := false
+	sqlparser.Walk(func(node sqlparser.SQLNode) (kontinue bool, err error) {
+		switch node := node.(type) {
+		case *sqlparser.FuncExpr:
+			found = found || isAggregate(node)
+		case *sqlparser.GroupConcatExpr:
+			found = true
+		}
+		return !found, nil
+	}, exprs)
+	return found
+}
+
+// parseDeps parses the aggregates and the columns out of the aggregates in the expr,
+// the expr is computed by them in the proxy.
+// such as: `sum(a)/count(b)+c` depends on `sum(a)`, `count(b)` and `c`.
+func parseDeps(expr sqlparser.Expr, tbInfos map[string]*tableInfo) ([]selectTuple, error) {
+	var deps []selectTuple
+	err := sqlparser.Walk(func(node sqlparser.SQLNode) (kontinue bool, err error) {
+		var dep sqlparser.Expr
+		switch node := node.(type) {
+		case *sqlparser.ColName:
+			dep = node
+		case *sqlparser.FuncExpr:
+			if isAggregate(node) {
+				dep = node
+			}
+		case *sqlparser.GroupConcatExpr:
+			dep = node
+		}
+		if dep == nil {
+			return true, nil
+		}
+
+		tuple, _, err := parseSelectExpr(&sqlparser.AliasedExpr{Expr: dep}, tbInfos)
+		if err != nil {
+			return false, err
+		}
+		for _, d := range deps {
+			if d.isCol == tuple.isCol && strings.EqualFold(d.field, tuple.field) &&
+				(!d.isCol || d.info.referTables[0] == tuple.info.referTables[0]) {
+				return false, nil
+			}
+		}
+		deps = append(deps, *tuple)
+		return false, nil
+	}, expr)
+	return deps, err
 }
 
 func parseSelectExprs(exprs sqlparser.SelectExprs, root PlanNode) ([]selectTuple, aggrType, error) {
@@ -121,6 +243,7 @@ func parseSelectExprs(exprs sqlparser.SelectExprs, root PlanNode) ([]selectTuple
 	aggType := nullAgg
 	tbInfos := root.getReferTables()
 	_, isMergeNode := root.(*MergeNode)
+	_, isDerivedNode := root.(*DerivedNode)
 	for _, expr := range exprs {
 		switch exp := expr.(type) {
 		case *sqlparser.AliasedExpr:
@@ -130,11 +253,15 @@ func parseSelectExprs(exprs sqlparser.SelectExprs, root PlanNode) ([]selectTuple
 			}
 			if hasAgg {
 				hasAggs = true
-				hasDist = hasDist || tuple.distinct
+				// The GROUP_CONCAT cannot be merged from the partial results, same as the distinct.
+				hasDist = hasDist || tuple.distinct || tuple.aggrFuc == "group_concat"
+				for _, dep := range tuple.deps {
+					hasDist = hasDist || dep.distinct || dep.aggrFuc == "group_concat"
+				}
 			}
 			tuples = append(tuples, *tuple)
 		case *sqlparser.StarExpr:
-			if !isMergeNode {
+			if !isMergeNode && !isDerivedNode {
 				return nil, aggType, errors.New("unsupported: '*'.expression.in.cross-shard.query")
 			}
 			tuple := selectTuple{expr: exp, field: "*"}
@@ -155,6 +282,106 @@ func parseSelectExprs(exprs sqlparser.SelectExprs, root PlanNode) ([]selectTuple
 	return tuples, setAggregatorType(hasAggs, hasDist, isMergeNode), nil
 }
 
+// parseHiddenExprs returns the exprs in the HAVING, the ORDER BY and the window functions which are
+// not in the select fields, they're fetched as the hidden fields and removed from the results after used.
+// eg: `select a from t group by a having count(*) > 1 order by sum(b)/count(*)`,
+// the `count(*)` and `sum(b) / count(*)` are hidden.
+// If the having is pushed down, the aggregates in the having are computed by the backends.
+func parseHiddenExprs(node *sqlparser.Select, fields []selectTuple, windows []windowTuple, pushHaving bool) sqlparser.SelectExprs {
+	var hidden sqlparser.SelectExprs
+	addHidden := func(expr sqlparser.Expr) {
+		if _, ok := resolveField(fields, expr); ok {
+			return
+		}
+		for _, h := range hidden {
+			if strings.EqualFold(sqlparser.String(h.(*sqlparser.AliasedExpr).Expr), sqlparser.String(expr)) {
+				return
+			}
+		}
+		hidden = append(hidden, &sqlparser.AliasedExpr{Expr: expr})
+	}
+
+	if node.Having != nil && !pushHaving {
+		sqlparser.Walk(func(node sqlparser.SQLNode) (kontinue bool, err error) {
+			switch node := node.(type) {
+			case *sqlparser.FuncExpr:
+				if isAggregate(node) {
+					addHidden(node)
+					return false, nil
+				}
+			case *sqlparser.GroupConcatExpr:
+				addHidden(node)
+				return false, nil
+			case *sqlparser.Subquery:
+				return false, nil
+			}
+			return true, nil
+		}, node.Having.Expr)
+	}
+
+	for _, order := range node.OrderBy {
+		switch e := order.Expr.(type) {
+		case *sqlparser.ColName:
+			// The column is pushed by the order by plan if it's not in the fields.
+			continue
+		case *sqlparser.SQLVal:
+			// The ordinal or the constant.
+			continue
+		case *sqlparser.NullVal:
+			continue
+		default:
+			addHidden(e)
+		}
+	}
+
+	for _, expr := range windowExprs(windows) {
+		addHidden(expr)
+	}
+	return hidden
+}
+
+// hiddenIdxs returns the indexes of the hidden fields.
+func hiddenIdxs(fields []selectTuple) []int {
+	var idxs []int
+	for i, field := range fields {
+		if field.hidden {
+			idxs = append(idxs, i)
+		}
+	}
+	return idxs
+}
+
+// resolveField returns the index of the expr in the fields. The column is matched by
+// the alias or the column name, the others are matched by the expr, such as: `count(*)`.
+func resolveField(fields []selectTuple, expr sqlparser.Expr) (int, bool) {
+	if col, ok := expr.(*sqlparser.ColName); ok {
+		name := col.Name.String()
+		table := col.Qualifier.Name.String()
+		if table == "" {
+			for i, field := range fields {
+				if strings.EqualFold(field.alias, name) {
+					return i, true
+				}
+			}
+		}
+		for i, field := range fields {
+			if field.isCol && strings.EqualFold(field.field, name) &&
+				(table == "" || len(field.info.referTables) == 0 || table == field.info.referTables[0]) {
+				return i, true
+			}
+		}
+		return -1, false
+	}
+
+	text := sqlparser.String(expr)
+	for i, field := range fields {
+		if !field.isCol && field.info.expr != nil && strings.EqualFold(sqlparser.String(field.info.expr), text) {
+			return i, true
+		}
+	}
+	return -1, false
+}
+
 // aggrType mark aggregate function whether can push down.
 type aggrType int
 
@@ -278,6 +505,109 @@ func decomposeAgg(tuple *selectTuple) *sqlparser.AliasedExpr {
 	}
 }
 
+// decomposeStatistics decomposes the STD/VARIANCE to the sum, the sum of squares and the count.
+// such as: std(a) -> sum(a) as `std(a)`, sum((a) * (a)), count(a).
+func decomposeStatistics(tuple *selectTuple) []*sqlparser.AliasedExpr {
+	alias := tuple.alias
+	if alias == "" {
+		alias = tuple.field
+	}
+	exprs := tuple.expr.(*sqlparser.AliasedExpr).Expr.(*sqlparser.FuncExpr).Exprs
+	arg := exprs[0].(*sqlparser.AliasedExpr).Expr
+	sum := &sqlparser.AliasedExpr{
+		Expr: &sqlparser.FuncExpr{
+			Name:  sqlparser.NewColIdent("sum"),
+			Exprs: exprs,
+		},
+		As: sqlparser.NewColIdent(alias),
+	}
+	square := &sqlparser.AliasedExpr{Expr: &sqlparser.FuncExpr{
+		Name: sqlparser.NewColIdent("sum"),
+		Exprs: sqlparser.SelectExprs{&sqlparser.AliasedExpr{Expr: &sqlparser.BinaryExpr{
+			Operator: sqlparser.MultStr,
+			Left:     &sqlparser.ParenExpr{Expr: arg},
+			Right:    &sqlparser.ParenExpr{Expr: arg},
+		}}},
+	}}
+	count := &sqlparser.AliasedExpr{Expr: &sqlparser.FuncExpr{
+		Name:  sqlparser.NewColIdent("count"),
+		Exprs: exprs,
+	}}
+	return []*sqlparser.AliasedExpr{sum, square, count}
+}
+
+// decomposeGroupConcat decomposes the GROUP_CONCAT to the value and the order by exprs.
+// such as: group_concat(a, b order by c desc separator ';') -> concat(a, b) as `group_concat(...)`, c.
+func decomposeGroupConcat(tuple *selectTuple) (*sqlparser.AliasedExpr, []*sqlparser.AliasedExpr, []AggrOrder, string) {
+	var orders []*sqlparser.AliasedExpr
+	var orderBy []AggrOrder
+	node := tuple.expr.(*sqlparser.AliasedExpr).Expr.(*sqlparser.GroupConcatExpr)
+
+	alias := tuple.alias
+	if alias == "" {
+		alias = tuple.field
+	}
+	value := &sqlparser.AliasedExpr{As: sqlparser.NewColIdent(alias)}
+	if len(node.Exprs) == 1 {
+		value.Expr = node.Exprs[0].(*sqlparser.AliasedExpr).Expr
+	} else {
+		value.Expr = &sqlparser.FuncExpr{
+			Name:  sqlparser.NewColIdent("concat"),
+			Exprs: node.Exprs,
+		}
+	}
+
+	for _, o := range node.OrderBy {
+		expr := o.Expr
+		// The position of the args, such as: group_concat(a order by 1).
+		if val, ok := expr.(*sqlparser.SQLVal); ok && val.Type == sqlparser.IntVal {
+			n, _ := strconv.Atoi(string(val.Val))
+			expr = node.Exprs[n-1].(*sqlparser.AliasedExpr).Expr
+		}
+		orders = append(orders, &sqlparser.AliasedExpr{Expr: expr})
+
+		direction := ASC
+		if o.Direction == sqlparser.DescScr {
+			direction = DESC
+		}
+		orderBy = append(orderBy, AggrOrder{Direction: direction})
+	}
+
+	separator := ","
+	if node.Separator != "" {
+		separator = strings.TrimPrefix(node.Separator, " separator '")
+		separator = strings.TrimSuffix(separator, "'")
+	}
+	return value, orders, orderBy, separator
+}
+
+// decomposeJSONObjectAgg decomposes the JSON_OBJECTAGG to the key and the value.
+// such as: json_objectagg(a, b) -> a as `json_objectagg(a, b)`, b.
+func decomposeJSONObjectAgg(tuple *selectTuple) []*sqlparser.AliasedExpr {
+	alias := tuple.alias
+	if alias == "" {
+		alias = tuple.field
+	}
+	exprs := tuple.expr.(*sqlparser.AliasedExpr).Expr.(*sqlparser.FuncExpr).Exprs
+	key := &sqlparser.AliasedExpr{
+		Expr: exprs[0].(*sqlparser.AliasedExpr).Expr,
+		As:   sqlparser.NewColIdent(alias),
+	}
+	value := &sqlparser.AliasedExpr{Expr: exprs[1].(*sqlparser.AliasedExpr).Expr}
+	return []*sqlparser.AliasedExpr{key, value}
+}
+
+// extraTuple used to build the tuple of the decomposed expr, the expr belongs to the origin aggregate.
+func extraTuple(expr *sqlparser.AliasedExpr, origin *selectTuple) selectTuple {
+	tuple := parseExpr(expr.Expr)
+	tuple.expr = expr
+	// The column may omit the table name if only one table.
+	if len(origin.info.referTables) == 1 {
+		tuple.info.referTables = origin.info.referTables
+	}
+	return tuple
+}
+
 func getSelectExprs(node sqlparser.SelectStatement) sqlparser.SelectExprs {
 	var exprs sqlparser.SelectExprs
 	switch node := node.(type) {
@@ -380,54 +710,30 @@ func checkGroupBy(exprs sqlparser.GroupBy, fields []selectTuple, router *router.
 	return groupTuples, nil
 }
 
-// checkDistinct used to check the distinct, and convert distinct to groupby.
-func checkDistinct(node *sqlparser.Select, groups, fields []selectTuple, router *router.Router, tbInfos map[string]*tableInfo, canOpt bool) ([]selectTuple, error) {
-	// field in grouby must be contained in the select exprs, that mains groups is a subset of fields.
-	// if has groupby, neednot process distinct again.
-	if node.Distinct == "" || len(node.GroupBy) > 0 {
-		return groups, nil
+// checkDistinct used to check whether the rows need to be deduplicated in the proxy.
+// The distinct is always pushed down, so it's unnecessary if:
+// 1. with groupby, the groupby fields are the subset of the select exprs.
+// 2. with aggregates but without groupby, only one row is returned.
+// 3. the fields contain the shardkey, the rows from different backends are unique.
+func checkDistinct(node *sqlparser.Select, fields []selectTuple, aggTyp aggrType, router *router.Router, tbInfos map[string]*tableInfo, canOpt bool) (bool, error) {
+	if node.Distinct == "" || len(node.GroupBy) > 0 || aggTyp != nullAgg {
+		return false, nil
 	}
 
-	// If fields contains shardkey, just push down group by,
-	// neednot process distinct again.
-	hasShard := false
 	if canOpt {
 		for _, tuple := range fields {
 			if tuple.isCol {
 				ok, err := checkShard(tuple.info.referTables[0], tuple.field, tbInfos, router)
 				if err != nil {
-					return nil, err
+					return false, err
 				}
 				if ok {
-					hasShard = true
-					break
+					return false, nil
 				}
 			}
 		}
 	}
-
-	// distinct convert to groupby.
-	for _, tuple := range fields {
-		expr, ok := tuple.expr.(*sqlparser.AliasedExpr)
-		if !ok {
-			return nil, errors.New("unsupported: distinct")
-		}
-		if expr.As.IsEmpty() {
-			if _, ok := expr.Expr.(*sqlparser.ColName); !ok {
-				return nil, errors.New("unsupported: distinct")
-			}
-			node.GroupBy = append(node.GroupBy, expr.Expr)
-		} else {
-			node.GroupBy = append(node.GroupBy, &sqlparser.ColName{
-				Name: expr.As,
-			})
-		}
-	}
-	node.Distinct = ""
-	if hasShard {
-		return nil, nil
-	}
-	return fields, nil
+	return true, nil
 }
 
 // GetProject return the project which is used in explain.
@@ -435,6 +741,9 @@ func GetProject(root PlanNode) string {
 	var prefix, project string
 	tuples := root.getFields()
 	for _, tuple := range tuples {
+		if tuple.hidden {
+			continue
+		}
 		field := tuple.field
 		if tuple.alias != "" {
 			field = tuple.alias
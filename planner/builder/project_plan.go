@@ -0,0 +1,277 @@
+/*
+ * NeoDB
+ *
+ * Copyright 2021-2030 The NeoDB Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package builder
+
+import (
+	"strings"
+
+	"github.com/sealdb/neodb/expression"
+
+	"github.com/sealdb/mysqlstack/sqlparser"
+	"github.com/sealdb/mysqlstack/sqlparser/depends/common"
+	"github.com/sealdb/mysqlstack/xlog"
+)
+
+var (
+	_ ChildPlan = &ProjectPlan{}
+)
+
+// Projection represents a column of the projected row.
+type Projection struct {
+	Field string
+	// Index of the input column, -1 if the column is computed by the Expr.
+	Index int
+	Expr  expression.Evaluator `json:"-"`
+}
+
+// ProjectPlan represents the select exprs computed in the proxy, such as:
+// `sum(a)/count(b)` over the merged aggregates, `t1.a+t2.b` over the joined rows.
+// The computed exprs are replaced by their dependencies in the input, the input
+// columns after the dependencies(such as the order by columns) are kept.
+type ProjectPlan struct {
+	log *xlog.Log
+	// the select tuples to be projected.
+	fields []selectTuple
+	// the tuples fetched as the input.
+	input    []selectTuple
+	Projects []Projection `json:"Project(s)"`
+	typ      ChildType
+}
+
+// NewProjectPlan used to create ProjectPlan.
+func NewProjectPlan(log *xlog.Log, fields []selectTuple) *ProjectPlan {
+	return &ProjectPlan{
+		log:    log,
+		fields: fields,
+		typ:    ChildTypeProject,
+	}
+}
+
+// analyze used to build the input tuples and compile the computed exprs.
+// The input is the non-computed fields in order followed by the dependencies.
+func (p *ProjectPlan) analyze() error {
+	for _, field := range p.fields {
+		if len(field.deps) == 0 {
+			p.input = append(p.input, field)
+		}
+	}
+	for _, field := range p.fields {
+		for _, dep := range field.deps {
+			if _, ok := p.resolve(dep.expr.(*sqlparser.AliasedExpr).Expr); !ok {
+				p.input = append(p.input, dep)
+			}
+		}
+	}
+
+	idx := 0
+	for _, field := range p.fields {
+		name := field.field
+		if field.alias != "" {
+			name = field.alias
+		}
+		if len(field.deps) == 0 {
+			p.Projects = append(p.Projects, Projection{Field: name, Index: idx})
+			idx++
+			continue
+		}
+		eval, err := expression.Compile(field.expr.(*sqlparser.AliasedExpr).Expr, p.resolve)
+		if err != nil {
+			return err
+		}
+		p.Projects = append(p.Projects, Projection{Field: name, Index: -1, Expr: eval})
+	}
+	return nil
+}
+
+// resolve returns the index of the expr in the input.
+func (p *ProjectPlan) resolve(expr sqlparser.Expr) (int, bool) {
+	col, isCol := expr.(*sqlparser.ColName)
+	for i, in := range p.input {
+		if isCol {
+			if in.isCol && strings.EqualFold(in.field, col.Name.String()) {
+				table := col.Qualifier.Name.String()
+				if table == "" || len(in.info.referTables) == 0 || table == in.info.referTables[0] {
+					return i, true
+				}
+			}
+			continue
+		}
+		if aliased, ok := in.expr.(*sqlparser.AliasedExpr); ok && !in.isCol {
+			if sqlparser.String(aliased.Expr) == sqlparser.String(expr) {
+				return i, true
+			}
+		}
+	}
+	return -1, false
+}
+
+// Build used to build distributed querys.
+func (p *ProjectPlan) Build() error {
+	return p.analyze()
+}
+
+// Type returns the type of the plan.
+func (p *ProjectPlan) Type() ChildType {
+	return p.typ
+}
+
+// JSON returns the plan info.
+func (p *ProjectPlan) JSON() string {
+	out, err := common.ToJSONString(p, false, "", "\t")
+	if err != nil {
+		return err.Error()
+	}
+	return out
+}
+
+// InputLen returns the count of the input columns replaced by the projections.
+func (p *ProjectPlan) InputLen() int {
+	return len(p.input)
+}
+
+// Computed returns the fields computed in the proxy.
+func (p *ProjectPlan) Computed() []string {
+	var fields []string
+	for _, project := range p.Projects {
+		if project.Index < 0 {
+			fields = append(fields, project.Field)
+		}
+	}
+	return fields
+}
+
+// buildSelectPlans builds the plans of the select exprs computed in the proxy, in the execution order:
+// the project over the fetched rows, the aggregate, the project over the aggregates.
+// The compute reports whether the tuple must be computed over the fetched rows.
+// Returns the tuples to be fetched, and the projected fields if any project is built.
+func buildSelectPlans(log *xlog.Log, fields, groups []selectTuple, exprs sqlparser.SelectExprs, hasAggr bool,
+	compute func(tuple selectTuple) bool) ([]ChildPlan, []selectTuple, []selectTuple, error) {
+	var children []ChildPlan
+
+	// The exprs over the aggregates are computed after the aggregation.
+	var postProject *ProjectPlan
+	tuples := fields
+	if hasComputed(fields) {
+		postProject = NewProjectPlan(log, fields)
+		if err := postProject.Build(); err != nil {
+			return nil, nil, nil, err
+		}
+		tuples = postProject.input
+		exprs = tupleExprs(tuples)
+	}
+
+	var aggrPlan *AggregatePlan
+	if hasAggr {
+		aggrPlan = NewAggregatePlan(log, exprs, tuples, groups, false)
+		if err := aggrPlan.Build(); err != nil {
+			return nil, nil, nil, err
+		}
+		tuples = aggrPlan.tuples
+	}
+
+	var preProject *ProjectPlan
+	tuples = append([]selectTuple(nil), tuples...)
+	for i := range tuples {
+		if compute(tuples[i]) {
+			tuples[i].deps = columnDeps(tuples[i].expr.(*sqlparser.AliasedExpr).Expr)
+		}
+	}
+	if hasComputed(tuples) {
+		preProject = NewProjectPlan(log, tuples)
+		if err := preProject.Build(); err != nil {
+			return nil, nil, nil, err
+		}
+		children = append(children, preProject)
+		tuples = preProject.input
+	}
+	if aggrPlan != nil {
+		children = append(children, aggrPlan)
+	}
+
+	var projected []selectTuple
+	switch {
+	case postProject != nil:
+		children = append(children, postProject)
+		projected = append(projected, fields...)
+	case preProject != nil:
+		projected = preProject.fields
+	}
+	return children, tuples, projected, nil
+}
+
+// hasComputed returns true if any tuple need be computed in the proxy.
+func hasComputed(tuples []selectTuple) bool {
+	for _, tuple := range tuples {
+		if len(tuple.deps) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// orderByCols returns true if all the order by items are columns.
+func orderByCols(orderBy sqlparser.OrderBy) bool {
+	for _, order := range orderBy {
+		if _, ok := order.Expr.(*sqlparser.ColName); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// orderByComputed returns true if the order by refers to the fields computed in the proxy.
+func orderByComputed(orderBy sqlparser.OrderBy, fields []selectTuple) bool {
+	for _, order := range orderBy {
+		col, ok := order.Expr.(*sqlparser.ColName)
+		if !ok || !col.Qualifier.IsEmpty() {
+			continue
+		}
+		for _, field := range fields {
+			if len(field.deps) > 0 && strings.EqualFold(field.alias, col.Name.String()) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// canCompute returns true if the expr can be evaluated in the proxy by the columns.
+func canCompute(expr sqlparser.Expr) bool {
+	_, err := expression.Compile(expr, func(e sqlparser.Expr) (int, bool) {
+		_, ok := e.(*sqlparser.ColName)
+		return 0, ok
+	})
+	return err == nil
+}
+
+// columnDeps returns the columns the expr depends on.
+func columnDeps(expr sqlparser.Expr) []selectTuple {
+	var deps []selectTuple
+	sqlparser.Walk(func(node sqlparser.SQLNode) (kontinue bool, err error) {
+		if col, ok := node.(*sqlparser.ColName); ok {
+			for _, dep := range deps {
+				if dep.info.cols[0].Equal(col) {
+					return false, nil
+				}
+			}
+			deps = append(deps, parseExpr(col))
+		}
+		return true, nil
+	}, expr)
+	return deps
+}
+
+// tupleExprs returns the select exprs of the tuples.
+func tupleExprs(tuples []selectTuple) sqlparser.SelectExprs {
+	exprs := make(sqlparser.SelectExprs, 0, len(tuples))
+	for _, tuple := range tuples {
+		exprs = append(exprs, tuple.expr)
+	}
+	return exprs
+}
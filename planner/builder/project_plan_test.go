@@ -0,0 +1,179 @@
+/*
+ * NeoDB
+ *
+ * Copyright 2021-2030 The NeoDB Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package builder
+
+import (
+	"testing"
+
+	"github.com/sealdb/neodb/router"
+
+	"github.com/sealdb/mysqlstack/sqlparser"
+	"github.com/sealdb/mysqlstack/xlog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProjectPlan(t *testing.T) {
+	tcases := []struct {
+		query    string
+		out      []string
+		children []ChildType
+		computed [][]string
+	}{
+		// The exprs over the aggregates.
+		{
+			query: "select a, sum(b)/count(c) as x, round(avg(b), 2) from B group by a order by x",
+			out: []string{
+				"backend1: select a, sum(b), count(c), sum(b) as `avg(b)`, count(b) from sbtest.B0 as B group by a",
+				"backend2: select a, sum(b), count(c), sum(b) as `avg(b)`, count(b) from sbtest.B1 as B group by a",
+			},
+			children: []ChildType{ChildTypeAggregate, ChildTypeProject, ChildTypeOrderby},
+			computed: [][]string{nil, {"x", "round(avg(b), 2)"}, nil},
+		},
+		{
+			query: "select max(a)-min(a), count(distinct b)+1 from B",
+			out: []string{
+				"backend1: select distinct a as `max(a)`, a as `min(a)`, b as `count(distinct b)` from sbtest.B0 as B",
+				"backend2: select distinct a as `max(a)`, a as `min(a)`, b as `count(distinct b)` from sbtest.B1 as B",
+			},
+			children: []ChildType{ChildTypeAggregate, ChildTypeProject},
+			computed: [][]string{nil, {"max(a) - min(a)", "count(distinct b) + 1"}},
+		},
+		{
+			query: "select sum(a)/count(b) as x from B having x > 1",
+			out: []string{
+				"backend1: select sum(a), count(b) from sbtest.B0 as B",
+				"backend2: select sum(a), count(b) from sbtest.B1 as B",
+			},
+			children: []ChildType{ChildTypeAggregate, ChildTypeProject, ChildTypeHaving},
+			computed: [][]string{nil, {"x"}, nil},
+		},
+		// The exprs mixing the columns from both sides of the join.
+		{
+			query: "select A.a+B.b as x, case when A.a > B.b then A.a else B.b end from A join B on A.id = B.id where A.id = 1",
+			out: []string{
+				"backend6: select A.a, A.id from sbtest.A6 as A where A.id = 1 order by A.id asc",
+				"backend2: select B.b, B.id from sbtest.B1 as B where B.id = 1 order by B.id asc",
+			},
+			children: []ChildType{ChildTypeProject},
+			computed: [][]string{{"x", "case when A.a > B.b then A.a else B.b end"}},
+		},
+		// The exprs over the null-supplying side of the left join.
+		{
+			query: "select A.a, ifnull(B.b, 0) from A left join B on A.id = B.id where A.id = 1",
+			out: []string{
+				"backend6: select A.a, A.id from sbtest.A6 as A where A.id = 1 order by A.id asc",
+				"backend2: select B.b, B.id from sbtest.B1 as B where B.id = 1 order by B.id asc",
+			},
+			children: []ChildType{ChildTypeProject},
+			computed: [][]string{{"ifnull(B.b, 0)"}},
+		},
+		// The exprs computed both before and after the aggregation.
+		{
+			query: "select A.a, sum(A.b*B.b)/count(*) from A join B on A.id = B.id where A.id = 1 group by A.a",
+			out: []string{
+				"backend6: select A.a, 1 as `count(*)`, A.b, A.id from sbtest.A6 as A where A.id = 1 order by A.id asc",
+				"backend2: select B.b, B.id from sbtest.B1 as B where B.id = 1 order by B.id asc",
+			},
+			children: []ChildType{ChildTypeProject, ChildTypeAggregate, ChildTypeProject},
+			computed: [][]string{{"sum(A.b * B.b)"}, nil, {"sum(A.b * B.b) / count(*)"}},
+		},
+	}
+
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	database := "sbtest"
+
+	route, cleanup := router.MockNewRouter(log)
+	defer cleanup()
+
+	err := route.CreateDatabase(database)
+	assert.Nil(t, err)
+	err = route.AddForTest(database, router.MockTableMConfig(), router.MockTableBConfig())
+	assert.Nil(t, err)
+	for _, tcase := range tcases {
+		node, err := sqlparser.Parse(tcase.query)
+		assert.Nil(t, err)
+		p, err := BuildNode(log, route, database, node.(sqlparser.SelectStatement))
+		assert.Nil(t, err, tcase.query)
+		assert.Equal(t, tcase.out, querysOf(p), tcase.query)
+		var children []ChildType
+		var computed [][]string
+		for _, child := range p.Children() {
+			children = append(children, child.Type())
+			if project, ok := child.(*ProjectPlan); ok {
+				computed = append(computed, project.Computed())
+				continue
+			}
+			computed = append(computed, nil)
+		}
+		assert.Equal(t, tcase.children, children, tcase.query)
+		assert.Equal(t, tcase.computed, computed, tcase.query)
+	}
+}
+
+func TestProjectPlanUnsupported(t *testing.T) {
+	querys := []string{
+		"select sum(count(a)) from B",
+	}
+	wants := []string{
+		"unsupported: invalid.use.of.group.function[sum]",
+	}
+
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	database := "sbtest"
+
+	route, cleanup := router.MockNewRouter(log)
+	defer cleanup()
+
+	err := route.CreateDatabase(database)
+	assert.Nil(t, err)
+	err = route.AddForTest(database, router.MockTableBConfig())
+	assert.Nil(t, err)
+	for i, query := range querys {
+		node, err := sqlparser.Parse(query)
+		assert.Nil(t, err)
+		_, err = BuildNode(log, route, database, node.(sqlparser.SelectStatement))
+		assert.NotNil(t, err, query)
+		if err != nil {
+			assert.Equal(t, wants[i], err.Error(), query)
+		}
+	}
+}
+
+func TestProjectPlanJSON(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	node, err := sqlparser.Parse("select a, a+b as c from t")
+	assert.Nil(t, err)
+	tbInfos := map[string]*tableInfo{"t": {}}
+	var fields []selectTuple
+	for _, expr := range node.(*sqlparser.Select).SelectExprs {
+		tuple, _, err := parseSelectExpr(expr.(*sqlparser.AliasedExpr), tbInfos)
+		assert.Nil(t, err)
+		fields = append(fields, *tuple)
+	}
+	fields[1].deps = columnDeps(fields[1].info.expr)
+
+	plan := NewProjectPlan(log, fields)
+	assert.Nil(t, plan.Build())
+	assert.Equal(t, ChildTypeProject, plan.Type())
+	assert.Equal(t, 2, plan.InputLen())
+	assert.Equal(t, []string{"c"}, plan.Computed())
+	want := `{
+	"Project(s)": [
+		{
+			"Field": "a",
+			"Index": 0
+		},
+		{
+			"Field": "c",
+			"Index": -1
+		}
+	]
+}`
+	assert.Equal(t, want, plan.JSON())
+}
@@ -165,50 +165,19 @@ func TestCheckDistinct(t *testing.T) {
 		"select distinct A.a,A.b as c from A",
 		"select distinct A.id from A",
 		"select distinct A.a,A.b,A.c from A group by a",
-	}
-	wants := []int{
-		2,
-		1,
-		1,
-	}
-
-	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
-	database := "sbtest"
-
-	route, cleanup := router.MockNewRouter(log)
-	defer cleanup()
-
-	err := route.CreateDatabase(database)
-	assert.Nil(t, err)
-	err = route.AddForTest(database, router.MockTableMConfig(), router.MockTableBConfig(), router.MockTableGConfig())
-	assert.Nil(t, err)
-
-	for i, query := range querys {
-		node, err := sqlparser.Parse(query)
-		assert.Nil(t, err)
-		sel := node.(*sqlparser.Select)
-
-		p, err := scanTableExprs(log, route, database, sel.From)
-		assert.Nil(t, err)
-
-		fields, _, err := parseSelectExprs(sel.SelectExprs, p)
-		assert.Nil(t, err)
-
-		_, ok := p.(*MergeNode)
-		_, err = checkDistinct(sel, nil, fields, route, p.getReferTables(), ok)
-		assert.Nil(t, err)
-		assert.Equal(t, wants[i], len(sel.GroupBy))
-	}
-}
-
-func TestCheckDistinctError(t *testing.T) {
-	querys := []string{
 		"select distinct * from A",
 		"select distinct A.a+1 as a, A.b*10 from A",
+		"select distinct count(A.a) from A",
+		"select A.a from A",
 	}
-	wants := []string{
-		"unsupported: distinct",
-		"unsupported: distinct",
+	wants := []bool{
+		true,
+		false,
+		false,
+		true,
+		true,
+		false,
+		false,
 	}
 
 	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
@@ -230,13 +199,13 @@ func TestCheckDistinctError(t *testing.T) {
 		p, err := scanTableExprs(log, route, database, sel.From)
 		assert.Nil(t, err)
 
-		fields, _, err := parseSelectExprs(sel.SelectExprs, p)
+		fields, aggTyp, err := parseSelectExprs(sel.SelectExprs, p)
 		assert.Nil(t, err)
 
 		_, ok := p.(*MergeNode)
-		_, err = checkDistinct(sel, nil, fields, route, p.getReferTables(), ok)
-		got := err.Error()
-		assert.Equal(t, wants[i], got)
+		dedup, err := checkDistinct(sel, fields, aggTyp, route, p.getReferTables(), ok)
+		assert.Nil(t, err)
+		assert.Equal(t, wants[i], dedup, query)
 	}
 }
 
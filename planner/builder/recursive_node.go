@@ -0,0 +1,229 @@
+/*
+ * NeoDB
+ *
+ * Copyright 2021-2030 The NeoDB Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package builder
+
+import (
+	"strings"
+
+	"github.com/sealdb/neodb/router"
+	"github.com/sealdb/neodb/xcontext"
+
+	"github.com/pkg/errors"
+	"github.com/sealdb/mysqlstack/sqlparser"
+	"github.com/sealdb/mysqlstack/sqlparser/depends/sqltypes"
+	"github.com/sealdb/mysqlstack/xlog"
+)
+
+// RecursiveNode represents the recursive common table expression.
+// eg: with recursive t(n) as (select 1 union all select n+1 from t where n < 5) select * from t;
+// The anchor `select 1` is executed first, then the recursive member is executed on the rows
+// produced by the last iteration, which are inlined as the derived table `t`, until no more
+// rows are produced. The recursive member is planned on each iteration, so it can be pushed
+// down with the other tables or joined in the proxy.
+type RecursiveNode struct {
+	log      *xlog.Log
+	router   *router.Router
+	database string
+	// Anchor is the plan of the anchor members.
+	Anchor PlanNode
+	// Name of the recursive cte.
+	Name string
+	// Union Type between the anchor and the recursive member.
+	Typ string
+	// recursive is the recursive member's query.
+	recursive string
+	children  []ChildPlan
+	// referred tables' tableInfo map.
+	referTables map[string]*tableInfo
+}
+
+// processRecursive used to process the union of the recursive cte, the node.Right is the recursive member.
+func processRecursive(log *xlog.Log, router *router.Router, database string, node *sqlparser.Union, name string) (PlanNode, error) {
+	anchor, err := processPart(log, router, database, node.Left)
+	if err != nil {
+		return nil, err
+	}
+	member := node.Right
+	if paren, ok := member.(*sqlparser.ParenSelect); ok {
+		member = paren.Select
+	}
+	sel, ok := member.(*sqlparser.Select)
+	if !ok {
+		return nil, errors.Errorf("unsupported: recursive.cte.'%s'.must.have.one.recursive.member.referring.to.it.once", name)
+	}
+
+	copied := *sel
+	copied.Comments = nil
+	return &RecursiveNode{
+		log:       log,
+		router:    router,
+		database:  database,
+		Anchor:    anchor,
+		Name:      name,
+		Typ:       node.Type,
+		recursive: sqlparser.String(&copied),
+	}, nil
+}
+
+// Iterate builds the plan of the recursive member on the rows of the last iteration.
+func (r *RecursiveNode) Iterate(rows *sqltypes.Result) (PlanNode, error) {
+	stmt, err := sqlparser.Parse(r.recursive)
+	if err != nil {
+		return nil, err
+	}
+	sel := stmt.(*sqlparser.Select)
+	_ = sqlparser.Walk(func(node sqlparser.SQLNode) (bool, error) {
+		expr, ok := node.(*sqlparser.AliasedTableExpr)
+		if !ok {
+			return true, nil
+		}
+		if table, ok := expr.Expr.(sqlparser.TableName); ok && table.Qualifier.IsEmpty() && strings.EqualFold(table.Name.String(), r.Name) {
+			expr.Expr = &sqlparser.Subquery{Select: rowsTable(rows)}
+			if expr.As.IsEmpty() {
+				expr.As = table.Name
+			}
+			return false, nil
+		}
+		return true, nil
+	}, sel)
+
+	root, err := processSelect(r.log, r.router, r.database, sel)
+	if err != nil {
+		return nil, err
+	}
+	root.buildQuery(root)
+	return root, nil
+}
+
+// rowsTable returns the query selects the rows from dual, the columns are named by the fields.
+// eg: select 1 as a, 'x' as b from dual union all select 2, 'y' from dual
+func rowsTable(rows *sqltypes.Result) sqlparser.SelectStatement {
+	var stmt sqlparser.SelectStatement
+	for i, row := range rows.Rows {
+		sel := &sqlparser.Select{
+			From: sqlparser.TableExprs{&sqlparser.AliasedTableExpr{Expr: sqlparser.TableName{Name: sqlparser.NewTableIdent("dual")}}},
+		}
+		for j, v := range row {
+			expr := &sqlparser.AliasedExpr{Expr: &sqlparser.NullVal{}}
+			if !v.IsNull() {
+				expr.Expr = router.SQLValFromValue(v)
+			}
+			if i == 0 {
+				expr.As = sqlparser.NewColIdent(rows.Fields[j].Name)
+			}
+			sel.SelectExprs = append(sel.SelectExprs, expr)
+		}
+		if stmt == nil {
+			stmt = sel
+			continue
+		}
+		stmt = &sqlparser.Union{Type: sqlparser.UnionAllStr, Left: stmt, Right: sel}
+	}
+	return stmt
+}
+
+// buildQuery used to build the QueryTuple of the anchor.
+func (r *RecursiveNode) buildQuery(root PlanNode) {
+	r.Anchor.buildQuery(r.Anchor)
+}
+
+// Children returns the children of the plan.
+func (r *RecursiveNode) Children() []ChildPlan {
+	return r.children
+}
+
+// getReferTables get the referTables.
+func (r *RecursiveNode) getReferTables() map[string]*tableInfo {
+	return r.referTables
+}
+
+// GetQuery used to get the Querys of the anchor.
+func (r *RecursiveNode) GetQuery() []xcontext.QueryTuple {
+	return r.Anchor.GetQuery()
+}
+
+func (r *RecursiveNode) getFields() []selectTuple {
+	return r.Anchor.getFields()
+}
+
+// addNoTableFilter used to push the no table filters to the anchor,
+// no rows are produced if the anchor is filtered out.
+func (r *RecursiveNode) addNoTableFilter(exprs []sqlparser.Expr) {
+	r.Anchor.addNoTableFilter(exprs)
+}
+
+// Temporarily unreachable.
+func (r *RecursiveNode) pushOrderBy(orderBy sqlparser.OrderBy) error {
+	panic("unreachable")
+}
+
+// Temporarily unreachable.
+func (r *RecursiveNode) pushLimit(limit *sqlparser.Limit) error {
+	panic("unreachable")
+}
+
+// Temporarily unreachable.
+func (r *RecursiveNode) calcRoute() (PlanNode, error) {
+	panic("unreachable")
+}
+
+// Temporarily unreachable.
+func (r *RecursiveNode) pushFilter(filter exprInfo) error {
+	panic("unreachable")
+}
+
+// Temporarily unreachable.
+func (r *RecursiveNode) pushKeyFilter(filter exprInfo, table, field string) error {
+	panic("unreachable")
+}
+
+// Temporarily unreachable.
+func (r *RecursiveNode) pushSelectExpr(field selectTuple) (int, error) {
+	panic("unreachable")
+}
+
+// Temporarily unreachable.
+func (r *RecursiveNode) pushHaving(having exprInfo) error {
+	panic("unreachable")
+}
+
+// Temporarily unreachable.
+func (r *RecursiveNode) pushWindows(windows []windowTuple) error {
+	panic("unreachable")
+}
+
+// Temporarily unreachable.
+func (r *RecursiveNode) pushDistinct() error {
+	panic("unreachable")
+}
+
+// Temporarily unreachable.
+func (r *RecursiveNode) pushMisc(sel *sqlparser.Select) {
+	panic("unreachable")
+}
+
+// unreachable.
+func (r *RecursiveNode) pushSelectExprs(fields, groups []selectTuple, sel *sqlparser.Select, aggTyp aggrType) error {
+	panic("unreachable")
+}
+
+// unreachable.
+func (r *RecursiveNode) setParent(p *JoinNode) {
+	panic("unreachable")
+}
+
+// unreachable.
+func (r *RecursiveNode) reOrder(int) {
+	panic("unreachable")
+}
+
+// Order unreachable.
+func (r *RecursiveNode) Order() int {
+	panic("unreachable")
+}
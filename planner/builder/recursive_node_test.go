@@ -0,0 +1,97 @@
+/*
+ * NeoDB
+ *
+ * Copyright 2021-2030 The NeoDB Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package builder
+
+import (
+	"testing"
+
+	"github.com/sealdb/neodb/router"
+	"github.com/sealdb/neodb/xparser"
+
+	"github.com/sealdb/mysqlstack/sqlparser"
+	querypb "github.com/sealdb/mysqlstack/sqlparser/depends/query"
+	"github.com/sealdb/mysqlstack/sqlparser/depends/sqltypes"
+	"github.com/sealdb/mysqlstack/xlog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecursiveNode(t *testing.T) {
+	tcases := []struct {
+		query  string
+		typ    string
+		anchor []string
+		rows   *sqltypes.Result
+		iter   []string
+	}{
+		{
+			query: "with recursive t(n) as (select 1 union all select n + 1 from t where n < 5) select * from t where n > 2",
+			typ:   sqlparser.UnionAllStr,
+			anchor: []string{
+				": select 1 as n from dual",
+			},
+			rows: &sqltypes.Result{
+				Fields: []*querypb.Field{{Name: "n", Type: querypb.Type_INT64}},
+				Rows: [][]sqltypes.Value{
+					{sqltypes.NewInt64(1)},
+					{sqltypes.NewInt64(2)},
+				},
+			},
+			iter: []string{
+				": select n + 1 from (select 1 as n from dual union all select 2 from dual) as t where n < 5",
+			},
+		},
+		// The recursive member joins the shard table.
+		{
+			query: "with recursive t as (select id, 0 as lvl from B where id = 1 union select B.id, t.lvl + 1 from B join t on B.a = t.id) select id, lvl from t",
+			typ:   sqlparser.UnionStr,
+			anchor: []string{
+				"backend2: select id, 0 as lvl from sbtest.B1 as B where id = 1",
+			},
+			rows: &sqltypes.Result{
+				Fields: []*querypb.Field{{Name: "id", Type: querypb.Type_INT64}, {Name: "lvl", Type: querypb.Type_VARCHAR}},
+				Rows: [][]sqltypes.Value{
+					{sqltypes.NewInt64(1), sqltypes.NewVarChar("x")},
+					{sqltypes.NewInt64(3), sqltypes.NULL},
+				},
+			},
+			iter: []string{
+				"backend1: select B.id, t.lvl + 1 from sbtest.B0 as B join (select 1 as id, 'x' as lvl from dual union all select 3, null from dual) as t on B.a = t.id",
+				"backend2: select B.id, t.lvl + 1 from sbtest.B1 as B join (select 1 as id, 'x' as lvl from dual union all select 3, null from dual) as t on B.a = t.id",
+			},
+		},
+	}
+
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	database := "sbtest"
+
+	route, cleanup := router.MockNewRouter(log)
+	defer cleanup()
+
+	err := route.CreateDatabase(database)
+	assert.Nil(t, err)
+	err = route.AddForTest(database, router.MockTableMConfig(), router.MockTableBConfig(), router.MockTableGConfig())
+	assert.Nil(t, err)
+	for _, tcase := range tcases {
+		node, err := xparser.Parse(tcase.query)
+		assert.Nil(t, err)
+		p, err := BuildNode(log, route, database, node.(sqlparser.SelectStatement))
+		assert.Nil(t, err, tcase.query)
+		d, ok := p.(*DerivedNode)
+		assert.True(t, ok, tcase.query)
+		r, ok := d.Inner.(*RecursiveNode)
+		assert.True(t, ok, tcase.query)
+		assert.Equal(t, "t", r.Name)
+		assert.Equal(t, tcase.typ, r.Typ, tcase.query)
+		assert.Equal(t, tcase.anchor, querysOf(p), tcase.query)
+
+		iter, err := r.Iterate(tcase.rows)
+		assert.Nil(t, err, tcase.query)
+		assert.Equal(t, tcase.iter, querysOf(iter), tcase.query)
+	}
+}
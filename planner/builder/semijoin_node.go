@@ -0,0 +1,255 @@
+/*
+ * NeoDB
+ *
+ * Copyright 2021-2030 The NeoDB Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package builder
+
+import (
+	"github.com/sealdb/neodb/router"
+	"github.com/sealdb/neodb/xcontext"
+
+	"github.com/pkg/errors"
+	"github.com/sealdb/mysqlstack/sqlparser"
+	"github.com/sealdb/mysqlstack/sqlparser/depends/sqltypes"
+	"github.com/sealdb/mysqlstack/xlog"
+)
+
+// Subquery is the uncorrelated IN/EXISTS subquery executed before the outer query.
+type Subquery struct {
+	// Name is the placeholder of the subquery in the outer query.
+	Name string
+	Type SubqueryType
+	// Node is the plan of the subquery.
+	Node PlanNode
+}
+
+// SemiJoinNode represents the select with the IN/EXISTS subqueries which can't be pushed down.
+// eg: select * from t1 where a in (select b from t2) and not exists (select 1 from t3);
+// The subqueries are executed first, their results are bound to the outer query as the IN lists
+// and the EXISTS flags, then the outer query is planned again, the IN lists prune the shards.
+// The NOT IN and NOT EXISTS are the anti-joins.
+type SemiJoinNode struct {
+	log      *xlog.Log
+	router   *router.Router
+	database string
+	// Outer is the plan of the outer query with the placeholders, used to explain.
+	Outer PlanNode
+	// Subqueries are executed before the outer query.
+	Subqueries []*Subquery
+	// query is the outer query before the subqueries are extracted.
+	query string
+}
+
+func newSemiJoinNode(log *xlog.Log, router *router.Router, database string, outer PlanNode, query string) *SemiJoinNode {
+	return &SemiJoinNode{
+		log:      log,
+		router:   router,
+		database: database,
+		Outer:    outer,
+		query:    query,
+	}
+}
+
+// Rebind used to build the plan of the outer query with the results of the subqueries.
+// The results are in the same order as the Subqueries.
+func (s *SemiJoinNode) Rebind(results []*sqltypes.Result) (PlanNode, error) {
+	stmt, err := sqlparser.Parse(s.query)
+	if err != nil {
+		return nil, err
+	}
+	sel, ok := stmt.(*sqlparser.Select)
+	if !ok {
+		return nil, errors.Errorf("unsupported: semi.join.outer.query[%s]", s.query)
+	}
+
+	indexes := make(map[string]int, len(s.Subqueries))
+	for i, sub := range s.Subqueries {
+		if i >= len(results) {
+			return nil, errors.Errorf("semi.join.subquery[%s].result.missing", sub.Name)
+		}
+		if (sub.Type == SubqueryIn || sub.Type == SubqueryNotIn) && len(results[i].Fields) > 1 {
+			return nil, errors.New("Operand should contain 1 column(s)")
+		}
+		indexes[sub.Name] = i
+	}
+
+	rewriteSubqueries(sel.Where, func(sq *subqueryExpr) sqlparser.Expr {
+		i, ok := indexes[sq.name]
+		if !ok {
+			// Pushed down.
+			return sq.expr
+		}
+		qr := results[i]
+		switch sq.typ {
+		case SubqueryIn, SubqueryNotIn:
+			vals := valTuple(qr)
+			if len(vals) == 0 {
+				// `expr IN ()` is false, `expr NOT IN ()` is true.
+				right := "0"
+				if sq.typ == SubqueryNotIn {
+					right = "1"
+				}
+				return &sqlparser.ComparisonExpr{
+					Operator: sqlparser.EqualStr,
+					Left:     sqlparser.NewIntVal([]byte("1")),
+					Right:    sqlparser.NewIntVal([]byte(right)),
+				}
+			}
+			return &sqlparser.ComparisonExpr{
+				Operator: sq.expr.(*sqlparser.ComparisonExpr).Operator,
+				Left:     sq.expr.(*sqlparser.ComparisonExpr).Left,
+				Right:    vals,
+			}
+		}
+		if len(qr.Rows) > 0 {
+			return sqlparser.NewIntVal([]byte("1"))
+		}
+		return sqlparser.NewIntVal([]byte("0"))
+	})
+	return BuildNode(s.log, s.router, s.database, sel)
+}
+
+// valTuple returns the distinct values of the first column.
+func valTuple(qr *sqltypes.Result) sqlparser.ValTuple {
+	var vals sqlparser.ValTuple
+	hasNull := false
+	seen := make(map[string]struct{}, len(qr.Rows))
+	for _, row := range qr.Rows {
+		if len(row) == 0 {
+			continue
+		}
+		if row[0].IsNull() {
+			if !hasNull {
+				hasNull = true
+				vals = append(vals, &sqlparser.NullVal{})
+			}
+			continue
+		}
+		key := string(row[0].Raw())
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		seen[key] = struct{}{}
+		vals = append(vals, router.SQLValFromValue(row[0]))
+	}
+	return vals
+}
+
+// buildQuery used to build the QueryTuple.
+func (s *SemiJoinNode) buildQuery(root PlanNode) {
+	s.Outer.buildQuery(s.Outer)
+}
+
+// Children returns the children of the outer plan.
+func (s *SemiJoinNode) Children() []ChildPlan {
+	return s.Outer.Children()
+}
+
+// getReferTables get the referTables of the outer plan.
+func (s *SemiJoinNode) getReferTables() map[string]*tableInfo {
+	return s.Outer.getReferTables()
+}
+
+// GetQuery used to get the Querys of the subqueries and the outer plan.
+func (s *SemiJoinNode) GetQuery() []xcontext.QueryTuple {
+	var querys []xcontext.QueryTuple
+	for _, sub := range s.Subqueries {
+		querys = append(querys, sub.Node.GetQuery()...)
+	}
+	return append(querys, s.Outer.GetQuery()...)
+}
+
+// getFields get the fields of the outer plan.
+func (s *SemiJoinNode) getFields() []selectTuple {
+	return s.Outer.getFields()
+}
+
+// unreachable.
+func (s *SemiJoinNode) calcRoute() (PlanNode, error) {
+	panic("unreachable")
+}
+
+// unreachable.
+func (s *SemiJoinNode) pushFilter(filter exprInfo) error {
+	panic("unreachable")
+}
+
+// unreachable.
+func (s *SemiJoinNode) pushKeyFilter(filter exprInfo, table, field string) error {
+	panic("unreachable")
+}
+
+// unreachable.
+func (s *SemiJoinNode) pushSelectExprs(fields, groups []selectTuple, sel *sqlparser.Select, aggTyp aggrType) error {
+	panic("unreachable")
+}
+
+// unreachable.
+func (s *SemiJoinNode) pushSelectExpr(field selectTuple) (int, error) {
+	panic("unreachable")
+}
+
+// unreachable.
+func (s *SemiJoinNode) pushHaving(having exprInfo) error {
+	panic("unreachable")
+}
+
+// unreachable.
+func (s *SemiJoinNode) pushWindows(windows []windowTuple) error {
+	panic("unreachable")
+}
+
+// unreachable.
+func (s *SemiJoinNode) pushOrderBy(orderBy sqlparser.OrderBy) error {
+	panic("unreachable")
+}
+
+// unreachable.
+func (s *SemiJoinNode) pushDistinct() error {
+	panic("unreachable")
+}
+
+// unreachable.
+func (s *SemiJoinNode) pushLimit(limit *sqlparser.Limit) error {
+	panic("unreachable")
+}
+
+// unreachable.
+func (s *SemiJoinNode) pushMisc(sel *sqlparser.Select) {
+	panic("unreachable")
+}
+
+// addNoTableFilter used to push the no table filters to the outer query.
+func (s *SemiJoinNode) addNoTableFilter(exprs []sqlparser.Expr) {
+	if len(exprs) == 0 {
+		return
+	}
+	s.Outer.addNoTableFilter(exprs)
+	// The outer query is planned again by Rebind.
+	if stmt, err := sqlparser.Parse(s.query); err == nil {
+		sel := stmt.(*sqlparser.Select)
+		for _, expr := range exprs {
+			sel.AddWhere(expr)
+		}
+		s.query = sqlparser.String(sel)
+	}
+}
+
+// unreachable.
+func (s *SemiJoinNode) setParent(p *JoinNode) {
+	panic("unreachable")
+}
+
+// unreachable.
+func (s *SemiJoinNode) reOrder(int) {
+	panic("unreachable")
+}
+
+// Order unreachable.
+func (s *SemiJoinNode) Order() int {
+	panic("unreachable")
+}
@@ -0,0 +1,87 @@
+/*
+ * NeoDB
+ *
+ * Copyright 2021-2030 The NeoDB Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package builder
+
+import (
+	"testing"
+
+	"github.com/sealdb/neodb/router"
+
+	"github.com/sealdb/mysqlstack/sqlparser"
+	querypb "github.com/sealdb/mysqlstack/sqlparser/depends/query"
+	"github.com/sealdb/mysqlstack/sqlparser/depends/sqltypes"
+	"github.com/sealdb/mysqlstack/xlog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSemiJoinNodeRebind(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	database := "sbtest"
+
+	route, cleanup := router.MockNewRouter(log)
+	defer cleanup()
+
+	err := route.CreateDatabase(database)
+	assert.Nil(t, err)
+	err = route.AddForTest(database, router.MockTableMConfig(), router.MockTableBConfig(), router.MockTableCConfig())
+	assert.Nil(t, err)
+
+	query := "select * from A where id in (select b from C) and not exists (select 1 from B where id = 1) and a not in (select b from C)"
+	node, err := sqlparser.Parse(query)
+	assert.Nil(t, err)
+	p, err := BuildNode(log, route, database, node.(sqlparser.SelectStatement))
+	assert.Nil(t, err)
+	semi := p.(*SemiJoinNode)
+	assert.Equal(t, 3, len(semi.Subqueries))
+
+	fields := []*querypb.Field{{Name: "b", Type: querypb.Type_INT64}}
+	// The values are distinct, NULL doesn't route.
+	{
+		results := []*sqltypes.Result{
+			{
+				Fields: fields,
+				Rows: [][]sqltypes.Value{
+					{sqltypes.NewInt64(1)},
+					{sqltypes.NULL},
+					{sqltypes.NewInt64(1)},
+				},
+			},
+			{},
+			{
+				Fields: fields,
+				Rows: [][]sqltypes.Value{
+					{sqltypes.NewVarChar("x'y")},
+				},
+			},
+		}
+		outer, err := semi.Rebind(results)
+		assert.Nil(t, err)
+		assert.Equal(t, []string{
+			"backend6: select * from sbtest.A6 as A where id in (1, null) and not 0 and a not in ('x\\'y')",
+		}, querysOf(outer))
+	}
+
+	// The empty results.
+	{
+		results := []*sqltypes.Result{{Fields: fields}, {Rows: [][]sqltypes.Value{{sqltypes.NewInt64(1)}}}, {Fields: fields}}
+		outer, err := semi.Rebind(results)
+		assert.Nil(t, err)
+		assert.Equal(t, "backend1: select * from sbtest.A1 as A where 1 = 0 and not 1 and 1 = 1", querysOf(outer)[0])
+	}
+
+	// Errors.
+	{
+		_, err := semi.Rebind(nil)
+		assert.EqualError(t, err, "semi.join.subquery[__sq1].result.missing")
+
+		results := []*sqltypes.Result{{Fields: append(fields, fields...)}, {}, {}}
+		_, err = semi.Rebind(results)
+		assert.EqualError(t, err, "Operand should contain 1 column(s)")
+	}
+}
@@ -0,0 +1,289 @@
+/*
+ * NeoDB
+ *
+ * Copyright 2021-2030 The NeoDB Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package builder
+
+import (
+	"fmt"
+
+	"github.com/sealdb/neodb/router"
+
+	"github.com/pkg/errors"
+	"github.com/sealdb/mysqlstack/sqlparser"
+	"github.com/sealdb/mysqlstack/xlog"
+)
+
+// SubqueryType is the type of the subquery in the WHERE clause.
+type SubqueryType string
+
+const (
+	// SubqueryIn is the `expr IN (subquery)`.
+	SubqueryIn SubqueryType = "IN"
+	// SubqueryNotIn is the `expr NOT IN (subquery)`.
+	SubqueryNotIn SubqueryType = "NOT IN"
+	// SubqueryExists is the `EXISTS (subquery)`.
+	SubqueryExists SubqueryType = "EXISTS"
+	// SubqueryNotExists is the `NOT EXISTS (subquery)`.
+	SubqueryNotExists SubqueryType = "NOT EXISTS"
+)
+
+// subqueryExpr is the IN/EXISTS subquery in the WHERE clause.
+type subqueryExpr struct {
+	// name of the placeholder which replaces the subquery.
+	name string
+	typ  SubqueryType
+	// expr is the IN comparison or the EXISTS expr.
+	expr sqlparser.Expr
+	sel  sqlparser.SelectStatement
+}
+
+// hasSubquery returns true if the node contains subquery.
+func hasSubquery(node sqlparser.SQLNode) bool {
+	has := false
+	_ = sqlparser.Walk(func(node sqlparser.SQLNode) (kontinue bool, err error) {
+		if _, ok := node.(*sqlparser.Subquery); ok {
+			has = true
+			return false, nil
+		}
+		return !has, nil
+	}, node)
+	return has
+}
+
+// rewriteSubqueries calls the fn on the IN/EXISTS subqueries of the where in order,
+// and replaces the IN comparison or the EXISTS expr with the returned expr.
+// The other subqueries are left as is.
+func rewriteSubqueries(where *sqlparser.Where, fn func(sq *subqueryExpr) sqlparser.Expr) {
+	if where == nil {
+		return
+	}
+	cnt := 0
+	where.Expr = sqlparser.Rewrite(where.Expr, func(cursor *sqlparser.Cursor) bool {
+		var sq *subqueryExpr
+		switch node := cursor.Node().(type) {
+		case *sqlparser.ComparisonExpr:
+			sub, ok := node.Right.(*sqlparser.Subquery)
+			if !ok || (node.Operator != sqlparser.InStr && node.Operator != sqlparser.NotInStr) {
+				return true
+			}
+			sq = &subqueryExpr{typ: SubqueryIn, expr: node, sel: sub.Select}
+			if node.Operator == sqlparser.NotInStr {
+				sq.typ = SubqueryNotIn
+			}
+		case *sqlparser.ExistsExpr:
+			sq = &subqueryExpr{typ: SubqueryExists, expr: node, sel: node.Subquery.Select}
+			if _, ok := cursor.Parent().(*sqlparser.NotExpr); ok {
+				sq.typ = SubqueryNotExists
+			}
+		case *sqlparser.Subquery:
+			return false
+		default:
+			return true
+		}
+		cnt++
+		sq.name = fmt.Sprintf("__sq%d", cnt)
+		cursor.Replace(fn(sq))
+		return false
+	}, nil).(sqlparser.Expr)
+}
+
+// extractSubqueries replaces the IN/EXISTS subqueries of the where with the placeholders,
+// `expr IN ::__sqN` for the IN and `:__sqN` for the EXISTS.
+func extractSubqueries(where *sqlparser.Where) []*subqueryExpr {
+	var subs []*subqueryExpr
+	rewriteSubqueries(where, func(sq *subqueryExpr) sqlparser.Expr {
+		subs = append(subs, sq)
+		if cmp, ok := sq.expr.(*sqlparser.ComparisonExpr); ok {
+			return &sqlparser.ComparisonExpr{
+				Operator: cmp.Operator,
+				Left:     cmp.Left,
+				Right:    sqlparser.ListArg("::" + sq.name),
+			}
+		}
+		return sqlparser.NewValArg([]byte(":" + sq.name))
+	})
+	return subs
+}
+
+// checkCorrelated used to check whether the subquery refers to the tables of the outer query.
+func checkCorrelated(sel sqlparser.SelectStatement, tbInfos map[string]*tableInfo) error {
+	inner := make(map[string]struct{})
+	_ = sqlparser.Walk(func(node sqlparser.SQLNode) (kontinue bool, err error) {
+		if expr, ok := node.(*sqlparser.AliasedTableExpr); ok {
+			if !expr.As.IsEmpty() {
+				inner[expr.As.String()] = struct{}{}
+			} else if tb, ok := expr.Expr.(sqlparser.TableName); ok {
+				inner[tb.Name.String()] = struct{}{}
+			}
+		}
+		return true, nil
+	}, sel)
+
+	return sqlparser.Walk(func(node sqlparser.SQLNode) (kontinue bool, err error) {
+		if col, ok := node.(*sqlparser.ColName); ok {
+			tableName := col.Qualifier.Name.String()
+			if _, ok := inner[tableName]; ok || tableName == "" {
+				return true, nil
+			}
+			if _, ok := tbInfos[tableName]; ok {
+				return false, errors.Errorf("unsupported: correlated.subquery.column.'%s'", sqlparser.String(col))
+			}
+		}
+		return true, nil
+	}, sel)
+}
+
+// processSubqueries used to build the IN/EXISTS subqueries of the select.
+// The subquery is pushed down with the outer query if they can be executed on the same backends:
+// the subquery only refers to global tables, both route to the same backend, or the IN subquery
+// selects the shard key of a table co-located with the outer table compared by its shard key.
+// Otherwise, the subquery is executed first by the SemiJoinNode.
+func processSubqueries(log *xlog.Log, router *router.Router, database string, root PlanNode, query string, subs []*subqueryExpr) (PlanNode, error) {
+	tbInfos := root.getReferTables()
+	semi := newSemiJoinNode(log, router, database, root, query)
+	for _, sq := range subs {
+		if cmp, ok := sq.expr.(*sqlparser.ComparisonExpr); ok {
+			if _, ok := cmp.Left.(sqlparser.ValTuple); ok {
+				return nil, errors.New("unsupported: tuple.in.subquery")
+			}
+		}
+		if err := checkCorrelated(sq.sel, tbInfos); err != nil {
+			return nil, err
+		}
+		if sq.typ == SubqueryExists || sq.typ == SubqueryNotExists {
+			// One row is enough to check the existence.
+			if sel, ok := sq.sel.(*sqlparser.Select); ok && sel.Limit == nil {
+				sel.Limit = &sqlparser.Limit{Rowcount: sqlparser.NewIntVal([]byte("1"))}
+			}
+		}
+
+		node, err := BuildNode(log, router, database, sq.sel)
+		if err != nil {
+			return nil, err
+		}
+		if pushSubquery(root, node, sq) {
+			continue
+		}
+		semi.Subqueries = append(semi.Subqueries, &Subquery{
+			Name: sq.name,
+			Type: sq.typ,
+			Node: node,
+		})
+	}
+
+	if len(semi.Subqueries) == 0 {
+		return root, nil
+	}
+	return semi, nil
+}
+
+// pushSubquery try to push the subquery down to the outer MergeNode,
+// the placeholder is restored to the subquery if success.
+func pushSubquery(root, node PlanNode, sq *subqueryExpr) bool {
+	m, ok := root.(*MergeNode)
+	if !ok {
+		return false
+	}
+	in, ok := node.(*MergeNode)
+	if !ok {
+		return false
+	}
+
+	nested := hasSubquery(in.Sel)
+	switch {
+	case in.nonGlobalCnt == 0 && !nested:
+		// The global tables exist on all the backends.
+	case m.routeLen == 1 && in.routeLen == 1 && (m.backend == in.backend || m.nonGlobalCnt == 0):
+		m.backend = in.backend
+		m.nonGlobalCnt += in.nonGlobalCnt
+	case sq.typ == SubqueryIn && !nested && isColocatedIn(m, in, sq):
+		m.nonGlobalCnt += in.nonGlobalCnt
+	default:
+		return false
+	}
+
+	sub := &sqlparser.Subquery{Select: in.Sel}
+	sel := m.Sel.(*sqlparser.Select)
+	sel.Where.Expr = sqlparser.Rewrite(sel.Where.Expr, func(cursor *sqlparser.Cursor) bool {
+		switch node := cursor.Node().(type) {
+		case *sqlparser.ComparisonExpr:
+			if arg, ok := node.Right.(sqlparser.ListArg); ok && string(arg) == "::"+sq.name {
+				node.Right = sub
+				return false
+			}
+		case *sqlparser.SQLVal:
+			if node.Type == sqlparser.ValArg && string(node.Val) == ":"+sq.name {
+				cursor.Replace(&sqlparser.ExistsExpr{Subquery: sub})
+				return false
+			}
+		}
+		return true
+	}, nil).(sqlparser.Expr)
+	return true
+}
+
+// isColocatedIn used to check whether the `col IN (select key from t)` can be executed on each shard,
+// the col is the shard key of the outer table, the key is the shard key of the co-located table t.
+// If true, the table t is renamed to the sub table co-located with the outer table in each query.
+func isColocatedIn(m, in *MergeNode, sq *subqueryExpr) bool {
+	sel, ok := in.Sel.(*sqlparser.Select)
+	if !ok || len(sel.SelectExprs) != 1 || len(sel.GroupBy) > 0 || sel.Having != nil || sel.Limit != nil {
+		return false
+	}
+	expr, ok := sel.SelectExprs[0].(*sqlparser.AliasedExpr)
+	if !ok {
+		return false
+	}
+	inCol, ok := expr.Expr.(*sqlparser.ColName)
+	if !ok {
+		return false
+	}
+	col, ok := sq.expr.(*sqlparser.ComparisonExpr).Left.(*sqlparser.ColName)
+	if !ok {
+		return false
+	}
+
+	it := findShardTable(in.referTables, inCol)
+	ot := findShardTable(m.referTables, col)
+	if it == nil || ot == nil || in.nonGlobalCnt != 1 || !isColocated(ot, it) {
+		return false
+	}
+	segments, err := m.router.GetSegments(it.database, it.tableName, m.indexes)
+	if err != nil || len(segments) != m.routeLen || len(segments) != len(ot.Segments) {
+		return false
+	}
+	for i, segment := range segments {
+		if segment.Backend != ot.Segments[i].Backend {
+			return false
+		}
+	}
+	it.Segments = segments
+	m.subTables = append(m.subTables, it)
+	return true
+}
+
+// findShardTable returns the table whose single shard key is the col, nil if not found.
+func findShardTable(tbInfos map[string]*tableInfo, col *sqlparser.ColName) *tableInfo {
+	tableName := col.Qualifier.Name.String()
+	var tbInfo *tableInfo
+	if tableName == "" {
+		if len(tbInfos) != 1 {
+			return nil
+		}
+		_, tbInfo = getOneTableInfo(tbInfos)
+	} else {
+		tbInfo = tbInfos[tableName]
+	}
+	if tbInfo == nil || tbInfo.shardRange == nil || len(tbInfo.shardRange.keys) != 1 {
+		return nil
+	}
+	if !col.Name.EqualString(tbInfo.shardRange.keys[0]) {
+		return nil
+	}
+	return tbInfo
+}
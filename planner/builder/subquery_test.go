@@ -0,0 +1,184 @@
+/*
+ * NeoDB
+ *
+ * Copyright 2021-2030 The NeoDB Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package builder
+
+import (
+	"testing"
+
+	"github.com/sealdb/neodb/router"
+
+	"github.com/sealdb/mysqlstack/sqlparser"
+	"github.com/sealdb/mysqlstack/xlog"
+	"github.com/stretchr/testify/assert"
+)
+
+func querysOf(node PlanNode) []string {
+	var querys []string
+	for _, q := range node.GetQuery() {
+		querys = append(querys, q.Backend+": "+q.Query)
+	}
+	return querys
+}
+
+func TestSubqueryPushDown(t *testing.T) {
+	tcases := []struct {
+		query string
+		out   []string
+	}{
+		// The co-located tables.
+		{
+			query: "select * from B where id in (select a from C)",
+			out: []string{
+				"backend1: select * from sbtest.B0 as B where id in (select a from sbtest.C0 as C)",
+				"backend2: select * from sbtest.B1 as B where id in (select a from sbtest.C1 as C)",
+			},
+		},
+		// The global table.
+		{
+			query: "select * from B where exists (select 1 from G where G.a=1)",
+			out: []string{
+				"backend1: select * from sbtest.B0 as B where exists (select 1 from sbtest.G where G.a = 1 limit 1)",
+				"backend2: select * from sbtest.B1 as B where exists (select 1 from sbtest.G where G.a = 1 limit 1)",
+			},
+		},
+		// The same backend.
+		{
+			query: "select * from G where a not in (select id from B where id = 1)",
+			out: []string{
+				"backend2: select * from sbtest.G where a not in (select id from sbtest.B1 as B where id = 1)",
+			},
+		},
+	}
+
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	database := "sbtest"
+
+	route, cleanup := router.MockNewRouter(log)
+	defer cleanup()
+
+	err := route.CreateDatabase(database)
+	assert.Nil(t, err)
+	err = route.AddForTest(database, router.MockTableMConfig(), router.MockTableBConfig(), router.MockTableGConfig(), router.MockTableCConfig())
+	assert.Nil(t, err)
+	for _, tcase := range tcases {
+		node, err := sqlparser.Parse(tcase.query)
+		assert.Nil(t, err)
+		p, err := BuildNode(log, route, database, node.(sqlparser.SelectStatement))
+		assert.Nil(t, err)
+		_, ok := p.(*MergeNode)
+		assert.True(t, ok, tcase.query)
+		assert.Equal(t, tcase.out, querysOf(p), tcase.query)
+	}
+}
+
+func TestSubquerySemiJoin(t *testing.T) {
+	tcases := []struct {
+		query string
+		types []SubqueryType
+		out   []string
+	}{
+		// IN is not on the shard key.
+		{
+			query: "select * from B where b in (select a from C)",
+			types: []SubqueryType{SubqueryIn},
+			out: []string{
+				"backend1: select a from sbtest.C0 as C",
+				"backend2: select a from sbtest.C1 as C",
+				"backend1: select * from sbtest.B0 as B where b in ::__sq1",
+				"backend2: select * from sbtest.B1 as B where b in ::__sq1",
+			},
+		},
+		// Different backends.
+		{
+			query: "select * from A where id = 1 and not exists (select 1 from B where id = 1) and a not in (select b from C)",
+			types: []SubqueryType{SubqueryNotExists, SubqueryNotIn},
+			out: []string{
+				"backend2: select 1 from sbtest.B1 as B where id = 1 limit 1",
+				"backend1: select b from sbtest.C0 as C",
+				"backend2: select b from sbtest.C1 as C",
+				"backend6: select * from sbtest.A6 as A where id = 1 and not :__sq1 and a not in ::__sq2",
+			},
+		},
+		// The nested subquery is pushed down to the co-located table.
+		{
+			query: "select * from B where id in (select a from C where a in (select a from C where b=1))",
+			types: []SubqueryType{SubqueryIn},
+			out: []string{
+				"backend1: select a from sbtest.C0 as C where a in (select a from sbtest.C0 as C where b = 1)",
+				"backend2: select a from sbtest.C1 as C where a in (select a from sbtest.C1 as C where b = 1)",
+				"backend1: select * from sbtest.B0 as B where id in ::__sq1",
+				"backend2: select * from sbtest.B1 as B where id in ::__sq1",
+			},
+		},
+		// Join.
+		{
+			query: "select A.id, B.a from A join B on A.id=B.a where A.a in (select a from G)",
+			types: []SubqueryType{SubqueryIn},
+		},
+	}
+
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	database := "sbtest"
+
+	route, cleanup := router.MockNewRouter(log)
+	defer cleanup()
+
+	err := route.CreateDatabase(database)
+	assert.Nil(t, err)
+	err = route.AddForTest(database, router.MockTableMConfig(), router.MockTableBConfig(), router.MockTableGConfig(), router.MockTableCConfig())
+	assert.Nil(t, err)
+	for _, tcase := range tcases {
+		node, err := sqlparser.Parse(tcase.query)
+		assert.Nil(t, err)
+		p, err := BuildNode(log, route, database, node.(sqlparser.SelectStatement))
+		assert.Nil(t, err)
+		semi, ok := p.(*SemiJoinNode)
+		assert.True(t, ok, tcase.query)
+		var types []SubqueryType
+		for _, sub := range semi.Subqueries {
+			types = append(types, sub.Type)
+		}
+		assert.Equal(t, tcase.types, types)
+		if tcase.out != nil {
+			assert.Equal(t, tcase.out, querysOf(p), tcase.query)
+		}
+	}
+}
+
+func TestSubqueryUnsupported(t *testing.T) {
+	querys := []string{
+		"select * from A where exists (select 1 from B where B.id=A.id)",
+		"select * from A where (a,b) in (select a,b from B)",
+		"select * from A where a = (select a from B)",
+		"select * from A where a in (select a from D)",
+	}
+	results := []string{
+		"unsupported: correlated.subquery.column.'A.id'",
+		"unsupported: tuple.in.subquery",
+		"unsupported: subqueries.in.select",
+		"Table 'D' doesn't exist (errno 1146) (sqlstate 42S02)",
+	}
+
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	database := "sbtest"
+
+	route, cleanup := router.MockNewRouter(log)
+	defer cleanup()
+
+	err := route.CreateDatabase(database)
+	assert.Nil(t, err)
+	err = route.AddForTest(database, router.MockTableMConfig(), router.MockTableBConfig())
+	assert.Nil(t, err)
+	for i, query := range querys {
+		node, err := sqlparser.Parse(query)
+		assert.Nil(t, err)
+		_, err = BuildNode(log, route, database, node.(sqlparser.SelectStatement))
+		assert.EqualError(t, err, results[i], query)
+	}
+}
@@ -120,15 +120,26 @@ func (u *UnionNode) pushHaving(having exprInfo) error {
 }
 
 // Temporarily unreachable.
-func (u *UnionNode) pushMisc(sel *sqlparser.Select) {
+func (u *UnionNode) pushWindows(windows []windowTuple) error {
 	panic("unreachable")
 }
 
 // Temporarily unreachable.
-func (u *UnionNode) addNoTableFilter(exprs []sqlparser.Expr) {
+func (u *UnionNode) pushDistinct() error {
+	panic("unreachable")
+}
+
+// Temporarily unreachable.
+func (u *UnionNode) pushMisc(sel *sqlparser.Select) {
 	panic("unreachable")
 }
 
+// addNoTableFilter used to push the no table filters to both sides of the union.
+func (u *UnionNode) addNoTableFilter(exprs []sqlparser.Expr) {
+	u.Left.addNoTableFilter(exprs)
+	u.Right.addNoTableFilter(exprs)
+}
+
 // unreachable.
 func (u *UnionNode) pushSelectExprs(fields, groups []selectTuple, sel *sqlparser.Select, aggTyp aggrType) error {
 	panic("unreachable")
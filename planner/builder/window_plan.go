@@ -0,0 +1,385 @@
+/*
+ * NeoDB
+ *
+ * Copyright 2021-2030 The NeoDB Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package builder
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/sealdb/neodb/expression"
+	"github.com/sealdb/neodb/xparser"
+
+	"github.com/pkg/errors"
+	"github.com/sealdb/mysqlstack/sqlparser"
+	"github.com/sealdb/mysqlstack/sqlparser/depends/common"
+	"github.com/sealdb/mysqlstack/sqlparser/depends/sqltypes"
+	"github.com/sealdb/mysqlstack/xlog"
+)
+
+var (
+	_ ChildPlan = &WindowPlan{}
+)
+
+// windowArgs are the window functions computed in the proxy with the min and max count of the args.
+var windowArgs = map[string][2]int{
+	"row_number":   {0, 0},
+	"rank":         {0, 0},
+	"dense_rank":   {0, 0},
+	"percent_rank": {0, 0},
+	"cume_dist":    {0, 0},
+	"ntile":        {1, 1},
+	"lag":          {1, 3},
+	"lead":         {1, 3},
+	"first_value":  {1, 1},
+	"last_value":   {1, 1},
+	"nth_value":    {2, 2},
+	"count":        {1, 1},
+	"sum":          {1, 1},
+	"avg":          {1, 1},
+	"min":          {1, 1},
+	"max":          {1, 1},
+}
+
+// windowTuple is the window function in the select list.
+type windowTuple struct {
+	// index of the select expr.
+	index int
+	fn    *sqlparser.FuncExpr
+	spec  *xparser.WindowSpec
+	// text of the window function, such as: `rank() over (order by a asc)`.
+	text string
+}
+
+// WindowOrder is the order by item of the window.
+type WindowOrder struct {
+	// Index of the field.
+	Index int
+	Desc  bool
+}
+
+// WindowFunction represents the window function computed in the proxy.
+type WindowFunction struct {
+	Window string
+	// Name of the function in lower case.
+	Name string `json:"-"`
+	// Index of the field which the result is set to.
+	Index int `json:"-"`
+	// Arg is the index of the field as the first arg, -1 if no arg.
+	Arg int `json:"-"`
+	// Offset is the N of the LAG/LEAD/NTILE/NTH_VALUE.
+	Offset int `json:"-"`
+	// Default is the default value of the LAG/LEAD.
+	Default    sqltypes.Value `json:"-"`
+	Partitions []int          `json:"-"`
+	OrderBys   []WindowOrder  `json:"-"`
+	// Frame is nil if the default frame is used.
+	Frame *xparser.WindowFrame `json:"-"`
+}
+
+// WindowPlan represents the window functions computed in the proxy after the rows merged,
+// the rows are partitioned and sorted by the windows, and the frames are computed in memory.
+// The window function's field is fetched as NULL, its args and the exprs of the partition
+// by and the order by are fetched as the hidden fields.
+type WindowPlan struct {
+	log *xlog.Log
+	// the fields of the rows.
+	fields  []selectTuple
+	windows []windowTuple
+	Windows []WindowFunction `json:"Window(s)"`
+	// The indexes mark the hidden fields to be removed.
+	RemovedIdxs []int `json:",omitempty"`
+	typ         ChildType
+}
+
+// NewWindowPlan used to create WindowPlan.
+func NewWindowPlan(log *xlog.Log, windows []windowTuple, fields []selectTuple) *WindowPlan {
+	return &WindowPlan{
+		log:     log,
+		fields:  fields,
+		windows: windows,
+		typ:     ChildTypeWindow,
+	}
+}
+
+// analyze used to resolve the args, the partition by and the order by of the windows.
+func (p *WindowPlan) analyze() error {
+	for _, field := range p.fields {
+		if field.field == "*" {
+			return errors.Errorf("unsupported: exists.'*'.and.window.function.in.proxy")
+		}
+	}
+
+	resolve := func(expr sqlparser.Expr) (int, error) {
+		idx, ok := resolveField(p.fields, expr)
+		if !ok {
+			return -1, errors.Errorf("unsupported: unknown.column.'%s'.in.window", sqlparser.String(expr))
+		}
+		return idx, nil
+	}
+
+	for _, w := range p.windows {
+		fn := WindowFunction{
+			Window: w.text,
+			Name:   w.fn.Name.Lowered(),
+			Index:  w.index,
+			Arg:    -1,
+			Frame:  w.spec.Frame,
+		}
+		argc, ok := windowArgs[fn.Name]
+		if !ok || w.fn.Distinct || len(w.fn.Exprs) < argc[0] || len(w.fn.Exprs) > argc[1] {
+			return errors.Errorf("unsupported: window.function.'%s'.in.proxy", w.text)
+		}
+
+		var err error
+		for i, expr := range w.fn.Exprs {
+			aliased, ok := expr.(*sqlparser.AliasedExpr)
+			if !ok {
+				// The count(*) counts the rows.
+				if fn.Name == "count" {
+					continue
+				}
+				return errors.Errorf("unsupported: window.function.'%s'.in.proxy", w.text)
+			}
+			switch {
+			case i == 0 && fn.Name != "ntile":
+				fn.Arg, err = resolve(aliased.Expr)
+			case i == 2:
+				fn.Default, err = windowDefault(aliased.Expr)
+			default:
+				fn.Offset, err = windowOffset(aliased.Expr, fn.Name)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		if (fn.Name == "lag" || fn.Name == "lead") && len(w.fn.Exprs) == 1 {
+			fn.Offset = 1
+		}
+
+		for _, expr := range w.spec.PartitionBy {
+			idx, err := resolve(expr)
+			if err != nil {
+				return err
+			}
+			fn.Partitions = append(fn.Partitions, idx)
+		}
+		for _, order := range w.spec.OrderBy {
+			idx, err := resolve(order.Expr)
+			if err != nil {
+				return err
+			}
+			fn.OrderBys = append(fn.OrderBys, WindowOrder{Index: idx, Desc: order.Direction == sqlparser.DescScr})
+		}
+		p.Windows = append(p.Windows, fn)
+	}
+	return nil
+}
+
+// windowOffset returns the N of the LAG/LEAD/NTILE/NTH_VALUE, which must be the integer.
+func windowOffset(expr sqlparser.Expr, name string) (int, error) {
+	if val, ok := expr.(*sqlparser.SQLVal); ok && val.Type == sqlparser.IntVal {
+		n, err := strconv.Atoi(string(val.Val))
+		if err == nil && (n > 0 || name == "lag" || name == "lead") {
+			return n, nil
+		}
+	}
+	return 0, errors.Errorf("Incorrect arguments to %s", name)
+}
+
+// windowDefault returns the default value of the LAG/LEAD, which must be the constant.
+func windowDefault(expr sqlparser.Expr) (sqltypes.Value, error) {
+	eval, err := expression.Compile(expr, func(e sqlparser.Expr) (int, bool) {
+		return -1, false
+	})
+	if err != nil {
+		return sqltypes.NULL, err
+	}
+	return eval.Eval(nil)
+}
+
+// Build used to build distributed querys.
+func (p *WindowPlan) Build() error {
+	return p.analyze()
+}
+
+// Type returns the type of the plan.
+func (p *WindowPlan) Type() ChildType {
+	return p.typ
+}
+
+// JSON returns the plan info.
+func (p *WindowPlan) JSON() string {
+	out, err := common.ToJSONString(p, false, "", "\t")
+	if err != nil {
+		return err.Error()
+	}
+	return out
+}
+
+// parseWindows returns the window functions in the select list, the window function
+// without the alias is named by its text. The window functions are only supported
+// in the select list.
+func parseWindows(node *sqlparser.Select) ([]windowTuple, error) {
+	var windows []windowTuple
+	for i, expr := range node.SelectExprs {
+		aliased, ok := expr.(*sqlparser.AliasedExpr)
+		if !ok || !xparser.IsWindow(aliased.Expr) {
+			continue
+		}
+		w, err := xparser.ParseWindow(aliased.Expr)
+		if err != nil {
+			return nil, err
+		}
+		fn, ok := w.Func.(*sqlparser.FuncExpr)
+		if !ok {
+			return nil, errors.Errorf("unsupported: window.function.'%s'", sqlparser.String(w))
+		}
+		text := sqlparser.String(w)
+		if aliased.As.IsEmpty() {
+			aliased.As = sqlparser.NewColIdent(text)
+		}
+		windows = append(windows, windowTuple{index: i, fn: fn, spec: w.Spec, text: text})
+	}
+
+	cnt := 0
+	_ = sqlparser.Walk(func(node sqlparser.SQLNode) (bool, error) {
+		switch node := node.(type) {
+		case *sqlparser.Subquery:
+			// The subquery is built by itself.
+			return false, nil
+		case *sqlparser.FuncExpr:
+			if xparser.IsWindow(node) {
+				cnt++
+			}
+		}
+		return true, nil
+	}, node)
+	if cnt != len(windows) {
+		return nil, errors.New("unsupported: window.function.out.of.the.select.list")
+	}
+	return windows, nil
+}
+
+// windowPushDown returns true if the window functions can be computed by the backends,
+// that is, the partition by contains the shard key, the rows of every partition are
+// in the same shard. The rows must not be aggregated in the proxy.
+func windowPushDown(m *MergeNode, node *sqlparser.Select, windows []windowTuple) bool {
+	if len(node.GroupBy) > 0 || node.Having != nil || hasAggregates(node.SelectExprs) {
+		return false
+	}
+	for _, w := range windows {
+		if !partitionByShardKey(m, w.spec.PartitionBy) {
+			return false
+		}
+	}
+	return true
+}
+
+// partitionByShardKey returns true if the partition by contains the shard key of a shard table,
+// the shard tables in the MergeNode are co-located.
+func partitionByShardKey(m *MergeNode, partitionBy sqlparser.Exprs) bool {
+	for _, expr := range partitionBy {
+		col, ok := expr.(*sqlparser.ColName)
+		if !ok {
+			continue
+		}
+		table := col.Qualifier.Name.String()
+		for name, tbInfo := range m.referTables {
+			if tbInfo.shardKey == "" || tbInfo.derived != nil {
+				continue
+			}
+			if (table == name || (table == "" && len(m.referTables) == 1)) && strings.EqualFold(col.Name.String(), tbInfo.shardKey) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// hasAggregates returns true if the exprs contain the aggregates out of the window functions.
+func hasAggregates(exprs sqlparser.SelectExprs) bool {
+	found := false
+	_ = sqlparser.Walk(func(node sqlparser.SQLNode) (bool, error) {
+		switch node := node.(type) {
+		case *sqlparser.Subquery:
+			return false, nil
+		case *sqlparser.FuncExpr:
+			if xparser.IsWindow(node) {
+				return false, nil
+			}
+			found = found || isAggregate(node)
+		case *sqlparser.GroupConcatExpr:
+			found = true
+		}
+		return !found, nil
+	}, exprs)
+	return found
+}
+
+// windowAggregates returns the aggregates in the window functions, which are
+// computed by the backends with the window functions pushed down.
+func windowAggregates(expr sqlparser.Expr) map[*sqlparser.FuncExpr]bool {
+	aggrs := make(map[*sqlparser.FuncExpr]bool)
+	_ = sqlparser.Walk(func(node sqlparser.SQLNode) (bool, error) {
+		if xparser.IsWindow(node) {
+			_ = sqlparser.Walk(func(node sqlparser.SQLNode) (bool, error) {
+				if fn, ok := node.(*sqlparser.FuncExpr); ok && isAggregate(fn) {
+					aggrs[fn] = true
+				}
+				return true, nil
+			}, node)
+			return false, nil
+		}
+		return true, nil
+	}, expr)
+	return aggrs
+}
+
+// replaceWindows returns the copy of the select, the window functions are replaced by NULL,
+// which are computed by the WindowPlan in the proxy.
+func replaceWindows(node *sqlparser.Select, windows []windowTuple) *sqlparser.Select {
+	copied := *node
+	copied.SelectExprs = append(sqlparser.SelectExprs(nil), node.SelectExprs...)
+	for _, w := range windows {
+		copied.SelectExprs[w.index] = &sqlparser.AliasedExpr{
+			Expr: &sqlparser.NullVal{},
+			As:   copied.SelectExprs[w.index].(*sqlparser.AliasedExpr).As,
+		}
+	}
+	return &copied
+}
+
+// windowExprs returns the exprs which the window functions depend on: the first args,
+// the exprs of the partition by and the order by.
+func windowExprs(windows []windowTuple) []sqlparser.Expr {
+	var exprs []sqlparser.Expr
+	for _, w := range windows {
+		if len(w.fn.Exprs) > 0 && w.fn.Name.Lowered() != "ntile" {
+			if aliased, ok := w.fn.Exprs[0].(*sqlparser.AliasedExpr); ok {
+				exprs = append(exprs, aliased.Expr)
+			}
+		}
+		exprs = append(exprs, w.spec.PartitionBy...)
+		for _, order := range w.spec.OrderBy {
+			exprs = append(exprs, order.Expr)
+		}
+	}
+	return exprs
+}
+
+// formatWindow formats the window function with the OVER clause for the backends.
+func formatWindow(buf *sqlparser.TrackedBuffer, node sqlparser.SQLNode) {
+	if xparser.IsWindow(node) {
+		if w, err := xparser.ParseWindow(node.(sqlparser.Expr)); err == nil {
+			w.Format(buf)
+			return
+		}
+	}
+	node.Format(buf)
+}
@@ -0,0 +1,139 @@
+/*
+ * NeoDB
+ *
+ * Copyright 2021-2030 The NeoDB Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package builder
+
+import (
+	"testing"
+
+	"github.com/sealdb/neodb/router"
+	"github.com/sealdb/neodb/xparser"
+
+	"github.com/sealdb/mysqlstack/sqlparser"
+	"github.com/sealdb/mysqlstack/xlog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWindowPlan(t *testing.T) {
+	tcases := []struct {
+		query  string
+		out    []string
+		window string
+	}{
+		// Single route.
+		{
+			query: "select a, row_number() over (order by b desc) from A where id = 1",
+			out: []string{
+				"backend6: select a, row_number() over (order by b desc) as `row_number() over (order by b desc)` from sbtest.A6 as A where id = 1",
+			},
+		},
+		// The partition by contains the shard key.
+		{
+			query: "select id, sum(a) over (partition by id order by b rows between 1 preceding and current row) as s from A limit 1",
+			out: []string{
+				"backend1: select id, sum(a) over (partition by id order by b asc rows between 1 preceding and current row) as s from sbtest.A1 as A limit 1",
+				"backend2: select id, sum(a) over (partition by id order by b asc rows between 1 preceding and current row) as s from sbtest.A2 as A limit 1",
+				"backend3: select id, sum(a) over (partition by id order by b asc rows between 1 preceding and current row) as s from sbtest.A3 as A limit 1",
+				"backend4: select id, sum(a) over (partition by id order by b asc rows between 1 preceding and current row) as s from sbtest.A4 as A limit 1",
+				"backend5: select id, sum(a) over (partition by id order by b asc rows between 1 preceding and current row) as s from sbtest.A5 as A limit 1",
+				"backend6: select id, sum(a) over (partition by id order by b asc rows between 1 preceding and current row) as s from sbtest.A6 as A limit 1",
+			},
+		},
+		// Computed in the proxy.
+		{
+			query: "select a, rank() over (partition by a order by b desc) as r, lag(c, 2, 0) over (order by b) from A",
+			out: []string{
+				"backend1: select a, null as r, null as `lag(c, 2, 0) over (order by b asc)`, b, c from sbtest.A1 as A",
+				"backend2: select a, null as r, null as `lag(c, 2, 0) over (order by b asc)`, b, c from sbtest.A2 as A",
+				"backend3: select a, null as r, null as `lag(c, 2, 0) over (order by b asc)`, b, c from sbtest.A3 as A",
+				"backend4: select a, null as r, null as `lag(c, 2, 0) over (order by b asc)`, b, c from sbtest.A4 as A",
+				"backend5: select a, null as r, null as `lag(c, 2, 0) over (order by b asc)`, b, c from sbtest.A5 as A",
+				"backend6: select a, null as r, null as `lag(c, 2, 0) over (order by b asc)`, b, c from sbtest.A6 as A",
+			},
+			window: "{\n\t\"Window(s)\": [\n\t\t{\n\t\t\t\"Window\": \"rank() over (partition by a order by b desc)\"\n\t\t},\n\t\t{\n\t\t\t\"Window\": \"lag(c, 2, 0) over (order by b asc)\"\n\t\t}\n\t],\n\t\"RemovedIdxs\": [\n\t\t3,\n\t\t4\n\t]\n}",
+		},
+		// The window over the aggregates, the order by and the limit are not pushed down.
+		{
+			query: "select a, sum(sum(b)) over (order by a) as s from A group by a order by s limit 2",
+			out: []string{
+				"backend1: select a, null as s, sum(b) from sbtest.A1 as A group by a",
+				"backend2: select a, null as s, sum(b) from sbtest.A2 as A group by a",
+				"backend3: select a, null as s, sum(b) from sbtest.A3 as A group by a",
+				"backend4: select a, null as s, sum(b) from sbtest.A4 as A group by a",
+				"backend5: select a, null as s, sum(b) from sbtest.A5 as A group by a",
+				"backend6: select a, null as s, sum(b) from sbtest.A6 as A group by a",
+			},
+			window: "{\n\t\"Window(s)\": [\n\t\t{\n\t\t\t\"Window\": \"sum(sum(b)) over (order by a asc)\"\n\t\t}\n\t]\n}",
+		},
+	}
+
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	database := "sbtest"
+
+	route, cleanup := router.MockNewRouter(log)
+	defer cleanup()
+
+	err := route.CreateDatabase(database)
+	assert.Nil(t, err)
+	err = route.AddForTest(database, router.MockTableMConfig())
+	assert.Nil(t, err)
+	for _, tcase := range tcases {
+		node, err := xparser.Parse(tcase.query)
+		assert.Nil(t, err, tcase.query)
+		p, err := BuildNode(log, route, database, node.(sqlparser.SelectStatement))
+		assert.Nil(t, err, tcase.query)
+		assert.Equal(t, tcase.out, querysOf(p), tcase.query)
+
+		window := ""
+		for _, child := range p.Children() {
+			if child.Type() == ChildTypeWindow {
+				window = child.JSON()
+			}
+		}
+		assert.Equal(t, tcase.window, window, tcase.query)
+	}
+}
+
+func TestWindowPlanError(t *testing.T) {
+	querys := []string{
+		"select *, rank() over (order by b) from A",
+		"select a from A where rank() over (order by b) > 1",
+		"select a, foo(a) over () from A",
+		"select a, count(distinct b) over () from A",
+		"select a, ntile(0) over (order by b) from A",
+		"select a, lag(a, b) over (order by b) from A",
+	}
+	results := []string{
+		"unsupported: exists.'*'.and.window.function.in.proxy",
+		"unsupported: window.function.out.of.the.select.list",
+		"unsupported: window.function.'foo(a) over ()'.in.proxy",
+		"unsupported: window.function.'count(distinct b) over ()'.in.proxy",
+		"Incorrect arguments to ntile",
+		"Incorrect arguments to lag",
+	}
+
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	database := "sbtest"
+
+	route, cleanup := router.MockNewRouter(log)
+	defer cleanup()
+
+	err := route.CreateDatabase(database)
+	assert.Nil(t, err)
+	err = route.AddForTest(database, router.MockTableMConfig())
+	assert.Nil(t, err)
+	for i, query := range querys {
+		node, err := xparser.Parse(query)
+		assert.Nil(t, err, query)
+		_, err = BuildNode(log, route, database, node.(sqlparser.SelectStatement))
+		assert.NotNil(t, err, query)
+		if err != nil {
+			assert.Equal(t, results[i], err.Error(), query)
+		}
+	}
+}
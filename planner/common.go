@@ -13,6 +13,8 @@ import (
 	"errors"
 	"fmt"
 
+	"github.com/sealdb/neodb/router"
+
 	"github.com/sealdb/mysqlstack/sqldb"
 	"github.com/sealdb/mysqlstack/sqlparser"
 	"github.com/sealdb/mysqlstack/xlog"
@@ -30,12 +32,47 @@ func hasSubquery(node sqlparser.SQLNode) bool {
 	return has
 }
 
+// nonDeterministicFuncs are the functions whose results differ by the calls, the
+// rows moved by the shard key update can't be evaluated by them in the proxy.
+var nonDeterministicFuncs = map[string]bool{
+	"now":               true,
+	"sysdate":           true,
+	"current_timestamp": true,
+	"localtime":         true,
+	"localtimestamp":    true,
+	"curdate":           true,
+	"current_date":      true,
+	"curtime":           true,
+	"current_time":      true,
+	"utc_date":          true,
+	"utc_time":          true,
+	"utc_timestamp":     true,
+	"unix_timestamp":    true,
+	"rand":              true,
+	"uuid":              true,
+	"uuid_short":        true,
+	"connection_id":     true,
+	"last_insert_id":    true,
+	"found_rows":        true,
+	"row_count":         true,
+}
+
+// checkDeterministic returns the error if the update expressions call any non-deterministic function.
+func checkDeterministic(exprs sqlparser.UpdateExprs) error {
+	return sqlparser.Walk(func(node sqlparser.SQLNode) (kontinue bool, err error) {
+		if fn, ok := node.(*sqlparser.FuncExpr); ok && nonDeterministicFuncs[fn.Name.Lowered()] {
+			return false, fmt.Errorf("unsupported: non-deterministic.function[%s].in.shard.key.update", fn.Name.Lowered())
+		}
+		return true, nil
+	}, exprs)
+}
+
 // isUpdateShardKey returns true if any of the update
 // expressions modify a shardkey column.
 func isUpdateShardKey(exprs sqlparser.UpdateExprs, shardkey string) bool {
-	if shardkey != "" {
+	for _, key := range router.SplitShardKey(shardkey) {
 		for _, assignment := range exprs {
-			if assignment.Name.Name.EqualString(shardkey) {
+			if assignment.Name.Name.EqualString(key) {
 				return true
 			}
 		}
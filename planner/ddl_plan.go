@@ -75,14 +75,19 @@ func (p *DDLPlan) checkUnsupportedOperations(database, table string) error {
 	}
 	// Unsupported operations check when shardtype is HASH/LIST.
 	if shardKey != "" {
+		keys := router.SplitShardKey(shardKey)
 		switch node.Action {
 		case sqlparser.AlterDropColumnStr:
-			if strings.EqualFold(shardKey, node.DropColumnName) {
-				return errors.New("unsupported: cannot.drop.the.column.on.shard.key")
+			for _, key := range keys {
+				if strings.EqualFold(key, node.DropColumnName) {
+					return errors.New("unsupported: cannot.drop.the.column.on.shard.key")
+				}
 			}
 		case sqlparser.AlterModifyColumnStr:
-			if node.ModifyColumnDef.Name.EqualString(shardKey) {
-				return errors.New("unsupported: cannot.modify.the.column.on.shard.key")
+			for _, key := range keys {
+				if node.ModifyColumnDef.Name.EqualString(key) {
+					return errors.New("unsupported: cannot.modify.the.column.on.shard.key")
+				}
 			}
 			// constraint check in column definition
 			if node.ModifyColumnDef.Type.PrimaryKeyOpt == sqlparser.ColKeyPrimary ||
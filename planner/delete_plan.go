@@ -10,6 +10,8 @@
 package planner
 
 import (
+	"fmt"
+
 	"github.com/sealdb/neodb/planner/builder"
 	"github.com/sealdb/neodb/router"
 	"github.com/sealdb/neodb/xcontext"
@@ -17,6 +19,8 @@ import (
 	"github.com/pkg/errors"
 	"github.com/sealdb/mysqlstack/sqlparser"
 	"github.com/sealdb/mysqlstack/sqlparser/depends/common"
+	querypb "github.com/sealdb/mysqlstack/sqlparser/depends/query"
+	"github.com/sealdb/mysqlstack/sqlparser/depends/sqltypes"
 	"github.com/sealdb/mysqlstack/xlog"
 )
 
@@ -48,6 +52,33 @@ type DeletePlan struct {
 
 	// query and backend tuple
 	Querys []xcontext.QueryTuple
+
+	// Fields is the querys fetching the fields of the targets, only set if the multi-table
+	// delete cannot be pushed down. The target rows are identified by the primary keys,
+	// which are known by the field flags.
+	Fields []xcontext.QueryTuple
+
+	// Root is the select plan resolving the primary keys of the target rows, built by
+	// BuildResolve with the fields.
+	Root builder.PlanNode
+
+	// the tables to delete from of the multi-table delete.
+	targets []*multiTarget
+
+	// resolve is the select on the joined tables locking the target rows, its select exprs
+	// are set by BuildResolve. It's kept as the string since the ast is changed by the builder.
+	resolve string
+
+	// ForceXA is true if the querys write the unique global indexes, the rows and their
+	// lookup pairs must be in the same XA transaction even if they are on one backend.
+	ForceXA bool
+
+	// Prune prunes the routes by the lookup index values at execution, nil if the routes
+	// aren't pruned by the lookup tables.
+	Prune *builder.LookupPrune
+
+	// lookups maintain the lookup tables by the pairs of the rows to change.
+	lookups []*lookupTask
 }
 
 // NewDeletePlan used to create DeletePlan
@@ -66,10 +97,6 @@ func NewDeletePlan(log *xlog.Log, database string, query string, node *sqlparser
 // analyze used to analyze the 'delete' is at the support level.
 func (p *DeletePlan) analyze() error {
 	node := p.node
-	// Currently we both support parse delete with single or multi tables, but only support build plans for single table.
-	if !node.IsSingleTable {
-		return errors.New("unsupported: currently.not.support.multitables.in.delete")
-	}
 	// Currently not support deal with partitions.
 	if node.Partitions != nil {
 		return errors.New("unsupported: currently.not.support.partitions.in.delete")
@@ -88,6 +115,9 @@ func (p *DeletePlan) Build() error {
 	if err = p.analyze(); err != nil {
 		return err
 	}
+	if !p.node.IsSingleTable {
+		return p.buildMultiTables()
+	}
 	newNode := *p.node
 	// For single table, the len(TableRefs)=1 and the type of TableExpr must be AliasedTableExpr.
 	newAliseExpr := newNode.TableRefs[0].(*sqlparser.AliasedTableExpr)
@@ -120,12 +150,20 @@ func (p *DeletePlan) Build() error {
 		}
 
 		// Get the routing segments info.
-		segments, err = builder.LookupFromWhere(databaseID.String(), tableID.String(), shardkey, newNode.Where, p.router)
+		segments, p.Prune, err = builder.LookupFromWhere(databaseID.String(), tableID.String(), shardkey, newNode.Where, p.router)
 		if err != nil {
 			return err
 		}
 	}
 
+	// The rows to delete are read to maintain the lookup tables.
+	tableConf, err := p.router.TableConfig(databaseID.String(), tableID.String())
+	if err != nil {
+		return err
+	}
+	lookups := tableConf.LookupIndexes
+	var lookupReads []xcontext.QueryTuple
+
 	// step 4: Rewritten the newNode to produce a new query.
 	for _, segment := range segments {
 		// rewrite column field
@@ -139,7 +177,210 @@ func (p *DeletePlan) Build() error {
 			Range:   segment.Range.String(),
 		}
 		p.Querys = append(p.Querys, tuple)
+
+		if len(lookups) > 0 {
+			buf := sqlparser.NewTrackedBuffer(nil)
+			buf.Myprintf("select %s from `%s`.`%s`%v%v%v", lookupSelectExprs(tableConf.ShardKey, lookups), databaseID.String(), segment.Table, newNode.Where, newNode.OrderBy, newNode.Limit)
+			lookupReads = append(lookupReads, xcontext.QueryTuple{Query: buf.String(), Backend: segment.Backend})
+		}
+	}
+
+	// step 5: delete the pairs from the lookup tables in the same transaction.
+	if len(lookups) == 0 {
+		return nil
+	}
+	p.ForceXA = hasUniqueLookup(lookups)
+	if newNode.Where == nil && newNode.Limit == nil {
+		// All the rows are deleted, so are the lookup tables.
+		for _, index := range lookups {
+			lookupSegments, err := p.router.Lookup(databaseID.String(), index.Name, nil, nil)
+			if err != nil {
+				return err
+			}
+			for _, segment := range lookupSegments {
+				p.Querys = append(p.Querys, xcontext.QueryTuple{
+					Query:   fmt.Sprintf("delete from `%s`.`%s`", databaseID.String(), segment.Table),
+					Backend: segment.Backend,
+					Range:   segment.Range.String(),
+					Discard: true,
+				})
+			}
+		}
+		return nil
+	}
+	p.lookups = append(p.lookups, &lookupTask{
+		reads:   lookupReads,
+		indexes: lookups,
+		build: func(_ []*sqlparser.SQLVal, pairs [][]LookupPair) ([]xcontext.QueryTuple, error) {
+			var querys []xcontext.QueryTuple
+			for i, index := range lookups {
+				deletes, err := buildLookupDeletes(p.router, databaseID.String(), tableConf.ShardKey, index, pairs[i])
+				if err != nil {
+					return nil, err
+				}
+				querys = append(querys, deletes...)
+			}
+			return querys, nil
+		},
+	})
+	return nil
+}
+
+// buildMultiTables used to build the multi-table delete. If all the tables are co-located
+// or global, the delete is pushed down to the shards. Otherwise the primary keys of the
+// target rows are resolved by the select plan first, and the targets are deleted by them.
+func (p *DeletePlan) buildMultiTables() error {
+	node := p.node
+	tables, err := multiTables(p.router, p.database, node.TableRefs, "delete")
+	if err != nil {
+		return err
+	}
+
+	sel := &sqlparser.Select{From: node.TableRefs, Where: node.Where, Lock: sqlparser.ForUpdateStr}
+	resolve := sqlparser.String(&sqlparser.Select{
+		SelectExprs: sqlparser.SelectExprs{&sqlparser.StarExpr{}},
+		From:        node.TableRefs,
+		Where:       node.Where,
+		Lock:        sqlparser.ForUpdateStr,
+	})
+	hasLookups, hasGlobal := false, false
+	for _, name := range node.TableList {
+		target, ok := tables[name.Name.String()]
+		if !ok || (!name.Qualifier.IsEmpty() && name.Qualifier.String() != databaseOf(target, p.database)) {
+			return errors.Errorf("unsupported: unknown.table.'%s'.in.multi-table.delete", sqlparser.String(name))
+		}
+		p.targets = append(p.targets, target)
+		hasLookups = hasLookups || len(target.config.LookupIndexes) > 0
+		for _, key := range router.SplitShardKey(target.config.ShardKey) {
+			sel.SelectExprs = append(sel.SelectExprs, &sqlparser.AliasedExpr{Expr: &sqlparser.ColName{
+				Name:      sqlparser.NewColIdent(key),
+				Qualifier: sqlparser.TableName{Name: sqlparser.NewTableIdent(target.name)},
+			}})
+		}
+		hasGlobal = hasGlobal || target.config.ShardType == "GLOBAL"
+	}
+	if hasGlobal {
+		// The copies of the global table must be deleted by the same rows.
+		for _, table := range tables {
+			if table.config.ShardType != "GLOBAL" {
+				return errors.New("unsupported: delete.from.the.global.table.joined.with.the.non-global.tables")
+			}
+		}
+	}
+
+	root, err := builder.BuildNode(p.log, p.router, p.database, sel)
+	if err != nil {
+		return err
+	}
+
+	// The delete of the lookup indexed tables maintains the lookup tables by the shard keys.
+	if m, ok := root.(*builder.MergeNode); ok && !hasLookups {
+		p.Querys, err = m.GenerateDML(func(sel *sqlparser.Select) string {
+			targets := make(sqlparser.TableNames, 0, len(p.targets))
+			for _, target := range p.targets {
+				name := sqlparser.TableName{Name: target.expr.As}
+				if name.Name.IsEmpty() {
+					name = target.expr.Expr.(sqlparser.TableName)
+				}
+				targets = append(targets, name)
+			}
+			buf := sqlparser.NewTrackedBuffer(nil)
+			buf.Myprintf("delete %v%v%v from %v%v", node.Comments, &node.DeleteOptionList, targets, sel.From, sel.Where)
+			return buf.String()
+		})
+		return err
+	}
+
+	if p.Fields, err = multiFields(p.router, p.database, p.targets, "delete.from"); err != nil {
+		return err
+	}
+	p.resolve = resolve
+	return nil
+}
+
+// BuildResolve used to build the Root resolving the target rows by the fields of the
+// targets fetched by the Fields querys. The rows are identified by the primary key, or
+// the not null unique key, the target without them is refused.
+func (p *DeletePlan) BuildResolve(fields [][]*querypb.Field) error {
+	stmt, err := sqlparser.Parse(p.resolve)
+	if err != nil {
+		return err
+	}
+	sel := stmt.(*sqlparser.Select)
+	keys, err := multiKeys(p.targets, fields, "delete.from")
+	if err != nil {
+		return err
+	}
+	sel.SelectExprs = nil
+	for _, exprs := range keys {
+		sel.SelectExprs = append(sel.SelectExprs, exprs...)
+	}
+	p.Root, err = builder.BuildNode(p.log, p.router, p.database, sel)
+	return err
+}
+
+// BuildTargets used to build the querys deleting the target rows of the multi-table delete
+// by the keys resolved by the Root. The rows of each target are deleted by the single table
+// delete on the shard keys and the primary keys, which maintains the lookup tables too.
+func (p *DeletePlan) BuildTargets(res *sqltypes.Result) error {
+	p.Querys = p.Querys[:0]
+	offset := 0
+	for _, target := range p.targets {
+		n := len(target.keys)
+		rows := target.targetRows(res.Rows, offset, n)
+		offset += n
+		if len(rows) == 0 {
+			continue
+		}
+
+		fields, keyIdxs, uniqIdxs := target.keyFields()
+		database := databaseOf(target, p.database)
+		for _, where := range buildKeyWheres(rows, fields, keyIdxs, uniqIdxs) {
+			node := &sqlparser.Delete{
+				Comments:         p.node.Comments,
+				DeleteOptionList: p.node.DeleteOptionList,
+				IsSingleTable:    true,
+				TableRefs: sqlparser.TableExprs{&sqlparser.AliasedTableExpr{Expr: sqlparser.TableName{
+					Name:      sqlparser.NewTableIdent(target.config.Name),
+					Qualifier: sqlparser.NewTableIdent(database),
+				}}},
+				Where: sqlparser.NewWhere(sqlparser.WhereClause, where),
+			}
+			plan := NewDeletePlan(p.log, database, sqlparser.String(node), node, p.router)
+			if err := plan.Build(); err != nil {
+				return err
+			}
+			p.Querys = append(p.Querys, plan.Querys...)
+			p.ForceXA = p.ForceXA || plan.ForceXA
+			p.lookups = append(p.lookups, plan.lookups...)
+		}
+	}
+	return nil
+}
+
+// PruneRoutes used to drop the querys of the routes not kept by the Prune.
+func (p *DeletePlan) PruneRoutes(keep []bool) {
+	p.Querys = pruneQuerys(p.Querys, keep)
+	for _, task := range p.lookups {
+		task.reads = pruneQuerys(task.reads, keep)
 	}
+}
+
+// Lookups returns the querys reading the lookup pairs of the rows to delete, they are executed
+// in the transaction before the Querys and the results are passed to the BuildLookups.
+func (p *DeletePlan) Lookups() []xcontext.QueryTuple {
+	return lookupReads(p.lookups)
+}
+
+// BuildLookups used to build the querys deleting the pairs from the lookup tables by the
+// results of the Lookups.
+func (p *DeletePlan) BuildLookups(results []*sqltypes.Result) error {
+	querys, err := buildLookupTasks(p.lookups, results)
+	if err != nil {
+		return err
+	}
+	p.lookups = nil
+	p.Querys = append(p.Querys, querys...)
 	return nil
 }
 
@@ -152,6 +393,9 @@ func (p *DeletePlan) Type() PlanType {
 func (p *DeletePlan) JSON() string {
 	type explain struct {
 		RawQuery   string                `json:",omitempty"`
+		Fields     []xcontext.QueryTuple `json:",omitempty"`
+		Resolve    []xcontext.QueryTuple `json:",omitempty"`
+		Lookups    []xcontext.QueryTuple `json:",omitempty"`
 		Partitions []xcontext.QueryTuple `json:",omitempty"`
 	}
 
@@ -160,8 +404,16 @@ func (p *DeletePlan) JSON() string {
 	parts = append(parts, p.Querys...)
 	exp := &explain{
 		RawQuery:   p.RawQuery,
+		Lookups:    p.Lookups(),
 		Partitions: parts,
 	}
+	// The fields of the targets are fetched to resolve the target rows before the delete.
+	if p.Fields != nil {
+		exp.Fields = p.Fields
+	}
+	if p.Root != nil {
+		exp.Resolve = p.Root.GetQuery()
+	}
 	out, err := common.ToJSONString(exp, false, "", "\t")
 	if err != nil {
 		return err.Error()
@@ -15,6 +15,7 @@ import (
 	"github.com/sealdb/neodb/router"
 
 	"github.com/sealdb/mysqlstack/sqlparser"
+	querypb "github.com/sealdb/mysqlstack/sqlparser/depends/query"
 	"github.com/sealdb/mysqlstack/xlog"
 	"github.com/stretchr/testify/assert"
 )
@@ -233,10 +234,10 @@ func TestDeleteUnsupportedPlan(t *testing.T) {
 
 	results := []string{
 		"unsupported: subqueries.in.delete",
-		"unsupported: currently.not.support.multitables.in.delete",
-		"unsupported: currently.not.support.multitables.in.delete",
-		"unsupported: currently.not.support.multitables.in.delete",
-		"unsupported: currently.not.support.multitables.in.delete",
+		"Table 'db1.t1' doesn't exist (errno 1146) (sqlstate 42S02)",
+		"Table 'db3.t1' doesn't exist (errno 1146) (sqlstate 42S02)",
+		"Table 'a' doesn't exist (errno 1146) (sqlstate 42S02)",
+		"Table 't1' doesn't exist (errno 1146) (sqlstate 42S02)",
 		"unsupported: currently.not.support.partitions.in.delete",
 		"Unknown column 'x.id' in 'where clause' (errno 1054) (sqlstate 42S22)",
 		"Unknown column 'x.A.id' in 'where clause' (errno 1054) (sqlstate 42S22)",
@@ -269,6 +270,129 @@ func TestDeleteUnsupportedPlan(t *testing.T) {
 	}
 }
 
+func TestDeleteMultiTablesPlan(t *testing.T) {
+	querys := []string{
+		"delete B from B join C on B.id = C.a where C.name = 'x'",
+		"delete low_priority b, C from B as b join C on b.id = C.a join G on G.id = b.id where b.id = 1",
+		"delete sbtest.G from G join G as g2 on G.id = g2.id",
+		"delete from A using A join B on A.name = B.name where B.id = 1",
+	}
+	results := []string{
+		`{
+	"RawQuery": "delete B from B join C on B.id = C.a where C.name = 'x'",
+	"Partitions": [
+		{
+			"Query": "delete B from sbtest.B0 as B join sbtest.C0 as C on B.id = C.a where C.name = 'x'",
+			"Backend": "backend1",
+			"Range": "[0-512)"
+		},
+		{
+			"Query": "delete B from sbtest.B1 as B join sbtest.C1 as C on B.id = C.a where C.name = 'x'",
+			"Backend": "backend2",
+			"Range": "[512-4096)"
+		}
+	]
+}`,
+		`{
+	"RawQuery": "delete low_priority b, C from B as b join C on b.id = C.a join G on G.id = b.id where b.id = 1",
+	"Partitions": [
+		{
+			"Query": "delete low_priority b, C from sbtest.B1 as b join sbtest.C1 as C on b.id = C.a join sbtest.G on G.id = b.id where b.id = 1",
+			"Backend": "backend2",
+			"Range": "[512-4096)"
+		}
+	]
+}`,
+		`{
+	"RawQuery": "delete sbtest.G from G join G as g2 on G.id = g2.id",
+	"Partitions": [
+		{
+			"Query": "delete sbtest.G from sbtest.G join sbtest.G as g2 on G.id = g2.id",
+			"Backend": "backend1",
+			"Range": ""
+		},
+		{
+			"Query": "delete sbtest.G from sbtest.G join sbtest.G as g2 on G.id = g2.id",
+			"Backend": "backend2",
+			"Range": ""
+		}
+	]
+}`,
+		`{
+	"RawQuery": "delete from A using A join B on A.name = B.name where B.id = 1",
+	"Fields": [
+		{
+			"Query": "select * from sbtest.A1 where 1 != 1",
+			"Backend": "backend1",
+			"Range": "[0-32)"
+		}
+	]
+}`,
+	}
+
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	database := "sbtest"
+
+	route, cleanup := router.MockNewRouter(log)
+	defer cleanup()
+
+	err := route.CreateDatabase(database)
+	assert.Nil(t, err)
+	err = route.AddForTest(database, router.MockTableMConfig(), router.MockTableBConfig(), router.MockTableCConfig(), router.MockTableGConfig(), router.MockTableSConfig())
+	assert.Nil(t, err)
+	for i, query := range querys {
+		node, err := sqlparser.Parse(query)
+		assert.Nil(t, err)
+		plan := NewDeletePlan(log, database, query, node.(*sqlparser.Delete), route)
+		err = plan.Build()
+		assert.Nil(t, err, query)
+		assert.Equal(t, results[i], plan.JSON(), query)
+		assert.Equal(t, i == 3, plan.Fields != nil, query)
+	}
+
+	// The target rows are resolved by the primary key.
+	{
+		query := querys[3]
+		node, err := sqlparser.Parse(query)
+		assert.Nil(t, err)
+		plan := NewDeletePlan(log, database, query, node.(*sqlparser.Delete), route)
+		err = plan.Build()
+		assert.Nil(t, err)
+
+		fields := []*querypb.Field{{Name: "id"}, {Name: "k"}, {Name: "name"}}
+		err = plan.BuildResolve([][]*querypb.Field{fields})
+		assert.Equal(t, "unsupported: cannot.delete.from.table[A].without.primary.or.unique.key", err.Error())
+
+		fields[1].Flags = uint32(querypb.MySqlFlag_PRI_KEY_FLAG)
+		err = plan.BuildResolve([][]*querypb.Field{fields})
+		assert.Nil(t, err)
+		assert.Equal(t, "select A.id, A.k, A.name from sbtest.A1 as A for update", plan.Root.GetQuery()[0].Query)
+	}
+
+	// Unsupported.
+	querys = []string{
+		"delete x from A join B",
+		"delete xx.B from B join C on B.id = C.a",
+		"delete G from A join G on A.id = G.id",
+		"delete S from A join S on A.id = S.id",
+		"delete A from A join (select id from B) as b on A.id = b.id",
+	}
+	errs := []string{
+		"unsupported: unknown.table.'x'.in.multi-table.delete",
+		"unsupported: unknown.table.'xx.B'.in.multi-table.delete",
+		"unsupported: delete.from.the.global.table.joined.with.the.non-global.tables",
+		"unsupported: cross-shard.multi-table.delete.from.the.single.table[S]",
+		"unsupported: subqueries.in.delete",
+	}
+	for i, query := range querys {
+		node, err := sqlparser.Parse(query)
+		assert.Nil(t, err)
+		plan := NewDeletePlan(log, database, query, node.(*sqlparser.Delete), route)
+		err = plan.Build()
+		assert.Equal(t, errs[i], err.Error(), query)
+	}
+}
+
 func TestDeleteErrorPlan(t *testing.T) {
 	query1 := "delete from A where id=1"
 	query2 := "delete from B"
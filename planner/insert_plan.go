@@ -12,12 +12,14 @@ package planner
 import (
 	"sort"
 
+	"github.com/sealdb/neodb/planner/builder"
 	"github.com/sealdb/neodb/router"
 	"github.com/sealdb/neodb/xcontext"
 
 	"github.com/pkg/errors"
 	"github.com/sealdb/mysqlstack/sqlparser"
 	"github.com/sealdb/mysqlstack/sqlparser/depends/common"
+	"github.com/sealdb/mysqlstack/sqlparser/depends/sqltypes"
 	"github.com/sealdb/mysqlstack/xlog"
 )
 
@@ -25,6 +27,11 @@ var (
 	_ Plan = &InsertPlan{}
 )
 
+const (
+	// insertBatchRows is the max rows of one insert query built by the INSERT ... SELECT.
+	insertBatchRows = 1000
+)
+
 // InsertPlan represents insertion plan
 type InsertPlan struct {
 	log *xlog.Log
@@ -49,6 +56,17 @@ type InsertPlan struct {
 
 	// query and backend tuple
 	Querys []xcontext.QueryTuple
+
+	// Root is the select plan reading the rows of the INSERT ... SELECT, only set if
+	// the insert cannot be pushed down.
+	Root builder.PlanNode
+
+	// AutoIncrement used to fill the auto-increment column of the rows read by the Root.
+	AutoIncrement func(database string, ins *sqlparser.Insert) error
+
+	// ForceXA is true if the querys write the unique global indexes, the rows and their
+	// lookup pairs must be in the same XA transaction even if they are on one backend.
+	ForceXA bool
 }
 
 // NewInsertPlan used to create InsertPlan
@@ -68,13 +86,7 @@ func NewInsertPlan(log *xlog.Log, database string, query string, node *sqlparser
 func (p *InsertPlan) Build() error {
 	newNode := *(p.node)
 
-	// 1. Currently insert/replace not support subquery.
-	rows, ok := newNode.Rows.(sqlparser.Values)
-	if !ok {
-		return errors.Errorf("unsupported: rows.can.not.be.subquery[%T]", newNode.Rows)
-	}
-
-	// 2. Currently insert/replace not support partitions.
+	// 1. Currently insert/replace not support partitions.
 	if len(newNode.Partitions) != 0 {
 		return errors.Errorf("unsupported: neodb.now.not.support.insert.with.partition.")
 	}
@@ -83,6 +95,15 @@ func (p *InsertPlan) Build() error {
 	if newNode.Table.Qualifier.IsEmpty() {
 		newNode.Table.Qualifier = sqlparser.NewTableIdent(p.database)
 	}
+
+	// 2. The rows of the insert/replace ... select are read by the select.
+	if sel, ok := newNode.Rows.(sqlparser.SelectStatement); ok {
+		return p.buildSelect(&newNode, sel)
+	}
+	rows, ok := newNode.Rows.(sqlparser.Values)
+	if !ok {
+		return errors.Errorf("unsupported: rows.can.not.be.subquery[%T]", newNode.Rows)
+	}
 	database := newNode.Table.Qualifier.String()
 	table := newNode.Table.Name.String()
 
@@ -106,7 +127,7 @@ func (p *InsertPlan) Build() error {
 			p.Querys = append(p.Querys, tuple)
 		}
 		return nil
-	case router.MethodTypeHash, router.MethodTypeList:
+	case router.MethodTypeHash, router.MethodTypeList, router.MethodTypeRange, router.MethodTypeInterval:
 		// Get the shard key.
 		shardKey, err := p.router.ShardKey(database, table)
 		if err != nil {
@@ -121,16 +142,46 @@ func (p *InsertPlan) Build() error {
 			}
 		}
 
-		// Find the shard key index.
-		idx := -1
-		for i, column := range newNode.Columns {
-			if column.EqualString(shardKey) {
-				idx = i
-				break
+		// Find the lookup index columns, the lookup tables are maintained with the rows.
+		tableConf, err := p.router.TableConfig(database, table)
+		if err != nil {
+			return err
+		}
+		lookups := tableConf.LookupIndexes
+		lookupIdxs := make([]int, len(lookups))
+		for i, index := range lookups {
+			if len(newNode.OnDup) > 0 && isUpdateShardKey(sqlparser.UpdateExprs(newNode.OnDup), index.Column) {
+				return errors.Errorf("unsupported: cannot.update.lookup.index.column[%s]", index.Column)
+			}
+			// The rows not inserted would leave their values in the unique index.
+			if index.Unique && (newNode.Action == sqlparser.ReplaceStr || newNode.Ignore != "" || len(newNode.OnDup) > 0) {
+				return errors.Errorf("unsupported: replace.ignore.or.on.duplicate.with.unique.global.index[%s]", index.Name)
+			}
+			lookupIdxs[i] = -1
+			for j, column := range newNode.Columns {
+				if column.EqualString(index.Column) {
+					lookupIdxs[i] = j
+					break
+				}
 			}
 		}
-		if idx == -1 {
-			return errors.Errorf("unsupported: shardkey.column[%v].missing", shardKey)
+		pairs := make([][]LookupPair, len(lookups))
+
+		// Find the shard key indexes, the composite shard key has more than one.
+		keys := router.SplitShardKey(shardKey)
+		idxs := make([]int, 0, len(keys))
+		for _, key := range keys {
+			idx := -1
+			for i, column := range newNode.Columns {
+				if column.EqualString(key) {
+					idx = i
+					break
+				}
+			}
+			if idx == -1 {
+				return errors.Errorf("unsupported: shardkey.column[%v].missing", key)
+			}
+			idxs = append(idxs, idx)
 		}
 
 		// Rebuild distributed querys.
@@ -145,12 +196,35 @@ func (p *InsertPlan) Build() error {
 		rTuples := make(map[string]*rowsTuple)
 
 		for _, row := range rows {
-			if idx >= len(row) {
-				return errors.Errorf("unsupported: shardkey[%v].out.of.index:[%v]", shardKey, idx)
+			vals := make([]*sqlparser.SQLVal, 0, len(idxs))
+			for i, idx := range idxs {
+				if idx >= len(row) {
+					return errors.Errorf("unsupported: shardkey[%v].out.of.index:[%v]", keys[i], idx)
+				}
+				val, ok := row[idx].(*sqlparser.SQLVal)
+				if !ok {
+					return errors.Errorf("unsupported: shardkey[%v].type.canot.be[%T]", keys[i], row[idx])
+				}
+				vals = append(vals, val)
 			}
-			shardVal, ok := row[idx].(*sqlparser.SQLVal)
-			if !ok {
-				return errors.Errorf("unsupported: shardkey[%v].type.canot.be[%T]", shardKey, row[idx])
+			shardVal := vals[0]
+			if len(vals) > 1 {
+				if shardVal, err = router.TupleKey(vals); err != nil {
+					return err
+				}
+			}
+
+			for i, idx := range lookupIdxs {
+				if idx == -1 || idx >= len(row) {
+					continue
+				}
+				val, err := lookupValue(lookups[i].Column, row[idx])
+				if err != nil {
+					return err
+				}
+				if val != nil {
+					pairs[i] = append(pairs[i], LookupPair{Value: val, Key: shardVal})
+				}
 			}
 
 			segments, err := p.router.Lookup(database, table, shardVal, shardVal)
@@ -192,12 +266,124 @@ func (p *InsertPlan) Build() error {
 			}
 			p.Querys = append(p.Querys, tuple)
 		}
+
+		// Insert the pairs into the lookup tables in the same transaction.
+		for i, index := range lookups {
+			querys, err := BuildLookupInserts(p.router, database, shardKey, index, pairs[i], !index.Unique)
+			if err != nil {
+				return err
+			}
+			p.Querys = append(p.Querys, querys...)
+			p.ForceXA = p.ForceXA || index.Unique
+		}
 		return nil
 	default:
 		return errors.Errorf("unsupported: neodb.not.support.method.type[%s].", methodType)
 	}
 }
 
+// buildSelect used to build the insert/replace ... select. The insert is pushed down if the
+// rows can be inserted on the backends where they are read, otherwise the rows are read by
+// the Root and inserted by the BuildRows.
+func (p *InsertPlan) buildSelect(node *sqlparser.Insert, sel sqlparser.SelectStatement) error {
+	if paren, ok := sel.(*sqlparser.ParenSelect); ok {
+		sel = paren.Select
+	}
+	database := node.Table.Qualifier.String()
+	table := node.Table.Name.String()
+
+	conf, err := p.router.TableConfig(database, table)
+	if err != nil {
+		return err
+	}
+	// The lookup tables and the auto-increment column are maintained with the rows in proxy.
+	pushable := len(conf.LookupIndexes) == 0
+	check := *node
+	check.Rows = sqlparser.Values{}
+	if conf.AutoIncrement != nil && columnIndex(node.Columns, conf.AutoIncrement.Column) == -1 {
+		pushable = false
+		check.Columns = append(append(sqlparser.Columns(nil), node.Columns...), sqlparser.NewColIdent(conf.AutoIncrement.Column))
+	}
+
+	// The insert of the rows is checked before reading them.
+	if err := NewInsertPlan(p.log, database, p.RawQuery, &check, p.router).Build(); err != nil {
+		return err
+	}
+
+	root, err := builder.BuildNode(p.log, p.router, p.database, sel)
+	if err != nil {
+		return err
+	}
+	if m, ok := root.(*builder.MergeNode); ok && pushable {
+		key := -1
+		if conf.ShardKey != "" && len(router.SplitShardKey(conf.ShardKey)) == 1 {
+			key = columnIndex(node.Columns, conf.ShardKey)
+		}
+		querys, ok, err := m.GenerateInsert(database, table, key, func(table string, sel sqlparser.SelectStatement) string {
+			ins := *node
+			ins.Table.Name = sqlparser.NewTableIdent(table)
+			ins.Rows = sel.(sqlparser.InsertRows)
+			return sqlparser.String(&ins)
+		})
+		if err != nil {
+			return err
+		}
+		if ok {
+			p.Querys = querys
+			return nil
+		}
+	}
+	p.Root = root
+	p.ForceXA = hasUniqueLookup(conf.LookupIndexes)
+	return nil
+}
+
+// BuildRows used to build the querys inserting the rows read by the Root, the rows are
+// inserted in batches and routed by the shard key of each batch.
+func (p *InsertPlan) BuildRows(res *sqltypes.Result) error {
+	p.Querys = p.Querys[:0]
+	for start := 0; start < len(res.Rows); start += insertBatchRows {
+		end := start + insertBatchRows
+		if end > len(res.Rows) {
+			end = len(res.Rows)
+		}
+		rows := make(sqlparser.Values, 0, end-start)
+		for _, row := range res.Rows[start:end] {
+			tuple := make(sqlparser.ValTuple, 0, len(row))
+			for _, val := range row {
+				tuple = append(tuple, valueExpr(val))
+			}
+			rows = append(rows, tuple)
+		}
+
+		node := *p.node
+		node.Columns = append(sqlparser.Columns(nil), p.node.Columns...)
+		node.Rows = rows
+		if p.AutoIncrement != nil {
+			if err := p.AutoIncrement(p.database, &node); err != nil {
+				return err
+			}
+		}
+		plan := NewInsertPlan(p.log, p.database, sqlparser.String(&node), &node, p.router)
+		if err := plan.Build(); err != nil {
+			return err
+		}
+		p.Querys = append(p.Querys, plan.Querys...)
+		p.ForceXA = p.ForceXA || plan.ForceXA
+	}
+	return nil
+}
+
+// columnIndex returns the index of the column in the columns, -1 if not found.
+func columnIndex(columns sqlparser.Columns, name string) int {
+	for i, column := range columns {
+		if column.EqualString(name) {
+			return i
+		}
+	}
+	return -1
+}
+
 // Type returns the type of the plan.
 func (p *InsertPlan) Type() PlanType {
 	return p.Typ
@@ -207,6 +393,7 @@ func (p *InsertPlan) Type() PlanType {
 func (p *InsertPlan) JSON() string {
 	type explain struct {
 		RawQuery   string                `json:",omitempty"`
+		Select     []xcontext.QueryTuple `json:",omitempty"`
 		Partitions []xcontext.QueryTuple `json:",omitempty"`
 	}
 
@@ -216,6 +403,10 @@ func (p *InsertPlan) JSON() string {
 		RawQuery:   p.RawQuery,
 		Partitions: parts,
 	}
+	// The rows are read before the insert.
+	if p.Root != nil {
+		exp.Select = p.Root.GetQuery()
+	}
 	out, err := common.ToJSONString(exp, false, "", "\t")
 	if err != nil {
 		return err.Error()
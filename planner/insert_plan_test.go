@@ -17,6 +17,8 @@ import (
 	"github.com/sealdb/neodb/router"
 
 	"github.com/sealdb/mysqlstack/sqlparser"
+	querypb "github.com/sealdb/mysqlstack/sqlparser/depends/query"
+	"github.com/sealdb/mysqlstack/sqlparser/depends/sqltypes"
 	"github.com/sealdb/mysqlstack/xlog"
 	"github.com/stretchr/testify/assert"
 )
@@ -216,9 +218,8 @@ func TestInsertUnsupportedPlan(t *testing.T) {
 		"insert into sbtest.A(b, c, id) values(1,2,3) on duplicate key update id=1",
 		"insert into sbtest.A(b, c, id) values(1, floor(3), floor(3))",
 		"insert into sbtest.A select * from sbtest.B",
-		"insert into sbtest.A(b,c,id) select id,b,c from sbtest.A",
-		"insert into sbtest.G(b, c, id) select * from sbtest.A",
-		"insert into sbtest.G select * from sbtest.A",
+		"insert into sbtest.A(b,c,id) select b,c,id from sbtest.A on duplicate key update id=1",
+		"insert into sbtest.G(b, c, id) select * from sbtest.X",
 		"insert /* simple */ high_priority into a partition (col_1) values (1)",
 	}
 
@@ -227,10 +228,9 @@ func TestInsertUnsupportedPlan(t *testing.T) {
 		"unsupported: shardkey.column[id].missing",
 		"unsupported: cannot.update.shard.key",
 		"unsupported: shardkey[id].type.canot.be[*sqlparser.FuncExpr]",
-		"unsupported: rows.can.not.be.subquery[*sqlparser.Select]",
-		"unsupported: rows.can.not.be.subquery[*sqlparser.Select]",
-		"unsupported: rows.can.not.be.subquery[*sqlparser.Select]",
-		"unsupported: rows.can.not.be.subquery[*sqlparser.Select]",
+		"unsupported: shardkey.column[id].missing",
+		"unsupported: cannot.update.shard.key",
+		"Table 'X' doesn't exist (errno 1146) (sqlstate 42S02)",
 		"unsupported: neodb.now.not.support.insert.with.partition.",
 	}
 
@@ -259,6 +259,147 @@ func TestInsertUnsupportedPlan(t *testing.T) {
 	}
 }
 
+func TestInsertSelectPlan(t *testing.T) {
+	querys := []string{
+		"insert into C(a, b) select id, name from B where id > 10",
+		"insert into C(b, a) select B.name, B.id from B join G on B.id = G.id",
+		"insert into C(a, b) select id, name from B where id = 1 limit 2",
+		"insert into G(a, b) select a, b from G",
+		"insert into S(a, b) select id, name from B where id = 1002",
+		"insert into C(a, b) select id, name from B limit 2",
+		"insert into C(a, b) select name, id from B",
+		"insert into G(a, b) select id, name from B",
+		"insert into S(a, b) select id, name from B where id = 1",
+		"replace into A(id, b) (select a, b from S)",
+	}
+	pushed := [][]string{
+		{
+			"insert into sbtest.C0(a, b) select id, name from sbtest.B0 as B where id > 10",
+			"insert into sbtest.C1(a, b) select id, name from sbtest.B1 as B where id > 10",
+		},
+		{
+			"insert into sbtest.C0(b, a) select B.name, B.id from sbtest.B0 as B join sbtest.G on B.id = G.id",
+			"insert into sbtest.C1(b, a) select B.name, B.id from sbtest.B1 as B join sbtest.G on B.id = G.id",
+		},
+		{
+			"insert into sbtest.C1(a, b) select id, name from sbtest.B1 as B where id = 1 limit 2",
+		},
+		{
+			"insert into sbtest.G(a, b) select a, b from sbtest.G",
+			"insert into sbtest.G(a, b) select a, b from sbtest.G",
+		},
+		{
+			"insert into sbtest.S(a, b) select id, name from sbtest.B0 as B where id = 1002",
+		},
+	}
+	selects := [][]string{
+		{
+			"select id, name from sbtest.B0 as B limit 2",
+			"select id, name from sbtest.B1 as B limit 2",
+		},
+		{
+			"select name, id from sbtest.B0 as B",
+			"select name, id from sbtest.B1 as B",
+		},
+		{
+			"select id, name from sbtest.B0 as B",
+			"select id, name from sbtest.B1 as B",
+		},
+		{
+			"select id, name from sbtest.B1 as B where id = 1",
+		},
+		{
+			"select a, b from sbtest.S",
+		},
+	}
+
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	database := "sbtest"
+
+	route, cleanup := router.MockNewRouter(log)
+	defer cleanup()
+
+	err := route.CreateDatabase(database)
+	assert.Nil(t, err)
+	err = route.AddForTest(database, router.MockTableMConfig(), router.MockTableBConfig(), router.MockTableCConfig(), router.MockTableGConfig(), router.MockTableSConfig())
+	assert.Nil(t, err)
+	for i, query := range querys {
+		node, err := sqlparser.Parse(query)
+		assert.Nil(t, err)
+		plan := NewInsertPlan(log, database, query, node.(*sqlparser.Insert), route)
+		err = plan.Build()
+		assert.Nil(t, err)
+
+		var got []string
+		if i < len(pushed) {
+			assert.Nil(t, plan.Root)
+			for _, q := range plan.Querys {
+				got = append(got, q.Query)
+			}
+			assert.Equal(t, pushed[i], got)
+		} else {
+			assert.NotNil(t, plan.Root)
+			for _, q := range plan.Root.GetQuery() {
+				got = append(got, q.Query)
+			}
+			assert.Equal(t, selects[i-len(pushed)], got)
+		}
+		plan.JSON()
+		plan.Size()
+	}
+}
+
+func TestInsertSelectBuildRows(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	database := "sbtest"
+
+	route, cleanup := router.MockNewRouter(log)
+	defer cleanup()
+
+	err := route.CreateDatabase(database)
+	assert.Nil(t, err)
+	err = route.AddForTest(database, router.MockTableBConfig(), router.MockTableCConfig())
+	assert.Nil(t, err)
+
+	query := "insert ignore into C(b, a) select name, id from B limit 2000"
+	node, err := sqlparser.Parse(query)
+	assert.Nil(t, err)
+	plan := NewInsertPlan(log, database, query, node.(*sqlparser.Insert), route)
+	err = plan.Build()
+	assert.Nil(t, err)
+	assert.NotNil(t, plan.Root)
+
+	called := 0
+	plan.AutoIncrement = func(database string, ins *sqlparser.Insert) error {
+		called++
+		return nil
+	}
+	res := &sqltypes.Result{
+		Fields: []*querypb.Field{
+			{Name: "name", Type: querypb.Type_VARCHAR},
+			{Name: "id", Type: querypb.Type_INT32},
+		},
+	}
+	for i := 0; i < insertBatchRows+1; i++ {
+		res.Rows = append(res.Rows, []sqltypes.Value{
+			sqltypes.NULL,
+			sqltypes.MakeTrusted(querypb.Type_INT32, []byte(fmt.Sprintf("%d", i))),
+		})
+	}
+	err = plan.BuildRows(res)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, called)
+	// The first batch is routed to both the partitions.
+	assert.Equal(t, 3, len(plan.Querys))
+	assert.Contains(t, plan.Querys[2].Query, "insert ignore into sbtest.C")
+	assert.Contains(t, plan.Querys[2].Query, "(b, a) values (null, 1000)")
+
+	// The shard key must not be null.
+	res.Rows = [][]sqltypes.Value{{sqltypes.NULL, sqltypes.NULL}}
+	err = plan.BuildRows(res)
+	assert.NotNil(t, err)
+}
+
 func TestInsertPlanBench(t *testing.T) {
 	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
 
@@ -370,18 +511,14 @@ func TestReplaceUnsupportedPlan(t *testing.T) {
 		"replace into sbtest.A(b, c, id) values(1,2)",
 		"replace into sbtest.A(b, c, d) values(1,2, 3)",
 		"replace into sbtest.A select * from sbtest.B",
-		"replace into sbtest.A(b,c,id) select id,b,c from sbtest.A",
-		"replace into sbtest.G(b, c, id) select * from sbtest.A",
-		"replace into sbtest.G select * from sbtest.A",
+		"replace into sbtest.G(b, c, id) select * from sbtest.X",
 	}
 
 	results := []string{
 		"unsupported: shardkey[id].out.of.index:[2]",
 		"unsupported: shardkey.column[id].missing",
-		"unsupported: rows.can.not.be.subquery[*sqlparser.Select]",
-		"unsupported: rows.can.not.be.subquery[*sqlparser.Select]",
-		"unsupported: rows.can.not.be.subquery[*sqlparser.Select]",
-		"unsupported: rows.can.not.be.subquery[*sqlparser.Select]",
+		"unsupported: shardkey.column[id].missing",
+		"Table 'X' doesn't exist (errno 1146) (sqlstate 42S02)",
 	}
 
 	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
@@ -439,6 +576,64 @@ func TestReplacePlanBench(t *testing.T) {
 	}
 }
 
+func TestInsertPlanCompositeShardKey(t *testing.T) {
+	results := []string{
+		`{
+	"RawQuery": "insert into CK(tenant_id, user_id, b) values(1,2,3),(1,1,4),('1',2,5)",
+	"Partitions": [
+		{
+			"Query": "insert into sbtest.CK_0000(tenant_id, user_id, b) values (1, 2, 3), ('1', 2, 5)",
+			"Backend": "backend1",
+			"Range": "[0-2048)"
+		},
+		{
+			"Query": "insert into sbtest.CK_0001(tenant_id, user_id, b) values (1, 1, 4)",
+			"Backend": "backend2",
+			"Range": "[2048-4096)"
+		}
+	]
+}`,
+	}
+	querys := []string{
+		"insert into CK(tenant_id, user_id, b) values(1,2,3),(1,1,4),('1',2,5)",
+	}
+
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	database := "sbtest"
+
+	route, cleanup := router.MockNewRouter(log)
+	defer cleanup()
+
+	err := route.CreateDatabase(database)
+	assert.Nil(t, err)
+	err = route.AddForTest(database, router.MockTableCKConfig())
+	assert.Nil(t, err)
+	for i, query := range querys {
+		node, err := sqlparser.Parse(query)
+		assert.Nil(t, err)
+		plan := NewInsertPlan(log, database, query, node.(*sqlparser.Insert), route)
+
+		// plan build
+		{
+			err := plan.Build()
+			assert.Nil(t, err)
+			got := plan.JSON()
+			want := results[i]
+			assert.Equal(t, want, got)
+		}
+	}
+
+	// error.
+	{
+		query := "insert into CK(tenant_id, b) values(1,2)"
+		node, err := sqlparser.Parse(query)
+		assert.Nil(t, err)
+		plan := NewInsertPlan(log, database, query, node.(*sqlparser.Insert), route)
+		err = plan.Build()
+		assert.EqualError(t, err, "unsupported: shardkey.column[user_id].missing")
+	}
+}
+
 func TestInsertPlanError(t *testing.T) {
 	querys := []string{
 		"insert into A(b, c, id) values(1, 2, 3)",
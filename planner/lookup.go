@@ -0,0 +1,235 @@
+/*
+ * NeoDB
+ *
+ * Copyright 2021-2030 The NeoDB Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package planner
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/sealdb/neodb/config"
+	"github.com/sealdb/neodb/router"
+	"github.com/sealdb/neodb/xcontext"
+
+	"github.com/pkg/errors"
+	"github.com/sealdb/mysqlstack/sqlparser"
+	"github.com/sealdb/mysqlstack/sqlparser/depends/sqltypes"
+)
+
+// LookupPair is a row of the lookup table, maps the column value to the shard key.
+type LookupPair struct {
+	Value *sqlparser.SQLVal
+	Key   *sqlparser.SQLVal
+}
+
+// lookupPairs groups the pairs by the sub tables of the lookup table, the pairs are deduplicated.
+func lookupPairs(route *router.Router, database string, index *config.LookupIndexConfig, pairs []LookupPair) (map[string]router.Segment, map[string]sqlparser.Values, []string, error) {
+	segments := make(map[string]router.Segment)
+	rows := make(map[string]sqlparser.Values)
+	seen := make(map[string]bool)
+	for _, pair := range pairs {
+		id := fmt.Sprintf("%d:%d:%s,%d:%s", pair.Value.Type, len(pair.Value.Val), pair.Value.Val, pair.Key.Type, pair.Key.Val)
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+
+		segs, err := route.Lookup(database, index.Name, pair.Value, pair.Value)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		segment := segs[0]
+		segments[segment.Table] = segment
+		rows[segment.Table] = append(rows[segment.Table], sqlparser.ValTuple{pair.Value, pair.Key})
+	}
+
+	// Sorts by the sub tables to avoid deadlock.
+	subTables := make([]string, 0, len(rows))
+	for subTable := range rows {
+		subTables = append(subTables, subTable)
+	}
+	sort.Strings(subTables)
+	return segments, rows, subTables, nil
+}
+
+// BuildLookupInserts builds the querys to insert the pairs into the lookup table.
+// If ignore, the pairs already exist are ignored, otherwise the duplicate value
+// of the unique index fails the query with ER_DUP_ENTRY.
+func BuildLookupInserts(route *router.Router, database, shardKey string, index *config.LookupIndexConfig, pairs []LookupPair, ignore bool) ([]xcontext.QueryTuple, error) {
+	segments, rows, subTables, err := lookupPairs(route, database, index, pairs)
+	if err != nil {
+		return nil, err
+	}
+
+	insert := "insert"
+	if ignore {
+		insert = "insert ignore"
+	}
+	var querys []xcontext.QueryTuple
+	for _, subTable := range subTables {
+		segment := segments[subTable]
+		buf := sqlparser.NewTrackedBuffer(nil)
+		buf.Myprintf("%s into `%s`.`%s`(`%s`, `%s`) %v", insert, database, subTable, index.Column, shardKey, rows[subTable])
+		querys = append(querys, xcontext.QueryTuple{
+			Query:   buf.String(),
+			Backend: segment.Backend,
+			Range:   segment.Range.String(),
+			Discard: true,
+		})
+	}
+	return querys, nil
+}
+
+// buildLookupDeletes builds the querys to delete the pairs from the lookup table.
+func buildLookupDeletes(route *router.Router, database, shardKey string, index *config.LookupIndexConfig, pairs []LookupPair) ([]xcontext.QueryTuple, error) {
+	return buildLookupPairsQuerys(route, database, shardKey, index, pairs, "delete from")
+}
+
+// BuildLookupSelects builds the querys to select the pairs which exist in the lookup table.
+func BuildLookupSelects(route *router.Router, database, shardKey string, index *config.LookupIndexConfig, pairs []LookupPair) ([]xcontext.QueryTuple, error) {
+	return buildLookupPairsQuerys(route, database, shardKey, index, pairs, fmt.Sprintf("select `%s`, `%s` from", index.Column, shardKey))
+}
+
+// buildLookupPairsQuerys builds the querys on the pairs of the lookup table:
+// 'prefix `db`.`sub` where (col, key) in ((..), (..))'.
+func buildLookupPairsQuerys(route *router.Router, database, shardKey string, index *config.LookupIndexConfig, pairs []LookupPair, prefix string) ([]xcontext.QueryTuple, error) {
+	segments, rows, subTables, err := lookupPairs(route, database, index, pairs)
+	if err != nil {
+		return nil, err
+	}
+
+	var querys []xcontext.QueryTuple
+	for _, subTable := range subTables {
+		segment := segments[subTable]
+		tuples := make(sqlparser.ValTuple, 0, len(rows[subTable]))
+		for _, row := range rows[subTable] {
+			tuples = append(tuples, row)
+		}
+		buf := sqlparser.NewTrackedBuffer(nil)
+		buf.Myprintf("%s `%s`.`%s` where (`%s`, `%s`) in %v", prefix, database, subTable, index.Column, shardKey, tuples)
+		querys = append(querys, xcontext.QueryTuple{
+			Query:   buf.String(),
+			Backend: segment.Backend,
+			Range:   segment.Range.String(),
+			Discard: true,
+		})
+	}
+	return querys, nil
+}
+
+// lookupTask maintains the lookup indexes of the rows to change. The pairs are read from the
+// rows by the reads, which are executed in the transaction by the executor before the change,
+// then the build returns the querys maintaining the lookup tables by the pairs.
+type lookupTask struct {
+	// reads select the shard key and the columns of the indexes in order.
+	reads   []xcontext.QueryTuple
+	indexes []*config.LookupIndexConfig
+	build   func(keys []*sqlparser.SQLVal, pairs [][]LookupPair) ([]xcontext.QueryTuple, error)
+}
+
+// lookupReads returns the reads of the tasks in order.
+func lookupReads(tasks []*lookupTask) []xcontext.QueryTuple {
+	var reads []xcontext.QueryTuple
+	for _, task := range tasks {
+		reads = append(reads, task.reads...)
+	}
+	return reads
+}
+
+// buildLookupTasks returns the querys built by the tasks, the results are of the reads in order.
+func buildLookupTasks(tasks []*lookupTask, results []*sqltypes.Result) ([]xcontext.QueryTuple, error) {
+	var querys []xcontext.QueryTuple
+	for _, task := range tasks {
+		n := len(task.reads)
+		if len(results) < n {
+			return nil, errors.Errorf("planner.lookup.reads[%d].results[%d].mismatch", n, len(results))
+		}
+		keys, pairs, err := readLookupPairs(task.reads, results[:n], task.indexes)
+		if err != nil {
+			return nil, err
+		}
+		results = results[n:]
+		built, err := task.build(keys, pairs)
+		if err != nil {
+			return nil, err
+		}
+		querys = append(querys, built...)
+	}
+	return querys, nil
+}
+
+// readLookupPairs reads the pairs of the lookup indexes from the results of the querys,
+// the querys select the shard key and the columns of the indexes in order.
+// It returns the shard keys of the rows and the pairs of every index, the pairs of the NULL values are skipped.
+func readLookupPairs(querys []xcontext.QueryTuple, results []*sqltypes.Result, indexes []*config.LookupIndexConfig) ([]*sqlparser.SQLVal, [][]LookupPair, error) {
+	var keys []*sqlparser.SQLVal
+	pairs := make([][]LookupPair, len(indexes))
+	for k, qr := range results {
+		for _, row := range qr.Rows {
+			if len(row) != len(indexes)+1 {
+				return nil, nil, errors.Errorf("planner.lookup.query[%s].columns.mismatch", querys[k].Query)
+			}
+			if row[0].IsNull() {
+				continue
+			}
+			key := router.SQLValFromValue(row[0])
+			keys = append(keys, key)
+			for i := range indexes {
+				if row[i+1].IsNull() {
+					continue
+				}
+				pairs[i] = append(pairs[i], LookupPair{Value: router.SQLValFromValue(row[i+1]), Key: key})
+			}
+		}
+	}
+	return keys, pairs, nil
+}
+
+// pruneQuerys returns the querys of the routes kept, all if the keep is nil.
+func pruneQuerys(querys []xcontext.QueryTuple, keep []bool) []xcontext.QueryTuple {
+	if keep == nil {
+		return querys
+	}
+	res := make([]xcontext.QueryTuple, 0, len(querys))
+	for i, query := range querys {
+		if i < len(keep) && keep[i] {
+			res = append(res, query)
+		}
+	}
+	return res
+}
+
+// hasUniqueLookup returns true if any of the lookup indexes is the unique global index.
+func hasUniqueLookup(indexes []*config.LookupIndexConfig) bool {
+	for _, index := range indexes {
+		if index.Unique {
+			return true
+		}
+	}
+	return false
+}
+
+// lookupSelectExprs returns 'shardkey, col1, col2, ...' to read the pairs of the lookup indexes.
+func lookupSelectExprs(shardKey string, indexes []*config.LookupIndexConfig) string {
+	exprs := fmt.Sprintf("`%s`", shardKey)
+	for _, index := range indexes {
+		exprs += fmt.Sprintf(", `%s`", index.Column)
+	}
+	return exprs
+}
+
+// lookupValue returns the value of the lookup column, nil if NULL.
+func lookupValue(column string, expr sqlparser.Expr) (*sqlparser.SQLVal, error) {
+	switch expr := expr.(type) {
+	case *sqlparser.SQLVal:
+		return expr, nil
+	case *sqlparser.NullVal:
+		return nil, nil
+	}
+	return nil, errors.Errorf("unsupported: lookup.index.column[%s].type.canot.be[%T]", column, expr)
+}
@@ -0,0 +1,267 @@
+/*
+ * NeoDB
+ *
+ * Copyright 2021-2030 The NeoDB Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package planner
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/sealdb/neodb/config"
+	"github.com/sealdb/neodb/router"
+	"github.com/sealdb/neodb/xcontext"
+
+	"github.com/sealdb/mysqlstack/sqlparser"
+	querypb "github.com/sealdb/mysqlstack/sqlparser/depends/query"
+	"github.com/sealdb/mysqlstack/sqlparser/depends/sqltypes"
+	"github.com/sealdb/mysqlstack/xlog"
+	"github.com/stretchr/testify/assert"
+)
+
+// mockLookupRouter returns the router with the table L sharded by id and its lookup table LN sharded by name.
+func mockLookupRouter(t *testing.T, log *xlog.Log, unique bool) (*router.Router, func()) {
+	route, cleanup := router.MockNewRouter(log)
+	err := route.CreateDatabase("sbtest")
+	assert.Nil(t, err)
+	err = route.AddForTest("sbtest",
+		&config.TableConfig{
+			Name:          "L",
+			ShardType:     "HASH",
+			ShardKey:      "id",
+			LookupIndexes: []*config.LookupIndexConfig{{Name: "LN", Column: "name", Unique: unique}},
+			Partitions: []*config.PartitionConfig{
+				{Table: "L0", Segment: "0-2048", Backend: "backend1"},
+				{Table: "L1", Segment: "2048-4096", Backend: "backend2"},
+			},
+		},
+		&config.TableConfig{
+			Name:      "LN",
+			ShardType: "HASH",
+			ShardKey:  "name",
+			Partitions: []*config.PartitionConfig{
+				{Table: "LN0", Segment: "0-2048", Backend: "backend1"},
+				{Table: "LN1", Segment: "2048-4096", Backend: "backend2"},
+			},
+		})
+	assert.Nil(t, err)
+	return route, cleanup
+}
+
+// lookupSubTable returns the sub table of LN which the value routes to.
+func lookupSubTable(t *testing.T, route *router.Router, val *sqlparser.SQLVal) string {
+	segments, err := route.Lookup("sbtest", "LN", val, val)
+	assert.Nil(t, err)
+	return segments[0].Table
+}
+
+// lookupQuerys returns the querys which maintain the lookup tables.
+func lookupQuerys(querys []xcontext.QueryTuple) []string {
+	var res []string
+	for _, q := range querys {
+		if q.Discard {
+			res = append(res, q.Query)
+		}
+	}
+	return res
+}
+
+func TestInsertPlanLookupIndex(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	route, cleanup := mockLookupRouter(t, log, false)
+	defer cleanup()
+
+	subA := lookupSubTable(t, route, sqlparser.NewStrVal([]byte("a")))
+	query := "insert into sbtest.L(id, name) values(1, 'a'), (2, null), (3, 'a'), (1, 'a')"
+	node, err := sqlparser.Parse(query)
+	assert.Nil(t, err)
+	plan := NewInsertPlan(log, "sbtest", query, node.(*sqlparser.Insert), route)
+	err = plan.Build()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"insert ignore into `sbtest`.`" + subA + "`(`name`, `id`) values ('a', 1), ('a', 3)"}, lookupQuerys(plan.Querys))
+	assert.False(t, plan.ForceXA)
+
+	// Errors.
+	querys := []string{
+		"insert into sbtest.L(id, name) values(1, concat('a', 'b'))",
+		"insert into sbtest.L(id, name) values(1, 'a') on duplicate key update name='b'",
+	}
+	results := []string{
+		"unsupported: lookup.index.column[name].type.canot.be[*sqlparser.FuncExpr]",
+		"unsupported: cannot.update.lookup.index.column[name]",
+	}
+	for i, query := range querys {
+		node, err := sqlparser.Parse(query)
+		assert.Nil(t, err)
+		plan := NewInsertPlan(log, "sbtest", query, node.(*sqlparser.Insert), route)
+		err = plan.Build()
+		assert.EqualError(t, err, results[i])
+	}
+}
+
+// lookupResults returns the results of the lookup reads, the rows of L0 are (1, 'a') and (2, NULL).
+func lookupResults(reads []xcontext.QueryTuple) []*sqltypes.Result {
+	var results []*sqltypes.Result
+	for _, read := range reads {
+		qr := &sqltypes.Result{}
+		if strings.Contains(read.Query, "`L0`") {
+			qr.Rows = [][]sqltypes.Value{
+				{sqltypes.MakeTrusted(querypb.Type_INT64, []byte("1")), sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("a"))},
+				{sqltypes.MakeTrusted(querypb.Type_INT64, []byte("2")), sqltypes.NULL},
+			}
+		}
+		results = append(results, qr)
+	}
+	return results
+}
+
+func TestDeletePlanLookupIndex(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	route, cleanup := mockLookupRouter(t, log, false)
+	defer cleanup()
+
+	subA := lookupSubTable(t, route, sqlparser.NewStrVal([]byte("a")))
+
+	// Delete the matched rows.
+	{
+		query := "delete from sbtest.L where age > 10 limit 5"
+		node, err := sqlparser.Parse(query)
+		assert.Nil(t, err)
+		plan := NewDeletePlan(log, "sbtest", query, node.(*sqlparser.Delete), route)
+		err = plan.Build()
+		assert.Nil(t, err)
+		assert.Nil(t, lookupQuerys(plan.Querys))
+
+		reads := plan.Lookups()
+		var got []string
+		for _, read := range reads {
+			got = append(got, read.Query)
+		}
+		assert.Equal(t, []string{
+			"select `id`, `name` from `sbtest`.`L0` where sbtest.L0.age > 10 limit 5",
+			"select `id`, `name` from `sbtest`.`L1` where sbtest.L1.age > 10 limit 5",
+		}, got)
+		err = plan.BuildLookups(lookupResults(reads))
+		assert.Nil(t, err)
+		assert.Nil(t, plan.Lookups())
+		assert.Equal(t, []string{"delete from `sbtest`.`" + subA + "` where (`name`, `id`) in (('a', 1))"}, lookupQuerys(plan.Querys))
+
+		// The results must match the reads.
+		node, err = sqlparser.Parse(query)
+		assert.Nil(t, err)
+		plan = NewDeletePlan(log, "sbtest", query, node.(*sqlparser.Delete), route)
+		err = plan.Build()
+		assert.Nil(t, err)
+		err = plan.BuildLookups(lookupResults(reads[:1]))
+		assert.NotNil(t, err)
+	}
+
+	// Delete all the rows.
+	{
+		query := "delete from sbtest.L"
+		node, err := sqlparser.Parse(query)
+		assert.Nil(t, err)
+		plan := NewDeletePlan(log, "sbtest", query, node.(*sqlparser.Delete), route)
+		err = plan.Build()
+		assert.Nil(t, err)
+		assert.Nil(t, plan.Lookups())
+		assert.Equal(t, []string{"delete from `sbtest`.`LN0`", "delete from `sbtest`.`LN1`"}, lookupQuerys(plan.Querys))
+	}
+}
+
+func TestUpdatePlanLookupIndex(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	route, cleanup := mockLookupRouter(t, log, false)
+	defer cleanup()
+
+	subA := lookupSubTable(t, route, sqlparser.NewStrVal([]byte("a")))
+	subB := lookupSubTable(t, route, sqlparser.NewStrVal([]byte("b")))
+
+	// The pairs move to the new value.
+	{
+		query := "update sbtest.L set name='b' where age > 10"
+		node, err := sqlparser.Parse(query)
+		assert.Nil(t, err)
+		plan := NewUpdatePlan(log, "sbtest", query, node.(*sqlparser.Update), route)
+		err = plan.Build()
+		assert.Nil(t, err)
+		err = plan.BuildLookups(lookupResults(plan.Lookups()))
+		assert.Nil(t, err)
+		assert.Equal(t, []string{
+			"delete from `sbtest`.`" + subA + "` where (`name`, `id`) in (('a', 1))",
+			"insert ignore into `sbtest`.`" + subB + "`(`name`, `id`) values ('b', 1), ('b', 2)",
+		}, lookupQuerys(plan.Querys))
+	}
+
+	// Set to NULL.
+	{
+		query := "update sbtest.L set name=null where age > 10"
+		node, err := sqlparser.Parse(query)
+		assert.Nil(t, err)
+		plan := NewUpdatePlan(log, "sbtest", query, node.(*sqlparser.Update), route)
+		err = plan.Build()
+		assert.Nil(t, err)
+		err = plan.BuildLookups(lookupResults(plan.Lookups()))
+		assert.Nil(t, err)
+		assert.Equal(t, []string{
+			"delete from `sbtest`.`" + subA + "` where (`name`, `id`) in (('a', 1))",
+		}, lookupQuerys(plan.Querys))
+	}
+
+	// The lookup column is not updated.
+	{
+		query := "update sbtest.L set age=1 where name='a'"
+		node, err := sqlparser.Parse(query)
+		assert.Nil(t, err)
+		plan := NewUpdatePlan(log, "sbtest", query, node.(*sqlparser.Update), route)
+		err = plan.Build()
+		assert.Nil(t, err)
+		assert.Nil(t, plan.Lookups())
+		assert.Nil(t, lookupQuerys(plan.Querys))
+	}
+
+	// Unsupported value.
+	{
+		query := "update sbtest.L set name=concat(name, 'x') where age > 10"
+		node, err := sqlparser.Parse(query)
+		assert.Nil(t, err)
+		plan := NewUpdatePlan(log, "sbtest", query, node.(*sqlparser.Update), route)
+		err = plan.Build()
+		assert.EqualError(t, err, "unsupported: lookup.index.column[name].type.canot.be[*sqlparser.FuncExpr]")
+	}
+}
+
+func TestInsertPlanUniqueGlobalIndex(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	route, cleanup := mockLookupRouter(t, log, true)
+	defer cleanup()
+
+	// The duplicate value fails the insert.
+	subA := lookupSubTable(t, route, sqlparser.NewStrVal([]byte("a")))
+	query := "insert into sbtest.L(id, name) values(1, 'a'), (2, null)"
+	node, err := sqlparser.Parse(query)
+	assert.Nil(t, err)
+	plan := NewInsertPlan(log, "sbtest", query, node.(*sqlparser.Insert), route)
+	err = plan.Build()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"insert into `sbtest`.`" + subA + "`(`name`, `id`) values ('a', 1)"}, lookupQuerys(plan.Querys))
+	assert.True(t, plan.ForceXA)
+
+	// Errors.
+	querys := []string{
+		"insert ignore into sbtest.L(id, name) values(1, 'a')",
+		"replace into sbtest.L(id, name) values(1, 'a')",
+		"insert into sbtest.L(id, name) values(1, 'a') on duplicate key update age=1",
+	}
+	for _, query := range querys {
+		node, err := sqlparser.Parse(query)
+		assert.Nil(t, err)
+		plan := NewInsertPlan(log, "sbtest", query, node.(*sqlparser.Insert), route)
+		err = plan.Build()
+		assert.EqualError(t, err, "unsupported: replace.ignore.or.on.duplicate.with.unique.global.index[LN]", query)
+	}
+}
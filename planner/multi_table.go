@@ -0,0 +1,182 @@
+/*
+ * NeoDB
+ *
+ * Copyright 2021-2030 The NeoDB Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package planner
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sealdb/neodb/config"
+	"github.com/sealdb/neodb/router"
+	"github.com/sealdb/neodb/xcontext"
+
+	"github.com/pkg/errors"
+	"github.com/sealdb/mysqlstack/sqlparser"
+	querypb "github.com/sealdb/mysqlstack/sqlparser/depends/query"
+	"github.com/sealdb/mysqlstack/sqlparser/depends/sqltypes"
+)
+
+// multiTarget is the table changed by the multi-table delete or update.
+type multiTarget struct {
+	expr   *sqlparser.AliasedTableExpr
+	name   string
+	config *config.TableConfig
+	// keys are the columns identifying the rows, the primary key and the shard key.
+	keys []string
+}
+
+// multiTables returns the tables of the table refs by their aliases or names,
+// the stmt is the statement name used in the errors.
+func multiTables(r *router.Router, database string, refs sqlparser.TableExprs, stmt string) (map[string]*multiTarget, error) {
+	tables := make(map[string]*multiTarget)
+	err := sqlparser.Walk(func(n sqlparser.SQLNode) (kontinue bool, err error) {
+		expr, ok := n.(*sqlparser.AliasedTableExpr)
+		if !ok {
+			return true, nil
+		}
+		tableName, ok := expr.Expr.(sqlparser.TableName)
+		if !ok {
+			return false, errors.Errorf("unsupported: derived.table.in.multi-table.%s", stmt)
+		}
+		db := database
+		if !tableName.Qualifier.IsEmpty() {
+			db = tableName.Qualifier.String()
+		}
+		conf, err := r.TableConfig(db, tableName.Name.String())
+		if err != nil {
+			return false, err
+		}
+		name := expr.As.String()
+		if name == "" {
+			name = tableName.Name.String()
+		}
+		tables[name] = &multiTarget{expr: expr, name: name, config: conf}
+		return false, nil
+	}, refs)
+	if err != nil {
+		return nil, err
+	}
+	return tables, nil
+}
+
+// databaseOf returns the database of the target table.
+func databaseOf(target *multiTarget, database string) string {
+	if tableName, ok := target.expr.Expr.(sqlparser.TableName); ok && !tableName.Qualifier.IsEmpty() {
+		return tableName.Qualifier.String()
+	}
+	return database
+}
+
+// multiFields returns the querys fetching the fields of the targets which cannot be changed
+// by the pushed down query, the action is the change used in the errors, such as 'delete.from'.
+func multiFields(r *router.Router, database string, targets []*multiTarget, action string) ([]xcontext.QueryTuple, error) {
+	var querys []xcontext.QueryTuple
+	for _, target := range targets {
+		if target.config.ShardKey == "" {
+			return nil, errors.Errorf("unsupported: cross-shard.multi-table.%s.the.%s.table[%s]", action, strings.ToLower(target.config.ShardType), target.config.Name)
+		}
+		db := databaseOf(target, database)
+		segments, err := r.Lookup(db, target.config.Name, nil, nil)
+		if err != nil {
+			return nil, err
+		}
+		querys = append(querys, xcontext.QueryTuple{
+			Query:   fmt.Sprintf("select * from %s.%s where 1 != 1", db, segments[0].Table),
+			Backend: segments[0].Backend,
+			Range:   segments[0].Range.String(),
+		})
+	}
+	return querys, nil
+}
+
+// multiKeys sets the keys of the targets by their fields, the rows are identified by the
+// primary key, or the not null unique key, the target without them is refused.
+// Returns the select exprs of the keys of every target.
+func multiKeys(targets []*multiTarget, fields [][]*querypb.Field, action string) ([]sqlparser.SelectExprs, error) {
+	exprs := make([]sqlparser.SelectExprs, len(targets))
+	for i, target := range targets {
+		idxs := uniqueKeyIdxs(fields[i])
+		if len(idxs) == 0 {
+			return nil, errors.Errorf("unsupported: cannot.%s.table[%s].without.primary.or.unique.key", action, target.config.Name)
+		}
+		target.keys = router.SplitShardKey(target.config.ShardKey)
+		for _, idx := range idxs {
+			found := false
+			for _, key := range target.keys {
+				found = found || strings.EqualFold(key, fields[i][idx].Name)
+			}
+			if !found {
+				target.keys = append(target.keys, fields[i][idx].Name)
+			}
+		}
+		for _, key := range target.keys {
+			exprs[i] = append(exprs[i], &sqlparser.AliasedExpr{Expr: &sqlparser.ColName{
+				Name:      sqlparser.NewColIdent(key),
+				Qualifier: sqlparser.TableName{Name: sqlparser.NewTableIdent(target.name)},
+			}})
+		}
+	}
+	return exprs, nil
+}
+
+// keyFields returns the fields of the keys of the target, the indexes of the shard keys
+// and the indexes of all the keys, used to build the where clauses by buildKeyWheres.
+func (target *multiTarget) keyFields() ([]*querypb.Field, []int, []int) {
+	n := len(target.keys)
+	fields := make([]*querypb.Field, n)
+	keyIdxs := make([]int, len(router.SplitShardKey(target.config.ShardKey)))
+	uniqIdxs := make([]int, n)
+	for i, key := range target.keys {
+		fields[i] = &querypb.Field{Name: key}
+		uniqIdxs[i] = i
+		if i < len(keyIdxs) {
+			keyIdxs[i] = i
+		}
+	}
+	return fields, keyIdxs, uniqIdxs
+}
+
+// targetRows returns the n columns from the offset of the rows, whose first columns are the
+// keys of the target. The joined rows may contain the same target row more than once, only
+// the first is kept, and the rows with the NULL keys which aren't matched are skipped.
+func (target *multiTarget) targetRows(rows [][]sqltypes.Value, offset, n int) [][]sqltypes.Value {
+	seen := make(map[string]struct{})
+	var res [][]sqltypes.Value
+	for _, row := range rows {
+		keys := row[offset : offset+len(target.keys)]
+		hasNull := false
+		for _, key := range keys {
+			hasNull = hasNull || key.IsNull()
+		}
+		if hasNull {
+			continue
+		}
+		k := rowKey(keys)
+		if _, ok := seen[k]; ok {
+			continue
+		}
+		seen[k] = struct{}{}
+		res = append(res, row[offset:offset+n])
+	}
+	return res
+}
+
+// rowKey returns the string identifying the values.
+func rowKey(vals []sqltypes.Value) string {
+	var buf strings.Builder
+	for _, val := range vals {
+		if val.IsNull() {
+			buf.WriteByte(1)
+		} else {
+			buf.WriteString(val.String())
+		}
+		buf.WriteByte(0)
+	}
+	return buf.String()
+}
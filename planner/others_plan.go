@@ -94,7 +94,7 @@ func (p *OthersPlan) Build() error {
 				Range:   segment.Segment,
 			}
 			p.Querys = append(p.Querys, tuple)
-		case router.MethodTypeHash, router.MethodTypeList:
+		case router.MethodTypeHash, router.MethodTypeList, router.MethodTypeRange, router.MethodTypeInterval:
 			segments := route.Partitions
 			for _, segment := range segments {
 				newNode.Tables[0].Name = sqlparser.NewTableIdent(segment.Table)
@@ -11,6 +11,7 @@ package planner
 
 import (
 	"errors"
+	"fmt"
 	"strings"
 
 	"github.com/sealdb/neodb/planner/builder"
@@ -61,11 +62,13 @@ func NewSelectPlan(log *xlog.Log, database string, query string, node *sqlparser
 }
 
 // Build used to build distributed querys.
-// For now, we don't support subquery in select.
+// For now, we only support the IN/EXISTS subqueries in the WHERE clause and the derived tables.
 func (p *SelectPlan) Build() error {
 	var err error
 	// Check subquery.
-	if hasSubquery(p.node) {
+	node := p.node
+	if hasSubquery(node.SelectExprs) || hasSubquery(node.GroupBy) ||
+		(node.Having != nil && hasSubquery(node.Having)) || hasSubquery(node.OrderBy) {
 		return errors.New("unsupported: subqueries.in.select")
 	}
 	p.Root, err = builder.BuildNode(p.log, p.router, p.database, p.node)
@@ -97,11 +100,32 @@ func (p *SelectPlan) JSON() string {
 		Aggregate   []string              `json:",omitempty"`
 		GatherMerge []string              `json:",omitempty"`
 		HashGroupBy []string              `json:",omitempty"`
+		Compute     []string              `json:",omitempty"`
+		Having      []string              `json:",omitempty"`
+		Distinct    []string              `json:",omitempty"`
 		Limit       *limit                `json:",omitempty"`
+		SemiJoin    []string              `json:",omitempty"`
+		Derived     string                `json:",omitempty"`
+	}
+
+	// The subqueries are executed before the outer query.
+	root := p.Root
+	var semiJoin []string
+	if s, ok := root.(*builder.SemiJoinNode); ok {
+		for _, sub := range s.Subqueries {
+			semiJoin = append(semiJoin, fmt.Sprintf("%s :%s", sub.Type, sub.Name))
+		}
+		root = s.Outer
+	}
+
+	// The derived table is materialized in the proxy.
+	var derived string
+	if d, ok := root.(*builder.DerivedNode); ok {
+		derived = d.Alias()
 	}
 
 	var joins *join
-	if j, ok := p.Root.(*builder.JoinNode); ok {
+	if j, ok := root.(*builder.JoinNode); ok {
 		joins = &join{}
 		switch j.Strategy {
 		case builder.Cartesian:
@@ -110,6 +134,8 @@ func (p *SelectPlan) JSON() string {
 			joins.Strategy = "Sort Merge Join"
 		case builder.NestLoop:
 			joins.Strategy = "Nested Loop Join"
+		case builder.HashJoin:
+			joins.Strategy = "Hash Join"
 		}
 		if j.IsLeftJoin {
 			joins.Type = "LEFT JOIN"
@@ -126,8 +152,11 @@ func (p *SelectPlan) JSON() string {
 	var aggregate []string
 	var hashGroup []string
 	var gatherMerge []string
+	var distinct []string
+	var compute []string
+	var having []string
 	var lim *limit
-	for _, sub := range p.Root.Children() {
+	for _, sub := range root.Children() {
 		switch sub.Type() {
 		case builder.ChildTypeAggregate:
 			plan := sub.(*builder.AggregatePlan)
@@ -146,6 +175,12 @@ func (p *SelectPlan) JSON() string {
 				}
 				gatherMerge = append(gatherMerge, field)
 			}
+		case builder.ChildTypeProject:
+			compute = append(compute, sub.(*builder.ProjectPlan).Computed()...)
+		case builder.ChildTypeHaving:
+			having = append(having, sub.(*builder.HavingPlan).Havings...)
+		case builder.ChildTypeDistinct:
+			distinct = sub.(*builder.DistinctPlan).Fields
 		case builder.ChildTypeLimit:
 			plan := sub.(*builder.LimitPlan)
 			lim = &limit{Offset: plan.Offset, Limit: plan.Limit}
@@ -159,7 +194,12 @@ func (p *SelectPlan) JSON() string {
 		Aggregate:   aggregate,
 		GatherMerge: gatherMerge,
 		HashGroupBy: hashGroup,
+		Compute:     compute,
+		Having:      having,
+		Distinct:    distinct,
 		Limit:       lim,
+		SemiJoin:    semiJoin,
+		Derived:     derived,
 	}
 	out, err := common.ToJSONString(exp, false, "", "\t")
 	if err != nil {
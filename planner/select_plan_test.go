@@ -258,12 +258,12 @@ func TestSelectPlan(t *testing.T) {
 	"Project": "tmp, b, sum(id), count(id)",
 	"Partitions": [
 		{
-			"Query": "select id as tmp, b, id as ` + "`sum(id)`" + `, id as ` + "`count(id)`" + ` from sbtest.B0 as B group by b order by b asc",
+			"Query": "select id as tmp, b, id as ` + "`sum(id)`" + `, id as ` + "`count(id)`" + ` from sbtest.B0 as B order by b asc",
 			"Backend": "backend1",
 			"Range": "[0-512)"
 		},
 		{
-			"Query": "select id as tmp, b, id as ` + "`sum(id)`" + `, id as ` + "`count(id)`" + ` from sbtest.B1 as B group by b order by b asc",
+			"Query": "select id as tmp, b, id as ` + "`sum(id)`" + `, id as ` + "`count(id)`" + ` from sbtest.B1 as B order by b asc",
 			"Backend": "backend2",
 			"Range": "[512-4096)"
 		}
@@ -473,13 +473,68 @@ func TestSelectPlan(t *testing.T) {
 	}
 }
 
+func TestSelectPlanRange(t *testing.T) {
+	results := []string{
+		`{
+	"RawQuery": "select * from RG where id>=150 and id<250",
+	"Project": "*",
+	"Partitions": [
+		{
+			"Query": "select * from sbtest.RG_0001 as RG where id >= 150 and id < 250",
+			"Backend": "backend2",
+			"Range": "[100-200)"
+		},
+		{
+			"Query": "select * from sbtest.RG_0002 as RG where id >= 150 and id < 250",
+			"Backend": "backend3",
+			"Range": "[200-MAXVALUE)"
+		}
+	]
+}`,
+		`{
+	"RawQuery": "select a from L where id between 2 and 5",
+	"Project": "a",
+	"Partitions": [
+		{
+			"Query": "select a from sbtest.L_0001 as L where id between 2 and 5",
+			"Backend": "backend2",
+			"Range": ""
+		}
+	]
+}`,
+	}
+	querys := []string{
+		"select * from RG where id>=150 and id<250",
+		"select a from L where id between 2 and 5",
+	}
+
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	database := "sbtest"
+
+	route, cleanup := router.MockNewRouter(log)
+	defer cleanup()
+
+	err := route.CreateDatabase(database)
+	assert.Nil(t, err)
+	err = route.AddForTest(database, router.MockTableRangeConfig(), router.MockTableListConfig())
+	assert.Nil(t, err)
+	for i, query := range querys {
+		node, err := sqlparser.Parse(query)
+		assert.Nil(t, err)
+		plan := NewSelectPlan(log, database, query, node.(*sqlparser.Select), route)
+		err = plan.Build()
+		assert.Nil(t, err)
+		assert.Equal(t, results[i], plan.JSON())
+	}
+}
+
 func TestSelectUnsupportedPlan(t *testing.T) {
 	querys := []string{
-		"select * from A as A1 where id in (select id from B)",
+		"select * from A as A1 where exists (select id from B where B.id=A1.id)",
 		"select A.*,(select b.str from b where A.id=B.id) str from A",
 	}
 	results := []string{
-		"unsupported: subqueries.in.select",
+		"unsupported: correlated.subquery.column.'A1.id'",
 		"unsupported: subqueries.in.select",
 	}
 
@@ -507,3 +562,228 @@ func TestSelectUnsupportedPlan(t *testing.T) {
 		}
 	}
 }
+
+func TestSelectPlanSemiJoin(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	database := "sbtest"
+
+	route, cleanup := router.MockNewRouter(log)
+	defer cleanup()
+
+	err := route.CreateDatabase(database)
+	assert.Nil(t, err)
+	err = route.AddForTest(database, router.MockTableMConfig(), router.MockTableBConfig())
+	assert.Nil(t, err)
+
+	query := "select * from A where id = 1 and a not in (select a from B where id = 1)"
+	node, err := sqlparser.Parse(query)
+	assert.Nil(t, err)
+	plan := NewSelectPlan(log, database, query, node.(*sqlparser.Select), route)
+	err = plan.Build()
+	assert.Nil(t, err)
+	want := `{
+	"RawQuery": "select * from A where id = 1 and a not in (select a from B where id = 1)",
+	"Project": "*",
+	"Partitions": [
+		{
+			"Query": "select a from sbtest.B1 as B where id = 1",
+			"Backend": "backend2",
+			"Range": "[512-4096)"
+		},
+		{
+			"Query": "select * from sbtest.A6 as A where id = 1 and a not in ::__sq1",
+			"Backend": "backend6",
+			"Range": "[512-4096)"
+		}
+	],
+	"SemiJoin": [
+		"NOT IN :__sq1"
+	]
+}`
+	assert.Equal(t, want, plan.JSON())
+}
+
+func TestSelectPlanDerived(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	database := "sbtest"
+
+	route, cleanup := router.MockNewRouter(log)
+	defer cleanup()
+
+	err := route.CreateDatabase(database)
+	assert.Nil(t, err)
+	err = route.AddForTest(database, router.MockTableBConfig())
+	assert.Nil(t, err)
+
+	query := "select t.a, count(*) from (select a, b from B) as t where t.b > 1 group by t.a"
+	node, err := sqlparser.Parse(query)
+	assert.Nil(t, err)
+	plan := NewSelectPlan(log, database, query, node.(*sqlparser.Select), route)
+	err = plan.Build()
+	assert.Nil(t, err)
+	want := `{
+	"RawQuery": "select t.a, count(*) from (select a, b from B) as t where t.b > 1 group by t.a",
+	"Project": "a, count(*)",
+	"Partitions": [
+		{
+			"Query": "select a, b from sbtest.B0 as B where b > 1",
+			"Backend": "backend1",
+			"Range": "[0-512)"
+		},
+		{
+			"Query": "select a, b from sbtest.B1 as B where b > 1",
+			"Backend": "backend2",
+			"Range": "[512-4096)"
+		}
+	],
+	"Aggregate": [
+		"count(*)"
+	],
+	"HashGroupBy": [
+		"a"
+	],
+	"Derived": "t"
+}`
+	assert.Equal(t, want, plan.JSON())
+}
+
+func TestSelectPlanDistinct(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	database := "sbtest"
+
+	route, cleanup := router.MockNewRouter(log)
+	defer cleanup()
+
+	err := route.CreateDatabase(database)
+	assert.Nil(t, err)
+	err = route.AddForTest(database, router.MockTableBConfig())
+	assert.Nil(t, err)
+
+	query := "select distinct a, b as c from B order by a"
+	node, err := sqlparser.Parse(query)
+	assert.Nil(t, err)
+	plan := NewSelectPlan(log, database, query, node.(*sqlparser.Select), route)
+	err = plan.Build()
+	assert.Nil(t, err)
+	want := `{
+	"RawQuery": "select distinct a, b as c from B order by a",
+	"Project": "a, c",
+	"Partitions": [
+		{
+			"Query": "select distinct a, b as c from sbtest.B0 as B order by a asc",
+			"Backend": "backend1",
+			"Range": "[0-512)"
+		},
+		{
+			"Query": "select distinct a, b as c from sbtest.B1 as B order by a asc",
+			"Backend": "backend2",
+			"Range": "[512-4096)"
+		}
+	],
+	"GatherMerge": [
+		"a"
+	],
+	"Distinct": [
+		"a",
+		"c"
+	]
+}`
+	assert.Equal(t, want, plan.JSON())
+}
+
+func TestSelectPlanCompute(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	database := "sbtest"
+
+	route, cleanup := router.MockNewRouter(log)
+	defer cleanup()
+
+	err := route.CreateDatabase(database)
+	assert.Nil(t, err)
+	err = route.AddForTest(database, router.MockTableBConfig())
+	assert.Nil(t, err)
+
+	query := "select a, sum(b)/count(b) as x from B group by a"
+	node, err := sqlparser.Parse(query)
+	assert.Nil(t, err)
+	plan := NewSelectPlan(log, database, query, node.(*sqlparser.Select), route)
+	err = plan.Build()
+	assert.Nil(t, err)
+	want := `{
+	"RawQuery": "select a, sum(b)/count(b) as x from B group by a",
+	"Project": "a, x",
+	"Partitions": [
+		{
+			"Query": "select a, sum(b), count(b) from sbtest.B0 as B group by a order by a asc",
+			"Backend": "backend1",
+			"Range": "[0-512)"
+		},
+		{
+			"Query": "select a, sum(b), count(b) from sbtest.B1 as B group by a order by a asc",
+			"Backend": "backend2",
+			"Range": "[512-4096)"
+		}
+	],
+	"Aggregate": [
+		"sum(b)",
+		"count(b)"
+	],
+	"HashGroupBy": [
+		"a"
+	],
+	"Compute": [
+		"x"
+	]
+}`
+	assert.Equal(t, want, plan.JSON())
+}
+
+func TestSelectPlanHaving(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	database := "sbtest"
+
+	route, cleanup := router.MockNewRouter(log)
+	defer cleanup()
+
+	err := route.CreateDatabase(database)
+	assert.Nil(t, err)
+	err = route.AddForTest(database, router.MockTableBConfig())
+	assert.Nil(t, err)
+
+	query := "select a, sum(b) from B group by a having count(b) > 1 order by 2 desc"
+	node, err := sqlparser.Parse(query)
+	assert.Nil(t, err)
+	plan := NewSelectPlan(log, database, query, node.(*sqlparser.Select), route)
+	err = plan.Build()
+	assert.Nil(t, err)
+	want := `{
+	"RawQuery": "select a, sum(b) from B group by a having count(b) > 1 order by 2 desc",
+	"Project": "a, sum(b)",
+	"Partitions": [
+		{
+			"Query": "select a, sum(b), count(b) from sbtest.B0 as B group by a",
+			"Backend": "backend1",
+			"Range": "[0-512)"
+		},
+		{
+			"Query": "select a, sum(b), count(b) from sbtest.B1 as B group by a",
+			"Backend": "backend2",
+			"Range": "[512-4096)"
+		}
+	],
+	"Aggregate": [
+		"sum(b)",
+		"count(b)"
+	],
+	"GatherMerge": [
+		"sum(b)"
+	],
+	"HashGroupBy": [
+		"a"
+	],
+	"Having": [
+		"count(b) > 1"
+	]
+}`
+	assert.Equal(t, want, plan.JSON())
+}
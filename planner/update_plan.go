@@ -10,13 +10,23 @@
 package planner
 
 import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/sealdb/neodb/config"
+	"github.com/sealdb/neodb/expression"
 	"github.com/sealdb/neodb/planner/builder"
 	"github.com/sealdb/neodb/router"
 	"github.com/sealdb/neodb/xcontext"
+	"github.com/sealdb/neodb/xparser"
 
 	"github.com/pkg/errors"
 	"github.com/sealdb/mysqlstack/sqlparser"
 	"github.com/sealdb/mysqlstack/sqlparser/depends/common"
+	querypb "github.com/sealdb/mysqlstack/sqlparser/depends/query"
+	"github.com/sealdb/mysqlstack/sqlparser/depends/sqltypes"
 	"github.com/sealdb/mysqlstack/xlog"
 )
 
@@ -48,6 +58,53 @@ type UpdatePlan struct {
 
 	// query and backend tuple
 	Querys []xcontext.QueryTuple
+
+	// Resolve is the querys to read and lock the rows whose shard key is updated,
+	// the rows are moved by BuildMoves.
+	Resolve []xcontext.QueryTuple
+
+	// Columns is the query reading the extra of the columns of the moved rows,
+	// the generated and the ON UPDATE columns are set by BuildColumns.
+	Columns []xcontext.QueryTuple
+
+	// generated are the generated columns which are left out of the moved rows,
+	// onUpdates are the ON UPDATE values of the columns not assigned by the update.
+	generated map[string]bool
+	onUpdates map[string]sqlparser.Expr
+
+	// the qualified table and the shard key of the moved rows.
+	table    sqlparser.TableName
+	shardkey string
+
+	// multi is the multiple-table update.
+	multi *xparser.MultiUpdate
+
+	// Fields is the querys fetching the fields of the targets, only set if the multi-table
+	// update cannot be pushed down, the target rows are identified by the primary keys.
+	Fields []xcontext.QueryTuple
+
+	// Root is the select plan resolving the primary keys and the new values of the target
+	// rows, built by BuildResolve with the fields.
+	Root builder.PlanNode
+
+	// the tables to update of the multi-table update, and their assignments.
+	targets []*multiTarget
+	assigns []sqlparser.UpdateExprs
+
+	// resolve is the select on the joined tables locking the target rows, its select exprs
+	// are the new values of the assignments in the order of the targets.
+	resolve string
+
+	// ForceXA is true if the querys write the unique global indexes, the rows and their
+	// lookup pairs must be in the same XA transaction even if they are on one backend.
+	ForceXA bool
+
+	// Prune prunes the routes by the lookup index values at execution, nil if the routes
+	// aren't pruned by the lookup tables.
+	Prune *builder.LookupPrune
+
+	// lookups maintain the lookup tables by the pairs of the rows to change.
+	lookups []*lookupTask
 }
 
 // NewUpdatePlan used to create UpdatePlan
@@ -63,11 +120,18 @@ func NewUpdatePlan(log *xlog.Log, database string, query string, node *sqlparser
 	}
 }
 
+// NewMultiUpdatePlan used to create the UpdatePlan of the multiple-table update.
+func NewMultiUpdatePlan(log *xlog.Log, database string, query string, node *xparser.MultiUpdate, router *router.Router) *UpdatePlan {
+	p := NewUpdatePlan(log, database, query, node.Update, router)
+	p.multi = node
+	return p
+}
+
 // analyze used to analyze the 'update' is at the support level.
 func (p *UpdatePlan) analyze() error {
 	node := p.node
 	// analyze subquery.
-	if hasSubquery(p.node) {
+	if hasSubquery(p.node) || (p.multi != nil && hasSubquery(p.multi.TableRefs)) {
 		return errors.New("unsupported: subqueries.in.update")
 	}
 	if node.Where == nil {
@@ -81,6 +145,9 @@ func (p *UpdatePlan) Build() error {
 	if err := p.analyze(); err != nil {
 		return err
 	}
+	if p.multi != nil {
+		return p.buildMultiTables()
+	}
 
 	node := p.node
 	// Database.
@@ -96,16 +163,68 @@ func (p *UpdatePlan) Build() error {
 		return err
 	}
 
-	// analyze whether update shardkey.
+	// Get the routing segments info, pruned by the lookup indexes at execution.
+	segments, prune, err := builder.LookupFromWhere(database, table, shardkey, node.Where, p.router)
+	if err != nil {
+		return err
+	}
+	p.Prune = prune
+
+	// The rows whose shard key is updated are moved to the new partitions, they are read
+	// and locked first, the move querys are built after the rows are resolved.
 	if isUpdateShardKey(node.Exprs, shardkey) {
-		return errors.New("unsupported: cannot.update.shard.key")
+		// The order by and the limit are applied by every partition, not across them.
+		if len(segments) > 1 && (len(node.OrderBy) > 0 || node.Limit != nil) {
+			return errors.New("unsupported: order.by.or.limit.in.cross-shard.shard.key.update")
+		}
+		// The new values are evaluated in the proxy once for all the rows.
+		if err := checkDeterministic(node.Exprs); err != nil {
+			return err
+		}
+		p.Columns = []xcontext.QueryTuple{{
+			Query:   fmt.Sprintf("select column_name, extra from information_schema.columns where table_schema='%s' and table_name='%s'", database, segments[0].Table),
+			Backend: segments[0].Backend,
+			Range:   segments[0].Range.String(),
+		}}
+		for _, segment := range segments {
+			buf := sqlparser.NewTrackedBuffer(nil)
+			buf.Myprintf("select * from %s.%s%v%v%v for update", database, segment.Table, node.Where, node.OrderBy, node.Limit)
+			tuple := xcontext.QueryTuple{
+				Query:   buf.String(),
+				Backend: segment.Backend,
+				Range:   segment.Range.String(),
+			}
+			p.Resolve = append(p.Resolve, tuple)
+		}
+		p.table = sqlparser.TableName{
+			Name:      sqlparser.NewTableIdent(table),
+			Qualifier: sqlparser.NewTableIdent(database),
+		}
+		p.shardkey = shardkey
+		return nil
 	}
 
-	// Get the routing segments info.
-	segments, err := builder.LookupFromWhere(database, table, shardkey, node.Where, p.router)
+	// The lookup indexes whose column is updated.
+	tableConf, err := p.router.TableConfig(database, table)
 	if err != nil {
 		return err
 	}
+	var lookups []*config.LookupIndexConfig
+	var newVals []*sqlparser.SQLVal
+	for _, index := range tableConf.LookupIndexes {
+		for _, assignment := range node.Exprs {
+			if assignment.Name.Name.EqualString(index.Column) {
+				val, err := lookupValue(index.Column, assignment.Expr)
+				if err != nil {
+					return err
+				}
+				lookups = append(lookups, index)
+				newVals = append(newVals, val)
+				break
+			}
+		}
+	}
+	var lookupReads []xcontext.QueryTuple
 
 	// Rewrite the query.
 	for _, segment := range segments {
@@ -117,10 +236,564 @@ func (p *UpdatePlan) Build() error {
 			Range:   segment.Range.String(),
 		}
 		p.Querys = append(p.Querys, tuple)
+
+		if len(lookups) > 0 {
+			buf := sqlparser.NewTrackedBuffer(nil)
+			buf.Myprintf("select %s from `%s`.`%s`%v%v%v", lookupSelectExprs(shardkey, lookups), database, segment.Table, node.Where, node.OrderBy, node.Limit)
+			lookupReads = append(lookupReads, xcontext.QueryTuple{Query: buf.String(), Backend: segment.Backend})
+		}
 	}
+
+	// Move the pairs of the updated rows to the new values in the lookup tables.
+	if len(lookups) == 0 {
+		return nil
+	}
+	p.ForceXA = hasUniqueLookup(lookups)
+	p.lookups = append(p.lookups, &lookupTask{
+		reads:   lookupReads,
+		indexes: lookups,
+		build: func(keys []*sqlparser.SQLVal, pairs [][]LookupPair) ([]xcontext.QueryTuple, error) {
+			var querys []xcontext.QueryTuple
+			for i, index := range lookups {
+				deletes, err := buildLookupDeletes(p.router, database, shardkey, index, pairs[i])
+				if err != nil {
+					return nil, err
+				}
+				querys = append(querys, deletes...)
+			}
+			for i, index := range lookups {
+				if newVals[i] == nil {
+					continue
+				}
+				newPairs := make([]LookupPair, 0, len(keys))
+				for _, key := range keys {
+					newPairs = append(newPairs, LookupPair{Value: newVals[i], Key: key})
+				}
+				inserts, err := BuildLookupInserts(p.router, database, shardkey, index, newPairs, !index.Unique)
+				if err != nil {
+					return nil, err
+				}
+				querys = append(querys, inserts...)
+			}
+			return querys, nil
+		},
+	})
 	return nil
 }
 
+// buildMultiTables used to build the multi-table update. If all the tables are co-located
+// or global, the update is pushed down to the shards. Otherwise the primary keys and the new
+// values of the target rows are resolved by the select plan first, and the targets are
+// updated by them. The shard keys cannot be updated.
+func (p *UpdatePlan) buildMultiTables() error {
+	node := p.multi
+	tables, err := multiTables(p.router, p.database, node.TableRefs, "update")
+	if err != nil {
+		return err
+	}
+
+	// The assignments are grouped by the targets.
+	idxs := make(map[*multiTarget]int)
+	hasLookups, hasGlobal := false, false
+	for _, assignment := range node.Exprs {
+		var target *multiTarget
+		if assignment.Name.Qualifier.IsEmpty() {
+			if len(tables) > 1 {
+				return errors.Errorf("unsupported: unqualified.column.'%s'.in.multi-table.update", assignment.Name.Name.String())
+			}
+			for _, table := range tables {
+				target = table
+			}
+		} else {
+			name := assignment.Name.Qualifier
+			target = tables[name.Name.String()]
+			if target == nil || (!name.Qualifier.IsEmpty() && name.Qualifier.String() != databaseOf(target, p.database)) {
+				return errors.Errorf("unsupported: unknown.column.'%s'.in.multi-table.update", sqlparser.String(assignment.Name))
+			}
+		}
+		if isUpdateShardKey(sqlparser.UpdateExprs{assignment}, target.config.ShardKey) {
+			return errors.New("unsupported: cannot.update.shard.key.in.multi-table.update")
+		}
+		for _, index := range target.config.LookupIndexes {
+			hasLookups = hasLookups || assignment.Name.Name.EqualString(index.Column)
+		}
+
+		idx, ok := idxs[target]
+		if !ok {
+			idx = len(p.targets)
+			idxs[target] = idx
+			p.targets = append(p.targets, target)
+			p.assigns = append(p.assigns, nil)
+			hasGlobal = hasGlobal || target.config.ShardType == "GLOBAL"
+		}
+		p.assigns[idx] = append(p.assigns[idx], assignment)
+	}
+	if hasGlobal {
+		// The copies of the global table must be updated by the same rows.
+		for _, table := range tables {
+			if table.config.ShardType != "GLOBAL" {
+				return errors.New("unsupported: update.of.the.global.table.joined.with.the.non-global.tables")
+			}
+		}
+	}
+
+	var vals sqlparser.SelectExprs
+	for _, assigns := range p.assigns {
+		for _, assignment := range assigns {
+			vals = append(vals, &sqlparser.AliasedExpr{Expr: assignment.Expr})
+		}
+	}
+	resolve := sqlparser.String(&sqlparser.Select{
+		SelectExprs: vals,
+		From:        node.TableRefs,
+		Where:       node.Where,
+		Lock:        sqlparser.ForUpdateStr,
+	})
+	stmt, err := sqlparser.Parse(resolve)
+	if err != nil {
+		return err
+	}
+	root, err := builder.BuildNode(p.log, p.router, p.database, stmt.(*sqlparser.Select))
+	if err != nil {
+		return err
+	}
+
+	// The update of the lookup indexed columns maintains the lookup tables by the shard keys.
+	if m, ok := root.(*builder.MergeNode); ok && !hasLookups {
+		p.Querys, err = m.GenerateDML(func(sel *sqlparser.Select) string {
+			buf := sqlparser.NewTrackedBuffer(nil)
+			buf.Myprintf("update %v%v set %v%v", node.Comments, sel.From, node.Exprs, sel.Where)
+			return buf.String()
+		})
+		return err
+	}
+
+	if p.Fields, err = multiFields(p.router, p.database, p.targets, "update"); err != nil {
+		return err
+	}
+	p.resolve = resolve
+	return nil
+}
+
+// BuildResolve used to build the Root resolving the keys and the new values of the target
+// rows by the fields of the targets fetched by the Fields querys. The rows are identified
+// by the primary key, or the not null unique key, the target without them is refused.
+func (p *UpdatePlan) BuildResolve(fields [][]*querypb.Field) error {
+	stmt, err := sqlparser.Parse(p.resolve)
+	if err != nil {
+		return err
+	}
+	sel := stmt.(*sqlparser.Select)
+	keys, err := multiKeys(p.targets, fields, "update")
+	if err != nil {
+		return err
+	}
+	vals := sel.SelectExprs
+	sel.SelectExprs = nil
+	for i, exprs := range keys {
+		sel.SelectExprs = append(sel.SelectExprs, exprs...)
+		sel.SelectExprs = append(sel.SelectExprs, vals[:len(p.assigns[i])]...)
+		vals = vals[len(p.assigns[i]):]
+	}
+	p.Root, err = builder.BuildNode(p.log, p.router, p.database, sel)
+	return err
+}
+
+// BuildTargets used to build the querys updating the target rows of the multi-table update
+// by the keys and the new values resolved by the Root. The rows of each target with the same
+// new values are updated by the single table update on the shard keys and the primary keys,
+// which maintains the lookup tables too.
+func (p *UpdatePlan) BuildTargets(res *sqltypes.Result) error {
+	p.Querys = p.Querys[:0]
+	offset := 0
+	for i, target := range p.targets {
+		n, assigns := len(target.keys), p.assigns[i]
+		rows := target.targetRows(res.Rows, offset, n+len(assigns))
+		offset += n + len(assigns)
+
+		// The rows are grouped by the new values in the order they are resolved.
+		var groups [][][]sqltypes.Value
+		groupIdxs := make(map[string]int)
+		for _, row := range rows {
+			k := rowKey(row[n:])
+			idx, ok := groupIdxs[k]
+			if !ok {
+				idx = len(groups)
+				groupIdxs[k] = idx
+				groups = append(groups, nil)
+			}
+			groups[idx] = append(groups[idx], row)
+		}
+
+		database := databaseOf(target, p.database)
+		fields, keyIdxs, uniqIdxs := target.keyFields()
+		for _, group := range groups {
+			exprs := make(sqlparser.UpdateExprs, len(assigns))
+			for j, assignment := range assigns {
+				exprs[j] = &sqlparser.UpdateExpr{
+					Name: &sqlparser.ColName{Name: assignment.Name.Name},
+					Expr: valueExpr(group[0][n+j]),
+				}
+			}
+			keyRows := make([][]sqltypes.Value, len(group))
+			for j, row := range group {
+				keyRows[j] = row[:n]
+			}
+			for _, where := range buildKeyWheres(keyRows, fields, keyIdxs, uniqIdxs) {
+				node := &sqlparser.Update{
+					Comments: p.node.Comments,
+					Table: sqlparser.TableName{
+						Name:      sqlparser.NewTableIdent(target.config.Name),
+						Qualifier: sqlparser.NewTableIdent(database),
+					},
+					Exprs: exprs,
+					Where: sqlparser.NewWhere(sqlparser.WhereClause, where),
+				}
+				plan := NewUpdatePlan(p.log, database, sqlparser.String(node), node, p.router)
+				if err := plan.Build(); err != nil {
+					return err
+				}
+				p.Querys = append(p.Querys, plan.Querys...)
+				p.ForceXA = p.ForceXA || plan.ForceXA
+				p.lookups = append(p.lookups, plan.lookups...)
+			}
+		}
+	}
+	return nil
+}
+
+// BuildColumns used to set the generated and the ON UPDATE columns by the result of the Columns
+// query, the rows are (column_name, extra) of the information_schema.columns.
+func (p *UpdatePlan) BuildColumns(res *sqltypes.Result) error {
+	p.generated = make(map[string]bool)
+	p.onUpdates = make(map[string]sqlparser.Expr)
+	for _, row := range res.Rows {
+		if len(row) < 2 {
+			return errors.Errorf("unsupported: invalid.columns.of.table[%s]", p.table.Name.String())
+		}
+		name := strings.ToLower(row[0].String())
+		extra := strings.ToLower(row[1].String())
+		if strings.Contains(extra, "virtual generated") || strings.Contains(extra, "stored generated") {
+			p.generated[name] = true
+		}
+		if idx := strings.Index(extra, "on update "); idx >= 0 {
+			expr, err := onUpdateExpr(extra[idx+len("on update "):])
+			if err != nil {
+				return err
+			}
+			p.onUpdates[name] = expr
+		}
+	}
+	return nil
+}
+
+// onUpdateExpr returns the expr of the ON UPDATE value, such as 'current_timestamp(3)'.
+func onUpdateExpr(val string) (sqlparser.Expr, error) {
+	fn := &sqlparser.FuncExpr{Name: sqlparser.NewColIdent("current_timestamp")}
+	if !strings.HasPrefix(val, "current_timestamp") {
+		return nil, errors.Errorf("unsupported: on.update.value[%s]", val)
+	}
+	if fsp := strings.TrimPrefix(val, "current_timestamp"); fsp != "" {
+		if len(fsp) < 3 || fsp[0] != '(' || fsp[len(fsp)-1] != ')' {
+			return nil, errors.Errorf("unsupported: on.update.value[%s]", val)
+		}
+		fn.Exprs = sqlparser.SelectExprs{&sqlparser.AliasedExpr{Expr: sqlparser.NewIntVal([]byte(fsp[1 : len(fsp)-1]))}}
+	}
+	return fn, nil
+}
+
+// BuildMoves used to build the querys moving the rows read by the Resolve querys, the
+// rows are deleted by their primary (or unique) keys in the old partitions and inserted
+// with the new values, the lookup indexes are maintained by the delete and insert plans.
+// The generated columns are computed by the insert, the ON UPDATE columns not assigned
+// are set by their ON UPDATE values if the row is changed.
+func (p *UpdatePlan) BuildMoves(res *sqltypes.Result) error {
+	p.Querys = p.Querys[:0]
+	if len(res.Rows) == 0 {
+		return nil
+	}
+
+	resolve := func(expr sqlparser.Expr) (int, bool) {
+		if col, ok := expr.(*sqlparser.ColName); ok {
+			for i, field := range res.Fields {
+				if col.Name.EqualString(field.Name) {
+					return i, true
+				}
+			}
+		}
+		return -1, false
+	}
+
+	// The assignments are evaluated from left to right on the new row as MySQL does.
+	idxs := make([]int, len(p.node.Exprs))
+	evals := make([]expression.Evaluator, len(p.node.Exprs))
+	for i, assignment := range p.node.Exprs {
+		idx, ok := resolve(assignment.Name)
+		if !ok {
+			return errors.Errorf("unsupported: unknown.column.'%s'.in.field.list", assignment.Name.Name.String())
+		}
+		if p.generated[assignment.Name.Name.Lowered()] {
+			return errors.Errorf("The value specified for generated column '%s' in table '%s' is not allowed.", assignment.Name.Name.String(), p.table.Name.String())
+		}
+		eval, err := expression.Compile(assignment.Expr, resolve)
+		if err != nil {
+			return err
+		}
+		idxs[i], evals[i] = idx, eval
+	}
+
+	keys := router.SplitShardKey(p.shardkey)
+	keyIdxs := make([]int, len(keys))
+	for i, key := range keys {
+		idx, ok := resolve(&sqlparser.ColName{Name: sqlparser.NewColIdent(key)})
+		if !ok {
+			return errors.Errorf("unsupported: shardkey.column[%v].missing", key)
+		}
+		keyIdxs[i] = idx
+	}
+	uniqIdxs := uniqueKeyIdxs(res.Fields)
+	if len(uniqIdxs) == 0 {
+		return errors.Errorf("unsupported: cannot.update.shard.key.of.table[%s].without.primary.or.unique.key", p.table.Name.String())
+	}
+
+	// The columns inserted and the ON UPDATE values of the columns not assigned.
+	assigned := make([]bool, len(res.Fields))
+	for _, idx := range idxs {
+		assigned[idx] = true
+	}
+	var fieldIdxs []int
+	onUpdates := make([]sqlparser.Expr, len(res.Fields))
+	columns := make(sqlparser.Columns, 0, len(res.Fields))
+	for i, field := range res.Fields {
+		name := strings.ToLower(field.Name)
+		if p.generated[name] {
+			continue
+		}
+		if !assigned[i] {
+			onUpdates[i] = p.onUpdates[name]
+		}
+		fieldIdxs = append(fieldIdxs, i)
+		columns = append(columns, sqlparser.NewColIdent(field.Name))
+	}
+	rows := make(sqlparser.Values, 0, len(res.Rows))
+	olds := make([][]sqltypes.Value, 0, len(res.Rows))
+	for _, row := range res.Rows {
+		for i, idx := range keyIdxs {
+			if row[idx].IsNull() {
+				return errors.Errorf("unsupported: shardkey[%v].is.null", keys[i])
+			}
+		}
+		olds = append(olds, row)
+
+		newRow := make([]sqltypes.Value, len(row))
+		copy(newRow, row)
+		for i, eval := range evals {
+			val, err := eval.Eval(newRow)
+			if err != nil {
+				return err
+			}
+			newRow[idxs[i]] = val
+		}
+		changed := false
+		for _, idx := range idxs {
+			if newRow[idx].IsNull() != row[idx].IsNull() || !bytes.Equal(newRow[idx].Raw(), row[idx].Raw()) {
+				changed = true
+				break
+			}
+		}
+		tuple := make(sqlparser.ValTuple, 0, len(fieldIdxs))
+		for _, idx := range fieldIdxs {
+			if changed && onUpdates[idx] != nil {
+				tuple = append(tuple, onUpdates[idx])
+				continue
+			}
+			tuple = append(tuple, valueExpr(newRow[idx]))
+		}
+		rows = append(rows, tuple)
+	}
+
+	// The old rows are deleted by the unique key, not by the shard key which other rows may share.
+	wheres := buildKeyWheres(olds, res.Fields, keyIdxs, uniqIdxs)
+	database := p.table.Qualifier.String()
+	for _, where := range wheres {
+		node := &sqlparser.Delete{
+			Comments:      p.node.Comments,
+			IsSingleTable: true,
+			TableRefs:     sqlparser.TableExprs{&sqlparser.AliasedTableExpr{Expr: p.table}},
+			Where:         sqlparser.NewWhere(sqlparser.WhereClause, where),
+		}
+		plan := NewDeletePlan(p.log, database, sqlparser.String(node), node, p.router)
+		if err := plan.Build(); err != nil {
+			return err
+		}
+		p.Querys = append(p.Querys, plan.Querys...)
+		p.ForceXA = p.ForceXA || plan.ForceXA
+		p.lookups = append(p.lookups, plan.lookups...)
+	}
+
+	node := &sqlparser.Insert{
+		Action:   sqlparser.InsertStr,
+		Comments: p.node.Comments,
+		Table:    p.table,
+		Columns:  columns,
+		Rows:     rows,
+	}
+	plan := NewInsertPlan(p.log, database, sqlparser.String(node), node, p.router)
+	if err := plan.Build(); err != nil {
+		return err
+	}
+	p.Querys = append(p.Querys, plan.Querys...)
+	p.ForceXA = p.ForceXA || plan.ForceXA
+	return nil
+}
+
+// PruneRoutes used to drop the querys of the routes not kept by the Prune.
+func (p *UpdatePlan) PruneRoutes(keep []bool) {
+	p.Querys = pruneQuerys(p.Querys, keep)
+	p.Resolve = pruneQuerys(p.Resolve, keep)
+	for _, task := range p.lookups {
+		task.reads = pruneQuerys(task.reads, keep)
+	}
+}
+
+// Lookups returns the querys reading the lookup pairs of the rows to update, they are executed
+// in the transaction before the Querys and the results are passed to the BuildLookups.
+func (p *UpdatePlan) Lookups() []xcontext.QueryTuple {
+	return lookupReads(p.lookups)
+}
+
+// BuildLookups used to build the querys moving the pairs in the lookup tables by the results
+// of the Lookups.
+func (p *UpdatePlan) BuildLookups(results []*sqltypes.Result) error {
+	querys, err := buildLookupTasks(p.lookups, results)
+	if err != nil {
+		return err
+	}
+	p.lookups = nil
+	p.Querys = append(p.Querys, querys...)
+	return nil
+}
+
+// uniqueKeyIdxs returns the indexes of the primary key columns, or of the not null unique
+// key columns if there's no primary key, nil if the rows cannot be identified.
+func uniqueKeyIdxs(fields []*querypb.Field) []int {
+	var pks, uniqs []int
+	for i, field := range fields {
+		switch {
+		case field.Flags&uint32(querypb.MySqlFlag_PRI_KEY_FLAG) != 0:
+			pks = append(pks, i)
+		case field.Flags&uint32(querypb.MySqlFlag_UNIQUE_KEY_FLAG) != 0 && field.Flags&uint32(querypb.MySqlFlag_NOT_NULL_FLAG) != 0:
+			uniqs = append(uniqs, i)
+		}
+	}
+	if len(pks) > 0 {
+		return pks
+	}
+	return uniqs
+}
+
+// buildKeyWheres returns the where clauses to delete the rows by the keys. If the unique key
+// is the single shard key, all the rows are deleted by it, otherwise the rows are grouped by
+// the shard keys to route, and deleted by the rest columns of the unique keys in every group.
+func buildKeyWheres(olds [][]sqltypes.Value, fields []*querypb.Field, keyIdxs, uniqIdxs []int) []sqlparser.Expr {
+	colName := func(idx int) *sqlparser.ColName {
+		return &sqlparser.ColName{Name: sqlparser.NewColIdent(fields[idx].Name)}
+	}
+	equalCond := func(row []sqltypes.Value, idxs []int) sqlparser.Expr {
+		var cond sqlparser.Expr
+		for _, idx := range idxs {
+			eq := &sqlparser.ComparisonExpr{Operator: sqlparser.EqualStr, Left: colName(idx), Right: router.SQLValFromValue(row[idx])}
+			if cond == nil {
+				cond = eq
+			} else {
+				cond = &sqlparser.AndExpr{Left: cond, Right: eq}
+			}
+		}
+		return cond
+	}
+	compare := func(x, y []sqltypes.Value, idxs []int) int {
+		for _, idx := range idxs {
+			if cmp := sqltypes.NullsafeCompare(x[idx], y[idx]); cmp != 0 {
+				return cmp
+			}
+		}
+		return 0
+	}
+
+	// The rows are deleted in the order of the shard keys and the unique keys.
+	sort.Slice(olds, func(a, b int) bool {
+		if cmp := compare(olds[a], olds[b], keyIdxs); cmp != 0 {
+			return cmp < 0
+		}
+		return compare(olds[a], olds[b], uniqIdxs) < 0
+	})
+
+	// uniqCond returns the condition matching the unique keys of the rows.
+	uniqCond := func(rows [][]sqltypes.Value, idxs []int) sqlparser.Expr {
+		if len(idxs) == 1 {
+			vals := make(sqlparser.ValTuple, 0, len(rows))
+			for _, row := range rows {
+				vals = append(vals, router.SQLValFromValue(row[idxs[0]]))
+			}
+			return &sqlparser.ComparisonExpr{Operator: sqlparser.InStr, Left: colName(idxs[0]), Right: vals}
+		}
+		var cond sqlparser.Expr
+		for _, row := range rows {
+			and := equalCond(row, idxs)
+			if len(rows) > 1 {
+				and = &sqlparser.ParenExpr{Expr: and}
+			}
+			if cond == nil {
+				cond = and
+			} else {
+				cond = &sqlparser.OrExpr{Left: cond, Right: and}
+			}
+		}
+		if len(rows) > 1 {
+			cond = &sqlparser.ParenExpr{Expr: cond}
+		}
+		return cond
+	}
+
+	if len(keyIdxs) == 1 && len(uniqIdxs) == 1 && keyIdxs[0] == uniqIdxs[0] {
+		return []sqlparser.Expr{uniqCond(olds, uniqIdxs)}
+	}
+
+	// The shard key columns of the unique key are equal in the group.
+	var restIdxs []int
+	for _, idx := range uniqIdxs {
+		shardKey := false
+		for _, keyIdx := range keyIdxs {
+			shardKey = shardKey || idx == keyIdx
+		}
+		if !shardKey {
+			restIdxs = append(restIdxs, idx)
+		}
+	}
+	var wheres []sqlparser.Expr
+	for start := 0; start < len(olds); {
+		end := start + 1
+		for end < len(olds) && compare(olds[start], olds[end], keyIdxs) == 0 {
+			end++
+		}
+		where := equalCond(olds[start], keyIdxs)
+		if len(restIdxs) > 0 {
+			where = &sqlparser.AndExpr{Left: where, Right: uniqCond(olds[start:end], restIdxs)}
+		}
+		wheres = append(wheres, where)
+		start = end
+	}
+	return wheres
+}
+
+// valueExpr converts the value of the row to the expr of the insert values.
+func valueExpr(v sqltypes.Value) sqlparser.Expr {
+	if v.IsNull() {
+		return &sqlparser.NullVal{}
+	}
+	return router.SQLValFromValue(v)
+}
+
 // Type returns the type of the plan.
 func (p *UpdatePlan) Type() PlanType {
 	return p.typ
@@ -130,6 +803,10 @@ func (p *UpdatePlan) Type() PlanType {
 func (p *UpdatePlan) JSON() string {
 	type explain struct {
 		RawQuery   string                `json:",omitempty"`
+		Fields     []xcontext.QueryTuple `json:",omitempty"`
+		Columns    []xcontext.QueryTuple `json:",omitempty"`
+		Resolve    []xcontext.QueryTuple `json:",omitempty"`
+		Lookups    []xcontext.QueryTuple `json:",omitempty"`
 		Partitions []xcontext.QueryTuple `json:",omitempty"`
 	}
 
@@ -138,8 +815,16 @@ func (p *UpdatePlan) JSON() string {
 	parts = append(parts, p.Querys...)
 	exp := &explain{
 		RawQuery:   p.RawQuery,
+		Fields:     p.Fields,
+		Columns:    p.Columns,
+		Resolve:    p.Resolve,
+		Lookups:    p.Lookups(),
 		Partitions: parts,
 	}
+	// The keys and the new values of the multi-table update are resolved before the update.
+	if p.Root != nil {
+		exp.Resolve = p.Root.GetQuery()
+	}
 	out, err := common.ToJSONString(exp, false, "", "\t")
 	if err != nil {
 		return err.Error()
@@ -153,5 +838,8 @@ func (p *UpdatePlan) Size() int {
 	for _, q := range p.Querys {
 		size += len(q.Query)
 	}
+	for _, q := range p.Resolve {
+		size += len(q.Query)
+	}
 	return size
 }
@@ -11,9 +11,13 @@ package planner
 
 import (
 	"github.com/sealdb/neodb/router"
+	"github.com/sealdb/neodb/xcontext"
+	"github.com/sealdb/neodb/xparser"
 	"testing"
 
 	"github.com/sealdb/mysqlstack/sqlparser"
+	querypb "github.com/sealdb/mysqlstack/sqlparser/depends/query"
+	"github.com/sealdb/mysqlstack/sqlparser/depends/sqltypes"
 	"github.com/sealdb/mysqlstack/xlog"
 	"github.com/stretchr/testify/assert"
 )
@@ -90,13 +94,11 @@ func TestUpdatePlan(t *testing.T) {
 func TestUpdateUnsupportedPlan(t *testing.T) {
 	querys := []string{
 		"update sbtest.A set a=3",
-		"update sbtest.A set id=3 where id=1",
 		"update sbtest.A set b=3 where id in (select id from t1)",
 	}
 
 	results := []string{
 		"unsupported: missing.where.clause.in.DML",
-		"unsupported: cannot.update.shard.key",
 		"unsupported: subqueries.in.update",
 	}
 
@@ -202,7 +204,366 @@ func TestUpdateShardKey(t *testing.T) {
 	// plan build
 	{
 		err := plan.Build()
-		assert.NotNil(t, err)
+		assert.Nil(t, err)
+		assert.Equal(t, 0, len(plan.Querys))
+		want := []xcontext.QueryTuple{{
+			Query:   "select * from sbtest.A6 where id = 2 for update",
+			Backend: "backend6",
+			Range:   "[512-4096)",
+		}}
+		assert.Equal(t, want, plan.Resolve)
+		want = []xcontext.QueryTuple{{
+			Query:   "select column_name, extra from information_schema.columns where table_schema='sbtest' and table_name='A6'",
+			Backend: "backend6",
+			Range:   "[512-4096)",
+		}}
+		assert.Equal(t, want, plan.Columns)
+	}
+}
+
+func TestUpdateShardKeyMoves(t *testing.T) {
+	querys := []string{
+		"update B set name = concat(name, '-'), id = id + 1000 where id in (1, 2)",
+		"update /*+ hint */ B set id = 600, name = null where name = 'x'",
+	}
+	results := []string{
+		`{
+	"RawQuery": "update B set name = concat(name, '-'), id = id + 1000 where id in (1, 2)",
+	"Columns": [
+		{
+			"Query": "select column_name, extra from information_schema.columns where table_schema='sbtest' and table_name='B1'",
+			"Backend": "backend2",
+			"Range": "[512-4096)"
+		}
+	],
+	"Resolve": [
+		{
+			"Query": "select * from sbtest.B1 where id in (1, 2) for update",
+			"Backend": "backend2",
+			"Range": "[512-4096)"
+		}
+	],
+	"Partitions": [
+		{
+			"Query": "delete from sbtest.B1 where sbtest.B1.id in (1, 2)",
+			"Backend": "backend2",
+			"Range": "[512-4096)"
+		},
+		{
+			"Query": "insert into sbtest.B0(id, name) values (1002, 'b-')",
+			"Backend": "backend1",
+			"Range": "[0-512)"
+		},
+		{
+			"Query": "insert into sbtest.B1(id, name) values (1001, 'a-')",
+			"Backend": "backend2",
+			"Range": "[512-4096)"
+		}
+	]
+}`,
+		`{
+	"RawQuery": "update /*+ hint */ B set id = 600, name = null where name = 'x'",
+	"Columns": [
+		{
+			"Query": "select column_name, extra from information_schema.columns where table_schema='sbtest' and table_name='B0'",
+			"Backend": "backend1",
+			"Range": "[0-512)"
+		}
+	],
+	"Resolve": [
+		{
+			"Query": "select * from sbtest.B0 where name = 'x' for update",
+			"Backend": "backend1",
+			"Range": "[0-512)"
+		},
+		{
+			"Query": "select * from sbtest.B1 where name = 'x' for update",
+			"Backend": "backend2",
+			"Range": "[512-4096)"
+		}
+	],
+	"Partitions": [
+		{
+			"Query": "delete /*+ hint */ from sbtest.B1 where sbtest.B1.id in (1, 2)",
+			"Backend": "backend2",
+			"Range": "[512-4096)"
+		},
+		{
+			"Query": "insert /*+ hint */ into sbtest.B1(id, name) values (600, null), (600, null)",
+			"Backend": "backend2",
+			"Range": "[512-4096)"
+		}
+	]
+}`,
+	}
+	pkFlags := uint32(querypb.MySqlFlag_PRI_KEY_FLAG | querypb.MySqlFlag_NOT_NULL_FLAG)
+	rows := &sqltypes.Result{
+		Fields: []*querypb.Field{
+			{Name: "id", Type: querypb.Type_INT32, Flags: pkFlags},
+			{Name: "name", Type: querypb.Type_VARCHAR},
+		},
+		Rows: [][]sqltypes.Value{
+			{sqltypes.NewInt32(2), sqltypes.NewVarChar("b")},
+			{sqltypes.NewInt32(1), sqltypes.NewVarChar("a")},
+		},
+	}
+
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	database := "sbtest"
+
+	route, cleanup := router.MockNewRouter(log)
+	defer cleanup()
+
+	err := route.CreateDatabase(database)
+	assert.Nil(t, err)
+	err = route.AddForTest(database, router.MockTableBConfig())
+	assert.Nil(t, err)
+
+	for i, query := range querys {
+		node, err := sqlparser.Parse(query)
+		assert.Nil(t, err)
+		plan := NewUpdatePlan(log, database, query, node.(*sqlparser.Update), route)
+		err = plan.Build()
+		assert.Nil(t, err)
+		err = plan.BuildMoves(rows)
+		assert.Nil(t, err)
+		assert.Equal(t, results[i], plan.JSON())
+	}
+
+	// No rows to move.
+	{
+		node, err := sqlparser.Parse(querys[0])
+		assert.Nil(t, err)
+		plan := NewUpdatePlan(log, database, querys[0], node.(*sqlparser.Update), route)
+		err = plan.Build()
+		assert.Nil(t, err)
+		err = plan.BuildMoves(&sqltypes.Result{Fields: rows.Fields})
+		assert.Nil(t, err)
+		assert.Equal(t, 0, len(plan.Querys))
+	}
+
+	// The rows sharing the old shard key with the moved one are kept, it's deleted by the primary key.
+	{
+		query := "update B set id = 600 where oid = 10"
+		node, err := sqlparser.Parse(query)
+		assert.Nil(t, err)
+		plan := NewUpdatePlan(log, database, query, node.(*sqlparser.Update), route)
+		err = plan.Build()
+		assert.Nil(t, err)
+
+		rows := &sqltypes.Result{
+			Fields: []*querypb.Field{
+				{Name: "oid", Type: querypb.Type_INT32, Flags: pkFlags},
+				{Name: "id", Type: querypb.Type_INT32},
+			},
+			Rows: [][]sqltypes.Value{
+				{sqltypes.NewInt32(10), sqltypes.NewInt32(1)},
+			},
+		}
+		err = plan.BuildMoves(rows)
+		assert.Nil(t, err)
+		var got []string
+		for _, q := range plan.Querys {
+			got = append(got, q.Query)
+		}
+		want := []string{
+			"delete from sbtest.B1 where sbtest.B1.id = 1 and sbtest.B1.oid in (10)",
+			"insert into sbtest.B1(oid, id) values (10, 600)",
+		}
+		assert.Equal(t, want, got)
+
+		// No primary or unique key to identify the rows.
+		rows.Fields[0].Flags = uint32(querypb.MySqlFlag_UNIQUE_KEY_FLAG)
+		err = plan.BuildMoves(rows)
+		assert.Equal(t, "unsupported: cannot.update.shard.key.of.table[B].without.primary.or.unique.key", err.Error())
+	}
+
+	// Order by and limit across the partitions.
+	{
+		query := "update B set id = 600 where name = 'x' order by id limit 1"
+		node, err := sqlparser.Parse(query)
+		assert.Nil(t, err)
+		plan := NewUpdatePlan(log, database, query, node.(*sqlparser.Update), route)
+		err = plan.Build()
+		assert.Equal(t, "unsupported: order.by.or.limit.in.cross-shard.shard.key.update", err.Error())
+	}
+
+	// Composite shard key, the rows are grouped by the old shard keys.
+	{
+		query := "update CK set user_id = user_id + 1 where tenant_id = 1"
+		err = route.AddForTest(database, router.MockTableCKConfig())
+		assert.Nil(t, err)
+		node, err := sqlparser.Parse(query)
+		assert.Nil(t, err)
+		plan := NewUpdatePlan(log, database, query, node.(*sqlparser.Update), route)
+		err = plan.Build()
+		assert.Nil(t, err)
+		assert.Equal(t, 2, len(plan.Resolve))
+
+		rows := &sqltypes.Result{
+			Fields: []*querypb.Field{
+				{Name: "tenant_id", Type: querypb.Type_INT32, Flags: pkFlags},
+				{Name: "user_id", Type: querypb.Type_INT32},
+				{Name: "seq", Type: querypb.Type_INT32, Flags: pkFlags},
+			},
+			Rows: [][]sqltypes.Value{
+				{sqltypes.NewInt32(1), sqltypes.NewInt32(2), sqltypes.NewInt32(7)},
+				{sqltypes.NewInt32(1), sqltypes.NewInt32(1), sqltypes.NewInt32(8)},
+				{sqltypes.NewInt32(1), sqltypes.NewInt32(1), sqltypes.NewInt32(9)},
+			},
+		}
+		err = plan.BuildMoves(rows)
+		assert.Nil(t, err)
+		var got []string
+		for _, q := range plan.Querys {
+			got = append(got, q.Query)
+		}
+		want := []string{
+			"delete from sbtest.CK_0001 where sbtest.CK_0001.tenant_id = 1 and sbtest.CK_0001.user_id = 1 and sbtest.CK_0001.seq in (8, 9)",
+			"delete from sbtest.CK_0000 where sbtest.CK_0000.tenant_id = 1 and sbtest.CK_0000.user_id = 2 and sbtest.CK_0000.seq in (7)",
+			"insert into sbtest.CK_0000(tenant_id, user_id, seq) values (1, 3, 7), (1, 2, 8), (1, 2, 9)",
+		}
+		assert.Equal(t, want, got)
+	}
+
+	// Errors.
+	{
+		querys := []string{
+			"update B set id = 1, xx = 2 where id = 1",
+			"update B set id = yy where id = 1",
+			"update B set id = null where id = 1",
+		}
+		wants := []string{
+			"unsupported: unknown.column.'xx'.in.field.list",
+			"unsupported: unknown.column.'yy'.in.expression",
+			"unsupported: shardkey[id].type.canot.be[*sqlparser.NullVal]",
+		}
+		for i, query := range querys {
+			node, err := sqlparser.Parse(query)
+			assert.Nil(t, err)
+			plan := NewUpdatePlan(log, database, query, node.(*sqlparser.Update), route)
+			err = plan.Build()
+			assert.Nil(t, err)
+			err = plan.BuildMoves(rows)
+			assert.Equal(t, wants[i], err.Error())
+		}
+	}
+}
+
+func TestUpdateShardKeyMovesColumns(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	database := "sbtest"
+
+	route, cleanup := router.MockNewRouter(log)
+	defer cleanup()
+
+	err := route.CreateDatabase(database)
+	assert.Nil(t, err)
+	err = route.AddForTest(database, router.MockTableBConfig())
+	assert.Nil(t, err)
+
+	pkFlags := uint32(querypb.MySqlFlag_PRI_KEY_FLAG | querypb.MySqlFlag_NOT_NULL_FLAG)
+	rows := &sqltypes.Result{
+		Fields: []*querypb.Field{
+			{Name: "oid", Type: querypb.Type_INT32, Flags: pkFlags},
+			{Name: "id", Type: querypb.Type_INT32},
+			{Name: "name", Type: querypb.Type_VARCHAR},
+			{Name: "name_len", Type: querypb.Type_INT32},
+			{Name: "updated", Type: querypb.Type_TIMESTAMP},
+			{Name: "modified", Type: querypb.Type_DATETIME},
+		},
+		Rows: [][]sqltypes.Value{
+			{sqltypes.NewInt32(10), sqltypes.NewInt32(1), sqltypes.NewVarChar("a"), sqltypes.NewInt32(1),
+				sqltypes.MakeTrusted(querypb.Type_TIMESTAMP, []byte("2021-01-01 00:00:00")),
+				sqltypes.MakeTrusted(querypb.Type_DATETIME, []byte("2021-01-01 00:00:00.000"))},
+		},
+	}
+	columns := &sqltypes.Result{
+		Rows: [][]sqltypes.Value{
+			{sqltypes.NewVarChar("oid"), sqltypes.NewVarChar("")},
+			{sqltypes.NewVarChar("id"), sqltypes.NewVarChar("")},
+			{sqltypes.NewVarChar("name"), sqltypes.NewVarChar("")},
+			{sqltypes.NewVarChar("name_len"), sqltypes.NewVarChar("VIRTUAL GENERATED")},
+			{sqltypes.NewVarChar("updated"), sqltypes.NewVarChar("on update CURRENT_TIMESTAMP")},
+			{sqltypes.NewVarChar("modified"), sqltypes.NewVarChar("DEFAULT_GENERATED on update CURRENT_TIMESTAMP(3)")},
+		},
+	}
+
+	querys := []string{
+		// The generated column is left out, the ON UPDATE columns are set.
+		"update B set id = 600 where oid = 10",
+		// The ON UPDATE column assigned keeps the assigned value.
+		"update B set id = 600, updated = '2022-01-01 00:00:00' where oid = 10",
+		// The row isn't changed, the ON UPDATE columns are kept.
+		"update B set id = 1 where oid = 10",
+	}
+	wants := []string{
+		"insert into sbtest.B1(oid, id, name, updated, modified) values (10, 600, 'a', current_timestamp(), current_timestamp(3))",
+		"insert into sbtest.B1(oid, id, name, updated, modified) values (10, 600, 'a', '2022-01-01 00:00:00', current_timestamp(3))",
+		"insert into sbtest.B1(oid, id, name, updated, modified) values (10, 1, 'a', '2021-01-01 00:00:00', '2021-01-01 00:00:00.000')",
+	}
+	for i, query := range querys {
+		node, err := sqlparser.Parse(query)
+		assert.Nil(t, err)
+		plan := NewUpdatePlan(log, database, query, node.(*sqlparser.Update), route)
+		err = plan.Build()
+		assert.Nil(t, err)
+		err = plan.BuildColumns(columns)
+		assert.Nil(t, err)
+		err = plan.BuildMoves(rows)
+		assert.Nil(t, err)
+		assert.Equal(t, wants[i], plan.Querys[len(plan.Querys)-1].Query, query)
+	}
+
+	// The generated column can't be assigned.
+	{
+		query := "update B set id = 600, name_len = 2 where oid = 10"
+		node, err := sqlparser.Parse(query)
+		assert.Nil(t, err)
+		plan := NewUpdatePlan(log, database, query, node.(*sqlparser.Update), route)
+		err = plan.Build()
+		assert.Nil(t, err)
+		err = plan.BuildColumns(columns)
+		assert.Nil(t, err)
+		err = plan.BuildMoves(rows)
+		assert.EqualError(t, err, "The value specified for generated column 'name_len' in table 'B' is not allowed.")
+	}
+
+	// The unknown ON UPDATE value.
+	{
+		query := "update B set id = 600 where oid = 10"
+		node, err := sqlparser.Parse(query)
+		assert.Nil(t, err)
+		plan := NewUpdatePlan(log, database, query, node.(*sqlparser.Update), route)
+		err = plan.Build()
+		assert.Nil(t, err)
+		err = plan.BuildColumns(&sqltypes.Result{
+			Rows: [][]sqltypes.Value{{sqltypes.NewVarChar("updated"), sqltypes.NewVarChar("on update now()")}},
+		})
+		assert.EqualError(t, err, "unsupported: on.update.value[now()]")
+	}
+
+	// The non-deterministic new values.
+	{
+		querys := []string{
+			"update B set id = 600, name = uuid() where oid = 10",
+			"update B set id = floor(rand() * 1000) where oid = 10",
+			"update B set id = 600, updated = NOW() where oid = 10",
+			"update B set id = 600, updated = current_timestamp where oid = 10",
+		}
+		wants := []string{
+			"unsupported: non-deterministic.function[uuid].in.shard.key.update",
+			"unsupported: non-deterministic.function[rand].in.shard.key.update",
+			"unsupported: non-deterministic.function[now].in.shard.key.update",
+			"unsupported: non-deterministic.function[current_timestamp].in.shard.key.update",
+		}
+		for i, query := range querys {
+			node, err := sqlparser.Parse(query)
+			assert.Nil(t, err)
+			plan := NewUpdatePlan(log, database, query, node.(*sqlparser.Update), route)
+			err = plan.Build()
+			assert.EqualError(t, err, wants[i])
+		}
 	}
 }
 
@@ -258,3 +619,146 @@ func TestUpdateDatabaseNotFound(t *testing.T) {
 		assert.NotNil(t, err)
 	}
 }
+
+func TestUpdateMultiTablesPlan(t *testing.T) {
+	querys := []string{
+		"update B join C on B.id = C.a set B.name = C.name, C.name = 'x' where C.name = 'x'",
+		"update sbtest.G join G as g2 on G.id = g2.id set G.name = g2.name where g2.id = 1",
+		"update A join B on A.name = B.name set A.name = B.name, B.name = concat(A.name, 'x') where B.id = 1",
+	}
+	results := []string{
+		`{
+	"RawQuery": "update B join C on B.id = C.a set B.name = C.name, C.name = 'x' where C.name = 'x'",
+	"Partitions": [
+		{
+			"Query": "update sbtest.B0 as B join sbtest.C0 as C on B.id = C.a set B.name = C.name, C.name = 'x' where C.name = 'x'",
+			"Backend": "backend1",
+			"Range": "[0-512)"
+		},
+		{
+			"Query": "update sbtest.B1 as B join sbtest.C1 as C on B.id = C.a set B.name = C.name, C.name = 'x' where C.name = 'x'",
+			"Backend": "backend2",
+			"Range": "[512-4096)"
+		}
+	]
+}`,
+		`{
+	"RawQuery": "update sbtest.G join G as g2 on G.id = g2.id set G.name = g2.name where g2.id = 1",
+	"Partitions": [
+		{
+			"Query": "update sbtest.G join sbtest.G as g2 on G.id = g2.id set G.name = g2.name where g2.id = 1",
+			"Backend": "backend1",
+			"Range": ""
+		},
+		{
+			"Query": "update sbtest.G join sbtest.G as g2 on G.id = g2.id set G.name = g2.name where g2.id = 1",
+			"Backend": "backend2",
+			"Range": ""
+		}
+	]
+}`,
+		`{
+	"RawQuery": "update A join B on A.name = B.name set A.name = B.name, B.name = concat(A.name, 'x') where B.id = 1",
+	"Fields": [
+		{
+			"Query": "select * from sbtest.A1 where 1 != 1",
+			"Backend": "backend1",
+			"Range": "[0-32)"
+		},
+		{
+			"Query": "select * from sbtest.B0 where 1 != 1",
+			"Backend": "backend1",
+			"Range": "[0-512)"
+		}
+	]
+}`,
+	}
+
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	database := "sbtest"
+
+	route, cleanup := router.MockNewRouter(log)
+	defer cleanup()
+
+	err := route.CreateDatabase(database)
+	assert.Nil(t, err)
+	err = route.AddForTest(database, router.MockTableMConfig(), router.MockTableBConfig(), router.MockTableCConfig(), router.MockTableGConfig(), router.MockTableSConfig())
+	assert.Nil(t, err)
+	for i, query := range querys {
+		node, err := xparser.Parse(query)
+		assert.Nil(t, err)
+		plan := NewMultiUpdatePlan(log, database, query, node.(*xparser.MultiUpdate), route)
+		err = plan.Build()
+		assert.Nil(t, err, query)
+		assert.Equal(t, results[i], plan.JSON(), query)
+		assert.Equal(t, i == 2, plan.Fields != nil, query)
+	}
+
+	// The target rows are resolved by the primary keys with the new values.
+	{
+		query := querys[2]
+		node, err := xparser.Parse(query)
+		assert.Nil(t, err)
+		plan := NewMultiUpdatePlan(log, database, query, node.(*xparser.MultiUpdate), route)
+		err = plan.Build()
+		assert.Nil(t, err)
+
+		fields := []*querypb.Field{{Name: "id"}, {Name: "name"}}
+		err = plan.BuildResolve([][]*querypb.Field{fields, fields})
+		assert.Equal(t, "unsupported: cannot.update.table[A].without.primary.or.unique.key", err.Error())
+
+		fields[0].Flags = uint32(querypb.MySqlFlag_PRI_KEY_FLAG)
+		err = plan.BuildResolve([][]*querypb.Field{fields, fields})
+		assert.Nil(t, err)
+		assert.Equal(t, "select A.id, concat(A.name, 'x'), A.name from sbtest.A1 as A for update", plan.Root.GetQuery()[0].Query)
+
+		// The rows of A are joined twice with the same B row.
+		rows := &sqltypes.Result{
+			Rows: [][]sqltypes.Value{
+				{sqltypes.NewInt64(1), sqltypes.NewVarChar("b1"), sqltypes.NewInt64(1), sqltypes.NewVarChar("a1x")},
+				{sqltypes.NewInt64(2), sqltypes.NewVarChar("b1"), sqltypes.NewInt64(1), sqltypes.NewVarChar("a2x")},
+				{sqltypes.NewInt64(3), sqltypes.NewVarChar("b2"), sqltypes.NewInt64(1), sqltypes.NewVarChar("a3x")},
+				{sqltypes.NewInt64(1), sqltypes.NewVarChar("b3"), sqltypes.NULL, sqltypes.NULL},
+			},
+		}
+		err = plan.BuildTargets(rows)
+		assert.Nil(t, err)
+		want := []string{
+			"update sbtest.A6 set name = 'b1' where id in (1, 2)",
+			"update sbtest.A6 set name = 'b2' where id in (3)",
+			"update sbtest.B1 set name = 'a1x' where id in (1)",
+		}
+		var got []string
+		for _, q := range plan.Querys {
+			got = append(got, q.Query)
+		}
+		assert.Equal(t, want, got)
+	}
+
+	// Unsupported.
+	querys = []string{
+		"update A, B set name = 1 where A.id = 1",
+		"update A join B on A.id = B.id set A.id = 1 where B.id = 1",
+		"update A join G on A.id = G.id set G.name = 1 where A.id = 1",
+		"update A join S on A.id = S.id set S.name = 1 where A.id = 1",
+		"update A join B set x.name = 1 where A.id = 1",
+		"update A join B set A.name = 1",
+		"update A join (select id from B) as b on A.id = b.id set A.name = 1 where b.id = 1",
+	}
+	errs := []string{
+		"unsupported: unqualified.column.'name'.in.multi-table.update",
+		"unsupported: cannot.update.shard.key.in.multi-table.update",
+		"unsupported: update.of.the.global.table.joined.with.the.non-global.tables",
+		"unsupported: cross-shard.multi-table.update.the.single.table[S]",
+		"unsupported: unknown.column.'x.name'.in.multi-table.update",
+		"unsupported: missing.where.clause.in.DML",
+		"unsupported: subqueries.in.update",
+	}
+	for i, query := range querys {
+		node, err := xparser.Parse(query)
+		assert.Nil(t, err)
+		plan := NewMultiUpdatePlan(log, database, query, node.(*xparser.MultiUpdate), route)
+		err = plan.Build()
+		assert.Equal(t, errs[i], err.Error(), query)
+	}
+}
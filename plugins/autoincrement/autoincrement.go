@@ -45,6 +45,17 @@ func (autoinc *AutoIncrement) Init() error {
 // Process -- process auto-increment.
 // Append the auto-increment column&value to the end of the row if not exists.
 func (autoinc *AutoIncrement) Process(database string, ins *sqlparser.Insert) error {
+	return autoinc.process(database, ins, true)
+}
+
+// Preview -- fills the auto-increment column like Process, but the values are not consumed,
+// used to show the routes of the insert without executing it.
+func (autoinc *AutoIncrement) Preview(database string, ins *sqlparser.Insert) error {
+	return autoinc.process(database, ins, false)
+}
+
+// process fills the auto-increment column, the values are consumed if consume is true.
+func (autoinc *AutoIncrement) process(database string, ins *sqlparser.Insert, consume bool) error {
 	var seq uint64
 	router := autoinc.router
 
@@ -64,7 +75,9 @@ func (autoinc *AutoIncrement) Process(database string, ins *sqlparser.Insert) er
 	seq = autoinc.seq
 	switch rows := ins.Rows.(type) {
 	case sqlparser.Values:
-		autoinc.seq += uint64(len(rows))
+		if consume {
+			autoinc.seq += uint64(len(rows))
+		}
 	}
 	autoinc.mu.Unlock()
 
@@ -101,6 +101,13 @@ func TestPluginAutoincModifyForAutoinc(t *testing.T) {
 			want:    "insert into t1(a) select a from t1",
 			autoinc: &config.AutoIncrement{Column: "a"},
 		},
+
+		// Insert with select without autoinc column, filled after the rows are read.
+		{
+			query:   "insert into t1(b) select b from t1",
+			want:    "insert into t1(b) select b from t1",
+			autoinc: &config.AutoIncrement{Column: "a"},
+		},
 	}
 
 	for _, test := range tests {
@@ -180,4 +187,25 @@ func TestPluginAutoIncrement(t *testing.T) {
 		insert.Format(buf)
 		log.Debug("%v", buf.String())
 	}
+
+	// Preview doesn't consume the values.
+	{
+		route.AddForTest(db, &config.TableConfig{
+			Name:          "t4",
+			ShardType:     "GLOBAL",
+			AutoIncrement: &config.AutoIncrement{Column: "id"},
+		})
+		fill := func(process func(string, *sqlparser.Insert) error) string {
+			node, err := sqlparser.Parse("insert into t4(b) values(1),(2)")
+			assert.Nil(t, err)
+			insert := node.(*sqlparser.Insert)
+			err = process(db, insert)
+			assert.Nil(t, err)
+			return sqlparser.String(insert)
+		}
+		preview := fill(autoplug.Preview)
+		assert.Equal(t, preview, fill(autoplug.Preview))
+		assert.Equal(t, preview, fill(autoplug.Process))
+		assert.NotEqual(t, preview, fill(autoplug.Process))
+	}
 }
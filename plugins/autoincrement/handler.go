@@ -26,6 +26,7 @@ var (
 type AutoIncrementHandler interface {
 	Init() error
 	Process(database string, ins *sqlparser.Insert) error
+	Preview(database string, ins *sqlparser.Insert) error
 	Close() error
 }
 
@@ -49,6 +50,11 @@ func GetAutoIncrement(node *sqlparser.DDL) (*config.AutoIncrement, error) {
 }
 
 func modifyForAutoinc(ins *sqlparser.Insert, autoinc *config.AutoIncrement, seq uint64) {
+	// The rows of the insert ... select are filled in batches after read.
+	rows, ok := ins.Rows.(sqlparser.Values)
+	if !ok {
+		return
+	}
 	col := sqlparser.NewColIdent(autoinc.Column)
 
 	// Insert has autoinc column.
@@ -63,7 +69,6 @@ func modifyForAutoinc(ins *sqlparser.Insert, autoinc *config.AutoIncrement, seq
 	ins.Columns = append(ins.Columns, col)
 
 	// 2. append vals to each row's end.
-	rows := ins.Rows.(sqlparser.Values)
 	for i := range rows {
 		seq++
 		rows[i] = append(rows[i], sqlparser.NewIntVal([]byte(strconv.FormatUint(seq, 10))))
@@ -16,6 +16,7 @@ import (
 
 	"github.com/sealdb/neodb/backend"
 	"github.com/sealdb/neodb/config"
+	"github.com/sealdb/neodb/xparser"
 
 	"github.com/sealdb/mysqlstack/sqldb"
 	"github.com/sealdb/mysqlstack/sqlparser"
@@ -124,7 +125,7 @@ func (p *Privilege) CheckPrivilege(db string, user string, node sqlparser.Statem
 			return (userpriv.priv.superPriv || userpriv.priv.selectPriv || dbpriv.priv.selectPriv)
 		case *sqlparser.Insert:
 			return (userpriv.priv.superPriv || userpriv.priv.insertPriv || dbpriv.priv.insertPriv)
-		case *sqlparser.Update:
+		case *sqlparser.Update, *xparser.MultiUpdate:
 			return (userpriv.priv.superPriv || userpriv.priv.updatePriv || dbpriv.priv.updatePriv)
 		case *sqlparser.Delete:
 			return (userpriv.priv.superPriv || userpriv.priv.deletePriv || dbpriv.priv.deletePriv)
@@ -150,7 +151,7 @@ func (p *Privilege) Check(database string, user string, node sqlparser.Statement
 	db := database
 
 	if node != nil {
-		sqlparser.Walk(func(nod sqlparser.SQLNode) (kontinue bool, err error) {
+		xparser.Walk(func(nod sqlparser.SQLNode) (kontinue bool, err error) {
 			switch nod := nod.(type) {
 			case *sqlparser.StarExpr:
 				return false, nil
@@ -300,9 +300,21 @@ func (r *Rebalance) Rebalance() (*sqltypes.Result, error) {
 		return &sqltypes.Result{}, err
 	}
 
-	if err := RebalanceMigrate(log, r, max, min, database, table); err != nil {
-		log.Error("admin.rebalance.migrate.return.error:%+v", err)
-		return &sqltypes.Result{}, err
+	// The partition tables of the colocate group are moved together.
+	tables := []string{table}
+	if table != "" {
+		var err error
+		if tables, err = r.router.ColocatedPartitions(database, table); err != nil {
+			log.Error("admin.rebalance.colocated.partitions.return.error:%+v", err)
+			return &sqltypes.Result{}, err
+		}
+		defer r.router.ShiftColocated(database, tables)()
+	}
+	for _, tbl := range tables {
+		if err := RebalanceMigrate(log, r, max, min, database, tbl); err != nil {
+			log.Error("admin.rebalance.migrate.return.error:%+v", err)
+			return &sqltypes.Result{}, err
+		}
 	}
 	return &sqltypes.Result{}, nil
 }
@@ -13,8 +13,10 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/sealdb/neodb/config"
 	"github.com/sealdb/neodb/plugins/autoincrement"
 	"github.com/sealdb/neodb/router"
+	"github.com/sealdb/neodb/xparser"
 
 	"github.com/sealdb/mysqlstack/driver"
 	"github.com/sealdb/mysqlstack/sqldb"
@@ -49,8 +51,19 @@ func tryGetShardKey(ddl *sqlparser.DDL) (string, error) {
 	switch partOpt := ddl.PartitionOption.(type) {
 	case *sqlparser.PartOptHash:
 		shardKey = strings.ToLower(partOpt.Name)
+	case *xparser.PartOptHashFunc:
+		shardKey = strings.ToLower(partOpt.Name)
 	case *sqlparser.PartOptList:
 		shardKey = strings.ToLower(partOpt.Name)
+	case *xparser.PartOptRange:
+		shardKey = strings.ToLower(partOpt.Name)
+	case *xparser.PartOptColocate:
+		shardKey = strings.ToLower(partOpt.Name)
+	case *xparser.PartOptInterval:
+		shardKey = strings.ToLower(partOpt.Name)
+		if err := checkIntervalKey(ddl, shardKey); err != nil {
+			return "", err
+		}
 	case *sqlparser.PartOptNormal:
 		for _, col := range ddl.TableSpec.Columns {
 			if col.Type.PrimaryKeyOpt == sqlparser.ColKeyPrimary ||
@@ -75,41 +88,103 @@ end:
 }
 
 func checkShardKey(ddl *sqlparser.DDL, shardKey string) error {
-	shardKeyOK := false
+	keys := router.SplitShardKey(shardKey)
 	constraintCheckOK := true
 	// shardKey check and constraint check in column definition
+	for _, key := range keys {
+		shardKeyOK := false
+		for _, col := range ddl.TableSpec.Columns {
+			if col.Name.EqualString(key) {
+				shardKeyOK = true
+				break
+			}
+		}
+		if !shardKeyOK {
+			return fmt.Errorf("Sharding Key column '%s' doesn't exist in table", key)
+		}
+	}
 	for _, col := range ddl.TableSpec.Columns {
-		if col.Name.EqualString(shardKey) {
-			shardKeyOK = true
-		} else {
+		// The single column constraint can only be defined on the single shard key.
+		if len(keys) > 1 || !col.Name.EqualString(shardKey) {
 			if col.Type.PrimaryKeyOpt == sqlparser.ColKeyPrimary ||
 				col.Type.UniqueKeyOpt == sqlparser.ColKeyUniqueKey {
 				constraintCheckOK = false
 			}
 		}
 	}
-
-	if !shardKeyOK {
-		return fmt.Errorf("Sharding Key column '%s' doesn't exist in table", shardKey)
-	}
 	if !constraintCheckOK {
 		return fmt.Errorf("The unique/primary constraint should be only defined on the sharding key column[%s]", shardKey)
 	}
 
-	// constraint check in index definition
+	// constraint check in index definition, all the shard key columns must be contained.
 	for _, index := range ddl.TableSpec.Indexes {
-		constraintCheckOK = false
 		if index.Unique || index.Primary {
-			for _, colIdx := range index.Opts.Columns {
-				if colIdx.Column.EqualString(shardKey) {
-					constraintCheckOK = true
-					break
+			for _, key := range keys {
+				constraintCheckOK = false
+				for _, colIdx := range index.Opts.Columns {
+					if colIdx.Column.EqualString(key) {
+						constraintCheckOK = true
+						break
+					}
+				}
+				if !constraintCheckOK {
+					return fmt.Errorf("The unique/primary constraint should be only defined on the sharding key column[%s]", shardKey)
 				}
 			}
-			if !constraintCheckOK {
-				return fmt.Errorf("The unique/primary constraint should be only defined on the sharding key column[%s]", shardKey)
+		}
+	}
+	return nil
+}
+
+// checkIntervalKey checks the shard key of the interval table is a datetime column.
+func checkIntervalKey(ddl *sqlparser.DDL, shardKey string) error {
+	for _, col := range ddl.TableSpec.Columns {
+		if col.Name.EqualString(shardKey) {
+			switch strings.ToLower(col.Type.Type) {
+			case "datetime", "date", "timestamp":
+				return nil
 			}
+			return fmt.Errorf("Sharding Key column '%s' of the interval table must be DATETIME, DATE or TIMESTAMP", shardKey)
+		}
+	}
+	// The missing column is reported by checkShardKey.
+	return nil
+}
+
+// createPartitionTables used to create the sub tables of the partitions on the backends as the template sub table.
+func (spanner *Spanner) createPartitionTables(database string, template *config.PartitionConfig, parts []*config.PartitionConfig) error {
+	query := fmt.Sprintf("SHOW CREATE TABLE `%s`.`%s`", database, template.Table)
+	qr, err := spanner.ExecuteOnThisBackend(template.Backend, query)
+	if err != nil {
+		return err
+	}
+	if len(qr.Rows) == 0 || len(qr.Rows[0]) < 2 {
+		return fmt.Errorf("spanner.show.create.table[%s].is.empty", template.Table)
+	}
+	create := string(qr.Rows[0][1].Raw())
+	prefix := fmt.Sprintf("CREATE TABLE `%s`", template.Table)
+	if !strings.HasPrefix(create, prefix) {
+		return fmt.Errorf("spanner.unexpected.create.table:%s", create)
+	}
+
+	for _, part := range parts {
+		ddl := fmt.Sprintf("CREATE TABLE IF NOT EXISTS `%s`.`%s`%s", database, part.Table, create[len(prefix):])
+		if _, err := spanner.ExecuteOnThisBackend(part.Backend, ddl); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dropPartitionTables used to drop the sub tables of the partitions on the backends.
+func (spanner *Spanner) dropPartitionTables(database string, parts []*config.PartitionConfig) error {
+	log := spanner.log
+	for _, part := range parts {
+		query := fmt.Sprintf("DROP TABLE IF EXISTS `%s`.`%s`", database, part.Table)
+		if _, err := spanner.ExecuteOnThisBackend(part.Backend, query); err != nil {
+			return err
 		}
+		log.Warning("spanner.dropped.partition[%s.%s].on.backend[%s]", database, part.Table, part.Backend)
 	}
 	return nil
 }
@@ -132,12 +207,18 @@ func checkTableExists(database string, table string, router *router.Router) bool
 // Here we need to deal with database.table grammar.
 // Supports:
 // 1. CREATE/DROP DATABASE
-// 2. CREATE/DROP TABLE ... PARTITION BY HASH(shardkey)
+// 2. CREATE/DROP TABLE ... PARTITION BY HASH/LIST/RANGE(shardkey), HASH(col1, col2, ...) for the composite shard key,
+// [PARTITION BY HASH/LIST/RANGE(shardkey)] COLOCATE WITH table for the colocate table,
+// INTERVAL(shardkey) DAY|MONTH ... for the interval table
 // 3. CREATE/DROP INDEX ON TABLE(columns...)
 // 4. ALTER TABLE .. ENGINE=xx
 // 5. ALTER TABLE .. ADD COLUMN (column definition)
 // 6. ALTER TABLE .. MODIFY COLUMN column definition
 // 7. ALTER TABLE .. DROP COLUMN column
+// 8. ALTER TABLE .. ADD PARTITION (PARTITION backend VALUES IN (...), ...) for the list table
+// 9. ALTER TABLE .. DROP PARTITION sub_table, ... for the list table
+// 10. CREATE [UNIQUE GLOBAL|LOOKUP] INDEX name ON table(column)
+// 11. DROP [GLOBAL|LOOKUP] INDEX name ON table
 func (spanner *Spanner) handleDDL(session *driver.Session, query string, node *sqlparser.DDL) (*sqltypes.Result, error) {
 	log := spanner.log
 	route := spanner.router
@@ -226,6 +307,15 @@ func (spanner *Spanner) handleDDL(session *driver.Session, query string, node *s
 			return nil, err
 		}
 
+		// The colocate table inherits the shard key of the table colocated with if absent.
+		if partOpt, ok := ddl.PartitionOption.(*xparser.PartOptColocate); ok && partOpt.Name == "" {
+			colocateKey, err := route.ShardKey(database, partOpt.Table)
+			if err != nil {
+				return nil, err
+			}
+			partOpt.Name = colocateKey
+		}
+
 		shardKey, err := tryGetShardKey(ddl)
 		if err != nil {
 			return nil, err
@@ -245,6 +335,12 @@ func (spanner *Spanner) handleDDL(session *driver.Session, query string, node *s
 			if err := route.CreateHashTable(database, table, shardKey, tableType, backends, partOpt.PartitionNum, extra); err != nil {
 				return nil, err
 			}
+		case *xparser.PartOptHashFunc:
+			tableType = router.TableTypePartitionHash
+			extra.HashFunction = partOpt.Function
+			if err := route.CreateHashTable(database, table, shardKey, tableType, backends, partOpt.PartitionNum, extra); err != nil {
+				return nil, err
+			}
 		case *sqlparser.PartOptNormal:
 			tableType = router.TableTypePartitionHash
 			if err := route.CreateHashTable(database, table, shardKey, tableType, backends, nil, extra); err != nil {
@@ -255,6 +351,32 @@ func (spanner *Spanner) handleDDL(session *driver.Session, query string, node *s
 			if err := route.CreateListTable(database, table, shardKey, tableType, partOpt.PartDefs, extra); err != nil {
 				return nil, err
 			}
+		case *xparser.PartOptRange:
+			tableType = router.TableTypePartitionRange
+			for _, partDef := range partOpt.PartDefs {
+				if isExist := scatter.CheckBackend(partDef.Backend); !isExist {
+					log.Error("spanner.ddl.execute[%v].backend.doesn't.exist", query)
+					return nil, fmt.Errorf("create table partition by range backend '%s' doesn't exist", partDef.Backend)
+				}
+			}
+			if err := route.CreateRangeTable(database, table, shardKey, tableType, partOpt.PartDefs, extra); err != nil {
+				return nil, err
+			}
+		case *xparser.PartOptInterval:
+			tableType = router.TableTypePartitionInterval
+			interval := &config.IntervalConfig{
+				Unit:      partOpt.Unit,
+				Premake:   partOpt.Premake,
+				Retention: partOpt.Retention,
+				Detach:    partOpt.Detach,
+			}
+			if err := route.CreateIntervalTable(database, table, shardKey, tableType, backends, interval, extra); err != nil {
+				return nil, err
+			}
+		case *xparser.PartOptColocate:
+			if err := route.CreateColocateTable(database, table, partOpt.ShardType, shardKey, partOpt.Table, extra); err != nil {
+				return nil, err
+			}
 		case *sqlparser.PartOptGlobal:
 			tableType = router.TableTypeGlobal
 			if err := route.CreateNonPartTable(database, table, tableType, backends, extra); err != nil {
@@ -317,6 +439,15 @@ func (spanner *Spanner) handleDDL(session *driver.Session, query string, node *s
 				return &sqltypes.Result{}, nil
 			}
 
+			// The lookup table is dropped by DROP LOOKUP INDEX.
+			if owner, ok := route.LookupIndexOwner(db, table); ok {
+				return nil, fmt.Errorf("unsupported: table[%s].is.the.lookup.index.of.table[%s]", table, owner)
+			}
+			var lookups []*config.LookupIndexConfig
+			if tableConf, err := route.TableConfig(db, table); err == nil {
+				lookups = tableConf.LookupIndexes
+			}
+
 			// Execute.
 			r, err := spanner.ExecuteDDL(session, db, query, node)
 			if err != nil {
@@ -329,6 +460,13 @@ func (spanner *Spanner) handleDDL(session *driver.Session, query string, node *s
 			if err != nil {
 				return r, err
 			}
+
+			// Drop the lookup tables of the table.
+			for _, index := range lookups {
+				if err := spanner.dropLookupTable(session, db, index.Name); err != nil {
+					return nil, err
+				}
+			}
 		}
 		return r, nil
 	case sqlparser.CreateIndexStr, sqlparser.DropIndexStr,
@@ -351,6 +489,91 @@ func (spanner *Spanner) handleDDL(session *driver.Session, query string, node *s
 			log.Error("spanner.ddl[%v].error[%+v]", query, err)
 		}
 		return r, err
+	case xparser.AlterAddPartitionStr:
+		// Check the database
+		if err := route.CheckDatabase(database); err != nil {
+			return nil, err
+		}
+		table := ddl.Table.Name.String()
+		if !checkTableExists(database, table, route) {
+			return nil, sqldb.NewSQLError(sqldb.ER_NO_SUCH_TABLE, table)
+		}
+
+		partOpt := ddl.PartitionOption.(*sqlparser.PartOptList)
+		for _, partDef := range partOpt.PartDefs {
+			if isExist := scatter.CheckBackend(partDef.Backend); !isExist {
+				log.Error("spanner.ddl.execute[%v].backend.doesn't.exist", query)
+				return nil, fmt.Errorf("alter table add partition backend '%s' doesn't exist", partDef.Backend)
+			}
+		}
+		tableConf, err := route.TableConfig(database, table)
+		if err != nil {
+			return nil, err
+		}
+		template := tableConf.Partitions[0]
+
+		// The sub tables are created before the router is updated, so that the new partitions can always be written.
+		parts, err := route.ListAddUniform(database, table, partOpt.PartDefs)
+		if err != nil {
+			return nil, err
+		}
+		err = spanner.createPartitionTables(database, template, parts)
+		if err == nil {
+			err = route.AddListPartitions(database, table, parts)
+		}
+		if err != nil {
+			log.Error("spanner.ddl[%v].error[%+v]", query, err)
+			if x := spanner.dropPartitionTables(database, parts); x != nil {
+				log.Error("spanner.ddl[%v].drop.partitions.error[%+v]", query, x)
+			}
+			return nil, err
+		}
+		return &sqltypes.Result{}, nil
+	case xparser.AlterDropPartitionStr:
+		// Check the database
+		if err := route.CheckDatabase(database); err != nil {
+			return nil, err
+		}
+		table := ddl.Table.Name.String()
+		if !checkTableExists(database, table, route) {
+			return nil, sqldb.NewSQLError(sqldb.ER_NO_SUCH_TABLE, table)
+		}
+
+		names := make([]string, 0, len(ddl.Tables))
+		for _, name := range ddl.Tables {
+			names = append(names, name.Name.String())
+		}
+		// The router is updated first, so that no query routes to the dropped sub tables.
+		parts, err := route.DropListPartitions(database, table, names)
+		if err != nil {
+			return nil, err
+		}
+		if err := spanner.dropPartitionTables(database, parts); err != nil {
+			log.Error("spanner.ddl[%v].error[%+v]", query, err)
+			return nil, err
+		}
+		return &sqltypes.Result{}, nil
+	case xparser.CreateLookupIndexStr, xparser.DropLookupIndexStr:
+		// Check the database
+		if err := route.CheckDatabase(database); err != nil {
+			return nil, err
+		}
+		table := ddl.Table.Name.String()
+		if !checkTableExists(database, table, route) {
+			return nil, sqldb.NewSQLError(sqldb.ER_NO_SUCH_TABLE, table)
+		}
+
+		var err error
+		if ddl.Action == xparser.CreateLookupIndexStr {
+			err = spanner.createLookupIndex(session, database, table, ddl.IndexName, ddl.IndexOpts.Columns[0].Column.String(), ddl.IndexType == sqlparser.UniqueStr)
+		} else {
+			err = spanner.dropLookupIndex(session, database, table, ddl.IndexName)
+		}
+		if err != nil {
+			log.Error("spanner.ddl[%v].error[%+v]", query, err)
+			return nil, err
+		}
+		return &sqltypes.Result{}, nil
 	case sqlparser.AlterDatabase:
 		if ddl.Database.String() != "" {
 			// Check the alter database
@@ -13,10 +13,12 @@ import (
 	"errors"
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/sealdb/neodb/fakedb"
 
 	"github.com/sealdb/mysqlstack/driver"
+	querypb "github.com/sealdb/mysqlstack/sqlparser/depends/query"
 	"github.com/sealdb/mysqlstack/sqlparser/depends/sqltypes"
 	"github.com/sealdb/mysqlstack/xlog"
 	"github.com/stretchr/testify/assert"
@@ -1359,6 +1361,456 @@ func TestProxyDDLGlobalSingleNormalList(t *testing.T) {
 	}
 }
 
+func TestProxyDDLListPartition(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	fakedbs, proxy, cleanup := MockProxy(log)
+	defer cleanup()
+	address := proxy.Address()
+
+	showCreate := &sqltypes.Result{
+		Fields: []*querypb.Field{
+			{Name: "Table", Type: querypb.Type_VARCHAR},
+			{Name: "Create Table", Type: querypb.Type_VARCHAR},
+		},
+		Rows: [][]sqltypes.Value{
+			{
+				sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("l_0000")),
+				sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("CREATE TABLE `l_0000` (\n  `a` int(11) DEFAULT NULL\n) ENGINE=InnoDB")),
+			},
+		},
+	}
+
+	// fakedbs.
+	{
+		fakedbs.AddQueryPattern("use .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("create .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("drop .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("insert .*", &sqltypes.Result{})
+		fakedbs.AddQuery("show create table `test`.`l_0000`", showCreate)
+	}
+
+	// create database.
+	{
+		client, err := driver.NewConn("mock", "mock", address, "", "utf8")
+		assert.Nil(t, err)
+		query := "create database test"
+		_, err = client.FetchAll(query, -1)
+		assert.Nil(t, err)
+	}
+
+	querys := []string{
+		"CREATE TABLE l(a int, b int) partition by list(a)(" +
+			"PARTITION backend1 VALUES IN (1, 2)," +
+			"PARTITION backend2 VALUES IN (3))",
+		"CREATE TABLE l1(a int, b int) partition by list(a)(" +
+			"PARTITION backend1 VALUES IN (1, 2)," +
+			"PARTITION backend2 VALUES IN (DEFAULT))",
+		"CREATE TABLE l2(a int, b int) partition by list(a)(" +
+			"PARTITION backend1 VALUES IN (1, 2)," +
+			"PARTITION backend2 VALUES IN ('1'))",
+		"CREATE TABLE l2(a int, b int) partition by list(a)(" +
+			"PARTITION backend1 VALUES IN (1, DEFAULT))",
+		"ALTER TABLE l ADD PARTITION (PARTITION backend2 VALUES IN (4, 5))",
+		"ALTER TABLE l ADD PARTITION (PARTITION backend2 VALUES IN (5))",
+		"ALTER TABLE l ADD PARTITION (PARTITION backend8 VALUES IN (6))",
+		"ALTER TABLE l1 ADD PARTITION (PARTITION backend1 VALUES IN (6))",
+		"ALTER TABLE lx ADD PARTITION (PARTITION backend1 VALUES IN (6))",
+		"ALTER TABLE l DROP PARTITION l_0001",
+		"ALTER TABLE l DROP PARTITION l_0001",
+	}
+	results := []string{
+		"",
+		"",
+		"list.partition[l2_0001].value[1].is.duplicate (errno 1105) (sqlstate HY000)",
+		"partition.list.DEFAULT.must.be.the.only.value (errno 1105) (sqlstate HY000)",
+		"",
+		"list.partition[l_0003].value[5].is.duplicate (errno 1105) (sqlstate HY000)",
+		"alter table add partition backend 'backend8' doesn't exist (errno 1105) (sqlstate HY000)",
+		"router.list.table[l1].has.DEFAULT.partition[l1_0001].can't.add.partition (errno 1105) (sqlstate HY000)",
+		"Table 'lx' doesn't exist (errno 1146) (sqlstate 42S02)",
+		"",
+		"router.list.table[l].partition[l_0001].not.found (errno 1105) (sqlstate HY000)",
+	}
+	for i, query := range querys {
+		client, err := driver.NewConn("mock", "mock", address, "test", "utf8")
+		assert.Nil(t, err)
+		_, err = client.FetchAll(query, -1)
+		want := results[i]
+		if want == "" {
+			assert.Nil(t, err, query)
+		} else {
+			assert.NotNil(t, err, query)
+			assert.Equal(t, want, err.Error())
+		}
+	}
+
+	assert.Equal(t, 1, fakedbs.GetQueryCalledNum("create table if not exists `test`.`l_0002` (\n  `a` int(11) default null\n) engine=innodb"))
+	assert.Equal(t, 1, fakedbs.GetQueryCalledNum("drop table if exists `test`.`l_0001`"))
+	tconf, err := proxy.Router().TableConfig("test", "l")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(tconf.Partitions))
+	assert.Equal(t, []string{"4", "5"}, tconf.Partitions[1].ListValues)
+
+	// The rows out of the lists go to the DEFAULT partition.
+	{
+		client, err := driver.NewConn("mock", "mock", address, "test", "utf8")
+		assert.Nil(t, err)
+		_, err = client.FetchAll("insert into l1(a, b) values(1, 1), (100, 1)", -1)
+		assert.Nil(t, err)
+		_, err = client.FetchAll("insert into l(a, b) values(3, 1)", -1)
+		assert.EqualError(t, err, "Table has no partition for value 3 (errno 1105) (sqlstate HY000)")
+	}
+}
+
+func TestProxyDDLRange(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	fakedbs, proxy, cleanup := MockProxy(log)
+	defer cleanup()
+	address := proxy.Address()
+
+	// fakedbs.
+	{
+		fakedbs.AddQueryPattern("use .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("create .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("select .*", &sqltypes.Result{})
+	}
+
+	// create database.
+	{
+		client, err := driver.NewConn("mock", "mock", address, "", "utf8")
+		assert.Nil(t, err)
+		query := "create database test"
+		_, err = client.FetchAll(query, -1)
+		assert.Nil(t, err)
+	}
+
+	querys := []string{
+		"CREATE TABLE r(a int primary key,b int ) partition by range(a)(" +
+			"PARTITION backend1 VALUES LESS THAN (100)," +
+			"PARTITION backend2 VALUES LESS THAN MAXVALUE)",
+		"CREATE TABLE r(a int primary key,b int ) partition by range(a)(" +
+			"PARTITION backend1 VALUES LESS THAN (100))",
+		"CREATE TABLE r1(a int primary key,b int ) partition by range(b)(" +
+			"PARTITION backend1 VALUES LESS THAN (100))",
+		"CREATE TABLE r2(a int primary key,b int ) partition by range(a)(" +
+			"PARTITION backend8 VALUES LESS THAN (100))",
+		"CREATE TABLE r3(a int primary key,b int ) partition by range(a)(" +
+			"PARTITION backend1 VALUES LESS THAN MAXVALUE," +
+			"PARTITION backend2 VALUES LESS THAN (100))",
+		"CREATE TABLE r4(a int primary key,b int ) partition by range(a)(" +
+			"PARTITION backend1 VALUES LESS THAN (200)," +
+			"PARTITION backend2 VALUES LESS THAN (100))",
+		"CREATE TABLE r5(a int primary key,b int ) partition by range(a)(" +
+			"PARTITION backend1 VALUES IN (200))",
+	}
+
+	results := []string{
+		"",
+		"router.add.db[test].table[r].exists (errno 1105) (sqlstate HY000)",
+		"The unique/primary constraint should be only defined on the sharding key column[b] (errno 1105) (sqlstate HY000)",
+		"create table partition by range backend 'backend8' doesn't exist (errno 1105) (sqlstate HY000)",
+		"range.partition[r3_0000].MAXVALUE.can.only.be.used.in.last.partition (errno 1105) (sqlstate HY000)",
+		"range.partition[r4_0001].values.less.than.must.be.strictly.increasing (errno 1105) (sqlstate HY000)",
+		"You have an error in your SQL syntax; check the manual that corresponds to your MySQL server version for the right syntax to use, syntax error at position 93 near 'IN' (errno 1149) (sqlstate 42000)",
+	}
+
+	for i, query := range querys {
+		client, err := driver.NewConn("mock", "mock", address, "test", "utf8")
+		assert.Nil(t, err)
+		_, err = client.FetchAll(query, -1)
+		want := results[i]
+		if want == "" {
+			assert.Nil(t, err)
+		} else {
+			assert.NotNil(t, err)
+			assert.Equal(t, want, err.Error())
+		}
+	}
+
+	// select with the shard key.
+	{
+		client, err := driver.NewConn("mock", "mock", address, "test", "utf8")
+		assert.Nil(t, err)
+		query := "select * from r where a=10"
+		_, err = client.FetchAll(query, -1)
+		assert.Nil(t, err)
+
+		query = "select * from r where a=1000"
+		_, err = client.FetchAll(query, -1)
+		assert.Nil(t, err)
+	}
+}
+
+func TestProxyDDLCompositeShardKey(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	fakedbs, proxy, cleanup := MockProxy(log)
+	defer cleanup()
+	address := proxy.Address()
+
+	// fakedbs.
+	{
+		fakedbs.AddQueryPattern("use .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("create .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("insert .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("select .*", &sqltypes.Result{})
+	}
+
+	// create database.
+	{
+		client, err := driver.NewConn("mock", "mock", address, "", "utf8")
+		assert.Nil(t, err)
+		query := "create database test"
+		_, err = client.FetchAll(query, -1)
+		assert.Nil(t, err)
+	}
+
+	querys := []string{
+		"CREATE TABLE c(a int, b int, c int, primary key(a, b)) partition by hash(a, b)",
+		"CREATE TABLE c1(a int, b int, c int, unique key(a, c)) partition by hash(a, b)",
+		"CREATE TABLE c2(a int primary key, b int) partition by hash(a, b)",
+		"CREATE TABLE c3(a int, b int) partition by hash(a, d)",
+		"CREATE TABLE c4(a int, b int) partition by hash(a, b) partitions 8",
+		"CREATE TABLE c5(a int, b int) partition by hash(a, A)",
+	}
+
+	results := []string{
+		"",
+		"The unique/primary constraint should be only defined on the sharding key column[a,b] (errno 1105) (sqlstate HY000)",
+		"The unique/primary constraint should be only defined on the sharding key column[a,b] (errno 1105) (sqlstate HY000)",
+		"Sharding Key column 'd' doesn't exist in table (errno 1105) (sqlstate HY000)",
+		"",
+		"You have an error in your SQL syntax; check the manual that corresponds to your MySQL server version for the right syntax to use, Duplicate column name 'A' in shard key (errno 1149) (sqlstate 42000)",
+	}
+
+	for i, query := range querys {
+		client, err := driver.NewConn("mock", "mock", address, "test", "utf8")
+		assert.Nil(t, err)
+		_, err = client.FetchAll(query, -1)
+		want := results[i]
+		if want == "" {
+			assert.Nil(t, err)
+		} else {
+			assert.NotNil(t, err)
+			assert.Equal(t, want, err.Error())
+		}
+	}
+
+	// insert and select with the shard key.
+	{
+		client, err := driver.NewConn("mock", "mock", address, "test", "utf8")
+		assert.Nil(t, err)
+		query := "insert into c(a, b, c) values(1, 2, 3), (2, 3, 4)"
+		_, err = client.FetchAll(query, -1)
+		assert.Nil(t, err)
+
+		query = "insert into c(a, c) values(1, 3)"
+		_, err = client.FetchAll(query, -1)
+		assert.NotNil(t, err)
+
+		query = "select * from c where a=1 and b=2"
+		_, err = client.FetchAll(query, -1)
+		assert.Nil(t, err)
+
+		query = "update c set b=1 where a=1"
+		_, err = client.FetchAll(query, -1)
+		assert.NotNil(t, err)
+	}
+}
+
+func TestProxyDDLColocate(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	fakedbs, proxy, cleanup := MockProxy(log)
+	defer cleanup()
+	address := proxy.Address()
+
+	// fakedbs.
+	{
+		fakedbs.AddQueryPattern("use .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("create .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("select .*", &sqltypes.Result{})
+	}
+
+	// create database.
+	{
+		client, err := driver.NewConn("mock", "mock", address, "", "utf8")
+		assert.Nil(t, err)
+		query := "create database test"
+		_, err = client.FetchAll(query, -1)
+		assert.Nil(t, err)
+	}
+
+	querys := []string{
+		"CREATE TABLE t1(a int primary key, b int) partition by hash(a)",
+		"CREATE TABLE t2(a int, b int primary key) partition by hash(b) colocate with t1",
+		"CREATE TABLE t3(a int, b int primary key) colocate with t2",
+		"CREATE TABLE t4(a int primary key, b int) partition by list(a) colocate with t1",
+		"CREATE TABLE t4(a int, b int primary key) colocate with t1",
+		"CREATE TABLE t4(a int primary key, b int) colocate with t5",
+		"CREATE TABLE t4(a int, b int, primary key(a, b)) partition by hash(a, b) colocate with t1",
+	}
+
+	results := []string{
+		"",
+		"",
+		"",
+		"router.colocate.shardtype[LIST].mismatch.with.table[t1].shardtype[HASH] (errno 1105) (sqlstate HY000)",
+		"The unique/primary constraint should be only defined on the sharding key column[a] (errno 1105) (sqlstate HY000)",
+		"Table 't5' doesn't exist (errno 1146) (sqlstate 42S02)",
+		"router.compute.colocate.shard.key[a,b].mismatch.with.table[t1].shard.key[a] (errno 1105) (sqlstate HY000)",
+	}
+
+	for i, query := range querys {
+		client, err := driver.NewConn("mock", "mock", address, "test", "utf8")
+		assert.Nil(t, err)
+		_, err = client.FetchAll(query, -1)
+		want := results[i]
+		if want == "" {
+			assert.Nil(t, err)
+		} else {
+			assert.NotNil(t, err)
+			assert.Equal(t, want, err.Error())
+		}
+	}
+
+	// The tables are in the same colocate group.
+	route := proxy.Router()
+	for _, table := range []string{"t1", "t2", "t3"} {
+		tConf, err := route.TableConfig("test", table)
+		assert.Nil(t, err)
+		assert.Equal(t, "t1", tConf.ColocateGroup)
+	}
+	tConf, err := route.TableConfig("test", "t3")
+	assert.Nil(t, err)
+	assert.Equal(t, "b", tConf.ShardKey)
+}
+
+func TestProxyDDLHashFunction(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	fakedbs, proxy, cleanup := MockProxy(log)
+	defer cleanup()
+	address := proxy.Address()
+
+	// fakedbs.
+	{
+		fakedbs.AddQueryPattern("use .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("create .*", &sqltypes.Result{})
+	}
+
+	// create database.
+	{
+		client, err := driver.NewConn("mock", "mock", address, "", "utf8")
+		assert.Nil(t, err)
+		query := "create database test"
+		_, err = client.FetchAll(query, -1)
+		assert.Nil(t, err)
+	}
+
+	querys := []string{
+		"CREATE TABLE t1(a int primary key, b int) partition by hash(a) using 'crc32-mod'",
+		"CREATE TABLE t2(a int, b int, primary key(a, b)) partition by hash(a, b) partitions 8 using 'mod'",
+		"CREATE TABLE t3(a int primary key, b int) partition by hash(a) colocate with t1",
+		"CREATE TABLE t4(a int primary key, b int) partition by hash(a) using 'md5'",
+		"CREATE TABLE t4(a int primary key, b int) partition by hash(a) using crc32",
+	}
+
+	results := []string{
+		"",
+		"",
+		"",
+		"router.hash.function[md5].is.unsupported (errno 1105) (sqlstate HY000)",
+		"You have an error in your SQL syntax; check the manual that corresponds to your MySQL server version for the right syntax to use, syntax error at position 75 near 'crc32' (errno 1149) (sqlstate 42000)",
+	}
+
+	for i, query := range querys {
+		client, err := driver.NewConn("mock", "mock", address, "test", "utf8")
+		assert.Nil(t, err)
+		_, err = client.FetchAll(query, -1)
+		want := results[i]
+		if want == "" {
+			assert.Nil(t, err)
+		} else {
+			assert.NotNil(t, err)
+			assert.Equal(t, want, err.Error())
+		}
+	}
+
+	// The hash function is persisted and inherited by the colocate table.
+	route := proxy.Router()
+	for table, fn := range map[string]string{"t1": "crc32-mod", "t2": "mod", "t3": "crc32-mod"} {
+		tConf, err := route.TableConfig("test", table)
+		assert.Nil(t, err)
+		assert.Equal(t, fn, tConf.HashFunction)
+	}
+	_, err := route.TableConfig("test", "t4")
+	assert.NotNil(t, err)
+}
+
+func TestProxyDDLInterval(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	fakedbs, proxy, cleanup := MockProxy(log)
+	defer cleanup()
+	address := proxy.Address()
+
+	// fakedbs.
+	{
+		fakedbs.AddQueryPattern("use .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("create .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("insert .*", &sqltypes.Result{})
+	}
+
+	// create database.
+	{
+		client, err := driver.NewConn("mock", "mock", address, "", "utf8")
+		assert.Nil(t, err)
+		query := "create database test"
+		_, err = client.FetchAll(query, -1)
+		assert.Nil(t, err)
+	}
+
+	querys := []string{
+		"CREATE TABLE t1(a int, ts datetime) partition by interval(ts) day premake 2 retention 7",
+		"CREATE TABLE t2(a int primary key, ts datetime) partition by interval(ts) month",
+		"CREATE TABLE t2(a int, ts varchar(32)) partition by interval(ts) month",
+		"CREATE TABLE t2(a int, ts datetime) partition by interval(b) month",
+	}
+	results := []string{
+		"",
+		"The unique/primary constraint should be only defined on the sharding key column[ts] (errno 1105) (sqlstate HY000)",
+		"Sharding Key column 'ts' of the interval table must be DATETIME, DATE or TIMESTAMP (errno 1105) (sqlstate HY000)",
+		"Sharding Key column 'b' doesn't exist in table (errno 1105) (sqlstate HY000)",
+	}
+	for i, query := range querys {
+		client, err := driver.NewConn("mock", "mock", address, "test", "utf8")
+		assert.Nil(t, err)
+		_, err = client.FetchAll(query, -1)
+		want := results[i]
+		if want == "" {
+			assert.Nil(t, err)
+		} else {
+			assert.NotNil(t, err)
+			assert.Equal(t, want, err.Error())
+		}
+	}
+
+	tconf, err := proxy.Router().TableConfig("test", "t1")
+	assert.Nil(t, err)
+	assert.Equal(t, "INTERVAL", tconf.ShardType)
+	assert.Equal(t, 3, len(tconf.Partitions))
+	assert.Equal(t, 7, tconf.Interval.Retention)
+
+	// The rows are routed by the day.
+	{
+		client, err := driver.NewConn("mock", "mock", address, "test", "utf8")
+		assert.Nil(t, err)
+		today := time.Now().Format("2006-01-02")
+		_, err = client.FetchAll(fmt.Sprintf("insert into t1(a, ts) values(1, '%s 12:00:00')", today), -1)
+		assert.Nil(t, err)
+
+		_, err = client.FetchAll("insert into t1(a, ts) values(1, '2999-01-01')", -1)
+		assert.NotNil(t, err)
+	}
+}
+
 func TestProxyDDLAlterRename(t *testing.T) {
 	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
 	fakedbs, proxy, cleanup := MockProxy(log)
@@ -13,6 +13,7 @@ import (
 	"strings"
 
 	"github.com/sealdb/neodb/executor"
+	"github.com/sealdb/neodb/executor/engine"
 	"github.com/sealdb/neodb/optimizer"
 	"github.com/sealdb/neodb/planner"
 	"github.com/sealdb/neodb/planner/builder"
@@ -25,16 +26,30 @@ import (
 	"github.com/sealdb/mysqlstack/sqlparser/depends/sqltypes"
 )
 
+// buildPlanTree used to build the plan tree of the query, the auto-increment column of the
+// rows read by the insert ... select is filled by the plugin.
+func (spanner *Spanner) buildPlanTree(database string, query string, node sqlparser.Statement) (*planner.PlanTree, error) {
+	plans, err := optimizer.NewSimpleOptimizer(spanner.log, database, query, node, spanner.router).BuildPlanTree()
+	if err != nil {
+		return nil, err
+	}
+	for _, plan := range plans.Plans() {
+		if insert, ok := plan.(*planner.InsertPlan); ok {
+			insert.AutoIncrement = spanner.plugins.PlugAutoIncrement().Process
+		}
+	}
+	return plans, nil
+}
+
 // ExecuteMultiStmtsInTxn used to execute multiple statements in the transaction.
 func (spanner *Spanner) ExecuteMultiStmtsInTxn(session *driver.Session, database string, query string, node sqlparser.Statement) (*sqltypes.Result, error) {
 	log := spanner.log
-	router := spanner.router
 	sessions := spanner.sessions
 	txSession := sessions.getTxnSession(session)
 
 	sessions.MultiStmtTxnBinding(session, nil, node, query)
 
-	plans, err := optimizer.NewSimpleOptimizer(log, database, query, node, router).BuildPlanTree()
+	plans, err := spanner.buildPlanTree(database, query, node)
 	if err != nil {
 		return nil, err
 	}
@@ -53,7 +68,6 @@ func (spanner *Spanner) ExecuteMultiStmtsInTxn(session *driver.Session, database
 func (spanner *Spanner) ExecuteSingleStmtTxnTwoPC(session *driver.Session, database string, query string, node sqlparser.Statement) (*sqltypes.Result, error) {
 	log := spanner.log
 	conf := spanner.conf
-	router := spanner.router
 	scatter := spanner.scatter
 	sessions := spanner.sessions
 
@@ -69,6 +83,11 @@ func (spanner *Spanner) ExecuteSingleStmtTxnTwoPC(session *driver.Session, datab
 	txn.SetTimeout(conf.Proxy.QueryTimeout)
 	txn.SetMaxResult(conf.Proxy.MaxResultSize)
 	txn.SetMaxJoinRows(conf.Proxy.MaxJoinRows)
+	txn.SetMaxQueryMemory(conf.Proxy.MaxQueryMemory)
+	txn.SetSpillDir(conf.Proxy.SpillDir)
+	txn.SetMaxSpillSize(conf.Proxy.MaxSpillSize)
+	txn.SetMaxShardKeyUpdateRows(conf.Proxy.MaxShardKeyUpdateRows)
+	txn.SetMaxRecursionDepth(conf.Proxy.CTEMaxRecursionDepth)
 	txn.SetIsExecOnRep(isExecOnRep(conf.Proxy.LoadBalance, node))
 
 	// binding.
@@ -82,7 +101,7 @@ func (spanner *Spanner) ExecuteSingleStmtTxnTwoPC(session *driver.Session, datab
 	}
 
 	// Transaction execute.
-	plans, err := optimizer.NewSimpleOptimizer(log, database, query, node, router).BuildPlanTree()
+	plans, err := spanner.buildPlanTree(database, query, node)
 	if err != nil {
 		return nil, err
 	}
@@ -131,7 +150,6 @@ func (spanner *Spanner) ExecuteDDL(session *driver.Session, database string, que
 func (spanner *Spanner) executeWithTimeout(session *driver.Session, database string, query string, node sqlparser.Statement, timeout int) (*sqltypes.Result, error) {
 	log := spanner.log
 	conf := spanner.conf
-	router := spanner.router
 	scatter := spanner.scatter
 	sessions := spanner.sessions
 
@@ -147,13 +165,18 @@ func (spanner *Spanner) executeWithTimeout(session *driver.Session, database str
 	txn.SetTimeout(timeout)
 	txn.SetMaxResult(conf.Proxy.MaxResultSize)
 	txn.SetMaxJoinRows(conf.Proxy.MaxJoinRows)
+	txn.SetMaxQueryMemory(conf.Proxy.MaxQueryMemory)
+	txn.SetSpillDir(conf.Proxy.SpillDir)
+	txn.SetMaxSpillSize(conf.Proxy.MaxSpillSize)
+	txn.SetMaxShardKeyUpdateRows(conf.Proxy.MaxShardKeyUpdateRows)
+	txn.SetMaxRecursionDepth(conf.Proxy.CTEMaxRecursionDepth)
 	txn.SetIsExecOnRep(isExecOnRep(conf.Proxy.LoadBalance, node))
 
 	// binding.
 	sessions.TxnBinding(session, txn, node, query)
 	defer sessions.TxnUnBinding(session)
 
-	plans, err := optimizer.NewSimpleOptimizer(log, database, query, node, router).BuildPlanTree()
+	plans, err := spanner.buildPlanTree(database, query, node)
 	if err != nil {
 		return nil, err
 	}
@@ -200,10 +223,23 @@ func (spanner *Spanner) ExecuteStreamFetch(session *driver.Session, database str
 	if !ok {
 		return errors.New("ExecuteStreamFetch.unsupport.cross-shard.join")
 	}
+	// The routes are pruned by the lookup tables read in the transaction.
+	keep, err := engine.PruneRoutes(txn, m.LookupPrunes())
+	if err != nil {
+		return err
+	}
+	var querys []xcontext.QueryTuple
+	for i, query := range m.GetQuery() {
+		if keep == nil || keep[i] {
+			querys = append(querys, query)
+		}
+	}
 	reqCtx := xcontext.NewRequestContext()
 	reqCtx.Mode = m.ReqMode
-	reqCtx.Querys = m.GetQuery()
+	reqCtx.Querys = querys
 	reqCtx.RawQuery = plan.RawQuery
+	// The sorted rows of the shards are merged and sent in order.
+	reqCtx.OrderBy, reqCtx.Offset, reqCtx.Limit = m.MergeOrder()
 	streamBufferSize := spanner.conf.Proxy.StreamBufferSize
 	return txn.ExecuteStreamFetch(reqCtx, callback, streamBufferSize)
 }
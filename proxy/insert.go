@@ -35,10 +35,11 @@ func (spanner *Spanner) handleInsert(session *driver.Session, query string, node
 		return nil, err
 	}
 	// If single or global table, just send sql to backends directly.
-	if methodType == router.MethodTypeHash || methodType == router.MethodTypeList {
+	if methodType == router.MethodTypeHash || methodType == router.MethodTypeList || methodType == router.MethodTypeRange ||
+		methodType == router.MethodTypeInterval {
 		// Pre-filled columns after table for insert if node.Columns is nil.
 		// For statement "insert into t ... set ...", the columns will never be nil.
-		// For statement "insert ... select...", the columns may be nil, but we hasn`t support yet.
+		// For statement "insert ... select...", the columns may be nil.
 		// For statement "insert ... values...", the columns may be nil.
 		// e.g.: "insert into t values(...),(...),..."--->"insert into t(c1,c2,c3,...) values(...),(...),...".
 		if nodePtr.Columns == nil {
@@ -65,5 +66,9 @@ func (spanner *Spanner) handleInsert(session *driver.Session, query string, node
 		}
 	}
 
-	return spanner.ExecuteDML(session, database, query, node)
+	qr, err := spanner.ExecuteDML(session, database, query, node)
+	if err != nil {
+		return nil, spanner.lookupDupEntryError(database, table, err)
+	}
+	return qr, nil
 }
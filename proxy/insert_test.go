@@ -304,3 +304,60 @@ func TestProxyInsertWithoutColumns(t *testing.T) {
 		}
 	}
 }
+
+func TestProxyInsertSelect(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	fakedbs, proxy, cleanup := MockProxy(log)
+	defer cleanup()
+	address := proxy.Address()
+
+	rows := &sqltypes.Result{
+		Fields: []*querypb.Field{
+			{Name: "b", Type: querypb.Type_INT32},
+		},
+		Rows: [][]sqltypes.Value{{sqltypes.NewInt32(2)}},
+	}
+
+	// fakedbs.
+	{
+		fakedbs.AddQueryPattern("create .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("select b from .*", rows)
+		fakedbs.AddQueryPattern("insert .*", &sqltypes.Result{RowsAffected: 1})
+	}
+
+	client, err := driver.NewConn("mock", "mock", address, "", "utf8")
+	assert.Nil(t, err)
+	defer client.Close()
+
+	// create test table.
+	{
+		_, err = client.FetchAll("create database test", -1)
+		assert.Nil(t, err)
+		_, err = client.FetchAll("create table test.t1(`id` bigint(20) unsigned NOT NULL AUTO_INCREMENT key, b int) partition by hash(id)", -1)
+		assert.Nil(t, err)
+		_, err = client.FetchAll("create table test.t2(id int, b int) partition by hash(id)", -1)
+		assert.Nil(t, err)
+	}
+
+	// The rows are inserted on the co-located shards.
+	{
+		qr, err := client.FetchAll("explain insert into test.t1(id, b) select id, b from test.t2", -1)
+		assert.Nil(t, err)
+		assert.Contains(t, qr.Rows[0][0].String(), "insert into test.t1_0000(id, b) select id, b from test.t2_0000 as t2")
+		assert.NotContains(t, qr.Rows[0][0].String(), `"Select"`)
+
+		_, err = client.FetchAll("insert into test.t1(id, b) select id, b from test.t2", -1)
+		assert.Nil(t, err)
+	}
+
+	// The rows are read by the proxy and the auto-increment column is filled.
+	{
+		qr, err := client.FetchAll("explain insert into test.t1(b) select b from test.t2", -1)
+		assert.Nil(t, err)
+		assert.Contains(t, qr.Rows[0][0].String(), `"Select"`)
+
+		qr, err = client.FetchAll("insert into test.t1(b) select b from test.t2", -1)
+		assert.Nil(t, err)
+		assert.NotZero(t, qr.RowsAffected)
+	}
+}
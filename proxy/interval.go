@@ -0,0 +1,235 @@
+/*
+ * NeoDB
+ *
+ * Copyright 2021-2030 The NeoDB Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package proxy
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sealdb/neodb/router"
+
+	querypb "github.com/sealdb/mysqlstack/sqlparser/depends/query"
+	"github.com/sealdb/mysqlstack/sqlparser/depends/sqltypes"
+	"github.com/sealdb/mysqlstack/xlog"
+)
+
+// IntervalStatus tuple, the last run of the interval table.
+type IntervalStatus struct {
+	LastRun   time.Time
+	Premade   int
+	Retired   int
+	LastError string
+}
+
+// IntervalScheduler tuple.
+// It pre-creates the partitions of the interval tables ahead of time,
+// and drops(or detaches) the partitions out of the retention window.
+type IntervalScheduler struct {
+	log     *xlog.Log
+	spanner *Spanner
+	done    chan bool
+	ticker  *time.Ticker
+	wg      sync.WaitGroup
+
+	// mu serializes the runs and protects the status.
+	mu     sync.Mutex
+	status map[string]*IntervalStatus
+}
+
+// NewIntervalScheduler creates the IntervalScheduler tuple.
+func NewIntervalScheduler(log *xlog.Log, spanner *Spanner) *IntervalScheduler {
+	return &IntervalScheduler{
+		log:     log,
+		spanner: spanner,
+		done:    make(chan bool),
+		ticker:  time.NewTicker(time.Duration(time.Minute)), // 1 minute.
+		status:  make(map[string]*IntervalStatus),
+	}
+}
+
+// Init used to init the interval scheduler goroutine, the goroutine is started only if
+// the interval-scheduler of the node is enabled, so that one node owns the scheduling.
+func (is *IntervalScheduler) Init() error {
+	log := is.log
+
+	if !is.spanner.conf.Proxy.IntervalScheduler {
+		is.ticker.Stop()
+		log.Info("interval.scheduler.disabled.on.this.node")
+		return nil
+	}
+	is.wg.Add(1)
+	go func(is *IntervalScheduler) {
+		defer is.wg.Done()
+		is.schedule()
+	}(is)
+	log.Info("interval.scheduler.init.done")
+	return nil
+}
+
+// Close used to close the interval scheduler goroutine.
+func (is *IntervalScheduler) Close() {
+	close(is.done)
+	is.wg.Wait()
+}
+
+func (is *IntervalScheduler) schedule() {
+	defer is.ticker.Stop()
+	for {
+		select {
+		case <-is.ticker.C:
+			if is.spanner.ReadOnly() {
+				continue
+			}
+			is.Run(time.Now())
+		case <-is.done:
+			return
+		}
+	}
+}
+
+// Run used to premake and retire the partitions of all the interval tables at now,
+// returns the first error.
+func (is *IntervalScheduler) Run(now time.Time) error {
+	is.mu.Lock()
+	defer is.mu.Unlock()
+
+	var firstErr error
+	route := is.spanner.router
+	for database, tables := range route.Tables() {
+		for _, table := range tables {
+			conf, err := route.TableConfig(database, table)
+			if err != nil || conf.ShardType != router.MethodTypeInterval {
+				continue
+			}
+
+			status := &IntervalStatus{LastRun: now}
+			status.Premade, status.Retired, err = is.runTable(database, table, now)
+			if err != nil {
+				is.log.Error("interval.scheduler.run[%s.%s].error:%+v", database, table, err)
+				status.LastError = err.Error()
+				if firstErr == nil {
+					firstErr = err
+				}
+			}
+			is.status[database+"."+table] = status
+		}
+	}
+	return firstErr
+}
+
+// runTable used to premake and retire the partitions of the interval table.
+// The sub tables are created before the router is updated, so that the rows can always be written,
+// and the router is updated before the sub tables are dropped, so that no query routes to them.
+func (is *IntervalScheduler) runTable(database, table string, now time.Time) (int, int, error) {
+	log := is.log
+	spanner := is.spanner
+	route := spanner.router
+
+	conf, err := route.TableConfig(database, table)
+	if err != nil {
+		return 0, 0, err
+	}
+	parts, err := router.IntervalPremake(conf, spanner.scatter.Backends(), now)
+	if err != nil {
+		return 0, 0, err
+	}
+	if len(parts) > 0 {
+		if err := spanner.createPartitionTables(database, conf.Partitions[len(conf.Partitions)-1], parts); err != nil {
+			return 0, 0, err
+		}
+		if err := route.AddIntervalPartitions(database, table, parts); err != nil {
+			return 0, 0, err
+		}
+		log.Warning("interval.scheduler[%s.%s].premade.partitions:%d", database, table, len(parts))
+	}
+
+	if conf, err = route.TableConfig(database, table); err != nil {
+		return len(parts), 0, err
+	}
+	expired, err := router.IntervalExpired(conf, now)
+	if err != nil || len(expired) == 0 {
+		return len(parts), 0, err
+	}
+	if err := route.RemoveIntervalPartitions(database, table, expired); err != nil {
+		return len(parts), 0, err
+	}
+	if conf.Interval.Detach {
+		for _, part := range expired {
+			log.Warning("interval.scheduler[%s.%s].detached.partition[%s].on.backend[%s]", database, table, part.Table, part.Backend)
+		}
+		return len(parts), len(expired), nil
+	}
+	return len(parts), len(expired), spanner.dropPartitionTables(database, expired)
+}
+
+// Status returns the partitions and the last run of the interval tables.
+func (is *IntervalScheduler) Status() *sqltypes.Result {
+	is.mu.Lock()
+	defer is.mu.Unlock()
+
+	qr := &sqltypes.Result{}
+	qr.Fields = []*querypb.Field{
+		{Name: "Table", Type: querypb.Type_VARCHAR},
+		{Name: "Interval", Type: querypb.Type_VARCHAR},
+		{Name: "Partitions", Type: querypb.Type_INT64},
+		{Name: "Oldest", Type: querypb.Type_VARCHAR},
+		{Name: "Newest", Type: querypb.Type_VARCHAR},
+		{Name: "Last_Run", Type: querypb.Type_VARCHAR},
+		{Name: "Premade", Type: querypb.Type_INT64},
+		{Name: "Retired", Type: querypb.Type_INT64},
+		{Name: "Last_Error", Type: querypb.Type_VARCHAR},
+	}
+
+	var names []string
+	route := is.spanner.router
+	for database, tables := range route.Tables() {
+		for _, table := range tables {
+			names = append(names, database+"."+table)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		idx := strings.Index(name, ".")
+		conf, err := route.TableConfig(name[:idx], name[idx+1:])
+		if err != nil || conf.ShardType != router.MethodTypeInterval {
+			continue
+		}
+
+		interval := fmt.Sprintf("%s PREMAKE %d RETENTION %d", conf.Interval.Unit, conf.Interval.Premake, conf.Interval.Retention)
+		if conf.Interval.Detach {
+			interval += " DETACH"
+		}
+		oldest, newest := conf.Partitions[0], conf.Partitions[len(conf.Partitions)-1]
+		status, ok := is.status[name]
+		if !ok {
+			status = &IntervalStatus{}
+		}
+		var lastRun string
+		if !status.LastRun.IsZero() {
+			lastRun = status.LastRun.Format(router.IntervalLayout)
+		}
+		row := []sqltypes.Value{
+			sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(name)),
+			sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(interval)),
+			sqltypes.MakeTrusted(querypb.Type_INT64, []byte(fmt.Sprintf("%d", len(conf.Partitions)))),
+			sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(fmt.Sprintf("%s(<%s)", oldest.Table, oldest.LessThan))),
+			sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(fmt.Sprintf("%s(<%s)", newest.Table, newest.LessThan))),
+			sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(lastRun)),
+			sqltypes.MakeTrusted(querypb.Type_INT64, []byte(fmt.Sprintf("%d", status.Premade))),
+			sqltypes.MakeTrusted(querypb.Type_INT64, []byte(fmt.Sprintf("%d", status.Retired))),
+			sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(status.LastError)),
+		}
+		qr.Rows = append(qr.Rows, row)
+	}
+	qr.RowsAffected = uint64(len(qr.Rows))
+	return qr
+}
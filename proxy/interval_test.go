@@ -0,0 +1,117 @@
+/*
+ * NeoDB
+ *
+ * Copyright 2021-2030 The NeoDB Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package proxy
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sealdb/mysqlstack/driver"
+	querypb "github.com/sealdb/mysqlstack/sqlparser/depends/query"
+	"github.com/sealdb/mysqlstack/sqlparser/depends/sqltypes"
+	"github.com/sealdb/mysqlstack/xlog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIntervalScheduler(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	fakedbs, proxy, cleanup := MockProxy(log)
+	defer cleanup()
+	address := proxy.Address()
+
+	showCreate := &sqltypes.Result{
+		Fields: []*querypb.Field{
+			{Name: "Table", Type: querypb.Type_VARCHAR},
+			{Name: "Create Table", Type: querypb.Type_VARCHAR},
+		},
+		Rows: [][]sqltypes.Value{
+			{
+				sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("iv_0001")),
+				sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("CREATE TABLE `iv_0001` (\n  `id` int(11) DEFAULT NULL,\n  `ts` datetime DEFAULT NULL\n) ENGINE=InnoDB")),
+			},
+		},
+	}
+
+	// fakedbs.
+	{
+		fakedbs.AddQueryPattern("use .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("create .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("drop .*", &sqltypes.Result{})
+		fakedbs.AddQuery("show create table `test`.`iv_0001`", showCreate)
+	}
+
+	// create database and table.
+	{
+		client, err := driver.NewConn("mock", "mock", address, "", "utf8")
+		assert.Nil(t, err)
+		defer client.Close()
+		_, err = client.FetchAll("create database test", -1)
+		assert.Nil(t, err)
+		_, err = client.FetchAll("create table test.iv(id int, ts datetime) partition by interval(ts) day premake 1 retention 1", -1)
+		assert.Nil(t, err)
+	}
+
+	route := proxy.Router()
+	scheduler := proxy.Spanner().interval
+	tconf, err := route.TableConfig("test", "iv")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(tconf.Partitions))
+
+	// Two days later, two partitions are premade and one is retired.
+	{
+		err := scheduler.Run(time.Now().AddDate(0, 0, 2))
+		assert.Nil(t, err)
+
+		tconf, err := route.TableConfig("test", "iv")
+		assert.Nil(t, err)
+		assert.Equal(t, 3, len(tconf.Partitions))
+		assert.Equal(t, "iv_0001", tconf.Partitions[0].Table)
+		assert.Equal(t, "iv_0003", tconf.Partitions[2].Table)
+		assert.Equal(t, 1, fakedbs.GetQueryCalledNum("create table if not exists `test`.`iv_0003` (\n  `id` int(11) default null,\n  `ts` datetime default null\n) engine=innodb"))
+		assert.Equal(t, 1, fakedbs.GetQueryCalledNum("drop table if exists `test`.`iv_0000`"))
+
+		client, err := driver.NewConn("mock", "mock", address, "", "utf8")
+		assert.Nil(t, err)
+		defer client.Close()
+		qr, err := client.FetchAll("neodb interval status", -1)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(qr.Rows))
+		assert.Equal(t, "test.iv", qr.Rows[0][0].String())
+		assert.Equal(t, "DAY PREMAKE 1 RETENTION 1", qr.Rows[0][1].String())
+		assert.Equal(t, "3", qr.Rows[0][2].String())
+		assert.Equal(t, "2", qr.Rows[0][6].String())
+		assert.Equal(t, "1", qr.Rows[0][7].String())
+		assert.Equal(t, "", qr.Rows[0][8].String())
+	}
+
+	// Nothing to do.
+	{
+		client, err := driver.NewConn("mock", "mock", address, "", "utf8")
+		assert.Nil(t, err)
+		defer client.Close()
+		qr, err := client.FetchAll("neodb interval run", -1)
+		assert.Nil(t, err)
+		assert.Equal(t, "0", qr.Rows[0][6].String())
+		assert.Equal(t, "0", qr.Rows[0][7].String())
+	}
+
+	// The template sub table can't be shown.
+	{
+		err := scheduler.Run(time.Now().AddDate(0, 0, 5))
+		assert.NotNil(t, err)
+
+		tconf, err := route.TableConfig("test", "iv")
+		assert.Nil(t, err)
+		assert.Equal(t, 3, len(tconf.Partitions))
+
+		qr := scheduler.Status()
+		assert.Equal(t, 1, len(qr.Rows))
+		assert.NotEqual(t, "", qr.Rows[0][8].String())
+	}
+}
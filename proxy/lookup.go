@@ -0,0 +1,247 @@
+/*
+ * NeoDB
+ *
+ * Copyright 2021-2030 The NeoDB Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package proxy
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/sealdb/neodb/config"
+	"github.com/sealdb/neodb/planner"
+	"github.com/sealdb/neodb/router"
+
+	"github.com/sealdb/mysqlstack/driver"
+	"github.com/sealdb/mysqlstack/sqldb"
+	"github.com/sealdb/mysqlstack/sqlparser"
+)
+
+// lookupBackfillBatch is the max number of the pairs inserted into the lookup table by one query.
+const lookupBackfillBatch = 1000
+
+// erDupEntry is the MySQL error ER_DUP_ENTRY.
+const erDupEntry = 1062
+
+// createLookupIndex used to create the lookup table and build the lookup index of the table.
+// The lookup table is a hash table sharded by the column, maps the column to the shard key.
+// The index is maintained by the DMLs once added, and used for routing after the backfill is done.
+// The unique index has a unique key on the column, so the duplicate value fails the DMLs.
+func (spanner *Spanner) createLookupIndex(session *driver.Session, database, table, name, column string, unique bool) error {
+	log := spanner.log
+	route := spanner.router
+
+	// The rows and their pairs on the other backends are written atomically only by the XA.
+	if !spanner.isTwoPC() {
+		return fmt.Errorf("unsupported: lookup.index.without.twopc")
+	}
+
+	tableConf, err := route.TableConfig(database, table)
+	if err != nil {
+		return err
+	}
+	if tableConf.ShardKey == "" || len(router.SplitShardKey(tableConf.ShardKey)) > 1 {
+		return fmt.Errorf("unsupported: lookup.index.on.table[%s].without.single.shard.key", table)
+	}
+	if strings.EqualFold(column, tableConf.ShardKey) {
+		return fmt.Errorf("unsupported: lookup.index.column[%s].is.the.shard.key", column)
+	}
+	if checkTableExists(database, name, route) {
+		return fmt.Errorf("lookup.index.table[%s].already.exists", name)
+	}
+
+	// The column types of the lookup table are the same as the table.
+	template := tableConf.Partitions[0]
+	query := fmt.Sprintf("select column_name, column_type from information_schema.columns where table_schema='%s' and table_name='%s'", database, template.Table)
+	qr, err := spanner.ExecuteOnThisBackend(template.Backend, query)
+	if err != nil {
+		return err
+	}
+	types := make(map[string]string)
+	for _, row := range qr.Rows {
+		if len(row) < 2 {
+			continue
+		}
+		types[strings.ToLower(row[0].ToString())] = row[1].ToString()
+	}
+	colType, ok := types[strings.ToLower(column)]
+	if !ok {
+		return fmt.Errorf("lookup.index.column[%s].doesn't.exist.in.table[%s]", column, table)
+	}
+	keyType, ok := types[strings.ToLower(tableConf.ShardKey)]
+	if !ok {
+		return fmt.Errorf("lookup.index.shard.key[%s].doesn't.exist.in.table[%s]", tableConf.ShardKey, table)
+	}
+
+	// Create the lookup table.
+	if err := route.CreateHashTable(database, name, column, router.TableTypePartitionHash, spanner.scatter.Backends(), nil, &router.Extra{}); err != nil {
+		return err
+	}
+	create := fmt.Sprintf("create table `%s`.`%s`(`%s` %s, `%s` %s, primary key(`%s`, `%s`)) engine=innodb", database, name, column, colType, tableConf.ShardKey, keyType, column, tableConf.ShardKey)
+	if unique {
+		// The key is named as the index, the ER_DUP_ENTRY reports it.
+		create = fmt.Sprintf("create table `%s`.`%s`(`%s` %s not null, `%s` %s, unique key `%s`(`%s`)) engine=innodb", database, name, column, colType, tableConf.ShardKey, keyType, name, column)
+	}
+	node, err := sqlparser.Parse(create)
+	if err != nil {
+		route.DropTable(database, name)
+		return err
+	}
+	if _, err := spanner.ExecuteDDL(session, database, sqlparser.String(node), node); err != nil {
+		route.DropTable(database, name)
+		return err
+	}
+
+	index := &config.LookupIndexConfig{Name: name, Column: column, Unique: unique, Building: true}
+	if err := route.AddLookupIndex(database, table, index); err != nil {
+		if x := spanner.dropLookupTable(session, database, name); x != nil {
+			log.Error("spanner.lookup.index[%s].drop.table.error[%+v]", name, x)
+		}
+		return err
+	}
+
+	// Backfill the pairs of the existing rows, the pairs already written by the DMLs are ignored.
+	if err := spanner.backfillLookupIndex(database, tableConf, index); err != nil {
+		log.Error("spanner.lookup.index[%s].backfill.error[%+v]", name, err)
+		if x := route.DropLookupIndex(database, table, name); x != nil {
+			log.Error("spanner.lookup.index[%s].drop.index.error[%+v]", name, x)
+		} else if x := spanner.dropLookupTable(session, database, name); x != nil {
+			log.Error("spanner.lookup.index[%s].drop.table.error[%+v]", name, x)
+		}
+		return err
+	}
+	return route.FinishLookupIndex(database, table, name)
+}
+
+// backfillLookupIndex used to insert the pairs of the rows in every partition into the lookup table.
+// The pairs written by the DMLs during the backfill are ignored, the unique index checks all the pairs
+// are in the lookup table after the insert, otherwise the value is duplicate.
+func (spanner *Spanner) backfillLookupIndex(database string, tableConf *config.TableConfig, index *config.LookupIndexConfig) error {
+	for _, part := range tableConf.Partitions {
+		query := fmt.Sprintf("select `%s`, `%s` from `%s`.`%s` where `%s` is not null", index.Column, tableConf.ShardKey, database, part.Table, index.Column)
+		qr, err := spanner.ExecuteOnThisBackend(part.Backend, query)
+		if err != nil {
+			return err
+		}
+
+		pairs := make([]planner.LookupPair, 0, len(qr.Rows))
+		for _, row := range qr.Rows {
+			if len(row) < 2 || row[0].IsNull() || row[1].IsNull() {
+				continue
+			}
+			pairs = append(pairs, planner.LookupPair{Value: router.SQLValFromValue(row[0]), Key: router.SQLValFromValue(row[1])})
+		}
+		for len(pairs) > 0 {
+			n := len(pairs)
+			if n > lookupBackfillBatch {
+				n = lookupBackfillBatch
+			}
+			querys, err := planner.BuildLookupInserts(spanner.router, database, tableConf.ShardKey, index, pairs[:n], true)
+			if err != nil {
+				return err
+			}
+			for _, q := range querys {
+				if _, err := spanner.ExecuteOnThisBackend(q.Backend, q.Query); err != nil {
+					return err
+				}
+			}
+			if index.Unique {
+				if err := spanner.checkLookupPairs(database, tableConf.ShardKey, index, pairs[:n]); err != nil {
+					return err
+				}
+			}
+			pairs = pairs[n:]
+		}
+	}
+	return nil
+}
+
+// checkLookupPairs used to check the pairs are all in the unique index,
+// the pair is ignored by the insert if the value belongs to another row.
+func (spanner *Spanner) checkLookupPairs(database, shardKey string, index *config.LookupIndexConfig, pairs []planner.LookupPair) error {
+	querys, err := planner.BuildLookupSelects(spanner.router, database, shardKey, index, pairs)
+	if err != nil {
+		return err
+	}
+	found := make(map[string]bool)
+	for _, q := range querys {
+		qr, err := spanner.ExecuteOnThisBackend(q.Backend, q.Query)
+		if err != nil {
+			return err
+		}
+		for _, row := range qr.Rows {
+			if len(row) < 2 {
+				continue
+			}
+			found[strings.ToLower(row[0].ToString())+"\x00"+row[1].ToString()] = true
+		}
+	}
+	for _, pair := range pairs {
+		if !found[strings.ToLower(string(pair.Value.Val))+"\x00"+string(pair.Key.Val)] {
+			return sqldb.NewSQLError1(erDupEntry, "23000", "Duplicate entry '%s' for key '%s'", pair.Value.Val, index.Name)
+		}
+	}
+	return nil
+}
+
+// dupEntryRegexp matches the message of ER_DUP_ENTRY, the key is prefixed by the table since MySQL 8.0.19.
+var dupEntryRegexp = regexp.MustCompile(`^Duplicate entry '(.*)' for key '(?:[^']*\.)?([^'.]+)'$`)
+
+// lookupDupEntryError returns the ER_DUP_ENTRY on the unique global index of the table
+// instead of the backend error on the lookup table, other errors are returned as is.
+func (spanner *Spanner) lookupDupEntryError(database, table string, err error) error {
+	sqlErr, ok := err.(*sqldb.SQLError)
+	if !ok || sqlErr.Num != erDupEntry {
+		return err
+	}
+	matches := dupEntryRegexp.FindStringSubmatch(sqlErr.Message)
+	if matches == nil {
+		return err
+	}
+	tableConf, x := spanner.router.TableConfig(database, table)
+	if x != nil {
+		return err
+	}
+	for _, index := range tableConf.LookupIndexes {
+		if index.Unique && index.Name == matches[2] {
+			return sqldb.NewSQLError1(erDupEntry, sqlErr.State, "Duplicate entry '%s' for key '%s'", matches[1], index.Name)
+		}
+	}
+	return err
+}
+
+// dropLookupIndex used to drop the lookup index from the table and drop the lookup table.
+func (spanner *Spanner) dropLookupIndex(session *driver.Session, database, table, name string) error {
+	// The router is updated first, so that the DMLs don't write the lookup table any more.
+	if err := spanner.router.DropLookupIndex(database, table, name); err != nil {
+		return err
+	}
+	return spanner.dropLookupTable(session, database, name)
+}
+
+// dropLookupTable used to drop the lookup table on the backends and the router.
+func (spanner *Spanner) dropLookupTable(session *driver.Session, database, name string) error {
+	log := spanner.log
+	route := spanner.router
+
+	query := fmt.Sprintf("drop table `%s`.`%s`", database, name)
+	node, err := sqlparser.Parse(query)
+	if err != nil {
+		return err
+	}
+	ddl := node.(*sqlparser.DDL)
+	ddl.Table = ddl.Tables[0]
+	_, err = spanner.ExecuteDDL(session, database, query, ddl)
+	if err != nil {
+		log.Error("spanner.ddl.execute[%v].error[%+v]", query, err)
+	}
+	if x := route.DropTable(database, name); x != nil {
+		log.Error("spanner.ddl.router.drop.table[%s].error[%+v]", name, x)
+	}
+	return err
+}
@@ -0,0 +1,316 @@
+/*
+ * NeoDB
+ *
+ * Copyright 2021-2030 The NeoDB Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package proxy
+
+import (
+	"testing"
+
+	"github.com/sealdb/neodb/config"
+
+	"github.com/sealdb/mysqlstack/driver"
+	"github.com/sealdb/mysqlstack/sqldb"
+	"github.com/sealdb/mysqlstack/sqlparser"
+	querypb "github.com/sealdb/mysqlstack/sqlparser/depends/query"
+	"github.com/sealdb/mysqlstack/sqlparser/depends/sqltypes"
+	"github.com/sealdb/mysqlstack/xlog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProxyLookupIndex(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	fakedbs, proxy, cleanup := MockProxy(log)
+	defer cleanup()
+	address := proxy.Address()
+	route := proxy.Router()
+	proxy.SetTwoPC(true)
+
+	columns := &sqltypes.Result{
+		Fields: []*querypb.Field{
+			{Name: "column_name", Type: querypb.Type_VARCHAR},
+			{Name: "column_type", Type: querypb.Type_VARCHAR},
+		},
+		Rows: [][]sqltypes.Value{
+			{sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("a")), sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("int(11)"))},
+			{sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("name")), sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("varchar(20)"))},
+		},
+	}
+	pairs := &sqltypes.Result{
+		Fields: []*querypb.Field{
+			{Name: "name", Type: querypb.Type_VARCHAR},
+			{Name: "a", Type: querypb.Type_INT32},
+		},
+		Rows: [][]sqltypes.Value{
+			{sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("x")), sqltypes.MakeTrusted(querypb.Type_INT32, []byte("1"))},
+		},
+	}
+
+	// fakedbs.
+	{
+		fakedbs.AddQueryPattern("use .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("create .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("drop .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("insert .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("select column_name, column_type from information_schema.columns .*", columns)
+		fakedbs.AddQueryPattern("select `name`, `a` from `test`.`l_000.*` where `name` is not null", pairs)
+		fakedbs.AddQueryPattern("select distinct `name`, `a` from `test`.`l_name_.*", pairs)
+		fakedbs.AddQueryPattern("select .* from test.l_000.*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("xa .*", &sqltypes.Result{})
+	}
+
+	// create database.
+	{
+		client, err := driver.NewConn("mock", "mock", address, "", "utf8")
+		assert.Nil(t, err)
+		query := "create database test"
+		_, err = client.FetchAll(query, -1)
+		assert.Nil(t, err)
+	}
+
+	querys := []string{
+		"create table l(a int, name varchar(20)) partition by list(a)(" +
+			"partition backend1 values in (1, 2)," +
+			"partition backend2 values in (3))",
+		"create table g(a int, name varchar(20)) global",
+		"create lookup index l_name on l(name)",
+		"create lookup index l_name on l(name)",
+		"create lookup index l_a on l(a)",
+		"create lookup index g_name on g(name)",
+		"create lookup index l_name on lx(name)",
+		"drop table l_name",
+		"drop lookup index l_xx on l",
+	}
+	results := []string{
+		"",
+		"",
+		"",
+		"lookup.index.table[l_name].already.exists (errno 1105) (sqlstate HY000)",
+		"unsupported: lookup.index.column[a].is.the.shard.key (errno 1105) (sqlstate HY000)",
+		"unsupported: lookup.index.on.table[g].without.single.shard.key (errno 1105) (sqlstate HY000)",
+		"Table 'lx' doesn't exist (errno 1146) (sqlstate 42S02)",
+		"unsupported: table[l_name].is.the.lookup.index.of.table[l] (errno 1105) (sqlstate HY000)",
+		"router.table[l].lookup.index[l_xx].not.found (errno 1105) (sqlstate HY000)",
+	}
+	for i, query := range querys {
+		client, err := driver.NewConn("mock", "mock", address, "test", "utf8")
+		assert.Nil(t, err)
+		_, err = client.FetchAll(query, -1)
+		want := results[i]
+		if want == "" {
+			assert.Nil(t, err, query)
+		} else {
+			assert.NotNil(t, err, query)
+			assert.Equal(t, want, err.Error())
+		}
+	}
+
+	// The index is built.
+	{
+		tconf, err := route.TableConfig("test", "l")
+		assert.Nil(t, err)
+		assert.Equal(t, []*config.LookupIndexConfig{{Name: "l_name", Column: "name"}}, tconf.LookupIndexes)
+		iconf, err := route.TableConfig("test", "l_name")
+		assert.Nil(t, err)
+		assert.Equal(t, "name", iconf.ShardKey)
+		assert.Equal(t, 1, fakedbs.GetQueryCalledNum("select `name`, `a` from `test`.`l_0000` where `name` is not null"))
+		assert.Equal(t, 1, fakedbs.GetQueryCalledNum("select `name`, `a` from `test`.`l_0001` where `name` is not null"))
+	}
+
+	// DMLs.
+	{
+		client, err := driver.NewConn("mock", "mock", address, "test", "utf8")
+		assert.Nil(t, err)
+		_, err = client.FetchAll("insert into l(a, name) values(1, 'y')", -1)
+		assert.Nil(t, err)
+
+		// 'x' is mapped to 1 in the lookup table, only l_0000 is queried.
+		_, err = client.FetchAll("select * from l where name = 'x'", -1)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, fakedbs.GetQueryCalledNum("select * from test.l_0000 as l where name = 'x'"))
+		assert.Equal(t, 0, fakedbs.GetQueryCalledNum("select * from test.l_0001 as l where name = 'x'"))
+
+		// The lookup table is read at execution, not by the explain.
+		segments, err := route.Lookup("test", "l_name", sqlparser.NewStrVal([]byte("x")), sqlparser.NewStrVal([]byte("x")))
+		assert.Nil(t, err)
+		read := "select distinct `name`, `a` from `test`.`" + segments[0].Table + "` where `name` in ('x')"
+		assert.Equal(t, 1, fakedbs.GetQueryCalledNum(read))
+		_, err = client.FetchAll("explain select * from l where name = 'x'", -1)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, fakedbs.GetQueryCalledNum(read))
+	}
+
+	// Drop the index.
+	{
+		client, err := driver.NewConn("mock", "mock", address, "test", "utf8")
+		assert.Nil(t, err)
+		_, err = client.FetchAll("drop lookup index l_name on l", -1)
+		assert.Nil(t, err)
+		tconf, err := route.TableConfig("test", "l")
+		assert.Nil(t, err)
+		assert.Nil(t, tconf.LookupIndexes)
+		_, err = route.TableConfig("test", "l_name")
+		assert.NotNil(t, err)
+	}
+
+	// The index can't be created without the twopc.
+	{
+		proxy.SetTwoPC(false)
+		client, err := driver.NewConn("mock", "mock", address, "test", "utf8")
+		assert.Nil(t, err)
+		_, err = client.FetchAll("create lookup index l_name on l(name)", -1)
+		assert.NotNil(t, err)
+		assert.Equal(t, "unsupported: lookup.index.without.twopc (errno 1105) (sqlstate HY000)", err.Error())
+		_, err = route.TableConfig("test", "l_name")
+		assert.NotNil(t, err)
+		proxy.SetTwoPC(true)
+	}
+
+	// Drop the table drops the lookup tables.
+	{
+		client, err := driver.NewConn("mock", "mock", address, "test", "utf8")
+		assert.Nil(t, err)
+		_, err = client.FetchAll("create lookup index l_name on l(name)", -1)
+		assert.Nil(t, err)
+		_, err = client.FetchAll("drop table l", -1)
+		assert.Nil(t, err)
+		_, err = route.TableConfig("test", "l_name")
+		assert.NotNil(t, err)
+	}
+}
+
+func TestProxyUniqueGlobalIndex(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	fakedbs, proxy, cleanup := MockProxy(log)
+	defer cleanup()
+	address := proxy.Address()
+	route := proxy.Router()
+	// The rows and the pairs of the unique global index are written in the XA transaction.
+	proxy.SetTwoPC(true)
+
+	columns := &sqltypes.Result{
+		Fields: []*querypb.Field{
+			{Name: "column_name", Type: querypb.Type_VARCHAR},
+			{Name: "column_type", Type: querypb.Type_VARCHAR},
+		},
+		Rows: [][]sqltypes.Value{
+			{sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("a")), sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("int(11)"))},
+			{sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("email")), sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("varchar(20)"))},
+		},
+	}
+	pairs := &sqltypes.Result{
+		Fields: []*querypb.Field{
+			{Name: "email", Type: querypb.Type_VARCHAR},
+			{Name: "a", Type: querypb.Type_INT32},
+		},
+		Rows: [][]sqltypes.Value{
+			{sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("x")), sqltypes.MakeTrusted(querypb.Type_INT32, []byte("1"))},
+		},
+	}
+	rows := &sqltypes.Result{
+		Fields: []*querypb.Field{
+			{Name: "a", Type: querypb.Type_INT32},
+			{Name: "email", Type: querypb.Type_VARCHAR},
+		},
+		Rows: [][]sqltypes.Value{
+			{sqltypes.MakeTrusted(querypb.Type_INT32, []byte("1")), sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("x"))},
+		},
+	}
+
+	// fakedbs.
+	{
+		fakedbs.AddQueryPattern("use .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("create .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("drop .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("insert .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("update .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("delete .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("XA .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("select column_name, column_type from information_schema.columns .*", columns)
+		fakedbs.AddQueryPattern("select `email`, `a` from `test`.`u_000.*` where `email` is not null", pairs)
+		// The pair of u_email is inserted, the pair of u_bad is ignored as duplicate.
+		fakedbs.AddQueryPattern("select `email`, `a` from `test`.`u_email_.*", pairs)
+		fakedbs.AddQueryPattern("select `email`, `a` from `test`.`u_bad_.*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("select `a`, `email` from `test`.`u_000.*", rows)
+	}
+
+	// create database.
+	{
+		client, err := driver.NewConn("mock", "mock", address, "", "utf8")
+		assert.Nil(t, err)
+		query := "create database test"
+		_, err = client.FetchAll(query, -1)
+		assert.Nil(t, err)
+	}
+
+	querys := []string{
+		"create table u(a int, email varchar(20), b int) partition by list(a)(" +
+			"partition backend1 values in (1, 2)," +
+			"partition backend2 values in (3))",
+		"create unique global index u_email on u(email)",
+		"create unique global index u_bad on u(email)",
+		"insert ignore into u(a, email) values(2, 'y')",
+		"replace into u(a, email) values(2, 'y')",
+		"insert into u(a, email) values(2, 'y') on duplicate key update b=2",
+		"insert into u(a, email) values(2, 'y')",
+		"insert into u(a, email) values(2, 'z')",
+		"update u set email='w' where a=1",
+	}
+	results := []string{
+		"",
+		"",
+		"Duplicate entry 'x' for key 'u_bad' (errno 1062) (sqlstate 23000)",
+		"unsupported: replace.ignore.or.on.duplicate.with.unique.global.index[u_email] (errno 1105) (sqlstate HY000)",
+		"unsupported: replace.ignore.or.on.duplicate.with.unique.global.index[u_email] (errno 1105) (sqlstate HY000)",
+		"unsupported: replace.ignore.or.on.duplicate.with.unique.global.index[u_email] (errno 1105) (sqlstate HY000)",
+		"",
+		"Duplicate entry 'z' for key 'u_email' (errno 1062) (sqlstate 23000)",
+		"Duplicate entry 'w' for key 'u_email' (errno 1062) (sqlstate 23000)",
+	}
+	for i, query := range querys {
+		// The backends report the duplicate values on the unique index of the sub table.
+		for _, val := range []string{"z", "w"} {
+			segments, err := route.Lookup("test", "u_email", sqlparser.NewStrVal([]byte(val)), sqlparser.NewStrVal([]byte(val)))
+			if err != nil {
+				continue
+			}
+			sub := segments[0].Table
+			insert := "insert into `test`.`" + sub + "`(`email`, `a`) values ('" + val + "', "
+			dup := sqldb.NewSQLError1(1062, "23000", "Duplicate entry '%s' for key '%s.u_email'", val, sub)
+			fakedbs.AddQueryError(insert+"2)", dup)
+			fakedbs.AddQueryError(insert+"1)", dup)
+		}
+
+		client, err := driver.NewConn("mock", "mock", address, "test", "utf8")
+		assert.Nil(t, err)
+		_, err = client.FetchAll(query, -1)
+		want := results[i]
+		if want == "" {
+			assert.Nil(t, err, query)
+		} else {
+			assert.NotNil(t, err, query)
+			assert.Equal(t, want, err.Error())
+		}
+	}
+
+	tconf, err := route.TableConfig("test", "u")
+	assert.Nil(t, err)
+	assert.Equal(t, []*config.LookupIndexConfig{{Name: "u_email", Column: "email", Unique: true}}, tconf.LookupIndexes)
+	_, err = route.TableConfig("test", "u_bad")
+	assert.NotNil(t, err)
+	assert.Equal(t, 1, fakedbs.GetQueryCalledNum("create table test.u_email_0000 (\n\t`email` varchar(20) not null,\n\t`a` int(11),\n\tunique key `u_email` (`email`)\n) engine=innodb"))
+
+	// The writes are refused without the twopc.
+	{
+		proxy.SetTwoPC(false)
+		client, err := driver.NewConn("mock", "mock", address, "test", "utf8")
+		assert.Nil(t, err)
+		_, err = client.FetchAll("insert into u(a, email) values(3, 'v')", -1)
+		assert.NotNil(t, err)
+		assert.Equal(t, "unsupported: unique.global.index.write.without.twopc (errno 1105) (sqlstate HY000)", err.Error())
+	}
+}
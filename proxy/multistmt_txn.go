@@ -93,6 +93,11 @@ func (spanner *Spanner) ExecuteBegin(session *driver.Session, query string, node
 	txn.SetTimeout(conf.Proxy.QueryTimeout)
 	txn.SetMaxResult(conf.Proxy.MaxResultSize)
 	txn.SetMaxJoinRows(conf.Proxy.MaxJoinRows)
+	txn.SetMaxQueryMemory(conf.Proxy.MaxQueryMemory)
+	txn.SetSpillDir(conf.Proxy.SpillDir)
+	txn.SetMaxSpillSize(conf.Proxy.MaxSpillSize)
+	txn.SetMaxShardKeyUpdateRows(conf.Proxy.MaxShardKeyUpdateRows)
+	txn.SetMaxRecursionDepth(conf.Proxy.CTEMaxRecursionDepth)
 	txn.SetMultiStmtTxn()
 	txn.SetIsExecOnRep(false)
 
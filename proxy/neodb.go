@@ -10,6 +10,10 @@
 package proxy
 
 import (
+	"time"
+
+	"github.com/sealdb/neodb/xparser"
+
 	"github.com/pkg/errors"
 	"github.com/sealdb/mysqlstack/driver"
 	"github.com/sealdb/mysqlstack/sqldb"
@@ -18,7 +22,7 @@ import (
 	"github.com/sealdb/mysqlstack/sqlparser/depends/sqltypes"
 )
 
-// handleNeoDB used to handle the command: neodb attach/detach/attachlist.
+// handleNeoDB used to handle the command: neodb attach/detach/attachlist/interval/route.
 func (spanner *Spanner) handleNeoDB(session *driver.Session, query string, node sqlparser.Statement) (*sqltypes.Result, error) {
 	var err error
 	var qr *sqltypes.Result
@@ -29,6 +33,15 @@ func (spanner *Spanner) handleNeoDB(session *driver.Session, query string, node
 	row := snode.Row
 	var attachName string
 
+	switch snode.Action {
+	case xparser.RouteStr, xparser.RouteQueryStr:
+		if qr, err = spanner.handleRoute(session, snode); err != nil {
+			log.Error("proxy.query.neodb.[%s].error:%s", query, err)
+			return nil, err
+		}
+		return qr, nil
+	}
+
 	if row != nil {
 		if len(row) != DetachParamsCount && len(row) != AttachParamsCount {
 			return nil, errors.Errorf("spanner.query.execute.neodb.%s.error,", snode.Action)
@@ -78,6 +91,12 @@ func (spanner *Spanner) handleNeoDB(session *driver.Session, query string, node
 	case sqlparser.XARollbackStr:
 		adminXA := NewAdminXA(log, spanner.scatter, spanner.router, spanner)
 		qr, err = adminXA.Rollback()
+	case xparser.IntervalStatusStr:
+		qr = spanner.interval.Status()
+	case xparser.IntervalRunStr:
+		if err = spanner.interval.Run(time.Now()); err == nil {
+			qr = spanner.interval.Status()
+		}
 	default:
 		log.Error("proxy.neodb.unsupported[%s]", query)
 		err = sqldb.NewSQLErrorf(sqldb.ER_UNKNOWN_ERROR, "unsupported.query: %v", query)
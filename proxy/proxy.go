@@ -203,6 +203,30 @@ func (p *Proxy) SetMaxJoinRows(size int) {
 	p.conf.Proxy.MaxJoinRows = size
 }
 
+// SetMaxQueryMemory used to set the memory limit of a query before spilling to disk.
+func (p *Proxy) SetMaxQueryMemory(size int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.log.Info("proxy.SetMaxQueryMemory:[%d->%d]", p.conf.Proxy.MaxQueryMemory, size)
+	p.conf.Proxy.MaxQueryMemory = size
+}
+
+// SetMaxShardKeyUpdateRows used to set the max rows moved by one UPDATE of the shard key.
+func (p *Proxy) SetMaxShardKeyUpdateRows(rows int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.log.Info("proxy.SetMaxShardKeyUpdateRows:[%d->%d]", p.conf.Proxy.MaxShardKeyUpdateRows, rows)
+	p.conf.Proxy.MaxShardKeyUpdateRows = rows
+}
+
+// SetCTEMaxRecursionDepth used to set the max iterations of the recursive cte.
+func (p *Proxy) SetCTEMaxRecursionDepth(depth int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.log.Info("proxy.SetCTEMaxRecursionDepth:[%d->%d]", p.conf.Proxy.CTEMaxRecursionDepth, depth)
+	p.conf.Proxy.CTEMaxRecursionDepth = depth
+}
+
 // SetDDLTimeout used to set the ddl timeout.
 func (p *Proxy) SetDDLTimeout(timeout int) {
 	p.mu.Lock()
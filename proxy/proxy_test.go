@@ -46,6 +46,24 @@ func TestProxy1(t *testing.T) {
 		assert.Equal(t, 6666, proxy.conf.Proxy.MaxJoinRows)
 	}
 
+	// SetMaxQueryMemory
+	{
+		proxy.SetMaxQueryMemory(6666)
+		assert.Equal(t, 6666, proxy.conf.Proxy.MaxQueryMemory)
+	}
+
+	// SetMaxShardKeyUpdateRows
+	{
+		proxy.SetMaxShardKeyUpdateRows(6666)
+		assert.Equal(t, 6666, proxy.conf.Proxy.MaxShardKeyUpdateRows)
+	}
+
+	// SetCTEMaxRecursionDepth
+	{
+		proxy.SetCTEMaxRecursionDepth(6666)
+		assert.Equal(t, 6666, proxy.conf.Proxy.CTEMaxRecursionDepth)
+	}
+
 	// SetDDLTimeout
 	{
 		proxy.SetDDLTimeout(6666)
@@ -15,6 +15,7 @@ import (
 
 	"github.com/sealdb/neodb/monitor"
 	"github.com/sealdb/neodb/xbase"
+	"github.com/sealdb/neodb/xparser"
 
 	"github.com/sealdb/mysqlstack/driver"
 	"github.com/sealdb/mysqlstack/sqldb"
@@ -68,7 +69,7 @@ func (spanner *Spanner) ComQuery(session *driver.Session, query string, bindVari
 	query = strings.TrimSpace(query)
 	query = strings.TrimSuffix(query, ";")
 
-	node, err := sqlparser.Parse(query)
+	node, err := xparser.Parse(query)
 	if err != nil {
 		log.Error("query[%v].parser.error: %v", query, err)
 		return sqldb.NewSQLError(sqldb.ER_SYNTAX_ERROR, err.Error())
@@ -84,7 +85,7 @@ func (spanner *Spanner) ComQuery(session *driver.Session, query string, bindVari
 		}
 
 		// This sucks.
-		node, err = sqlparser.Parse(query)
+		node, err = xparser.Reparse(query)
 		if err != nil {
 			log.Error("query[%v].parser.error: %v", query, err)
 			return sqldb.NewSQLError(sqldb.ER_SYNTAX_ERROR, err.Error())
@@ -92,7 +93,7 @@ func (spanner *Spanner) ComQuery(session *driver.Session, query string, bindVari
 	}
 
 	if spanner.isLowerCaseTableNames() {
-		node = sqlparser.LowerCaseTableNames(node).(sqlparser.Statement)
+		node = xparser.LowerCaseTableNames(node)
 		query = sqlparser.String(node)
 	}
 	log.Debug("query:%v", query)
@@ -251,7 +252,7 @@ func (spanner *Spanner) ComQuery(session *driver.Session, query string, bindVari
 		}
 		spanner.auditLog(session, W, xbase.DELETE, query, qr, status)
 		return returnQuery(qr, callback, err)
-	case *sqlparser.Update:
+	case *sqlparser.Update, *xparser.MultiUpdate:
 		if qr, err = spanner.handleUpdate(session, query, node); err != nil {
 			log.Error("proxy.update[%s].from.session[%v].error:%+v", xbase.TruncateQuery(query, 256), session.ID(), err)
 			status = 1
@@ -417,7 +418,7 @@ func (spanner *Spanner) ComQuery(session *driver.Session, query string, bindVari
 // IsDML returns the DML query or not.
 func (spanner *Spanner) IsDML(node sqlparser.Statement) bool {
 	switch node.(type) {
-	case *sqlparser.Select, *sqlparser.Union, *sqlparser.Insert, *sqlparser.Delete, *sqlparser.Update:
+	case *sqlparser.Select, *sqlparser.Union, *sqlparser.Insert, *sqlparser.Delete, *sqlparser.Update, *xparser.MultiUpdate:
 		return true
 	}
 	return false
@@ -426,7 +427,7 @@ func (spanner *Spanner) IsDML(node sqlparser.Statement) bool {
 // IsDMLWrite returns the DML write or not.
 func (spanner *Spanner) IsDMLWrite(node sqlparser.Statement) bool {
 	switch node.(type) {
-	case *sqlparser.Insert, *sqlparser.Delete, *sqlparser.Update:
+	case *sqlparser.Insert, *sqlparser.Delete, *sqlparser.Update, *xparser.MultiUpdate:
 		return true
 	}
 	return false
@@ -447,7 +448,7 @@ func (spanner *Spanner) IsAdminCmd(node sqlparser.Statement) bool {
 	if node, ok := node.(*sqlparser.NeoDB); ok {
 		switch node.Action {
 		case sqlparser.AttachStr, sqlparser.DetachStr, sqlparser.ReshardStr, sqlparser.CleanupStr,
-			sqlparser.XACommitStr, sqlparser.XARollbackStr, sqlparser.RebalanceStr:
+			sqlparser.XACommitStr, sqlparser.XARollbackStr, sqlparser.RebalanceStr, xparser.IntervalRunStr:
 			return true
 		}
 	}
@@ -467,7 +468,7 @@ func queryStat(node sqlparser.Statement, timeStart time.Time, slowQueryTime time
 		command = "Insert"
 	case *sqlparser.Delete:
 		command = "Delete"
-	case *sqlparser.Update:
+	case *sqlparser.Update, *xparser.MultiUpdate:
 		command = "Update"
 	case *sqlparser.Select:
 		command = "Select"
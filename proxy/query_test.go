@@ -11,6 +11,7 @@ package proxy
 
 import (
 	"errors"
+	"fmt"
 	"testing"
 
 	"github.com/sealdb/mysqlstack/driver"
@@ -690,26 +691,101 @@ func TestProxyQuerySubQuery(t *testing.T) {
 		out   string
 	}{
 		{
-			query: "select * from (select a from t) as tt",
+			query: "select a, (select a from t2) from t1",
 			out:   "unsupported: subqueries.in.select (errno 1105) (sqlstate HY000)",
 		},
 		{
-			query: "select * from t1 join t2 on t1.a=t2.a where exists(select a from t)",
-			out:   "unsupported: subqueries.in.select (errno 1105) (sqlstate HY000)",
+			query: "select a from t1 where exists (select a from t2 where t2.a=t1.a)",
+			out:   "unsupported: correlated.subquery.column.'t1.a' (errno 1105) (sqlstate HY000)",
+		},
+		{
+			query: "select a from t1 where a in (select a from t2) and not exists (select a from t2 where id=1)",
 		},
 	}
 
 	fakedbs.AddQueryPattern("use .*", &sqltypes.Result{})
+	fakedbs.AddQueryPattern("create .*", &sqltypes.Result{})
 	fakedbs.AddQueryPattern("select .*", &sqltypes.Result{})
 	{
-		client, err := driver.NewConn("mock", "mock", address, " ", "utf8")
+		client, err := driver.NewConn("mock", "mock", address, "", "utf8")
+		assert.Nil(t, err)
+		_, err = client.FetchAll("create database test", -1)
+		assert.Nil(t, err)
+		_, err = client.FetchAll("create table test.t1(id int, a int) partition by hash(id)", -1)
+		assert.Nil(t, err)
+		_, err = client.FetchAll("create table test.t2(id int, a int) partition by hash(id)", -1)
+		assert.Nil(t, err)
+		client.Quit()
+	}
+	{
+		client, err := driver.NewConn("mock", "mock", address, "test", "utf8")
 		assert.Nil(t, err)
 		defer client.Close()
 
 		for _, testcase := range testcases {
 			_, err = client.FetchAll(testcase.query, -1)
-			assert.Equal(t, testcase.out, err.Error())
+			if testcase.out == "" {
+				assert.Nil(t, err)
+			} else {
+				assert.Equal(t, testcase.out, err.Error())
+			}
+		}
+	}
+}
+
+func TestProxyQueryWith(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	fakedbs, proxy, cleanup := MockProxy(log)
+	defer cleanup()
+	address := proxy.Address()
+
+	intResult := func(field string, vals ...int64) *sqltypes.Result {
+		res := &sqltypes.Result{Fields: []*querypb.Field{{Name: field, Type: querypb.Type_INT64}}}
+		for _, v := range vals {
+			res.Rows = append(res.Rows, []sqltypes.Value{sqltypes.NewInt64(v)})
 		}
+		return res
+	}
+
+	fakedbs.AddQueryPattern("use .*", &sqltypes.Result{})
+	fakedbs.AddQueryPattern("create .*", &sqltypes.Result{})
+	fakedbs.AddQueryPattern("select c.a from \\(select a from test.t1_[0-9]+ as t1 where id = 1\\) as c", intResult("a", 7))
+	fakedbs.AddQuery("select 1 as n from dual", intResult("n", 1))
+	fakedbs.AddQuery("select n + 1 from (select 1 as n from dual) as r where n < 3", intResult("n + 1", 2))
+	fakedbs.AddQuery("select n + 1 from (select 2 as n from dual) as r where n < 3", intResult("n + 1", 3))
+	fakedbs.AddQuery("select n + 1 from (select 3 as n from dual) as r where n < 3", intResult("n + 1"))
+	{
+		client, err := driver.NewConn("mock", "mock", address, "", "utf8")
+		assert.Nil(t, err)
+		_, err = client.FetchAll("create database test", -1)
+		assert.Nil(t, err)
+		_, err = client.FetchAll("create table test.t1(id int, a int) partition by hash(id)", -1)
+		assert.Nil(t, err)
+		client.Quit()
+	}
+
+	client, err := driver.NewConn("mock", "mock", address, "test", "utf8")
+	assert.Nil(t, err)
+	defer client.Close()
+
+	// The cte is inlined as the derived table.
+	{
+		qr, err := client.FetchAll("with c as (select a from t1 where id = 1) select c.a from c", -1)
+		assert.Nil(t, err)
+		assert.Equal(t, "[[7]]", fmt.Sprintf("%v", qr.Rows))
+	}
+
+	// The recursive cte is executed iteratively by the proxy.
+	{
+		query := "with recursive r(n) as (select 1 union all select n + 1 from r where n < 3) select * from r"
+		qr, err := client.FetchAll(query, -1)
+		assert.Nil(t, err)
+		assert.Equal(t, "[[1] [2] [3]]", fmt.Sprintf("%v", qr.Rows))
+
+		proxy.SetCTEMaxRecursionDepth(1)
+		_, err = client.FetchAll(query, -1)
+		assert.NotNil(t, err)
+		assert.Contains(t, err.Error(), "cte-max-recursion-depth")
 	}
 }
 
@@ -0,0 +1,196 @@
+/*
+ * NeoDB
+ *
+ * Copyright 2021-2030 The NeoDB Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package proxy
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/sealdb/neodb/optimizer"
+	"github.com/sealdb/neodb/planner"
+	"github.com/sealdb/neodb/xcontext"
+	"github.com/sealdb/neodb/xparser"
+
+	"github.com/sealdb/mysqlstack/driver"
+	"github.com/sealdb/mysqlstack/sqldb"
+	"github.com/sealdb/mysqlstack/sqlparser"
+	"github.com/sealdb/mysqlstack/sqlparser/depends/common"
+	querypb "github.com/sealdb/mysqlstack/sqlparser/depends/query"
+	"github.com/sealdb/mysqlstack/sqlparser/depends/sqltypes"
+)
+
+// ValueRoute is the partition which the shard key value routes to.
+type ValueRoute struct {
+	Index   int    `json:"index"`
+	Table   string `json:"table"`
+	Range   string `json:"range"`
+	Backend string `json:"backend"`
+	Address string `json:"address"`
+}
+
+// QueryRoute is the rewritten query which the planner sends to the backend.
+type QueryRoute struct {
+	Backend string `json:"backend"`
+	Address string `json:"address"`
+	Range   string `json:"range"`
+	Query   string `json:"query"`
+}
+
+// backendAddress returns the address of the backend, empty if the backend doesn't exist.
+func (spanner *Spanner) backendAddress(name string) string {
+	for _, conf := range spanner.scatter.BackendConfigsClone() {
+		if conf.Name == name {
+			return conf.Address
+		}
+	}
+	return ""
+}
+
+// RouteValues returns the partition which the shard key values of the table route to.
+func (spanner *Spanner) RouteValues(database, table string, vals []*sqlparser.SQLVal) (*ValueRoute, error) {
+	index, segment, err := spanner.router.RouteValues(database, table, vals)
+	if err != nil {
+		return nil, err
+	}
+	return &ValueRoute{
+		Index:   index,
+		Table:   segment.Table,
+		Range:   segment.Range.String(),
+		Backend: segment.Backend,
+		Address: spanner.backendAddress(segment.Backend),
+	}, nil
+}
+
+// RouteQuery returns the querys which the planner sends to the backends for the DML, the querys are not executed.
+// The inserts into the lookup indexes are included, the UPDATE and DELETE include the reads of the lookup pairs
+// instead, the querys maintaining the lookup indexes are built from the pairs at execution.
+func (spanner *Spanner) RouteQuery(database, query string) ([]QueryRoute, error) {
+	node, err := xparser.Parse(query)
+	if err != nil {
+		return nil, err
+	}
+
+	switch node := node.(type) {
+	case *sqlparser.Union, *sqlparser.Select, *sqlparser.Delete, *sqlparser.Update, *xparser.MultiUpdate:
+	case *sqlparser.Insert:
+		autoincPlug := spanner.plugins.PlugAutoIncrement()
+		// The auto-increment values are not consumed by the simulation.
+		if err := autoincPlug.Preview(database, node); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, sqldb.NewSQLError(sqldb.ER_SYNTAX_ERROR, "route only supports SELECT/DELETE/INSERT/UPDATE/UNION")
+	}
+
+	simOptimizer := optimizer.NewSimpleOptimizer(spanner.log, database, query, node, spanner.router)
+	planTree, err := simOptimizer.BuildPlanTree()
+	if err != nil {
+		return nil, err
+	}
+
+	var querys []xcontext.QueryTuple
+	for _, plan := range planTree.Plans() {
+		switch plan := plan.(type) {
+		case *planner.SelectPlan:
+			querys = append(querys, plan.Root.GetQuery()...)
+		case *planner.UnionPlan:
+			querys = append(querys, plan.Root.GetQuery()...)
+		case *planner.InsertPlan:
+			querys = append(querys, plan.Querys...)
+		case *planner.UpdatePlan:
+			querys = append(querys, plan.Lookups()...)
+			querys = append(querys, plan.Querys...)
+		case *planner.DeletePlan:
+			querys = append(querys, plan.Lookups()...)
+			querys = append(querys, plan.Querys...)
+		}
+	}
+
+	routes := make([]QueryRoute, 0, len(querys))
+	for _, q := range querys {
+		routes = append(routes, QueryRoute{
+			Backend: q.Backend,
+			Address: spanner.backendAddress(q.Backend),
+			Range:   q.Range,
+			Query:   q.Query,
+		})
+	}
+	return routes, nil
+}
+
+// handleRoute used to handle the command:
+// NEODB ROUTE table VALUE value|(value, ...)
+// NEODB ROUTE statement
+func (spanner *Spanner) handleRoute(session *driver.Session, node *sqlparser.NeoDB) (*sqltypes.Result, error) {
+	database := session.Schema()
+	qr := &sqltypes.Result{}
+
+	if node.Action == xparser.RouteStr {
+		if !node.Table.Qualifier.IsEmpty() {
+			database = node.Table.Qualifier.String()
+		}
+		vals := make([]*sqlparser.SQLVal, 0, len(node.Row))
+		for _, v := range node.Row {
+			vals = append(vals, v.(*sqlparser.SQLVal))
+		}
+		route, err := spanner.RouteValues(database, node.Table.Name.String(), vals)
+		if err != nil {
+			return nil, err
+		}
+		qr.Fields = []*querypb.Field{
+			{Name: "Index", Type: querypb.Type_INT64},
+			{Name: "Table", Type: querypb.Type_VARCHAR},
+			{Name: "Range", Type: querypb.Type_VARCHAR},
+			{Name: "Backend", Type: querypb.Type_VARCHAR},
+			{Name: "Address", Type: querypb.Type_VARCHAR},
+		}
+		qr.Rows = append(qr.Rows, []sqltypes.Value{
+			sqltypes.MakeTrusted(querypb.Type_INT64, []byte(strconv.Itoa(route.Index))),
+			sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(route.Table)),
+			sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(route.Range)),
+			sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(route.Backend)),
+			sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(route.Address)),
+		})
+		qr.RowsAffected = 1
+		return qr, nil
+	}
+
+	if len(node.Row) != 1 {
+		return nil, fmt.Errorf("spanner.route.statement.missing")
+	}
+	query := common.BytesToString(node.Row[0].(*sqlparser.SQLVal).Val)
+	stmt, err := xparser.Parse(query)
+	if err != nil {
+		return nil, err
+	}
+	privilegePlug := spanner.plugins.PlugPrivilege()
+	if err := privilegePlug.Check(database, session.User(), stmt); err != nil {
+		return nil, err
+	}
+	routes, err := spanner.RouteQuery(database, query)
+	if err != nil {
+		return nil, err
+	}
+	qr.Fields = []*querypb.Field{
+		{Name: "Backend", Type: querypb.Type_VARCHAR},
+		{Name: "Address", Type: querypb.Type_VARCHAR},
+		{Name: "Range", Type: querypb.Type_VARCHAR},
+		{Name: "Query", Type: querypb.Type_VARCHAR},
+	}
+	for _, route := range routes {
+		qr.Rows = append(qr.Rows, []sqltypes.Value{
+			sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(route.Backend)),
+			sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(route.Address)),
+			sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(route.Range)),
+			sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(route.Query)),
+		})
+	}
+	qr.RowsAffected = uint64(len(qr.Rows))
+	return qr, nil
+}
@@ -0,0 +1,100 @@
+/*
+ * NeoDB
+ *
+ * Copyright 2021-2030 The NeoDB Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package proxy
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/sealdb/mysqlstack/driver"
+	"github.com/sealdb/mysqlstack/sqlparser"
+	"github.com/sealdb/mysqlstack/sqlparser/depends/sqltypes"
+	"github.com/sealdb/mysqlstack/xlog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProxyRoute(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	fakedbs, proxy, cleanup := MockProxy(log)
+	defer cleanup()
+	address := proxy.Address()
+
+	// fakedbs.
+	{
+		fakedbs.AddQueryPattern("use .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("create .*", &sqltypes.Result{})
+	}
+
+	// create database and table.
+	{
+		client, err := driver.NewConn("mock", "mock", address, "", "utf8")
+		assert.Nil(t, err)
+		_, err = client.FetchAll("create database test", -1)
+		assert.Nil(t, err)
+		_, err = client.FetchAll("create table test.t1(id int, b int) partition by hash(id)", -1)
+		assert.Nil(t, err)
+		client.Quit()
+	}
+
+	route := proxy.Router()
+	val := sqlparser.NewIntVal([]byte("1"))
+	index, err := route.GetIndex("test", "t1", val)
+	assert.Nil(t, err)
+	segments, err := route.GetSegments("test", "t1", []int{index})
+	assert.Nil(t, err)
+	segment := segments[0]
+	backendAddress := proxy.Spanner().backendAddress(segment.Backend)
+	assert.NotEqual(t, "", backendAddress)
+
+	client, err := driver.NewConn("mock", "mock", address, "test", "utf8")
+	assert.Nil(t, err)
+	defer client.Quit()
+
+	// route the value.
+	{
+		qr, err := client.FetchAll("neodb route t1 value 1", -1)
+		assert.Nil(t, err)
+		want := fmt.Sprintf("[[%d %s %s %s %s]]", index, segment.Table, segment.Range.String(), segment.Backend, backendAddress)
+		assert.Equal(t, want, fmt.Sprintf("%+v", qr.Rows))
+	}
+
+	// route the statement.
+	{
+		qr, err := client.FetchAll("neodb route select b from t1 where id=1", -1)
+		assert.Nil(t, err)
+		want := fmt.Sprintf("[[%s %s %s select b from test.%s as t1 where id = 1]]", segment.Backend, backendAddress, segment.Range.String(), segment.Table)
+		assert.Equal(t, want, fmt.Sprintf("%+v", qr.Rows))
+
+		// The insert isn't executed.
+		qr, err = client.FetchAll("neodb route insert into t1(id, b) values(1, 2)", -1)
+		assert.Nil(t, err)
+		want = fmt.Sprintf("[[%s %s %s insert into test.%s(id, b) values (1, 2)]]", segment.Backend, backendAddress, segment.Range.String(), segment.Table)
+		assert.Equal(t, want, fmt.Sprintf("%+v", qr.Rows))
+
+		qr, err = client.FetchAll("neodb route delete from test.t1 where b=1", -1)
+		assert.Nil(t, err)
+		segments, err = route.GetSegments("test", "t1", nil)
+		assert.Nil(t, err)
+		assert.Equal(t, len(segments), len(qr.Rows))
+	}
+
+	// errors.
+	{
+		querys := []string{
+			"neodb route t1 value (1, 2)",
+			"neodb route t2 value 1",
+			"neodb route show tables",
+			"neodb route select * from t2",
+		}
+		for _, query := range querys {
+			_, err := client.FetchAll(query, -1)
+			assert.NotNil(t, err, query)
+		}
+	}
+}
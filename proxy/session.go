@@ -24,7 +24,8 @@ type bitmask uint32
 
 // session variables capabilities.
 const (
-	cap_streaming_fetch bitmask = 1 << iota // streaming fetch for this session
+	cap_streaming_fetch  bitmask = 1 << iota // streaming fetch for this session
+	cap_shard_key_update                     // update the shard key by moving the rows for this session
 )
 
 type session struct {
@@ -36,6 +37,10 @@ type session struct {
 	timestamp    int64
 	capabilities bitmask
 	transaction  backend.Transaction
+	// groupConcatMaxLen is the session variable group_concat_max_len.
+	groupConcatMaxLen int
+	// joinBatchSize is the session variable neodb_join_batch_size.
+	joinBatchSize int
 }
 
 func (s *session) setStreamingFetchVar(r bool) {
@@ -50,12 +55,38 @@ func (s *session) getStreamingFetchVar() bool {
 	return s.capabilities&cap_streaming_fetch != 0
 }
 
+func (s *session) setShardKeyUpdateVar(r bool) {
+	if r {
+		s.capabilities |= cap_shard_key_update
+	} else {
+		s.capabilities &= ^cap_shard_key_update
+	}
+}
+
+func (s *session) getShardKeyUpdateVar() bool {
+	return s.capabilities&cap_shard_key_update != 0
+}
+
+func (s *session) setGroupConcatMaxLen(max int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.groupConcatMaxLen = max
+}
+
+func (s *session) setJoinBatchSize(size int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.joinBatchSize = size
+}
+
 func newSession(log *xlog.Log, s *driver.Session) *session {
 	log.Debug("session[%v].created", s.ID())
 	return &session{
-		log:       log,
-		session:   s,
-		timestamp: time.Now().Unix(),
+		log:               log,
+		session:           s,
+		timestamp:         time.Now().Unix(),
+		groupConcatMaxLen: backend.DefaultGroupConcatMaxLen,
+		joinBatchSize:     backend.DefaultJoinBatchSize,
 	}
 }
 
@@ -40,4 +40,13 @@ func TestSession(t *testing.T) {
 		sf = sess.getStreamingFetchVar()
 		assert.False(t, sf)
 	}
+
+	{
+		sess.setShardKeyUpdateVar(true)
+		assert.True(t, sess.getShardKeyUpdateVar())
+		assert.False(t, sess.getStreamingFetchVar())
+
+		sess.setShardKeyUpdateVar(false)
+		assert.False(t, sess.getShardKeyUpdateVar())
+	}
 }
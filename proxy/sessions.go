@@ -124,6 +124,9 @@ func (ss *Sessions) TxnBinding(s *driver.Session, txn backend.Transaction, node
 
 	// Bind sid to txn.
 	txn.SetSessionID(s.ID())
+	txn.SetGroupConcatMaxLen(session.groupConcatMaxLen)
+	txn.SetJoinBatchSize(session.joinBatchSize)
+	txn.SetShardKeyUpdate(session.getShardKeyUpdateVar())
 	session.transaction = txn
 	session.timestamp = time.Now().Unix()
 }
@@ -171,6 +174,12 @@ func (ss *Sessions) MultiStmtTxnBinding(s *driver.Session, txn backend.Transacti
 		txn.SetSessionID(s.ID())
 		session.transaction = txn
 	}
+	// The session variables may be changed during the trans.
+	if session.transaction != nil {
+		session.transaction.SetGroupConcatMaxLen(session.groupConcatMaxLen)
+		session.transaction.SetJoinBatchSize(session.joinBatchSize)
+		session.transaction.SetShardKeyUpdate(session.getShardKeyUpdateVar())
+	}
 	session.timestamp = time.Now().Unix()
 }
 
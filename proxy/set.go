@@ -11,6 +11,8 @@ package proxy
 
 import (
 	"fmt"
+	"math"
+	"strconv"
 	"strings"
 
 	"github.com/sealdb/mysqlstack/driver"
@@ -19,10 +21,19 @@ import (
 )
 
 const (
-	var_mysql_autocommit      = "autocommit"
-	var_neodb_streaming_fetch = "neodb_streaming_fetch"
+	var_mysql_autocommit           = "autocommit"
+	var_mysql_group_concat_max_len = "group_concat_max_len"
+	var_neodb_streaming_fetch      = "neodb_streaming_fetch"
+	var_neodb_join_batch_size      = "neodb_join_batch_size"
+	var_neodb_shard_key_update     = "neodb_shard_key_update"
 )
 
+// minGroupConcatMaxLen is the minimum value of group_concat_max_len, same as MySQL.
+const minGroupConcatMaxLen = 4
+
+// maxJoinBatchSize is the maximum value of neodb_join_batch_size.
+const maxJoinBatchSize = 10000
+
 // handleSet used to handle the SET command.
 func (spanner *Spanner) handleSet(session *driver.Session, query string, node *sqlparser.Set) (*sqltypes.Result, error) {
 	log := spanner.log
@@ -58,6 +69,66 @@ func (spanner *Spanner) handleSet(session *driver.Session, query string, node *s
 				}
 			}
 
+		case var_neodb_shard_key_update:
+			// The UPDATE of the shard key moves the rows between the partitions, it must be enabled explicitly.
+			switch expr := expr.Val.(*sqlparser.OptVal).Value.(type) {
+			case *sqlparser.SQLVal:
+				switch expr.Type {
+				case sqlparser.StrVal:
+					switch strings.ToLower(string(expr.Val)) {
+					case "on":
+						txSession.setShardKeyUpdateVar(true)
+					case "off":
+						txSession.setShardKeyUpdateVar(false)
+					default:
+						return nil, fmt.Errorf("Variable '%s' can't be set to the value of '%s'", name, string(expr.Val))
+					}
+				case sqlparser.IntVal:
+					txSession.setShardKeyUpdateVar(string(expr.Val) != "0")
+				default:
+					return nil, fmt.Errorf("Incorrect argument type to variable '%s'", name)
+				}
+			case sqlparser.BoolVal:
+				txSession.setShardKeyUpdateVar(bool(expr))
+			default:
+				return nil, fmt.Errorf("Incorrect argument type to variable '%s'", name)
+			}
+
+		case var_mysql_group_concat_max_len:
+			switch expr := expr.Val.(*sqlparser.OptVal).Value.(type) {
+			case *sqlparser.SQLVal:
+				if expr.Type != sqlparser.IntVal {
+					return nil, fmt.Errorf("Incorrect argument type to variable '%s'", name)
+				}
+				// The value out of range is truncated as MySQL does.
+				max, err := strconv.ParseUint(string(expr.Val), 10, 64)
+				if err != nil || max > math.MaxInt32 {
+					max = math.MaxInt32
+				}
+				if max < minGroupConcatMaxLen {
+					max = minGroupConcatMaxLen
+				}
+				txSession.setGroupConcatMaxLen(int(max))
+			default:
+				return nil, fmt.Errorf("Incorrect argument type to variable '%s'", name)
+			}
+
+		case var_neodb_join_batch_size:
+			switch expr := expr.Val.(*sqlparser.OptVal).Value.(type) {
+			case *sqlparser.SQLVal:
+				if expr.Type != sqlparser.IntVal {
+					return nil, fmt.Errorf("Incorrect argument type to variable '%s'", name)
+				}
+				// 0 disables the batched lookup of the nested loop join.
+				size, err := strconv.ParseUint(string(expr.Val), 10, 64)
+				if err != nil || size > maxJoinBatchSize {
+					size = maxJoinBatchSize
+				}
+				txSession.setJoinBatchSize(int(size))
+			default:
+				return nil, fmt.Errorf("Incorrect argument type to variable '%s'", name)
+			}
+
 		case var_mysql_autocommit:
 			var autocommit = true
 
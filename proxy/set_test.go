@@ -13,6 +13,7 @@ import (
 	"testing"
 
 	"github.com/sealdb/mysqlstack/driver"
+	querypb "github.com/sealdb/mysqlstack/sqlparser/depends/query"
 	"github.com/sealdb/mysqlstack/sqlparser/depends/sqltypes"
 	"github.com/sealdb/mysqlstack/xlog"
 	"github.com/stretchr/testify/assert"
@@ -76,6 +77,46 @@ func TestProxySet(t *testing.T) {
 			_, err := client.FetchAll(query, -1)
 			assert.NotNil(t, err)
 		}
+		{
+			query := "set @@SESSION.group_concat_max_len=2048"
+			_, err := client.FetchAll(query, -1)
+			assert.Nil(t, err)
+		}
+		{
+			query := "set group_concat_max_len='abc'"
+			_, err := client.FetchAll(query, -1)
+			assert.NotNil(t, err)
+		}
+		{
+			query := "set @@SESSION.neodb_join_batch_size=500"
+			_, err := client.FetchAll(query, -1)
+			assert.Nil(t, err)
+		}
+		{
+			query := "set neodb_join_batch_size=0"
+			_, err := client.FetchAll(query, -1)
+			assert.Nil(t, err)
+		}
+		{
+			query := "set neodb_join_batch_size='abc'"
+			_, err := client.FetchAll(query, -1)
+			assert.NotNil(t, err)
+		}
+		{
+			query := "set @@SESSION.neodb_shard_key_update=on"
+			_, err := client.FetchAll(query, -1)
+			assert.Nil(t, err)
+		}
+		{
+			query := "set neodb_shard_key_update=0"
+			_, err := client.FetchAll(query, -1)
+			assert.Nil(t, err)
+		}
+		{
+			query := "set neodb_shard_key_update='abc'"
+			_, err := client.FetchAll(query, -1)
+			assert.NotNil(t, err)
+		}
 		{
 			query := "SET SESSION TRANSACTION ISOLATION LEVEL SERIALIZABLE, READ WRITE"
 			_, err := client.FetchAll(query, -1)
@@ -204,3 +245,57 @@ func TestProxySetAutocommit(t *testing.T) {
 		}
 	}
 }
+
+func TestProxySetGroupConcatMaxLen(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	fakedbs, proxy, cleanup := MockProxy(log)
+	defer cleanup()
+	address := proxy.Address()
+
+	// fakedbs.
+	{
+		fakedbs.AddQueryPattern("create .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("select b as .*", &sqltypes.Result{
+			Fields: []*querypb.Field{
+				{
+					Name: "group_concat(b)",
+					Type: querypb.Type_VARCHAR,
+				},
+			},
+			Rows: [][]sqltypes.Value{
+				{sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("abc"))},
+				{sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("def"))},
+			},
+		})
+	}
+
+	// create test table.
+	{
+		client, err := driver.NewConn("mock", "mock", address, "", "utf8")
+		assert.Nil(t, err)
+		query := "create database test"
+		_, err = client.FetchAll(query, -1)
+		assert.Nil(t, err)
+		query = "create table test.t1(id int, b varchar(32)) partition by hash(id)"
+		_, err = client.FetchAll(query, -1)
+		assert.Nil(t, err)
+	}
+
+	{
+		client, err := driver.NewConn("mock", "mock", address, "", "utf8")
+		assert.Nil(t, err)
+		query := "select group_concat(b) from test.t1"
+		qr, err := client.FetchAll(query, -1)
+		assert.Nil(t, err)
+		assert.True(t, len(qr.Rows[0][0].Raw()) > 5)
+
+		query = "set group_concat_max_len=5"
+		_, err = client.FetchAll(query, -1)
+		assert.Nil(t, err)
+
+		query = "select group_concat(b) from test.t1"
+		qr, err = client.FetchAll(query, -1)
+		assert.Nil(t, err)
+		assert.Equal(t, "abc,d", qr.Rows[0][0].ToString())
+	}
+}
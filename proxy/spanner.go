@@ -37,6 +37,7 @@ type Spanner struct {
 	plugins       *plugins.Plugin
 	diskChecker   *DiskCheck
 	manager       *Manager
+	interval      *IntervalScheduler
 	readonly      sync2.AtomicBool
 	mu            sync.RWMutex
 	serverVersion string
@@ -75,6 +76,12 @@ func (spanner *Spanner) Init() error {
 		return err
 	}
 	spanner.manager = mgr
+
+	interval := NewIntervalScheduler(log, spanner)
+	if err := interval.Init(); err != nil {
+		return err
+	}
+	spanner.interval = interval
 	return nil
 }
 
@@ -82,6 +89,7 @@ func (spanner *Spanner) Init() error {
 func (spanner *Spanner) Close() error {
 	spanner.diskChecker.Close()
 	spanner.manager.Close()
+	spanner.interval.Close()
 	spanner.log.Info("spanner.closed...")
 	return nil
 }
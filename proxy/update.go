@@ -10,6 +10,8 @@
 package proxy
 
 import (
+	"github.com/sealdb/neodb/xparser"
+
 	"github.com/sealdb/mysqlstack/driver"
 	"github.com/sealdb/mysqlstack/sqlparser"
 	"github.com/sealdb/mysqlstack/sqlparser/depends/sqltypes"
@@ -18,5 +20,34 @@ import (
 // handleUpdate used to handle the update command.
 func (spanner *Spanner) handleUpdate(session *driver.Session, query string, node sqlparser.Statement) (*sqltypes.Result, error) {
 	database := session.Schema()
-	return spanner.ExecuteDML(session, database, query, node)
+	qr, err := spanner.ExecuteDML(session, database, query, node)
+	if err != nil {
+		// The duplicate value of the unique global index.
+		var tables []sqlparser.TableName
+		switch node := node.(type) {
+		case *sqlparser.Update:
+			tables = append(tables, node.Table)
+		case *xparser.MultiUpdate:
+			_ = sqlparser.Walk(func(n sqlparser.SQLNode) (kontinue bool, err error) {
+				if expr, ok := n.(*sqlparser.AliasedTableExpr); ok {
+					if name, ok := expr.Expr.(sqlparser.TableName); ok {
+						tables = append(tables, name)
+					}
+					return false, nil
+				}
+				return true, nil
+			}, node.TableRefs)
+		}
+		for _, table := range tables {
+			db := database
+			if !table.Qualifier.IsEmpty() {
+				db = table.Qualifier.String()
+			}
+			if dupErr := spanner.lookupDupEntryError(db, table.Name.String(), err); dupErr != err {
+				return nil, dupErr
+			}
+		}
+		return nil, err
+	}
+	return qr, nil
 }
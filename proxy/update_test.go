@@ -15,6 +15,7 @@ import (
 	"github.com/sealdb/neodb/fakedb"
 
 	"github.com/sealdb/mysqlstack/driver"
+	querypb "github.com/sealdb/mysqlstack/sqlparser/depends/query"
 	"github.com/sealdb/mysqlstack/sqlparser/depends/sqltypes"
 	"github.com/sealdb/mysqlstack/xlog"
 	"github.com/stretchr/testify/assert"
@@ -59,4 +60,91 @@ func TestProxyUpdate(t *testing.T) {
 		_, err = client.FetchAll(query, -1)
 		assert.Nil(t, err)
 	}
+
+	// Multiple-table update on the co-located tables.
+	{
+		client, err := driver.NewConn("mock", "mock", address, "", "utf8")
+		assert.Nil(t, err)
+		query := "create table test.t2(id int, b int) partition by hash(id)"
+		_, err = client.FetchAll(query, -1)
+		assert.Nil(t, err)
+
+		query = "update test.t1 join test.t2 on t1.id = t2.id set t1.b = t2.b where t2.id = 1"
+		_, err = client.FetchAll(query, -1)
+		assert.Nil(t, err)
+
+		query = "update test.t1 join test.t2 on t1.id = t2.id set t1.id = t2.id where t2.id = 1"
+		_, err = client.FetchAll(query, -1)
+		assert.NotNil(t, err)
+	}
+}
+
+func TestProxyUpdateShardKey(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	fakedbs, proxy, cleanup := MockProxy(log)
+	defer cleanup()
+	address := proxy.Address()
+	proxy.SetTwoPC(true)
+
+	rows := &sqltypes.Result{
+		Fields: []*querypb.Field{
+			{Name: "id", Type: querypb.Type_INT32, Flags: uint32(querypb.MySqlFlag_PRI_KEY_FLAG)},
+			{Name: "b", Type: querypb.Type_INT32},
+		},
+		Rows: [][]sqltypes.Value{{sqltypes.NewInt32(1), sqltypes.NewInt32(2)}},
+	}
+
+	// fakedbs.
+	{
+		fakedbs.AddQueryPattern("create .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("select .* for update", rows)
+		fakedbs.AddQueryPattern("select column_name, extra from information_schema.columns .*", &sqltypes.Result{
+			Fields: []*querypb.Field{
+				{Name: "column_name", Type: querypb.Type_VARCHAR},
+				{Name: "extra", Type: querypb.Type_VARCHAR},
+			},
+		})
+		fakedbs.AddQueryPattern("delete .*", &sqltypes.Result{RowsAffected: 1})
+		fakedbs.AddQueryPattern("insert .*", &sqltypes.Result{RowsAffected: 1})
+		fakedbs.AddQueryPattern("XA .*", &sqltypes.Result{})
+	}
+
+	client, err := driver.NewConn("mock", "mock", address, "", "utf8")
+	assert.Nil(t, err)
+	defer client.Close()
+
+	// create test table.
+	{
+		_, err = client.FetchAll("create database test", -1)
+		assert.Nil(t, err)
+		_, err = client.FetchAll("create table test.t1(id int, b int) partition by hash(id)", -1)
+		assert.Nil(t, err)
+	}
+
+	// The session does not enable the update of the shard key.
+	{
+		query := "update test.t1 set id = 100 where id = 1"
+		_, err = client.FetchAll(query, -1)
+		assert.NotNil(t, err)
+		want := "unsupported: cannot.update.shard.key (errno 1105) (sqlstate HY000)"
+		assert.Equal(t, want, err.Error())
+	}
+
+	// The row is moved.
+	{
+		_, err = client.FetchAll("set neodb_shard_key_update=on", -1)
+		assert.Nil(t, err)
+		qr, err := client.FetchAll("update test.t1 set id = 100 where id = 1", -1)
+		assert.Nil(t, err)
+		assert.Equal(t, uint64(1), qr.RowsAffected)
+	}
+
+	// The rows exceed the limit.
+	{
+		proxy.SetMaxShardKeyUpdateRows(0)
+		_, err = client.FetchAll("update test.t1 set id = 100 where id = 1", -1)
+		assert.NotNil(t, err)
+		want := "unsupported: shard.key.update.row.count.exceeded.allowed.limit.of.'0' (errno 1105) (sqlstate HY000)"
+		assert.Equal(t, want, err.Error())
+	}
 }
@@ -10,6 +10,8 @@
 package router
 
 import (
+	"sort"
+
 	"github.com/sealdb/neodb/config"
 
 	"github.com/pkg/errors"
@@ -92,11 +94,12 @@ func (r *Router) changeTheRuleBackend(fromBackend string, toBackend string, data
 
 	// 1. Find the table config.
 	found := false
+	position := 0
 	for _, v := range schema.Tables {
 		if found {
 			break
 		}
-		for _, partition := range v.TableConfig.Partitions {
+		for i, partition := range v.TableConfig.Partitions {
 			if (partition.Backend == fromBackend) && (partition.Table == partitionTable) {
 				log.Warning("router.rule[%s:%s].change.from[%s].to[%s].found:%+v", database, partitionTable, fromBackend, toBackend, partition)
 
@@ -104,6 +107,7 @@ func (r *Router) changeTheRuleBackend(fromBackend string, toBackend string, data
 				table = v.Name
 				tableConfig = v.TableConfig
 				partitionConfig = partition
+				position = i
 				break
 			}
 		}
@@ -112,6 +116,29 @@ func (r *Router) changeTheRuleBackend(fromBackend string, toBackend string, data
 		return "", errors.Errorf("router.rule.change.cant.found.backend[%s]+table:[%s]", fromBackend, partitionTable)
 	}
 
+	// The members of the colocate group are shifted one by one after their data are migrated,
+	// the partitions at the same position must be either not shifted yet or shifted to the same
+	// backend, and the single member can't be shifted unless the group is shifted together.
+	if group := tableConfig.ColocateGroup; group != "" {
+		var members []string
+		for _, v := range schema.Tables {
+			if v.Name == table || v.TableConfig.ColocateGroup != group || position >= len(v.TableConfig.Partitions) {
+				continue
+			}
+			member := v.TableConfig.Partitions[position]
+			if member.Backend != fromBackend && member.Backend != toBackend {
+				return "", errors.Errorf("router.rule.change.colocate.group[%s].partition[%s].is.on.backend[%s]", group, member.Table, member.Backend)
+			}
+			if member.Backend == fromBackend {
+				members = append(members, member.Table)
+			}
+		}
+		if len(members) > 0 && !r.colocateShifts[database+"."+partitionTable] {
+			sort.Strings(members)
+			return "", errors.Errorf("router.rule.change.colocate.group[%s].partition[%s].must.be.shifted.with%v", group, partitionTable, members)
+		}
+	}
+
 	// 2. Change the backend to to-backend.
 	if tableConfig.ShardType == "GLOBAL" {
 		for _, partition := range tableConfig.Partitions {
@@ -147,6 +174,63 @@ func (r *Router) changeTheRuleBackend(fromBackend string, toBackend string, data
 	return table, nil
 }
 
+// ColocatedPartitions returns the partition table and the partition tables at the same position
+// of the other members in the colocate group, which must be shifted together.
+func (r *Router) ColocatedPartitions(database string, partitionTable string) ([]string, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	schema, ok := r.Schemas[database]
+	if !ok {
+		return nil, errors.Errorf("router.colocate.cant.found.database:%s", database)
+	}
+
+	for _, v := range schema.Tables {
+		for i, partition := range v.TableConfig.Partitions {
+			if partition.Table != partitionTable {
+				continue
+			}
+
+			var members []string
+			if group := v.TableConfig.ColocateGroup; group != "" {
+				for _, m := range schema.Tables {
+					if m.Name == v.Name || m.TableConfig.ColocateGroup != group || i >= len(m.TableConfig.Partitions) {
+						continue
+					}
+					if member := m.TableConfig.Partitions[i]; member.Backend == partition.Backend {
+						members = append(members, member.Table)
+					}
+				}
+				sort.Strings(members)
+			}
+			return append([]string{partitionTable}, members...), nil
+		}
+	}
+	return nil, errors.Errorf("router.colocate.cant.found.partition.table[%s]", partitionTable)
+}
+
+// ShiftColocated marks the partition tables returned by the ColocatedPartitions as shifted
+// together, their rules can be changed one by one after their data are migrated.
+// Returns the func to unmark them.
+func (r *Router) ShiftColocated(database string, partitionTables []string) func() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.colocateShifts == nil {
+		r.colocateShifts = make(map[string]bool)
+	}
+	for _, table := range partitionTables {
+		r.colocateShifts[database+"."+table] = true
+	}
+	return func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		for _, table := range partitionTables {
+			delete(r.colocateShifts, database+"."+table)
+		}
+	}
+}
+
 // ReLoad used to re-load the config files from disk to cache.
 func (r *Router) ReLoad() error {
 	log := r.log
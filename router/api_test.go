@@ -338,6 +338,82 @@ func TestApiPartitionRuleShiftErrors(t *testing.T) {
 	}
 }
 
+func TestApiPartitionRuleShiftColocate(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	router, cleanup := MockNewRouter(log)
+	defer cleanup()
+
+	database := "sbtest"
+	err := router.CreateDatabase(database)
+	assert.Nil(t, err)
+	err = router.CreateHashTable(database, "t1", "id", TableTypePartitionHash, []string{"backend1", "backend2"}, nil, nil)
+	assert.Nil(t, err)
+	err = router.CreateColocateTable(database, "t2", "", "", "t1", nil)
+	assert.Nil(t, err)
+
+	// colocated partitions.
+	{
+		tables, err := router.ColocatedPartitions(database, "t1_0000")
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"t1_0000", "t2_0000"}, tables)
+
+		_, err = router.ColocatedPartitions("xx", "t1_0000")
+		assert.EqualError(t, err, "router.colocate.cant.found.database:xx")
+		_, err = router.ColocatedPartitions(database, "t3_0000")
+		assert.EqualError(t, err, "router.colocate.cant.found.partition.table[t3_0000]")
+	}
+
+	// The single member can't be shifted.
+	{
+		err := router.PartitionRuleShift("backend1", "backend3", database, "t1_0000")
+		assert.EqualError(t, err, "router.rule.change.colocate.group[t1].partition[t1_0000].must.be.shifted.with[t2_0000]")
+		tConf, err := router.TableConfig(database, "t1")
+		assert.Nil(t, err)
+		assert.Equal(t, "backend1", tConf.Partitions[0].Backend)
+	}
+
+	// Shift the members of the group together one by one.
+	{
+		tables, err := router.ColocatedPartitions(database, "t1_0000")
+		assert.Nil(t, err)
+		done := router.ShiftColocated(database, tables)
+
+		err = router.PartitionRuleShift("backend1", "backend3", database, "t1_0000")
+		assert.Nil(t, err)
+
+		tables, err = router.ColocatedPartitions(database, "t2_0000")
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"t2_0000"}, tables)
+
+		err = router.PartitionRuleShift("backend1", "backend4", database, "t2_0000")
+		assert.EqualError(t, err, "router.rule.change.colocate.group[t1].partition[t1_0000].is.on.backend[backend3]")
+
+		err = router.PartitionRuleShift("backend1", "backend3", database, "t2_0000")
+		assert.Nil(t, err)
+		done()
+
+		tables, err = router.ColocatedPartitions(database, "t1_0000")
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"t1_0000", "t2_0000"}, tables)
+
+		// Unmarked.
+		err = router.PartitionRuleShift("backend3", "backend1", database, "t2_0000")
+		assert.EqualError(t, err, "router.rule.change.colocate.group[t1].partition[t2_0000].must.be.shifted.with[t1_0000]")
+	}
+
+	// The group is kept after reload.
+	{
+		err := router.ReLoad()
+		assert.Nil(t, err)
+		for _, table := range []string{"t1", "t2"} {
+			tConf, err := router.TableConfig(database, table)
+			assert.Nil(t, err)
+			assert.Equal(t, "t1", tConf.ColocateGroup)
+			assert.Equal(t, "backend3", tConf.Partitions[0].Backend)
+		}
+	}
+}
+
 func TestApiReLoad(t *testing.T) {
 	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
 	router, cleanup := MockNewRouter(log)
@@ -13,8 +13,10 @@ import (
 	"fmt"
 	"sort"
 	"strconv"
+	"time"
 
 	"github.com/sealdb/neodb/config"
+	"github.com/sealdb/neodb/xparser"
 
 	"github.com/pkg/errors"
 	"github.com/sealdb/mysqlstack/sqlparser"
@@ -148,23 +150,32 @@ func (r *Router) SingleUniform(table string, backends []string) (*config.TableCo
 	}, nil
 }
 
-func listMergePartition(partitionDef sqlparser.PartitionDefinitions) (map[string]string, error) {
-	partitionMap := make(map[string]string)
+// listPartitions returns the partitions of the list definitions in order, the values are checked by List.Build.
+// eg: PARTITION backend1 VALUES IN (1, 2) or PARTITION backend2 VALUES IN (DEFAULT).
+func listPartitions(table string, seq int, partitionDef sqlparser.PartitionDefinitions) ([]*config.PartitionConfig, error) {
+	parts := make([]*config.PartitionConfig, 0, len(partitionDef))
 	for _, onePart := range partitionDef {
-		row := onePart.Row
-		valuesNum := len(row)
-		for i := 0; i < valuesNum; i++ {
-			key := common.BytesToString(row[i].(*sqlparser.SQLVal).Val)
-			if _, ok := partitionMap[key]; !ok {
-				partitionMap[key] = onePart.Backend
-			} else {
-				if partitionMap[key] != onePart.Backend {
-					return nil, errors.New("partition.list.different.backend.with.same.values")
+		partConf := &config.PartitionConfig{
+			Table:   fmt.Sprintf("%s_%04d", table, seq),
+			Backend: onePart.Backend,
+		}
+		for _, expr := range onePart.Row {
+			switch expr := expr.(type) {
+			case *sqlparser.SQLVal:
+				partConf.ListValues = append(partConf.ListValues, common.BytesToString(expr.Val))
+			case *sqlparser.Default:
+				if len(onePart.Row) != 1 {
+					return nil, errors.New("partition.list.DEFAULT.must.be.the.only.value")
 				}
+				partConf.ListDefault = true
+			default:
+				return nil, errors.Errorf("partition.list.value[%s].must.be.literal", sqlparser.String(expr))
 			}
 		}
+		parts = append(parts, partConf)
+		seq++
 	}
-	return partitionMap, nil
+	return parts, nil
 }
 
 // ListUniform used to uniform the list table to backends.
@@ -176,32 +187,128 @@ func (r *Router) ListUniform(table string, shardkey string, partitionDef sqlpars
 		return nil, errors.New("shard.key.cant.be.null")
 	}
 
-	listMap, err := listMergePartition(partitionDef)
+	nums := len(partitionDef)
+	if nums == 0 {
+		return nil, errors.New("router.compute.partition.list.is.null")
+	}
+
+	parts, err := listPartitions(table, 0, partitionDef)
 	if err != nil {
 		return nil, err
 	}
+	tableConf := &config.TableConfig{
+		Name:       table,
+		ShardType:  MethodTypeList,
+		ShardKey:   shardkey,
+		Partitions: parts,
+	}
+	if err := NewList(r.log, tableConf).Build(); err != nil {
+		return nil, err
+	}
+	return tableConf, nil
+}
+
+// RangeUniform used to uniform the range table to backends.
+// The partition definitions are kept in order, the bounds are checked by Range.Build.
+func (r *Router) RangeUniform(table string, shardkey string, partitionDef xparser.RangePartitionDefinitions) (*config.TableConfig, error) {
+	if table == "" {
+		return nil, errors.New("table.cant.be.null")
+	}
+	if shardkey == "" {
+		return nil, errors.New("shard.key.cant.be.null")
+	}
 
-	nums := len(listMap)
+	nums := len(partitionDef)
 	if nums == 0 {
-		return nil, errors.New("router.compute.partition.list.is.null")
+		return nil, errors.New("router.compute.partition.range.is.null")
 	}
 
 	tableConf := &config.TableConfig{
 		Name:       table,
-		ShardType:  MethodTypeList,
+		ShardType:  MethodTypeRange,
 		ShardKey:   shardkey,
 		Partitions: make([]*config.PartitionConfig, 0, 16),
 	}
 
-	i := 0
-	for listValue, backend := range listMap {
+	for i, onePart := range partitionDef {
+		lessThan := MaxValue
+		if onePart.LessThan != nil {
+			lessThan = common.BytesToString(onePart.LessThan.Val)
+		}
+		partConf := &config.PartitionConfig{
+			Table:    fmt.Sprintf("%s_%04d", table, i),
+			Backend:  onePart.Backend,
+			LessThan: lessThan,
+		}
+		tableConf.Partitions = append(tableConf.Partitions, partConf)
+	}
+	return tableConf, nil
+}
+
+// IntervalUniform used to uniform the interval table to backends, the partitions cover
+// the current interval of now and the premade ones.
+func (r *Router) IntervalUniform(table string, shardkey string, backends []string, interval *config.IntervalConfig, now time.Time) (*config.TableConfig, error) {
+	if table == "" {
+		return nil, errors.New("table.cant.be.null")
+	}
+	if shardkey == "" {
+		return nil, errors.New("shard.key.cant.be.null")
+	}
+
+	sort.Strings(backends)
+	tableConf := &config.TableConfig{
+		Name:      table,
+		ShardType: MethodTypeInterval,
+		ShardKey:  shardkey,
+		Interval:  interval,
+	}
+	parts, err := IntervalPremake(tableConf, backends, now)
+	if err != nil {
+		return nil, err
+	}
+	tableConf.Partitions = parts
+	return tableConf, nil
+}
+
+// ColocateUniform used to uniform the colocate table as the table colocated with,
+// the partitions are cloned with the sub table renamed.
+func (r *Router) ColocateUniform(table string, shardkey string, colocate *config.TableConfig) (*config.TableConfig, error) {
+	if table == "" {
+		return nil, errors.New("table.cant.be.null")
+	}
+	if shardkey == "" {
+		shardkey = colocate.ShardKey
+	}
+	if len(SplitShardKey(shardkey)) != len(SplitShardKey(colocate.ShardKey)) {
+		return nil, errors.Errorf("router.compute.colocate.shard.key[%s].mismatch.with.table[%s].shard.key[%s]", shardkey, colocate.Name, colocate.ShardKey)
+	}
+
+	group := colocate.ColocateGroup
+	if group == "" {
+		group = colocate.Name
+	}
+	tableConf := &config.TableConfig{
+		Name:          table,
+		Slots:         colocate.Slots,
+		Blocks:        colocate.Blocks,
+		ShardType:     colocate.ShardType,
+		ShardKey:      shardkey,
+		Partitions:    make([]*config.PartitionConfig, 0, len(colocate.Partitions)),
+		ColocateGroup: group,
+		HashFunction:  colocate.HashFunction,
+	}
+
+	for i, onePart := range colocate.Partitions {
 		partConf := &config.PartitionConfig{
-			Table:     fmt.Sprintf("%s_%04d", table, i),
-			Backend:   backend,
-			ListValue: listValue,
+			Table:       fmt.Sprintf("%s_%04d", table, i),
+			Segment:     onePart.Segment,
+			Backend:     onePart.Backend,
+			ListValue:   onePart.ListValue,
+			ListValues:  onePart.ListValues,
+			ListDefault: onePart.ListDefault,
+			LessThan:    onePart.LessThan,
 		}
 		tableConf.Partitions = append(tableConf.Partitions, partConf)
-		i++
 	}
 	return tableConf, nil
 }
@@ -429,19 +429,19 @@ func TestRouterComputeList(t *testing.T) {
 			"table": "l_0000",
 			"segment": "",
 			"backend": "node1",
-			"listvalue": "2"
+			"listvalues": ["2"]
 		},
 		{
 			"table": "l_0001",
 			"segment": "",
 			"backend": "node2",
-			"listvalue": "4"
+			"listvalues": ["4"]
 		},
 		{
 			"table": "l_0002",
 			"segment": "",
 			"backend": "node3",
-			"listvalue": "6"
+			"listvalues": ["6"]
 		}
 	]
 }`
@@ -476,8 +476,68 @@ func TestRouterComputeList(t *testing.T) {
 	for _, gotPartition := range got.Partitions {
 		for _, wantPartition := range want.Partitions {
 			if wantPartition.Backend == gotPartition.Backend {
-				assert.EqualValues(t, wantPartition.ListValue, gotPartition.ListValue)
+				assert.EqualValues(t, wantPartition.ListValues, gotPartition.ListValues)
 			}
 		}
 	}
 }
+
+func TestRouterComputeListValues(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	router, cleanup := MockNewRouter(log)
+	defer cleanup()
+
+	partitionDef := sqlparser.PartitionDefinitions{
+		&sqlparser.PartitionDefinition{
+			Backend: "node1",
+			Row:     sqlparser.ValTuple{sqlparser.NewIntVal([]byte("1")), sqlparser.NewStrVal([]byte("a"))},
+		},
+		&sqlparser.PartitionDefinition{
+			Backend: "node1",
+			Row:     sqlparser.ValTuple{sqlparser.NewIntVal([]byte("2"))},
+		},
+		&sqlparser.PartitionDefinition{
+			Backend: "node2",
+			Row:     sqlparser.ValTuple{&sqlparser.Default{}},
+		},
+	}
+	got, err := router.ListUniform("l", "id", partitionDef)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(got.Partitions))
+	assert.Equal(t, &config.PartitionConfig{Table: "l_0000", Backend: "node1", ListValues: []string{"1", "a"}}, got.Partitions[0])
+	assert.Equal(t, &config.PartitionConfig{Table: "l_0001", Backend: "node1", ListValues: []string{"2"}}, got.Partitions[1])
+	assert.Equal(t, &config.PartitionConfig{Table: "l_0002", Backend: "node2", ListDefault: true}, got.Partitions[2])
+
+	// Errors.
+	{
+		defs := []sqlparser.PartitionDefinitions{
+			{
+				&sqlparser.PartitionDefinition{
+					Backend: "node1",
+					Row:     sqlparser.ValTuple{sqlparser.NewIntVal([]byte("1")), &sqlparser.Default{}},
+				},
+			},
+			{
+				&sqlparser.PartitionDefinition{
+					Backend: "node1",
+					Row:     sqlparser.ValTuple{&sqlparser.ColName{Name: sqlparser.NewColIdent("a")}},
+				},
+			},
+			{
+				&sqlparser.PartitionDefinition{
+					Backend: "node1",
+					Row:     sqlparser.ValTuple{sqlparser.NewIntVal([]byte("1")), sqlparser.NewIntVal([]byte("1"))},
+				},
+			},
+		}
+		wants := []string{
+			"partition.list.DEFAULT.must.be.the.only.value",
+			"partition.list.value[a].must.be.literal",
+			"list.partition[l_0000].value[1].is.duplicate",
+		}
+		for i, def := range defs {
+			_, err := router.ListUniform("l", "id", def)
+			assert.EqualError(t, err, wants[i])
+		}
+	}
+}
@@ -15,8 +15,10 @@ import (
 	"os"
 	"path"
 	"strings"
+	"time"
 
 	"github.com/sealdb/neodb/config"
+	"github.com/sealdb/neodb/xparser"
 
 	"github.com/pkg/errors"
 	"github.com/sealdb/mysqlstack/sqldb"
@@ -31,6 +33,8 @@ const (
 	TableTypePartitionHash  = "hash"
 	TableTypePartitionList  = "list"
 	TableTypePartitionRange = "range"
+
+	TableTypePartitionInterval = "interval"
 )
 
 const (
@@ -278,6 +282,12 @@ func (r *Router) CreateHashTable(db, table, shardKey string, tableType string, b
 
 	if extra != nil {
 		tableConf.AutoIncrement = extra.AutoIncrement
+		if extra.HashFunction != "" {
+			if _, err := GetHashFunction(extra.HashFunction); err != nil {
+				return err
+			}
+			tableConf.HashFunction = extra.HashFunction
+		}
 	}
 
 	return r.createTable(db, table, tableConf)
@@ -310,6 +320,279 @@ func (r *Router) CreateListTable(db, table, shardKey string, tableType string,
 	return r.createTable(db, table, tableConf)
 }
 
+// CreateRangeTable used to add a range table to router and flush the schema to disk.
+func (r *Router) CreateRangeTable(db, table, shardKey string, tableType string,
+	partitionDef xparser.RangePartitionDefinitions, extra *Extra) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var err error
+	var tableConf *config.TableConfig
+
+	switch tableType {
+	case TableTypePartitionRange:
+		if tableConf, err = r.RangeUniform(table, shardKey, partitionDef); err != nil {
+			return err
+		}
+	default:
+		err := errors.Errorf("tableType is unsupported: %s", tableType)
+		return err
+	}
+
+	if extra != nil {
+		tableConf.AutoIncrement = extra.AutoIncrement
+	}
+
+	return r.createTable(db, table, tableConf)
+}
+
+// CreateIntervalTable used to add an interval table to router and flush the schema to disk.
+// The partitions cover the current interval and the premade ones.
+func (r *Router) CreateIntervalTable(db, table, shardKey string, tableType string, backends []string,
+	interval *config.IntervalConfig, extra *Extra) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var err error
+	var tableConf *config.TableConfig
+
+	switch tableType {
+	case TableTypePartitionInterval:
+		if tableConf, err = r.IntervalUniform(table, shardKey, backends, interval, time.Now()); err != nil {
+			return err
+		}
+	default:
+		err := errors.Errorf("tableType is unsupported: %s", tableType)
+		return err
+	}
+
+	if extra != nil {
+		tableConf.AutoIncrement = extra.AutoIncrement
+	}
+
+	return r.createTable(db, table, tableConf)
+}
+
+// AddIntervalPartitions used to append the premade partitions to the interval table and flush the schema to disk.
+func (r *Router) AddIntervalPartitions(db, table string, parts []*config.PartitionConfig) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	conf, err := r.partitionTableConfig(db, table, MethodTypeInterval)
+	if err != nil {
+		return err
+	}
+	newConf := *conf
+	newConf.Partitions = make([]*config.PartitionConfig, 0, len(conf.Partitions)+len(parts))
+	newConf.Partitions = append(newConf.Partitions, conf.Partitions...)
+	newConf.Partitions = append(newConf.Partitions, parts...)
+	return r.replaceTable(db, &newConf)
+}
+
+// RemoveIntervalPartitions used to remove the oldest partitions from the interval table and flush the schema to disk.
+func (r *Router) RemoveIntervalPartitions(db, table string, parts []*config.PartitionConfig) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	conf, err := r.partitionTableConfig(db, table, MethodTypeInterval)
+	if err != nil {
+		return err
+	}
+	if len(parts) >= len(conf.Partitions) {
+		return errors.Errorf("router.interval.table[%s].must.keep.one.partition", table)
+	}
+	for i, part := range parts {
+		if conf.Partitions[i].Table != part.Table {
+			return errors.Errorf("router.interval.partition[%s].is.not.the.oldest", part.Table)
+		}
+	}
+	newConf := *conf
+	newConf.Partitions = append([]*config.PartitionConfig{}, conf.Partitions[len(parts):]...)
+	return r.replaceTable(db, &newConf)
+}
+
+// ListAddUniform used to compute the partitions to add to the list table, the sub tables are named after the last one.
+func (r *Router) ListAddUniform(db, table string, partitionDef sqlparser.PartitionDefinitions) ([]*config.PartitionConfig, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	conf, err := r.partitionTableConfig(db, table, MethodTypeList)
+	if err != nil {
+		return nil, err
+	}
+	if len(partitionDef) == 0 {
+		return nil, errors.New("router.compute.partition.list.is.null")
+	}
+	seq := len(conf.Partitions)
+	for _, part := range conf.Partitions {
+		// The rows of the new values may be in the DEFAULT partition already.
+		if part.ListDefault {
+			return nil, errors.Errorf("router.list.table[%s].has.DEFAULT.partition[%s].can't.add.partition", table, part.Table)
+		}
+		if n, ok := subTableSeq(part.Table); ok && n >= seq {
+			seq = n + 1
+		}
+	}
+	parts, err := listPartitions(table, seq, partitionDef)
+	if err != nil {
+		return nil, err
+	}
+
+	newConf := *conf
+	newConf.Partitions = append(append([]*config.PartitionConfig{}, conf.Partitions...), parts...)
+	if err := NewList(r.log, &newConf).Build(); err != nil {
+		return nil, err
+	}
+	return parts, nil
+}
+
+// AddListPartitions used to append the partitions to the list table and flush the schema to disk.
+func (r *Router) AddListPartitions(db, table string, parts []*config.PartitionConfig) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	conf, err := r.partitionTableConfig(db, table, MethodTypeList)
+	if err != nil {
+		return err
+	}
+	newConf := *conf
+	newConf.Partitions = make([]*config.PartitionConfig, 0, len(conf.Partitions)+len(parts))
+	newConf.Partitions = append(newConf.Partitions, conf.Partitions...)
+	newConf.Partitions = append(newConf.Partitions, parts...)
+	return r.replaceTable(db, &newConf)
+}
+
+// DropListPartitions used to drop the partitions by the sub table names from the list table and flush the schema to disk.
+// It returns the dropped partitions.
+func (r *Router) DropListPartitions(db, table string, partitionTables []string) ([]*config.PartitionConfig, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	conf, err := r.partitionTableConfig(db, table, MethodTypeList)
+	if err != nil {
+		return nil, err
+	}
+
+	drops := make(map[string]bool, len(partitionTables))
+	for _, name := range partitionTables {
+		drops[name] = true
+	}
+	var dropped, kept []*config.PartitionConfig
+	for _, part := range conf.Partitions {
+		if drops[part.Table] {
+			dropped = append(dropped, part)
+			delete(drops, part.Table)
+		} else {
+			kept = append(kept, part)
+		}
+	}
+	for _, name := range partitionTables {
+		if drops[name] {
+			return nil, errors.Errorf("router.list.table[%s].partition[%s].not.found", table, name)
+		}
+	}
+	if len(kept) == 0 {
+		return nil, errors.Errorf("router.list.table[%s].must.keep.one.partition", table)
+	}
+
+	newConf := *conf
+	newConf.Partitions = kept
+	if err := r.replaceTable(db, &newConf); err != nil {
+		return nil, err
+	}
+	return dropped, nil
+}
+
+// partitionTableConfig returns the config of the table with the shard type, whose partitions can be changed.
+func (r *Router) partitionTableConfig(db, table string, shardType MethodType) (*config.TableConfig, error) {
+	schema, ok := r.Schemas[db]
+	if !ok {
+		return nil, errors.Errorf("router.can.not.find.db[%v]", db)
+	}
+	tbl, ok := schema.Tables[table]
+	if !ok {
+		return nil, sqldb.NewSQLError(sqldb.ER_NO_SUCH_TABLE, table)
+	}
+	if tbl.TableConfig.ShardType != string(shardType) {
+		return nil, errors.Errorf("router.table[%s].shardtype[%s].is.not.%s", table, tbl.TableConfig.ShardType, strings.ToLower(string(shardType)))
+	}
+	// The partitions of the colocate group members must be the same.
+	if tbl.TableConfig.ColocateGroup != "" {
+		return nil, errors.Errorf("router.table[%s].is.in.colocate.group[%s].partitions.can't.be.changed", table, tbl.TableConfig.ColocateGroup)
+	}
+	return tbl.TableConfig, nil
+}
+
+// replaceTable used to replace the table with the new config and flush the schema to disk,
+// the old one is kept if fails.
+func (r *Router) replaceTable(db string, tableConf *config.TableConfig) error {
+	log := r.log
+	schema := r.Schemas[db]
+	old := schema.Tables[tableConf.Name]
+
+	delete(schema.Tables, tableConf.Name)
+	if err := r.addTable(db, tableConf); err != nil {
+		schema.Tables[tableConf.Name] = old
+		log.Error("frm.replace.table[db:%v, table:%v].add.route.error:%v", db, tableConf.Name, err)
+		return err
+	}
+	if err := r.writeTableFrmData(db, tableConf.Name, tableConf); err != nil {
+		schema.Tables[tableConf.Name] = old
+		log.Error("frm.replace.table[db:%v, table:%v].file.error:%+v", db, tableConf.Name, err)
+		return err
+	}
+	if err := config.UpdateVersion(r.metadir); err != nil {
+		log.Panicf("frm.replace.table.update.version.error:%v", err)
+		return err
+	}
+	return nil
+}
+
+// CreateColocateTable used to add a table colocated with another table to router and flush the schema to disk.
+// The table joins the colocate group of the colocate table, the group is named by the first table if absent.
+func (r *Router) CreateColocateTable(db, table, shardType, shardKey, colocateWith string, extra *Extra) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	log := r.log
+	schema, ok := r.Schemas[db]
+	if !ok {
+		return errors.Errorf("router.can.not.find.db[%v]", db)
+	}
+	colocate, ok := schema.Tables[colocateWith]
+	if !ok {
+		return sqldb.NewSQLError(sqldb.ER_NO_SUCH_TABLE, colocateWith)
+	}
+	colocateConf := colocate.TableConfig
+	switch colocateConf.ShardType {
+	case MethodTypeHash, MethodTypeList, MethodTypeRange:
+	default:
+		return errors.Errorf("router.colocate.table[%s].shardtype[%s].is.unsupported", colocateWith, colocateConf.ShardType)
+	}
+	if shardType != "" && shardType != colocateConf.ShardType {
+		return errors.Errorf("router.colocate.shardtype[%s].mismatch.with.table[%s].shardtype[%s]", shardType, colocateWith, colocateConf.ShardType)
+	}
+
+	tableConf, err := r.ColocateUniform(table, shardKey, colocateConf)
+	if err != nil {
+		return err
+	}
+	if extra != nil {
+		tableConf.AutoIncrement = extra.AutoIncrement
+	}
+
+	// The first member names the group.
+	if colocateConf.ColocateGroup == "" {
+		colocateConf.ColocateGroup = tableConf.ColocateGroup
+		if err := r.writeTableFrmData(db, colocateWith, colocateConf); err != nil {
+			colocateConf.ColocateGroup = ""
+			log.Error("frm.create.colocate.table[db:%v, table:%v].file.error:%+v", db, colocateWith, err)
+			return err
+		}
+	}
+	return r.createTable(db, table, tableConf)
+}
+
 // checkNameInvalid used to check if db or table name contains invalid char '/'.
 func (r *Router) checkNameInvalid(name string) bool {
 	// 1. Currently neodb don`t support db/table name like `a/a`, in MySQL, `/` will be converted to `@002f`
@@ -14,8 +14,10 @@ import (
 	"os"
 	"path"
 	"testing"
+	"time"
 
 	"github.com/sealdb/neodb/config"
+	"github.com/sealdb/neodb/xparser"
 
 	"github.com/sealdb/mysqlstack/sqlparser"
 	"github.com/sealdb/mysqlstack/xlog"
@@ -51,7 +53,7 @@ func TestFrmTable(t *testing.T) {
 	{
 		tmpRouter := router
 		backends := []string{"backend1", "backend2", "backend3"}
-		err := router.CreateHashTable("test", "t1", "id", TableTypePartitionHash, backends, nil, &Extra{&config.AutoIncrement{"id"}})
+		err := router.CreateHashTable("test", "t1", "id", TableTypePartitionHash, backends, nil, &Extra{AutoIncrement: &config.AutoIncrement{"id"}})
 		assert.Nil(t, err)
 		assert.True(t, checkFileExistsForTest(tmpRouter, "test", "t1"))
 	}
@@ -89,7 +91,7 @@ func TestFrmTable(t *testing.T) {
 	// Add global table.
 	{
 		backends := []string{"backend1", "backend2"}
-		err := router.CreateNonPartTable("test", "t3", TableTypeGlobal, backends, &Extra{&config.AutoIncrement{"id"}})
+		err := router.CreateNonPartTable("test", "t3", TableTypeGlobal, backends, &Extra{AutoIncrement: &config.AutoIncrement{"id"}})
 		assert.Nil(t, err)
 	}
 
@@ -571,7 +573,7 @@ func TestFrmTableCreateListTable(t *testing.T) {
 		err = router.CreateListTable("test", "l", "id", TableTypePartitionList, sqlparser.PartitionDefinitions{}, nil)
 		assert.NotNil(t, err)
 
-		err = router.CreateListTable("test", "l", "id", TableTypePartitionList, partitionDef, &Extra{&config.AutoIncrement{"id"}})
+		err = router.CreateListTable("test", "l", "id", TableTypePartitionList, partitionDef, &Extra{AutoIncrement: &config.AutoIncrement{"id"}})
 		assert.NotNil(t, err)
 	}
 }
@@ -590,3 +592,325 @@ func TestCreateDatabaseError(t *testing.T) {
 	err = router.CreateDatabase("t0123456789012345678901234567890123456789012345678901234567890123")
 	assert.EqualError(t, err, "Identifier name 't0123456789012345678901234567890123456789012345678901234567890123' is too long (errno 1059) (sqlstate 42000)")
 }
+
+func TestFrmTableCreateRangeTable(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	router, cleanup := MockNewRouter(log)
+	defer cleanup()
+
+	router.CreateDatabase("test")
+
+	// Add range table.
+	{
+		partitionDef := xparser.RangePartitionDefinitions{
+			&xparser.RangePartitionDefinition{
+				Backend:  "node1",
+				LessThan: sqlparser.NewIntVal([]byte("100")),
+			},
+			&xparser.RangePartitionDefinition{
+				Backend: "node2",
+			},
+		}
+
+		err := router.CreateRangeTable("test", "r", "id", TableTypePartitionRange, partitionDef, nil)
+		assert.Nil(t, err)
+		assert.True(t, checkFileExistsForTest(router, "test", "r"))
+
+		tconf, err := router.TableConfig("test", "r")
+		assert.Nil(t, err)
+		assert.Equal(t, "100", tconf.Partitions[0].LessThan)
+		assert.Equal(t, MaxValue, tconf.Partitions[1].LessThan)
+
+		err = router.CreateRangeTable("test", "r1", "", TableTypePartitionRange, partitionDef, nil)
+		assert.NotNil(t, err)
+
+		err = router.CreateRangeTable("test", "r1", "id", TableTypePartitionHash, partitionDef, nil)
+		assert.NotNil(t, err)
+
+		err = router.CreateRangeTable("test", "r1", "id", TableTypePartitionRange, xparser.RangePartitionDefinitions{}, nil)
+		assert.NotNil(t, err)
+
+		// MAXVALUE must be the last one.
+		err = router.CreateRangeTable("test", "r1", "id", TableTypePartitionRange, xparser.RangePartitionDefinitions{partitionDef[1], partitionDef[0]}, nil)
+		assert.NotNil(t, err)
+	}
+
+	// Reload from the frm files.
+	{
+		err := router.ReLoad()
+		assert.Nil(t, err)
+		segments, err := router.Lookup("test", "r", sqlparser.NewIntVal([]byte("100")), sqlparser.NewIntVal([]byte("100")))
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(segments))
+		assert.Equal(t, "r_0001", segments[0].Table)
+	}
+}
+
+func TestFrmTableCreateColocateTable(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	router, cleanup := MockNewRouter(log)
+	defer cleanup()
+
+	router.CreateDatabase("test")
+
+	// Add colocate table.
+	{
+		partitionDef := xparser.RangePartitionDefinitions{
+			&xparser.RangePartitionDefinition{
+				Backend:  "node1",
+				LessThan: sqlparser.NewIntVal([]byte("100")),
+			},
+			&xparser.RangePartitionDefinition{
+				Backend: "node2",
+			},
+		}
+		err := router.CreateRangeTable("test", "r", "id", TableTypePartitionRange, partitionDef, nil)
+		assert.Nil(t, err)
+
+		err = router.CreateColocateTable("test", "r1", MethodTypeRange, "uid", "r", nil)
+		assert.Nil(t, err)
+		assert.True(t, checkFileExistsForTest(router, "test", "r1"))
+
+		tconf, err := router.TableConfig("test", "r1")
+		assert.Nil(t, err)
+		assert.Equal(t, "r", tconf.ColocateGroup)
+		assert.Equal(t, "uid", tconf.ShardKey)
+		assert.Equal(t, MethodTypeRange, tconf.ShardType)
+		assert.Equal(t, "r1_0000", tconf.Partitions[0].Table)
+		assert.Equal(t, "100", tconf.Partitions[0].LessThan)
+		assert.Equal(t, "node2", tconf.Partitions[1].Backend)
+
+		// The group is named by the first table.
+		err = router.CreateColocateTable("test", "r2", "", "", "r1", nil)
+		assert.Nil(t, err)
+		tconf, err = router.TableConfig("test", "r2")
+		assert.Nil(t, err)
+		assert.Equal(t, "r", tconf.ColocateGroup)
+		assert.Equal(t, "uid", tconf.ShardKey)
+	}
+
+	// Errors.
+	{
+		err := router.CreateColocateTable("xx", "r3", "", "", "r", nil)
+		assert.EqualError(t, err, "router.can.not.find.db[xx]")
+
+		err = router.CreateColocateTable("test", "r3", "", "", "x", nil)
+		assert.EqualError(t, err, "Table 'x' doesn't exist (errno 1146) (sqlstate 42S02)")
+
+		err = router.CreateColocateTable("test", "r3", MethodTypeHash, "", "r", nil)
+		assert.EqualError(t, err, "router.colocate.shardtype[HASH].mismatch.with.table[r].shardtype[RANGE]")
+
+		err = router.CreateColocateTable("test", "r3", "", "a,b", "r", nil)
+		assert.EqualError(t, err, "router.compute.colocate.shard.key[a,b].mismatch.with.table[r].shard.key[id]")
+
+		err = router.CreateColocateTable("test", "r", "", "", "r1", nil)
+		assert.EqualError(t, err, "router.add.db[test].table[r].exists")
+
+		err = router.CreateNonPartTable("test", "g", TableTypeGlobal, []string{"node1"}, nil)
+		assert.Nil(t, err)
+		err = router.CreateColocateTable("test", "r3", "", "", "g", nil)
+		assert.EqualError(t, err, "router.colocate.table[g].shardtype[GLOBAL].is.unsupported")
+	}
+}
+
+func TestFrmTableCreateIntervalTable(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	router, cleanup := MockNewRouter(log)
+	defer cleanup()
+
+	router.CreateDatabase("test")
+	interval := &config.IntervalConfig{Unit: IntervalDay, Premake: 2, Retention: 1}
+	err := router.CreateIntervalTable("test", "iv", "ts", TableTypePartitionInterval, []string{"node2", "node1"}, interval, nil)
+	assert.Nil(t, err)
+	assert.True(t, checkFileExistsForTest(router, "test", "iv"))
+
+	tconf, err := router.TableConfig("test", "iv")
+	assert.Nil(t, err)
+	assert.Equal(t, MethodTypeInterval, tconf.ShardType)
+	assert.Equal(t, 3, len(tconf.Partitions))
+	assert.Equal(t, "node1", tconf.Partitions[0].Backend)
+	assert.Equal(t, "node2", tconf.Partitions[1].Backend)
+
+	// Add the partitions of the next days.
+	{
+		now := time.Now().AddDate(0, 0, 2)
+		parts, err := IntervalPremake(tconf, []string{"node1", "node2"}, now)
+		assert.Nil(t, err)
+		assert.Equal(t, 2, len(parts))
+		err = router.AddIntervalPartitions("test", "iv", parts)
+		assert.Nil(t, err)
+
+		tconf, err = router.TableConfig("test", "iv")
+		assert.Nil(t, err)
+		assert.Equal(t, 5, len(tconf.Partitions))
+		assert.Equal(t, "iv_0004", tconf.Partitions[4].Table)
+
+		// Reload from the file.
+		err = router.RefreshTable("test", "iv")
+		assert.Nil(t, err)
+		tconf, err = router.TableConfig("test", "iv")
+		assert.Nil(t, err)
+		assert.Equal(t, 5, len(tconf.Partitions))
+
+		expired, err := IntervalExpired(tconf, now)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(expired))
+		err = router.RemoveIntervalPartitions("test", "iv", expired)
+		assert.Nil(t, err)
+
+		segs, err := router.Lookup("test", "iv", nil, nil)
+		assert.Nil(t, err)
+		assert.Equal(t, 4, len(segs))
+		assert.Equal(t, "iv_0001", segs[0].Table)
+	}
+
+	// Errors.
+	{
+		err := router.CreateIntervalTable("test", "iv1", "ts", TableTypePartitionRange, []string{"node1"}, interval, nil)
+		assert.EqualError(t, err, "tableType is unsupported: range")
+
+		err = router.CreateIntervalTable("test", "iv1", "ts", TableTypePartitionInterval, []string{"node1"}, &config.IntervalConfig{Unit: "WEEK"}, nil)
+		assert.EqualError(t, err, "interval.unit[WEEK].is.unsupported")
+
+		tconf, err := router.TableConfig("test", "iv")
+		assert.Nil(t, err)
+		err = router.RemoveIntervalPartitions("test", "iv", tconf.Partitions)
+		assert.EqualError(t, err, "router.interval.table[iv].must.keep.one.partition")
+
+		err = router.RemoveIntervalPartitions("test", "iv", tconf.Partitions[1:2])
+		assert.EqualError(t, err, "router.interval.partition[iv_0002].is.not.the.oldest")
+
+		// The bad partition is rejected and the table is kept.
+		bad := []*config.PartitionConfig{{Table: "iv_0005", Backend: "node1", LessThan: "2000-01-01 00:00:00"}}
+		err = router.AddIntervalPartitions("test", "iv", bad)
+		assert.EqualError(t, err, "range.partition[iv_0005].values.less.than.must.be.strictly.increasing")
+		segs, err := router.Lookup("test", "iv", nil, nil)
+		assert.Nil(t, err)
+		assert.Equal(t, 4, len(segs))
+
+		err = router.AddIntervalPartitions("xx", "iv", nil)
+		assert.EqualError(t, err, "router.can.not.find.db[xx]")
+
+		err = router.AddIntervalPartitions("test", "x", nil)
+		assert.EqualError(t, err, "Table 'x' doesn't exist (errno 1146) (sqlstate 42S02)")
+
+		err = router.CreateNonPartTable("test", "g", TableTypeGlobal, []string{"node1"}, nil)
+		assert.Nil(t, err)
+		err = router.RemoveIntervalPartitions("test", "g", nil)
+		assert.EqualError(t, err, "router.table[g].shardtype[GLOBAL].is.not.interval")
+	}
+}
+
+func TestFrmTableListPartitions(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	router, cleanup := MockNewRouter(log)
+	defer cleanup()
+
+	router.CreateDatabase("test")
+	partitionDef := sqlparser.PartitionDefinitions{
+		&sqlparser.PartitionDefinition{
+			Backend: "node1",
+			Row:     sqlparser.ValTuple{sqlparser.NewIntVal([]byte("1")), sqlparser.NewIntVal([]byte("2"))},
+		},
+		&sqlparser.PartitionDefinition{
+			Backend: "node2",
+			Row:     sqlparser.ValTuple{sqlparser.NewIntVal([]byte("3"))},
+		},
+	}
+	err := router.CreateListTable("test", "l", "id", TableTypePartitionList, partitionDef, nil)
+	assert.Nil(t, err)
+
+	// Add partitions.
+	{
+		addDef := sqlparser.PartitionDefinitions{
+			&sqlparser.PartitionDefinition{
+				Backend: "node2",
+				Row:     sqlparser.ValTuple{sqlparser.NewIntVal([]byte("4")), sqlparser.NewIntVal([]byte("5"))},
+			},
+		}
+		parts, err := router.ListAddUniform("test", "l", addDef)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(parts))
+		assert.Equal(t, "l_0002", parts[0].Table)
+		err = router.AddListPartitions("test", "l", parts)
+		assert.Nil(t, err)
+
+		idx, err := router.GetIndex("test", "l", sqlparser.NewIntVal([]byte("5")))
+		assert.Nil(t, err)
+		assert.Equal(t, 2, idx)
+
+		// The value exists.
+		addDef[0].Row = sqlparser.ValTuple{sqlparser.NewStrVal([]byte("3"))}
+		_, err = router.ListAddUniform("test", "l", addDef)
+		assert.EqualError(t, err, "list.partition[l_0003].value[3].is.duplicate")
+	}
+
+	// Drop partitions.
+	{
+		parts, err := router.DropListPartitions("test", "l", []string{"l_0001"})
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(parts))
+		assert.Equal(t, "node2", parts[0].Backend)
+
+		_, err = router.GetIndex("test", "l", sqlparser.NewIntVal([]byte("3")))
+		assert.EqualError(t, err, "Table has no partition for value 3")
+
+		// Reload from the file.
+		err = router.RefreshTable("test", "l")
+		assert.Nil(t, err)
+		tconf, err := router.TableConfig("test", "l")
+		assert.Nil(t, err)
+		assert.Equal(t, 2, len(tconf.Partitions))
+
+		// The new sub table is named after the last one.
+		addDef := sqlparser.PartitionDefinitions{
+			&sqlparser.PartitionDefinition{
+				Backend: "node1",
+				Row:     sqlparser.ValTuple{&sqlparser.Default{}},
+			},
+		}
+		parts, err = router.ListAddUniform("test", "l", addDef)
+		assert.Nil(t, err)
+		assert.Equal(t, "l_0003", parts[0].Table)
+		err = router.AddListPartitions("test", "l", parts)
+		assert.Nil(t, err)
+
+		idx, err := router.GetIndex("test", "l", sqlparser.NewIntVal([]byte("3")))
+		assert.Nil(t, err)
+		assert.Equal(t, 2, idx)
+	}
+
+	// Errors.
+	{
+		addDef := sqlparser.PartitionDefinitions{
+			&sqlparser.PartitionDefinition{
+				Backend: "node1",
+				Row:     sqlparser.ValTuple{sqlparser.NewIntVal([]byte("9"))},
+			},
+		}
+		_, err := router.ListAddUniform("test", "l", addDef)
+		assert.EqualError(t, err, "router.list.table[l].has.DEFAULT.partition[l_0003].can't.add.partition")
+
+		_, err = router.ListAddUniform("test", "l", nil)
+		assert.EqualError(t, err, "router.compute.partition.list.is.null")
+
+		_, err = router.DropListPartitions("test", "l", []string{"l_0001"})
+		assert.EqualError(t, err, "router.list.table[l].partition[l_0001].not.found")
+
+		_, err = router.DropListPartitions("test", "l", []string{"l_0000", "l_0002", "l_0003"})
+		assert.EqualError(t, err, "router.list.table[l].must.keep.one.partition")
+
+		_, err = router.DropListPartitions("xx", "l", nil)
+		assert.EqualError(t, err, "router.can.not.find.db[xx]")
+
+		err = router.CreateHashTable("test", "h", "id", TableTypePartitionHash, []string{"node1"}, nil, nil)
+		assert.Nil(t, err)
+		_, err = router.ListAddUniform("test", "h", addDef)
+		assert.EqualError(t, err, "router.table[h].shardtype[HASH].is.not.list")
+
+		err = router.CreateColocateTable("test", "l1", "", "", "l", nil)
+		assert.Nil(t, err)
+		_, err = router.DropListPartitions("test", "l1", []string{"l1_0000"})
+		assert.EqualError(t, err, "router.table[l1].is.in.colocate.group[l].partitions.can't.be.changed")
+	}
+}
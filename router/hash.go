@@ -18,7 +18,6 @@ import (
 
 	"github.com/sealdb/neodb/config"
 
-	jump "github.com/lithammer/go-jump-consistent-hash"
 	"github.com/pkg/errors"
 	"github.com/sealdb/mysqlstack/sqlparser"
 	"github.com/sealdb/mysqlstack/sqlparser/depends/common"
@@ -53,6 +52,9 @@ type Hash struct {
 	// hash method
 	typ MethodType
 
+	// hash function
+	fn HashFunction
+
 	// table config
 	conf *config.TableConfig
 
@@ -78,6 +80,10 @@ func (h *Hash) Build() error {
 	var err error
 	var start, end int
 
+	if h.fn, err = GetHashFunction(h.conf.HashFunction); err != nil {
+		return err
+	}
+
 	for _, part := range h.conf.Partitions {
 		segments := strings.Split(part.Segment, "-")
 		if len(segments) != 2 {
@@ -161,28 +167,19 @@ func (h *Hash) Type() MethodType {
 }
 
 // GetIndex returns index based on sqlval.
+// If the hash function is modulo the partitions, returns the first slot of the partition.
 func (h *Hash) GetIndex(sqlval *sqlparser.SQLVal) (int, error) {
-	idx := -1
-	valStr := common.BytesToString(sqlval.Val)
-	switch sqlval.Type {
-	case sqlparser.IntVal:
-		unsigned, err := strconv.ParseInt(valStr, 0, 64)
-		if err != nil {
-			return -1, errors.Errorf("hash.getindex.val.key.parser.uint64.error:[%v]", err)
-		}
-		idx = int(jump.Hash(uint64(unsigned), int32(h.slots)))
-	case sqlparser.FloatVal:
-		unsigned, err := strconv.ParseFloat(valStr, 64)
-		if err != nil {
-			return -1, errors.Errorf("hash.getindex.val.key.parser.float.error:[%v]", err)
-		}
-		idx = int(jump.Hash(uint64(unsigned), int32(h.slots)))
-	case sqlparser.StrVal:
-		idx = int(jump.HashString(valStr, int32(h.slots), jump.NewCRC64()))
-	default:
-		return -1, errors.Errorf("hash.unsupported.key.type:[%v]", sqlval.Type)
+	if h.fn == nil {
+		return -1, errors.New("hash.function.is.not.built")
+	}
+	if !h.fn.Modulo() {
+		return h.fn.Index(sqlval, h.slots)
 	}
-	return idx, nil
+	idx, err := h.fn.Index(sqlval, len(h.Segments))
+	if err != nil {
+		return -1, err
+	}
+	return h.Segments[idx].Range.(*HashRange).Start, nil
 }
 
 // GetSegments returns Segments based on index.
@@ -196,3 +193,32 @@ func (h *Hash) GetSegment(index int) (Segment, error) {
 	}
 	return h.partitions[index], nil
 }
+
+// TupleKey combines the values of the composite shard key into one key,
+// the integer values are normalized, so 1 and '1' are the same.
+func TupleKey(vals []*sqlparser.SQLVal) (*sqlparser.SQLVal, error) {
+	var buf bytes.Buffer
+	for _, val := range vals {
+		valStr := common.BytesToString(val.Val)
+		switch val.Type {
+		case sqlparser.IntVal:
+			v, err := strconv.ParseInt(valStr, 0, 64)
+			if err != nil {
+				return nil, errors.Errorf("hash.tuplekey.val.key.parser.int64.error:[%v]", err)
+			}
+			valStr = strconv.FormatInt(v, 10)
+		case sqlparser.FloatVal:
+			v, err := strconv.ParseFloat(valStr, 64)
+			if err != nil {
+				return nil, errors.Errorf("hash.tuplekey.val.key.parser.float.error:[%v]", err)
+			}
+			valStr = strconv.FormatInt(int64(v), 10)
+		case sqlparser.StrVal:
+		default:
+			return nil, errors.Errorf("hash.unsupported.key.type:[%v]", val.Type)
+		}
+		// The length prefix keeps the combination unambiguous, ('a,', 'b') != ('a', ',b').
+		fmt.Fprintf(&buf, "%d:%s", len(valStr), valStr)
+	}
+	return sqlparser.NewStrVal(buf.Bytes()), nil
+}
@@ -245,3 +245,30 @@ func TestHashLookupBench(t *testing.T) {
 		fmt.Printf(" LOOP\t%v COST %v, avg:%v/s\n", N, took, (int64(N)/(took.Nanoseconds()/1e6))*1000)
 	}
 }
+
+func TestHashTupleKey(t *testing.T) {
+	// Same tuple in different forms.
+	{
+		key1, err := TupleKey([]*sqlparser.SQLVal{sqlparser.NewIntVal([]byte("0x10")), sqlparser.NewStrVal([]byte("a"))})
+		assert.Nil(t, err)
+		key2, err := TupleKey([]*sqlparser.SQLVal{sqlparser.NewFloatVal([]byte("16.2")), sqlparser.NewStrVal([]byte("a"))})
+		assert.Nil(t, err)
+		assert.Equal(t, "2:161:a", string(key1.Val))
+		assert.Equal(t, key1, key2)
+	}
+
+	// The length prefix keeps the tuples apart.
+	{
+		key1, err := TupleKey([]*sqlparser.SQLVal{sqlparser.NewStrVal([]byte("a:1")), sqlparser.NewStrVal([]byte("b"))})
+		assert.Nil(t, err)
+		key2, err := TupleKey([]*sqlparser.SQLVal{sqlparser.NewStrVal([]byte("a")), sqlparser.NewStrVal([]byte("1:b"))})
+		assert.Nil(t, err)
+		assert.NotEqual(t, key1, key2)
+	}
+
+	// Error.
+	{
+		_, err := TupleKey([]*sqlparser.SQLVal{sqlparser.NewIntVal([]byte("1")), sqlparser.NewValArg([]byte(":a"))})
+		assert.Equal(t, "hash.unsupported.key.type:[5]", err.Error())
+	}
+}
@@ -0,0 +1,263 @@
+/*
+ * NeoDB
+ *
+ * Copyright 2021-2030 The NeoDB Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package router
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+	"math/bits"
+	"strconv"
+	"sync"
+
+	jump "github.com/lithammer/go-jump-consistent-hash"
+	"github.com/pkg/errors"
+	"github.com/sealdb/mysqlstack/sqlparser"
+	"github.com/sealdb/mysqlstack/sqlparser/depends/common"
+)
+
+const (
+	// HashFunctionJumpCRC64 is the jump consistent hash on the slots, strings are hashed by CRC64 first.
+	HashFunctionJumpCRC64 = "jump-crc64"
+	// HashFunctionCRC32Mod places the key in the partition crc32(key) % partitions.
+	HashFunctionCRC32Mod = "crc32-mod"
+	// HashFunctionMurmur3 places the key in the slot murmur3(key) % slots.
+	HashFunctionMurmur3 = "murmur3"
+	// HashFunctionMod places the integer key in the partition key % partitions.
+	HashFunctionMod = "mod"
+)
+
+// DefaultHashFunction is the hash function used if the table doesn't specify one.
+const DefaultHashFunction = HashFunctionJumpCRC64
+
+// HashFunction maps the shard key to an index in [0, n).
+type HashFunction interface {
+	// Index returns the index of the key in [0, n).
+	Index(sqlval *sqlparser.SQLVal, n int) (int, error)
+
+	// Modulo returns true if n is the number of the partitions and the index is
+	// the ordinal of the partition, like the 'mod N' of other sharding middlewares.
+	// Otherwise n is the number of the slots and the index is the slot.
+	Modulo() bool
+}
+
+var (
+	hashFunctionsMu sync.RWMutex
+	hashFunctions   = map[string]HashFunction{
+		HashFunctionJumpCRC64: &jumpCRC64{},
+		HashFunctionCRC32Mod:  &crc32Mod{},
+		HashFunctionMurmur3:   &murmur3{},
+		HashFunctionMod:       &intMod{},
+	}
+)
+
+// RegisterHashFunction used to register a new hash function, the name can't be registered twice.
+func RegisterHashFunction(name string, fn HashFunction) error {
+	hashFunctionsMu.Lock()
+	defer hashFunctionsMu.Unlock()
+
+	if name == "" || fn == nil {
+		return errors.New("router.hash.function.name.and.function.can't.be.empty")
+	}
+	if _, ok := hashFunctions[name]; ok {
+		return errors.Errorf("router.hash.function[%s].already.registered", name)
+	}
+	hashFunctions[name] = fn
+	return nil
+}
+
+// GetHashFunction returns the hash function by name, empty name returns the default.
+func GetHashFunction(name string) (HashFunction, error) {
+	hashFunctionsMu.RLock()
+	defer hashFunctionsMu.RUnlock()
+
+	if name == "" {
+		name = DefaultHashFunction
+	}
+	fn, ok := hashFunctions[name]
+	if !ok {
+		return nil, errors.Errorf("router.hash.function[%s].is.unsupported", name)
+	}
+	return fn, nil
+}
+
+// hashKeyBytes returns the bytes hashed for the key, the numbers are
+// formatted as decimal integers, so 1, 1.0 and '1' are the same.
+func hashKeyBytes(sqlval *sqlparser.SQLVal) ([]byte, error) {
+	switch sqlval.Type {
+	case sqlparser.IntVal, sqlparser.FloatVal:
+		v, err := hashKeyInt(sqlval)
+		if err != nil {
+			return nil, err
+		}
+		return strconv.AppendInt(nil, v, 10), nil
+	case sqlparser.StrVal:
+		return sqlval.Val, nil
+	}
+	return nil, errors.Errorf("hash.unsupported.key.type:[%v]", sqlval.Type)
+}
+
+// hashKeyInt returns the integer of the key, the float is truncated.
+func hashKeyInt(sqlval *sqlparser.SQLVal) (int64, error) {
+	valStr := common.BytesToString(sqlval.Val)
+	switch sqlval.Type {
+	case sqlparser.IntVal:
+		v, err := strconv.ParseInt(valStr, 0, 64)
+		if err != nil {
+			return 0, errors.Errorf("hash.getindex.val.key.parser.uint64.error:[%v]", err)
+		}
+		return v, nil
+	case sqlparser.FloatVal:
+		v, err := strconv.ParseFloat(valStr, 64)
+		if err != nil {
+			return 0, errors.Errorf("hash.getindex.val.key.parser.float.error:[%v]", err)
+		}
+		return int64(v), nil
+	}
+	return 0, errors.Errorf("hash.unsupported.key.type:[%v]", sqlval.Type)
+}
+
+// jumpCRC64 is the default hash function.
+type jumpCRC64 struct{}
+
+// Index impl.
+func (*jumpCRC64) Index(sqlval *sqlparser.SQLVal, n int) (int, error) {
+	switch sqlval.Type {
+	case sqlparser.IntVal:
+		v, err := hashKeyInt(sqlval)
+		if err != nil {
+			return -1, err
+		}
+		return int(jump.Hash(uint64(v), int32(n))), nil
+	case sqlparser.FloatVal:
+		v, err := strconv.ParseFloat(common.BytesToString(sqlval.Val), 64)
+		if err != nil {
+			return -1, errors.Errorf("hash.getindex.val.key.parser.float.error:[%v]", err)
+		}
+		return int(jump.Hash(uint64(v), int32(n))), nil
+	case sqlparser.StrVal:
+		return int(jump.HashString(common.BytesToString(sqlval.Val), int32(n), jump.NewCRC64())), nil
+	}
+	return -1, errors.Errorf("hash.unsupported.key.type:[%v]", sqlval.Type)
+}
+
+// Modulo impl.
+func (*jumpCRC64) Modulo() bool {
+	return false
+}
+
+// crc32Mod uses the IEEE crc32 modulo the partitions.
+type crc32Mod struct{}
+
+// Index impl.
+func (*crc32Mod) Index(sqlval *sqlparser.SQLVal, n int) (int, error) {
+	key, err := hashKeyBytes(sqlval)
+	if err != nil {
+		return -1, err
+	}
+	return int(crc32.ChecksumIEEE(key) % uint32(n)), nil
+}
+
+// Modulo impl.
+func (*crc32Mod) Modulo() bool {
+	return true
+}
+
+// murmur3 uses the 32 bits murmur3(x86, seed 0) modulo the slots.
+type murmur3 struct{}
+
+// Index impl.
+func (*murmur3) Index(sqlval *sqlparser.SQLVal, n int) (int, error) {
+	key, err := hashKeyBytes(sqlval)
+	if err != nil {
+		return -1, err
+	}
+	return int(murmur3Sum32(key) % uint32(n)), nil
+}
+
+// Modulo impl.
+func (*murmur3) Modulo() bool {
+	return false
+}
+
+// murmur3Sum32 returns the murmur3 x86 32 bits hash with seed 0.
+func murmur3Sum32(data []byte) uint32 {
+	const (
+		c1 = 0xcc9e2d51
+		c2 = 0x1b873593
+	)
+
+	var h uint32
+	nblocks := len(data) / 4
+	for i := 0; i < nblocks; i++ {
+		k := binary.LittleEndian.Uint32(data[i*4:])
+		k *= c1
+		k = bits.RotateLeft32(k, 15)
+		k *= c2
+		h ^= k
+		h = bits.RotateLeft32(h, 13)
+		h = h*5 + 0xe6546b64
+	}
+
+	var k uint32
+	tail := data[nblocks*4:]
+	switch len(tail) {
+	case 3:
+		k ^= uint32(tail[2]) << 16
+		fallthrough
+	case 2:
+		k ^= uint32(tail[1]) << 8
+		fallthrough
+	case 1:
+		k ^= uint32(tail[0])
+		k *= c1
+		k = bits.RotateLeft32(k, 15)
+		k *= c2
+		h ^= k
+	}
+
+	h ^= uint32(len(data))
+	h ^= h >> 16
+	h *= 0x85ebca6b
+	h ^= h >> 13
+	h *= 0xc2b2ae35
+	h ^= h >> 16
+	return h
+}
+
+// intMod places the integer key in the partition key % partitions,
+// the negative key is placed as its absolute value.
+type intMod struct{}
+
+// Index impl.
+func (*intMod) Index(sqlval *sqlparser.SQLVal, n int) (int, error) {
+	var v int64
+	var err error
+	switch sqlval.Type {
+	case sqlparser.IntVal, sqlparser.FloatVal:
+		if v, err = hashKeyInt(sqlval); err != nil {
+			return -1, err
+		}
+	case sqlparser.StrVal:
+		if v, err = strconv.ParseInt(common.BytesToString(sqlval.Val), 10, 64); err != nil {
+			return -1, errors.Errorf("hash.mod.key[%s].must.be.integer", sqlval.Val)
+		}
+	default:
+		return -1, errors.Errorf("hash.unsupported.key.type:[%v]", sqlval.Type)
+	}
+	idx := v % int64(n)
+	if idx < 0 {
+		idx = -idx
+	}
+	return int(idx), nil
+}
+
+// Modulo impl.
+func (*intMod) Modulo() bool {
+	return true
+}
@@ -0,0 +1,145 @@
+/*
+ * NeoDB
+ *
+ * Copyright 2021-2030 The NeoDB Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package router
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/sealdb/neodb/config"
+
+	"github.com/sealdb/mysqlstack/sqlparser"
+	"github.com/sealdb/mysqlstack/xlog"
+	"github.com/stretchr/testify/assert"
+)
+
+func mockHashFunctionConfig(fn string) *config.TableConfig {
+	return &config.TableConfig{
+		Name:         "H",
+		ShardType:    "HASH",
+		ShardKey:     "id",
+		HashFunction: fn,
+		Partitions: []*config.PartitionConfig{
+			{Table: "H_0000", Segment: "0-1024", Backend: "backend0"},
+			{Table: "H_0001", Segment: "1024-2048", Backend: "backend1"},
+			{Table: "H_0002", Segment: "2048-3072", Backend: "backend2"},
+			{Table: "H_0003", Segment: "3072-4096", Backend: "backend3"},
+		},
+	}
+}
+
+func TestHashFunction(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+
+	tests := []struct {
+		fn    string
+		key   *sqlparser.SQLVal
+		table string
+	}{
+		// The default jump-crc64 keeps the placement.
+		{"", sqlparser.NewIntVal([]byte("1")), "H_0002"},
+		{HashFunctionJumpCRC64, sqlparser.NewIntVal([]byte("1")), "H_0002"},
+		// mod: key % partitions.
+		{HashFunctionMod, sqlparser.NewIntVal([]byte("0")), "H_0000"},
+		{HashFunctionMod, sqlparser.NewIntVal([]byte("5")), "H_0001"},
+		{HashFunctionMod, sqlparser.NewIntVal([]byte("-7")), "H_0003"},
+		{HashFunctionMod, sqlparser.NewStrVal([]byte("10")), "H_0002"},
+		{HashFunctionMod, sqlparser.NewFloatVal([]byte("11.9")), "H_0003"},
+		// crc32-mod: crc32('123456789')=0xcbf43926, 0xcbf43926 % 4 = 2.
+		{HashFunctionCRC32Mod, sqlparser.NewStrVal([]byte("123456789")), "H_0002"},
+		{HashFunctionCRC32Mod, sqlparser.NewIntVal([]byte("123456789")), "H_0002"},
+		// murmur3: murmur3('hello')=0x248bfa47, 0x248bfa47 % 4096 = 2631.
+		{HashFunctionMurmur3, sqlparser.NewStrVal([]byte("hello")), "H_0002"},
+	}
+	for _, test := range tests {
+		hash := NewHash(log, _mockHashSlots, mockHashFunctionConfig(test.fn))
+		err := hash.Build()
+		assert.Nil(t, err)
+		idx, err := hash.GetIndex(test.key)
+		assert.Nil(t, err)
+		seg, err := hash.GetSegment(idx)
+		assert.Nil(t, err)
+		assert.Equal(t, test.table, seg.Table, fmt.Sprintf("%s:%s", test.fn, test.key.Val))
+	}
+
+	assert.Equal(t, uint32(0x248bfa47), murmur3Sum32([]byte("hello")))
+	assert.Equal(t, uint32(0), murmur3Sum32(nil))
+}
+
+func TestHashFunctionError(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+
+	// Unsupported function.
+	{
+		hash := NewHash(log, _mockHashSlots, mockHashFunctionConfig("md5"))
+		err := hash.Build()
+		assert.EqualError(t, err, "router.hash.function[md5].is.unsupported")
+	}
+
+	// mod on non-integer.
+	{
+		hash := NewHash(log, _mockHashSlots, mockHashFunctionConfig(HashFunctionMod))
+		err := hash.Build()
+		assert.Nil(t, err)
+		_, err = hash.GetIndex(sqlparser.NewStrVal([]byte("abc")))
+		assert.EqualError(t, err, "hash.mod.key[abc].must.be.integer")
+		_, err = hash.GetIndex(sqlparser.NewHexVal([]byte("01")))
+		assert.NotNil(t, err)
+	}
+
+	// Register.
+	{
+		err := RegisterHashFunction(HashFunctionMod, &intMod{})
+		assert.EqualError(t, err, "router.hash.function[mod].already.registered")
+		err = RegisterHashFunction("", nil)
+		assert.NotNil(t, err)
+		err = RegisterHashFunction("test-mod", &intMod{})
+		assert.Nil(t, err)
+		defer delete(hashFunctions, "test-mod")
+		fn, err := GetHashFunction("test-mod")
+		assert.Nil(t, err)
+		assert.True(t, fn.Modulo())
+	}
+}
+
+func TestHashFunctionFrmLoad(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	router, cleanup := MockNewRouter(log)
+	defer cleanup()
+
+	router.CreateDatabase("test")
+	conf := mockHashFunctionConfig(HashFunctionMod)
+	conf.Name = "h"
+	conf.Slots = 4096
+	conf.Blocks = 1024
+	err := router.writeTableFrmData("test", "h", conf)
+	assert.Nil(t, err)
+
+	{
+		router1, cleanup1 := MockNewRouter(log)
+		defer cleanup1()
+		err := router1.LoadConfig()
+		assert.Nil(t, err)
+		tconf, err := router1.TableConfig("test", "h")
+		assert.Nil(t, err)
+		assert.Equal(t, HashFunctionMod, tconf.HashFunction)
+	}
+
+	// The unknown hash function fails the rebuild.
+	{
+		conf.HashFunction = "md5"
+		err := router.writeTableFrmData("test", "h", conf)
+		assert.Nil(t, err)
+
+		router1, cleanup1 := MockNewRouter(log)
+		defer cleanup1()
+		err = router1.LoadConfig()
+		assert.EqualError(t, err, "router.hash.function[md5].is.unsupported")
+	}
+}
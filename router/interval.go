@@ -0,0 +1,227 @@
+/*
+ * NeoDB
+ *
+ * Copyright 2021-2030 The NeoDB Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package router
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sealdb/neodb/config"
+
+	"github.com/pkg/errors"
+	"github.com/sealdb/mysqlstack/sqlparser"
+	"github.com/sealdb/mysqlstack/sqlparser/depends/common"
+	"github.com/sealdb/mysqlstack/xlog"
+)
+
+const (
+	// IntervalDay splits the partitions by day.
+	IntervalDay = "DAY"
+	// IntervalMonth splits the partitions by month.
+	IntervalMonth = "MONTH"
+)
+
+// IntervalLayout is the layout of the interval bounds.
+const IntervalLayout = "2006-01-02 15:04:05"
+
+// intervalKeyLayouts are the datetime layouts accepted as the shard key.
+var intervalKeyLayouts = []string{
+	"2006-01-02 15:04:05.999999",
+	"2006-01-02",
+	"20060102150405",
+	"20060102",
+}
+
+// Interval tuple, the range partitions split by the time interval on a datetime shard key.
+// The bounds are formatted by IntervalLayout, so the keys are normalized and compared as strings.
+type Interval struct {
+	*Range
+}
+
+// NewInterval creates new interval.
+func NewInterval(log *xlog.Log, conf *config.TableConfig) *Interval {
+	rang := NewRange(log, conf)
+	rang.typ = MethodTypeInterval
+	return &Interval{Range: rang}
+}
+
+// Build used to build interval segments from schema config.
+func (iv *Interval) Build() error {
+	if err := checkInterval(iv.conf.Interval); err != nil {
+		return err
+	}
+	for _, part := range iv.conf.Partitions {
+		if _, err := time.Parse(IntervalLayout, part.LessThan); err != nil {
+			return errors.Errorf("interval.partition[%v].bound[%v].must.be.datetime", part.Table, part.LessThan)
+		}
+	}
+	return iv.Range.Build()
+}
+
+// normalize formats the sqlval as the bounds.
+func (iv *Interval) normalize(sqlval *sqlparser.SQLVal) (*sqlparser.SQLVal, error) {
+	if sqlval == nil {
+		return nil, nil
+	}
+	switch sqlval.Type {
+	case sqlparser.IntVal, sqlparser.StrVal:
+	default:
+		return nil, errors.Errorf("interval.unsupported.key.type:[%v]", sqlval.Type)
+	}
+	t, err := ParseIntervalTime(common.BytesToString(sqlval.Val))
+	if err != nil {
+		return nil, err
+	}
+	return sqlparser.NewStrVal([]byte(t.Format(IntervalLayout))), nil
+}
+
+// Lookup used to lookup partition(s) through the sharding-key range [start, end].
+func (iv *Interval) Lookup(start *sqlparser.SQLVal, end *sqlparser.SQLVal) ([]Segment, error) {
+	var err error
+	if start, err = iv.normalize(start); err != nil {
+		return nil, err
+	}
+	if end, err = iv.normalize(end); err != nil {
+		return nil, err
+	}
+	return iv.Range.Lookup(start, end)
+}
+
+// GetIndex returns index based on sqlval.
+func (iv *Interval) GetIndex(sqlval *sqlparser.SQLVal) (int, error) {
+	key, err := iv.normalize(sqlval)
+	if err != nil {
+		return -1, err
+	}
+	return iv.Range.GetIndex(key)
+}
+
+// ParseIntervalTime parses the datetime key, such as '2021-01-02 03:04:05', '2021-01-02' or 20210102.
+func ParseIntervalTime(val string) (time.Time, error) {
+	for _, layout := range intervalKeyLayouts {
+		if t, err := time.Parse(layout, val); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, errors.Errorf("interval.key[%v].is.not.datetime", val)
+}
+
+func checkInterval(interval *config.IntervalConfig) error {
+	if interval == nil {
+		return errors.New("interval.config.can't.be.nil")
+	}
+	switch interval.Unit {
+	case IntervalDay, IntervalMonth:
+	default:
+		return errors.Errorf("interval.unit[%v].is.unsupported", interval.Unit)
+	}
+	if interval.Premake < 0 || interval.Retention < 0 {
+		return errors.Errorf("interval.premake[%d].retention[%d].can't.be.negative", interval.Premake, interval.Retention)
+	}
+	return nil
+}
+
+// intervalStart returns the start of the interval which t is in.
+func intervalStart(unit string, t time.Time) time.Time {
+	if unit == IntervalMonth {
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	}
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// intervalAdd returns t moved by n intervals.
+func intervalAdd(unit string, t time.Time, n int) time.Time {
+	if unit == IntervalMonth {
+		return t.AddDate(0, n, 0)
+	}
+	return t.AddDate(0, 0, n)
+}
+
+// subTableSeq returns the sequence of the sub table, eg: t_0012 -> 12.
+func subTableSeq(table string) (int, bool) {
+	idx := strings.LastIndex(table, "_")
+	if idx < 0 {
+		return 0, false
+	}
+	seq, err := strconv.Atoi(table[idx+1:])
+	return seq, err == nil
+}
+
+// IntervalPremake returns the partitions to append, so that the partitions cover the
+// current interval of now and the next Premake intervals. The backends are used in turn.
+func IntervalPremake(conf *config.TableConfig, backends []string, now time.Time) ([]*config.PartitionConfig, error) {
+	interval := conf.Interval
+	if err := checkInterval(interval); err != nil {
+		return nil, err
+	}
+	if len(backends) == 0 {
+		return nil, errors.New("router.interval.backends.can't.be.empty")
+	}
+
+	unit := interval.Unit
+	current := intervalStart(unit, now)
+	end := intervalAdd(unit, current, interval.Premake+1)
+
+	seq := 0
+	bound := current
+	if n := len(conf.Partitions); n > 0 {
+		last := conf.Partitions[n-1]
+		var err error
+		if bound, err = time.Parse(IntervalLayout, last.LessThan); err != nil {
+			return nil, errors.Errorf("interval.partition[%v].bound[%v].must.be.datetime", last.Table, last.LessThan)
+		}
+		lastSeq, ok := subTableSeq(last.Table)
+		if !ok {
+			lastSeq = n - 1
+		}
+		seq = lastSeq + 1
+	}
+
+	var parts []*config.PartitionConfig
+	for bound.Before(end) {
+		bound = intervalAdd(unit, bound, 1)
+		parts = append(parts, &config.PartitionConfig{
+			Table:    fmt.Sprintf("%s_%04d", conf.Name, seq),
+			Backend:  backends[seq%len(backends)],
+			LessThan: bound.Format(IntervalLayout),
+		})
+		seq++
+	}
+	return parts, nil
+}
+
+// IntervalExpired returns the partitions out of the retention window, which keeps the current
+// interval of now and the Retention intervals before it. Retention 0 keeps all the partitions,
+// and the last partition is always kept.
+func IntervalExpired(conf *config.TableConfig, now time.Time) ([]*config.PartitionConfig, error) {
+	interval := conf.Interval
+	if err := checkInterval(interval); err != nil {
+		return nil, err
+	}
+	if interval.Retention == 0 {
+		return nil, nil
+	}
+
+	limit := intervalAdd(interval.Unit, intervalStart(interval.Unit, now), -interval.Retention)
+	var parts []*config.PartitionConfig
+	for i := 0; i < len(conf.Partitions)-1; i++ {
+		part := conf.Partitions[i]
+		bound, err := time.Parse(IntervalLayout, part.LessThan)
+		if err != nil {
+			return nil, errors.Errorf("interval.partition[%v].bound[%v].must.be.datetime", part.Table, part.LessThan)
+		}
+		if bound.After(limit) {
+			break
+		}
+		parts = append(parts, part)
+	}
+	return parts, nil
+}
@@ -0,0 +1,188 @@
+/*
+ * NeoDB
+ *
+ * Copyright 2021-2030 The NeoDB Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package router
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sealdb/neodb/config"
+
+	"github.com/sealdb/mysqlstack/sqlparser"
+	"github.com/sealdb/mysqlstack/xlog"
+	"github.com/stretchr/testify/assert"
+)
+
+func mockIntervalConfig(unit string, bounds ...string) *config.TableConfig {
+	conf := &config.TableConfig{
+		Name:      "iv",
+		ShardType: MethodTypeInterval,
+		ShardKey:  "ts",
+		Interval:  &config.IntervalConfig{Unit: unit, Premake: 2, Retention: 2},
+	}
+	for i, bound := range bounds {
+		conf.Partitions = append(conf.Partitions, &config.PartitionConfig{
+			Table:    "iv_000" + string(rune('0'+i)),
+			Backend:  "backend1",
+			LessThan: bound,
+		})
+	}
+	return conf
+}
+
+func TestInterval(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	interval := NewInterval(log, mockIntervalConfig(IntervalDay, "2021-01-02 00:00:00", "2021-01-03 00:00:00", "2021-01-04 00:00:00"))
+	err := interval.Build()
+	assert.Nil(t, err)
+	assert.Equal(t, MethodTypeInterval, string(interval.Type()))
+	assert.Equal(t, "[MINVALUE-2021-01-02 00:00:00)", interval.Segments[0].Range.String())
+
+	// GetIndex.
+	{
+		vals := []*sqlparser.SQLVal{
+			sqlparser.NewStrVal([]byte("2021-01-01 23:59:59")),
+			sqlparser.NewStrVal([]byte("2021-01-02")),
+			sqlparser.NewStrVal([]byte("2021-01-03 12:00:00.123")),
+			sqlparser.NewIntVal([]byte("20210103")),
+			sqlparser.NewIntVal([]byte("20210102235959")),
+		}
+		wants := []int{0, 1, 2, 2, 1}
+		for i, val := range vals {
+			idx, err := interval.GetIndex(val)
+			assert.Nil(t, err)
+			assert.Equal(t, wants[i], idx)
+		}
+
+		_, err := interval.GetIndex(sqlparser.NewStrVal([]byte("2021-01-04")))
+		assert.EqualError(t, err, "Table has no partition for value 2021-01-04 00:00:00")
+		_, err = interval.GetIndex(sqlparser.NewStrVal([]byte("xx")))
+		assert.EqualError(t, err, "interval.key[xx].is.not.datetime")
+		_, err = interval.GetIndex(sqlparser.NewFloatVal([]byte("1.1")))
+		assert.EqualError(t, err, "interval.unsupported.key.type:[2]")
+	}
+
+	// Lookup.
+	{
+		segs, err := interval.Lookup(sqlparser.NewStrVal([]byte("2021-01-02 08:00:00")), nil)
+		assert.Nil(t, err)
+		assert.Equal(t, 2, len(segs))
+		assert.Equal(t, "iv_0001", segs[0].Table)
+
+		segs, err = interval.Lookup(nil, sqlparser.NewIntVal([]byte("20210101")))
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(segs))
+
+		_, err = interval.Lookup(sqlparser.NewStrVal([]byte("xx")), nil)
+		assert.NotNil(t, err)
+	}
+}
+
+func TestIntervalBuildError(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	noInterval := mockIntervalConfig(IntervalDay, "2021-01-02 00:00:00")
+	noInterval.Interval = nil
+	negative := mockIntervalConfig(IntervalDay, "2021-01-02 00:00:00")
+	negative.Interval.Premake = -1
+
+	confs := []*config.TableConfig{
+		noInterval,
+		negative,
+		mockIntervalConfig("YEAR", "2021-01-02 00:00:00"),
+		mockIntervalConfig(IntervalDay, "MAXVALUE"),
+		mockIntervalConfig(IntervalDay, "2021-01-03 00:00:00", "2021-01-02 00:00:00"),
+		mockIntervalConfig(IntervalDay),
+	}
+	wants := []string{
+		"interval.config.can't.be.nil",
+		"interval.premake[-1].retention[2].can't.be.negative",
+		"interval.unit[YEAR].is.unsupported",
+		"interval.partition[iv_0000].bound[MAXVALUE].must.be.datetime",
+		"range.partition[iv_0001].values.less.than.must.be.strictly.increasing",
+		"range.partition.can't.be.empty",
+	}
+	for i, conf := range confs {
+		err := NewInterval(log, conf).Build()
+		assert.EqualError(t, err, wants[i])
+	}
+}
+
+func TestIntervalPremake(t *testing.T) {
+	now := time.Date(2021, 1, 31, 10, 0, 0, 0, time.Local)
+	backends := []string{"backend1", "backend2"}
+
+	// Day.
+	{
+		conf := mockIntervalConfig(IntervalDay)
+		parts, err := IntervalPremake(conf, backends, now)
+		assert.Nil(t, err)
+		assert.Equal(t, 3, len(parts))
+		assert.Equal(t, "iv_0000", parts[0].Table)
+		assert.Equal(t, "2021-02-01 00:00:00", parts[0].LessThan)
+		assert.Equal(t, "backend2", parts[1].Backend)
+		assert.Equal(t, "2021-02-03 00:00:00", parts[2].LessThan)
+
+		// Covered already.
+		conf.Partitions = parts
+		parts, err = IntervalPremake(conf, backends, now)
+		assert.Nil(t, err)
+		assert.Equal(t, 0, len(parts))
+
+		// The next day.
+		parts, err = IntervalPremake(conf, backends, now.AddDate(0, 0, 1))
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(parts))
+		assert.Equal(t, "iv_0003", parts[0].Table)
+		assert.Equal(t, "backend2", parts[0].Backend)
+		assert.Equal(t, "2021-02-04 00:00:00", parts[0].LessThan)
+	}
+
+	// Month.
+	{
+		conf := mockIntervalConfig(IntervalMonth, "2020-11-01 00:00:00")
+		conf.Partitions[0].Table = "iv_0011"
+		parts, err := IntervalPremake(conf, backends, now)
+		assert.Nil(t, err)
+		assert.Equal(t, 5, len(parts))
+		assert.Equal(t, "iv_0012", parts[0].Table)
+		assert.Equal(t, "2020-12-01 00:00:00", parts[0].LessThan)
+		assert.Equal(t, "2021-04-01 00:00:00", parts[4].LessThan)
+	}
+
+	// Errors.
+	{
+		_, err := IntervalPremake(mockIntervalConfig(IntervalDay), nil, now)
+		assert.EqualError(t, err, "router.interval.backends.can't.be.empty")
+
+		_, err = IntervalPremake(mockIntervalConfig(IntervalDay, "xx"), backends, now)
+		assert.EqualError(t, err, "interval.partition[iv_0000].bound[xx].must.be.datetime")
+	}
+}
+
+func TestIntervalExpired(t *testing.T) {
+	now := time.Date(2021, 1, 5, 10, 0, 0, 0, time.Local)
+	conf := mockIntervalConfig(IntervalDay, "2021-01-02 00:00:00", "2021-01-03 00:00:00", "2021-01-04 00:00:00", "2021-01-06 00:00:00")
+
+	// Keep 01-03, 01-04 and 01-05.
+	parts, err := IntervalExpired(conf, now)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(parts))
+	assert.Equal(t, "iv_0001", parts[1].Table)
+
+	// The last one is always kept.
+	parts, err = IntervalExpired(conf, now.AddDate(1, 0, 0))
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(parts))
+
+	// Keep all.
+	conf.Interval.Retention = 0
+	parts, err = IntervalExpired(conf, now)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(parts))
+}
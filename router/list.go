@@ -11,6 +11,8 @@ package router
 
 import (
 	"bytes"
+	"math"
+	"strconv"
 
 	"github.com/sealdb/neodb/config"
 
@@ -45,6 +47,12 @@ type List struct {
 	// table config
 	conf *config.TableConfig
 
+	// values maps the list value key to the segment index.
+	values map[string]int
+
+	// the index of the DEFAULT segment, -1 if absent.
+	defaultIdx int
+
 	// Partition map
 	Segments []Segment `json:",omitempty"`
 }
@@ -52,20 +60,42 @@ type List struct {
 // NewList creates new list.
 func NewList(log *xlog.Log, conf *config.TableConfig) *List {
 	return &List{
-		log:      log,
-		conf:     conf,
-		typ:      MethodTypeList,
-		Segments: make([]Segment, 0, 16),
+		log:        log,
+		conf:       conf,
+		typ:        MethodTypeList,
+		values:     make(map[string]int),
+		defaultIdx: -1,
+		Segments:   make([]Segment, 0, 16),
 	}
 }
 
 // Build used to build list bitmap from schema config.
+// Each partition holds a value set, the values can't be duplicate and at most one partition is DEFAULT.
 func (list *List) Build() error {
-	for _, part := range list.conf.Partitions {
+	for i, part := range list.conf.Partitions {
+		values := part.ListValues
+		if len(values) == 0 && part.ListValue != "" {
+			values = []string{part.ListValue}
+		}
+		if part.ListDefault {
+			if list.defaultIdx != -1 {
+				return errors.Errorf("list.partition[%v].DEFAULT.is.duplicate", part.Table)
+			}
+			list.defaultIdx = i
+		}
+		for _, value := range values {
+			key := listKey(value)
+			if _, ok := list.values[key]; ok {
+				return errors.Errorf("list.partition[%v].value[%v].is.duplicate", part.Table, value)
+			}
+			list.values[key] = i
+		}
+
 		partition := Segment{
-			Table:     part.Table,
-			Backend:   part.Backend,
-			ListValue: part.ListValue,
+			Table:       part.Table,
+			Backend:     part.Backend,
+			ListValues:  values,
+			ListDefault: part.ListDefault,
 			Range: &ListRange{
 				str: "",
 			},
@@ -76,33 +106,87 @@ func (list *List) Build() error {
 	return nil
 }
 
+// listKey returns the key to match the list value, the numeric values are
+// matched as numbers, eg: 1, '1' and 1.0 are the same, the others as strings.
+func listKey(value string) string {
+	if i, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return strconv.FormatInt(i, 10)
+	}
+	if f, err := strconv.ParseFloat(value, 64); err == nil {
+		if f == math.Trunc(f) && math.Abs(f) < 1<<53 {
+			return strconv.FormatInt(int64(f), 10)
+		}
+		return strconv.FormatFloat(f, 'g', -1, 64)
+	}
+	return value
+}
+
 // Clear used to clean partitions.
 func (list *List) Clear() error {
 	return nil
 }
 
-// Lookup used to lookup partition(s) through the sharding-key range
-// List.Lookup only supports the type uint64/string.
+// Lookup used to lookup partition(s) through the sharding-key range [start, end],
+// the nil start(or end) means the range is unbounded below(or above).
+// List.Lookup prunes the partitions whose numeric values are all out of the range,
+// the others and the DEFAULT partition are kept.
 func (list *List) Lookup(start *sqlparser.SQLVal, end *sqlparser.SQLVal) ([]Segment, error) {
 	// if open interval we returns all partitions.
-	if start == nil || end == nil {
+	if start == nil && end == nil {
 		return list.Segments, nil
 	}
 
-	// Check item types.
-	if start.Type != end.Type {
-		return nil, errors.Errorf("list.lookup.key.type.must.be.same:[%v!=%v]", start.Type, end.Type)
-	}
-
-	// List just handle the equal.
-	if bytes.Equal(start.Val, end.Val) {
+	// List handle the equal.
+	if start != nil && end != nil && bytes.Equal(start.Val, end.Val) {
+		// Check item types.
+		if start.Type != end.Type {
+			return nil, errors.Errorf("list.lookup.key.type.must.be.same:[%v!=%v]", start.Type, end.Type)
+		}
 		idx, err := list.GetIndex(start)
 		if err != nil {
 			return nil, err
 		}
 		return []Segment{list.Segments[idx]}, nil
 	}
-	return list.Segments, nil
+
+	var segments []Segment
+	for _, segment := range list.Segments {
+		if segment.ListDefault {
+			segments = append(segments, segment)
+			continue
+		}
+		for _, value := range segment.ListValues {
+			if inNumericRange(value, start, end) {
+				segments = append(segments, segment)
+				break
+			}
+		}
+	}
+	// The range matches no rows, but the query still needs a route.
+	if len(segments) == 0 && len(list.Segments) > 0 {
+		segments = list.Segments[:1]
+	}
+	return segments, nil
+}
+
+// inNumericRange returns false only if the value and the bounds are
+// numeric and the value is out of the range [start, end].
+func inNumericRange(value string, start *sqlparser.SQLVal, end *sqlparser.SQLVal) bool {
+	v, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return true
+	}
+	if start != nil {
+		if s, err := strconv.ParseFloat(common.BytesToString(start.Val), 64); err == nil && v < s {
+			return false
+		}
+	}
+	if end != nil {
+		if e, err := strconv.ParseFloat(common.BytesToString(end.Val), 64); err == nil && v > e {
+			return false
+		}
+	}
+	return true
 }
 
 // Type returns the list type.
@@ -110,16 +194,16 @@ func (list *List) Type() MethodType {
 	return list.typ
 }
 
-// GetIndex returns index based on sqlval.
+// GetIndex returns index based on sqlval, the value out of the lists goes to the DEFAULT partition.
 func (list *List) GetIndex(sqlval *sqlparser.SQLVal) (int, error) {
-	idx := -1
 	valStr := common.BytesToString(sqlval.Val)
-	for idx, segment := range list.Segments {
-		if segment.ListValue == valStr {
-			return idx, nil
-		}
+	if idx, ok := list.values[listKey(valStr)]; ok {
+		return idx, nil
+	}
+	if list.defaultIdx != -1 {
+		return list.defaultIdx, nil
 	}
-	return idx, errors.Errorf("Table has no partition for value %v", valStr)
+	return -1, errors.Errorf("Table has no partition for value %v", valStr)
 }
 
 // GetSegments returns Segments based on index.
@@ -13,6 +13,8 @@ import (
 	"sort"
 	"testing"
 
+	"github.com/sealdb/neodb/config"
+
 	"github.com/sealdb/mysqlstack/sqlparser"
 	"github.com/sealdb/mysqlstack/xlog"
 	"github.com/stretchr/testify/assert"
@@ -66,7 +68,16 @@ func TestListLookup(t *testing.T) {
 	// float
 	floatVal := sqlparser.NewFloatVal([]byte("65536.99999"))
 	{
-		_, err := list.Lookup(intVal, floatVal)
+		parts, err := list.Lookup(intVal, floatVal)
+		assert.Nil(t, err)
+		assert.Equal(t, 2, len(parts))
+		assert.Equal(t, "L_0001", parts[0].Table)
+		assert.Equal(t, "L_0002", parts[1].Table)
+	}
+
+	// type not same.
+	{
+		_, err := list.Lookup(intVal, sqlparser.NewStrVal([]byte("2")))
 		assert.NotNil(t, err)
 	}
 
@@ -74,7 +85,15 @@ func TestListLookup(t *testing.T) {
 	{
 		parts, err := list.Lookup(nil, intVal)
 		assert.Nil(t, err)
-		assert.Equal(t, 3, len(parts))
+		assert.Equal(t, 1, len(parts))
+		assert.Equal(t, "L_0000", parts[0].Table)
+	}
+
+	// [startKey, nil]
+	{
+		parts, err := list.Lookup(sqlparser.NewIntVal([]byte("5")), nil)
+		assert.Nil(t, err)
+		assert.Equal(t, 2, len(parts))
 	}
 
 	// [nil, nil]
@@ -91,7 +110,112 @@ func TestListLookup(t *testing.T) {
 
 		parts, err := list.Lookup(s, e)
 		assert.Nil(t, err)
-		assert.Equal(t, 3, len(parts))
+		assert.Equal(t, 1, len(parts))
 		sort.Sort(Segments(parts))
 	}
+
+	// [start, end], the not numeric values are kept.
+	{
+		conf := MockTableListConfig()
+		conf.Partitions[2].ListValue = "abc"
+		strList := NewList(log, conf)
+		err := strList.Build()
+		assert.Nil(t, err)
+
+		s := sqlparser.NewIntVal([]byte("3"))
+		e := sqlparser.NewIntVal([]byte("10"))
+		parts, err := strList.Lookup(s, e)
+		assert.Nil(t, err)
+		assert.Equal(t, 2, len(parts))
+		assert.Equal(t, "L_0001", parts[0].Table)
+		assert.Equal(t, "L_0002", parts[1].Table)
+	}
+}
+
+func TestListValuesAndDefault(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	conf := &config.TableConfig{
+		Name:      "L",
+		ShardType: "LIST",
+		ShardKey:  "id",
+		Partitions: []*config.PartitionConfig{
+			&config.PartitionConfig{Table: "L_0000", Backend: "backend1", ListValues: []string{"1", "2.5", "abc"}},
+			&config.PartitionConfig{Table: "L_0001", Backend: "backend2", ListValues: []string{"10", "20"}},
+			&config.PartitionConfig{Table: "L_0002", Backend: "backend2", ListDefault: true},
+		},
+	}
+	list := NewList(log, conf)
+	err := list.Build()
+	assert.Nil(t, err)
+
+	// The numeric values are matched as numbers.
+	{
+		vals := []*sqlparser.SQLVal{
+			sqlparser.NewIntVal([]byte("1")),
+			sqlparser.NewStrVal([]byte("1")),
+			sqlparser.NewFloatVal([]byte("1.0")),
+			sqlparser.NewFloatVal([]byte("2.50")),
+			sqlparser.NewStrVal([]byte("abc")),
+			sqlparser.NewIntVal([]byte("20")),
+			sqlparser.NewIntVal([]byte("3")),
+			sqlparser.NewStrVal([]byte("ABC")),
+			sqlparser.NewStrVal([]byte("01")),
+		}
+		wants := []int{0, 0, 0, 0, 0, 1, 2, 2, 0}
+		for i, val := range vals {
+			idx, err := list.GetIndex(val)
+			assert.Nil(t, err)
+			assert.Equal(t, wants[i], idx, string(val.Val))
+		}
+	}
+
+	// The DEFAULT partition and the not numeric values are kept in the range.
+	{
+		parts, err := list.Lookup(sqlparser.NewIntVal([]byte("5")), sqlparser.NewIntVal([]byte("15")))
+		assert.Nil(t, err)
+		assert.Equal(t, 3, len(parts))
+		assert.Equal(t, "L_0001", parts[1].Table)
+		assert.True(t, parts[2].ListDefault)
+
+		parts, err = list.Lookup(sqlparser.NewIntVal([]byte("5")), nil)
+		assert.Nil(t, err)
+		assert.Equal(t, 3, len(parts))
+
+		parts, err = list.Lookup(sqlparser.NewIntVal([]byte("30")), sqlparser.NewIntVal([]byte("30")))
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(parts))
+		assert.Equal(t, "L_0002", parts[0].Table)
+	}
+
+	// Without DEFAULT.
+	{
+		conf.Partitions = conf.Partitions[:2]
+		list := NewList(log, conf)
+		err := list.Build()
+		assert.Nil(t, err)
+		_, err = list.GetIndex(sqlparser.NewIntVal([]byte("3")))
+		assert.EqualError(t, err, "Table has no partition for value 3")
+	}
+}
+
+func TestListBuildError(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	confs := []*config.TableConfig{
+		&config.TableConfig{Name: "L", ShardType: "LIST", ShardKey: "id", Partitions: []*config.PartitionConfig{
+			&config.PartitionConfig{Table: "L_0000", Backend: "backend1", ListValues: []string{"1", "2"}},
+			&config.PartitionConfig{Table: "L_0001", Backend: "backend1", ListValues: []string{"1.0"}},
+		}},
+		&config.TableConfig{Name: "L", ShardType: "LIST", ShardKey: "id", Partitions: []*config.PartitionConfig{
+			&config.PartitionConfig{Table: "L_0000", Backend: "backend1", ListDefault: true},
+			&config.PartitionConfig{Table: "L_0001", Backend: "backend1", ListDefault: true},
+		}},
+	}
+	wants := []string{
+		"list.partition[L_0001].value[1.0].is.duplicate",
+		"list.partition[L_0001].DEFAULT.is.duplicate",
+	}
+	for i, conf := range confs {
+		err := NewList(log, conf).Build()
+		assert.EqualError(t, err, wants[i])
+	}
 }
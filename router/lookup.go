@@ -0,0 +1,211 @@
+/*
+ * NeoDB
+ *
+ * Copyright 2021-2030 The NeoDB Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package router
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sealdb/neodb/config"
+
+	"github.com/pkg/errors"
+	"github.com/sealdb/mysqlstack/sqlparser"
+	querypb "github.com/sealdb/mysqlstack/sqlparser/depends/query"
+	"github.com/sealdb/mysqlstack/sqlparser/depends/sqltypes"
+)
+
+// checkLookupIndexes used to check the lookup indexes of the table config.
+func checkLookupIndexes(tbl *config.TableConfig) error {
+	if len(tbl.LookupIndexes) == 0 {
+		return nil
+	}
+	switch tbl.ShardType {
+	case MethodTypeHash, MethodTypeList, MethodTypeRange, MethodTypeInterval:
+	default:
+		return errors.Errorf("router.table[%s].shardtype[%s].can't.have.lookup.index", tbl.Name, tbl.ShardType)
+	}
+	if len(SplitShardKey(tbl.ShardKey)) > 1 {
+		return errors.Errorf("router.table[%s].composite.shard.key.can't.have.lookup.index", tbl.Name)
+	}
+
+	names := make(map[string]bool)
+	for _, index := range tbl.LookupIndexes {
+		if index.Name == "" || index.Column == "" {
+			return errors.Errorf("router.table[%s].lookup.index.name.and.column.can't.be.empty", tbl.Name)
+		}
+		if index.Name == tbl.Name {
+			return errors.Errorf("router.table[%s].lookup.index[%s].can't.be.the.table.itself", tbl.Name, index.Name)
+		}
+		if strings.EqualFold(index.Column, tbl.ShardKey) {
+			return errors.Errorf("router.table[%s].lookup.index[%s].column[%s].is.the.shard.key", tbl.Name, index.Name, index.Column)
+		}
+		if names[index.Name] {
+			return errors.Errorf("router.table[%s].lookup.index[%s].is.duplicate", tbl.Name, index.Name)
+		}
+		names[index.Name] = true
+	}
+	return nil
+}
+
+// AddLookupIndex used to add the lookup index to the table and flush the schema to disk.
+// The lookup table must be a hash table sharded by the column.
+func (r *Router) AddLookupIndex(db, table string, index *config.LookupIndexConfig) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	conf, err := r.lookupTableConfig(db, table)
+	if err != nil {
+		return err
+	}
+	lookupTbl, ok := r.Schemas[db].Tables[index.Name]
+	if !ok {
+		return errors.Errorf("router.lookup.index[%s].table.doesn't.exist", index.Name)
+	}
+	if lookupTbl.TableConfig.ShardType != MethodTypeHash || !strings.EqualFold(lookupTbl.ShardKey, index.Column) {
+		return errors.Errorf("router.lookup.index[%s].table.must.be.hash.by[%s]", index.Name, index.Column)
+	}
+	if owner, ok := r.lookupIndexOwner(db, index.Name); ok {
+		return errors.Errorf("router.lookup.index[%s].already.belongs.to.table[%s]", index.Name, owner)
+	}
+
+	newConf := *conf
+	newConf.LookupIndexes = append(append([]*config.LookupIndexConfig{}, conf.LookupIndexes...), index)
+	return r.replaceTable(db, &newConf)
+}
+
+// FinishLookupIndex used to mark the lookup index built, the index is used for routing after that.
+func (r *Router) FinishLookupIndex(db, table, name string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	conf, err := r.lookupTableConfig(db, table)
+	if err != nil {
+		return err
+	}
+	newConf := *conf
+	newConf.LookupIndexes = make([]*config.LookupIndexConfig, 0, len(conf.LookupIndexes))
+	found := false
+	for _, index := range conf.LookupIndexes {
+		if index.Name == name {
+			built := *index
+			built.Building = false
+			index = &built
+			found = true
+		}
+		newConf.LookupIndexes = append(newConf.LookupIndexes, index)
+	}
+	if !found {
+		return errors.Errorf("router.table[%s].lookup.index[%s].not.found", table, name)
+	}
+	return r.replaceTable(db, &newConf)
+}
+
+// DropLookupIndex used to drop the lookup index from the table and flush the schema to disk,
+// the lookup table is kept.
+func (r *Router) DropLookupIndex(db, table, name string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	conf, err := r.lookupTableConfig(db, table)
+	if err != nil {
+		return err
+	}
+	newConf := *conf
+	newConf.LookupIndexes = nil
+	for _, index := range conf.LookupIndexes {
+		if index.Name != name {
+			newConf.LookupIndexes = append(newConf.LookupIndexes, index)
+		}
+	}
+	if len(newConf.LookupIndexes) == len(conf.LookupIndexes) {
+		return errors.Errorf("router.table[%s].lookup.index[%s].not.found", table, name)
+	}
+	return r.replaceTable(db, &newConf)
+}
+
+// LookupIndexOwner returns the table which the lookup table belongs to.
+func (r *Router) LookupIndexOwner(db, table string) (string, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.lookupIndexOwner(db, table)
+}
+
+func (r *Router) lookupIndexOwner(db, table string) (string, bool) {
+	schema, ok := r.Schemas[db]
+	if !ok {
+		return "", false
+	}
+	for name, tbl := range schema.Tables {
+		for _, index := range tbl.TableConfig.LookupIndexes {
+			if index.Name == table {
+				return name, true
+			}
+		}
+	}
+	return "", false
+}
+
+func (r *Router) lookupTableConfig(db, table string) (*config.TableConfig, error) {
+	schema, ok := r.Schemas[db]
+	if !ok {
+		return nil, errors.Errorf("router.can.not.find.db[%v]", db)
+	}
+	tbl, ok := schema.Tables[table]
+	if !ok {
+		return nil, errors.Errorf("router.can.not.find.table[%v]", table)
+	}
+	return tbl.TableConfig, nil
+}
+
+// LookupIndexKeys returns the shard key values which the column values are mapped to by the rows
+// read from the lookup table, the rows are 'column, shard key'. The complete is false if some value
+// is not found in the lookup table(such as the rows written by an uncommitted transaction), the
+// caller should not prune the route.
+func LookupIndexKeys(vals []*sqlparser.SQLVal, rows [][]sqltypes.Value) ([]*sqlparser.SQLVal, bool) {
+	missing := make(map[string]bool)
+	for _, val := range vals {
+		missing[lookupValueKey(val.Type == sqlparser.StrVal, val.Val)] = true
+	}
+
+	var keys []*sqlparser.SQLVal
+	seen := make(map[string]bool)
+	for _, row := range rows {
+		if len(row) < 2 || row[1].IsNull() {
+			continue
+		}
+		delete(missing, lookupValueKey(!row[0].IsIntegral() && !row[0].IsFloat(), row[0].Raw()))
+		key := SQLValFromValue(row[1])
+		id := fmt.Sprintf("%d:%s", key.Type, key.Val)
+		if !seen[id] {
+			seen[id] = true
+			keys = append(keys, key)
+		}
+	}
+	return keys, len(missing) == 0
+}
+
+// lookupValueKey normalizes the value to match the column values returned by the lookup table,
+// the strings are compared case-insensitively.
+func lookupValueKey(isString bool, val []byte) string {
+	if isString {
+		return strings.ToLower(string(val))
+	}
+	return listKey(string(val))
+}
+
+// SQLValFromValue converts the value of the result to the SQLVal.
+func SQLValFromValue(v sqltypes.Value) *sqlparser.SQLVal {
+	switch {
+	case v.IsIntegral():
+		return sqlparser.NewIntVal(v.Raw())
+	case v.IsFloat() || v.Type() == querypb.Type_DECIMAL:
+		return sqlparser.NewFloatVal(v.Raw())
+	}
+	return sqlparser.NewStrVal(v.Raw())
+}
@@ -0,0 +1,169 @@
+/*
+ * NeoDB
+ *
+ * Copyright 2021-2030 The NeoDB Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package router
+
+import (
+	"testing"
+
+	"github.com/sealdb/neodb/config"
+
+	"github.com/sealdb/mysqlstack/sqlparser"
+	querypb "github.com/sealdb/mysqlstack/sqlparser/depends/query"
+	"github.com/sealdb/mysqlstack/sqlparser/depends/sqltypes"
+	"github.com/sealdb/mysqlstack/xlog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLookupIndex(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	router, cleanup := MockNewRouter(log)
+	defer cleanup()
+
+	backends := []string{"backend1", "backend2"}
+	err := router.CreateDatabase("test")
+	assert.Nil(t, err)
+	err = router.CreateHashTable("test", "t1", "id", TableTypePartitionHash, backends, nil, nil)
+	assert.Nil(t, err)
+	err = router.CreateHashTable("test", "t1_name", "name", TableTypePartitionHash, backends, nil, nil)
+	assert.Nil(t, err)
+	err = router.CreateHashTable("test", "t1_age", "id", TableTypePartitionHash, backends, nil, nil)
+	assert.Nil(t, err)
+
+	// Add.
+	{
+		err := router.AddLookupIndex("test", "t1", &config.LookupIndexConfig{Name: "t1_name", Column: "name", Building: true})
+		assert.Nil(t, err)
+		conf, err := router.TableConfig("test", "t1")
+		assert.Nil(t, err)
+		assert.Equal(t, []*config.LookupIndexConfig{{Name: "t1_name", Column: "name", Building: true}}, conf.LookupIndexes)
+
+		owner, ok := router.LookupIndexOwner("test", "t1_name")
+		assert.True(t, ok)
+		assert.Equal(t, "t1", owner)
+		_, ok = router.LookupIndexOwner("test", "t1")
+		assert.False(t, ok)
+	}
+
+	// Finish.
+	{
+		err := router.FinishLookupIndex("test", "t1", "t1_name")
+		assert.Nil(t, err)
+		conf, err := router.TableConfig("test", "t1")
+		assert.Nil(t, err)
+		assert.False(t, conf.LookupIndexes[0].Building)
+	}
+
+	// Reload from the frm.
+	{
+		router1, cleanup1 := MockNewRouter(log)
+		defer cleanup1()
+		err := router1.LoadConfig()
+		assert.Nil(t, err)
+		conf, err := router1.TableConfig("test", "t1")
+		assert.Nil(t, err)
+		assert.Equal(t, []*config.LookupIndexConfig{{Name: "t1_name", Column: "name"}}, conf.LookupIndexes)
+	}
+
+	// Errors.
+	{
+		indexes := []struct {
+			table string
+			index *config.LookupIndexConfig
+			err   string
+		}{
+			{"t2", &config.LookupIndexConfig{Name: "t1_name", Column: "name"}, "router.can.not.find.table[t2]"},
+			{"t1", &config.LookupIndexConfig{Name: "t1_xx", Column: "name"}, "router.lookup.index[t1_xx].table.doesn't.exist"},
+			{"t1", &config.LookupIndexConfig{Name: "t1_age", Column: "age"}, "router.lookup.index[t1_age].table.must.be.hash.by[age]"},
+			{"t1_age", &config.LookupIndexConfig{Name: "t1_name", Column: "name"}, "router.lookup.index[t1_name].already.belongs.to.table[t1]"},
+			{"t1_age", &config.LookupIndexConfig{Name: "t1", Column: "id"}, "router.table[t1_age].lookup.index[t1].column[id].is.the.shard.key"},
+			{"t1_age", &config.LookupIndexConfig{Name: "t1_age", Column: "id"}, "router.table[t1_age].lookup.index[t1_age].can't.be.the.table.itself"},
+		}
+		for _, index := range indexes {
+			err := router.AddLookupIndex("test", index.table, index.index)
+			assert.EqualError(t, err, index.err)
+		}
+
+		err := router.FinishLookupIndex("test", "t1", "t1_xx")
+		assert.EqualError(t, err, "router.table[t1].lookup.index[t1_xx].not.found")
+		err = router.DropLookupIndex("test", "t1", "t1_xx")
+		assert.EqualError(t, err, "router.table[t1].lookup.index[t1_xx].not.found")
+	}
+
+	// Drop.
+	{
+		err := router.DropLookupIndex("test", "t1", "t1_name")
+		assert.Nil(t, err)
+		conf, err := router.TableConfig("test", "t1")
+		assert.Nil(t, err)
+		assert.Nil(t, conf.LookupIndexes)
+		_, ok := router.LookupIndexOwner("test", "t1_name")
+		assert.False(t, ok)
+	}
+}
+
+func TestCheckLookupIndexes(t *testing.T) {
+	tests := []struct {
+		conf *config.TableConfig
+		err  string
+	}{
+		{&config.TableConfig{Name: "t", ShardType: "GLOBAL", LookupIndexes: []*config.LookupIndexConfig{{Name: "i", Column: "a"}}}, "router.table[t].shardtype[GLOBAL].can't.have.lookup.index"},
+		{&config.TableConfig{Name: "t", ShardType: "HASH", ShardKey: "a,b", LookupIndexes: []*config.LookupIndexConfig{{Name: "i", Column: "c"}}}, "router.table[t].composite.shard.key.can't.have.lookup.index"},
+		{&config.TableConfig{Name: "t", ShardType: "HASH", ShardKey: "id", LookupIndexes: []*config.LookupIndexConfig{{Name: "", Column: "a"}}}, "router.table[t].lookup.index.name.and.column.can't.be.empty"},
+		{&config.TableConfig{Name: "t", ShardType: "HASH", ShardKey: "id", LookupIndexes: []*config.LookupIndexConfig{{Name: "t", Column: "a"}}}, "router.table[t].lookup.index[t].can't.be.the.table.itself"},
+		{&config.TableConfig{Name: "t", ShardType: "HASH", ShardKey: "id", LookupIndexes: []*config.LookupIndexConfig{{Name: "i", Column: "ID"}}}, "router.table[t].lookup.index[i].column[ID].is.the.shard.key"},
+		{&config.TableConfig{Name: "t", ShardType: "HASH", ShardKey: "id", LookupIndexes: []*config.LookupIndexConfig{{Name: "i", Column: "a"}, {Name: "i", Column: "b"}}}, "router.table[t].lookup.index[i].is.duplicate"},
+		{&config.TableConfig{Name: "t", ShardType: "LIST", ShardKey: "id", LookupIndexes: []*config.LookupIndexConfig{{Name: "i", Column: "a"}, {Name: "j", Column: "b"}}}, ""},
+	}
+	for _, test := range tests {
+		err := checkLookupIndexes(test.conf)
+		if test.err == "" {
+			assert.Nil(t, err)
+		} else {
+			assert.EqualError(t, err, test.err)
+		}
+	}
+}
+
+func TestLookupIndexKeys(t *testing.T) {
+	vals := []*sqlparser.SQLVal{sqlparser.NewStrVal([]byte("a")), sqlparser.NewStrVal([]byte("B"))}
+	row := func(name, id string) []sqltypes.Value {
+		return []sqltypes.Value{sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(name)), sqltypes.MakeTrusted(querypb.Type_INT64, []byte(id))}
+	}
+	// The strings are matched case-insensitively.
+	rows := [][]sqltypes.Value{row("A", "1"), row("a", "2"), row("b", "1")}
+
+	// All found.
+	{
+		keys, complete := LookupIndexKeys(vals, rows)
+		assert.True(t, complete)
+		assert.Equal(t, []*sqlparser.SQLVal{sqlparser.NewIntVal([]byte("1")), sqlparser.NewIntVal([]byte("2"))}, keys)
+	}
+
+	// Some value is missing.
+	{
+		keys, complete := LookupIndexKeys(append(vals, sqlparser.NewStrVal([]byte("c"))), rows)
+		assert.False(t, complete)
+		assert.Equal(t, 2, len(keys))
+	}
+
+	// Nothing is read.
+	{
+		keys, complete := LookupIndexKeys(vals, nil)
+		assert.False(t, complete)
+		assert.Nil(t, keys)
+	}
+
+	// SQLValFromValue.
+	{
+		assert.Equal(t, sqlparser.NewIntVal([]byte("-1")), SQLValFromValue(sqltypes.MakeTrusted(querypb.Type_INT32, []byte("-1"))))
+		assert.Equal(t, sqlparser.NewFloatVal([]byte("1.5")), SQLValFromValue(sqltypes.MakeTrusted(querypb.Type_DECIMAL, []byte("1.5"))))
+		assert.Equal(t, sqlparser.NewFloatVal([]byte("2.5")), SQLValFromValue(sqltypes.MakeTrusted(querypb.Type_FLOAT64, []byte("2.5"))))
+		assert.Equal(t, sqlparser.NewStrVal([]byte("x")), SQLValFromValue(sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("x"))))
+	}
+}
@@ -454,6 +454,33 @@ func MockTableList1Config() *config.TableConfig {
 	return mock
 }
 
+// MockTableRangeConfig config, range shardtype.
+func MockTableRangeConfig() *config.TableConfig {
+	mock := &config.TableConfig{
+		Name:       "RG",
+		ShardType:  "RANGE",
+		ShardKey:   "id",
+		Partitions: make([]*config.PartitionConfig, 0, 16),
+	}
+	RG100 := &config.PartitionConfig{
+		Table:    "RG_0000",
+		Backend:  "backend1",
+		LessThan: "100",
+	}
+	RG200 := &config.PartitionConfig{
+		Table:    "RG_0001",
+		Backend:  "backend2",
+		LessThan: "200",
+	}
+	RGMax := &config.PartitionConfig{
+		Table:    "RG_0002",
+		Backend:  "backend3",
+		LessThan: "MAXVALUE",
+	}
+	mock.Partitions = append(mock.Partitions, RG100, RG200, RGMax)
+	return mock
+}
+
 // MockTableRConfig config.
 func MockTableRConfig() *config.TableConfig {
 	mock := &config.TableConfig{
@@ -477,6 +504,52 @@ func MockTableRConfig() *config.TableConfig {
 	return mock
 }
 
+// MockTableCKConfig config, composite shard key.
+func MockTableCKConfig() *config.TableConfig {
+	mock := &config.TableConfig{
+		Name:       "CK",
+		ShardType:  "HASH",
+		ShardKey:   "tenant_id,user_id",
+		Partitions: make([]*config.PartitionConfig, 0, 16),
+	}
+	S02048 := &config.PartitionConfig{
+		Table:   "CK_0000",
+		Segment: "0-2048",
+		Backend: "backend1",
+	}
+	S20484096 := &config.PartitionConfig{
+		Table:   "CK_0001",
+		Segment: "2048-4096",
+		Backend: "backend2",
+	}
+
+	mock.Partitions = append(mock.Partitions, S02048, S20484096)
+	return mock
+}
+
+// MockTableCK1Config config, composite shard key, same shards as CK.
+func MockTableCK1Config() *config.TableConfig {
+	mock := &config.TableConfig{
+		Name:       "CK1",
+		ShardType:  "HASH",
+		ShardKey:   "tenant_id,user_id",
+		Partitions: make([]*config.PartitionConfig, 0, 16),
+	}
+	S02048 := &config.PartitionConfig{
+		Table:   "CK1_0000",
+		Segment: "0-2048",
+		Backend: "backend1",
+	}
+	S20484096 := &config.PartitionConfig{
+		Table:   "CK1_0001",
+		Segment: "2048-4096",
+		Backend: "backend2",
+	}
+
+	mock.Partitions = append(mock.Partitions, S02048, S20484096)
+	return mock
+}
+
 // mockTmpDir is only used for MockNewRouter()
 var (
 	log        = xlog.NewStdLog(xlog.Level(xlog.PANIC))
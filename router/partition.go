@@ -42,8 +42,10 @@ type Segment struct {
 	// key range of this segment.
 	Range KeyRange `json:",omitempty"`
 
-	// partition list value.
-	ListValue string `json:",omitempty"`
+	// partition list values.
+	ListValues []string `json:",omitempty"`
+	// ListDefault is true if the segment is the DEFAULT list partition.
+	ListDefault bool `json:",omitempty"`
 }
 
 // Partition interface.
@@ -0,0 +1,253 @@
+/*
+ * NeoDB
+ *
+ * Copyright 2021-2030 The NeoDB Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package router
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+
+	"github.com/sealdb/neodb/config"
+
+	"github.com/pkg/errors"
+	"github.com/sealdb/mysqlstack/sqlparser"
+	"github.com/sealdb/mysqlstack/sqlparser/depends/common"
+	"github.com/sealdb/mysqlstack/xlog"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	// MinValue is the lower bound of the first range partition.
+	MinValue = "MINVALUE"
+	// MaxValue is the upper bound of the last range partition.
+	MaxValue = "MAXVALUE"
+)
+
+// RangeRange tuple.
+// [Start, End)
+type RangeRange struct {
+	Start string
+	End   string
+
+	// the position in the segments.
+	index int
+}
+
+// String returns start-end info.
+func (r *RangeRange) String() string {
+	return fmt.Sprintf("[%v-%v)", r.Start, r.End)
+}
+
+// Less impl.
+func (r *RangeRange) Less(b KeyRange) bool {
+	v := b.(*RangeRange)
+	return r.index < v.index
+}
+
+// Range tuple.
+type Range struct {
+	log *xlog.Log
+
+	// method
+	typ MethodType
+
+	// table config
+	conf *config.TableConfig
+
+	// numeric is true if all the bounds are numbers, the keys are compared
+	// as decimals, otherwise as strings.
+	numeric bool
+
+	// the exclusive upper bound of each segment.
+	numBounds []decimal.Decimal
+	strBounds []string
+
+	// whether the last segment is unbounded.
+	maxValue bool
+
+	Segments []Segment `json:",omitempty"`
+}
+
+// NewRange creates new range.
+func NewRange(log *xlog.Log, conf *config.TableConfig) *Range {
+	return &Range{
+		log:      log,
+		conf:     conf,
+		typ:      MethodTypeRange,
+		Segments: make([]Segment, 0, 16),
+	}
+}
+
+// Build used to build range segments from schema config.
+// The partitions must be ordered by the bound, only the last one can be MAXVALUE.
+func (r *Range) Build() error {
+	parts := r.conf.Partitions
+	if len(parts) == 0 {
+		return errors.New("range.partition.can't.be.empty")
+	}
+
+	numerics := 0
+	for i, part := range parts {
+		if part.LessThan == MaxValue {
+			if i != len(parts)-1 {
+				return errors.Errorf("range.partition[%v].MAXVALUE.can.only.be.used.in.last.partition", part.Table)
+			}
+			r.maxValue = true
+			continue
+		}
+		if _, err := decimal.NewFromString(part.LessThan); err == nil {
+			numerics++
+		}
+	}
+	bounds := len(parts)
+	if r.maxValue {
+		bounds--
+	}
+	// The numeric and string bounds can't be compared with each other.
+	if numerics != 0 && numerics != bounds {
+		return errors.Errorf("range.partition.bounds.can't.mix.numbers.and.strings")
+	}
+	r.numeric = numerics != 0
+
+	start := MinValue
+	for i, part := range parts {
+		if part.LessThan == "" {
+			return errors.Errorf("range.partition[%v].bound.can't.be.empty", part.Table)
+		}
+		if part.LessThan != MaxValue {
+			if r.numeric {
+				bound, _ := decimal.NewFromString(part.LessThan)
+				if i > 0 && bound.LessThanOrEqual(r.numBounds[i-1]) {
+					return errors.Errorf("range.partition[%v].values.less.than.must.be.strictly.increasing", part.Table)
+				}
+				r.numBounds = append(r.numBounds, bound)
+			} else {
+				if i > 0 && part.LessThan <= r.strBounds[i-1] {
+					return errors.Errorf("range.partition[%v].values.less.than.must.be.strictly.increasing", part.Table)
+				}
+				r.strBounds = append(r.strBounds, part.LessThan)
+			}
+		}
+
+		partition := Segment{
+			Table:   part.Table,
+			Backend: part.Backend,
+			Range: &RangeRange{
+				Start: start,
+				End:   part.LessThan,
+				index: i,
+			},
+		}
+		r.Segments = append(r.Segments, partition)
+		start = part.LessThan
+	}
+	return nil
+}
+
+// Clear used to clean partitions.
+func (r *Range) Clear() error {
+	return nil
+}
+
+// search returns the index of the segment which contains the sqlval,
+// returns len(Segments) if the sqlval is beyond the last bound.
+func (r *Range) search(sqlval *sqlparser.SQLVal) (int, error) {
+	valStr := common.BytesToString(sqlval.Val)
+	if r.numeric {
+		switch sqlval.Type {
+		case sqlparser.IntVal, sqlparser.FloatVal, sqlparser.StrVal:
+		default:
+			return -1, errors.Errorf("range.unsupported.key.type:[%v]", sqlval.Type)
+		}
+		key, err := decimal.NewFromString(valStr)
+		if err != nil {
+			return -1, errors.Errorf("range.getindex.val.key.parser.decimal.error:[%v]", err)
+		}
+		return sort.Search(len(r.numBounds), func(i int) bool {
+			return key.LessThan(r.numBounds[i])
+		}), nil
+	}
+
+	switch sqlval.Type {
+	case sqlparser.IntVal, sqlparser.FloatVal, sqlparser.StrVal:
+	default:
+		return -1, errors.Errorf("range.unsupported.key.type:[%v]", sqlval.Type)
+	}
+	return sort.Search(len(r.strBounds), func(i int) bool {
+		return valStr < r.strBounds[i]
+	}), nil
+}
+
+// Lookup used to lookup partition(s) through the sharding-key range [start, end],
+// the nil start(or end) means the range is unbounded below(or above).
+func (r *Range) Lookup(start *sqlparser.SQLVal, end *sqlparser.SQLVal) ([]Segment, error) {
+	// if open interval we returns all partitions.
+	if start == nil && end == nil {
+		return r.Segments, nil
+	}
+
+	var err error
+	lo, hi := 0, len(r.Segments)-1
+	if start != nil {
+		if lo, err = r.search(start); err != nil {
+			return nil, err
+		}
+		if end != nil && bytes.Equal(start.Val, end.Val) && lo == len(r.Segments) {
+			return nil, errors.Errorf("Table has no partition for value %v", common.BytesToString(start.Val))
+		}
+	}
+	if end != nil {
+		if hi, err = r.search(end); err != nil {
+			return nil, err
+		}
+	}
+
+	// The range beyond the bounds or empty matches no rows, but the query
+	// still needs a route, we keep the nearest segment.
+	if lo > len(r.Segments)-1 {
+		lo = len(r.Segments) - 1
+	}
+	if hi > len(r.Segments)-1 {
+		hi = len(r.Segments) - 1
+	}
+	if hi < lo {
+		hi = lo
+	}
+	return r.Segments[lo : hi+1], nil
+}
+
+// Type returns the range type.
+func (r *Range) Type() MethodType {
+	return r.typ
+}
+
+// GetIndex returns index based on sqlval.
+func (r *Range) GetIndex(sqlval *sqlparser.SQLVal) (int, error) {
+	idx, err := r.search(sqlval)
+	if err != nil {
+		return -1, err
+	}
+	if idx == len(r.Segments) {
+		return -1, errors.Errorf("Table has no partition for value %v", common.BytesToString(sqlval.Val))
+	}
+	return idx, nil
+}
+
+// GetSegments returns Segments based on index.
+func (r *Range) GetSegments() []Segment {
+	return r.Segments
+}
+
+// GetSegment returns Segment based on index.
+func (r *Range) GetSegment(index int) (Segment, error) {
+	if index < 0 || index >= len(r.Segments) {
+		return Segment{}, errors.Errorf("range.getsegment.index.[%d].out.of.range", index)
+	}
+	return r.Segments[index], nil
+}
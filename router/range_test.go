@@ -0,0 +1,216 @@
+/*
+ * NeoDB
+ *
+ * Copyright 2021-2030 The NeoDB Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package router
+
+import (
+	"testing"
+
+	"github.com/sealdb/neodb/config"
+
+	"github.com/sealdb/mysqlstack/sqlparser"
+	"github.com/sealdb/mysqlstack/xlog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRange(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	rang := NewRange(log, MockTableRangeConfig())
+	{
+		err := rang.Build()
+		assert.Nil(t, err)
+		assert.Equal(t, string(rang.Type()), MethodTypeRange)
+		assert.Equal(t, 3, len(rang.GetSegments()))
+		assert.Equal(t, "[MINVALUE-100)", rang.Segments[0].Range.String())
+		assert.Equal(t, "[200-MAXVALUE)", rang.Segments[2].Range.String())
+	}
+
+	{
+		seg, err := rang.GetSegment(1)
+		assert.Nil(t, err)
+		assert.Equal(t, "RG_0001", seg.Table)
+
+		_, err = rang.GetSegment(3)
+		assert.NotNil(t, err)
+	}
+
+	{
+		err := rang.Clear()
+		assert.Nil(t, err)
+	}
+}
+
+func TestRangeBuildError(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	confs := []*config.TableConfig{
+		// Empty partitions.
+		&config.TableConfig{Name: "r", ShardType: "RANGE", ShardKey: "id"},
+		// MAXVALUE is not the last.
+		&config.TableConfig{Name: "r", ShardType: "RANGE", ShardKey: "id", Partitions: []*config.PartitionConfig{
+			&config.PartitionConfig{Table: "r_0000", Backend: "backend1", LessThan: "MAXVALUE"},
+			&config.PartitionConfig{Table: "r_0001", Backend: "backend1", LessThan: "100"},
+		}},
+		// Not increasing.
+		&config.TableConfig{Name: "r", ShardType: "RANGE", ShardKey: "id", Partitions: []*config.PartitionConfig{
+			&config.PartitionConfig{Table: "r_0000", Backend: "backend1", LessThan: "100"},
+			&config.PartitionConfig{Table: "r_0001", Backend: "backend1", LessThan: "100"},
+		}},
+		&config.TableConfig{Name: "r", ShardType: "RANGE", ShardKey: "id", Partitions: []*config.PartitionConfig{
+			&config.PartitionConfig{Table: "r_0000", Backend: "backend1", LessThan: "b"},
+			&config.PartitionConfig{Table: "r_0001", Backend: "backend1", LessThan: "a"},
+		}},
+		// Empty bound.
+		&config.TableConfig{Name: "r", ShardType: "RANGE", ShardKey: "id", Partitions: []*config.PartitionConfig{
+			&config.PartitionConfig{Table: "r_0000", Backend: "backend1"},
+		}},
+		// Mixed numeric and string bounds.
+		&config.TableConfig{Name: "r", ShardType: "RANGE", ShardKey: "id", Partitions: []*config.PartitionConfig{
+			&config.PartitionConfig{Table: "r_0000", Backend: "backend1", LessThan: "100"},
+			&config.PartitionConfig{Table: "r_0001", Backend: "backend1", LessThan: "abc"},
+		}},
+	}
+	for _, conf := range confs {
+		rang := NewRange(log, conf)
+		err := rang.Build()
+		assert.NotNil(t, err)
+	}
+}
+
+func TestRangeLookup(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	rang := NewRange(log, MockTableRangeConfig())
+	err := rang.Build()
+	assert.Nil(t, err)
+
+	tests := []struct {
+		start  *sqlparser.SQLVal
+		end    *sqlparser.SQLVal
+		tables []string
+	}{
+		// [nil, nil]
+		{nil, nil, []string{"RG_0000", "RG_0001", "RG_0002"}},
+		// = 99
+		{sqlparser.NewIntVal([]byte("99")), sqlparser.NewIntVal([]byte("99")), []string{"RG_0000"}},
+		// = 100
+		{sqlparser.NewIntVal([]byte("100")), sqlparser.NewIntVal([]byte("100")), []string{"RG_0001"}},
+		// = '150'
+		{sqlparser.NewStrVal([]byte("150")), sqlparser.NewStrVal([]byte("150")), []string{"RG_0001"}},
+		// = 199.5
+		{sqlparser.NewFloatVal([]byte("199.5")), sqlparser.NewFloatVal([]byte("199.5")), []string{"RG_0001"}},
+		// = -1
+		{sqlparser.NewIntVal([]byte("-1")), sqlparser.NewIntVal([]byte("-1")), []string{"RG_0000"}},
+		// between 50 and 150
+		{sqlparser.NewIntVal([]byte("50")), sqlparser.NewIntVal([]byte("150")), []string{"RG_0000", "RG_0001"}},
+		// >= 150
+		{sqlparser.NewIntVal([]byte("150")), nil, []string{"RG_0001", "RG_0002"}},
+		// <= 99
+		{nil, sqlparser.NewIntVal([]byte("99")), []string{"RG_0000"}},
+		// between 300 and 100, empty range.
+		{sqlparser.NewIntVal([]byte("300")), sqlparser.NewIntVal([]byte("100")), []string{"RG_0002"}},
+	}
+	for _, test := range tests {
+		parts, err := rang.Lookup(test.start, test.end)
+		assert.Nil(t, err)
+		var tables []string
+		for _, part := range parts {
+			tables = append(tables, part.Table)
+		}
+		assert.Equal(t, test.tables, tables)
+	}
+
+	// Errors.
+	{
+		_, err := rang.Lookup(sqlparser.NewStrVal([]byte("abc")), nil)
+		assert.NotNil(t, err)
+
+		_, err = rang.Lookup(nil, sqlparser.NewHexNum([]byte("0x12")))
+		assert.NotNil(t, err)
+	}
+}
+
+func TestRangeGetIndex(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	conf := &config.TableConfig{
+		Name:      "R",
+		ShardType: "RANGE",
+		ShardKey:  "ts",
+		Partitions: []*config.PartitionConfig{
+			&config.PartitionConfig{Table: "R_0000", Backend: "backend1", LessThan: "2023-01-01"},
+			&config.PartitionConfig{Table: "R_0001", Backend: "backend2", LessThan: "2023-02-01"},
+		},
+	}
+	rang := NewRange(log, conf)
+	err := rang.Build()
+	assert.Nil(t, err)
+
+	{
+		idx, err := rang.GetIndex(sqlparser.NewStrVal([]byte("2022-12-31 23:59:59")))
+		assert.Nil(t, err)
+		assert.Equal(t, 0, idx)
+
+		idx, err = rang.GetIndex(sqlparser.NewStrVal([]byte("2023-01-15 10:00:00")))
+		assert.Nil(t, err)
+		assert.Equal(t, 1, idx)
+	}
+
+	// No MAXVALUE partition.
+	{
+		_, err := rang.GetIndex(sqlparser.NewStrVal([]byte("2023-02-01")))
+		assert.EqualError(t, err, "Table has no partition for value 2023-02-01")
+
+		_, err = rang.Lookup(sqlparser.NewStrVal([]byte("2023-02-01")), sqlparser.NewStrVal([]byte("2023-02-01")))
+		assert.NotNil(t, err)
+
+		parts, err := rang.Lookup(sqlparser.NewStrVal([]byte("2023-01-15")), nil)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(parts))
+		assert.Equal(t, "R_0001", parts[0].Table)
+	}
+}
+
+func TestRangeDecimalBounds(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	conf := &config.TableConfig{
+		Name:      "R",
+		ShardType: "RANGE",
+		ShardKey:  "price",
+		Partitions: []*config.PartitionConfig{
+			&config.PartitionConfig{Table: "R_0000", Backend: "backend1", LessThan: "1.5"},
+			&config.PartitionConfig{Table: "R_0001", Backend: "backend2", LessThan: "100.5"},
+			&config.PartitionConfig{Table: "R_0002", Backend: "backend2", LessThan: "MAXVALUE"},
+		},
+	}
+	rang := NewRange(log, conf)
+	err := rang.Build()
+	assert.Nil(t, err)
+
+	tests := []struct {
+		val *sqlparser.SQLVal
+		idx int
+	}{
+		{sqlparser.NewIntVal([]byte("1")), 0},
+		{sqlparser.NewFloatVal([]byte("1.49")), 0},
+		{sqlparser.NewFloatVal([]byte("1.5")), 1},
+		{sqlparser.NewIntVal([]byte("9")), 1},
+		{sqlparser.NewIntVal([]byte("20")), 1},
+		{sqlparser.NewStrVal([]byte("100.4")), 1},
+		{sqlparser.NewIntVal([]byte("100")), 1},
+		{sqlparser.NewFloatVal([]byte("100.5")), 2},
+		{sqlparser.NewIntVal([]byte("1000")), 2},
+	}
+	for _, test := range tests {
+		idx, err := rang.GetIndex(test.val)
+		assert.Nil(t, err)
+		assert.Equal(t, test.idx, idx, string(test.val.Val))
+	}
+
+	parts, err := rang.Lookup(sqlparser.NewIntVal([]byte("9")), sqlparser.NewIntVal([]byte("20")))
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(parts))
+	assert.Equal(t, "R_0001", parts[0].Table)
+}
@@ -10,6 +10,7 @@
 package router
 
 import (
+	"bytes"
 	"encoding/json"
 	"strings"
 	"sync"
@@ -25,6 +26,8 @@ import (
 // Extra -- router extra params.
 type Extra struct {
 	AutoIncrement *config.AutoIncrement
+	// HashFunction is the hash function of the hash table, empty means the default.
+	HashFunction string
 }
 
 // Table tuple.
@@ -57,6 +60,10 @@ type Router struct {
 
 	// schemas map, key is database name
 	Schemas map[string]*Schema `json:",omitempty"`
+
+	// colocateShifts are the partition tables of the colocate groups shifted together,
+	// key is database.partitionTable.
+	colocateShifts map[string]bool
 }
 
 // NewRouter creates the new router.
@@ -82,6 +89,12 @@ func (r *Router) addTable(db string, tbl *config.TableConfig) error {
 	if tbl == nil {
 		return errors.New("table.config..can't.be.nil")
 	}
+	if len(SplitShardKey(tbl.ShardKey)) > 1 && tbl.ShardType != MethodTypeHash {
+		return errors.Errorf("router.add.table[%v].composite.shard.key.only.supported.by.hash", tbl.Name)
+	}
+	if err := checkLookupIndexes(tbl); err != nil {
+		return err
+	}
 
 	// get schema
 	schema, _ := r.Schemas[db]
@@ -128,6 +141,18 @@ func (r *Router) addTable(db string, tbl *config.TableConfig) error {
 			return err
 		}
 		table.Partition = list
+	case MethodTypeRange:
+		rang := NewRange(r.log, tbl)
+		if err := rang.Build(); err != nil {
+			return err
+		}
+		table.Partition = rang
+	case MethodTypeInterval:
+		interval := NewInterval(r.log, tbl)
+		if err := interval.Build(); err != nil {
+			return err
+		}
+		table.Partition = interval
 	default:
 		return errors.Errorf("router.unsupport.shardtype:[%v]", tbl.ShardType)
 	}
@@ -357,6 +382,83 @@ func (r *Router) GetIndex(database, tableName string, sqlval *sqlparser.SQLVal)
 	return index, nil
 }
 
+// RouteValues returns the partition index and the segment which the shard key values route to,
+// the values of the composite shard key are in the order of the columns.
+func (r *Router) RouteValues(database, tableName string, vals []*sqlparser.SQLVal) (int, Segment, error) {
+	var segment Segment
+	table, err := r.getTable(database, tableName)
+	if err != nil {
+		return -1, segment, err
+	}
+
+	keys := SplitShardKey(table.ShardKey)
+	if len(keys) == 0 {
+		return -1, segment, errors.Errorf("router.table[%s].has.no.shard.key", tableName)
+	}
+	if len(keys) != len(vals) {
+		return -1, segment, errors.Errorf("router.table[%s].shard.key[%s].values.count[%d].mismatch", tableName, table.ShardKey, len(vals))
+	}
+
+	key := vals[0]
+	if len(vals) > 1 {
+		if key, err = TupleKey(vals); err != nil {
+			return -1, segment, err
+		}
+	}
+	index, err := table.Partition.GetIndex(key)
+	if err != nil {
+		return -1, segment, err
+	}
+	if segment, err = table.Partition.GetSegment(index); err != nil {
+		return -1, segment, err
+	}
+	return index, segment, nil
+}
+
+// LookupIndexes returns the indexes of the partition(s) through the sharding-key range [startKey, endKey],
+// the nil indexes means all the partitions.
+func (r *Router) LookupIndexes(database, tableName string, startKey, endKey *sqlparser.SQLVal) ([]int, error) {
+	table, err := r.getTable(database, tableName)
+	if err != nil {
+		return nil, err
+	}
+
+	if startKey != nil && endKey != nil && bytes.Equal(startKey.Val, endKey.Val) {
+		index, err := table.Partition.GetIndex(startKey)
+		if err != nil {
+			r.log.Error("router.partition.getindex.error:%+v", err)
+			return nil, err
+		}
+		return []int{index}, nil
+	}
+
+	// Hash can't prune by the range, the index is the slot.
+	if table.Partition.Type() == MethodTypeHash {
+		return nil, nil
+	}
+
+	parts, err := table.Partition.Lookup(startKey, endKey)
+	if err != nil {
+		r.log.Error("router.partition.lookup.error:%+v", err)
+		return nil, err
+	}
+	segments := table.Partition.GetSegments()
+	if len(parts) == len(segments) {
+		return nil, nil
+	}
+
+	var indexes []int
+	for _, part := range parts {
+		for i := range segments {
+			if segments[i].Range == part.Range {
+				indexes = append(indexes, i)
+				break
+			}
+		}
+	}
+	return indexes, nil
+}
+
 // GetSegments returns Segments based on indexes.
 func (r *Router) GetSegments(database, tableName string, indexes []int) ([]Segment, error) {
 	table, err := r.getTable(database, tableName)
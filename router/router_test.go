@@ -598,6 +598,54 @@ func TestRouterGetSegments(t *testing.T) {
 	}
 }
 
+func TestRouterLookupIndexes(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	router, cleanup := MockNewRouter(log)
+	defer cleanup()
+	assert.NotNil(t, router)
+	err := router.CreateDatabase("sbtest")
+	assert.Nil(t, err)
+	err = router.AddForTest("sbtest", MockTableMConfig(), MockTableListConfig(), MockTableRangeConfig())
+	assert.Nil(t, err)
+
+	tests := []struct {
+		table   string
+		start   *sqlparser.SQLVal
+		end     *sqlparser.SQLVal
+		indexes []int
+	}{
+		// hash equal.
+		{"A", sqlparser.NewIntVal([]byte("1")), sqlparser.NewIntVal([]byte("1")), []int{2323}},
+		// hash range.
+		{"A", sqlparser.NewIntVal([]byte("1")), sqlparser.NewIntVal([]byte("10")), nil},
+		// list range.
+		{"L", sqlparser.NewIntVal([]byte("2")), nil, []int{1, 2}},
+		{"L", nil, sqlparser.NewIntVal([]byte("5")), []int{0, 1}},
+		{"L", nil, sqlparser.NewIntVal([]byte("10")), nil},
+		// range.
+		{"RG", sqlparser.NewIntVal([]byte("100")), sqlparser.NewIntVal([]byte("100")), []int{1}},
+		{"RG", sqlparser.NewIntVal([]byte("150")), nil, []int{1, 2}},
+		{"RG", nil, sqlparser.NewIntVal([]byte("99")), []int{0}},
+	}
+	for _, test := range tests {
+		indexes, err := router.LookupIndexes("sbtest", test.table, test.start, test.end)
+		assert.Nil(t, err)
+		assert.Equal(t, test.indexes, indexes)
+	}
+
+	// Errors.
+	{
+		_, err := router.LookupIndexes("sbtest", "xx", nil, nil)
+		assert.NotNil(t, err)
+
+		_, err = router.LookupIndexes("sbtest", "L", sqlparser.NewIntVal([]byte("2")), sqlparser.NewIntVal([]byte("2")))
+		assert.NotNil(t, err)
+
+		_, err = router.LookupIndexes("sbtest", "RG", sqlparser.NewHexNum([]byte("0x12")), nil)
+		assert.NotNil(t, err)
+	}
+}
+
 func TestRouterGetSegmentsError(t *testing.T) {
 	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
 	router, cleanup := MockNewRouter(log)
@@ -709,3 +757,86 @@ func TestRouterIsPartitionHash(t *testing.T) {
 	isHash := router.IsPartitionHash(MethodTypeHash)
 	assert.Equal(t, true, isHash)
 }
+
+func TestRouterCompositeShardKey(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	router, cleanup := MockNewRouter(log)
+	defer cleanup()
+	assert.NotNil(t, router)
+	err := router.CreateDatabase("sbtest")
+	assert.Nil(t, err)
+
+	assert.Nil(t, SplitShardKey(""))
+	assert.Equal(t, []string{"tenant_id", "user_id"}, SplitShardKey("tenant_id,user_id"))
+
+	// hash.
+	{
+		err := router.addTable("sbtest", MockTableCKConfig())
+		assert.Nil(t, err)
+
+		shardKey, err := router.ShardKey("sbtest", "CK")
+		assert.Nil(t, err)
+		assert.Equal(t, "tenant_id,user_id", shardKey)
+
+		key, err := TupleKey([]*sqlparser.SQLVal{sqlparser.NewIntVal([]byte("1")), sqlparser.NewIntVal([]byte("2"))})
+		assert.Nil(t, err)
+		idx1, err := router.GetIndex("sbtest", "CK", key)
+		assert.Nil(t, err)
+		key, err = TupleKey([]*sqlparser.SQLVal{sqlparser.NewStrVal([]byte("1")), sqlparser.NewStrVal([]byte("2"))})
+		assert.Nil(t, err)
+		idx2, err := router.GetIndex("sbtest", "CK", key)
+		assert.Nil(t, err)
+		assert.Equal(t, idx1, idx2)
+	}
+
+	// range.
+	{
+		tbl := MockTableRangeConfig()
+		tbl.ShardKey = "id,name"
+		err := router.addTable("sbtest", tbl)
+		assert.Equal(t, "router.add.table[RG].composite.shard.key.only.supported.by.hash", err.Error())
+	}
+}
+
+func TestRouterRouteValues(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	router, cleanup := MockNewRouter(log)
+	defer cleanup()
+	assert.NotNil(t, router)
+	err := router.CreateDatabase("sbtest")
+	assert.Nil(t, err)
+	err = router.AddForTest("sbtest", MockTableMConfig(), MockTableGConfig(), MockTableCKConfig())
+	assert.Nil(t, err)
+
+	// hash.
+	{
+		idx, segment, err := router.RouteValues("sbtest", "A", []*sqlparser.SQLVal{sqlparser.NewIntVal([]byte("1"))})
+		assert.Nil(t, err)
+		assert.Equal(t, 2323, idx)
+		want, err := router.GetSegments("sbtest", "A", []int{idx})
+		assert.Nil(t, err)
+		assert.Equal(t, want[0], segment)
+	}
+
+	// composite shard key.
+	{
+		vals := []*sqlparser.SQLVal{sqlparser.NewIntVal([]byte("1")), sqlparser.NewStrVal([]byte("2"))}
+		idx, _, err := router.RouteValues("sbtest", "CK", vals)
+		assert.Nil(t, err)
+		key, err := TupleKey(vals)
+		assert.Nil(t, err)
+		want, err := router.GetIndex("sbtest", "CK", key)
+		assert.Nil(t, err)
+		assert.Equal(t, want, idx)
+	}
+
+	// errors.
+	{
+		_, _, err := router.RouteValues("sbtest", "G", []*sqlparser.SQLVal{sqlparser.NewIntVal([]byte("1"))})
+		assert.EqualError(t, err, "router.table[G].has.no.shard.key")
+		_, _, err = router.RouteValues("sbtest", "CK", []*sqlparser.SQLVal{sqlparser.NewIntVal([]byte("1"))})
+		assert.EqualError(t, err, "router.table[CK].shard.key[tenant_id,user_id].values.count[1].mismatch")
+		_, _, err = router.RouteValues("sbtest", "B", []*sqlparser.SQLVal{sqlparser.NewIntVal([]byte("1"))})
+		assert.EqualError(t, err, "Table 'B' doesn't exist (errno 1146) (sqlstate 42S02)")
+	}
+}
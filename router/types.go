@@ -9,6 +9,10 @@
 
 package router
 
+import (
+	"strings"
+)
+
 // MethodType type.
 type MethodType string
 
@@ -18,4 +22,19 @@ const (
 	MethodTypeGlobal = "GLOBAL"
 	MethodTypeSingle = "SINGLE"
 	MethodTypeList   = "LIST"
+	MethodTypeRange  = "RANGE"
+	// MethodTypeInterval is the range split by the time interval.
+	MethodTypeInterval = "INTERVAL"
 )
+
+// ShardKeySeparator separates the columns of the composite shard key.
+const ShardKeySeparator = ","
+
+// SplitShardKey returns the columns of the shard key,
+// eg: 'tenant_id,user_id' -> [tenant_id user_id].
+func SplitShardKey(shardKey string) []string {
+	if shardKey == "" {
+		return nil
+	}
+	return strings.Split(shardKey, ShardKeySeparator)
+}
@@ -0,0 +1,163 @@
+/*
+ * NeoDB
+ *
+ * Copyright 2021-2030 The NeoDB Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package spill
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/pkg/errors"
+	querypb "github.com/sealdb/mysqlstack/sqlparser/depends/query"
+	"github.com/sealdb/mysqlstack/sqlparser/depends/sqltypes"
+)
+
+const (
+	// filePrefix is the name prefix of the temp files.
+	filePrefix = "neodb-spill-"
+	// bufferSize is the buffer size of the file reader and writer.
+	bufferSize = 64 * 1024
+)
+
+// Iterator iterates the rows, Next returns io.EOF if there are no more rows.
+type Iterator interface {
+	Next() ([]sqltypes.Value, error)
+	Close() error
+}
+
+// File is a temp file holding the spilled rows, the rows are read in the order written.
+// Row format: the uvarint count of the values, each value is the uvarint type and the
+// uvarint length of the raw bytes followed by the bytes, the NULL has no length and bytes.
+type File struct {
+	file    *os.File
+	w       *bufio.Writer
+	buf     []byte
+	rows    int
+	size    int64
+	tracker *Tracker
+}
+
+// NewFile creates the temp file under the dir of the tracker.
+func NewFile(tracker *Tracker) (*File, error) {
+	dir := tracker.Dir()
+	if err := os.MkdirAll(dir, 0744); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	file, err := ioutil.TempFile(dir, filePrefix)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if tracker != nil {
+		tracker.spills.Add(1)
+	}
+	return &File{
+		file:    file,
+		w:       bufio.NewWriterSize(file, bufferSize),
+		tracker: tracker,
+	}, nil
+}
+
+// Write used to write the row to the file, returns error if the disk limit of the tracker is exceeded.
+func (f *File) Write(row []sqltypes.Value) error {
+	buf := binary.AppendUvarint(f.buf[:0], uint64(len(row)))
+	for _, v := range row {
+		buf = binary.AppendUvarint(buf, uint64(v.Type()))
+		if v.IsNull() {
+			continue
+		}
+		buf = binary.AppendUvarint(buf, uint64(len(v.Raw())))
+		buf = append(buf, v.Raw()...)
+	}
+	f.buf = buf
+	f.size += int64(len(buf))
+	if err := f.tracker.consumeDisk(int64(len(buf))); err != nil {
+		return err
+	}
+	if _, err := f.w.Write(buf); err != nil {
+		return errors.WithStack(err)
+	}
+	f.rows++
+	return nil
+}
+
+// Len returns the count of the rows written.
+func (f *File) Len() int {
+	return f.rows
+}
+
+// Reader flushes the written rows and returns the reader from the beginning of the file,
+// the readers are independent of each other.
+func (f *File) Reader() (*Reader, error) {
+	if err := f.w.Flush(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	file, err := os.Open(f.file.Name())
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return &Reader{
+		file: file,
+		r:    bufio.NewReaderSize(file, bufferSize),
+	}, nil
+}
+
+// Close closes and removes the file.
+func (f *File) Close() error {
+	f.tracker.releaseDisk(f.size)
+	f.size = 0
+	name := f.file.Name()
+	f.file.Close()
+	return os.Remove(name)
+}
+
+var _ Iterator = &Reader{}
+
+// Reader reads the rows from the File.
+type Reader struct {
+	file *os.File
+	r    *bufio.Reader
+}
+
+// Next returns the next row, io.EOF if the end of the file.
+func (r *Reader) Next() ([]sqltypes.Value, error) {
+	n, err := binary.ReadUvarint(r.r)
+	if err != nil {
+		if err == io.EOF {
+			return nil, err
+		}
+		return nil, errors.WithStack(err)
+	}
+	row := make([]sqltypes.Value, n)
+	for i := range row {
+		typ, err := binary.ReadUvarint(r.r)
+		if err != nil {
+			return nil, errors.Errorf("spill.file.corrupted:%v", err)
+		}
+		if querypb.Type(typ) == sqltypes.Null {
+			continue
+		}
+		size, err := binary.ReadUvarint(r.r)
+		if err != nil {
+			return nil, errors.Errorf("spill.file.corrupted:%v", err)
+		}
+		raw := make([]byte, size)
+		if _, err := io.ReadFull(r.r, raw); err != nil {
+			return nil, errors.Errorf("spill.file.corrupted:%v", err)
+		}
+		row[i] = sqltypes.MakeTrusted(querypb.Type(typ), raw)
+	}
+	return row, nil
+}
+
+// Close closes the reader.
+func (r *Reader) Close() error {
+	return r.file.Close()
+}
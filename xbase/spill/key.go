@@ -0,0 +1,46 @@
+/*
+ * NeoDB
+ *
+ * Copyright 2021-2030 The NeoDB Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package spill
+
+import (
+	"hash/fnv"
+	"strconv"
+
+	"github.com/sealdb/mysqlstack/sqlparser/depends/sqltypes"
+)
+
+// AppendKey appends the encoded value to the key, the values equal in comparison are
+// encoded the same, such as: `1`, `1.0` and `1.00`. The NULL is encoded as `N`.
+func AppendKey(key []byte, v sqltypes.Value) []byte {
+	if v.IsNull() {
+		return append(key, 'N')
+	}
+	raw := v.Raw()
+	if (v.IsFloat() || v.Type() == sqltypes.Decimal) && len(raw) > 0 {
+		if f, err := strconv.ParseFloat(string(raw), 64); err == nil {
+			if f == 0 {
+				// -0 equals to 0.
+				f = 0
+			}
+			raw = strconv.AppendFloat(nil, f, 'f', -1, 64)
+		}
+	}
+	key = strconv.AppendInt(key, int64(len(raw)), 10)
+	key = append(key, ':')
+	return append(key, raw...)
+}
+
+// Partition returns the partition of the key in [0, n), the seed makes
+// the keys of the same partition distributed again in the next level.
+func Partition(key string, seed int, n int) int {
+	h := fnv.New32a()
+	h.Write([]byte{byte(seed)})
+	h.Write([]byte(key))
+	return int(h.Sum32() % uint32(n))
+}
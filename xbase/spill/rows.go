@@ -0,0 +1,154 @@
+/*
+ * NeoDB
+ *
+ * Copyright 2021-2030 The NeoDB Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package spill
+
+import (
+	"io"
+
+	"github.com/sealdb/mysqlstack/sqlparser/depends/sqltypes"
+)
+
+// Rows is the row container held in memory, once the memory of the tracker
+// exceeds the limit, all the rows are spilled to the temp file in order.
+type Rows struct {
+	tracker *Tracker
+	rows    [][]sqltypes.Value
+	size    int64
+	file    *File
+	count   int
+}
+
+// NewRows creates the new Rows.
+func NewRows(tracker *Tracker) *Rows {
+	return &Rows{
+		tracker: tracker,
+	}
+}
+
+// Append used to append the row.
+func (r *Rows) Append(row []sqltypes.Value) error {
+	r.count++
+	if r.file != nil {
+		return r.file.Write(row)
+	}
+
+	size := RowSize(row)
+	r.rows = append(r.rows, row)
+	r.size += size
+	r.tracker.Consume(size)
+	if r.tracker.Exceeded() {
+		return r.spill()
+	}
+	return nil
+}
+
+// spill used to write the rows in memory to the temp file.
+func (r *Rows) spill() error {
+	file, err := NewFile(r.tracker)
+	if err != nil {
+		return err
+	}
+	r.file = file
+	for _, row := range r.rows {
+		if err := file.Write(row); err != nil {
+			return err
+		}
+	}
+	r.tracker.Release(r.size)
+	r.rows, r.size = nil, 0
+	return nil
+}
+
+// Len returns the count of the rows.
+func (r *Rows) Len() int {
+	return r.count
+}
+
+// Spilled returns true if the rows were spilled to disk.
+func (r *Rows) Spilled() bool {
+	return r.file != nil
+}
+
+// Iterator returns the iterator of the rows in order.
+func (r *Rows) Iterator() (Iterator, error) {
+	if r.file != nil {
+		return r.file.Reader()
+	}
+	return NewSliceIterator(r.rows), nil
+}
+
+// All returns all the rows, the spilled rows are read into memory.
+func (r *Rows) All() ([][]sqltypes.Value, error) {
+	if r.file == nil {
+		return r.rows, nil
+	}
+	it, err := r.file.Reader()
+	if err != nil {
+		return nil, err
+	}
+	defer it.Close()
+	return ReadAll(it)
+}
+
+// Close releases the memory and removes the temp file.
+func (r *Rows) Close() error {
+	r.tracker.Release(r.size)
+	r.rows, r.size = nil, 0
+	if r.file != nil {
+		file := r.file
+		r.file = nil
+		return file.Close()
+	}
+	return nil
+}
+
+// ReadAll reads all the remaining rows of the iterator.
+func ReadAll(it Iterator) ([][]sqltypes.Value, error) {
+	var rows [][]sqltypes.Value
+	for {
+		row, err := it.Next()
+		if err != nil {
+			if err == io.EOF {
+				return rows, nil
+			}
+			return nil, err
+		}
+		rows = append(rows, row)
+	}
+}
+
+var _ Iterator = &SliceIterator{}
+
+// SliceIterator iterates the rows in memory.
+type SliceIterator struct {
+	rows [][]sqltypes.Value
+	idx  int
+}
+
+// NewSliceIterator creates the new SliceIterator.
+func NewSliceIterator(rows [][]sqltypes.Value) *SliceIterator {
+	return &SliceIterator{
+		rows: rows,
+	}
+}
+
+// Next returns the next row, io.EOF if there are no more rows.
+func (it *SliceIterator) Next() ([]sqltypes.Value, error) {
+	if it.idx >= len(it.rows) {
+		return nil, io.EOF
+	}
+	row := it.rows[it.idx]
+	it.idx++
+	return row, nil
+}
+
+// Close does nothing.
+func (it *SliceIterator) Close() error {
+	return nil
+}
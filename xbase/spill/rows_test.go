@@ -0,0 +1,107 @@
+/*
+ * NeoDB
+ *
+ * Copyright 2021-2030 The NeoDB Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package spill
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	querypb "github.com/sealdb/mysqlstack/sqlparser/depends/query"
+	"github.com/sealdb/mysqlstack/sqlparser/depends/sqltypes"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRows(t *testing.T) {
+	dir, err := ioutil.TempDir("", "neodb_spill_")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	newRow := func(i int) []sqltypes.Value {
+		return []sqltypes.Value{
+			sqltypes.NewInt64(int64(i)),
+			sqltypes.NULL,
+			sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte{}),
+			sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(fmt.Sprintf("row-%d", i))),
+		}
+	}
+
+	// Unlimited, the rows are held in memory.
+	{
+		tracker := NewTracker(0, dir)
+		rows := NewRows(tracker)
+		for i := 0; i < 100; i++ {
+			err := rows.Append(newRow(i))
+			assert.Nil(t, err)
+		}
+		assert.False(t, rows.Spilled())
+		assert.Equal(t, 100, rows.Len())
+		assert.Equal(t, int64(0), tracker.Spills())
+		rows.Close()
+	}
+
+	// The rows are spilled once the memory exceeds the limit.
+	{
+		tracker := NewTracker(RowSize(newRow(0))*10, dir)
+		rows := NewRows(tracker)
+		for i := 0; i < 100; i++ {
+			err := rows.Append(newRow(i))
+			assert.Nil(t, err)
+		}
+		assert.True(t, rows.Spilled())
+		assert.Equal(t, 100, rows.Len())
+		assert.Equal(t, int64(1), tracker.Spills())
+		assert.Equal(t, int64(0), tracker.Used())
+
+		// The values are the same after read back.
+		all, err := rows.All()
+		assert.Nil(t, err)
+		assert.Equal(t, 100, len(all))
+		for i, row := range all {
+			want := newRow(i)
+			assert.Equal(t, fmt.Sprintf("%v", want), fmt.Sprintf("%v", row))
+			for j := range row {
+				assert.Equal(t, want[j].Type(), row[j].Type())
+			}
+			assert.True(t, row[1].IsNull())
+			assert.False(t, row[2].IsNull())
+		}
+
+		files, err := ioutil.ReadDir(dir)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(files))
+		rows.Close()
+		files, err = ioutil.ReadDir(dir)
+		assert.Nil(t, err)
+		assert.Equal(t, 0, len(files))
+	}
+}
+
+func TestAppendKey(t *testing.T) {
+	key := func(vals ...sqltypes.Value) string {
+		var k []byte
+		for _, v := range vals {
+			k = AppendKey(k, v)
+		}
+		return string(k)
+	}
+	dec := func(v string) sqltypes.Value {
+		return sqltypes.MakeTrusted(querypb.Type_DECIMAL, []byte(v))
+	}
+	str := func(v string) sqltypes.Value {
+		return sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(v))
+	}
+
+	assert.Equal(t, key(sqltypes.NewInt64(1)), key(dec("1.00")))
+	assert.Equal(t, key(sqltypes.NewInt64(0)), key(dec("-0.0")))
+	assert.NotEqual(t, key(sqltypes.NULL), key(str("N")))
+	assert.NotEqual(t, key(str("1:a"), str("b")), key(str("1"), str("a1:b")))
+	assert.NotEqual(t, key(str("")), key(sqltypes.NULL))
+}
@@ -0,0 +1,214 @@
+/*
+ * NeoDB
+ *
+ * Copyright 2021-2030 The NeoDB Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package spill
+
+import (
+	"container/heap"
+	"io"
+	"sort"
+
+	"github.com/sealdb/mysqlstack/sqlparser/depends/sqltypes"
+)
+
+// LessFunc reports whether the row a sorts before the row b.
+type LessFunc func(a, b []sqltypes.Value) bool
+
+// Sorter is the external merge sorter. The rows are sorted in memory, once the memory of
+// the tracker exceeds the limit, the sorted rows are written to a temp file as a run,
+// at last the runs are merged by the k-way merge. The sort is stable.
+type Sorter struct {
+	tracker *Tracker
+	less    LessFunc
+	rows    [][]sqltypes.Value
+	size    int64
+	runs    []*File
+}
+
+// NewSorter creates the new Sorter.
+func NewSorter(tracker *Tracker, less LessFunc) *Sorter {
+	return &Sorter{
+		tracker: tracker,
+		less:    less,
+	}
+}
+
+// Add used to add the row.
+func (s *Sorter) Add(row []sqltypes.Value) error {
+	size := RowSize(row)
+	s.rows = append(s.rows, row)
+	s.size += size
+	s.tracker.Consume(size)
+	if s.tracker.Exceeded() {
+		return s.flush()
+	}
+	return nil
+}
+
+// flush used to sort the rows in memory and write them to a new run.
+func (s *Sorter) flush() error {
+	s.sort()
+	run, err := NewFile(s.tracker)
+	if err != nil {
+		return err
+	}
+	s.runs = append(s.runs, run)
+	for _, row := range s.rows {
+		if err := run.Write(row); err != nil {
+			return err
+		}
+	}
+	s.tracker.Release(s.size)
+	s.rows, s.size = nil, 0
+	return nil
+}
+
+func (s *Sorter) sort() {
+	sort.SliceStable(s.rows, func(i, j int) bool {
+		return s.less(s.rows[i], s.rows[j])
+	})
+}
+
+// Spilled returns true if the rows were spilled to disk.
+func (s *Sorter) Spilled() bool {
+	return len(s.runs) > 0
+}
+
+// Iterator returns the iterator of the sorted rows, the Sorter can't be added after.
+func (s *Sorter) Iterator() (Iterator, error) {
+	s.sort()
+	if len(s.runs) == 0 {
+		return NewSliceIterator(s.rows), nil
+	}
+
+	its := make([]Iterator, 0, len(s.runs)+1)
+	for _, run := range s.runs {
+		r, err := run.Reader()
+		if err != nil {
+			for _, it := range its {
+				it.Close()
+			}
+			return nil, err
+		}
+		its = append(its, r)
+	}
+	// The rows in memory are the last run.
+	its = append(its, NewSliceIterator(s.rows))
+	return newMergeIterator(its, s.less)
+}
+
+// Close releases the memory and removes the runs.
+func (s *Sorter) Close() error {
+	var err error
+	s.tracker.Release(s.size)
+	s.rows, s.size = nil, 0
+	for _, run := range s.runs {
+		if x := run.Close(); x != nil && err == nil {
+			err = x
+		}
+	}
+	s.runs = nil
+	return err
+}
+
+// mergeItem is the current row of the run.
+type mergeItem struct {
+	row []sqltypes.Value
+	run int
+}
+
+// mergeHeap is the min heap of the runs' current rows, the rows
+// equal are ordered by the run to keep the sort stable.
+type mergeHeap struct {
+	items []mergeItem
+	less  LessFunc
+}
+
+func (h *mergeHeap) Len() int { return len(h.items) }
+func (h *mergeHeap) Less(i, j int) bool {
+	a, b := h.items[i], h.items[j]
+	if h.less(a.row, b.row) {
+		return true
+	}
+	if h.less(b.row, a.row) {
+		return false
+	}
+	return a.run < b.run
+}
+func (h *mergeHeap) Swap(i, j int)      { h.items[i], h.items[j] = h.items[j], h.items[i] }
+func (h *mergeHeap) Push(x interface{}) { h.items = append(h.items, x.(mergeItem)) }
+func (h *mergeHeap) Pop() interface{} {
+	n := len(h.items)
+	item := h.items[n-1]
+	h.items = h.items[:n-1]
+	return item
+}
+
+var _ Iterator = &mergeIterator{}
+
+// mergeIterator merges the sorted runs.
+type mergeIterator struct {
+	its  []Iterator
+	heap *mergeHeap
+}
+
+func newMergeIterator(its []Iterator, less LessFunc) (*mergeIterator, error) {
+	m := &mergeIterator{
+		its:  its,
+		heap: &mergeHeap{less: less},
+	}
+	for i := range its {
+		if err := m.advance(i); err != nil {
+			m.Close()
+			return nil, err
+		}
+	}
+	heap.Init(m.heap)
+	return m, nil
+}
+
+// advance pushes the next row of the run into the heap.
+func (m *mergeIterator) advance(run int) error {
+	row, err := m.its[run].Next()
+	if err != nil {
+		if err == io.EOF {
+			return nil
+		}
+		return err
+	}
+	m.heap.items = append(m.heap.items, mergeItem{row: row, run: run})
+	return nil
+}
+
+// Next returns the next row, io.EOF if there are no more rows.
+func (m *mergeIterator) Next() ([]sqltypes.Value, error) {
+	if m.heap.Len() == 0 {
+		return nil, io.EOF
+	}
+	item := heap.Pop(m.heap).(mergeItem)
+	row, err := m.its[item.run].Next()
+	switch err {
+	case nil:
+		heap.Push(m.heap, mergeItem{row: row, run: item.run})
+	case io.EOF:
+	default:
+		return nil, err
+	}
+	return item.row, nil
+}
+
+// Close closes all the runs' iterators.
+func (m *mergeIterator) Close() error {
+	var err error
+	for _, it := range m.its {
+		if x := it.Close(); x != nil && err == nil {
+			err = x
+		}
+	}
+	return err
+}
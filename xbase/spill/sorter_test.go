@@ -0,0 +1,66 @@
+/*
+ * NeoDB
+ *
+ * Copyright 2021-2030 The NeoDB Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package spill
+
+import (
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"testing"
+
+	"github.com/sealdb/mysqlstack/sqlparser/depends/sqltypes"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSorter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "neodb_spill_")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	// Sort by the first column, the second column is the insertion order.
+	less := func(a, b []sqltypes.Value) bool {
+		return sqltypes.NullsafeCompare(a[0], b[0]) < 0
+	}
+	newRow := func(key, seq int) []sqltypes.Value {
+		return []sqltypes.Value{sqltypes.NewInt64(int64(key)), sqltypes.NewInt64(int64(seq))}
+	}
+
+	for _, limit := range []int64{0, RowSize(newRow(0, 0)) * 7} {
+		tracker := NewTracker(limit, dir)
+		sorter := NewSorter(tracker, less)
+		r := rand.New(rand.NewSource(1))
+		for i := 0; i < 500; i++ {
+			err := sorter.Add(newRow(r.Intn(50), i))
+			assert.Nil(t, err)
+		}
+		assert.Equal(t, limit > 0, sorter.Spilled())
+
+		it, err := sorter.Iterator()
+		assert.Nil(t, err)
+		rows, err := ReadAll(it)
+		assert.Nil(t, err)
+		it.Close()
+		assert.Equal(t, 500, len(rows))
+		for i := 1; i < len(rows); i++ {
+			cmp := sqltypes.NullsafeCompare(rows[i-1][0], rows[i][0])
+			assert.True(t, cmp <= 0)
+			// Stable.
+			if cmp == 0 {
+				assert.True(t, sqltypes.NullsafeCompare(rows[i-1][1], rows[i][1]) < 0)
+			}
+		}
+
+		err = sorter.Close()
+		assert.Nil(t, err)
+		assert.Equal(t, int64(0), tracker.Used())
+		files, err := ioutil.ReadDir(dir)
+		assert.Nil(t, err)
+		assert.Equal(t, 0, len(files))
+	}
+}
@@ -0,0 +1,162 @@
+/*
+ * NeoDB
+ *
+ * Copyright 2021-2030 The NeoDB Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package spill
+
+import (
+	"os"
+
+	"github.com/sealdb/neodb/xbase/sync2"
+
+	"github.com/pkg/errors"
+	"github.com/sealdb/mysqlstack/sqlparser/depends/sqltypes"
+)
+
+const (
+	// valueOverhead is the estimated memory of a sqltypes.Value except the raw bytes.
+	valueOverhead = 40
+	// rowOverhead is the estimated memory of a row's slice header.
+	rowOverhead = 24
+)
+
+// Tracker tracks the memory held by the operators of the running query.
+// The operators spill the rows to the temp files under the dir if the
+// memory exceeds the limit, 0 limit means unlimited and never spill.
+// The bytes held by the temp files are bounded by the disk limit, 0 means unlimited.
+type Tracker struct {
+	limit     int64
+	dir       string
+	used      sync2.AtomicInt64
+	diskLimit int64
+	diskUsed  sync2.AtomicInt64
+	// the count of the spilled temp files.
+	spills sync2.AtomicInt64
+}
+
+// NewTracker creates the new Tracker.
+func NewTracker(limit int64, dir string) *Tracker {
+	return &Tracker{
+		limit: limit,
+		dir:   dir,
+	}
+}
+
+// SetLimit used to set the memory limit in bytes.
+func (t *Tracker) SetLimit(limit int64) {
+	t.limit = limit
+}
+
+// SetDiskLimit used to set the limit of the bytes held by the temp files.
+func (t *Tracker) SetDiskLimit(limit int64) {
+	t.diskLimit = limit
+}
+
+// SetDir used to set the dir of the temp files.
+func (t *Tracker) SetDir(dir string) {
+	t.dir = dir
+}
+
+// Enabled returns true if the memory is limited, the nil Tracker is disabled.
+func (t *Tracker) Enabled() bool {
+	return t != nil && t.limit > 0
+}
+
+// Limit returns the memory limit.
+func (t *Tracker) Limit() int64 {
+	if t == nil {
+		return 0
+	}
+	return t.limit
+}
+
+// Dir returns the dir of the temp files, default is the os temp dir.
+func (t *Tracker) Dir() string {
+	if t == nil || t.dir == "" {
+		return os.TempDir()
+	}
+	return t.dir
+}
+
+// Consume adds n bytes to the used memory.
+func (t *Tracker) Consume(n int64) {
+	if t != nil {
+		t.used.Add(n)
+	}
+}
+
+// Release subs n bytes from the used memory.
+func (t *Tracker) Release(n int64) {
+	if t != nil {
+		t.used.Add(-n)
+	}
+}
+
+// Used returns the used memory.
+func (t *Tracker) Used() int64 {
+	if t == nil {
+		return 0
+	}
+	return t.used.Get()
+}
+
+// Exceeded returns true if the used memory exceeds the limit.
+func (t *Tracker) Exceeded() bool {
+	return t.Enabled() && t.used.Get() > t.limit
+}
+
+// consumeDisk adds n bytes to the bytes held by the temp files, returns error if the disk limit is exceeded.
+func (t *Tracker) consumeDisk(n int64) error {
+	if t == nil {
+		return nil
+	}
+	if used := t.diskUsed.Add(n); t.diskLimit > 0 && used > t.diskLimit {
+		return errors.Errorf("Query execution was interrupted, max spill size[%d bytes] exceeded", t.diskLimit)
+	}
+	return nil
+}
+
+// releaseDisk subs n bytes from the bytes held by the temp files.
+func (t *Tracker) releaseDisk(n int64) {
+	if t != nil {
+		t.diskUsed.Add(-n)
+	}
+}
+
+// DiskUsed returns the bytes held by the temp files.
+func (t *Tracker) DiskUsed() int64 {
+	if t == nil {
+		return 0
+	}
+	return t.diskUsed.Get()
+}
+
+// Spills returns the count of the spilled temp files.
+func (t *Tracker) Spills() int64 {
+	if t == nil {
+		return 0
+	}
+	return t.spills.Get()
+}
+
+// RowSize returns the estimated memory of the row.
+func RowSize(row []sqltypes.Value) int64 {
+	size := int64(rowOverhead)
+	for _, v := range row {
+		size += valueOverhead + int64(len(v.Raw()))
+	}
+	return size
+}
+
+// RowsSize returns the estimated memory of the rows.
+func RowsSize(rows [][]sqltypes.Value) int64 {
+	var size int64
+	for _, row := range rows {
+		size += RowSize(row)
+	}
+	return size
+}
@@ -44,6 +44,9 @@ const (
 // ResultContext tuple.
 type ResultContext struct {
 	Results *sqltypes.Result
+	// Sorted is true if the Results are already in the order of the leading order by,
+	// such as the rows merged from the sorted rows of the backends.
+	Sorted bool
 }
 
 // NewResultContext returns the result context.
@@ -57,6 +60,25 @@ type RequestContext struct {
 	Mode     RequestMode
 	TxnMode  TxnMode
 	Querys   []QueryTuple
+
+	// OrderBy is the order of the rows sorted by each backend, if set, the sorted
+	// rows of the backends are merged by the order instead of being concatenated.
+	OrderBy []OrderKey
+	// Offset and Limit are applied to the merged rows, only valid if OrderBy is set.
+	// The Limit is negative if unlimited.
+	Offset int
+	Limit  int
+
+	// ForceXA is true if the XA transaction is started even if the querys are on one
+	// backend, such as the rows read for update before the later writes of the statement.
+	ForceXA bool
+}
+
+// OrderKey tuple.
+type OrderKey struct {
+	Field string
+	Table string
+	Desc  bool
 }
 
 // NewRequestContext creates RequestContext
@@ -75,6 +97,10 @@ type QueryTuple struct {
 
 	// Range info.
 	Range string
+
+	// Discard is true if the result is not returned, the query is executed for
+	// the side effect, such as maintaining the lookup tables.
+	Discard bool `json:",omitempty"`
 }
 
 // QueryTuples represents the query tuple slice.
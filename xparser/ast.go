@@ -0,0 +1,235 @@
+/*
+ * NeoDB
+ *
+ * Copyright 2021-2030 The NeoDB Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package xparser
+
+import (
+	"strconv"
+
+	"github.com/sealdb/mysqlstack/sqlparser"
+)
+
+const (
+	// PartitionTableRange is the partition type of the range table.
+	PartitionTableRange = "partitiontablerange"
+
+	// PartitionTableColocate is the partition type of the colocate table.
+	PartitionTableColocate = "partitiontablecolocate"
+
+	// PartitionTableInterval is the partition type of the interval table.
+	PartitionTableInterval = "partitiontableinterval"
+)
+
+const (
+	// AlterAddPartitionStr adds the partitions to the list table.
+	AlterAddPartitionStr = "alter table add partition"
+	// AlterDropPartitionStr drops the partitions from the list table.
+	AlterDropPartitionStr = "alter table drop partition"
+)
+
+const (
+	// CreateLookupIndexStr creates the lookup index on the column of the table.
+	CreateLookupIndexStr = "create lookup index"
+	// DropLookupIndexStr drops the lookup index of the table.
+	DropLookupIndexStr = "drop lookup index"
+)
+
+const (
+	// IntervalStatusStr shows the status of the interval partition scheduler.
+	IntervalStatusStr = "interval status"
+	// IntervalRunStr runs the interval partition scheduler at once.
+	IntervalRunStr = "interval run"
+)
+
+const (
+	// RouteStr shows the partition which the shard key value routes to.
+	RouteStr = "route"
+	// RouteQueryStr shows the backend querys of the statement without executing them.
+	RouteQueryStr = "route query"
+)
+
+// DefaultIntervalPremake is the number of the intervals created ahead by default.
+const DefaultIntervalPremake = 3
+
+// RangePartitionDefinition defines a single range partition.
+// eg: PARTITION backend1 VALUES LESS THAN (100)
+type RangePartitionDefinition struct {
+	Backend string
+	// LessThan is the exclusive upper bound, nil means MAXVALUE.
+	LessThan *sqlparser.SQLVal
+}
+
+// RangePartitionDefinitions specifies the range partition options.
+type RangePartitionDefinitions []*RangePartitionDefinition
+
+// PartOptRange range table.
+// eg: PARTITION BY RANGE(id) (PARTITION backend1 VALUES LESS THAN (100), ...)
+type PartOptRange struct {
+	Name     string
+	PartDefs RangePartitionDefinitions
+}
+
+// PartitionType return the partition type.
+func (*PartOptRange) PartitionType() string {
+	return PartitionTableRange
+}
+
+// PartOptHashFunc hash table with the hash function.
+// eg: PARTITION BY HASH(id) [PARTITIONS num] USING 'crc32-mod'
+type PartOptHashFunc struct {
+	sqlparser.PartOptHash
+	// Function is the name of the hash function registered in the router.
+	Function string
+}
+
+// PartitionType return the partition type.
+func (*PartOptHashFunc) PartitionType() string {
+	return sqlparser.PartitionTableHash
+}
+
+// PartOptColocate colocate table, the partitions are the same as the colocate table.
+// eg: PARTITION BY HASH(id) COLOCATE WITH t1, or COLOCATE WITH t1
+type PartOptColocate struct {
+	// ShardType is HASH/LIST/RANGE, empty means the same as the colocate table.
+	ShardType string
+	// Name is the shard key, empty means the same as the colocate table.
+	Name  string
+	Table string
+}
+
+// PartitionType return the partition type.
+func (*PartOptColocate) PartitionType() string {
+	return PartitionTableColocate
+}
+
+// PartOptInterval interval table, the partitions are split by the time interval.
+// eg: PARTITION BY INTERVAL(ts) DAY PREMAKE 7 RETENTION 30 DETACH
+type PartOptInterval struct {
+	Name string
+	// Unit is DAY or MONTH.
+	Unit string
+	// Premake is the number of the intervals created ahead.
+	Premake int
+	// Retention is the number of the past intervals kept, 0 means keeping all.
+	Retention int
+	// Detach keeps the expired sub tables on the backends instead of dropping them.
+	Detach bool
+}
+
+// PartitionType return the partition type.
+func (*PartOptInterval) PartitionType() string {
+	return PartitionTableInterval
+}
+
+// WindowFuncName is the name of the function which wraps the window function,
+// eg: `sum(a) OVER (ORDER BY b)` is parsed as `neodb_window(sum(a), 'order by b asc')`.
+// The name is reserved, the user-written one is rejected by the Parse.
+const WindowFuncName = "neodb_window"
+
+const (
+	// FrameRows is the frame unit of the physical rows.
+	FrameRows = "rows"
+	// FrameRange is the frame unit of the peer rows.
+	FrameRange = "range"
+)
+
+const (
+	// BoundUnboundedPreceding is the first row of the partition.
+	BoundUnboundedPreceding = "unbounded preceding"
+	// BoundPreceding is the n rows before the current row.
+	BoundPreceding = "preceding"
+	// BoundCurrentRow is the current row, or its last(first) peer for RANGE.
+	BoundCurrentRow = "current row"
+	// BoundFollowing is the n rows after the current row.
+	BoundFollowing = "following"
+	// BoundUnboundedFollowing is the last row of the partition.
+	BoundUnboundedFollowing = "unbounded following"
+)
+
+// FrameBound is the start or the end of the window frame.
+type FrameBound struct {
+	Typ string
+	// Offset is the n of the n PRECEDING|FOLLOWING.
+	Offset int
+}
+
+// Format formats the node.
+func (node FrameBound) Format(buf *sqlparser.TrackedBuffer) {
+	if node.Typ == BoundPreceding || node.Typ == BoundFollowing {
+		buf.Myprintf("%s ", strconv.Itoa(node.Offset))
+	}
+	buf.Myprintf("%s", node.Typ)
+}
+
+// WindowFrame is the frame clause of the window.
+// eg: ROWS BETWEEN 1 PRECEDING AND CURRENT ROW
+type WindowFrame struct {
+	// Unit is ROWS or RANGE.
+	Unit  string
+	Start FrameBound
+	End   FrameBound
+}
+
+// Format formats the node.
+func (node *WindowFrame) Format(buf *sqlparser.TrackedBuffer) {
+	buf.Myprintf("%s between %v and %v", node.Unit, node.Start, node.End)
+}
+
+// WindowSpec is the window specification of the OVER clause.
+// eg: OVER (PARTITION BY a ORDER BY b ROWS UNBOUNDED PRECEDING)
+type WindowSpec struct {
+	PartitionBy sqlparser.Exprs
+	OrderBy     sqlparser.OrderBy
+	// Frame is nil if the frame clause is absent.
+	Frame *WindowFrame
+}
+
+// Format formats the node.
+func (node *WindowSpec) Format(buf *sqlparser.TrackedBuffer) {
+	sep := ""
+	if len(node.PartitionBy) > 0 {
+		buf.Myprintf("partition by %v", node.PartitionBy)
+		sep = " "
+	}
+	if len(node.OrderBy) > 0 {
+		buf.Myprintf("%sorder by ", sep)
+		for i, order := range node.OrderBy {
+			if i > 0 {
+				buf.Myprintf(", ")
+			}
+			buf.Myprintf("%v", order)
+		}
+		sep = " "
+	}
+	if node.Frame != nil {
+		buf.Myprintf("%s%v", sep, node.Frame)
+	}
+}
+
+// WindowFunc is the function with the OVER clause.
+type WindowFunc struct {
+	Func sqlparser.Expr
+	Spec *WindowSpec
+}
+
+// Format formats the node.
+func (node *WindowFunc) Format(buf *sqlparser.TrackedBuffer) {
+	buf.Myprintf("%v over (%v)", node.Func, node.Spec)
+}
+
+// MultiUpdate is the multiple-table update, the Table, OrderBy and Limit of the Update are unused.
+// eg: UPDATE t1 JOIN t2 ON t1.id = t2.id SET t1.a = t2.b WHERE t2.c = 1
+type MultiUpdate struct {
+	*sqlparser.Update
+	TableRefs sqlparser.TableExprs
+}
+
+// Format formats the node.
+func (node *MultiUpdate) Format(buf *sqlparser.TrackedBuffer) {
+	buf.Myprintf("update %v%v set %v%v", node.Comments, node.TableRefs, node.Exprs, node.Where)
+}
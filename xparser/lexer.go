@@ -0,0 +1,170 @@
+/*
+ * NeoDB
+ *
+ * Copyright 2021-2030 The NeoDB Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package xparser
+
+import (
+	"strings"
+)
+
+// tokenType is the type of the token.
+type tokenType int
+
+const (
+	tokEOF tokenType = iota
+	tokIdent
+	tokQuotedIdent
+	tokString
+	tokNumber
+	tokPunct
+)
+
+// token tuple.
+type token struct {
+	typ tokenType
+	// val is the unquoted value of the token.
+	val string
+	// pos is the offset of the first byte of the token in the sql.
+	pos int
+	// end is the offset after the last byte of the token in the sql.
+	end int
+}
+
+// is returns true if the token is the (case-insensitive) keyword or punct.
+func (t token) is(s string) bool {
+	switch t.typ {
+	case tokIdent:
+		return strings.EqualFold(t.val, s)
+	case tokPunct:
+		return t.val == s
+	}
+	return false
+}
+
+// lexer is a small mysql tokenizer which keeps the offsets of every token,
+// so that the extension clauses can be cut out of the raw sql.
+type lexer struct {
+	sql string
+	pos int
+	// comments are the /* */ comments skipped.
+	comments []string
+}
+
+func isIdentChar(ch byte) bool {
+	return ch == '_' || ch == '$' || ch == '@' ||
+		(ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z') || (ch >= '0' && ch <= '9') || ch >= 0x80
+}
+
+func isDigit(ch byte) bool {
+	return ch >= '0' && ch <= '9'
+}
+
+// skipBlank skips the spaces and comments.
+func (l *lexer) skipBlank() {
+	for l.pos < len(l.sql) {
+		ch := l.sql[l.pos]
+		switch {
+		case ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r':
+			l.pos++
+		case ch == '#' || (ch == '-' && strings.HasPrefix(l.sql[l.pos:], "-- ")):
+			if idx := strings.IndexByte(l.sql[l.pos:], '\n'); idx >= 0 {
+				l.pos += idx + 1
+			} else {
+				l.pos = len(l.sql)
+			}
+		case ch == '/' && strings.HasPrefix(l.sql[l.pos:], "/*"):
+			start := l.pos
+			if idx := strings.Index(l.sql[l.pos+2:], "*/"); idx >= 0 {
+				l.pos += idx + 4
+			} else {
+				l.pos = len(l.sql)
+			}
+			l.comments = append(l.comments, l.sql[start:l.pos])
+		default:
+			return
+		}
+	}
+}
+
+// scanQuoted scans the quoted string or identifier, the quote can be escaped
+// by doubling it or by the backslash(except for the identifier).
+func (l *lexer) scanQuoted(quote byte) string {
+	var buf strings.Builder
+	l.pos++
+	for l.pos < len(l.sql) {
+		ch := l.sql[l.pos]
+		switch {
+		case ch == '\\' && quote != '`' && l.pos+1 < len(l.sql):
+			buf.WriteByte(l.sql[l.pos+1])
+			l.pos += 2
+		case ch == quote:
+			if l.pos+1 < len(l.sql) && l.sql[l.pos+1] == quote {
+				buf.WriteByte(quote)
+				l.pos += 2
+				continue
+			}
+			l.pos++
+			return buf.String()
+		default:
+			buf.WriteByte(ch)
+			l.pos++
+		}
+	}
+	return buf.String()
+}
+
+// next returns the next token.
+func (l *lexer) next() token {
+	l.skipBlank()
+	start := l.pos
+	if l.pos >= len(l.sql) {
+		return token{typ: tokEOF, pos: start, end: start}
+	}
+
+	ch := l.sql[l.pos]
+	switch {
+	case ch == '\'' || ch == '"':
+		val := l.scanQuoted(ch)
+		return token{typ: tokString, val: val, pos: start, end: l.pos}
+	case ch == '`':
+		val := l.scanQuoted(ch)
+		return token{typ: tokQuotedIdent, val: val, pos: start, end: l.pos}
+	case isDigit(ch) || (ch == '.' && l.pos+1 < len(l.sql) && isDigit(l.sql[l.pos+1])):
+		for l.pos < len(l.sql) && (isDigit(l.sql[l.pos]) || l.sql[l.pos] == '.') {
+			l.pos++
+		}
+		// Such as '1abc' is an identifier in mysql.
+		if l.pos < len(l.sql) && isIdentChar(l.sql[l.pos]) {
+			for l.pos < len(l.sql) && isIdentChar(l.sql[l.pos]) {
+				l.pos++
+			}
+			return token{typ: tokIdent, val: l.sql[start:l.pos], pos: start, end: l.pos}
+		}
+		return token{typ: tokNumber, val: l.sql[start:l.pos], pos: start, end: l.pos}
+	case isIdentChar(ch):
+		for l.pos < len(l.sql) && isIdentChar(l.sql[l.pos]) {
+			l.pos++
+		}
+		return token{typ: tokIdent, val: l.sql[start:l.pos], pos: start, end: l.pos}
+	}
+	l.pos++
+	return token{typ: tokPunct, val: string(ch), pos: start, end: l.pos}
+}
+
+// tokenize splits the sql into tokens, the last token is always tokEOF.
+func tokenize(sql string) []token {
+	l := &lexer{sql: sql}
+	var toks []token
+	for {
+		tok := l.next()
+		toks = append(toks, tok)
+		if tok.typ == tokEOF {
+			return toks
+		}
+	}
+}
@@ -0,0 +1,719 @@
+/*
+ * NeoDB
+ *
+ * Copyright 2021-2030 The NeoDB Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package xparser
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/sealdb/mysqlstack/sqlparser"
+)
+
+// Parse parses the sql and returns the statement.
+// The NeoDB extended grammar which the sqlparser doesn't understand
+// is cut out of the sql and attached to the AST, the rest of the sql
+// is handed to the sqlparser.
+// Supports:
+// 1. CREATE TABLE ... PARTITION BY RANGE(col) (PARTITION backend VALUES LESS THAN (value|MAXVALUE), ...)
+// 2. CREATE TABLE ... PARTITION BY HASH(col1, col2, ...) [PARTITIONS num] [USING 'function']
+// 3. CREATE TABLE ... [PARTITION BY HASH|LIST|RANGE(col, ...)] COLOCATE WITH table
+// 4. CREATE TABLE ... PARTITION BY INTERVAL(col) DAY|MONTH [PREMAKE num] [RETENTION num [DETACH]]
+// 5. NEODB INTERVAL STATUS|RUN
+// 6. ALTER TABLE ... ADD PARTITION (PARTITION backend VALUES IN (value|DEFAULT, ...), ...)
+// 7. ALTER TABLE ... DROP PARTITION sub_table, ...
+// 8. CREATE LOOKUP INDEX name ON table(col)
+// 9. DROP LOOKUP INDEX name ON table
+// 10. CREATE UNIQUE GLOBAL INDEX name ON table(col)
+// 11. DROP GLOBAL INDEX name ON table
+// 12. NEODB ROUTE table VALUE value|(value, ...)
+// 13. NEODB ROUTE statement
+// 14. WITH [RECURSIVE] name [(col, ...)] AS (query) [, ...] SELECT ...
+// 15. func(args) OVER ([PARTITION BY expr, ...] [ORDER BY expr [ASC|DESC], ...] [ROWS|RANGE frame])
+// 16. UPDATE table_references SET assignment, ... [WHERE expr]
+// The hints and names reserved for the rewritten statements cannot be written by the user.
+func Parse(sql string) (sqlparser.Statement, error) {
+	if node, ok := parsePlain(sql); ok {
+		return node, nil
+	}
+	if err := checkReserved(sql); err != nil {
+		return nil, err
+	}
+	return parse(sql)
+}
+
+// Reparse parses the sql formatted from the statement returned by the Parse, such as the
+// query with the bind variables, the reserved hints and names written by the Parse are kept.
+func Reparse(sql string) (sqlparser.Statement, error) {
+	if node, ok := parsePlain(sql); ok {
+		return node, nil
+	}
+	return parse(sql)
+}
+
+// plainExcludes are the words which the sqlparser may accept with another meaning than
+// the extended grammar, and the prefix of the reserved names and hints.
+var plainExcludes = []string{"neodb", "partition", "colocate", "global", "lookup"}
+
+// parsePlain parses the sql by the sqlparser only, to skip the lexer on the hot path.
+// The sql containing any of the plainExcludes is left to the parse, the other extended
+// statements are syntax errors to the sqlparser and fall back to the parse too.
+func parsePlain(sql string) (sqlparser.Statement, bool) {
+	lower := strings.ToLower(sql)
+	for _, word := range plainExcludes {
+		if strings.Contains(lower, word) {
+			return nil, false
+		}
+	}
+	node, err := sqlparser.Parse(sql)
+	if err != nil {
+		return nil, false
+	}
+	return node, true
+}
+
+// checkReserved rejects the names and the hints reserved for the rewritten statements,
+// the names are the identifiers and the hints are in the comments of the sql.
+func checkReserved(sql string) error {
+	l := &lexer{sql: sql}
+	for tok := l.next(); tok.typ != tokEOF; tok = l.next() {
+		if (tok.typ == tokIdent || tok.typ == tokQuotedIdent) && strings.EqualFold(tok.val, WindowFuncName) {
+			return errors.Errorf("unsupported: reserved.name.'%s'", WindowFuncName)
+		}
+	}
+	for _, comment := range l.comments {
+		if strings.Contains(strings.ToLower(comment), recursiveHintName) {
+			return errors.Errorf("unsupported: reserved.hint.'%s'", recursiveHintName)
+		}
+	}
+	return nil
+}
+
+func parse(sql string) (sqlparser.Statement, error) {
+	toks := tokenize(sql)
+	if hasWindow(toks) {
+		var err error
+		if sql, err = rewriteWindows(sql, toks); err != nil {
+			return nil, err
+		}
+		toks = tokenize(sql)
+	}
+	if len(toks) > 2 && toks[0].is("with") {
+		return parseWith(sql, toks)
+	}
+	if len(toks) > 2 && toks[0].is("update") {
+		if node, ok, err := parseMultiUpdate(sql, toks); ok {
+			return node, err
+		}
+	}
+	if len(toks) > 2 && toks[0].is("create") && toks[1].is("table") {
+		return parseCreateTable(sql, toks)
+	}
+	if len(toks) > 2 && toks[0].is("alter") && toks[1].is("table") {
+		if node, ok, err := parseAlterPartition(sql, toks); ok {
+			return node, err
+		}
+	}
+	if matchTokens(toks, "create", "lookup", "index") || matchTokens(toks, "drop", "lookup", "index") ||
+		matchTokens(toks, "create", "unique", "global", "index") || matchTokens(toks, "drop", "global", "index") {
+		return newParser(sql, toks).parseLookupIndex()
+	}
+	if len(toks) > 1 && toks[0].is("neodb") && toks[1].is("interval") {
+		return newParser(sql, toks[2:]).parseNeoDBInterval()
+	}
+	if len(toks) > 1 && toks[0].is("neodb") && toks[1].is("route") {
+		return newParser(sql, toks[2:]).parseNeoDBRoute()
+	}
+	return sqlparser.Parse(sql)
+}
+
+// parseCreateTable finds the extended partition option at the outermost level.
+func parseCreateTable(sql string, toks []token) (sqlparser.Statement, error) {
+	depth := 0
+	for i, tok := range toks {
+		switch {
+		case tok.is("("):
+			depth++
+		case tok.is(")"):
+			depth--
+		case depth == 0 && (matchTokens(toks[i:], "partition", "by") || matchTokens(toks[i:], "colocate", "with")) && hasOuterTokens(toks[i:], "colocate", "with"):
+			p := newParser(sql, toks[i:])
+			partOpt, err := p.parseColocateOption()
+			if err != nil {
+				return nil, err
+			}
+			return attachPartitionOption(sql[:tok.pos], partOpt)
+		case depth == 0 && matchTokens(toks[i:], "partition", "by", "range"):
+			p := newParser(sql, toks[i+3:])
+			partOpt, err := p.parseRangeOption()
+			if err != nil {
+				return nil, err
+			}
+			return attachPartitionOption(sql[:tok.pos], partOpt)
+		case depth == 0 && matchTokens(toks[i:], "partition", "by", "interval"):
+			p := newParser(sql, toks[i+3:])
+			partOpt, err := p.parseIntervalOption()
+			if err != nil {
+				return nil, err
+			}
+			return attachPartitionOption(sql[:tok.pos], partOpt)
+		case depth == 0 && matchTokens(toks[i:], "partition", "by", "hash", "(") &&
+			((len(toks) > i+5 && toks[i+5].is(",")) || hasOuterTokens(toks[i:], "using")):
+			// The single column hash without the function is handled by the sqlparser.
+			p := newParser(sql, toks[i+3:])
+			partOpt, err := p.parseHashOption()
+			if err != nil {
+				return nil, err
+			}
+			return attachPartitionOption(sql[:tok.pos], partOpt)
+		}
+	}
+	return sqlparser.Parse(sql)
+}
+
+// matchTokens returns true if the toks start with the words.
+func matchTokens(toks []token, words ...string) bool {
+	if len(toks) < len(words) {
+		return false
+	}
+	for i, word := range words {
+		if !toks[i].is(word) {
+			return false
+		}
+	}
+	return true
+}
+
+// hasOuterTokens returns true if the toks contain the words at the outermost level.
+func hasOuterTokens(toks []token, words ...string) bool {
+	depth := 0
+	for i, tok := range toks {
+		switch {
+		case tok.is("("):
+			depth++
+		case tok.is(")"):
+			depth--
+		case depth == 0 && matchTokens(toks[i:], words...):
+			return true
+		}
+	}
+	return false
+}
+
+// parseAlterPartition parses the ALTER TABLE ... ADD|DROP PARTITION, returns false if the sql is another ALTER.
+// The partitions to add are set as the PartitionOption and the sub tables to drop are set as the Tables.
+func parseAlterPartition(sql string, toks []token) (sqlparser.Statement, bool, error) {
+	p := newParser(sql, toks[2:])
+	table, err := p.tableName()
+	if err != nil {
+		return nil, false, nil
+	}
+
+	ddl := &sqlparser.DDL{Table: table, NewName: table}
+	rest := p.toks[p.idx:]
+	switch {
+	case matchTokens(rest, "add", "partition"):
+		p.idx += 2
+		ddl.Action = AlterAddPartitionStr
+		partDefs, err := p.listDefinitions()
+		if err != nil {
+			return nil, true, err
+		}
+		ddl.PartitionOption = &sqlparser.PartOptList{PartDefs: partDefs}
+	case matchTokens(rest, "drop", "partition"):
+		p.idx += 2
+		ddl.Action = AlterDropPartitionStr
+		for {
+			name, err := p.ident()
+			if err != nil {
+				return nil, true, err
+			}
+			ddl.Tables = append(ddl.Tables, sqlparser.TableName{Name: sqlparser.NewTableIdent(name)})
+			if !p.accept(",") {
+				break
+			}
+		}
+	default:
+		return nil, false, nil
+	}
+	return ddl, true, p.expectEOF()
+}
+
+// attachPartitionOption parses the create table without the partition option,
+// and sets the extended option to the DDL.
+func attachPartitionOption(sql string, partOpt sqlparser.PartitionOption) (sqlparser.Statement, error) {
+	node, err := sqlparser.Parse(sql)
+	if err != nil {
+		return nil, err
+	}
+	ddl, ok := node.(*sqlparser.DDL)
+	if !ok || ddl.Action != sqlparser.CreateTableStr {
+		return nil, errors.Errorf("syntax error: unexpected partition option in '%s'", sql)
+	}
+	ddl.PartitionOption = partOpt
+	return ddl, nil
+}
+
+// parser tuple.
+type parser struct {
+	sql  string
+	toks []token
+	idx  int
+}
+
+func newParser(sql string, toks []token) *parser {
+	return &parser{sql: sql, toks: toks}
+}
+
+func (p *parser) peek() token {
+	return p.toks[p.idx]
+}
+
+func (p *parser) next() token {
+	tok := p.toks[p.idx]
+	if tok.typ != tokEOF {
+		p.idx++
+	}
+	return tok
+}
+
+// accept consumes the token if it is the keyword or punct.
+func (p *parser) accept(s string) bool {
+	if p.peek().is(s) {
+		p.next()
+		return true
+	}
+	return false
+}
+
+// expect consumes the keywords or puncts in order.
+func (p *parser) expect(words ...string) error {
+	for _, word := range words {
+		tok := p.next()
+		if !tok.is(word) {
+			return p.errorf(tok)
+		}
+	}
+	return nil
+}
+
+// expectEOF checks all the tokens have been consumed.
+func (p *parser) expectEOF() error {
+	if tok := p.peek(); tok.typ != tokEOF {
+		return p.errorf(tok)
+	}
+	return nil
+}
+
+// ident returns the identifier, the quoted identifier is allowed.
+func (p *parser) ident() (string, error) {
+	tok := p.next()
+	if tok.typ != tokIdent && tok.typ != tokQuotedIdent {
+		return "", p.errorf(tok)
+	}
+	return tok.val, nil
+}
+
+// value returns the literal value, the negative number is allowed.
+func (p *parser) value() (*sqlparser.SQLVal, error) {
+	neg := p.accept("-")
+	tok := p.next()
+	switch tok.typ {
+	case tokNumber:
+		val := tok.val
+		if neg {
+			val = "-" + val
+		}
+		for _, ch := range val {
+			if ch == '.' {
+				return sqlparser.NewFloatVal([]byte(val)), nil
+			}
+		}
+		return sqlparser.NewIntVal([]byte(val)), nil
+	case tokString:
+		if !neg {
+			return sqlparser.NewStrVal([]byte(tok.val)), nil
+		}
+	}
+	return nil, p.errorf(tok)
+}
+
+// errorf returns the error in the sqlparser style.
+func (p *parser) errorf(tok token) error {
+	if tok.typ == tokEOF {
+		return fmt.Errorf("syntax error at position %d", len(p.sql)+1)
+	}
+	return fmt.Errorf("syntax error at position %d near '%s'", tok.end+1, tok.val)
+}
+
+// parseRangeOption parses:
+// (col) (PARTITION backend VALUES LESS THAN (value|MAXVALUE), ...)
+func (p *parser) parseRangeOption() (*PartOptRange, error) {
+	var err error
+	partOpt := &PartOptRange{}
+	if err = p.expect("("); err != nil {
+		return nil, err
+	}
+	if partOpt.Name, err = p.ident(); err != nil {
+		return nil, err
+	}
+	if err = p.expect(")", "("); err != nil {
+		return nil, err
+	}
+	for {
+		def := &RangePartitionDefinition{}
+		if err = p.expect("partition"); err != nil {
+			return nil, err
+		}
+		if def.Backend, err = p.ident(); err != nil {
+			return nil, err
+		}
+		if err = p.expect("values", "less", "than"); err != nil {
+			return nil, err
+		}
+		paren := p.accept("(")
+		if !p.accept("maxvalue") {
+			if def.LessThan, err = p.value(); err != nil {
+				return nil, err
+			}
+		}
+		if paren {
+			if err = p.expect(")"); err != nil {
+				return nil, err
+			}
+		}
+		partOpt.PartDefs = append(partOpt.PartDefs, def)
+		if !p.accept(",") {
+			break
+		}
+	}
+	if err = p.expect(")"); err != nil {
+		return nil, err
+	}
+	return partOpt, p.expectEOF()
+}
+
+// parseHashOption parses the composite shard key or the hash function:
+// (col1, col2, ...) [PARTITIONS num] [USING 'function']
+func (p *parser) parseHashOption() (sqlparser.PartitionOption, error) {
+	name, err := p.shardKey()
+	if err != nil {
+		return nil, err
+	}
+
+	partOpt := &sqlparser.PartOptHash{Name: name}
+	if p.accept("partitions") {
+		tok := p.next()
+		if tok.typ != tokNumber || strings.Contains(tok.val, ".") {
+			return nil, p.errorf(tok)
+		}
+		if tok.val == "0" {
+			return nil, errors.New("Number of partitions must be a positive integer")
+		}
+		partOpt.PartitionNum = sqlparser.NewIntVal([]byte(tok.val))
+	}
+	if p.accept("using") {
+		tok := p.next()
+		if tok.typ != tokString || tok.val == "" {
+			return nil, p.errorf(tok)
+		}
+		if err = p.expectEOF(); err != nil {
+			return nil, err
+		}
+		return &PartOptHashFunc{PartOptHash: *partOpt, Function: tok.val}, nil
+	}
+	return partOpt, p.expectEOF()
+}
+
+// parseColocateOption parses:
+// [PARTITION BY HASH|LIST|RANGE(col1, ...)] COLOCATE WITH table
+func (p *parser) parseColocateOption() (*PartOptColocate, error) {
+	var err error
+	partOpt := &PartOptColocate{}
+	if p.accept("partition") {
+		if err = p.expect("by"); err != nil {
+			return nil, err
+		}
+		tok := p.next()
+		if !tok.is("hash") && !tok.is("list") && !tok.is("range") {
+			return nil, p.errorf(tok)
+		}
+		partOpt.ShardType = strings.ToUpper(tok.val)
+		if partOpt.Name, err = p.shardKey(); err != nil {
+			return nil, err
+		}
+	}
+	if err = p.expect("colocate", "with"); err != nil {
+		return nil, err
+	}
+	if partOpt.Table, err = p.ident(); err != nil {
+		return nil, err
+	}
+	return partOpt, p.expectEOF()
+}
+
+// parseIntervalOption parses:
+// (col) DAY|MONTH [PREMAKE num] [RETENTION num [DETACH]]
+func (p *parser) parseIntervalOption() (*PartOptInterval, error) {
+	var err error
+	partOpt := &PartOptInterval{Premake: DefaultIntervalPremake}
+	if err = p.expect("("); err != nil {
+		return nil, err
+	}
+	if partOpt.Name, err = p.ident(); err != nil {
+		return nil, err
+	}
+	if err = p.expect(")"); err != nil {
+		return nil, err
+	}
+	tok := p.next()
+	if !tok.is("day") && !tok.is("month") {
+		return nil, p.errorf(tok)
+	}
+	partOpt.Unit = strings.ToUpper(tok.val)
+	if p.accept("premake") {
+		if partOpt.Premake, err = p.number(); err != nil {
+			return nil, err
+		}
+	}
+	if p.accept("retention") {
+		if partOpt.Retention, err = p.number(); err != nil {
+			return nil, err
+		}
+		partOpt.Detach = p.accept("detach")
+	}
+	return partOpt, p.expectEOF()
+}
+
+// parseNeoDBInterval parses the statement after NEODB INTERVAL:
+// STATUS|RUN
+func (p *parser) parseNeoDBInterval() (sqlparser.Statement, error) {
+	var action string
+	tok := p.next()
+	switch {
+	case tok.is("status"):
+		action = IntervalStatusStr
+	case tok.is("run"):
+		action = IntervalRunStr
+	default:
+		return nil, p.errorf(tok)
+	}
+	return &sqlparser.NeoDB{Action: action}, p.expectEOF()
+}
+
+// parseNeoDBRoute parses the statement after NEODB ROUTE:
+// [db.]table VALUE value|(value, ...)
+// statement
+// The values are in the Row, the statement is kept as the string in the Row.
+func (p *parser) parseNeoDBRoute() (sqlparser.Statement, error) {
+	if p.peek().typ == tokEOF {
+		return nil, p.errorf(p.peek())
+	}
+	start := p.peek().pos
+	if table, err := p.tableName(); err == nil && p.accept("value") {
+		row, err := p.valueTuple()
+		if err != nil {
+			return nil, err
+		}
+		return &sqlparser.NeoDB{Action: RouteStr, Table: table, Row: row}, p.expectEOF()
+	}
+	query := strings.TrimSpace(p.sql[start:])
+	return &sqlparser.NeoDB{Action: RouteQueryStr, Row: sqlparser.ValTuple{sqlparser.NewStrVal([]byte(query))}}, nil
+}
+
+// valueTuple parses:
+// value|(value, ...)
+func (p *parser) valueTuple() (sqlparser.ValTuple, error) {
+	var row sqlparser.ValTuple
+	tuple := p.accept("(")
+	for {
+		val, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		row = append(row, val)
+		if !tuple || !p.accept(",") {
+			break
+		}
+	}
+	if tuple {
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+	}
+	return row, nil
+}
+
+// ParseValues parses the literal value or the tuple of the literal values, such as: 1, 'a' or (1, 'a').
+func ParseValues(sql string) ([]*sqlparser.SQLVal, error) {
+	p := newParser(sql, tokenize(sql))
+	row, err := p.valueTuple()
+	if err != nil {
+		return nil, err
+	}
+	vals := make([]*sqlparser.SQLVal, 0, len(row))
+	for _, v := range row {
+		vals = append(vals, v.(*sqlparser.SQLVal))
+	}
+	return vals, p.expectEOF()
+}
+
+// parseLookupIndex parses:
+// CREATE [UNIQUE GLOBAL|LOOKUP] INDEX name ON table(col)
+// DROP [GLOBAL|LOOKUP] INDEX name ON table
+// The index name is the name of the lookup table, the IndexType of the UNIQUE GLOBAL index is 'unique'.
+func (p *parser) parseLookupIndex() (sqlparser.Statement, error) {
+	ddl := &sqlparser.DDL{Action: CreateLookupIndexStr}
+	if p.next().is("drop") {
+		ddl.Action = DropLookupIndexStr
+	}
+	if p.accept("unique") {
+		ddl.IndexType = sqlparser.UniqueStr
+	}
+	p.idx += 2
+
+	name, err := p.ident()
+	if err != nil {
+		return nil, err
+	}
+	ddl.IndexName = name
+	if err := p.expect("on"); err != nil {
+		return nil, err
+	}
+	if ddl.Table, err = p.tableName(); err != nil {
+		return nil, err
+	}
+	ddl.NewName = ddl.Table
+
+	if ddl.Action == CreateLookupIndexStr {
+		if err := p.expect("("); err != nil {
+			return nil, err
+		}
+		col, err := p.ident()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		ddl.IndexOpts = &sqlparser.IndexOptions{
+			Columns: []*sqlparser.IndexColumn{{Column: sqlparser.NewColIdent(col)}},
+		}
+	}
+	return ddl, p.expectEOF()
+}
+
+// number returns the non-negative integer.
+func (p *parser) number() (int, error) {
+	tok := p.next()
+	if tok.typ != tokNumber {
+		return 0, p.errorf(tok)
+	}
+	n, err := strconv.Atoi(tok.val)
+	if err != nil {
+		return 0, p.errorf(tok)
+	}
+	return n, nil
+}
+
+// tableName parses:
+// [db.]table
+func (p *parser) tableName() (sqlparser.TableName, error) {
+	var table sqlparser.TableName
+	name, err := p.ident()
+	if err != nil {
+		return table, err
+	}
+	if p.accept(".") {
+		table.Qualifier = sqlparser.NewTableIdent(name)
+		if name, err = p.ident(); err != nil {
+			return table, err
+		}
+	}
+	table.Name = sqlparser.NewTableIdent(name)
+	return table, nil
+}
+
+// listDefinitions parses:
+// (PARTITION backend VALUES IN (value|DEFAULT, ...), ...)
+func (p *parser) listDefinitions() (sqlparser.PartitionDefinitions, error) {
+	var err error
+	var partDefs sqlparser.PartitionDefinitions
+	if err = p.expect("("); err != nil {
+		return nil, err
+	}
+	for {
+		def := &sqlparser.PartitionDefinition{}
+		if err = p.expect("partition"); err != nil {
+			return nil, err
+		}
+		if def.Backend, err = p.ident(); err != nil {
+			return nil, err
+		}
+		if err = p.expect("values", "in", "("); err != nil {
+			return nil, err
+		}
+		for {
+			if p.accept("default") {
+				def.Row = append(def.Row, &sqlparser.Default{})
+			} else {
+				val, err := p.value()
+				if err != nil {
+					return nil, err
+				}
+				def.Row = append(def.Row, val)
+			}
+			if !p.accept(",") {
+				break
+			}
+		}
+		if err = p.expect(")"); err != nil {
+			return nil, err
+		}
+		partDefs = append(partDefs, def)
+		if !p.accept(",") {
+			break
+		}
+	}
+	if err = p.expect(")"); err != nil {
+		return nil, err
+	}
+	return partDefs, nil
+}
+
+// shardKey parses the shard key columns:
+// (col1, col2, ...)
+// The columns are joined by ',' for the composite shard key.
+func (p *parser) shardKey() (string, error) {
+	var err error
+	var cols []string
+	if err = p.expect("("); err != nil {
+		return "", err
+	}
+	for {
+		col, err := p.ident()
+		if err != nil {
+			return "", err
+		}
+		for _, c := range cols {
+			if strings.EqualFold(c, col) {
+				return "", errors.Errorf("Duplicate column name '%s' in shard key", col)
+			}
+		}
+		cols = append(cols, col)
+		if !p.accept(",") {
+			break
+		}
+	}
+	if err = p.expect(")"); err != nil {
+		return "", err
+	}
+	return strings.Join(cols, ","), nil
+}
@@ -0,0 +1,698 @@
+/*
+ * NeoDB
+ *
+ * Copyright 2021-2030 The NeoDB Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package xparser
+
+import (
+	"testing"
+
+	"github.com/sealdb/mysqlstack/sqlparser"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRange(t *testing.T) {
+	query := "create table t(a int primary key, b int) partition by range(a) (" +
+		"partition backend1 values less than (100), " +
+		"partition `backend2` values less than 200, " +
+		"partition backend3 values less than (maxvalue))"
+	node, err := Parse(query)
+	assert.Nil(t, err)
+	ddl := node.(*sqlparser.DDL)
+	assert.Equal(t, sqlparser.CreateTableStr, ddl.Action)
+	assert.Equal(t, "t", ddl.Table.Name.String())
+	assert.Equal(t, 2, len(ddl.TableSpec.Columns))
+
+	partOpt := ddl.PartitionOption.(*PartOptRange)
+	assert.Equal(t, PartitionTableRange, partOpt.PartitionType())
+	assert.Equal(t, "a", partOpt.Name)
+	assert.Equal(t, 3, len(partOpt.PartDefs))
+	assert.Equal(t, "backend1", partOpt.PartDefs[0].Backend)
+	assert.Equal(t, "100", string(partOpt.PartDefs[0].LessThan.Val))
+	assert.Equal(t, "backend2", partOpt.PartDefs[1].Backend)
+	assert.Equal(t, "200", string(partOpt.PartDefs[1].LessThan.Val))
+	assert.Nil(t, partOpt.PartDefs[2].LessThan)
+}
+
+func TestParseRangeValues(t *testing.T) {
+	query := "create table t(a varchar(32) primary key) " +
+		"/* comment */ PARTITION BY RANGE(a) (" +
+		"PARTITION backend1 VALUES LESS THAN (-10)," +
+		"PARTITION backend2 VALUES LESS THAN (1.5)," +
+		"PARTITION backend3 VALUES LESS THAN ('2023-01-01 00:00:00')," +
+		"PARTITION backend4 VALUES LESS THAN MAXVALUE)"
+	node, err := Parse(query)
+	assert.Nil(t, err)
+	partOpt := node.(*sqlparser.DDL).PartitionOption.(*PartOptRange)
+	assert.Equal(t, sqlparser.IntVal, partOpt.PartDefs[0].LessThan.Type)
+	assert.Equal(t, "-10", string(partOpt.PartDefs[0].LessThan.Val))
+	assert.Equal(t, sqlparser.FloatVal, partOpt.PartDefs[1].LessThan.Type)
+	assert.Equal(t, sqlparser.StrVal, partOpt.PartDefs[2].LessThan.Type)
+	assert.Equal(t, "2023-01-01 00:00:00", string(partOpt.PartDefs[2].LessThan.Val))
+	assert.Nil(t, partOpt.PartDefs[3].LessThan)
+}
+
+func TestParseRangeError(t *testing.T) {
+	querys := []string{
+		"create table t(a int) partition by range(a)",
+		"create table t(a int) partition by range a (partition backend1 values less than (1))",
+		"create table t(a int) partition by range(a) (partition backend1 values in (1))",
+		"create table t(a int) partition by range(a) (partition backend1 values less than (1)",
+		"create table t(a int) partition by range(a) (partition backend1 values less than (1)) xx",
+		"create table t(a int) partition by range(a) (partition backend1 values less than (-'a'))",
+		"create table t(a int) partition by range(a) (partition 1 values less than (1))",
+		"create table t(a int,) partition by range(a) (partition backend1 values less than (1))",
+		"create table t partition by range(a) (partition backend1 values less than (1))",
+	}
+	for _, query := range querys {
+		_, err := Parse(query)
+		assert.NotNil(t, err, query)
+	}
+}
+
+func TestParseCompositeHash(t *testing.T) {
+	query := "create table t(a int, b varchar(32), c int, primary key(a, b)) partition by hash(a, `b`) partitions 8"
+	node, err := Parse(query)
+	assert.Nil(t, err)
+	ddl := node.(*sqlparser.DDL)
+	assert.Equal(t, 3, len(ddl.TableSpec.Columns))
+	partOpt := ddl.PartitionOption.(*sqlparser.PartOptHash)
+	assert.Equal(t, "a,b", partOpt.Name)
+	assert.Equal(t, "8", string(partOpt.PartitionNum.Val))
+
+	node, err = Parse("create table t(a int, b int) partition by hash(a, b)")
+	assert.Nil(t, err)
+	partOpt = node.(*sqlparser.DDL).PartitionOption.(*sqlparser.PartOptHash)
+	assert.Equal(t, "a,b", partOpt.Name)
+	assert.Nil(t, partOpt.PartitionNum)
+}
+
+func TestParseHashFunction(t *testing.T) {
+	node, err := Parse("create table t(a int, b int) partition by hash(a) using 'crc32-mod'")
+	assert.Nil(t, err)
+	partOpt := node.(*sqlparser.DDL).PartitionOption.(*PartOptHashFunc)
+	assert.Equal(t, sqlparser.PartitionTableHash, partOpt.PartitionType())
+	assert.Equal(t, "a", partOpt.Name)
+	assert.Nil(t, partOpt.PartitionNum)
+	assert.Equal(t, "crc32-mod", partOpt.Function)
+
+	node, err = Parse("create table t(a int, b int) PARTITION BY HASH(a, b) PARTITIONS 8 USING \"mod\"")
+	assert.Nil(t, err)
+	partOpt = node.(*sqlparser.DDL).PartitionOption.(*PartOptHashFunc)
+	assert.Equal(t, "a,b", partOpt.Name)
+	assert.Equal(t, "8", string(partOpt.PartitionNum.Val))
+	assert.Equal(t, "mod", partOpt.Function)
+
+	querys := []string{
+		"create table t(a int) partition by hash(a) using",
+		"create table t(a int) partition by hash(a) using mod",
+		"create table t(a int) partition by hash(a) using ''",
+		"create table t(a int) partition by hash(a) using 'mod' xx",
+		"create table t(a int) partition by hash(a) using 'mod' partitions 8",
+	}
+	for _, query := range querys {
+		_, err := Parse(query)
+		assert.NotNil(t, err, query)
+	}
+}
+
+func TestParseCompositeHashError(t *testing.T) {
+	querys := []string{
+		"create table t(a int, b int) partition by hash(a, a)",
+		"create table t(a int, b int) partition by hash(a, b) partitions 0",
+		"create table t(a int, b int) partition by hash(a, b) partitions x",
+		"create table t(a int, b int) partition by hash(a, b",
+		"create table t(a int, b int) partition by hash(a, 1)",
+		"create table t(a int, b int) partition by hash(a, b) xx",
+	}
+	for _, query := range querys {
+		_, err := Parse(query)
+		assert.NotNil(t, err, query)
+	}
+
+	_, err := Parse("create table t(a int, b int) partition by hash(a, A)")
+	assert.Equal(t, "Duplicate column name 'A' in shard key", err.Error())
+}
+
+func TestParseColocate(t *testing.T) {
+	node, err := Parse("create table t2(a int primary key, b int) engine=innodb partition by hash(a) colocate with `t1`")
+	assert.Nil(t, err)
+	ddl := node.(*sqlparser.DDL)
+	assert.Equal(t, "t2", ddl.Table.Name.String())
+	assert.Equal(t, "innodb", ddl.TableSpec.Options.Engine)
+	partOpt := ddl.PartitionOption.(*PartOptColocate)
+	assert.Equal(t, PartitionTableColocate, partOpt.PartitionType())
+	assert.Equal(t, "HASH", partOpt.ShardType)
+	assert.Equal(t, "a", partOpt.Name)
+	assert.Equal(t, "t1", partOpt.Table)
+
+	node, err = Parse("create table t2(a int, b int) PARTITION BY RANGE(a, b) COLOCATE WITH t1")
+	assert.Nil(t, err)
+	partOpt = node.(*sqlparser.DDL).PartitionOption.(*PartOptColocate)
+	assert.Equal(t, "RANGE", partOpt.ShardType)
+	assert.Equal(t, "a,b", partOpt.Name)
+
+	node, err = Parse("create table t2(a int, b int) comment 'colocate with t3' colocate with t1")
+	assert.Nil(t, err)
+	partOpt = node.(*sqlparser.DDL).PartitionOption.(*PartOptColocate)
+	assert.Equal(t, "", partOpt.ShardType)
+	assert.Equal(t, "", partOpt.Name)
+	assert.Equal(t, "t1", partOpt.Table)
+}
+
+func TestParseColocateError(t *testing.T) {
+	querys := []string{
+		"create table t2(a int) colocate with",
+		"create table t2(a int) colocate with t1 xx",
+		"create table t2(a int) colocate with t1.t",
+		"create table t2(a int) partition by global colocate with t1",
+		"create table t2(a int) partition by hash(a) partitions 8 colocate with t1",
+		"create table t2(a int) partition by list(a) (partition backend1 values in (1)) colocate with t1",
+		"create table t2(a int) partition by hash(a, a) colocate with t1",
+	}
+	for _, query := range querys {
+		_, err := Parse(query)
+		assert.NotNil(t, err, query)
+	}
+}
+
+func TestParseInterval(t *testing.T) {
+	node, err := Parse("create table t(ts datetime, a int) engine=innodb partition by interval(`ts`) day")
+	assert.Nil(t, err)
+	ddl := node.(*sqlparser.DDL)
+	assert.Equal(t, "innodb", ddl.TableSpec.Options.Engine)
+	partOpt := ddl.PartitionOption.(*PartOptInterval)
+	assert.Equal(t, PartitionTableInterval, partOpt.PartitionType())
+	assert.Equal(t, &PartOptInterval{Name: "ts", Unit: "DAY", Premake: DefaultIntervalPremake}, partOpt)
+
+	node, err = Parse("create table t(ts datetime) PARTITION BY INTERVAL(ts) MONTH PREMAKE 6 RETENTION 12 DETACH")
+	assert.Nil(t, err)
+	partOpt = node.(*sqlparser.DDL).PartitionOption.(*PartOptInterval)
+	assert.Equal(t, &PartOptInterval{Name: "ts", Unit: "MONTH", Premake: 6, Retention: 12, Detach: true}, partOpt)
+
+	node, err = Parse("create table t(ts datetime) partition by interval(ts) day premake 0 retention 7")
+	assert.Nil(t, err)
+	partOpt = node.(*sqlparser.DDL).PartitionOption.(*PartOptInterval)
+	assert.Equal(t, &PartOptInterval{Name: "ts", Unit: "DAY", Premake: 0, Retention: 7}, partOpt)
+}
+
+func TestParseIntervalError(t *testing.T) {
+	querys := []string{
+		"create table t(ts datetime) partition by interval(ts)",
+		"create table t(ts datetime) partition by interval(ts) year",
+		"create table t(ts datetime) partition by interval(ts, a) day",
+		"create table t(ts datetime) partition by interval(ts) day premake",
+		"create table t(ts datetime) partition by interval(ts) day premake -1",
+		"create table t(ts datetime) partition by interval(ts) day premake 1.5",
+		"create table t(ts datetime) partition by interval(ts) day detach",
+		"create table t(ts datetime) partition by interval(ts) day retention 7 premake 1",
+	}
+	for _, query := range querys {
+		_, err := Parse(query)
+		assert.NotNil(t, err, query)
+	}
+}
+
+func TestParseNeoDBInterval(t *testing.T) {
+	node, err := Parse("neodb interval status")
+	assert.Nil(t, err)
+	assert.Equal(t, &sqlparser.NeoDB{Action: IntervalStatusStr}, node)
+
+	node, err = Parse("NEODB INTERVAL RUN")
+	assert.Nil(t, err)
+	assert.Equal(t, &sqlparser.NeoDB{Action: IntervalRunStr}, node)
+
+	querys := []string{
+		"neodb interval",
+		"neodb interval stop",
+		"neodb interval run now",
+	}
+	for _, query := range querys {
+		_, err := Parse(query)
+		assert.NotNil(t, err, query)
+	}
+}
+
+func TestParseNeoDBRoute(t *testing.T) {
+	node, err := Parse("neodb route db.t value 1")
+	assert.Nil(t, err)
+	assert.Equal(t, &sqlparser.NeoDB{
+		Action: RouteStr,
+		Table:  sqlparser.TableName{Name: sqlparser.NewTableIdent("t"), Qualifier: sqlparser.NewTableIdent("db")},
+		Row:    sqlparser.ValTuple{sqlparser.NewIntVal([]byte("1"))},
+	}, node)
+
+	node, err = Parse("NEODB ROUTE `t` VALUE (-1, 'a', 1.5)")
+	assert.Nil(t, err)
+	assert.Equal(t, &sqlparser.NeoDB{
+		Action: RouteStr,
+		Table:  sqlparser.TableName{Name: sqlparser.NewTableIdent("t")},
+		Row:    sqlparser.ValTuple{sqlparser.NewIntVal([]byte("-1")), sqlparser.NewStrVal([]byte("a")), sqlparser.NewFloatVal([]byte("1.5"))},
+	}, node)
+
+	node, err = Parse("neodb route select * from t where id=1 ")
+	assert.Nil(t, err)
+	assert.Equal(t, &sqlparser.NeoDB{
+		Action: RouteQueryStr,
+		Row:    sqlparser.ValTuple{sqlparser.NewStrVal([]byte("select * from t where id=1"))},
+	}, node)
+
+	querys := []string{
+		"neodb route",
+		"neodb route t value",
+		"neodb route t value (1",
+		"neodb route t value (1, 2) x",
+		"neodb route t value 1 2",
+	}
+	for _, query := range querys {
+		_, err := Parse(query)
+		assert.NotNil(t, err, query)
+	}
+}
+
+func TestParseValues(t *testing.T) {
+	vals, err := ParseValues("(1, 'a')")
+	assert.Nil(t, err)
+	assert.Equal(t, []*sqlparser.SQLVal{sqlparser.NewIntVal([]byte("1")), sqlparser.NewStrVal([]byte("a"))}, vals)
+
+	vals, err = ParseValues("-1.5")
+	assert.Nil(t, err)
+	assert.Equal(t, []*sqlparser.SQLVal{sqlparser.NewFloatVal([]byte("-1.5"))}, vals)
+
+	for _, sql := range []string{"", "a", "(1", "1, 2"} {
+		_, err := ParseValues(sql)
+		assert.NotNil(t, err, sql)
+	}
+}
+
+func TestParseAlterPartition(t *testing.T) {
+	node, err := Parse("alter table db.t add partition (partition backend1 values in (1, 'a'), partition `backend2` values in (default))")
+	assert.Nil(t, err)
+	ddl := node.(*sqlparser.DDL)
+	assert.Equal(t, AlterAddPartitionStr, ddl.Action)
+	assert.Equal(t, "db", ddl.Table.Qualifier.String())
+	assert.Equal(t, "t", ddl.Table.Name.String())
+	partDefs := ddl.PartitionOption.(*sqlparser.PartOptList).PartDefs
+	assert.Equal(t, 2, len(partDefs))
+	assert.Equal(t, "backend1", partDefs[0].Backend)
+	assert.Equal(t, sqlparser.ValTuple{sqlparser.NewIntVal([]byte("1")), sqlparser.NewStrVal([]byte("a"))}, partDefs[0].Row)
+	assert.Equal(t, "backend2", partDefs[1].Backend)
+	assert.Equal(t, sqlparser.ValTuple{&sqlparser.Default{}}, partDefs[1].Row)
+
+	node, err = Parse("ALTER TABLE t DROP PARTITION t_0001, `t_0002`")
+	assert.Nil(t, err)
+	ddl = node.(*sqlparser.DDL)
+	assert.Equal(t, AlterDropPartitionStr, ddl.Action)
+	assert.Equal(t, "t", ddl.NewName.Name.String())
+	assert.Equal(t, 2, len(ddl.Tables))
+	assert.Equal(t, "t_0002", ddl.Tables[1].Name.String())
+}
+
+func TestParseAlterPartitionError(t *testing.T) {
+	querys := []string{
+		"alter table t add partition",
+		"alter table t add partition (partition backend1 values in ())",
+		"alter table t add partition (partition backend1 values in (a))",
+		"alter table t add partition (partition backend1 values less than (1))",
+		"alter table t add partition (partition backend1 values in (1)) xx",
+		"alter table t drop partition",
+		"alter table t drop partition t_0001,",
+	}
+	for _, query := range querys {
+		_, err := Parse(query)
+		assert.NotNil(t, err, query)
+	}
+}
+
+func TestParsePassthrough(t *testing.T) {
+	querys := []string{
+		"select * from t where a='partition by range'",
+		"create table t(a int primary key) partition by hash(a)",
+		"create table t(a int primary key) comment 'partition by range(a)'",
+		"create table t(a int, b int) partition by list(a) (partition backend1 values in (1,2))",
+		"insert into t(a) values(1)",
+		"alter table t add column(b int)",
+		"alter table t drop column b",
+	}
+	for _, query := range querys {
+		want, err := sqlparser.Parse(query)
+		assert.Nil(t, err)
+		got, err := Parse(query)
+		assert.Nil(t, err)
+		assert.Equal(t, want, got)
+	}
+
+	_, err := Parse("create table t(")
+	assert.NotNil(t, err)
+}
+
+func TestParsePlain(t *testing.T) {
+	querys := []string{
+		"select * from t where a=1",
+		"insert into t(a) values(1)",
+		"update t set a=1 where b=2",
+		"create table t(a int primary key) partition by hash(a)",
+		"select NeoDB_window(a) from t",
+		"select /*+ neodb_recursive(c) */ * from t",
+		"with c as (select 1) select * from c",
+		"select sum(a) over (order by b) from t",
+		"update a join b on a.id=b.id set a.x=1",
+	}
+	results := []bool{true, true, true, false, false, false, false, false, false}
+	for i, query := range querys {
+		_, ok := parsePlain(query)
+		assert.Equal(t, results[i], ok, query)
+	}
+}
+
+func TestTokenize(t *testing.T) {
+	sql := "select `a``b`, 'x\\'y', \"z\" -- comment\n from t # comment\n where a>=1.5 and 1abc=2 /* c */"
+	toks := tokenize(sql)
+	want := []struct {
+		typ tokenType
+		val string
+	}{
+		{tokIdent, "select"},
+		{tokQuotedIdent, "a`b"},
+		{tokPunct, ","},
+		{tokString, "x'y"},
+		{tokPunct, ","},
+		{tokString, "z"},
+		{tokIdent, "from"},
+		{tokIdent, "t"},
+		{tokIdent, "where"},
+		{tokIdent, "a"},
+		{tokPunct, ">"},
+		{tokPunct, "="},
+		{tokNumber, "1.5"},
+		{tokIdent, "and"},
+		{tokIdent, "1abc"},
+		{tokPunct, "="},
+		{tokNumber, "2"},
+		{tokEOF, ""},
+	}
+	assert.Equal(t, len(want), len(toks))
+	for i, tok := range toks {
+		assert.Equal(t, want[i].typ, tok.typ)
+		assert.Equal(t, want[i].val, tok.val)
+		if tok.typ != tokEOF && tok.typ != tokString && tok.typ != tokQuotedIdent {
+			assert.Equal(t, tok.val, sql[tok.pos:tok.end])
+		}
+	}
+	assert.True(t, toks[0].is("SELECT"))
+	assert.False(t, toks[1].is("a`b"))
+}
+
+func TestParseLookupIndex(t *testing.T) {
+	node, err := Parse("create lookup index t_name_idx on db.t(`name`)")
+	assert.Nil(t, err)
+	ddl := node.(*sqlparser.DDL)
+	assert.Equal(t, CreateLookupIndexStr, ddl.Action)
+	assert.Equal(t, "t_name_idx", ddl.IndexName)
+	assert.Equal(t, "db", ddl.Table.Qualifier.String())
+	assert.Equal(t, "t", ddl.NewName.Name.String())
+	assert.Equal(t, "name", ddl.IndexOpts.Columns[0].Column.String())
+
+	node, err = Parse("DROP LOOKUP INDEX t_name_idx ON t")
+	assert.Nil(t, err)
+	ddl = node.(*sqlparser.DDL)
+	assert.Equal(t, DropLookupIndexStr, ddl.Action)
+	assert.Equal(t, "t_name_idx", ddl.IndexName)
+	assert.Equal(t, "t", ddl.Table.Name.String())
+	assert.Nil(t, ddl.IndexOpts)
+
+	node, err = Parse("create unique global index t_email_uk on t(email)")
+	assert.Nil(t, err)
+	ddl = node.(*sqlparser.DDL)
+	assert.Equal(t, CreateLookupIndexStr, ddl.Action)
+	assert.Equal(t, sqlparser.UniqueStr, ddl.IndexType)
+	assert.Equal(t, "t_email_uk", ddl.IndexName)
+	assert.Equal(t, "email", ddl.IndexOpts.Columns[0].Column.String())
+
+	node, err = Parse("drop global index t_email_uk on t")
+	assert.Nil(t, err)
+	ddl = node.(*sqlparser.DDL)
+	assert.Equal(t, DropLookupIndexStr, ddl.Action)
+	assert.Equal(t, "", ddl.IndexType)
+	assert.Equal(t, "t_email_uk", ddl.IndexName)
+
+	querys := []string{
+		"create unique global index uk on t",
+		"create lookup index",
+		"create lookup index idx on t",
+		"create lookup index idx on t(a, b)",
+		"create lookup index idx t(a)",
+		"drop lookup index idx on t(a)",
+		"drop lookup index idx",
+	}
+	for _, query := range querys {
+		_, err := Parse(query)
+		assert.NotNil(t, err, query)
+	}
+}
+
+func TestParseWith(t *testing.T) {
+	tcases := []struct {
+		query string
+		want  string
+	}{
+		{
+			query: "with c as (select a from t1 where b > 1) select * from c",
+			want:  "select * from (select a from t1 where b > 1) as c",
+		},
+		{
+			query: "WITH c(x, y) AS (SELECT a, b+1 FROM t1), d AS (SELECT x FROM c) SELECT d.x FROM d JOIN c AS e ON d.x = e.y",
+			want:  "select d.x from (select x from (select a as x, b + 1 as y from t1) as c) as d join (select a as x, b + 1 as y from t1) as e on d.x = e.y",
+		},
+		{
+			query: "with c as (select a from t1) select a from t2 where a in (select a from c) union select a from db.c",
+			want:  "select a from t2 where a in (select a from (select a from t1) as c) union select a from db.c",
+		},
+		// Without RECURSIVE, the name in its own query refers to the table.
+		{
+			query: "with t1 as (select a from t1) select * from t1",
+			want:  "select * from (select a from t1) as t1",
+		},
+	}
+	for _, tcase := range tcases {
+		node, err := Parse(tcase.query)
+		assert.Nil(t, err, tcase.query)
+		assert.Equal(t, tcase.want, sqlparser.String(node), tcase.query)
+	}
+}
+
+func TestParseWithRecursive(t *testing.T) {
+	query := "with recursive t(n) as (select 1 union all select n + 1 from t where n < 5) select * from t"
+	node, err := Parse(query)
+	assert.Nil(t, err)
+	want := "select * from (select 1 as n from dual union all select /*+ neodb_recursive(t) */ n + 1 from t where n < 5) as t"
+	assert.Equal(t, want, sqlparser.String(node))
+
+	sub := node.(*sqlparser.Select).From[0].(*sqlparser.AliasedTableExpr).Expr.(*sqlparser.Subquery)
+	union := sub.Select.(*sqlparser.Union)
+	assert.Equal(t, "t", RecursiveCTE(union.Right))
+	assert.Equal(t, "", RecursiveCTE(union.Left))
+
+	// The hint survives the formatting.
+	node, err = Reparse(want)
+	assert.Nil(t, err)
+	assert.Equal(t, want, sqlparser.String(node))
+
+	// The hint cannot be written by the user.
+	for _, query := range []string{
+		want,
+		"select * from (select 1 as n union all select /*+ NEODB_RECURSIVE(t) */ n + 1 from t where n < 5) as t",
+	} {
+		_, err = Parse(query)
+		assert.EqualError(t, err, "unsupported: reserved.hint.'neodb_recursive'", query)
+	}
+	// The hint in the string is not a comment.
+	_, err = Parse("select '/*+ neodb_recursive(t) */' from t")
+	assert.Nil(t, err)
+
+	// The cte names are case-insensitive.
+	node, err = Parse("with recursive T(n) as (select 1 union all select n + 1 from t where n < 5) select * from t")
+	assert.Nil(t, err)
+	assert.Equal(t, "select * from (select 1 as n from dual union all select /*+ neodb_recursive(T) */ n + 1 from t where n < 5) as t", sqlparser.String(node))
+
+	// Not recursive without the reference.
+	node, err = Parse("with recursive t as (select 1 as n) select * from t")
+	assert.Nil(t, err)
+	assert.Equal(t, "select * from (select 1 as n from dual) as t", sqlparser.String(node))
+}
+
+func TestParseWithError(t *testing.T) {
+	querys := []string{
+		"with",
+		"with c",
+		"with c as select 1",
+		"with c as (select 1",
+		"with c as (select 1)",
+		"with c as (select 1) delete from t",
+		"with c as (select 1), c as (select 2) select * from c",
+		"with c(x, y) as (select 1) select * from c",
+		"with c(x) as (select * from t1) select * from c",
+		"with c as (insert into t values(1)) select * from c",
+		"with recursive t as (select n from t) select * from t",
+		"with recursive t as (select n from t union select 1) select * from t",
+		"with recursive t as (select 1 union select n + 1 from t join t as t2) select * from t",
+		"with recursive t as (select 1 union select n + 1 from t limit 1) select * from t",
+		"with recursive t as (select 1 union all select n + 1 from t order by n) select * from t",
+	}
+	for _, query := range querys {
+		_, err := Parse(query)
+		assert.NotNil(t, err, query)
+	}
+}
+
+func TestParseWindow(t *testing.T) {
+	tcases := []struct {
+		query string
+		want  string
+		specs []string
+	}{
+		{
+			query: "select a, row_number() over (partition by a, b order by c desc) as rn from t1",
+			want:  "select a, neodb_window(row_number(), 'partition by a, b order by c desc') as rn from t1",
+			specs: []string{"row_number() over (partition by a, b order by c desc)"},
+		},
+		{
+			query: "SELECT SUM(a) OVER (ORDER BY b ROWS BETWEEN 1 PRECEDING AND 2 FOLLOWING), lag(a, 1, 0) OVER () FROM t1",
+			want:  "select neodb_window(SUM(a), 'order by b asc rows between 1 preceding and 2 following'), neodb_window(lag(a, 1, 0), '') from t1",
+			specs: []string{"SUM(a) over (order by b asc rows between 1 preceding and 2 following)", "lag(a, 1, 0) over ()"},
+		},
+		{
+			query: "select count(*) over (partition by (a + 1) range unbounded preceding) from t1 where over = 1",
+			want:  "select neodb_window(count(*), 'partition by (a + 1) range between unbounded preceding and current row') from t1 where over = 1",
+			specs: []string{"count(*) over (partition by (a + 1) range between unbounded preceding and current row)"},
+		},
+		{
+			query: "with c as (select a, rank() over (order by 'x''y') from t1) select * from c",
+			want:  "select * from (select a, neodb_window(rank(), 'order by \\'x\\\\\\'y\\' asc') from t1) as c",
+			specs: []string{"rank() over (order by 'x\\'y' asc)"},
+		},
+	}
+	for _, tcase := range tcases {
+		node, err := Parse(tcase.query)
+		assert.Nil(t, err, tcase.query)
+		assert.Equal(t, tcase.want, sqlparser.String(node), tcase.query)
+
+		var specs []string
+		_ = sqlparser.Walk(func(node sqlparser.SQLNode) (bool, error) {
+			if expr, ok := node.(sqlparser.Expr); ok && IsWindow(expr) {
+				w, err := ParseWindow(expr)
+				assert.Nil(t, err, tcase.query)
+				specs = append(specs, sqlparser.String(w))
+				return false, nil
+			}
+			return true, nil
+		}, node)
+		assert.Equal(t, tcase.specs, specs, tcase.query)
+
+		// The rewritten window survives the formatting.
+		again, err := Reparse(sqlparser.String(node))
+		assert.Nil(t, err, tcase.query)
+		assert.Equal(t, tcase.want, sqlparser.String(again), tcase.query)
+	}
+
+	// The name cannot be written by the user.
+	for _, query := range []string{
+		"select neodb_window(sum(a), 'order by b asc') from t1",
+		"select NEODB_WINDOW(rank(), '') from t1",
+		"select `neodb_window`(rank(), '') from t1",
+		"select a from t1 where b = neodb_window(row_number(), '')",
+	} {
+		_, err := Parse(query)
+		assert.EqualError(t, err, "unsupported: reserved.name.'neodb_window'", query)
+	}
+	// The name in the string is not an identifier.
+	_, err := Parse("select 'neodb_window(a)' from t1")
+	assert.Nil(t, err)
+}
+
+func TestParseWindowError(t *testing.T) {
+	querys := []string{
+		"select row_number() over w from t1 window w as (order by a)",
+		"select row_number() over (partition a) from t1",
+		"select row_number() over (partition by) from t1",
+		"select row_number() over (order by a from t1",
+		"select row_number() over (order by a +) from t1",
+		"select sum(a) over (rows between current row and 1 preceding) from t1",
+		"select sum(a) over (rows unbounded following) from t1",
+		"select sum(a) over (rows between 1 preceding and unbounded) from t1",
+		"select sum(a) over (order by a range 1 preceding) from t1",
+		"select sum(a) over (order by a) x) from t1",
+		"select (1) over (order by a) from t1",
+	}
+	for _, query := range querys {
+		_, err := Parse(query)
+		assert.NotNil(t, err, query)
+	}
+
+	_, err := ParseWindow(sqlparser.NewIntVal([]byte("1")))
+	assert.NotNil(t, err)
+}
+
+func TestParseMultiUpdate(t *testing.T) {
+	tcases := []struct {
+		query string
+		want  string
+	}{
+		{
+			query: "update t1 join t2 on t1.id = t2.id set t1.a = t2.b where t2.c = 1",
+			want:  "update t1 join t2 on t1.id = t2.id set t1.a = t2.b where t2.c = 1",
+		},
+		{
+			query: "update /*+ x */ db.t1 as a, t2 set a.a = 1, t2.b = a.b + 1 where a.id = t2.id",
+			want:  "update /*+ x */ db.t1 as a, t2 set a.a = 1, t2.b = a.b + 1 where a.id = t2.id",
+		},
+		{
+			query: "UPDATE t1 LEFT JOIN (t2 JOIN t3 ON t2.id = t3.id) ON t1.id = t2.id SET t1.a = 'set'",
+			want:  "update t1 left join (t2 join t3 on t2.id = t3.id) on t1.id = t2.id set t1.a = 'set'",
+		},
+	}
+	for _, tcase := range tcases {
+		node, err := Parse(tcase.query)
+		assert.Nil(t, err, tcase.query)
+		multi, ok := node.(*MultiUpdate)
+		assert.True(t, ok, tcase.query)
+		assert.Equal(t, tcase.want, sqlparser.String(node), tcase.query)
+
+		var tables []string
+		err = Walk(func(node sqlparser.SQLNode) (bool, error) {
+			if name, ok := node.(sqlparser.TableName); ok && !name.IsEmpty() {
+				tables = append(tables, sqlparser.String(name))
+			}
+			return true, nil
+		}, multi)
+		assert.Nil(t, err, tcase.query)
+		assert.NotEmpty(t, tables, tcase.query)
+	}
+
+	// The single-table update is parsed by the sqlparser.
+	node, err := Parse("update t1 set a = 1 where id in (select id from t2, t3)")
+	assert.Nil(t, err)
+	_, ok := node.(*sqlparser.Update)
+	assert.True(t, ok)
+
+	node, err = Parse("update T1 join db.T2 set T1.a = 1 where T2.b = 1")
+	assert.Nil(t, err)
+	assert.Equal(t, "update t1 join db.t2 set t1.a = 1 where t2.b = 1", sqlparser.String(LowerCaseTableNames(node)))
+}
+
+func TestParseMultiUpdateError(t *testing.T) {
+	querys := []string{
+		"update t1 join t2 set t1.a = 1 order by t1.a",
+		"update t1, t2 set t1.a = 1 limit 1",
+		"update t1 join t2 on set t1.a = 1",
+		"update t1 join t2 set t1.a = ",
+	}
+	for _, query := range querys {
+		_, err := Parse(query)
+		assert.NotNil(t, err, query)
+	}
+}
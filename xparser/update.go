@@ -0,0 +1,85 @@
+/*
+ * NeoDB
+ *
+ * Copyright 2021-2030 The NeoDB Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package xparser
+
+import (
+	"github.com/pkg/errors"
+	"github.com/sealdb/mysqlstack/sqlparser"
+)
+
+// parseMultiUpdate parses:
+// UPDATE table_references SET assignment, ... [WHERE expr]
+// The sqlparser only understands the single-table update, the table references are parsed
+// as the FROM of a select, and the rest is parsed as the update of a placeholder table.
+// Returns false if the update has only one table.
+func parseMultiUpdate(sql string, toks []token) (sqlparser.Statement, bool, error) {
+	depth, multi := 0, false
+	for _, tok := range toks[1:] {
+		switch {
+		case tok.is("("):
+			depth++
+		case tok.is(")"):
+			depth--
+		case depth == 0 && (tok.is(",") || tok.is("join")):
+			multi = true
+		case depth == 0 && tok.is("set"):
+			if !multi {
+				return nil, false, nil
+			}
+			stmt, err := sqlparser.Parse("select 1 from " + sql[toks[1].pos:tok.pos])
+			if err != nil {
+				return nil, true, err
+			}
+			refs := stmt.(*sqlparser.Select).From
+			if stmt, err = sqlparser.Parse(sql[:toks[1].pos] + "`dual` " + sql[tok.pos:]); err != nil {
+				return nil, true, err
+			}
+			node := stmt.(*sqlparser.Update)
+			if len(node.OrderBy) > 0 {
+				return nil, true, errors.New("Incorrect usage of UPDATE and ORDER BY")
+			}
+			if node.Limit != nil {
+				return nil, true, errors.New("Incorrect usage of UPDATE and LIMIT")
+			}
+			node.Table = sqlparser.TableName{}
+			return &MultiUpdate{Update: node, TableRefs: refs}, true, nil
+		}
+	}
+	return nil, false, nil
+}
+
+// parts returns the nodes of the multiple-table update which the sqlparser knows.
+func (node *MultiUpdate) parts() []sqlparser.SQLNode {
+	parts := []sqlparser.SQLNode{node.TableRefs, node.Exprs}
+	if node.Where != nil {
+		parts = append(parts, node.Where)
+	}
+	return parts
+}
+
+// Walk calls the visit on the nodes of the statement, the MultiUpdate is walked by its parts.
+func Walk(visit sqlparser.Visit, node sqlparser.SQLNode) error {
+	if node, ok := node.(*MultiUpdate); ok {
+		return sqlparser.Walk(visit, node.parts()...)
+	}
+	return sqlparser.Walk(visit, node)
+}
+
+// LowerCaseTableNames lowers the table names of the statement, the MultiUpdate is rewritten by its parts.
+func LowerCaseTableNames(node sqlparser.Statement) sqlparser.Statement {
+	if node, ok := node.(*MultiUpdate); ok {
+		node.TableRefs = sqlparser.LowerCaseTableNames(node.TableRefs).(sqlparser.TableExprs)
+		node.Exprs = sqlparser.LowerCaseTableNames(node.Exprs).(sqlparser.UpdateExprs)
+		if node.Where != nil {
+			node.Where = sqlparser.LowerCaseTableNames(node.Where).(*sqlparser.Where)
+		}
+		return node
+	}
+	return sqlparser.LowerCaseTableNames(node).(sqlparser.Statement)
+}
@@ -0,0 +1,269 @@
+/*
+ * NeoDB
+ *
+ * Copyright 2021-2030 The NeoDB Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package xparser
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/sealdb/mysqlstack/sqlparser"
+)
+
+// hasWindow returns true if the toks contain the OVER clause of the window function.
+func hasWindow(toks []token) bool {
+	for i := 1; i < len(toks)-1; i++ {
+		if toks[i].is("over") && toks[i-1].is(")") {
+			return true
+		}
+	}
+	return false
+}
+
+// rewriteWindows rewrites the window functions which the sqlparser doesn't understand:
+// func(args) OVER ([PARTITION BY expr, ...] [ORDER BY expr [ASC|DESC], ...] [frame])
+// to the function `neodb_window(func(args), 'spec')`, the spec is kept as the string
+// and parsed by ParseWindow.
+func rewriteWindows(sql string, toks []token) (string, error) {
+	var buf strings.Builder
+	last := 0
+	for i := 1; i < len(toks)-1; i++ {
+		if !toks[i].is("over") || !toks[i-1].is(")") {
+			continue
+		}
+		// The named window is not supported, such as: OVER w.
+		if !toks[i+1].is("(") {
+			return "", errors.New("unsupported: named.window")
+		}
+
+		// The function: name(args).
+		start := matchParen(toks, i-1, -1)
+		if start < 1 || toks[start-1].typ != tokIdent || toks[start-1].pos < last {
+			return "", (&parser{sql: sql}).errorf(toks[i])
+		}
+		fn := toks[start-1]
+
+		// The window spec: (spec).
+		end := matchParen(toks, i+1, 1)
+		if end < 0 {
+			return "", (&parser{sql: sql}).errorf(toks[len(toks)-1])
+		}
+		specToks := append(toks[i+2:end:end], token{typ: tokEOF, pos: toks[end].pos, end: toks[end].pos})
+		p := newParser(sql, specToks)
+		spec, err := p.parseWindowSpec()
+		if err != nil {
+			return "", err
+		}
+		if err := p.expectEOF(); err != nil {
+			return "", err
+		}
+
+		buf.WriteString(sql[last:fn.pos])
+		buf.WriteString(WindowFuncName)
+		buf.WriteString("(")
+		buf.WriteString(sql[fn.pos:toks[i-1].end])
+		buf.WriteString(", ")
+		buf.WriteString(sqlparser.String(sqlparser.NewStrVal([]byte(sqlparser.String(spec)))))
+		buf.WriteString(")")
+		last = toks[end].end
+		i = end
+	}
+	buf.WriteString(sql[last:])
+	return buf.String(), nil
+}
+
+// matchParen returns the index of the parenthesis matching the toks[idx],
+// step is -1 to search backward, -1 is returned if not found.
+func matchParen(toks []token, idx, step int) int {
+	depth := 0
+	for i := idx; i >= 0 && i < len(toks); i += step {
+		switch {
+		case toks[i].is("("):
+			depth += step
+		case toks[i].is(")"):
+			depth -= step
+		}
+		if depth == 0 {
+			return i
+		}
+	}
+	return -1
+}
+
+// parseWindowSpec parses:
+// [PARTITION BY expr, ...] [ORDER BY expr [ASC|DESC], ...] [ROWS|RANGE frame]
+func (p *parser) parseWindowSpec() (*WindowSpec, error) {
+	spec := &WindowSpec{}
+	if p.accept("partition") {
+		if err := p.expect("by"); err != nil {
+			return nil, err
+		}
+		orderBy, err := p.exprList("order", FrameRows, FrameRange)
+		if err != nil {
+			return nil, err
+		}
+		for _, order := range orderBy {
+			spec.PartitionBy = append(spec.PartitionBy, order.Expr)
+		}
+	}
+	if p.accept("order") {
+		if err := p.expect("by"); err != nil {
+			return nil, err
+		}
+		orderBy, err := p.exprList(FrameRows, FrameRange)
+		if err != nil {
+			return nil, err
+		}
+		spec.OrderBy = orderBy
+	}
+
+	unit := p.peek()
+	if !unit.is(FrameRows) && !unit.is(FrameRange) {
+		return spec, nil
+	}
+	p.next()
+	frame := &WindowFrame{Unit: strings.ToLower(unit.val), End: FrameBound{Typ: BoundCurrentRow}}
+	var err error
+	if p.accept("between") {
+		if frame.Start, err = p.frameBound(); err != nil {
+			return nil, err
+		}
+		if err = p.expect("and"); err != nil {
+			return nil, err
+		}
+		if frame.End, err = p.frameBound(); err != nil {
+			return nil, err
+		}
+	} else if frame.Start, err = p.frameBound(); err != nil {
+		return nil, err
+	}
+	if frame.Start.Typ == BoundUnboundedFollowing || frame.End.Typ == BoundUnboundedPreceding ||
+		boundOrder(frame.Start) > boundOrder(frame.End) {
+		return nil, errors.Errorf("unsupported: invalid.window.frame.'%s'", sqlparser.String(frame))
+	}
+	if frame.Unit == FrameRange && (frame.Start.Typ == BoundPreceding || frame.Start.Typ == BoundFollowing ||
+		frame.End.Typ == BoundPreceding || frame.End.Typ == BoundFollowing) {
+		return nil, errors.Errorf("unsupported: window.frame.'%s'", sqlparser.String(frame))
+	}
+	spec.Frame = frame
+	return spec, nil
+}
+
+// exprList parses the exprs separated by the comma until the keywords at the outermost level,
+// the exprs are parsed by the sqlparser as the order by list.
+func (p *parser) exprList(ends ...string) (sqlparser.OrderBy, error) {
+	isEnd := func(tok token) bool {
+		for _, end := range ends {
+			if tok.is(end) {
+				return true
+			}
+		}
+		return false
+	}
+
+	first := p.peek()
+	depth := 0
+	for tok := p.peek(); tok.typ != tokEOF && (depth > 0 || !isEnd(tok)); tok = p.peek() {
+		switch {
+		case tok.is("("):
+			depth++
+		case tok.is(")"):
+			depth--
+		}
+		p.next()
+	}
+	if first.pos == p.peek().pos {
+		return nil, p.errorf(first)
+	}
+
+	stmt, err := sqlparser.Parse("select 1 from dual order by " + p.sql[first.pos:p.peek().pos])
+	if err != nil {
+		return nil, p.errorf(first)
+	}
+	return stmt.(*sqlparser.Select).OrderBy, nil
+}
+
+// frameBound parses:
+// UNBOUNDED PRECEDING|UNBOUNDED FOLLOWING|CURRENT ROW|n PRECEDING|n FOLLOWING
+func (p *parser) frameBound() (FrameBound, error) {
+	switch {
+	case p.accept("unbounded"):
+		if p.accept("preceding") {
+			return FrameBound{Typ: BoundUnboundedPreceding}, nil
+		}
+		if err := p.expect("following"); err != nil {
+			return FrameBound{}, err
+		}
+		return FrameBound{Typ: BoundUnboundedFollowing}, nil
+	case p.accept("current"):
+		if err := p.expect("row"); err != nil {
+			return FrameBound{}, err
+		}
+		return FrameBound{Typ: BoundCurrentRow}, nil
+	}
+	n, err := p.number()
+	if err != nil {
+		return FrameBound{}, err
+	}
+	if p.accept("preceding") {
+		return FrameBound{Typ: BoundPreceding, Offset: n}, nil
+	}
+	if err := p.expect("following"); err != nil {
+		return FrameBound{}, err
+	}
+	return FrameBound{Typ: BoundFollowing, Offset: n}, nil
+}
+
+// boundOrder returns the order of the bound types, the start must not be after the end.
+func boundOrder(bound FrameBound) int {
+	switch bound.Typ {
+	case BoundUnboundedPreceding:
+		return 0
+	case BoundPreceding:
+		return 1
+	case BoundCurrentRow:
+		return 2
+	case BoundFollowing:
+		return 3
+	}
+	return 4
+}
+
+// IsWindow returns true if the expr is the window function rewritten by Parse.
+func IsWindow(expr sqlparser.SQLNode) bool {
+	node, ok := expr.(*sqlparser.FuncExpr)
+	if !ok || !node.Qualifier.IsEmpty() || !node.Name.EqualString(WindowFuncName) || len(node.Exprs) != 2 {
+		return false
+	}
+	_, ok = node.Exprs[1].(*sqlparser.AliasedExpr)
+	return ok
+}
+
+// ParseWindow returns the window function of the expr rewritten by Parse.
+func ParseWindow(expr sqlparser.Expr) (*WindowFunc, error) {
+	if !IsWindow(expr) {
+		return nil, errors.Errorf("unsupported: '%s'.is.not.a.window.function", sqlparser.String(expr))
+	}
+	node := expr.(*sqlparser.FuncExpr)
+	fn, ok := node.Exprs[0].(*sqlparser.AliasedExpr)
+	val, isVal := node.Exprs[1].(*sqlparser.AliasedExpr).Expr.(*sqlparser.SQLVal)
+	if !ok || !isVal || val.Type != sqlparser.StrVal {
+		return nil, errors.Errorf("unsupported: '%s'.is.not.a.window.function", sqlparser.String(expr))
+	}
+
+	spec := string(val.Val)
+	p := newParser(spec, tokenize(spec))
+	ws, err := p.parseWindowSpec()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expectEOF(); err != nil {
+		return nil, err
+	}
+	return &WindowFunc{Func: fn.Expr, Spec: ws}, nil
+}
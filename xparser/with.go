@@ -0,0 +1,274 @@
+/*
+ * NeoDB
+ *
+ * Copyright 2021-2030 The NeoDB Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package xparser
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/sealdb/mysqlstack/sqlparser"
+)
+
+const (
+	// recursiveHintName is reserved, the user-written hint is rejected by the Parse.
+	recursiveHintName   = "neodb_recursive"
+	recursiveHintPrefix = "/*+" + recursiveHintName + "("
+	recursiveHintSuffix = ")*/"
+)
+
+// cte is a common table expression of the WITH clause.
+type cte struct {
+	name string
+	cols []string
+	// query is the cte's query with the former ctes inlined,
+	// it is parsed again on every reference.
+	query string
+}
+
+// parseWith parses:
+// WITH [RECURSIVE] name [(col, ...)] AS (query) [, ...] statement
+// The ctes are inlined into the statement as the derived tables, the sqlparser doesn't
+// understand the WITH clause. The recursive member of the recursive cte is marked by the
+// hint `/*+ neodb_recursive(name) */`, which is executed iteratively by the proxy.
+// The cte names are case-insensitive.
+func parseWith(sql string, toks []token) (sqlparser.Statement, error) {
+	p := newParser(sql, toks[1:])
+	recursive := p.accept("recursive")
+
+	var ctes []*cte
+	for {
+		c, body, err := p.parseCTE()
+		if err != nil {
+			return nil, err
+		}
+		for _, former := range ctes {
+			if strings.EqualFold(former.name, c.name) {
+				return nil, errors.Errorf("Not unique table/alias: '%s'", c.name)
+			}
+		}
+		if c.query, err = buildCTE(c, body, ctes, recursive); err != nil {
+			return nil, err
+		}
+		ctes = append(ctes, c)
+		if !p.accept(",") {
+			break
+		}
+	}
+
+	if p.peek().typ == tokEOF {
+		return nil, p.errorf(p.peek())
+	}
+	stmt, err := sqlparser.Parse(sql[p.peek().pos:])
+	if err != nil {
+		return nil, err
+	}
+	sel, ok := stmt.(sqlparser.SelectStatement)
+	if !ok {
+		return nil, errors.New("unsupported: with.clause.only.supports.select")
+	}
+	if err := inlineCTEs(sel, ctes); err != nil {
+		return nil, err
+	}
+	return sel, nil
+}
+
+// parseCTE parses:
+// name [(col, ...)] AS (query)
+// The query is returned as the string.
+func (p *parser) parseCTE() (*cte, string, error) {
+	var err error
+	c := &cte{}
+	if c.name, err = p.ident(); err != nil {
+		return nil, "", err
+	}
+	if p.accept("(") {
+		for {
+			col, err := p.ident()
+			if err != nil {
+				return nil, "", err
+			}
+			c.cols = append(c.cols, col)
+			if !p.accept(",") {
+				break
+			}
+		}
+		if err = p.expect(")"); err != nil {
+			return nil, "", err
+		}
+	}
+	if err = p.expect("as"); err != nil {
+		return nil, "", err
+	}
+
+	open := p.next()
+	if !open.is("(") {
+		return nil, "", p.errorf(open)
+	}
+	for depth := 1; ; {
+		tok := p.next()
+		switch {
+		case tok.typ == tokEOF:
+			return nil, "", p.errorf(tok)
+		case tok.is("("):
+			depth++
+		case tok.is(")"):
+			if depth--; depth == 0 {
+				return c, p.sql[open.end:tok.pos], nil
+			}
+		}
+	}
+}
+
+// buildCTE parses the cte's query and inlines the former ctes into it,
+// returns the query with the columns renamed by the column list.
+func buildCTE(c *cte, body string, ctes []*cte, recursive bool) (string, error) {
+	stmt, err := sqlparser.Parse(body)
+	if err != nil {
+		return "", err
+	}
+	sel, ok := stmt.(sqlparser.SelectStatement)
+	if !ok {
+		return "", errors.Errorf("unsupported: cte.'%s'.is.not.a.select", c.name)
+	}
+	if err := inlineCTEs(sel, ctes); err != nil {
+		return "", err
+	}
+
+	// Without RECURSIVE, the name in its own query refers to the table.
+	if recursive && countRefs(sel, c.name) > 0 {
+		if err := markRecursive(sel, c.name); err != nil {
+			return "", err
+		}
+	}
+	if len(c.cols) > 0 {
+		if err := renameColumns(sel, c.cols); err != nil {
+			return "", err
+		}
+	}
+	return sqlparser.String(sel), nil
+}
+
+// markRecursive checks the recursive cte is `anchor UNION [ALL] recursive_member`,
+// the recursive member refers to the cte exactly once, then marks it by the hint.
+func markRecursive(sel sqlparser.SelectStatement, name string) error {
+	union, ok := sel.(*sqlparser.Union)
+	if !ok {
+		return errors.Errorf("unsupported: recursive.cte.'%s'.must.be.a.union", name)
+	}
+	if len(union.OrderBy) > 0 || union.Limit != nil {
+		return errors.Errorf("unsupported: order.by.or.limit.in.recursive.cte.'%s'", name)
+	}
+
+	member := union.Right
+	if paren, ok := member.(*sqlparser.ParenSelect); ok {
+		member = paren.Select
+	}
+	right, ok := member.(*sqlparser.Select)
+	if !ok || countRefs(union.Left, name) > 0 || countRefs(right, name) != 1 {
+		return errors.Errorf("unsupported: recursive.cte.'%s'.must.have.one.recursive.member.referring.to.it.once", name)
+	}
+	if len(right.GroupBy) > 0 || len(right.OrderBy) > 0 || right.Limit != nil || right.Distinct != "" {
+		return errors.Errorf("unsupported: group.by.order.by.limit.or.distinct.in.recursive.member.of.'%s'", name)
+	}
+	right.Comments = sqlparser.Comments{[]byte(fmt.Sprintf("/*+ %s(%s) */", recursiveHintName, name))}
+	union.Right = right
+	return nil
+}
+
+// RecursiveCTE returns the name of the recursive cte if the select is the recursive member
+// marked by the WITH RECURSIVE, otherwise returns empty. The hint cannot be written by the
+// user, which is rejected by the Parse.
+func RecursiveCTE(sel sqlparser.SelectStatement) string {
+	if paren, ok := sel.(*sqlparser.ParenSelect); ok {
+		sel = paren.Select
+	}
+	node, ok := sel.(*sqlparser.Select)
+	if !ok {
+		return ""
+	}
+	for _, comment := range node.Comments {
+		hint := strings.Replace(string(comment), " ", "", -1)
+		if strings.HasPrefix(hint, recursiveHintPrefix) && strings.HasSuffix(hint, recursiveHintSuffix) {
+			return hint[len(recursiveHintPrefix) : len(hint)-len(recursiveHintSuffix)]
+		}
+	}
+	return ""
+}
+
+// renameColumns sets the column list as the aliases of the first select.
+func renameColumns(sel sqlparser.SelectStatement, cols []string) error {
+	for {
+		switch node := sel.(type) {
+		case *sqlparser.Union:
+			sel = node.Left
+			continue
+		case *sqlparser.ParenSelect:
+			sel = node.Select
+			continue
+		case *sqlparser.Select:
+			if len(node.SelectExprs) != len(cols) {
+				return errors.New("In definition of view, derived table or common table expression, SELECT list and column names list have different column counts")
+			}
+			for i, expr := range node.SelectExprs {
+				aliased, ok := expr.(*sqlparser.AliasedExpr)
+				if !ok {
+					return errors.New("unsupported: star.in.cte.with.column.list")
+				}
+				aliased.As = sqlparser.NewColIdent(cols[i])
+			}
+		}
+		return nil
+	}
+}
+
+// inlineCTEs replaces the references of the ctes with the derived tables.
+func inlineCTEs(node sqlparser.SQLNode, ctes []*cte) error {
+	var err error
+	_ = sqlparser.Walk(func(node sqlparser.SQLNode) (bool, error) {
+		expr, ok := node.(*sqlparser.AliasedTableExpr)
+		if !ok || err != nil {
+			return err == nil, nil
+		}
+		table, ok := expr.Expr.(sqlparser.TableName)
+		if !ok || !table.Qualifier.IsEmpty() {
+			return true, nil
+		}
+		for _, c := range ctes {
+			if !strings.EqualFold(c.name, table.Name.String()) {
+				continue
+			}
+			var stmt sqlparser.Statement
+			if stmt, err = sqlparser.Parse(c.query); err != nil {
+				return false, nil
+			}
+			expr.Expr = &sqlparser.Subquery{Select: stmt.(sqlparser.SelectStatement)}
+			if expr.As.IsEmpty() {
+				expr.As = table.Name
+			}
+			return false, nil
+		}
+		return true, nil
+	}, node)
+	return err
+}
+
+// countRefs returns the count of the references of the cte in the node.
+func countRefs(node sqlparser.SQLNode, name string) int {
+	cnt := 0
+	_ = sqlparser.Walk(func(node sqlparser.SQLNode) (bool, error) {
+		if expr, ok := node.(*sqlparser.AliasedTableExpr); ok {
+			if table, ok := expr.Expr.(sqlparser.TableName); ok && table.Qualifier.IsEmpty() && strings.EqualFold(table.Name.String(), name) {
+				cnt++
+			}
+		}
+		return true, nil
+	}, node)
+	return cnt
+}